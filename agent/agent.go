@@ -3,14 +3,19 @@ package agent
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"hash/fnv"
+	"image"
 	"image/png"
 	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"time"
 
-	"github.com/df07/go-progressive-raytracer/pkg/integrator"
-	"github.com/df07/go-progressive-raytracer/pkg/renderer"
 	"github.com/df07/scene-llm/agent/llm"
 )
 
@@ -20,6 +25,16 @@ type Agent struct {
 	modelID      string          // Model ID (e.g., "gemini-2.5-flash")
 	events       chan<- AgentEvent
 	sceneManager *SceneManager
+
+	thinkingBudget *int          // Reasoning token budget (nil = provider default)
+	maxTurns       int           // Model calls allowed per message (0 = defaultMaxTurns)
+	callTimeout    time.Duration // Longest a single model call may take (0 = defaultCallTimeout)
+	renderQuality  RenderQuality // Quality chosen via set_render_quality (empty = not set)
+	denoise        bool          // Denoise renders, chosen via set_render_quality
+	integrator     string        // Integrator chosen via set_render_quality (empty = path tracing)
+	clampIndirect  float64       // Firefly clamp threshold chosen via set_render_quality (0 = off)
+	postProcess    PostProcess   // Effects chosen via set_post_process
+	outputDir      string        // Directory render_scene may write files to (empty = writing disabled)
 }
 
 // NewWithProvider creates an agent using the new provider interface
@@ -37,36 +52,162 @@ func (a *Agent) SetEventsChannel(events chan<- AgentEvent) {
 	a.events = events
 }
 
+// SetModel switches the provider and model used for the agent's next message
+// The scene and settings are kept. It must not be called while ProcessMessage is running.
+func (a *Agent) SetModel(provider llm.LLMProvider, modelID string) {
+	a.provider = provider
+	a.modelID = modelID
+}
+
+// ModelID returns the model the agent sends messages to
+func (a *Agent) ModelID() string {
+	return a.modelID
+}
+
+// SetThinkingBudget sets the reasoning token budget passed to the provider
+// 0 disables thinking, a negative budget restores the provider default
+func (a *Agent) SetThinkingBudget(budget int) {
+	if budget < 0 {
+		a.thinkingBudget = nil
+		return
+	}
+	a.thinkingBudget = &budget
+}
+
+// defaultMaxTurns is how many model calls ProcessMessage makes per message unless SetMaxTurns changes it
+const defaultMaxTurns = 10
+
+// maxRepeatedFailingTurns is how many turns in a row may make the same failing calls before
+// ProcessMessage gives up. Retrying the same call once or twice after an error is normal, so
+// only a run of identical turns where every call fails with the same errors counts.
+const maxRepeatedFailingTurns = 3
+
+// MaxTurnsLimit is the most turns a client may allow per message, which keeps a single
+// message's cost bounded
+const MaxTurnsLimit = 50
+
+// SetMaxTurns sets how many model calls ProcessMessage makes for a message before stopping
+// 0 or a negative value restores the default of 10.
+func (a *Agent) SetMaxTurns(turns int) {
+	a.maxTurns = max(turns, 0)
+}
+
+// MaxTurns returns how many model calls ProcessMessage makes for a message before stopping
+func (a *Agent) MaxTurns() int {
+	if a.maxTurns > 0 {
+		return a.maxTurns
+	}
+	return defaultMaxTurns
+}
+
+// defaultCallTimeout is how long a model call may take unless SetCallTimeout changes it
+// It is generous, since reasoning models can think for minutes, and only guards against hung calls.
+const defaultCallTimeout = 5 * time.Minute
+
+// maxTimeoutRetries is how many times a model call that timed out is retried before giving up
+const maxTimeoutRetries = 1
+
+// SetCallTimeout sets how long each model call may take before it is abandoned
+// 0 or a negative value restores the default of 5 minutes.
+func (a *Agent) SetCallTimeout(timeout time.Duration) {
+	a.callTimeout = max(timeout, 0)
+}
+
+// CallTimeout returns how long each model call may take before it is abandoned
+func (a *Agent) CallTimeout() time.Duration {
+	if a.callTimeout > 0 {
+		return a.callTimeout
+	}
+	return defaultCallTimeout
+}
+
+// SetOutputDir lets render_scene write renders to files under dir, for headless use
+// An empty dir disables writing files, which is the default.
+func (a *Agent) SetOutputDir(dir string) {
+	a.outputDir = dir
+}
+
+// RenderQuality returns the render quality chosen by the model, or empty if it hasn't chosen one
+func (a *Agent) RenderQuality() RenderQuality {
+	return a.renderQuality
+}
+
+// Denoise reports whether the model has turned on denoising for renders
+func (a *Agent) Denoise() bool {
+	return a.denoise
+}
+
+// Integrator returns the integrator chosen by the model, empty for the default
+func (a *Agent) Integrator() string {
+	return a.integrator
+}
+
+// ClampIndirect returns the firefly clamp threshold chosen by the model, 0 if clamping is off
+func (a *Agent) ClampIndirect() float64 {
+	return a.clampIndirect
+}
+
+// PostProcess returns the post-processing effects chosen by the model
+func (a *Agent) PostProcess() PostProcess {
+	return a.postProcess
+}
+
+// SceneRenderSettings returns the model's render choices and the current scene's shadow catchers
+// and complexity
+func (a *Agent) SceneRenderSettings() SceneRenderSettings {
+	return SceneRenderSettings{
+		Quality:        a.renderQuality,
+		Denoise:        a.denoise,
+		Integrator:     a.integrator,
+		ClampIndirect:  a.clampIndirect,
+		PostProcess:    a.postProcess,
+		ShadowCatchers: a.sceneManager.ShadowCatcherPass(),
+		Complexity:     a.sceneManager.Complexity(),
+	}
+}
+
 // GetSceneManager returns the scene manager for this agent
 func (a *Agent) GetSceneManager() *SceneManager {
 	return a.sceneManager
 }
 
 // ProcessMessage handles a conversation with agentic loop and emits events
-// Returns the updated conversation history including assistant responses and function calls
+// Returns the updated conversation history including assistant responses and function calls.
+// The agent works on its own copy of conversation for the whole turn and never writes to
+// the caller's slice, so callers replace their history with the returned one when it ends.
 func (a *Agent) ProcessMessage(ctx context.Context, conversation []llm.Message) ([]llm.Message, error) {
 	if a.provider == nil {
 		return nil, fmt.Errorf("agent has no provider - use NewWithProvider")
 	}
-	const maxTurns = 10
+	maxTurns := a.MaxTurns()
 
 	// Send processing event
 	a.events <- NewProcessingEvent("🤖 Processing your request...")
 
+	// Tell the user when a fallback provider has to switch providers
+	ctx = llm.WithFallbackHandler(ctx, func(from, to string, err error) {
+		a.events <- NewProcessingEvent(fmt.Sprintf("⚠️ %s failed (%v), trying %s...", from, err, to))
+	})
+
 	// Build scene context from our internal scene manager
 	sceneContext := a.sceneManager.BuildContext()
 
+	// Models without vision can't use rendered images, so render_scene is only offered to vision models
+	supportsVision := a.provider.SupportsVision()
+
 	// Build system prompt with scene context
-	systemPrompt := buildSystemPrompt(sceneContext)
+	systemPrompt := buildSystemPrompt(sceneContext, supportsVision)
 
 	// Get tool declarations in provider-agnostic format
-	tools := getAllTools()
+	tools := getToolsForProvider(supportsVision, a.outputDir != "")
 
-	// Work with conversation directly (already in internal format)
-	messages := conversation
+	// Copy the conversation (already in internal format) so appends can't write into the caller's backing array
+	messages := slices.Clone(conversation)
 
 	// Agentic loop
 	turnCount := 0
+	var lastFailure uint64 // Signature of the last turn whose calls all failed, 0 if the last turn made progress
+	repeatedFailures := 0
 	for {
 		// Check turn limit
 		if turnCount >= maxTurns {
@@ -75,14 +216,24 @@ func (a *Agent) ProcessMessage(ctx context.Context, conversation []llm.Message)
 			return messages, nil
 		}
 
+		turnStart := time.Now()
+
+		// Full history is still returned to the caller
+		history := trimConversation(messages, maxConversationTokens)
+		if !supportsVision {
+			history = withoutImages(history)
+		}
+
 		// Generate content using provider with new request struct
 		req := &llm.GenerateRequest{
 			Model:        a.modelID,
 			SystemPrompt: systemPrompt,
-			Messages:     messages,
+			Messages:     history,
 			Tools:        tools,
+
+			ThinkingBudget: a.thinkingBudget,
 		}
-		response, err := a.provider.GenerateContent(ctx, req)
+		response, err := a.generateContent(ctx, req)
 		if err != nil {
 			log.Printf("Failed to generate content: %v", err)
 			// Check if this is a context cancellation
@@ -102,6 +253,10 @@ func (a *Agent) ProcessMessage(ctx context.Context, conversation []llm.Message)
 
 		var functionCalls []*llm.FunctionCall
 		var hasToolRequests bool
+		var done bool // The model called the done tool
+
+		// Summarized once the turn is done, including the final turn without tool calls
+		telemetry := TelemetryEvent{ModelID: a.modelID, Turn: turnCount + 1, Tools: []string{}, Usage: response.Usage}
 
 		// Process response parts
 		for _, part := range response.Parts {
@@ -120,46 +275,84 @@ func (a *Agent) ProcessMessage(ctx context.Context, conversation []llm.Message)
 
 		// If no function calls, we're done
 		if len(functionCalls) == 0 {
+			telemetry.ElapsedMs = time.Since(turnStart).Milliseconds()
+			a.events <- telemetry
 			break
 		}
 
 		// Execute function calls and collect results
 		var functionResponses []llm.Part
-		for _, fc := range functionCalls {
+		var toolResults []ToolResult
+		for i, fc := range functionCalls {
+			if fc.ID == "" {
+				// Each response must name the call it answers. fc points into the assistant message
+				// just appended, so the conversation records the ID too, and that message's index
+				// keeps it unique across the conversation.
+				fc.ID = fmt.Sprintf("call_%d_%d", len(messages)-1, i+1)
+			}
+			telemetry.Tools = append(telemetry.Tools, fc.Name)
 			operation := parseToolRequestFromFunctionCall(fc)
-			if operation != nil {
-				hasToolRequests = true
-				toolResult := a.executeToolRequests(operation, fc.ID)
-
-				// Convert result to internal format
-				resultMap := make(map[string]interface{})
-				if toolResult.Success {
-					resultMap["success"] = true
-					resultMap["result"] = toolResult.Result
+			var toolResult ToolResult
+			if operation == nil {
+				// Answer a made-up tool name rather than skipping it, so the model can correct itself
+				log.Printf("Model called unknown tool '%s'", fc.Name)
+				toolResult = ToolResult{
+					Success: false,
+					Errors:  []string{unknownToolError(fc.Name, tools)},
+				}
+			} else {
+				if _, isDone := operation.(*DoneRequest); isDone {
+					done = true
+				} else {
+					hasToolRequests = true
+				}
+				if _, isRender := operation.(imageToolRequest); isRender && !supportsVision {
+					// The model called a tool it wasn't offered - don't spend time rendering an image it can't see
+					toolResult = ToolResult{
+						Success: false,
+						Errors:  []string{fmt.Sprintf("%s is unavailable: the current model does not support vision", operation.ToolName())},
+					}
 				} else {
-					resultMap["success"] = false
-					resultMap["errors"] = toolResult.Errors
+					toolResult = a.executeToolRequests(ctx, operation, fc.ID)
 				}
+			}
+
+			toolResults = append(toolResults, toolResult)
 
+			// Convert result to internal format
+			resultMap := make(map[string]interface{})
+			if toolResult.Success {
+				resultMap["success"] = true
+				resultMap["result"] = toolResult.Result
+				if len(toolResult.Warnings) > 0 {
+					resultMap["warnings"] = toolResult.Warnings
+				}
+			} else {
+				resultMap["success"] = false
+				resultMap["errors"] = toolResult.Errors
+			}
+
+			functionResponses = append(functionResponses, llm.Part{
+				Type: llm.PartTypeFunctionResponse,
+				FunctionResp: &llm.FunctionResponse{
+					ID:       fc.ID,
+					Name:     fc.Name,
+					Response: resultMap,
+				},
+			})
+
+			// Handle render_scene and preview_material images
+			if renderReq, ok := operation.(imageToolRequest); ok && renderReq.renderedImage() != nil {
+				telemetry.RenderCount++
+			}
+			if renderReq, ok := operation.(imageToolRequest); ok && supportsVision && renderReq.renderedImage() != nil {
 				functionResponses = append(functionResponses, llm.Part{
-					Type: llm.PartTypeFunctionResponse,
-					FunctionResp: &llm.FunctionResponse{
-						ID:       fc.ID,
-						Name:     fc.Name,
-						Response: resultMap,
+					Type: llm.PartTypeImage,
+					ImageData: &llm.ImageData{
+						Data:     renderReq.renderedImage(),
+						MIMEType: "image/png",
 					},
 				})
-
-				// Handle render_scene image
-				if renderReq, ok := operation.(*RenderSceneRequest); ok && renderReq.RenderedImage != nil {
-					functionResponses = append(functionResponses, llm.Part{
-						Type: llm.PartTypeImage,
-						ImageData: &llm.ImageData{
-							Data:     renderReq.RenderedImage,
-							MIMEType: "image/png",
-						},
-					})
-				}
 			}
 		}
 
@@ -169,7 +362,7 @@ func (a *Agent) ProcessMessage(ctx context.Context, conversation []llm.Message)
 			if err != nil {
 				a.events <- NewErrorEvent(fmt.Errorf("failed to create scene: %w", err))
 			} else {
-				a.events <- NewSceneRenderEvent(raytracerScene)
+				a.events <- NewSceneRenderEvent(raytracerScene, a.SceneRenderSettings())
 			}
 			hasToolRequests = false
 		}
@@ -182,7 +375,26 @@ func (a *Agent) ProcessMessage(ctx context.Context, conversation []llm.Message)
 			})
 		}
 
+		telemetry.ElapsedMs = time.Since(turnStart).Milliseconds()
+		a.events <- telemetry
 		turnCount++
+
+		// The model said it's finished, even if it made other tool calls this turn
+		if done {
+			break
+		}
+
+		// Stop a model that keeps retrying the same failing calls rather than spend every turn on them
+		if signature := failedTurnSignature(functionCalls, toolResults); signature != 0 && signature == lastFailure {
+			repeatedFailures++
+		} else {
+			lastFailure, repeatedFailures = signature, 1
+		}
+		if lastFailure != 0 && repeatedFailures >= maxRepeatedFailingTurns {
+			log.Printf("Stopping after %d identical failing turns", repeatedFailures)
+			a.events <- NewResponseEvent(fmt.Sprintf("Stopped early: the same tool calls failed %d times in a row (%s). Could you clarify or rephrase the request?", repeatedFailures, strings.Join(toolResults[0].Errors, "; ")))
+			break
+		}
 	}
 
 	// Send completion event
@@ -190,203 +402,1007 @@ func (a *Agent) ProcessMessage(ctx context.Context, conversation []llm.Message)
 	return messages, nil
 }
 
+// generateContent makes one model call, abandoning it if it takes longer than CallTimeout
+// A call that times out is retried up to maxTimeoutRetries times, then fails with an error
+// wrapping llm.ErrTimeout. Nothing is returned from an abandoned call, so the conversation
+// never gets a partial response. ctx ending is reported as its own error, not a timeout.
+func (a *Agent) generateContent(ctx context.Context, req *llm.GenerateRequest) (*llm.Response, error) {
+	timeout := a.CallTimeout()
+	for attempt := 0; ; attempt++ {
+		callCtx, cancel := context.WithTimeoutCause(ctx, timeout, llm.ErrTimeout)
+		response, err := a.provider.GenerateContent(callCtx, req)
+		timedOut := ctx.Err() == nil && context.Cause(callCtx) == llm.ErrTimeout
+		cancel()
+		if err == nil || !timedOut {
+			return response, err
+		}
+
+		err = fmt.Errorf("%w after %s", llm.ErrTimeout, timeout)
+		if attempt >= maxTimeoutRetries {
+			return nil, err
+		}
+		log.Printf("Model call timed out after %s, retrying", timeout)
+		a.events <- NewProcessingEvent(fmt.Sprintf("⚠️ The model didn't answer within %s, retrying...", timeout))
+	}
+}
+
+// failedTurnSignature fingerprints a turn's calls and their errors when every call failed
+// It returns 0 if any call succeeded. Arguments are hashed as JSON, which sorts map keys, so
+// the same calls always give the same signature.
+func failedTurnSignature(calls []*llm.FunctionCall, results []ToolResult) uint64 {
+	if len(calls) == 0 || len(calls) != len(results) {
+		return 0
+	}
+	h := fnv.New64a()
+	for i, call := range calls {
+		if results[i].Success {
+			return 0
+		}
+		args, _ := json.Marshal(call.Arguments)
+		fmt.Fprintf(h, "%s\x00%s\x00%s\x00", call.Name, args, strings.Join(results[i].Errors, "\x00"))
+	}
+	return h.Sum64()
+}
+
+// unknownToolError tells the model a tool doesn't exist and lists the ones it was offered
+func unknownToolError(name string, tools []llm.Tool) string {
+	names := make([]string, len(tools))
+	for i, tool := range tools {
+		names[i] = tool.Name
+	}
+	return fmt.Sprintf("unknown tool '%s' - there is no such tool. Available tools: %s", name, strings.Join(names, ", "))
+}
+
 // ToolResult represents the result of a tool execution
 type ToolResult struct {
-	Success bool        `json:"success"`
-	Result  interface{} `json:"result,omitempty"`
-	Errors  []string    `json:"errors,omitempty"`
+	Success  bool        `json:"success"`
+	Result   interface{} `json:"result,omitempty"`
+	Errors   []string    `json:"errors,omitempty"`
+	Warnings []string    `json:"warnings,omitempty"` // Likely mistakes that didn't stop a successful tool
 }
 
 // executeToolRequests executes a tool operation and returns structured result
-func (a *Agent) executeToolRequests(operation ToolRequest, toolCallID string) ToolResult {
+// ctx is used to abort long-running tools such as render_scene
+func (a *Agent) executeToolRequests(ctx context.Context, operation ToolRequest, toolCallID string) ToolResult {
 	startTime := time.Now()
 	var err error
 	var result interface{}
 
+	if spec, ok := toolRegistry[operation.ToolName()]; ok {
+		result, err = spec.execute(a, ctx, operation, toolCallID)
+	} else {
+		err = fmt.Errorf("unknown tool '%s'", operation.ToolName())
+	}
+
+	// Calculate duration
+	duration := time.Since(startTime).Milliseconds()
+
+	// Emit ToolCallEvent (for UI display)
+	var errorMsg string
+	var errors []string
+	success := err == nil
+	if err != nil {
+		errorMsg = err.Error()
+		// Check if error is ValidationErrors to extract individual errors
+		if validationErrs, ok := err.(ValidationErrors); ok {
+			errors = []string(validationErrs)
+		} else {
+			errors = []string{errorMsg}
+		}
+	}
+
+	// Create tool call event with image data if the tool rendered one
+	toolEvent := NewToolCallEvent(toolCallID, operation, success, errorMsg, duration)
+	if renderReq, ok := operation.(imageToolRequest); ok && renderReq.renderedImage() != nil {
+		toolEvent.RenderedImage = renderReq.renderedImage()
+	}
+	a.events <- toolEvent
+
+	// Return structured result (for LLM feedback)
+	if success {
+		return ToolResult{Success: true, Result: result, Warnings: a.toolWarnings(operation)}
+	}
+	return ToolResult{Success: false, Errors: errors}
+}
+
+// toolWarnings returns warnings about the shape a successful create_shape or update_shape left behind
+func (a *Agent) toolWarnings(operation ToolRequest) []string {
+	var warnings []string
 	switch op := operation.(type) {
 	case *CreateShapeRequest:
-		err = a.sceneManager.AddShapes([]ShapeRequest{op.Shape})
-		if err == nil {
-			// Return the created shape
-			result = op.Shape
-		}
+		warnings = a.sceneManager.ShapeWarnings(op.Shape)
 	case *UpdateShapeRequest:
-		// Capture before state
-		if beforeShape := a.sceneManager.FindShape(op.Id); beforeShape != nil {
-			op.Before = beforeShape
+		if op.After != nil {
+			warnings = a.sceneManager.ShapeWarnings(*op.After)
 		}
+	}
+	if len(warnings) == 0 {
+		return nil
+	}
+	return warnings
+}
 
-		err = a.sceneManager.UpdateShape(op.Id, op.Updates)
+// ------------------------------------------------------------
+// Tool handlers - one per tool, registered in toolRegistry
+// Each returns the result sent back to the LLM, or an error
+// ------------------------------------------------------------
 
-		// Capture after state if successful
-		if err == nil {
-			if afterShape := a.sceneManager.FindShape(op.Id); afterShape != nil {
-				op.After = afterShape
-				result = afterShape
-			}
-		}
-	case *RemoveShapeRequest:
-		// Capture shape before removal
-		if beforeShape := a.sceneManager.FindShape(op.Id); beforeShape != nil {
-			op.RemovedShape = beforeShape
+func (a *Agent) executeCreateShape(ctx context.Context, op *CreateShapeRequest, toolCallID string) (interface{}, error) {
+	if err := a.sceneManager.AddShapes([]ShapeRequest{op.Shape}); err != nil {
+		return nil, err
+	}
+	// Return the created shape as stored, with any material preset expanded
+	if created := a.sceneManager.FindShape(op.Shape.ID); created != nil {
+		op.Shape = *created
+	}
+	return op.Shape, nil
+}
+
+func (a *Agent) executeUpdateShape(ctx context.Context, op *UpdateShapeRequest, toolCallID string) (interface{}, error) {
+	// Capture before state
+	if beforeShape := a.sceneManager.FindShape(op.Id); beforeShape != nil {
+		op.Before = beforeShape
+	}
+
+	if err := a.sceneManager.UpdateShape(op.Id, op.Updates); err != nil {
+		return nil, err
+	}
+
+	// Capture after state
+	var result interface{}
+	if afterShape := a.sceneManager.FindShape(op.Id); afterShape != nil {
+		op.After = afterShape
+		result = afterShape
+	}
+	return result, nil
+}
+
+func (a *Agent) executeRevertShape(ctx context.Context, op *RevertShapeRequest, toolCallID string) (interface{}, error) {
+	// Capture before state, and the ID the shape will have afterwards
+	op.Before, _ = a.sceneManager.GetShapeCopy(op.Id)
+	snapshot, _ := a.sceneManager.ShapeSnapshot(op.Id)
+
+	if err := a.sceneManager.RevertShape(op.Id); err != nil {
+		return nil, err
+	}
+
+	// Capture after state
+	var result interface{}
+	if afterShape := a.sceneManager.FindShape(snapshot.ID); afterShape != nil {
+		op.After = afterShape
+		result = afterShape
+	}
+	return result, nil
+}
+
+func (a *Agent) executeRemoveShape(ctx context.Context, op *RemoveShapeRequest, toolCallID string) (interface{}, error) {
+	// Capture shape before removal
+	if beforeShape := a.sceneManager.FindShape(op.Id); beforeShape != nil {
+		op.RemovedShape = beforeShape
+	}
+
+	if err := a.sceneManager.RemoveShape(op.Id); err != nil {
+		return nil, err
+	}
+	return map[string]string{"id": op.Id, "status": "removed"}, nil
+}
+
+func (a *Agent) executeRenameShapes(ctx context.Context, op *RenameShapesRequest, toolCallID string) (interface{}, error) {
+	usesAffixes := len(op.Ids) > 0 || op.Prefix != "" || op.Suffix != ""
+	switch {
+	case len(op.Mapping) > 0 && usesAffixes:
+		return nil, fmt.Errorf("rename_shapes takes either mapping or ids with a prefix/suffix, not both")
+	case len(op.Mapping) > 0:
+		op.Renamed = op.Mapping
+	case len(op.Ids) == 0:
+		return nil, fmt.Errorf("rename_shapes requires a mapping, or ids with a prefix and/or suffix")
+	case op.Prefix == "" && op.Suffix == "":
+		return nil, fmt.Errorf("rename_shapes requires a prefix or suffix to apply to ids")
+	default:
+		op.Renamed = make(map[string]string, len(op.Ids))
+		for _, id := range op.Ids {
+			op.Renamed[id] = op.Prefix + id + op.Suffix
 		}
+	}
+
+	if err := a.sceneManager.RenameShapes(op.Renamed); err != nil {
+		op.Renamed = nil
+		return nil, err
+	}
+	return map[string]interface{}{"renamed": op.Renamed, "count": len(op.Renamed)}, nil
+}
+
+func (a *Agent) executeRemoveShapes(ctx context.Context, op *RemoveShapesRequest, toolCallID string) (interface{}, error) {
+	if len(op.Ids) == 0 {
+		return nil, fmt.Errorf("remove_shapes requires at least one id")
+	}
 
-		err = a.sceneManager.RemoveShape(op.Id)
-		if err == nil {
-			result = map[string]string{"id": op.Id, "status": "removed"}
+	// Capture shapes before removal
+	captured := make(map[string]bool)
+	for _, id := range op.Ids {
+		if beforeShape := a.sceneManager.FindShape(id); beforeShape != nil && !captured[id] {
+			captured[id] = true
+			op.RemovedShapes = append(op.RemovedShapes, *beforeShape)
 		}
-	case *SetEnvironmentLightingRequest:
-		err = a.sceneManager.SetEnvironmentLighting(op.LightingType, op.TopColor, op.BottomColor, op.Emission)
-		if err == nil {
-			result = map[string]interface{}{
-				"lighting_type": op.LightingType,
-				"top_color":     op.TopColor,
-				"bottom_color":  op.BottomColor,
-				"emission":      op.Emission,
-			}
+	}
+
+	op.Results = make(map[string]string, len(op.Ids))
+	for id, err := range a.sceneManager.RemoveShapes(op.Ids) {
+		if err != nil {
+			op.Results[id] = err.Error()
+		} else {
+			op.Results[id] = "removed"
 		}
-	case *CreateLightRequest:
-		err = a.sceneManager.AddLights([]LightRequest{op.Light})
-		if err == nil {
-			result = op.Light
+	}
+	return op.Results, nil
+}
+
+func (a *Agent) executeExportShape(ctx context.Context, op *ExportShapeRequest, toolCallID string) (interface{}, error) {
+	snippet, err := a.sceneManager.ExportShape(op.Id)
+	if err != nil {
+		return nil, err
+	}
+	op.Snippet = snippet
+	return map[string]string{"id": op.Id, "snippet": snippet}, nil
+}
+
+func (a *Agent) executeImportShape(ctx context.Context, op *ImportShapeRequest, toolCallID string) (interface{}, error) {
+	shape, err := a.sceneManager.ImportShape(op.Snippet, op.Id)
+	if err != nil {
+		return nil, err
+	}
+	op.Shape = shape
+	return shape, nil
+}
+
+func (a *Agent) executeImportDSL(ctx context.Context, op *ImportDSLRequest, toolCallID string) (interface{}, error) {
+	ids, err := a.sceneManager.ImportDSL(op.DSL)
+	if err != nil {
+		return nil, err
+	}
+	op.CreatedIds = ids
+	return map[string]interface{}{"created_ids": ids}, nil
+}
+
+func (a *Agent) executeArrayShapes(ctx context.Context, op *ArrayShapesRequest, toolCallID string) (interface{}, error) {
+	if len(op.Counts) != 3 || len(op.Spacing) != 3 {
+		return nil, fmt.Errorf("array_shapes requires counts and spacing as 3-element arrays [x, y, z]")
+	}
+
+	ids, err := a.sceneManager.ArrayShapes(op.Id,
+		[3]int{op.Counts[0], op.Counts[1], op.Counts[2]},
+		[3]float64{op.Spacing[0], op.Spacing[1], op.Spacing[2]})
+	if err != nil {
+		return nil, err
+	}
+	op.CreatedIds = ids
+	return map[string]interface{}{"source_id": op.Id, "created_ids": ids}, nil
+}
+
+func (a *Agent) executeSnapShapes(ctx context.Context, op *SnapShapesRequest, toolCallID string) (interface{}, error) {
+	if op.Id == "" || op.TargetID == "" {
+		return nil, fmt.Errorf("snap_shapes requires mover_id and target_id")
+	}
+	if len(op.Direction) != 3 {
+		return nil, fmt.Errorf("snap_shapes requires direction as a 3-element array [x, y, z]")
+	}
+	if err := a.sceneManager.SnapToShape(op.Id, op.TargetID, [3]float64{op.Direction[0], op.Direction[1], op.Direction[2]}); err != nil {
+		return nil, err
+	}
+
+	mover := a.sceneManager.FindShape(op.Id)
+	op.Position = make(map[string]interface{})
+	for _, key := range positionProperties {
+		if value, ok := mover.Properties[key]; ok {
+			op.Position[key] = value
 		}
-	case *UpdateLightRequest:
-		// Capture before state
-		if beforeLight := a.sceneManager.FindLight(op.Id); beforeLight != nil {
-			op.Before = beforeLight
+	}
+	return map[string]interface{}{"mover_id": op.Id, "target_id": op.TargetID, "position": op.Position}, nil
+}
+
+func (a *Agent) executeSetEnvironmentLighting(ctx context.Context, op *SetEnvironmentLightingRequest, toolCallID string) (interface{}, error) {
+	// Daylight places its sun by angle rather than by direction
+	if op.LightingType == "daylight" {
+		sunDirection, err := sunDirectionFromAngles(op.SunElevation, op.SunAzimuth)
+		if err != nil {
+			return nil, err
 		}
+		op.SunDirection = sunDirection
+	}
+	if err := a.sceneManager.SetEnvironmentLighting(op.LightingType, op.TopColor, op.BottomColor, op.Emission, op.SunDirection, op.Turbidity, op.Intensity, op.Replace); err != nil {
+		return nil, err
+	}
+	result := map[string]interface{}{
+		"lighting_type": op.LightingType,
+		"top_color":     op.TopColor,
+		"bottom_color":  op.BottomColor,
+		"emission":      op.Emission,
+		"sun_direction": op.SunDirection,
+		"turbidity":     op.Turbidity,
+		"intensity":     op.Intensity,
+		"light_ids":     environmentLightIDs(op.LightingType),
+	}
+	if op.LightingType == "daylight" {
+		result["sun_elevation"] = op.SunElevation
+		result["sun_azimuth"] = op.SunAzimuth
+	}
+	return result, nil
+}
 
-		err = a.sceneManager.UpdateLight(op.Id, op.Updates)
+func (a *Agent) executeCreateLight(ctx context.Context, op *CreateLightRequest, toolCallID string) (interface{}, error) {
+	if err := a.sceneManager.AddLights([]LightRequest{op.Light}); err != nil {
+		return nil, err
+	}
+	return op.Light, nil
+}
 
-		// Capture after state if successful
-		if err == nil {
-			if afterLight := a.sceneManager.FindLight(op.Id); afterLight != nil {
-				op.After = afterLight
-				result = afterLight
-			}
+func (a *Agent) executeLightShape(ctx context.Context, op *LightShapeRequest, toolCallID string) (interface{}, error) {
+	light, err := a.sceneManager.LightShape(op.Id, op.LightID, op.LightType, op.Direction, op.Distance, op.Emission)
+	if err != nil {
+		return nil, err
+	}
+	op.Light = light
+	return light, nil
+}
+
+func (a *Agent) executeSetupThreePointLighting(ctx context.Context, op *SetupThreePointLightingRequest, toolCallID string) (interface{}, error) {
+	lights, err := a.sceneManager.SetupThreePointLighting(op.Id, op.Intensity)
+	if err != nil {
+		return nil, err
+	}
+	op.Lights = lights
+	return map[string]interface{}{"lights": lights}, nil
+}
+
+func (a *Agent) executeUpdateLight(ctx context.Context, op *UpdateLightRequest, toolCallID string) (interface{}, error) {
+	// Capture before state
+	if beforeLight := a.sceneManager.FindLight(op.Id); beforeLight != nil {
+		op.Before = beforeLight
+	}
+
+	if err := a.sceneManager.UpdateLight(op.Id, op.Updates); err != nil {
+		return nil, err
+	}
+
+	// Capture after state
+	var result interface{}
+	if afterLight := a.sceneManager.FindLight(op.Id); afterLight != nil {
+		op.After = afterLight
+		result = afterLight
+	}
+	return result, nil
+}
+
+func (a *Agent) executeGetLight(ctx context.Context, op *GetLightRequest, toolCallID string) (interface{}, error) {
+	light, err := a.sceneManager.GetLightCopy(op.Id)
+	if err != nil {
+		return nil, err
+	}
+	op.Light = light
+	return map[string]interface{}{"light": light, "defaults": resolvedLightDefaults(*light)}, nil
+}
+
+func (a *Agent) executeSetLightEnabled(ctx context.Context, op *SetLightEnabledRequest, toolCallID string) (interface{}, error) {
+	if op.Enabled == nil {
+		return nil, fmt.Errorf("enabled must be true or false")
+	}
+	if err := a.sceneManager.SetLightEnabled(op.Id, *op.Enabled); err != nil {
+		return nil, err
+	}
+	light := *a.sceneManager.FindLight(op.Id)
+	op.Light = &light
+	return light, nil
+}
+
+func (a *Agent) executeSoloLight(ctx context.Context, op *SoloLightRequest, toolCallID string) (interface{}, error) {
+	suppressed, err := a.sceneManager.SoloLight(op.Id)
+	if err != nil {
+		return nil, err
+	}
+	op.Suppressed = suppressed
+	return map[string]interface{}{"soloed_light": op.Id, "suppressed": suppressed}, nil
+}
+
+func (a *Agent) executeUnsoloLight(ctx context.Context, op *UnsoloLightRequest, toolCallID string) (interface{}, error) {
+	if id := a.sceneManager.UnsoloLight(); id != "" {
+		return map[string]string{"unsoloed_light": id, "status": "all enabled lights restored"}, nil
+	}
+	return map[string]string{"status": "no light was soloed"}, nil
+}
+
+func (a *Agent) executeRemoveLight(ctx context.Context, op *RemoveLightRequest, toolCallID string) (interface{}, error) {
+	// Capture light before removal
+	if beforeLight := a.sceneManager.FindLight(op.Id); beforeLight != nil {
+		op.RemovedLight = beforeLight
+	}
+
+	if err := a.sceneManager.RemoveLight(op.Id); err != nil {
+		return nil, err
+	}
+	return map[string]string{"id": op.Id, "status": "removed"}, nil
+}
+
+func (a *Agent) executeSetCamera(ctx context.Context, op *SetCameraRequest, toolCallID string) (interface{}, error) {
+	if err := a.sceneManager.SetCamera(op.Camera); err != nil {
+		return nil, err
+	}
+	// Return the stored camera, which has the aperture an fstop converted to
+	op.Camera = a.sceneManager.GetCamera()
+	return op.Camera, nil
+}
+
+func (a *Agent) executeGetCamera(ctx context.Context, op *GetCameraRequest, toolCallID string) (interface{}, error) {
+	details := deriveCameraDetails(a.sceneManager.GetCamera())
+	op.Camera = &details
+	return details, nil
+}
+
+func (a *Agent) executeAddCamera(ctx context.Context, op *AddCameraRequest, toolCallID string) (interface{}, error) {
+	if err := a.sceneManager.AddCamera(op.ID, op.Camera); err != nil {
+		return nil, err
+	}
+	// Return the stored camera, which has the aperture an fstop converted to
+	op.Camera, _ = a.sceneManager.CameraByID(op.ID)
+	return map[string]interface{}{
+		"id":            op.ID,
+		"camera":        op.Camera,
+		"active_camera": a.sceneManager.ActiveCameraID(),
+		"cameras":       a.sceneManager.CameraIDs(),
+	}, nil
+}
+
+func (a *Agent) executeSelectCamera(ctx context.Context, op *SelectCameraRequest, toolCallID string) (interface{}, error) {
+	if err := a.sceneManager.SelectCamera(op.ID); err != nil {
+		return nil, err
+	}
+	camera := a.sceneManager.GetCamera()
+	op.Camera = &camera
+	return map[string]interface{}{
+		"active_camera": op.ID,
+		"camera":        camera,
+		"cameras":       a.sceneManager.CameraIDs(),
+	}, nil
+}
+
+func (a *Agent) executeRemoveCamera(ctx context.Context, op *RemoveCameraRequest, toolCallID string) (interface{}, error) {
+	if err := a.sceneManager.RemoveCamera(op.ID); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"removed": op.ID,
+		"cameras": a.sceneManager.CameraIDs(),
+	}, nil
+}
+
+func (a *Agent) executeCheckpointScene(ctx context.Context, op *CheckpointSceneRequest, toolCallID string) (interface{}, error) {
+	checkpoint, err := a.sceneManager.Checkpoint(op.Label, op.Overwrite)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"checkpoint":  checkpoint,
+		"checkpoints": len(a.sceneManager.Checkpoints()),
+	}, nil
+}
+
+func (a *Agent) executeRestoreCheckpoint(ctx context.Context, op *RestoreCheckpointRequest, toolCallID string) (interface{}, error) {
+	checkpoint, err := a.sceneManager.RestoreCheckpoint(op.Label)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"restored": checkpoint,
+	}, nil
+}
+
+func (a *Agent) executeListCheckpoints(ctx context.Context, op *ListCheckpointsRequest, toolCallID string) (interface{}, error) {
+	return map[string]interface{}{
+		"checkpoints": a.sceneManager.Checkpoints(),
+	}, nil
+}
+
+func (a *Agent) executeZoomCamera(ctx context.Context, op *ZoomCameraRequest, toolCallID string) (interface{}, error) {
+	if err := a.sceneManager.DollyCamera(op.Factor); err != nil {
+		return nil, err
+	}
+	camera := a.sceneManager.GetCamera()
+	op.Camera = &camera
+	return camera, nil
+}
+
+func (a *Agent) executeSetCameraPreset(ctx context.Context, op *SetCameraPresetRequest, toolCallID string) (interface{}, error) {
+	if err := a.sceneManager.SetCameraPreset(op.Preset); err != nil {
+		return nil, err
+	}
+	camera := a.sceneManager.GetCamera()
+	op.Camera = &camera
+	return camera, nil
+}
+
+func (a *Agent) executeFrameShape(ctx context.Context, op *FrameShapeRequest, toolCallID string) (interface{}, error) {
+	if err := a.sceneManager.FrameShape(op.ID, op.Margin); err != nil {
+		return nil, err
+	}
+	camera := a.sceneManager.GetCamera()
+	op.Camera = &camera
+	return camera, nil
+}
+
+func (a *Agent) executeSetAspectRatio(ctx context.Context, op *SetAspectRatioRequest, toolCallID string) (interface{}, error) {
+	aspect, err := parseAspectRatio(op.AspectRatio)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.sceneManager.SetAspectRatio(aspect); err != nil {
+		return nil, err
+	}
+
+	// Report the size render_scene will use, which defaults to high quality
+	quality := a.renderQuality
+	if quality == "" {
+		quality = QualityHigh
+	}
+	settings := a.sceneManager.RenderSettings(quality).WithAspectRatio(aspect)
+	return map[string]interface{}{
+		"aspect_ratio": aspect,
+		"quality":      quality,
+		"width":        settings.Width,
+		"height":       settings.Height,
+	}, nil
+}
+
+func (a *Agent) executeRenderScene(ctx context.Context, op *RenderSceneRequest, toolCallID string) (interface{}, error) {
+	startTime := time.Now()
+
+	// Emit start event to show "Rendering..." in UI
+	a.events <- NewToolCallStartEvent(toolCallID, op)
+
+	// Get scene for rendering
+	raytracerScene, err := a.sceneManager.ToRaytracerScene()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create scene: %w", err)
+	}
+
+	if len(raytracerScene.Shapes) == 0 {
+		return nil, fmt.Errorf("cannot render empty scene - add shapes first")
+	}
+
+	log.Printf("[render_scene] Scene has %d shapes, camera at %v looking at %v",
+		len(raytracerScene.Shapes),
+		raytracerScene.CameraConfig.Center,
+		raytracerScene.CameraConfig.LookAt)
+
+	mode, err := ParseRenderMode(op.Mode)
+	if err != nil {
+		return nil, err
+	}
+	aov, err := ParseAOV(op.AOV)
+	if err != nil {
+		return nil, err
+	}
+	if mode == RenderModeWireframe && aov != AOVBeauty {
+		return nil, fmt.Errorf("aov '%s' is only available in shaded mode", aov)
+	}
+
+	// Render at the model's chosen quality, defaulting to high (400x300, 500 samples)
+	quality := a.renderQuality
+	if quality == "" {
+		quality = QualityHigh
+	}
+	settings := a.sceneManager.RenderSettings(quality).WithAspectRatio(a.sceneManager.GetCamera().aspectRatio())
+	if op.AdaptiveMinSamples != nil {
+		settings.AdaptiveMinSamples = *op.AdaptiveMinSamples
+	}
+	if op.AdaptiveThreshold != nil {
+		settings.AdaptiveThreshold = *op.AdaptiveThreshold
+	}
+	settings.Denoise = a.denoise
+	if op.Denoise != nil {
+		settings.Denoise = *op.Denoise
+	}
+	settings.Integrator = a.integrator
+	if op.Integrator != "" {
+		settings.Integrator = op.Integrator
+	}
+	settings.ClampIndirect = a.clampIndirect
+	if op.ClampIndirect != nil {
+		settings.ClampIndirect = *op.ClampIndirect
+	}
+	settings.PostProcess = a.postProcess
+	if err := validateAdaptiveSampling(settings.AdaptiveMinSamples, settings.AdaptiveThreshold); err != nil {
+		return nil, err
+	}
+	if err := validateIntegrator(settings.Integrator); err != nil {
+		return nil, err
+	}
+	if err := validateClampIndirect(settings.ClampIndirect); err != nil {
+		return nil, err
+	}
+
+	// Check the output path and its format before spending time on the render
+	var outputPath, outputFormat string
+	if op.OutputPath != "" {
+		if outputPath, err = a.resolveOutputPath(op.OutputPath); err != nil {
+			return nil, err
+		}
+		if outputFormat, err = outputImageFormat(op.OutputPath, op.ImageFormat); err != nil {
+			return nil, err
 		}
-	case *RemoveLightRequest:
-		// Capture light before removal
-		if beforeLight := a.sceneManager.FindLight(op.Id); beforeLight != nil {
-			op.RemovedLight = beforeLight
+		if err := validateImageQuality(op.ImageQuality); err != nil {
+			return nil, err
 		}
+	} else if op.ImageFormat != "" || op.ImageQuality != 0 {
+		return nil, fmt.Errorf("image_format and image_quality only apply to the file written to output_path")
+	}
 
-		err = a.sceneManager.RemoveLight(op.Id)
-		if err == nil {
-			result = map[string]string{"id": op.Id, "status": "removed"}
+	var resultImg image.Image
+	if mode == RenderModeWireframe {
+		resultImg = RenderWireframe(a.sceneManager.GetState(), settings.Width, settings.Height)
+		settings.SamplesPerPixel = 0 // Wireframes aren't sampled
+	} else if aov != AOVBeauty {
+		resultImg, err = RenderAOV(a.sceneManager.GetState(), aov, settings.Width, settings.Height)
+		if err != nil {
+			return nil, err
 		}
-	case *SetCameraRequest:
-		err = a.sceneManager.SetCamera(op.Camera)
-		if err == nil {
-			result = op.Camera
+		settings.SamplesPerPixel = 1 // One primary ray per pixel
+	} else {
+		progress := func(percent int) {
+			a.events <- NewProcessingEvent(fmt.Sprintf("🎨 Rendering... %d%%", percent))
 		}
-	case *RenderSceneRequest:
-		// Emit start event to show "Rendering..." in UI
-		a.events <- NewToolCallStartEvent(toolCallID, operation)
-
-		// Get scene for rendering
-		raytracerScene, sceneErr := a.sceneManager.ToRaytracerScene()
-		if sceneErr != nil {
-			err = fmt.Errorf("failed to create scene: %w", sceneErr)
-			break
+		resultImg, err = RenderImageWithProgress(ctx, raytracerScene, settings, progress)
+		if err != nil {
+			if ctx.Err() != nil {
+				a.events <- NewRenderCancelledEvent(toolCallID)
+			}
+			return nil, err
 		}
+		resultImg = a.sceneManager.ShadowCatcherPass().Apply(resultImg)
+		resultImg = settings.PostProcess.Apply(resultImg)
+	}
 
-		if len(raytracerScene.Shapes) == 0 {
-			err = fmt.Errorf("cannot render empty scene - add shapes first")
-			break
+	// Encode as PNG, which is what the model sees whatever the output format
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, resultImg); err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+
+	// Store image in request
+	op.RenderedImage = buf.Bytes()
+
+	// Also write it to disk if asked, re-encoding it if the file isn't a PNG
+	var outputImage []byte
+	if outputPath != "" {
+		outputImage = op.RenderedImage
+		if outputFormat != ImageFormatPNG {
+			if outputImage, _, err = EncodeImage(resultImg, outputFormat, op.ImageQuality); err != nil {
+				return nil, err
+			}
+		}
+		if err := writeRenderFile(outputPath, outputImage); err != nil {
+			return nil, err
 		}
+	}
 
-		log.Printf("[render_scene] Scene has %d shapes, camera at %v looking at %v",
-			len(raytracerScene.Shapes),
-			raytracerScene.CameraConfig.Center,
-			raytracerScene.CameraConfig.LookAt)
+	// Return success with metadata
+	result := map[string]interface{}{
+		"mode":              mode,
+		"aov":               aov,
+		"quality":           quality,
+		"shape_count":       len(raytracerScene.Shapes),
+		"samples_per_pixel": settings.SamplesPerPixel,
+		"width":             settings.Width,
+		"height":            settings.Height,
+		"render_time_ms":    time.Since(startTime).Milliseconds(),
+	}
+	if mode == RenderModeShaded && aov == AOVBeauty {
+		result["adaptive_min_samples"] = settings.AdaptiveMinSamples
+		result["adaptive_threshold"] = settings.AdaptiveThreshold
+		result["denoise"] = settings.Denoise
+		result["integrator"] = integratorName(settings.Integrator)
+		result["clamp_indirect"] = settings.ClampIndirect
+		result["post_process"] = settings.PostProcess.Effects()
+	}
+	if outputPath != "" {
+		result["output_path"] = outputPath
+		result["output_bytes"] = len(outputImage)
+		result["image_format"] = outputFormat
+	}
+	return result, nil
+}
+
+// resolveOutputPath returns where a render named by the model is written: name is a PNG or JPEG
+// path relative to the output directory. Paths that would escape the output directory are rejected.
+func (a *Agent) resolveOutputPath(name string) (string, error) {
+	if a.outputDir == "" {
+		return "", fmt.Errorf("output_path is not available: no output directory is configured for this agent")
+	}
+	if !filepath.IsLocal(name) {
+		return "", fmt.Errorf("output_path '%s' must be a relative path inside the output directory", name)
+	}
+	if _, err := ImageFormatForPath(name); err != nil {
+		return "", fmt.Errorf("output_path %w", err)
+	}
+	return filepath.Join(a.outputDir, name), nil
+}
+
+// outputImageFormat returns the format a render written to path is encoded in
+// format defaults to the one the path's extension calls for, and must agree with it if given.
+func outputImageFormat(path, format string) (string, error) {
+	pathFormat, err := ImageFormatForPath(path)
+	if err != nil {
+		return "", err
+	}
+	if format == "" {
+		return pathFormat, nil
+	}
+	if format, err = ParseImageFormat(format); err != nil {
+		return "", err
+	}
+	if format != pathFormat {
+		return "", fmt.Errorf("image_format '%s' doesn't match output_path '%s'", format, path)
+	}
+	return format, nil
+}
 
-		// Render at same size as user preview (400x300) with high quality (500 samples)
-		config := renderer.DefaultProgressiveConfig()
-		config.MaxPasses = 1
-		config.MaxSamplesPerPixel = 500
+// writeRenderFile writes an encoded render, creating its directory if needed
+func writeRenderFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write render: %w", err)
+	}
+	return nil
+}
 
-		// Use the scene's default dimensions (400x300) - don't modify them
+func (a *Agent) executePreviewMaterial(ctx context.Context, op *PreviewMaterialRequest, toolCallID string) (interface{}, error) {
+	startTime := time.Now()
 
-		logger := renderer.NewDefaultLogger()
-		integ := integrator.NewPathTracingIntegrator(raytracerScene.SamplingConfig)
+	if op.Material == nil {
+		return nil, fmt.Errorf("preview_material requires a material object")
+	}
+	previewScene, err := newMaterialPreviewScene(op.Material)
+	if err != nil {
+		return nil, err
+	}
 
-		raytracer, renderErr := renderer.NewProgressiveRaytracer(raytracerScene, config, integ, logger)
-		if renderErr != nil {
-			err = fmt.Errorf("failed to create raytracer: %w", renderErr)
-			break
+	// Emit start event to show "Rendering..." in UI
+	a.events <- NewToolCallStartEvent(toolCallID, op)
+
+	raytracerScene, err := previewScene.ToRaytracerScene()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create preview scene: %w", err)
+	}
+	settings := materialPreviewSettings()
+	img, err := RenderImage(ctx, raytracerScene, settings)
+	if err != nil {
+		if ctx.Err() != nil {
+			a.events <- NewRenderCancelledEvent(toolCallID)
 		}
+		return nil, err
+	}
 
-		// Render (synchronous - this takes several seconds)
-		resultImg, _, renderErr := raytracer.RenderPass(1, nil)
-		if renderErr != nil {
-			err = fmt.Errorf("render failed: %w", renderErr)
-			break
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+	op.RenderedImage = buf.Bytes()
+
+	// Report the material as rendered, with any preset expanded
+	return map[string]interface{}{
+		"material":          previewScene.FindShape(materialPreviewSphereID).Properties["material"],
+		"samples_per_pixel": settings.SamplesPerPixel,
+		"width":             settings.Width,
+		"height":            settings.Height,
+		"render_time_ms":    time.Since(startTime).Milliseconds(),
+	}, nil
+}
+
+func (a *Agent) executeSetDefaultMaterial(ctx context.Context, op *SetDefaultMaterialRequest, toolCallID string) (interface{}, error) {
+	if err := a.sceneManager.SetDefaultMaterial(op.Material); err != nil {
+		return nil, err
+	}
+
+	usingDefault := 0
+	for _, shape := range a.sceneManager.GetState().Shapes {
+		if !hasProperty(shape.Properties, "material") && !hasProperty(shape.Properties, "color") {
+			usingDefault++
 		}
+	}
+	return map[string]interface{}{
+		"default_material":     a.sceneManager.DefaultMaterial(),
+		"shapes_using_default": usingDefault,
+	}, nil
+}
 
-		// Encode as PNG
-		var buf bytes.Buffer
-		if encodeErr := png.Encode(&buf, resultImg); encodeErr != nil {
-			err = fmt.Errorf("failed to encode image: %w", encodeErr)
-			break
+func (a *Agent) executeSetRenderQuality(ctx context.Context, op *SetRenderQualityRequest, toolCallID string) (interface{}, error) {
+	quality, err := parseRenderQualityStrict(op.Quality)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateIntegrator(op.Integrator); err != nil {
+		return nil, err
+	}
+	if op.ClampIndirect != nil {
+		if err := validateClampIndirect(*op.ClampIndirect); err != nil {
+			return nil, err
 		}
+		a.clampIndirect = *op.ClampIndirect
+	}
+	a.renderQuality = quality
+	if op.Denoise != nil {
+		a.denoise = *op.Denoise
+	}
+	if op.Integrator != "" {
+		a.integrator = op.Integrator
+	}
+	settings := a.sceneManager.RenderSettings(quality).WithAspectRatio(a.sceneManager.GetCamera().aspectRatio())
+	settings.Denoise = a.denoise
+	settings.Integrator = integratorName(a.integrator)
+	settings.ClampIndirect = a.clampIndirect
+	return map[string]interface{}{
+		"quality":  quality,
+		"settings": settings,
+	}, nil
+}
+
+func (a *Agent) executeSetPostProcess(ctx context.Context, op *SetPostProcessRequest, toolCallID string) (interface{}, error) {
+	postProcess := PostProcess{Vignette: op.Vignette, Contrast: op.Contrast, Saturation: op.Saturation}
+	if err := postProcess.validate(); err != nil {
+		return nil, err
+	}
+	a.postProcess = postProcess
+	return map[string]interface{}{
+		"post_process": postProcess,
+		"effects":      postProcess.Effects(),
+	}, nil
+}
+
+func (a *Agent) executeGetSceneState(ctx context.Context, op *GetSceneStateRequest, toolCallID string) (interface{}, error) {
+	if op.Since != nil && op.Resolved {
+		return nil, fmt.Errorf("since and resolved can't be combined - request the changes, then the resolved state if needed")
+	}
+	if op.Resolved {
+		op.SceneState = a.sceneManager.ResolvedSceneState()
+		return op.SceneState, nil
+	}
+	if op.Since != nil {
+		// Only return what changed after the caller's revision
+		op.Changes = a.sceneManager.ChangesSince(*op.Since)
+		return op.Changes, nil
+	}
+
+	// Get the complete scene state as JSON
+	sceneState := a.sceneManager.GetSceneState()
 
-		// Store image in request
-		op.RenderedImage = buf.Bytes()
+	// Store in request for potential use
+	op.SceneState = sceneState
+
+	// Return the scene state
+	return sceneState, nil
+}
 
-		// Return success with metadata
-		result = map[string]interface{}{
-			"shape_count":       len(raytracerScene.Shapes),
-			"samples_per_pixel": 500,
-			"width":             raytracerScene.SamplingConfig.Width,
-			"height":            raytracerScene.SamplingConfig.Height,
-			"render_time_ms":    time.Since(startTime).Milliseconds(),
+func (a *Agent) executeGetSceneStatistics(ctx context.Context, op *GetSceneStatisticsRequest, toolCallID string) (interface{}, error) {
+	op.Statistics = a.sceneManager.Statistics()
+	return op.Statistics, nil
+}
+
+func (a *Agent) executeFindShapesByTag(ctx context.Context, op *FindShapesByTagRequest, toolCallID string) (interface{}, error) {
+	if op.Tag == "" {
+		return nil, fmt.Errorf("find_shapes_by_tag requires a tag")
+	}
+
+	shapes := a.sceneManager.FindShapesByTag(op.Tag)
+	matches := make([]map[string]interface{}, len(shapes))
+	op.ShapeIds = make([]string, len(shapes))
+	for i, shape := range shapes {
+		op.ShapeIds[i] = shape.ID
+		matches[i] = map[string]interface{}{
+			"id":          shape.ID,
+			"type":        shape.Type,
+			"tags":        shape.Properties["tags"],
+			"description": shape.Properties["description"],
 		}
-	case *GetSceneStateRequest:
-		// Get the complete scene state as JSON
-		sceneState := a.sceneManager.GetSceneState()
+	}
+	return map[string]interface{}{
+		"tag":    op.Tag,
+		"count":  len(shapes),
+		"shapes": matches,
+	}, nil
+}
 
-		// Store in request for potential use
-		op.SceneState = sceneState
+func (a *Agent) executeSceneIsEmpty(ctx context.Context, op *SceneIsEmptyRequest, toolCallID string) (interface{}, error) {
+	renderable := a.sceneManager.RenderableShapeCount()
+	return map[string]interface{}{
+		"is_empty":               renderable == 0,
+		"shape_count":            a.sceneManager.GetShapeCount(),
+		"renderable_shape_count": renderable,
+	}, nil
+}
+
+// executeDone acknowledges the done tool; ProcessMessage ends the loop after the turn's calls
+func (a *Agent) executeDone(ctx context.Context, op *DoneRequest, toolCallID string) (interface{}, error) {
+	return map[string]interface{}{"done": true}, nil
+}
 
-		// Return the scene state
-		result = sceneState
+func (a *Agent) executeIsPointOccupied(ctx context.Context, op *IsPointOccupiedRequest, toolCallID string) (interface{}, error) {
+	if len(op.Point) != 3 {
+		return nil, fmt.Errorf("is_point_occupied requires point as a 3-element array [x, y, z]")
 	}
 
-	// Calculate duration
-	duration := time.Since(startTime).Milliseconds()
+	op.ShapeIds = a.sceneManager.ShapesContainingPoint([3]float64{op.Point[0], op.Point[1], op.Point[2]})
+	return map[string]interface{}{
+		"point":     op.Point,
+		"occupied":  len(op.ShapeIds) > 0,
+		"shape_ids": op.ShapeIds,
+	}, nil
+}
 
-	// Emit ToolCallEvent (for UI display)
-	var errorMsg string
-	var errors []string
-	success := err == nil
+func (a *Agent) executeCheckOverlap(ctx context.Context, op *CheckOverlapRequest, toolCallID string) (interface{}, error) {
+	report, err := a.sceneManager.OverlapReport(op.ShapeA, op.ShapeB)
 	if err != nil {
-		errorMsg = err.Error()
-		// Check if error is ValidationErrors to extract individual errors
-		if validationErrs, ok := err.(ValidationErrors); ok {
-			errors = []string(validationErrs)
-		} else {
-			errors = []string{errorMsg}
-		}
+		return nil, err
 	}
+	op.Report = report
+	return report, nil
+}
 
-	// Create tool call event with image data if this is a render_scene request
-	toolEvent := NewToolCallEvent(toolCallID, operation, success, errorMsg, duration)
-	if renderReq, ok := operation.(*RenderSceneRequest); ok && renderReq.RenderedImage != nil {
-		toolEvent.RenderedImage = renderReq.RenderedImage
+func (a *Agent) executeMeasureDistance(ctx context.Context, op *MeasureDistanceRequest, toolCallID string) (interface{}, error) {
+	distance, delta, err := a.sceneManager.Distance(op.From, op.To)
+	if err != nil {
+		return nil, err
 	}
-	a.events <- toolEvent
+	op.Distance = distance
+	op.Delta = delta[:]
+	return map[string]interface{}{
+		"distance": distance,
+		"delta":    op.Delta,
+	}, nil
+}
 
-	// Return structured result (for LLM feedback)
-	if success {
-		return ToolResult{Success: true, Result: result}
-	}
-	return ToolResult{Success: false, Errors: errors}
+func (a *Agent) executeValidateShape(ctx context.Context, op *ValidateShapeRequest, toolCallID string) (interface{}, error) {
+	// Dry run - report problems without touching the scene
+	validationErrs := a.sceneManager.ValidateShape(op.Shape)
+	return map[string]interface{}{
+		"valid":    len(validationErrs) == 0,
+		"errors":   validationErrs,
+		"warnings": a.sceneManager.ShapeWarnings(op.Shape),
+	}, nil
+}
+
+func (a *Agent) executeValidateLight(ctx context.Context, op *ValidateLightRequest, toolCallID string) (interface{}, error) {
+	validationErrs := a.sceneManager.ValidateLight(op.Light)
+	return map[string]interface{}{
+		"valid":  len(validationErrs) == 0,
+		"errors": validationErrs,
+	}, nil
 }
 
 // buildSystemPrompt constructs the system prompt with scene context
-func buildSystemPrompt(sceneContext string) string {
-	return fmt.Sprintf(`You are an autonomous 3D scene creation assistant with vision capabilities. Your job is to help users create and modify 3D scenes using raytracing.
+// supportsVision controls whether the model is told it can verify its work with render_scene
+func buildSystemPrompt(sceneContext string, supportsVision bool) string {
+	intro := "You are an autonomous 3D scene creation assistant with vision capabilities."
+	visualVerification := `VISUAL VERIFICATION (render_scene tool):
+You have vision and can see rendered images. Use the render_scene tool to verify your work meets the user's request. The rendered image will be sent to you and you can analyze it visually to check colors, materials, lighting, composition, and overall appearance. This is expensive at the default high quality (500 samples, ~3-5 seconds), so use it strategically - typically once after completing major work or when the user asks you to verify something specific. Use set_render_quality to switch to preview or draft while iterating, and back to high for a final check.`
+	workflow := `1. Explain to the user what you're doing as you work
+2. Call tools to create/modify the scene
+3. Review tool results - if there are errors, retry with corrections
+4. Call render_scene to verify the visual result matches the user's request
+5. If the render looks wrong, make corrections and verify again
+6. When satisfied with the visual result, summarize what you did and call the done tool to finish. This is the preferred way to stop; a response with no tool calls also ends your turn`
+
+	if !supportsVision {
+		intro = "You are an autonomous 3D scene creation assistant."
+		visualVerification = `VISUAL VERIFICATION:
+Visual verification is unavailable because the current model cannot see images, so there is no render_scene tool. Verify your work by reviewing tool results and the scene state (get_scene_state) instead, and reason carefully about positions, sizes, colors, and lighting.`
+		workflow = `1. Explain to the user what you're doing as you work
+2. Call tools to create/modify the scene
+3. Review tool results - if there are errors, retry with corrections
+4. Check the scene state to confirm objects are placed as intended
+5. When satisfied with the result, summarize what you did and call the done tool to finish. This is the preferred way to stop; a response with no tool calls also ends your turn`
+	}
+
+	return fmt.Sprintf(`%s Your job is to help users create and modify 3D scenes using raytracing.
 
 AVAILABLE TOOLS:
 You have access to tools for creating, updating, and removing shapes and lights. Each tool call will return a JSON result showing you what happened.
@@ -395,16 +1411,10 @@ You can make multiple tool calls in a single response.
 AUTOMATIC RENDERING:
 The user sees an automatically rendered preview after each tool call. You do NOT need to render the scene for the user.
 
-VISUAL VERIFICATION (render_scene tool):
-You have vision and can see rendered images. Use the render_scene tool to verify your work meets the user's request. The rendered image will be sent to you and you can analyze it visually to check colors, materials, lighting, composition, and overall appearance. This is expensive (500 samples, ~3-5 seconds), so use it strategically - typically once after completing major work or when the user asks you to verify something specific.
+%s
 
 WORKFLOW:
-1. Explain to the user what you're doing as you work
-2. Call tools to create/modify the scene
-3. Review tool results - if there are errors, retry with corrections
-4. Call render_scene to verify the visual result matches the user's request
-5. If the render looks wrong, make corrections and verify again
-6. When satisfied with the visual result, provide a final response (text only, no tool calls) to signal completion
+%s
 
 TOOL RESULTS:
 - Success: {"success": true, "result": {<full object>}}
@@ -413,7 +1423,7 @@ TOOL RESULTS:
 The results show the complete state of each object, including any defaults that were applied. Use these to track what's in the scene and validate your work.
 
 CURRENT SCENE:
-%s`, sceneContext)
+%s`, intro, visualVerification, workflow, sceneContext)
 }
 
 // Close cleans up the agent resources
@@ -1,9 +1,17 @@
 package agent
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"image/png"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/df07/scene-llm/agent/llm"
 	"github.com/df07/scene-llm/agent/llm/gemini"
@@ -14,9 +22,12 @@ import (
 type MockProvider struct {
 	Responses []*genai.GenerateContentResponse
 	CallCount int
+	Vision    bool                   // Value returned by SupportsVision
+	Requests  []*llm.GenerateRequest // Requests received, in call order
 }
 
 func (m *MockProvider) GenerateContent(ctx context.Context, req *llm.GenerateRequest) (*llm.Response, error) {
+	m.Requests = append(m.Requests, req)
 	if m.CallCount >= len(m.Responses) {
 		// Return empty response when we run out
 		return &llm.Response{
@@ -40,7 +51,7 @@ func (m *MockProvider) Name() string {
 }
 
 func (m *MockProvider) SupportsVision() bool {
-	return false
+	return m.Vision
 }
 
 func (m *MockProvider) SupportsThinking() bool {
@@ -493,7 +504,7 @@ func TestRenderSceneEmptyScene(t *testing.T) {
 		BaseToolRequest: BaseToolRequest{ToolType: "render_scene"},
 	}
 
-	result := agent.executeToolRequests(req, "test_call_1")
+	result := agent.executeToolRequests(context.Background(), req, "test_call_1")
 
 	// Should fail with empty scene error
 	if result.Success {
@@ -575,7 +586,7 @@ func TestRenderSceneWithShape(t *testing.T) {
 	}
 
 	// Execute the render (this will actually render, but should be fast for 100x75)
-	result := agent.executeToolRequests(req, "test_call_1")
+	result := agent.executeToolRequests(context.Background(), req, "test_call_1")
 
 	// Should succeed
 	if !result.Success {
@@ -681,6 +692,79 @@ func TestRenderSceneToolParsing(t *testing.T) {
 	}
 }
 
+// TestRenderSceneToolGatedByVision tests that render_scene is only offered to vision-capable models
+func TestRenderSceneToolGatedByVision(t *testing.T) {
+	hasRenderTool := func(tools []llm.Tool) bool {
+		for _, tool := range tools {
+			if tool.Name == "render_scene" {
+				return true
+			}
+		}
+		return false
+	}
+
+	conversation := []llm.Message{
+		{
+			Role:  llm.RoleUser,
+			Parts: []llm.Part{{Type: llm.PartTypeText, Text: "Create a scene"}},
+		},
+	}
+
+	t.Run("non-vision model", func(t *testing.T) {
+		events := make(chan AgentEvent, 100)
+		mockProvider := &MockProvider{
+			Responses: []*genai.GenerateContentResponse{
+				// Model calls render_scene even though it wasn't offered
+				NewMockResponse("", &genai.FunctionCall{Name: "render_scene", Args: map[string]any{}}),
+			},
+		}
+		agent := NewWithProvider(events, mockProvider, "mock-model")
+
+		messages, err := agent.ProcessMessage(context.Background(), conversation)
+		if err != nil {
+			t.Fatalf("ProcessMessage failed: %v", err)
+		}
+
+		req := mockProvider.Requests[0]
+		if hasRenderTool(req.Tools) {
+			t.Error("Expected render_scene not to be offered to a non-vision model")
+		}
+		if !strings.Contains(req.SystemPrompt, "Visual verification is unavailable") {
+			t.Error("Expected system prompt to note that visual verification is unavailable")
+		}
+
+		// The stray call should be rejected without returning an image
+		for _, msg := range messages {
+			for _, part := range msg.Parts {
+				if part.Type == llm.PartTypeImage {
+					t.Error("Expected no image parts for a non-vision model")
+				}
+				if part.Type == llm.PartTypeFunctionResponse && part.FunctionResp.Response["success"] != false {
+					t.Errorf("Expected render_scene call to fail, got %v", part.FunctionResp.Response)
+				}
+			}
+		}
+	})
+
+	t.Run("vision model", func(t *testing.T) {
+		events := make(chan AgentEvent, 100)
+		mockProvider := &MockProvider{Vision: true}
+		agent := NewWithProvider(events, mockProvider, "mock-model")
+
+		if _, err := agent.ProcessMessage(context.Background(), conversation); err != nil {
+			t.Fatalf("ProcessMessage failed: %v", err)
+		}
+
+		req := mockProvider.Requests[0]
+		if !hasRenderTool(req.Tools) {
+			t.Error("Expected render_scene to be offered to a vision model")
+		}
+		if strings.Contains(req.SystemPrompt, "Visual verification is unavailable") {
+			t.Error("Expected system prompt not to disable visual verification for a vision model")
+		}
+	})
+}
+
 func TestGetSceneStateWithEmptyScene(t *testing.T) {
 	events := make(chan AgentEvent, 100)
 	agent := NewWithProvider(events, &MockProvider{}, "mock-model")
@@ -689,7 +773,7 @@ func TestGetSceneStateWithEmptyScene(t *testing.T) {
 		BaseToolRequest: BaseToolRequest{ToolType: "get_scene_state"},
 	}
 
-	result := agent.executeToolRequests(req, "test_call_1")
+	result := agent.executeToolRequests(context.Background(), req, "test_call_1")
 
 	if !result.Success {
 		t.Fatalf("Expected success, got errors: %v", result.Errors)
@@ -758,7 +842,7 @@ func TestGetSceneStateWithShapesAndLights(t *testing.T) {
 		BaseToolRequest: BaseToolRequest{ToolType: "get_scene_state"},
 	}
 
-	result := agent.executeToolRequests(req, "test_call_1")
+	result := agent.executeToolRequests(context.Background(), req, "test_call_1")
 
 	if !result.Success {
 		t.Fatalf("Expected success, got errors: %v", result.Errors)
@@ -1120,3 +1204,1205 @@ func TestMultiTurnConversationHistory(t *testing.T) {
 	for range events {
 	}
 }
+
+// TestThinkingBudgetPassedToProvider tests that the agent's thinking budget reaches the provider request
+func TestThinkingBudgetPassedToProvider(t *testing.T) {
+	conversation := []llm.Message{
+		{
+			Role:  llm.RoleUser,
+			Parts: []llm.Part{{Type: llm.PartTypeText, Text: "Hello"}},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		budget   *int
+		expected *int
+	}{
+		{name: "default", budget: nil, expected: nil},
+		{name: "disabled", budget: intPtr(0), expected: intPtr(0)},
+		{name: "positive", budget: intPtr(2048), expected: intPtr(2048)},
+		{name: "negative restores default", budget: intPtr(-1), expected: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := make(chan AgentEvent, 100)
+			mockProvider := &MockProvider{}
+			agent := NewWithProvider(events, mockProvider, "mock-model")
+			if tt.budget != nil {
+				agent.SetThinkingBudget(*tt.budget)
+			}
+
+			if _, err := agent.ProcessMessage(context.Background(), conversation); err != nil {
+				t.Fatalf("ProcessMessage failed: %v", err)
+			}
+
+			got := mockProvider.Requests[0].ThinkingBudget
+			switch {
+			case tt.expected == nil && got != nil:
+				t.Errorf("Expected no thinking budget, got %d", *got)
+			case tt.expected != nil && got == nil:
+				t.Errorf("Expected thinking budget %d, got nil", *tt.expected)
+			case tt.expected != nil && *got != *tt.expected:
+				t.Errorf("Expected thinking budget %d, got %d", *tt.expected, *got)
+			}
+		})
+	}
+}
+
+func TestArrayShapesTool(t *testing.T) {
+	events := make(chan AgentEvent, 100)
+	agent := NewWithProvider(events, &MockProvider{}, "mock-model")
+	agent.sceneManager.AddShapes([]ShapeRequest{
+		{ID: "ball", Type: "sphere", Properties: map[string]interface{}{"center": []interface{}{0.0, 0.0, 0.0}, "radius": 0.5}},
+	})
+
+	req := parseToolRequestFromFunctionCall(&llm.FunctionCall{
+		Name: "array_shapes",
+		Arguments: map[string]interface{}{
+			"source_id": "ball",
+			"counts":    []interface{}{2.0, 2.0, 1.0},
+			"spacing":   []interface{}{1.5, 1.5, 0.0},
+		},
+	})
+	result := agent.executeToolRequests(context.Background(), req, "test_call_1")
+	if !result.Success {
+		t.Fatalf("Expected array_shapes to succeed, got errors: %v", result.Errors)
+	}
+	if ids := req.(*ArrayShapesRequest).CreatedIds; len(ids) != 3 {
+		t.Errorf("Expected 3 created IDs, got %v", ids)
+	}
+
+	// Counts and spacing must be 3-element arrays
+	bad := &ArrayShapesRequest{BaseToolRequest: BaseToolRequest{ToolType: "array_shapes", Id: "ball"}, Counts: []int{2}}
+	if result := agent.executeToolRequests(context.Background(), bad, "test_call_2"); result.Success {
+		t.Error("Expected malformed counts to fail")
+	}
+}
+
+func TestRemoveShapesTool(t *testing.T) {
+	events := make(chan AgentEvent, 100)
+	agent := NewWithProvider(events, &MockProvider{}, "mock-model")
+	agent.sceneManager.AddShapes([]ShapeRequest{
+		{ID: "a", Type: "sphere", Properties: map[string]interface{}{"center": []interface{}{0.0, 0.0, 0.0}, "radius": 1.0}},
+		{ID: "b", Type: "sphere", Properties: map[string]interface{}{"center": []interface{}{2.0, 0.0, 0.0}, "radius": 1.0}},
+	})
+
+	req := parseToolRequestFromFunctionCall(&llm.FunctionCall{
+		Name:      "remove_shapes",
+		Arguments: map[string]interface{}{"ids": []interface{}{"a", "nope"}},
+	})
+	result := agent.executeToolRequests(context.Background(), req, "test_call_1")
+	if !result.Success {
+		t.Fatalf("Expected remove_shapes to succeed with partial failures, got errors: %v", result.Errors)
+	}
+
+	statuses := result.Result.(map[string]string)
+	if statuses["a"] != "removed" {
+		t.Errorf("Expected 'a' to be removed, got %q", statuses["a"])
+	}
+	if !strings.Contains(statuses["nope"], "not found") {
+		t.Errorf("Expected 'nope' to report not found, got %q", statuses["nope"])
+	}
+
+	removeReq := req.(*RemoveShapesRequest)
+	if len(removeReq.RemovedShapes) != 1 || removeReq.RemovedShapes[0].ID != "a" {
+		t.Errorf("Expected removed shape 'a' to be captured, got %v", removeReq.RemovedShapes)
+	}
+
+	// An empty list is an error
+	empty := &RemoveShapesRequest{BaseToolRequest: BaseToolRequest{ToolType: "remove_shapes"}}
+	if result := agent.executeToolRequests(context.Background(), empty, "test_call_2"); result.Success {
+		t.Error("Expected remove_shapes with no ids to fail")
+	}
+}
+
+func TestSetRenderQualityTool(t *testing.T) {
+	events := make(chan AgentEvent, 100)
+	agent := NewWithProvider(events, &MockProvider{}, "mock-model")
+	agent.sceneManager = newRenderableSceneManager(t)
+
+	if agent.RenderQuality() != "" {
+		t.Fatalf("Expected no render quality by default, got %q", agent.RenderQuality())
+	}
+
+	req := parseToolRequestFromFunctionCall(&llm.FunctionCall{
+		Name:      "set_render_quality",
+		Arguments: map[string]interface{}{"quality": "preview"},
+	})
+	if result := agent.executeToolRequests(context.Background(), req, "test_call_1"); !result.Success {
+		t.Fatalf("Expected set_render_quality to succeed, got errors: %v", result.Errors)
+	}
+	if agent.RenderQuality() != QualityPreview {
+		t.Errorf("Expected preview quality, got %q", agent.RenderQuality())
+	}
+
+	// Subsequent renders use the chosen quality
+	renderReq := &RenderSceneRequest{BaseToolRequest: BaseToolRequest{ToolType: "render_scene"}}
+	result := agent.executeToolRequests(context.Background(), renderReq, "test_call_2")
+	if !result.Success {
+		t.Fatalf("Expected render to succeed, got errors: %v", result.Errors)
+	}
+	metadata := result.Result.(map[string]interface{})
+	preview := GetRenderSettings(QualityPreview)
+	if metadata["samples_per_pixel"] != preview.SamplesPerPixel || metadata["width"] != preview.Width {
+		t.Errorf("Expected preview settings in render metadata, got %v", metadata)
+	}
+
+	// Unknown qualities are rejected and leave the setting unchanged
+	bad := &SetRenderQualityRequest{BaseToolRequest: BaseToolRequest{ToolType: "set_render_quality"}, Quality: "ultra"}
+	if result := agent.executeToolRequests(context.Background(), bad, "test_call_3"); result.Success {
+		t.Error("Expected unknown quality to fail")
+	}
+	if agent.RenderQuality() != QualityPreview {
+		t.Errorf("Expected quality to remain preview, got %q", agent.RenderQuality())
+	}
+}
+
+func TestUserImagesGatedByVision(t *testing.T) {
+	image := llm.Part{Type: llm.PartTypeImage, ImageData: &llm.ImageData{MIMEType: "image/png", Data: []byte("png")}}
+	conversation := []llm.Message{
+		{
+			Role:  llm.RoleUser,
+			Parts: []llm.Part{{Type: llm.PartTypeText, Text: "Recreate this scene"}, image},
+		},
+	}
+
+	countImages := func(req *llm.GenerateRequest) int {
+		count := 0
+		for _, msg := range req.Messages {
+			for _, part := range msg.Parts {
+				if part.Type == llm.PartTypeImage {
+					count++
+				}
+			}
+		}
+		return count
+	}
+
+	for _, vision := range []bool{true, false} {
+		mockProvider := &MockProvider{Vision: vision}
+		agent := NewWithProvider(make(chan AgentEvent, 100), mockProvider, "mock-model")
+
+		if _, err := agent.ProcessMessage(context.Background(), conversation); err != nil {
+			t.Fatalf("ProcessMessage failed: %v", err)
+		}
+		if len(mockProvider.Requests) == 0 {
+			t.Fatal("Expected a request to the provider")
+		}
+
+		want := 0
+		if vision {
+			want = 1
+		}
+		if got := countImages(mockProvider.Requests[0]); got != want {
+			t.Errorf("vision=%v: expected %d image parts sent, got %d", vision, want, got)
+		}
+	}
+
+	// The caller's conversation keeps the image either way
+	if len(conversation[0].Parts) != 2 {
+		t.Errorf("Expected conversation to be unmodified, got %d parts", len(conversation[0].Parts))
+	}
+}
+
+func TestRenderSceneAdaptiveSamplingOverride(t *testing.T) {
+	events := make(chan AgentEvent, 100)
+	agent := NewWithProvider(events, &MockProvider{}, "mock-model")
+	if err := agent.sceneManager.AddShapes([]ShapeRequest{{ID: "ball", Type: "sphere", Properties: map[string]interface{}{
+		"center": []interface{}{0.0, 0.0, 0.0},
+		"radius": 1.0,
+	}}}); err != nil {
+		t.Fatalf("Failed to add shape: %v", err)
+	}
+
+	req := parseToolRequestFromFunctionCall(&llm.FunctionCall{
+		Name:      "render_scene",
+		Arguments: map[string]interface{}{"adaptive_min_samples": 0.5, "adaptive_threshold": 0.0},
+	})
+	renderReq, ok := req.(*RenderSceneRequest)
+	if !ok {
+		t.Fatalf("Expected *RenderSceneRequest, got %T", req)
+	}
+	if renderReq.AdaptiveMinSamples == nil || *renderReq.AdaptiveMinSamples != 0.5 || renderReq.AdaptiveThreshold == nil {
+		t.Fatalf("Expected both overrides to be parsed, got %+v", renderReq)
+	}
+
+	// A zero threshold is rejected before rendering
+	result := agent.executeToolRequests(context.Background(), req, "test_call_1")
+	if result.Success {
+		t.Fatal("Expected render_scene to reject adaptive_threshold 0")
+	}
+	if !strings.Contains(strings.Join(result.Errors, "; "), "adaptive_threshold") {
+		t.Errorf("Expected an adaptive_threshold error, got %v", result.Errors)
+	}
+}
+
+func TestResolveOutputPath(t *testing.T) {
+	agent := NewWithProvider(nil, &MockProvider{}, "mock-model")
+	if _, err := agent.resolveOutputPath("scene.png"); err == nil {
+		t.Error("Expected output_path to be rejected without an output directory")
+	}
+
+	dir := t.TempDir()
+	agent.SetOutputDir(dir)
+	tests := []struct {
+		name     string
+		expected string // Empty if the path is rejected
+	}{
+		{"scene.png", filepath.Join(dir, "scene.png")},
+		{"renders/final.PNG", filepath.Join(dir, "renders", "final.PNG")},
+		{"scene.jpg", filepath.Join(dir, "scene.jpg")},
+		{"../escape.png", ""},
+		{"renders/../../escape.png", ""},
+		{"/tmp/absolute.png", ""},
+		{"", ""},
+		{"scene.gif", ""},
+	}
+	for _, tt := range tests {
+		path, err := agent.resolveOutputPath(tt.name)
+		if tt.expected == "" {
+			if err == nil {
+				t.Errorf("resolveOutputPath(%q) = %q, expected an error", tt.name, path)
+			}
+			continue
+		}
+		if err != nil || path != tt.expected {
+			t.Errorf("resolveOutputPath(%q) = %q, %v, want %q", tt.name, path, err, tt.expected)
+		}
+	}
+}
+
+func TestRenderSceneOutputPath(t *testing.T) {
+	events := make(chan AgentEvent, 100)
+	agent := NewWithProvider(events, &MockProvider{}, "mock-model")
+	dir := t.TempDir()
+	agent.SetOutputDir(dir)
+	if err := agent.sceneManager.AddShapes([]ShapeRequest{{ID: "ball", Type: "sphere", Properties: map[string]interface{}{
+		"center": []interface{}{0.0, 0.0, 0.0},
+		"radius": 1.0,
+	}}}); err != nil {
+		t.Fatalf("Failed to add shape: %v", err)
+	}
+
+	// Wireframes don't need the path tracer, which keeps this fast
+	req := parseToolRequestFromFunctionCall(&llm.FunctionCall{
+		Name:      "render_scene",
+		Arguments: map[string]interface{}{"mode": "wireframe", "output_path": "batch/ball.png"},
+	})
+	result := agent.executeToolRequests(context.Background(), req, "test_call_1")
+	if !result.Success {
+		t.Fatalf("Expected render_scene to succeed, got errors: %v", result.Errors)
+	}
+
+	resultMap := result.Result.(map[string]interface{})
+	path := filepath.Join(dir, "batch", "ball.png")
+	if resultMap["output_path"] != path {
+		t.Errorf("Expected output_path %q, got %v", path, resultMap["output_path"])
+	}
+	written, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Expected the render to be written: %v", err)
+	}
+	if !bytes.Equal(written, req.(*RenderSceneRequest).RenderedImage) || resultMap["output_bytes"] != len(written) {
+		t.Errorf("Expected the file to hold the returned %v-byte PNG, got %d bytes", resultMap["output_bytes"], len(written))
+	}
+
+	// Escaping the output directory fails without writing anything
+	escape := parseToolRequestFromFunctionCall(&llm.FunctionCall{
+		Name:      "render_scene",
+		Arguments: map[string]interface{}{"mode": "wireframe", "output_path": "../escape.png"},
+	})
+	if result := agent.executeToolRequests(context.Background(), escape, "test_call_2"); result.Success {
+		t.Error("Expected output_path outside the output directory to be rejected")
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(dir), "escape.png")); err == nil {
+		t.Error("Expected nothing to be written outside the output directory")
+	}
+}
+
+func TestExportImportShape(t *testing.T) {
+	events := make(chan AgentEvent, 100)
+	source := NewWithProvider(events, &MockProvider{}, "mock-model")
+	if err := source.sceneManager.AddShapes([]ShapeRequest{{ID: "lamp_post", Type: "cylinder", Properties: map[string]interface{}{
+		"base_center": []interface{}{0.0, 0.0, 0.0},
+		"top_center":  []interface{}{0.0, 3.0, 0.0},
+		"radius":      0.1,
+		"capped":      true,
+		"material":    map[string]interface{}{"type": "metal", "albedo": []interface{}{0.2, 0.2, 0.2}, "fuzz": 0.3},
+	}}}); err != nil {
+		t.Fatalf("Failed to add shape: %v", err)
+	}
+
+	export := parseToolRequestFromFunctionCall(&llm.FunctionCall{Name: "export_shape", Arguments: map[string]interface{}{"id": "lamp_post"}})
+	result := source.executeToolRequests(context.Background(), export, "test_call_1")
+	if !result.Success {
+		t.Fatalf("Expected export_shape to succeed, got errors: %v", result.Errors)
+	}
+	snippet := result.Result.(map[string]string)["snippet"]
+	if strings.Contains(snippet, "lamp_post") {
+		t.Errorf("Expected the snippet to leave out the shape's ID, got %s", snippet)
+	}
+
+	// The snippet can be pasted into another session under a new name
+	target := NewWithProvider(events, &MockProvider{}, "mock-model")
+	importReq := parseToolRequestFromFunctionCall(&llm.FunctionCall{Name: "import_shape", Arguments: map[string]interface{}{
+		"snippet": snippet,
+		"new_id":  "lamp_post_2",
+	}})
+	result = target.executeToolRequests(context.Background(), importReq, "test_call_2")
+	if !result.Success {
+		t.Fatalf("Expected import_shape to succeed, got errors: %v", result.Errors)
+	}
+	original := source.sceneManager.FindShape("lamp_post")
+	imported := target.sceneManager.FindShape("lamp_post_2")
+	if imported == nil || imported.Type != original.Type || fmt.Sprint(imported.Properties) != fmt.Sprint(original.Properties) {
+		t.Errorf("Expected an identical copy of the shape, got %+v", imported)
+	}
+
+	// An object works as well as a JSON string
+	objectReq := parseToolRequestFromFunctionCall(&llm.FunctionCall{Name: "import_shape", Arguments: map[string]interface{}{
+		"snippet": map[string]interface{}{"type": "sphere", "properties": map[string]interface{}{"center": []interface{}{0.0, 1.0, 0.0}, "radius": 0.5}},
+		"new_id":  "globe",
+	}})
+	if result := target.executeToolRequests(context.Background(), objectReq, "test_call_3"); !result.Success {
+		t.Errorf("Expected an object snippet to be imported, got errors: %v", result.Errors)
+	}
+
+	tests := []struct {
+		name       string
+		args       map[string]interface{}
+		errorMatch string
+	}{
+		{"duplicate ID", map[string]interface{}{"snippet": snippet, "new_id": "lamp_post_2"}, "already exists"},
+		{"missing new_id", map[string]interface{}{"snippet": snippet}, "new_id is required"},
+		{"invalid JSON", map[string]interface{}{"snippet": "{not json", "new_id": "x"}, "not valid JSON"},
+		{"not a shape", map[string]interface{}{"snippet": `{"radius": 1}`, "new_id": "x"}, "type and properties"},
+		{"invalid shape", map[string]interface{}{"snippet": `{"type": "sphere", "properties": {"center": [0, 0, 0], "radius": -1}}`, "new_id": "x"}, "radius must be positive"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := parseToolRequestFromFunctionCall(&llm.FunctionCall{Name: "import_shape", Arguments: tt.args})
+			result := target.executeToolRequests(context.Background(), req, "test_call_4")
+			if result.Success || !strings.Contains(strings.Join(result.Errors, "; "), tt.errorMatch) {
+				t.Errorf("Expected an error containing %q, got %+v", tt.errorMatch, result)
+			}
+		})
+	}
+}
+
+func TestProcessMessageEmitsTelemetryPerTurn(t *testing.T) {
+	events := make(chan AgentEvent, 100)
+	withUsage := NewMockResponse("Let me add a ball and look at it.",
+		&genai.FunctionCall{Name: "create_shape", Args: map[string]any{
+			"id": "ball", "type": "sphere",
+			"properties": map[string]any{"center": []any{0.0, 1.0, 0.0}, "radius": 1.0},
+		}},
+		&genai.FunctionCall{Name: "render_scene", Args: map[string]any{}},
+	)
+	withUsage.UsageMetadata = &genai.GenerateContentResponseUsageMetadata{PromptTokenCount: 500, CandidatesTokenCount: 40}
+	mockProvider := &MockProvider{
+		Vision:    true,
+		Responses: []*genai.GenerateContentResponse{withUsage, NewMockResponse("Done")},
+	}
+	agent := NewWithProvider(events, mockProvider, "mock-model")
+	agent.renderQuality = QualityPreview
+
+	conversation := []llm.Message{{Role: llm.RoleUser, Parts: []llm.Part{{Type: llm.PartTypeText, Text: "Add a ball"}}}}
+	if _, err := agent.ProcessMessage(context.Background(), conversation); err != nil {
+		t.Fatalf("ProcessMessage failed: %v", err)
+	}
+
+	close(events)
+	var telemetry []TelemetryEvent
+	for event := range events {
+		if e, ok := event.(TelemetryEvent); ok {
+			telemetry = append(telemetry, e)
+		}
+	}
+	if len(telemetry) != mockProvider.CallCount {
+		t.Fatalf("Expected one telemetry event per turn (%d), got %d", mockProvider.CallCount, len(telemetry))
+	}
+
+	first, last := telemetry[0], telemetry[1]
+	if first.Turn != 1 || first.ModelID != "mock-model" || strings.Join(first.Tools, ",") != "create_shape,render_scene" || first.RenderCount != 1 {
+		t.Errorf("Unexpected first turn telemetry: %+v", first)
+	}
+	if first.Usage == nil || first.Usage.InputTokens != 500 || first.Usage.OutputTokens != 40 {
+		t.Errorf("Expected the first turn's token usage, got %+v", first.Usage)
+	}
+	if last.Turn != 2 || len(last.Tools) != 0 || last.RenderCount != 0 || last.Usage != nil {
+		t.Errorf("Unexpected final turn telemetry: %+v", last)
+	}
+}
+
+func TestProcessMessageMaxTurns(t *testing.T) {
+	events := make(chan AgentEvent, 100)
+
+	// Every reply asks for another tool call, so only the turn limit ends the loop
+	var responses []*genai.GenerateContentResponse
+	for i := 0; i < 5; i++ {
+		responses = append(responses, NewMockResponse("", &genai.FunctionCall{Name: "get_scene_state", Args: map[string]any{}}))
+	}
+	mockProvider := &MockProvider{Responses: responses}
+	agent := NewWithProvider(events, mockProvider, "mock-model")
+	if agent.MaxTurns() != 10 {
+		t.Errorf("Expected a default of 10 turns, got %d", agent.MaxTurns())
+	}
+	agent.SetMaxTurns(3)
+
+	conversation := []llm.Message{{Role: llm.RoleUser, Parts: []llm.Part{{Type: llm.PartTypeText, Text: "Look around"}}}}
+	if _, err := agent.ProcessMessage(context.Background(), conversation); err != nil {
+		t.Fatalf("ProcessMessage failed: %v", err)
+	}
+	if mockProvider.CallCount != 3 {
+		t.Errorf("Expected the loop to stop after 3 turns, got %d model calls", mockProvider.CallCount)
+	}
+
+	close(events)
+	found := false
+	for event := range events {
+		if response, ok := event.(ResponseEvent); ok && strings.Contains(response.Text, "Reached maximum turn limit (3 turns)") {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("Expected a response event naming the configured turn limit")
+	}
+
+	agent.SetMaxTurns(0)
+	if agent.MaxTurns() != 10 {
+		t.Errorf("Expected SetMaxTurns(0) to restore the default, got %d", agent.MaxTurns())
+	}
+}
+
+// unavailableProvider fails every request, like a rate-limited provider
+type unavailableProvider struct {
+	MockProvider
+}
+
+func (u *unavailableProvider) GenerateContent(ctx context.Context, req *llm.GenerateRequest) (*llm.Response, error) {
+	return nil, &llm.APIError{StatusCode: 429, Err: fmt.Errorf("429 rate limited")}
+}
+
+func (u *unavailableProvider) Name() string {
+	return "unavailable"
+}
+
+func TestProcessMessageReportsFallback(t *testing.T) {
+	events := make(chan AgentEvent, 100)
+	backup := &MockProvider{Responses: []*genai.GenerateContentResponse{NewMockResponse("Done")}}
+	provider, err := llm.NewFallbackProvider(&unavailableProvider{}, backup)
+	if err != nil {
+		t.Fatalf("NewFallbackProvider() failed: %v", err)
+	}
+	agent := NewWithProvider(events, provider, "mock-model")
+
+	conversation := []llm.Message{{Role: llm.RoleUser, Parts: []llm.Part{{Type: llm.PartTypeText, Text: "Hello"}}}}
+	if _, err := agent.ProcessMessage(context.Background(), conversation); err != nil {
+		t.Fatalf("ProcessMessage failed: %v", err)
+	}
+	if backup.CallCount != 1 {
+		t.Errorf("Expected the backup provider to answer, got %d calls", backup.CallCount)
+	}
+
+	close(events)
+	var notices []string
+	for event := range events {
+		if processing, ok := event.(ProcessingEvent); ok && strings.Contains(processing.Message, "trying mock") {
+			notices = append(notices, processing.Message)
+		}
+	}
+	if len(notices) != 1 || !strings.Contains(notices[0], "unavailable failed (429 rate limited)") {
+		t.Errorf("Expected one processing event about the fallback, got %v", notices)
+	}
+}
+
+func TestProcessMessageStopsOnDone(t *testing.T) {
+	events := make(chan AgentEvent, 100)
+
+	// done arrives alongside another tool call; the follow-up reply must never be requested
+	createSphere := &genai.FunctionCall{Name: "create_shape", Args: map[string]any{
+		"id":         "sphere1",
+		"type":       "sphere",
+		"properties": map[string]any{"center": []any{0.0, 1.0, 0.0}, "radius": 1.0},
+	}}
+	mockProvider := &MockProvider{Responses: []*genai.GenerateContentResponse{
+		NewMockResponse("Added a sphere.", createSphere, &genai.FunctionCall{Name: "done", Args: map[string]any{}}),
+		NewMockResponse("", &genai.FunctionCall{Name: "get_scene_state", Args: map[string]any{}}),
+	}}
+	agent := NewWithProvider(events, mockProvider, "mock-model")
+
+	conversation := []llm.Message{{Role: llm.RoleUser, Parts: []llm.Part{{Type: llm.PartTypeText, Text: "Add a sphere"}}}}
+	messages, err := agent.ProcessMessage(context.Background(), conversation)
+	if err != nil {
+		t.Fatalf("ProcessMessage failed: %v", err)
+	}
+	if mockProvider.CallCount != 1 {
+		t.Errorf("Expected the loop to stop after the done call, got %d model calls", mockProvider.CallCount)
+	}
+	if agent.sceneManager.FindShape("sphere1") == nil {
+		t.Error("Expected the other tool call in the same turn to still run")
+	}
+
+	// Every call in the final turn still gets a response, so the history stays valid
+	last := messages[len(messages)-1]
+	if last.Role != llm.RoleUser || len(last.Parts) != 2 {
+		t.Fatalf("Expected the conversation to end with both function responses, got %+v", last)
+	}
+
+	close(events)
+	complete := 0
+	for event := range events {
+		if _, ok := event.(CompleteEvent); ok {
+			complete++
+		}
+	}
+	if complete != 1 {
+		t.Errorf("Expected one complete event, got %d", complete)
+	}
+}
+
+func TestProcessMessageUnknownTool(t *testing.T) {
+	events := make(chan AgentEvent, 100)
+	mockProvider := &MockProvider{Responses: []*genai.GenerateContentResponse{
+		NewMockResponse("", &genai.FunctionCall{Name: "make_sphere", Args: map[string]any{"radius": 1.0}}),
+		NewMockResponse("I'll use create_shape instead."),
+	}}
+	agent := NewWithProvider(events, mockProvider, "mock-model")
+
+	conversation := []llm.Message{{Role: llm.RoleUser, Parts: []llm.Part{{Type: llm.PartTypeText, Text: "Add a sphere"}}}}
+	messages, err := agent.ProcessMessage(context.Background(), conversation)
+	if err != nil {
+		t.Fatalf("ProcessMessage failed: %v", err)
+	}
+	if mockProvider.CallCount != 2 {
+		t.Errorf("Expected the model to be asked again after the unknown tool, got %d model calls", mockProvider.CallCount)
+	}
+
+	// The bogus call gets an error response, after the assistant turn that made it
+	if len(messages) < 3 {
+		t.Fatalf("Expected a function response in the conversation, got %+v", messages)
+	}
+	response := messages[2]
+	if response.Role != llm.RoleUser || len(response.Parts) != 1 || response.Parts[0].FunctionResp == nil {
+		t.Fatalf("Expected a single function response, got %+v", response)
+	}
+	resp := response.Parts[0].FunctionResp
+	if resp.Name != "make_sphere" || resp.Response["success"] != false {
+		t.Errorf("Expected a failed response for make_sphere, got %+v", resp)
+	}
+	errs := strings.Join(resp.Response["errors"].([]string), "; ")
+	if !strings.Contains(errs, "unknown tool 'make_sphere'") || !strings.Contains(errs, "create_shape") {
+		t.Errorf("Expected the error to name the tool and list the available ones, got %q", errs)
+	}
+}
+
+// idlessProvider is an LLM provider whose function calls carry no IDs
+type idlessProvider struct {
+	MockProvider
+}
+
+func (p *idlessProvider) GenerateContent(ctx context.Context, req *llm.GenerateRequest) (*llm.Response, error) {
+	response, err := p.MockProvider.GenerateContent(ctx, req)
+	if err == nil {
+		for _, part := range response.Parts {
+			if part.FunctionCall != nil {
+				part.FunctionCall.ID = ""
+			}
+		}
+	}
+	return response, err
+}
+
+func TestProcessMessageCorrelatesCallIDs(t *testing.T) {
+	sphereCall := func(id, shapeID string) *genai.FunctionCall {
+		return &genai.FunctionCall{ID: id, Name: "create_shape", Args: map[string]any{
+			"id":         shapeID,
+			"type":       "sphere",
+			"properties": map[string]any{"center": []any{0.0, 1.0, 0.0}, "radius": 1.0},
+		}}
+	}
+	conversation := []llm.Message{{Role: llm.RoleUser, Parts: []llm.Part{{Type: llm.PartTypeText, Text: "Add two spheres"}}}}
+
+	tests := []struct {
+		name     string
+		provider llm.LLMProvider
+		wantIDs  []string // Expected call IDs; nil only requires them to be distinct
+	}{
+		{
+			name: "provider IDs",
+			provider: &MockProvider{Responses: []*genai.GenerateContentResponse{
+				NewMockResponse("", sphereCall("call-a", "sphere1"), sphereCall("call-b", "sphere2")),
+			}},
+			wantIDs: []string{"call-a", "call-b"},
+		},
+		{
+			name: "generated IDs",
+			provider: &MockProvider{Responses: []*genai.GenerateContentResponse{
+				NewMockResponse("", sphereCall("", "sphere1"), sphereCall("", "sphere2")),
+			}},
+		},
+		{
+			name: "missing IDs",
+			provider: &idlessProvider{MockProvider{Responses: []*genai.GenerateContentResponse{
+				NewMockResponse("", sphereCall("", "sphere1"), sphereCall("", "sphere2")),
+			}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agent := NewWithProvider(make(chan AgentEvent, 100), tt.provider, "mock-model")
+			messages, err := agent.ProcessMessage(context.Background(), conversation)
+			if err != nil {
+				t.Fatalf("ProcessMessage failed: %v", err)
+			}
+			if len(messages) < 3 {
+				t.Fatalf("Expected the calls and their responses in the conversation, got %+v", messages)
+			}
+
+			var callIDs, responseIDs []string
+			for _, part := range messages[1].Parts {
+				if part.FunctionCall != nil {
+					callIDs = append(callIDs, part.FunctionCall.ID)
+				}
+			}
+			for _, part := range messages[2].Parts {
+				if part.FunctionResp != nil {
+					responseIDs = append(responseIDs, part.FunctionResp.ID)
+				}
+			}
+
+			if len(callIDs) != 2 || callIDs[0] == "" || callIDs[0] == callIDs[1] {
+				t.Fatalf("Expected two distinct call IDs, got %q", callIDs)
+			}
+			if tt.wantIDs != nil && !reflect.DeepEqual(callIDs, tt.wantIDs) {
+				t.Errorf("Expected the provider's call IDs %q to be kept, got %q", tt.wantIDs, callIDs)
+			}
+			if !reflect.DeepEqual(responseIDs, callIDs) {
+				t.Errorf("Expected each response to reference its call: calls %q, responses %q", callIDs, responseIDs)
+			}
+		})
+	}
+}
+
+func TestProcessMessageStopsOnRepeatedFailures(t *testing.T) {
+	removeMissing := func(id string) *genai.GenerateContentResponse {
+		return NewMockResponse("", &genai.FunctionCall{Name: "remove_shape", Args: map[string]any{"id": id}})
+	}
+
+	t.Run("identical failures", func(t *testing.T) {
+		events := make(chan AgentEvent, 100)
+		var responses []*genai.GenerateContentResponse
+		for i := 0; i < 8; i++ {
+			responses = append(responses, removeMissing("ghost"))
+		}
+		mockProvider := &MockProvider{Responses: responses}
+		agent := NewWithProvider(events, mockProvider, "mock-model")
+
+		conversation := []llm.Message{{Role: llm.RoleUser, Parts: []llm.Part{{Type: llm.PartTypeText, Text: "Remove the ghost"}}}}
+		messages, err := agent.ProcessMessage(context.Background(), conversation)
+		if err != nil {
+			t.Fatalf("ProcessMessage failed: %v", err)
+		}
+		if len(mockProvider.Requests) != maxRepeatedFailingTurns {
+			t.Errorf("Expected the loop to stop after %d identical failing turns, got %d model calls", maxRepeatedFailingTurns, len(mockProvider.Requests))
+		}
+		if last := messages[len(messages)-1]; last.Parts[0].FunctionResp == nil {
+			t.Errorf("Expected the conversation to end with the last function response, got %+v", last)
+		}
+
+		close(events)
+		stopped := false
+		for event := range events {
+			if e, ok := event.(ResponseEvent); ok && strings.Contains(e.Text, "Stopped early") && strings.Contains(e.Text, "not found") {
+				stopped = true
+			}
+		}
+		if !stopped {
+			t.Error("Expected a message explaining why the agent stopped")
+		}
+	})
+
+	t.Run("varying failures", func(t *testing.T) {
+		// Different arguments each turn mean the model is still trying things
+		events := make(chan AgentEvent, 100)
+		mockProvider := &MockProvider{Responses: []*genai.GenerateContentResponse{
+			removeMissing("ghost"), removeMissing("ghost"), removeMissing("phantom"), removeMissing("phantom"), removeMissing("spirit"),
+		}}
+		agent := NewWithProvider(events, mockProvider, "mock-model")
+
+		conversation := []llm.Message{{Role: llm.RoleUser, Parts: []llm.Part{{Type: llm.PartTypeText, Text: "Remove the ghost"}}}}
+		if _, err := agent.ProcessMessage(context.Background(), conversation); err != nil {
+			t.Fatalf("ProcessMessage failed: %v", err)
+		}
+		if len(mockProvider.Requests) != 6 {
+			t.Errorf("Expected every scripted turn plus the final reply, got %d model calls", len(mockProvider.Requests))
+		}
+	})
+}
+
+func TestRevertShapeTool(t *testing.T) {
+	events := make(chan AgentEvent, 100)
+	agent := NewWithProvider(events, &MockProvider{}, "mock-model")
+	ball := ShapeRequest{ID: "ball", Type: "sphere", Properties: map[string]interface{}{"center": []interface{}{0.0, 1.0, 0.0}, "radius": 1.0}}
+	if err := agent.sceneManager.AddShapes([]ShapeRequest{ball}); err != nil {
+		t.Fatalf("AddShapes() failed: %v", err)
+	}
+
+	execute := func(name string, args map[string]interface{}) ToolResult {
+		req := parseToolRequestFromFunctionCall(&llm.FunctionCall{Name: name, Arguments: args})
+		return agent.executeToolRequests(context.Background(), req, "test_call_1")
+	}
+	if result := execute("update_shape", map[string]interface{}{"id": "ball", "updates": map[string]interface{}{"id": "big_ball", "properties": map[string]interface{}{"radius": 5.0}}}); !result.Success {
+		t.Fatalf("Expected update_shape to succeed, got errors: %v", result.Errors)
+	}
+
+	result := execute("revert_shape", map[string]interface{}{"id": "big_ball"})
+	if !result.Success {
+		t.Fatalf("Expected revert_shape to succeed, got errors: %v", result.Errors)
+	}
+	reverted := result.Result.(*ShapeRequest)
+	if radius, _ := extractFloat(reverted.Properties, "radius"); reverted.ID != "ball" || radius != 1 {
+		t.Errorf("Expected ball with radius 1, got %+v", reverted)
+	}
+
+	result = execute("revert_shape", map[string]interface{}{"id": "ball"})
+	if result.Success || !strings.Contains(strings.Join(result.Errors, " "), "no previous state") {
+		t.Errorf("Expected a second revert to fail, got %+v", result)
+	}
+}
+
+func TestRenameShapesTool(t *testing.T) {
+	events := make(chan AgentEvent, 100)
+	agent := NewWithProvider(events, &MockProvider{}, "mock-model")
+	legs := []ShapeRequest{
+		{ID: "leg_1", Type: "sphere", Properties: map[string]interface{}{"center": []interface{}{0.0, 0.0, 0.0}, "radius": 0.1}},
+		{ID: "leg_2", Type: "sphere", Properties: map[string]interface{}{"center": []interface{}{1.0, 0.0, 0.0}, "radius": 0.1}},
+	}
+	if err := agent.sceneManager.AddShapes(legs); err != nil {
+		t.Fatalf("AddShapes() failed: %v", err)
+	}
+
+	execute := func(args map[string]interface{}) ToolResult {
+		req := parseToolRequestFromFunctionCall(&llm.FunctionCall{Name: "rename_shapes", Arguments: args})
+		return agent.executeToolRequests(context.Background(), req, "test_call_1")
+	}
+
+	result := execute(map[string]interface{}{"ids": []interface{}{"leg_1", "leg_2"}, "prefix": "table_"})
+	if !result.Success {
+		t.Fatalf("Expected rename_shapes to succeed, got errors: %v", result.Errors)
+	}
+	renamed := result.Result.(map[string]interface{})["renamed"].(map[string]string)
+	if renamed["leg_1"] != "table_leg_1" || renamed["leg_2"] != "table_leg_2" {
+		t.Errorf("Expected prefixed IDs, got %v", renamed)
+	}
+
+	result = execute(map[string]interface{}{"mapping": map[string]interface{}{"table_leg_1": "front_leg"}})
+	if !result.Success || agent.sceneManager.FindShape("front_leg") == nil {
+		t.Errorf("Expected the mapping to rename table_leg_1, got %+v", result)
+	}
+
+	for _, args := range []map[string]interface{}{
+		{},
+		{"ids": []interface{}{"front_leg"}},
+		{"mapping": map[string]interface{}{"front_leg": "a"}, "prefix": "x_"},
+	} {
+		if result := execute(args); result.Success {
+			t.Errorf("Expected rename_shapes(%v) to fail", args)
+		}
+	}
+}
+
+func TestFindShapesByTagTool(t *testing.T) {
+	events := make(chan AgentEvent, 100)
+	agent := NewWithProvider(events, &MockProvider{}, "mock-model")
+	err := agent.sceneManager.AddShapes([]ShapeRequest{
+		{ID: "body", Type: "sphere", Properties: map[string]interface{}{
+			"center": []interface{}{0.0, 1.0, 0.0}, "radius": 1.0, "tags": []interface{}{"snowman"}}},
+		{ID: "head", Type: "sphere", Properties: map[string]interface{}{
+			"center": []interface{}{0.0, 2.5, 0.0}, "radius": 0.5, "tags": []interface{}{"snowman", "head"}, "description": "the character's head"}},
+		{ID: "tree", Type: "cone", Properties: map[string]interface{}{
+			"base_center": []interface{}{3.0, 0.0, 0.0}, "top_center": []interface{}{3.0, 3.0, 0.0}, "base_radius": 1.0, "top_radius": 0.0, "capped": true}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to add shapes: %v", err)
+	}
+
+	req := parseToolRequestFromFunctionCall(&llm.FunctionCall{Name: "find_shapes_by_tag", Arguments: map[string]interface{}{"tag": "snowman"}})
+	result := agent.executeToolRequests(context.Background(), req, "test_call_1")
+	if !result.Success {
+		t.Fatalf("Expected find_shapes_by_tag to succeed, got errors: %v", result.Errors)
+	}
+	if ids := req.(*FindShapesByTagRequest).ShapeIds; !reflect.DeepEqual(ids, []string{"body", "head"}) {
+		t.Errorf("Expected body and head, got %v", ids)
+	}
+	shapes := result.Result.(map[string]interface{})["shapes"].([]map[string]interface{})
+	if shapes[1]["description"] != "the character's head" {
+		t.Errorf("Expected the head's description in the result, got %v", shapes[1])
+	}
+
+	req = parseToolRequestFromFunctionCall(&llm.FunctionCall{Name: "find_shapes_by_tag", Arguments: map[string]interface{}{}})
+	if result := agent.executeToolRequests(context.Background(), req, "test_call_2"); result.Success {
+		t.Error("Expected find_shapes_by_tag without a tag to fail")
+	}
+}
+
+func TestSceneIsEmptyTool(t *testing.T) {
+	events := make(chan AgentEvent, 100)
+	agent := NewWithProvider(events, &MockProvider{}, "mock-model")
+
+	check := func() map[string]interface{} {
+		t.Helper()
+		req := parseToolRequestFromFunctionCall(&llm.FunctionCall{Name: "scene_is_empty", Arguments: map[string]interface{}{}})
+		result := agent.executeToolRequests(context.Background(), req, "test_call")
+		if !result.Success {
+			t.Fatalf("Expected scene_is_empty to succeed, got errors: %v", result.Errors)
+		}
+		return result.Result.(map[string]interface{})
+	}
+
+	if result := check(); result["is_empty"] != true || result["shape_count"] != 0 {
+		t.Errorf("Expected a new scene to be empty, got %v", result)
+	}
+
+	// Shapes that don't show up in a render leave the scene empty
+	err := agent.sceneManager.AddShapes([]ShapeRequest{
+		{ID: "ground", Type: "quad", Properties: map[string]interface{}{
+			"corner": []interface{}{-5.0, 0.0, -5.0}, "u": []interface{}{10.0, 0.0, 0.0}, "v": []interface{}{0.0, 0.0, 10.0},
+			"material": map[string]interface{}{"type": "shadow_catcher"}}},
+		{ID: "ghost", Type: "sphere", Properties: map[string]interface{}{
+			"center": []interface{}{0.0, 1.0, 0.0}, "radius": 1.0, "opacity": 0.0}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to add shapes: %v", err)
+	}
+	if result := check(); result["is_empty"] != true || result["shape_count"] != 2 || result["renderable_shape_count"] != 0 {
+		t.Errorf("Expected only hidden shapes to count as empty, got %v", result)
+	}
+
+	if err := agent.sceneManager.UpdateShape("ghost", map[string]interface{}{"properties": map[string]interface{}{"opacity": 0.5}}); err != nil {
+		t.Fatalf("Failed to update shape: %v", err)
+	}
+	if result := check(); result["is_empty"] != false || result["renderable_shape_count"] != 1 {
+		t.Errorf("Expected a visible shape to make the scene non-empty, got %v", result)
+	}
+}
+
+func intPtr(v int) *int {
+	return &v
+}
+
+func TestGetCameraTool(t *testing.T) {
+	events := make(chan AgentEvent, 100)
+	agent := NewWithProvider(events, &MockProvider{}, "mock-model")
+	if err := agent.sceneManager.SetCamera(CameraInfo{Center: []float64{0, 0, 8}, LookAt: []float64{0, 0, 0}, VFov: 50}); err != nil {
+		t.Fatalf("SetCamera() failed: %v", err)
+	}
+
+	req := parseToolRequestFromFunctionCall(&llm.FunctionCall{Name: "get_camera", Arguments: map[string]interface{}{}})
+	result := agent.executeToolRequests(context.Background(), req, "test_call_1")
+	if !result.Success {
+		t.Fatalf("Expected get_camera to succeed, got errors: %v", result.Errors)
+	}
+
+	details, ok := result.Result.(CameraDetails)
+	if !ok {
+		t.Fatalf("Expected CameraDetails result, got %T", result.Result)
+	}
+	if details.Distance != 8 || details.VFov != 50 {
+		t.Errorf("Expected distance 8 and vfov 50, got %+v", details)
+	}
+	if details.ViewDirection[2] != -1 {
+		t.Errorf("Expected camera to look down -Z, got %v", details.ViewDirection)
+	}
+}
+
+func TestZoomCameraTool(t *testing.T) {
+	events := make(chan AgentEvent, 100)
+	agent := NewWithProvider(events, &MockProvider{}, "mock-model")
+	if err := agent.sceneManager.SetCamera(CameraInfo{Center: []float64{0, 0, 8}, LookAt: []float64{0, 0, 0}, VFov: 50}); err != nil {
+		t.Fatalf("SetCamera() failed: %v", err)
+	}
+
+	req := parseToolRequestFromFunctionCall(&llm.FunctionCall{Name: "zoom_camera", Arguments: map[string]interface{}{"factor": 0.25}})
+	result := agent.executeToolRequests(context.Background(), req, "test_call_1")
+	if !result.Success {
+		t.Fatalf("Expected zoom_camera to succeed, got errors: %v", result.Errors)
+	}
+	if camera := result.Result.(CameraInfo); camera.Center[2] != 2 {
+		t.Errorf("Expected camera at z=2 after zooming by 0.25, got %v", camera.Center)
+	}
+
+	// A missing factor parses as 0 and is rejected
+	bad := parseToolRequestFromFunctionCall(&llm.FunctionCall{Name: "zoom_camera", Arguments: map[string]interface{}{}})
+	if result := agent.executeToolRequests(context.Background(), bad, "test_call_2"); result.Success {
+		t.Error("Expected zoom_camera without a factor to fail")
+	}
+}
+
+func TestSetCameraPresetTool(t *testing.T) {
+	events := make(chan AgentEvent, 100)
+	agent := NewWithProvider(events, &MockProvider{}, "mock-model")
+
+	// Fails until there is something to frame
+	req := parseToolRequestFromFunctionCall(&llm.FunctionCall{Name: "set_camera_preset", Arguments: map[string]interface{}{"preset": "front"}})
+	if result := agent.executeToolRequests(context.Background(), req, "test_call_1"); result.Success {
+		t.Error("Expected set_camera_preset to fail on an empty scene")
+	}
+
+	shape := ShapeRequest{ID: "ball", Type: "sphere", Properties: map[string]interface{}{"center": []interface{}{2.0, 1.0, 0.0}, "radius": 1.0}}
+	if err := agent.sceneManager.AddShapes([]ShapeRequest{shape}); err != nil {
+		t.Fatalf("AddShapes() failed: %v", err)
+	}
+
+	req = parseToolRequestFromFunctionCall(&llm.FunctionCall{Name: "set_camera_preset", Arguments: map[string]interface{}{"preset": "front"}})
+	result := agent.executeToolRequests(context.Background(), req, "test_call_2")
+	if !result.Success {
+		t.Fatalf("Expected set_camera_preset to succeed, got errors: %v", result.Errors)
+	}
+	camera := result.Result.(CameraInfo)
+	if camera.LookAt[0] != 2 || camera.LookAt[1] != 1 || camera.LookAt[2] != 0 {
+		t.Errorf("Expected camera to look at the sphere center, got %v", camera.LookAt)
+	}
+	if camera.Center[0] != 2 || camera.Center[1] != 1 || camera.Center[2] <= 1 {
+		t.Errorf("Expected camera in front of the sphere, got %v", camera.Center)
+	}
+
+	bad := parseToolRequestFromFunctionCall(&llm.FunctionCall{Name: "set_camera_preset", Arguments: map[string]interface{}{"preset": "sideways"}})
+	if result := agent.executeToolRequests(context.Background(), bad, "test_call_3"); result.Success {
+		t.Error("Expected set_camera_preset with an unknown preset to fail")
+	}
+}
+
+func TestFrameShapeTool(t *testing.T) {
+	events := make(chan AgentEvent, 100)
+	agent := NewWithProvider(events, &MockProvider{}, "mock-model")
+	shape := ShapeRequest{ID: "ball", Type: "sphere", Properties: map[string]interface{}{"center": []interface{}{2.0, 1.0, 0.0}, "radius": 1.0}}
+	if err := agent.sceneManager.AddShapes([]ShapeRequest{shape}); err != nil {
+		t.Fatalf("AddShapes() failed: %v", err)
+	}
+
+	req := parseToolRequestFromFunctionCall(&llm.FunctionCall{Name: "frame_shape", Arguments: map[string]interface{}{"id": "ball"}})
+	if req.(*FrameShapeRequest).Margin != defaultFrameMargin {
+		t.Errorf("Expected the default margin, got %g", req.(*FrameShapeRequest).Margin)
+	}
+	result := agent.executeToolRequests(context.Background(), req, "test_call_1")
+	if !result.Success {
+		t.Fatalf("Expected frame_shape to succeed, got errors: %v", result.Errors)
+	}
+	camera := result.Result.(CameraInfo)
+	if camera.LookAt[0] != 2 || camera.LookAt[1] != 1 || camera.LookAt[2] != 0 {
+		t.Errorf("Expected camera to look at the sphere center, got %v", camera.LookAt)
+	}
+
+	bad := parseToolRequestFromFunctionCall(&llm.FunctionCall{Name: "frame_shape", Arguments: map[string]interface{}{"id": "cube"}})
+	result = agent.executeToolRequests(context.Background(), bad, "test_call_2")
+	if result.Success || !strings.Contains(strings.Join(result.Errors, "; "), "not found") {
+		t.Errorf("Expected frame_shape with an unknown shape to fail, got %+v", result)
+	}
+}
+
+func TestCameraToolsKeepFStop(t *testing.T) {
+	events := make(chan AgentEvent, 100)
+	agent := NewWithProvider(events, &MockProvider{}, "mock-model")
+	shape := ShapeRequest{ID: "ball", Type: "sphere", Properties: map[string]interface{}{"center": []interface{}{2.0, 1.0, 0.0}, "radius": 1.0}}
+	if err := agent.sceneManager.AddShapes([]ShapeRequest{shape}); err != nil {
+		t.Fatalf("AddShapes() failed: %v", err)
+	}
+	if err := agent.sceneManager.SetCamera(CameraInfo{Center: []float64{0, 0, 8}, LookAt: []float64{0, 0, 0}, VFov: 40, FStop: 2.8}); err != nil {
+		t.Fatalf("SetCamera() failed: %v", err)
+	}
+
+	// The stored camera carries fstop and its derived aperture, which must not be rejected as
+	// both being set when the tools re-set it
+	calls := []*llm.FunctionCall{
+		{Name: "zoom_camera", Arguments: map[string]interface{}{"factor": 0.5}},
+		{Name: "set_camera_preset", Arguments: map[string]interface{}{"preset": "iso"}},
+		{Name: "frame_shape", Arguments: map[string]interface{}{"id": "ball"}},
+	}
+	for i, call := range calls {
+		req := parseToolRequestFromFunctionCall(call)
+		result := agent.executeToolRequests(context.Background(), req, fmt.Sprintf("test_call_%d", i))
+		if !result.Success {
+			t.Fatalf("Expected %s to succeed on an fstop camera, got errors: %v", call.Name, result.Errors)
+		}
+		if camera := agent.sceneManager.GetCamera(); camera.FStop != 2.8 || camera.Aperture != fstopToAperture(2.8, 40) {
+			t.Errorf("Expected %s to keep fstop 2.8 and its aperture, got %+v", call.Name, camera)
+		}
+	}
+
+	// Setting both explicitly is still rejected
+	err := agent.sceneManager.SetCamera(CameraInfo{Center: []float64{0, 0, 8}, LookAt: []float64{0, 0, 0}, VFov: 40, FStop: 2.8, Aperture: 0.5})
+	if err == nil || !strings.Contains(err.Error(), "mutually exclusive") {
+		t.Errorf("Expected fstop with an explicit aperture to be rejected, got %v", err)
+	}
+}
+
+func TestSetAspectRatioTool(t *testing.T) {
+	events := make(chan AgentEvent, 100)
+	agent := NewWithProvider(events, &MockProvider{}, "mock-model")
+	agent.sceneManager = newRenderableSceneManager(t)
+
+	req := parseToolRequestFromFunctionCall(&llm.FunctionCall{Name: "set_aspect_ratio", Arguments: map[string]interface{}{"aspect_ratio": "1:1"}})
+	result := agent.executeToolRequests(context.Background(), req, "test_call_1")
+	if !result.Success {
+		t.Fatalf("Expected set_aspect_ratio to succeed, got errors: %v", result.Errors)
+	}
+	metadata := result.Result.(map[string]interface{})
+	if metadata["aspect_ratio"] != 1.0 || metadata["width"] != metadata["height"] {
+		t.Errorf("Expected square dimensions, got %v", metadata)
+	}
+
+	// Renders use the new shape
+	agent.renderQuality = QualityPreview
+	renderReq := &RenderSceneRequest{BaseToolRequest: BaseToolRequest{ToolType: "render_scene"}}
+	result = agent.executeToolRequests(context.Background(), renderReq, "test_call_2")
+	if !result.Success {
+		t.Fatalf("Expected render to succeed, got errors: %v", result.Errors)
+	}
+	metadata = result.Result.(map[string]interface{})
+	if metadata["width"] != metadata["height"] {
+		t.Errorf("Expected a square render, got %vx%v", metadata["width"], metadata["height"])
+	}
+	img, err := png.Decode(bytes.NewReader(renderReq.RenderedImage))
+	if err != nil {
+		t.Fatalf("Failed to decode render: %v", err)
+	}
+	if bounds := img.Bounds(); bounds.Dx() != bounds.Dy() {
+		t.Errorf("Expected a square image, got %v", bounds)
+	}
+
+	// A bare number works too
+	req = parseToolRequestFromFunctionCall(&llm.FunctionCall{Name: "set_aspect_ratio", Arguments: map[string]interface{}{"aspect_ratio": 2.0}})
+	if result := agent.executeToolRequests(context.Background(), req, "test_call_3"); !result.Success || agent.sceneManager.GetCamera().AspectRatio != 2 {
+		t.Errorf("Expected aspect_ratio 2 to be accepted, got %+v", result)
+	}
+
+	bad := parseToolRequestFromFunctionCall(&llm.FunctionCall{Name: "set_aspect_ratio", Arguments: map[string]interface{}{"aspect_ratio": "0:9"}})
+	if result := agent.executeToolRequests(context.Background(), bad, "test_call_4"); result.Success {
+		t.Error("Expected a zero aspect ratio to be rejected")
+	}
+}
+
+func TestGetSceneStateSince(t *testing.T) {
+	events := make(chan AgentEvent, 100)
+	agent := NewWithProvider(events, &MockProvider{}, "mock-model")
+
+	// Full state includes the revision to pass back later
+	full := parseToolRequestFromFunctionCall(&llm.FunctionCall{Name: "get_scene_state", Arguments: map[string]interface{}{}})
+	result := agent.executeToolRequests(context.Background(), full, "call_1")
+	resultMap, ok := result.Result.(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected full state map, got %T", result.Result)
+	}
+	revision, ok := resultMap["revision"].(int)
+	if !ok {
+		t.Fatalf("Expected revision in full state, got %v", resultMap["revision"])
+	}
+
+	shape := ShapeRequest{
+		ID:   "sphere1",
+		Type: "sphere",
+		Properties: map[string]interface{}{
+			"center": []interface{}{0.0, 0.0, 0.0},
+			"radius": 1.0,
+		},
+	}
+	if err := agent.sceneManager.AddShapes([]ShapeRequest{shape}); err != nil {
+		t.Fatalf("AddShapes() failed: %v", err)
+	}
+
+	// Arguments arrive as JSON numbers
+	diff := parseToolRequestFromFunctionCall(&llm.FunctionCall{
+		Name:      "get_scene_state",
+		Arguments: map[string]interface{}{"since": float64(revision)},
+	})
+	result = agent.executeToolRequests(context.Background(), diff, "call_2")
+	if !result.Success {
+		t.Fatalf("Expected success, got errors: %v", result.Errors)
+	}
+
+	changes, ok := result.Result.(*SceneChanges)
+	if !ok {
+		t.Fatalf("Expected *SceneChanges, got %T", result.Result)
+	}
+	if len(changes.Shapes) != 1 || changes.Shapes[0].ID != "sphere1" {
+		t.Errorf("Expected only sphere1 to be reported, got %+v", changes.Shapes)
+	}
+	if changes.Revision != agent.sceneManager.Revision() {
+		t.Errorf("Expected current revision %d, got %d", agent.sceneManager.Revision(), changes.Revision)
+	}
+}
+
+// hangingProvider is an LLM provider whose first hangs calls block until their context ends
+type hangingProvider struct {
+	MockProvider
+	hangs int
+	calls int
+}
+
+func (p *hangingProvider) GenerateContent(ctx context.Context, req *llm.GenerateRequest) (*llm.Response, error) {
+	p.calls++
+	if p.calls <= p.hangs {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return p.MockProvider.GenerateContent(ctx, req)
+}
+
+func TestCallTimeout(t *testing.T) {
+	conversation := []llm.Message{{Role: llm.RoleUser, Parts: []llm.Part{{Type: llm.PartTypeText, Text: "Add a red ball"}}}}
+
+	t.Run("gives up after retrying", func(t *testing.T) {
+		provider := &hangingProvider{hangs: 100}
+		agent := NewWithProvider(make(chan AgentEvent, 100), provider, "mock-model")
+		agent.SetCallTimeout(20 * time.Millisecond)
+
+		messages, err := agent.ProcessMessage(context.Background(), conversation)
+		if !errors.Is(err, llm.ErrTimeout) {
+			t.Fatalf("Expected a timeout error, got %v", err)
+		}
+		if provider.calls != 1+maxTimeoutRetries {
+			t.Errorf("Expected the call to be tried %d times, got %d", 1+maxTimeoutRetries, provider.calls)
+		}
+		if len(messages) != len(conversation) {
+			t.Errorf("Expected no assistant message after a timeout, got %d messages", len(messages))
+		}
+	})
+
+	t.Run("retry succeeds", func(t *testing.T) {
+		provider := &hangingProvider{hangs: 1}
+		agent := NewWithProvider(make(chan AgentEvent, 100), provider, "mock-model")
+		agent.SetCallTimeout(20 * time.Millisecond)
+
+		messages, err := agent.ProcessMessage(context.Background(), conversation)
+		if err != nil {
+			t.Fatalf("Expected the retried call to succeed, got %v", err)
+		}
+		if len(messages) != len(conversation)+1 || messages[len(messages)-1].Role != llm.RoleAssistant {
+			t.Errorf("Expected one assistant message after the retry, got %+v", messages)
+		}
+	})
+
+	t.Run("cancellation is not a timeout", func(t *testing.T) {
+		provider := &hangingProvider{hangs: 100}
+		agent := NewWithProvider(make(chan AgentEvent, 100), provider, "mock-model")
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		_, err := agent.ProcessMessage(ctx, conversation)
+		if err == nil || errors.Is(err, llm.ErrTimeout) {
+			t.Errorf("Expected the caller's deadline to end the message without a timeout error, got %v", err)
+		}
+		if provider.calls != 1 {
+			t.Errorf("Expected no retry once the caller's context ended, got %d calls", provider.calls)
+		}
+	})
+
+	if got := NewWithProvider(nil, nil, "").CallTimeout(); got != defaultCallTimeout {
+		t.Errorf("Expected the default call timeout %s, got %s", defaultCallTimeout, got)
+	}
+}
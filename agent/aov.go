@@ -0,0 +1,379 @@
+package agent
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+)
+
+// AOV selects which arbitrary output variable render_scene produces
+type AOV string
+
+const (
+	AOVBeauty AOV = "beauty" // Final shaded image (default)
+	AOVNormal AOV = "normal" // World-space surface normals mapped to RGB
+	AOVDepth  AOV = "depth"  // Distance from the camera as normalized grayscale (near is bright)
+)
+
+// ParseAOV converts a tool argument to an AOV
+// An empty value defaults to beauty
+func ParseAOV(aov string) (AOV, error) {
+	switch AOV(aov) {
+	case "", AOVBeauty:
+		return AOVBeauty, nil
+	case AOVNormal, AOVDepth:
+		return AOV(aov), nil
+	default:
+		return "", fmt.Errorf("unsupported aov '%s' (supported: beauty, normal, depth)", aov)
+	}
+}
+
+// hitEpsilon ignores intersections this close to the ray origin
+const hitEpsilon = 1e-6
+
+// surfaceHit is the nearest intersection of a ray with a shape
+type surfaceHit struct {
+	t      float64 // Distance along the (normalized) ray
+	normal vec3    // Unit outward surface normal
+}
+
+// closer returns whichever hit is nearer, treating ok=false as no hit
+func closer(best surfaceHit, found bool, candidate surfaceHit, ok bool) (surfaceHit, bool) {
+	if ok && (!found || candidate.t < best.t) {
+		return candidate, true
+	}
+	return best, found
+}
+
+// intersectShape finds the nearest intersection of a ray with a shape
+// dir must be normalized. Unknown shape types never hit.
+func intersectShape(shape ShapeRequest, origin, dir vec3) (surfaceHit, bool) {
+	props := shape.Properties
+	switch shape.Type {
+	case "sphere":
+		center := vec3Property(props, "center", vec3{})
+		radius, _ := extractFloat(props, "radius")
+		return intersectSphere(center, radius, origin, dir)
+
+	case "ellipsoid":
+		center := vec3Property(props, "center", vec3{})
+		radii := vec3Property(props, "radii", vec3{1, 1, 1})
+		return intersectEllipsoid(center, radii, origin, dir)
+
+	case "pyramid":
+		center := vec3Property(props, "center", vec3{})
+		baseSize, ok := extractFloatArray(props, "base_size", 2)
+		if !ok {
+			return surfaceHit{}, false
+		}
+		height, _ := extractFloat(props, "height")
+		return pyramidMesh(center, baseSize[0], baseSize[1], height).intersect(origin, dir)
+
+	case "box":
+		center := vec3Property(props, "center", vec3{})
+		half := vec3Property(props, "dimensions", vec3{}).scale(0.5)
+		rotation := vec3Property(props, "rotation", vec3{})
+		return intersectBox(center, half, rotation, origin, dir)
+
+	case "quad":
+		corner := vec3Property(props, "corner", vec3{})
+		u := vec3Property(props, "u", vec3{})
+		v := vec3Property(props, "v", vec3{})
+		return intersectQuad(corner, u, v, origin, dir)
+
+	case "disc":
+		center := vec3Property(props, "center", vec3{})
+		normal := vec3Property(props, "normal", vec3{0, 1, 0})
+		radius, _ := extractFloat(props, "radius")
+		return intersectDisc(center, normal, radius, origin, dir)
+
+	case "cylinder":
+		base := vec3Property(props, "base_center", vec3{})
+		top := vec3Property(props, "top_center", vec3{})
+		radius, _ := extractFloat(props, "radius")
+		capped, _ := props["capped"].(bool)
+		return intersectFrustum(base, top, radius, radius, capped, origin, dir)
+
+	case "cone":
+		base := vec3Property(props, "base_center", vec3{})
+		top := vec3Property(props, "top_center", vec3{})
+		baseRadius, _ := extractFloat(props, "base_radius")
+		topRadius, _ := extractFloat(props, "top_radius")
+		capped, _ := props["capped"].(bool)
+		return intersectFrustum(base, top, baseRadius, topRadius, capped, origin, dir)
+	}
+
+	return surfaceHit{}, false
+}
+
+func intersectSphere(center vec3, radius float64, origin, dir vec3) (surfaceHit, bool) {
+	oc := origin.sub(center)
+	b := oc.dot(dir)
+	c := oc.dot(oc) - radius*radius
+	disc := b*b - c
+	if disc < 0 {
+		return surfaceHit{}, false
+	}
+
+	sqrtDisc := math.Sqrt(disc)
+	for _, t := range []float64{-b - sqrtDisc, -b + sqrtDisc} {
+		if t > hitEpsilon {
+			p := origin.add(dir.scale(t))
+			return surfaceHit{t: t, normal: p.sub(center).scale(1 / radius)}, true
+		}
+	}
+	return surfaceHit{}, false
+}
+
+// intersectEllipsoid intersects an axis-aligned ellipsoid by scaling the ray so the ellipsoid
+// becomes a unit sphere. The scaled direction isn't normalized, so the quadratic keeps its a term,
+// and t stays a distance along the original ray.
+func intersectEllipsoid(center, radii vec3, origin, dir vec3) (surfaceHit, bool) {
+	toUnit := func(v vec3) vec3 { return vec3{v[0] / radii[0], v[1] / radii[1], v[2] / radii[2]} }
+	oc := toUnit(origin.sub(center))
+	d := toUnit(dir)
+
+	a := d.dot(d)
+	b := oc.dot(d)
+	c := oc.dot(oc) - 1
+	disc := b*b - a*c
+	if disc < 0 {
+		return surfaceHit{}, false
+	}
+
+	sqrtDisc := math.Sqrt(disc)
+	for _, t := range []float64{(-b - sqrtDisc) / a, (-b + sqrtDisc) / a} {
+		if t > hitEpsilon {
+			// The normal is the gradient of the implicit surface, (p - center) / radii²
+			unit := oc.add(d.scale(t))
+			return surfaceHit{t: t, normal: toUnit(unit).normalize()}, true
+		}
+	}
+	return surfaceHit{}, false
+}
+
+// intersectBox intersects an oriented box by transforming the ray into the box's local frame
+func intersectBox(center, half, rotation vec3, origin, dir vec3) (surfaceHit, bool) {
+	localOrigin := origin.sub(center).unrotateXYZ(rotation)
+	localDir := dir.unrotateXYZ(rotation)
+
+	tNear, tFar := math.Inf(-1), math.Inf(1)
+	nearAxis, farAxis := 0, 0
+	for axis := 0; axis < 3; axis++ {
+		if math.Abs(localDir[axis]) < 1e-12 {
+			if math.Abs(localOrigin[axis]) > half[axis] {
+				return surfaceHit{}, false // Parallel to and outside this slab
+			}
+			continue
+		}
+		t0 := (-half[axis] - localOrigin[axis]) / localDir[axis]
+		t1 := (half[axis] - localOrigin[axis]) / localDir[axis]
+		if t0 > t1 {
+			t0, t1 = t1, t0
+		}
+		if t0 > tNear {
+			tNear, nearAxis = t0, axis
+		}
+		if t1 < tFar {
+			tFar, farAxis = t1, axis
+		}
+	}
+	if tNear > tFar || tFar <= hitEpsilon {
+		return surfaceHit{}, false
+	}
+
+	// Use the exit face if the ray starts inside the box
+	t, axis := tNear, nearAxis
+	if t <= hitEpsilon {
+		t, axis = tFar, farAxis
+	}
+
+	var normal vec3
+	if localOrigin[axis]+localDir[axis]*t > 0 {
+		normal[axis] = 1
+	} else {
+		normal[axis] = -1
+	}
+	return surfaceHit{t: t, normal: normal.rotateXYZ(rotation)}, true
+}
+
+func intersectQuad(corner, u, v vec3, origin, dir vec3) (surfaceHit, bool) {
+	n := u.cross(v)
+	denom := n.dot(dir)
+	if math.Abs(denom) < 1e-12 {
+		return surfaceHit{}, false
+	}
+	t := n.dot(corner.sub(origin)) / denom
+	if t <= hitEpsilon {
+		return surfaceHit{}, false
+	}
+
+	// Solve for the hit point's coordinates along u and v
+	p := origin.add(dir.scale(t)).sub(corner)
+	w := n.scale(1 / n.dot(n))
+	alpha := w.dot(p.cross(v))
+	beta := w.dot(u.cross(p))
+	if alpha < 0 || alpha > 1 || beta < 0 || beta > 1 {
+		return surfaceHit{}, false
+	}
+	return surfaceHit{t: t, normal: n.normalize()}, true
+}
+
+func intersectDisc(center, normal vec3, radius float64, origin, dir vec3) (surfaceHit, bool) {
+	n := normal.normalize()
+	denom := n.dot(dir)
+	if math.Abs(denom) < 1e-12 {
+		return surfaceHit{}, false
+	}
+	t := n.dot(center.sub(origin)) / denom
+	if t <= hitEpsilon {
+		return surfaceHit{}, false
+	}
+	if origin.add(dir.scale(t)).sub(center).length() > radius {
+		return surfaceHit{}, false
+	}
+	return surfaceHit{t: t, normal: n}, true
+}
+
+// intersectFrustum intersects a cylinder (equal radii) or cone (top radius smaller)
+// The side is the surface whose radius varies linearly from baseRadius to topRadius along the axis.
+func intersectFrustum(base, top vec3, baseRadius, topRadius float64, capped bool, origin, dir vec3) (surfaceHit, bool) {
+	axisVec := top.sub(base)
+	height := axisVec.length()
+	if height == 0 {
+		return surfaceHit{}, false
+	}
+	axis := axisVec.scale(1 / height)
+	slope := (topRadius - baseRadius) / height
+
+	// Split the ray into components along and perpendicular to the axis
+	ro := origin.sub(base)
+	s0, ds := ro.dot(axis), dir.dot(axis)
+	roPerp := ro.sub(axis.scale(s0))
+	dPerp := dir.sub(axis.scale(ds))
+
+	// |roPerp + t*dPerp|^2 = (baseRadius + slope*(s0 + t*ds))^2
+	r0 := baseRadius + slope*s0
+	a := dPerp.dot(dPerp) - slope*slope*ds*ds
+	b := 2 * (roPerp.dot(dPerp) - slope*ds*r0)
+	c := roPerp.dot(roPerp) - r0*r0
+
+	var best surfaceHit
+	found := false
+
+	var roots []float64
+	if math.Abs(a) < 1e-12 {
+		if math.Abs(b) > 1e-12 {
+			roots = []float64{-c / b}
+		}
+	} else if disc := b*b - 4*a*c; disc >= 0 {
+		sqrtDisc := math.Sqrt(disc)
+		roots = []float64{(-b - sqrtDisc) / (2 * a), (-b + sqrtDisc) / (2 * a)}
+	}
+	for _, t := range roots {
+		if t <= hitEpsilon {
+			continue
+		}
+		s := s0 + t*ds
+		if s < 0 || s > height {
+			continue
+		}
+		radial := roPerp.add(dPerp.scale(t)).normalize()
+		side := surfaceHit{t: t, normal: radial.sub(axis.scale(slope)).normalize()}
+		best, found = closer(best, found, side, true)
+	}
+
+	if capped {
+		baseHit, ok := intersectDisc(base, axis.scale(-1), baseRadius, origin, dir)
+		best, found = closer(best, found, baseHit, ok)
+		if topRadius > 0 {
+			topHit, ok := intersectDisc(top, axis, topRadius, origin, dir)
+			best, found = closer(best, found, topHit, ok)
+		}
+	}
+
+	return best, found
+}
+
+// RenderAOV renders a normal or depth buffer of the scene from the scene camera
+// It casts one primary ray per pixel against the scene's shapes directly, so it is
+// deterministic and fast. Pixels that miss every shape are black. Large buffers are
+// traced in tiles on every core.
+func RenderAOV(state *SceneState, aov AOV, width, height int) (image.Image, error) {
+	return renderAOV(state, aov, width, height, renderWorkers(width, height))
+}
+
+// renderAOV is RenderAOV with an explicit number of tracing workers
+func renderAOV(state *SceneState, aov AOV, width, height, workers int) (image.Image, error) {
+	if aov != AOVNormal && aov != AOVDepth {
+		return nil, fmt.Errorf("aov '%s' can't be rendered as a buffer", aov)
+	}
+
+	camera := newSceneCamera(state.Camera, width, height)
+	forward := camera.w.scale(-1)
+
+	// Cast primary rays and keep the nearest hit for each pixel
+	hits := make([]surfaceHit, width*height)
+	hitMask := make([]bool, width*height)
+	renderTiles(image.Rect(0, 0, width, height), workers, func(tile image.Rectangle) {
+		for y := tile.Min.Y; y < tile.Max.Y; y++ {
+			for x := tile.Min.X; x < tile.Max.X; x++ {
+				dir := camera.ray(float64(x)+0.5, float64(y)+0.5)
+				var best surfaceHit
+				found := false
+				for _, shape := range state.Shapes {
+					hit, ok := intersectShape(shape, camera.origin, dir)
+					best, found = closer(best, found, hit, ok)
+				}
+				if !found {
+					continue
+				}
+
+				i := y*width + x
+				if aov == AOVDepth {
+					best.t *= dir.dot(forward) // Distance along the view axis rather than the ray
+				} else if best.normal.dot(dir) > 0 {
+					best.normal = best.normal.scale(-1) // Show the side facing the camera
+				}
+				hits[i], hitMask[i] = best, true
+			}
+		}
+	})
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	if aov == AOVNormal {
+		channel := func(v float64) uint8 { return uint8(math.Round((v*0.5 + 0.5) * 255)) }
+		for i, hit := range hits {
+			if hitMask[i] {
+				n := hit.normal
+				img.SetRGBA(i%width, i/width, color.RGBA{channel(n[0]), channel(n[1]), channel(n[2]), 255})
+			} else {
+				img.SetRGBA(i%width, i/width, color.RGBA{0, 0, 0, 255})
+			}
+		}
+		return img, nil
+	}
+
+	// Normalize depth over the visible range so near surfaces are bright and far ones dark
+	minDepth, maxDepth := math.Inf(1), math.Inf(-1)
+	for i, hit := range hits {
+		if hitMask[i] {
+			minDepth = math.Min(minDepth, hit.t)
+			maxDepth = math.Max(maxDepth, hit.t)
+		}
+	}
+	for i, hit := range hits {
+		var gray uint8
+		if hitMask[i] {
+			value := 1.0
+			if maxDepth > minDepth {
+				value = 1 - 0.8*(hit.t-minDepth)/(maxDepth-minDepth) // Keep the farthest surface distinct from background
+			}
+			gray = uint8(math.Round(value * 255))
+		}
+		img.SetRGBA(i%width, i/width, color.RGBA{gray, gray, gray, 255})
+	}
+	return img, nil
+}
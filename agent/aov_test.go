@@ -0,0 +1,275 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"image/color"
+	"image/png"
+	"math"
+	"testing"
+)
+
+func TestParseAOV(t *testing.T) {
+	tests := []struct {
+		input       string
+		expected    AOV
+		expectError bool
+	}{
+		{"", AOVBeauty, false},
+		{"beauty", AOVBeauty, false},
+		{"normal", AOVNormal, false},
+		{"depth", AOVDepth, false},
+		{"albedo", "", true},
+	}
+
+	for _, tt := range tests {
+		aov, err := ParseAOV(tt.input)
+		if tt.expectError {
+			if err == nil {
+				t.Errorf("ParseAOV(%q) expected error, got %q", tt.input, aov)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseAOV(%q) unexpected error: %v", tt.input, err)
+		}
+		if aov != tt.expected {
+			t.Errorf("ParseAOV(%q) = %q, want %q", tt.input, aov, tt.expected)
+		}
+	}
+}
+
+func vecNear(a, b vec3) bool {
+	return a.sub(b).length() < 1e-6
+}
+
+func TestUnrotateInvertsRotate(t *testing.T) {
+	rotation := vec3{0.3, -1.2, 2.0}
+	v := vec3{1, 2, 3}
+	if got := v.rotateXYZ(rotation).unrotateXYZ(rotation); !vecNear(got, v) {
+		t.Errorf("Expected unrotate(rotate(v)) = %v, got %v", v, got)
+	}
+}
+
+func TestIntersectShape(t *testing.T) {
+	origin := vec3{0, 0, 5}
+	dir := vec3{0, 0, -1}
+
+	tests := []struct {
+		name      string
+		shape     ShapeRequest
+		expectHit bool
+		expectT   float64
+		normal    vec3
+	}{
+		{
+			name: "sphere",
+			shape: ShapeRequest{Type: "sphere", Properties: map[string]interface{}{
+				"center": []interface{}{0.0, 0.0, 0.0}, "radius": 1.0,
+			}},
+			expectHit: true, expectT: 4, normal: vec3{0, 0, 1},
+		},
+		{
+			name: "sphere missed",
+			shape: ShapeRequest{Type: "sphere", Properties: map[string]interface{}{
+				"center": []interface{}{3.0, 0.0, 0.0}, "radius": 1.0,
+			}},
+			expectHit: false,
+		},
+		{
+			name: "ellipsoid",
+			shape: ShapeRequest{Type: "ellipsoid", Properties: map[string]interface{}{
+				"center": []interface{}{0.0, 0.0, 0.0}, "radii": []interface{}{2.0, 1.0, 0.5},
+			}},
+			expectHit: true, expectT: 4.5, normal: vec3{0, 0, 1},
+		},
+		{
+			name: "box",
+			shape: ShapeRequest{Type: "box", Properties: map[string]interface{}{
+				"center": []interface{}{0.0, 0.0, 0.0}, "dimensions": []interface{}{2.0, 2.0, 2.0},
+			}},
+			expectHit: true, expectT: 4, normal: vec3{0, 0, 1},
+		},
+		{
+			name: "box rotated 45 degrees about y",
+			shape: ShapeRequest{Type: "box", Properties: map[string]interface{}{
+				"center": []interface{}{0.0, 0.0, 0.0}, "dimensions": []interface{}{2.0, 2.0, 2.0},
+				"rotation": []interface{}{0.0, math.Pi / 4, 0.0},
+			}},
+			// The front edge of the rotated cube is sqrt(2) from its center
+			expectHit: true, expectT: 5 - math.Sqrt2,
+		},
+		{
+			name: "pyramid",
+			shape: ShapeRequest{Type: "pyramid", Properties: map[string]interface{}{
+				"center": []interface{}{0.0, -1.0, 0.0}, "base_size": []interface{}{2.0, 2.0}, "height": 2.0,
+			}},
+			// Halfway up, the front face is 0.5 in front of the axis
+			expectHit: true, expectT: 4.5, normal: vec3{0, 1, 2}.normalize(),
+		},
+		{
+			name: "quad",
+			shape: ShapeRequest{Type: "quad", Properties: map[string]interface{}{
+				"corner": []interface{}{-1.0, -1.0, 0.0}, "u": []interface{}{2.0, 0.0, 0.0}, "v": []interface{}{0.0, 2.0, 0.0},
+			}},
+			expectHit: true, expectT: 5, normal: vec3{0, 0, 1},
+		},
+		{
+			name: "disc",
+			shape: ShapeRequest{Type: "disc", Properties: map[string]interface{}{
+				"center": []interface{}{0.0, 0.0, 1.0}, "normal": []interface{}{0.0, 0.0, 1.0}, "radius": 0.5,
+			}},
+			expectHit: true, expectT: 4, normal: vec3{0, 0, 1},
+		},
+		{
+			name: "cylinder side",
+			shape: ShapeRequest{Type: "cylinder", Properties: map[string]interface{}{
+				"base_center": []interface{}{0.0, -1.0, 0.0}, "top_center": []interface{}{0.0, 1.0, 0.0},
+				"radius": 1.0, "capped": true,
+			}},
+			expectHit: true, expectT: 4, normal: vec3{0, 0, 1},
+		},
+		{
+			name: "cylinder cap",
+			shape: ShapeRequest{Type: "cylinder", Properties: map[string]interface{}{
+				"base_center": []interface{}{0.0, 0.0, -1.0}, "top_center": []interface{}{0.0, 0.0, 1.0},
+				"radius": 1.0, "capped": true,
+			}},
+			expectHit: true, expectT: 4, normal: vec3{0, 0, 1},
+		},
+		{
+			name: "uncapped cylinder seen end-on hits far side only",
+			shape: ShapeRequest{Type: "cylinder", Properties: map[string]interface{}{
+				"base_center": []interface{}{0.0, 0.0, -1.0}, "top_center": []interface{}{0.0, 0.0, 1.0},
+				"radius": 1.0, "capped": false,
+			}},
+			expectHit: false,
+		},
+		{
+			name: "cone side",
+			shape: ShapeRequest{Type: "cone", Properties: map[string]interface{}{
+				"base_center": []interface{}{0.0, -1.0, 0.0}, "top_center": []interface{}{0.0, 1.0, 0.0},
+				"base_radius": 1.0, "top_radius": 0.0, "capped": true,
+			}},
+			// Halfway up, the cone's radius is 0.5
+			expectHit: true, expectT: 4.5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hit, ok := intersectShape(tt.shape, origin, dir)
+			if ok != tt.expectHit {
+				t.Fatalf("Expected hit=%v, got %v (t=%v)", tt.expectHit, ok, hit.t)
+			}
+			if !ok {
+				return
+			}
+			if math.Abs(hit.t-tt.expectT) > 1e-6 {
+				t.Errorf("Expected t=%v, got %v", tt.expectT, hit.t)
+			}
+			if math.Abs(hit.normal.length()-1) > 1e-6 {
+				t.Errorf("Expected unit normal, got %v", hit.normal)
+			}
+			if tt.normal != (vec3{}) && !vecNear(hit.normal, tt.normal) {
+				t.Errorf("Expected normal %v, got %v", tt.normal, hit.normal)
+			}
+		})
+	}
+}
+
+func TestRenderAOV(t *testing.T) {
+	state := &SceneState{
+		Camera: CameraInfo{Center: []float64{0, 0, 5}, LookAt: []float64{0, 0, 0}, VFov: 45},
+		Shapes: []ShapeRequest{
+			{ID: "near", Type: "sphere", Properties: map[string]interface{}{
+				"center": []interface{}{-0.8, 0.0, 1.0}, "radius": 0.5,
+			}},
+			{ID: "far", Type: "sphere", Properties: map[string]interface{}{
+				"center": []interface{}{0.8, 0.0, -1.0}, "radius": 0.5,
+			}},
+		},
+	}
+	width, height := 200, 150
+
+	t.Run("normal", func(t *testing.T) {
+		img, err := RenderAOV(state, AOVNormal, width, height)
+		if err != nil {
+			t.Fatalf("RenderAOV failed: %v", err)
+		}
+
+		// Background is black
+		if got := img.At(0, 0); got != (color.RGBA{0, 0, 0, 255}) {
+			t.Errorf("Expected black background, got %v", got)
+		}
+
+		// The near sphere's center faces the camera, so its normal is close to +Z (blue)
+		camera := newSceneCamera(state.Camera, width, height)
+		px, py := camera.project(camera.toCamera(vec3{-0.8, 0, 1.5}))
+		r, g, b, _ := img.At(int(px), int(py)).RGBA()
+		if b>>8 < 200 || r>>8 > 160 || g>>8 > 160 {
+			t.Errorf("Expected mostly blue normal at sphere center, got r=%d g=%d b=%d", r>>8, g>>8, b>>8)
+		}
+	})
+
+	t.Run("depth", func(t *testing.T) {
+		img, err := RenderAOV(state, AOVDepth, width, height)
+		if err != nil {
+			t.Fatalf("RenderAOV failed: %v", err)
+		}
+
+		camera := newSceneCamera(state.Camera, width, height)
+		gray := func(p vec3) uint32 {
+			px, py := camera.project(camera.toCamera(p))
+			r, _, _, _ := img.At(int(px), int(py)).RGBA()
+			return r >> 8
+		}
+
+		near, far, background := gray(vec3{-0.8, 0, 1.5}), gray(vec3{0.8, 0, -0.5}), gray(vec3{0, 1.5, 0})
+		if near <= far {
+			t.Errorf("Expected near surface (%d) to be brighter than far surface (%d)", near, far)
+		}
+		if far == background || background != 0 {
+			t.Errorf("Expected black background distinct from far surface, got background=%d far=%d", background, far)
+		}
+	})
+
+	t.Run("beauty rejected", func(t *testing.T) {
+		if _, err := RenderAOV(state, AOVBeauty, width, height); err == nil {
+			t.Error("Expected RenderAOV to reject beauty")
+		}
+	})
+}
+
+func TestRenderSceneToolAOV(t *testing.T) {
+	events := make(chan AgentEvent, 100)
+	agent := NewWithProvider(events, &MockProvider{}, "mock-model")
+	agent.sceneManager = newRenderableSceneManager(t)
+
+	req := &RenderSceneRequest{
+		BaseToolRequest: BaseToolRequest{ToolType: "render_scene"},
+		AOV:             "depth",
+	}
+	result := agent.executeToolRequests(context.Background(), req, "test_call_1")
+	if !result.Success {
+		t.Fatalf("Expected depth render to succeed, got errors: %v", result.Errors)
+	}
+
+	metadata := result.Result.(map[string]interface{})
+	if metadata["aov"] != AOVDepth {
+		t.Errorf("Expected aov depth in metadata, got %v", metadata["aov"])
+	}
+	if _, err := png.Decode(bytes.NewReader(req.RenderedImage)); err != nil {
+		t.Errorf("Expected a PNG image, got decode error: %v", err)
+	}
+
+	// AOVs aren't available for wireframes
+	wireReq := &RenderSceneRequest{
+		BaseToolRequest: BaseToolRequest{ToolType: "render_scene"},
+		Mode:            "wireframe",
+		AOV:             "normal",
+	}
+	if result := agent.executeToolRequests(context.Background(), wireReq, "test_call_2"); result.Success {
+		t.Error("Expected aov with wireframe mode to fail")
+	}
+}
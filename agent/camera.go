@@ -0,0 +1,220 @@
+package agent
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// vec3 is a minimal vector type for the built-in wireframe and AOV renderers
+type vec3 [3]float64
+
+func (a vec3) add(b vec3) vec3      { return vec3{a[0] + b[0], a[1] + b[1], a[2] + b[2]} }
+func (a vec3) sub(b vec3) vec3      { return vec3{a[0] - b[0], a[1] - b[1], a[2] - b[2]} }
+func (a vec3) scale(s float64) vec3 { return vec3{a[0] * s, a[1] * s, a[2] * s} }
+func (a vec3) dot(b vec3) float64   { return a[0]*b[0] + a[1]*b[1] + a[2]*b[2] }
+func (a vec3) length() float64      { return math.Sqrt(a.dot(a)) }
+func (a vec3) cross(b vec3) vec3 {
+	return vec3{a[1]*b[2] - a[2]*b[1], a[2]*b[0] - a[0]*b[2], a[0]*b[1] - a[1]*b[0]}
+}
+
+func (a vec3) normalize() vec3 {
+	if l := a.length(); l > 0 {
+		return a.scale(1 / l)
+	}
+	return a
+}
+
+// rotateXYZ rotates a vector about the X, then Y, then Z axes (angles in radians)
+func (a vec3) rotateXYZ(r vec3) vec3 {
+	sx, cx := math.Sincos(r[0])
+	sy, cy := math.Sincos(r[1])
+	sz, cz := math.Sincos(r[2])
+
+	x, y, z := a[0], a[1]*cx-a[2]*sx, a[1]*sx+a[2]*cx
+	x, z = x*cy+z*sy, -x*sy+z*cy
+	x, y = x*cz-y*sz, x*sz+y*cz
+	return vec3{x, y, z}
+}
+
+// unrotateXYZ undoes rotateXYZ by rotating about Z, then Y, then X by the negated angles
+func (a vec3) unrotateXYZ(r vec3) vec3 {
+	sx, cx := math.Sincos(-r[0])
+	sy, cy := math.Sincos(-r[1])
+	sz, cz := math.Sincos(-r[2])
+
+	x, y, z := a[0]*cz-a[1]*sz, a[0]*sz+a[1]*cz, a[2]
+	x, z = x*cy+z*sy, -x*sy+z*cy
+	y, z = y*cx-z*sx, y*sx+z*cx
+	return vec3{x, y, z}
+}
+
+// vec3Property reads a 3-element property, returning def if it is missing or malformed
+func vec3Property(properties map[string]interface{}, key string, def vec3) vec3 {
+	if values, ok := extractFloatArray(properties, key, 3); ok {
+		return vec3{values[0], values[1], values[2]}
+	}
+	return def
+}
+
+// shapeBounds returns the axis-aligned bounding box of a shape
+// Curved shapes other than spheres are bounded by their wireframe outline, which is close enough for framing.
+// ok is false for shapes with no extent to measure.
+func shapeBounds(shape ShapeRequest) (min, max vec3, ok bool) {
+	if shape.Type == "sphere" {
+		center := vec3Property(shape.Properties, "center", vec3{})
+		radius, _ := extractFloat(shape.Properties, "radius")
+		r := vec3{radius, radius, radius}
+		return center.sub(r), center.add(r), true
+	}
+
+	edges := shapeEdges(shape)
+	if len(edges) == 0 {
+		return vec3{}, vec3{}, false
+	}
+	min, max = edges[0].a, edges[0].a
+	for _, e := range edges {
+		for _, p := range []vec3{e.a, e.b} {
+			for i := range p {
+				min[i] = math.Min(min[i], p[i])
+				max[i] = math.Max(max[i], p[i])
+			}
+		}
+	}
+	return min, max, true
+}
+
+// cameraPresets are the directions from the scene center toward the camera for each named viewpoint
+// The renderer's up vector is always +Y, so "top" is tilted very slightly toward +Z to stay well defined.
+var cameraPresets = map[string]vec3{
+	"front": {0, 0, 1},
+	"back":  {0, 0, -1},
+	"left":  {-1, 0, 0},
+	"right": {1, 0, 0},
+	"top":   {0, 1, 0.001},
+	"iso":   {1, 1, 1},
+}
+
+// presetFramingMargin leaves some space around the scene when framing it with a preset
+const presetFramingMargin = 1.1
+
+// defaultFrameMargin is the space frame_shape leaves around a shape, as a fraction of its size
+const defaultFrameMargin = 0.1
+
+// parseAspectRatio reads an aspect ratio written as "width:height" (e.g. "16:9") or as a
+// single number (e.g. "1.85"), returning width divided by height
+func parseAspectRatio(value string) (float64, error) {
+	invalid := fmt.Errorf("aspect_ratio must be 'width:height' (e.g. '16:9') or a positive number, got '%s'", value)
+	width, height, isPair := strings.Cut(strings.TrimSpace(value), ":")
+	w, err := strconv.ParseFloat(strings.TrimSpace(width), 64)
+	if err != nil {
+		return 0, invalid
+	}
+	h := 1.0
+	if isPair {
+		if h, err = strconv.ParseFloat(strings.TrimSpace(height), 64); err != nil {
+			return 0, invalid
+		}
+	}
+	ratio := w / h
+	if !(w > 0 && h > 0) || math.IsInf(ratio, 0) {
+		return 0, fmt.Errorf("aspect_ratio must be positive, got '%s'", value)
+	}
+	return ratio, nil
+}
+
+// cameraSensorHeight is the height of the imaginary sensor behind the lens, in scene units
+// It matches a full-frame 35mm camera (24mm tall) with scene units taken as meters.
+const cameraSensorHeight = 0.024
+
+// fstopToAperture converts an f-number to the raytracer's aperture, the lens diameter in scene units
+// The focal length is the one that gives a full-frame sensor the camera's vertical field of
+// view, and the aperture is the focal length divided by the f-number. Lower f-numbers give a
+// wider aperture and shallower depth of field.
+func fstopToAperture(fstop, vfov float64) float64 {
+	focalLength := cameraSensorHeight / 2 / math.Tan(vfov*math.Pi/360)
+	return focalLength / fstop
+}
+
+// CameraDetails is the scene camera plus values derived from it, returned by get_camera
+type CameraDetails struct {
+	CameraInfo
+	ViewDirection []float64 `json:"view_direction"` // Unit vector from center toward look_at
+	Distance      float64   `json:"distance"`       // Distance from center to look_at
+	FocusDistance float64   `json:"focus_distance"` // Distance at which the image is sharpest
+}
+
+// deriveCameraDetails computes the view direction, look_at distance, and focus distance of a camera
+func deriveCameraDetails(camera CameraInfo) CameraDetails {
+	center := vec3{camera.Center[0], camera.Center[1], camera.Center[2]}
+	lookAt := vec3{camera.LookAt[0], camera.LookAt[1], camera.LookAt[2]}
+	offset := lookAt.sub(center)
+	direction := offset.normalize()
+
+	return CameraDetails{
+		CameraInfo:    camera,
+		ViewDirection: []float64{direction[0], direction[1], direction[2]},
+		Distance:      offset.length(),
+		// The raytracer focuses on look_at when no focus distance is set, which is always the case for now
+		FocusDistance: offset.length(),
+	}
+}
+
+// sceneCamera is a pinhole camera matching the scene camera, used by the renderers that
+// don't go through the raytracer. It projects points for wireframes and generates
+// primary rays for AOVs.
+type sceneCamera struct {
+	origin      vec3
+	u, v, w     vec3 // Right, up, and backward camera axes
+	halfHeight  float64
+	aspectRatio float64
+	width       int
+	height      int
+}
+
+// newSceneCamera builds a sceneCamera for the given image size
+func newSceneCamera(camera CameraInfo, width, height int) sceneCamera {
+	origin := vec3{camera.Center[0], camera.Center[1], camera.Center[2]}
+	lookAt := vec3{camera.LookAt[0], camera.LookAt[1], camera.LookAt[2]}
+
+	w := origin.sub(lookAt).normalize()
+	u := vec3{0, 1, 0}.cross(w).normalize()
+	if u.length() == 0 {
+		// Looking straight up or down - pick any perpendicular right axis
+		u = vec3{1, 0, 0}
+	}
+	v := w.cross(u)
+
+	return sceneCamera{
+		origin:      origin,
+		u:           u,
+		v:           v,
+		w:           w,
+		halfHeight:  math.Tan(camera.VFov * math.Pi / 360),
+		aspectRatio: float64(width) / float64(height),
+		width:       width,
+		height:      height,
+	}
+}
+
+// toCamera converts a world-space point to camera space (z is distance in front of the camera)
+func (c sceneCamera) toCamera(p vec3) vec3 {
+	d := p.sub(c.origin)
+	return vec3{d.dot(c.u), d.dot(c.v), -d.dot(c.w)}
+}
+
+// project maps a camera-space point in front of the camera to pixel coordinates
+func (c sceneCamera) project(p vec3) (float64, float64) {
+	x := p[0] / (p[2] * c.halfHeight * c.aspectRatio)
+	y := p[1] / (p[2] * c.halfHeight)
+	return (x + 1) / 2 * float64(c.width), (1 - y) / 2 * float64(c.height)
+}
+
+// ray returns the normalized direction of the primary ray through pixel (px, py)
+// Pixel coordinates may be fractional; (0.5, 0.5) is the center of the top-left pixel.
+func (c sceneCamera) ray(px, py float64) vec3 {
+	x := (2*px/float64(c.width) - 1) * c.halfHeight * c.aspectRatio
+	y := (1 - 2*py/float64(c.height)) * c.halfHeight
+	return c.u.scale(x).add(c.v.scale(y)).sub(c.w).normalize()
+}
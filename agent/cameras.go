@@ -0,0 +1,120 @@
+package agent
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// mainCameraID names the active camera of a scene that has never switched cameras
+const mainCameraID = "main"
+
+// ActiveCameraID returns the name of the camera that renders use
+func (sm *SceneManager) ActiveCameraID() string {
+	if sm.state.ActiveCamera == "" {
+		return mainCameraID
+	}
+	return sm.state.ActiveCamera
+}
+
+// CameraIDs returns the names of every camera in the scene, including the active one, sorted
+func (sm *SceneManager) CameraIDs() []string {
+	ids := []string{sm.ActiveCameraID()}
+	for id := range sm.state.Cameras {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// CameraByID returns a copy of a named camera, which may be the active one
+func (sm *SceneManager) CameraByID(id string) (CameraInfo, bool) {
+	if id == sm.ActiveCameraID() {
+		return sm.GetCamera(), true
+	}
+	camera, ok := sm.state.Cameras[id]
+	if !ok {
+		return CameraInfo{}, false
+	}
+	return copyCameras(map[string]CameraInfo{id: camera})[id], true
+}
+
+// AddCamera adds a named camera without making it active
+// The camera is validated like SetCamera, and the name must not already be in use.
+func (sm *SceneManager) AddCamera(id string, camera CameraInfo) error {
+	if id == "" {
+		return fmt.Errorf("camera id is required")
+	}
+	if sm.hasCamera(id) {
+		return fmt.Errorf("camera '%s' already exists - use select_camera and set_camera to change it", id)
+	}
+	camera, err := sm.validateCamera(camera)
+	if err != nil {
+		return err
+	}
+
+	if sm.state.Cameras == nil {
+		sm.state.Cameras = make(map[string]CameraInfo)
+	}
+	sm.state.Cameras[id] = camera
+	sm.revisions.cameraChanged()
+	return nil
+}
+
+// SelectCamera makes a named camera the active one, which renders and the camera tools use
+// The previously active camera is kept under its name, so it can be selected again.
+func (sm *SceneManager) SelectCamera(id string) error {
+	active := sm.ActiveCameraID()
+	if id == active {
+		return nil
+	}
+	camera, ok := sm.state.Cameras[id]
+	if !ok {
+		return sm.cameraNotFound(id)
+	}
+
+	delete(sm.state.Cameras, id)
+	sm.state.Cameras[active] = sm.state.Camera
+	sm.state.Camera = camera
+	sm.state.ActiveCamera = id
+	sm.revisions.cameraChanged()
+	return nil
+}
+
+// RemoveCamera removes a named camera, which must not be the active one
+func (sm *SceneManager) RemoveCamera(id string) error {
+	if id == sm.ActiveCameraID() {
+		return fmt.Errorf("camera '%s' is active and can't be removed - select another camera first", id)
+	}
+	if _, ok := sm.state.Cameras[id]; !ok {
+		return sm.cameraNotFound(id)
+	}
+	delete(sm.state.Cameras, id)
+	sm.revisions.cameraChanged()
+	return nil
+}
+
+// hasCamera reports whether a camera name is in use, including by the active camera
+func (sm *SceneManager) hasCamera(id string) bool {
+	_, ok := sm.state.Cameras[id]
+	return ok || id == sm.ActiveCameraID()
+}
+
+// cameraNotFound reports an unknown camera name along with the names that exist
+func (sm *SceneManager) cameraNotFound(id string) error {
+	return fmt.Errorf("camera '%s' not found (cameras: %s)", id, strings.Join(sm.CameraIDs(), ", "))
+}
+
+// copyCameras returns a copy of a camera map that shares no slices with it
+func copyCameras(cameras map[string]CameraInfo) map[string]CameraInfo {
+	if cameras == nil {
+		return nil
+	}
+	result := make(map[string]CameraInfo, len(cameras))
+	for id, camera := range cameras {
+		camera.Center = append([]float64(nil), camera.Center...)
+		camera.LookAt = append([]float64(nil), camera.LookAt...)
+		result[id] = camera
+	}
+	return result
+}
@@ -0,0 +1,153 @@
+package agent
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/df07/scene-llm/agent/llm"
+)
+
+func TestMultipleCameras(t *testing.T) {
+	sm := newRenderableSceneManager(t)
+	if sm.ActiveCameraID() != mainCameraID || !reflect.DeepEqual(sm.CameraIDs(), []string{"main"}) {
+		t.Fatalf("Expected a new scene to have just the main camera, got %q of %v", sm.ActiveCameraID(), sm.CameraIDs())
+	}
+
+	overhead := CameraInfo{Center: []float64{0, 10, 0.1}, LookDirection: []float64{0, -10, -0.1}, VFov: 30}
+	if err := sm.AddCamera("overhead", overhead); err != nil {
+		t.Fatalf("AddCamera() failed: %v", err)
+	}
+	if sm.ActiveCameraID() != "main" || sm.GetCamera().Center[2] != 5 {
+		t.Errorf("Expected adding a camera to leave main active, got %q at %v", sm.ActiveCameraID(), sm.GetCamera().Center)
+	}
+	if stored, _ := sm.CameraByID("overhead"); !reflect.DeepEqual(stored.LookAt, []float64{0, 0, 0}) || stored.LookDirection != nil {
+		t.Errorf("Expected the camera to be stored like SetCamera would, got %+v", stored)
+	}
+
+	// Each camera renders from its own position
+	cameraZ := func() float64 {
+		t.Helper()
+		raytracerScene, err := sm.ToRaytracerScene()
+		if err != nil {
+			t.Fatalf("ToRaytracerScene() failed: %v", err)
+		}
+		settings := GetRenderSettings(QualityPreview)
+		if _, err := RenderImage(context.Background(), raytracerScene, settings); err != nil {
+			t.Fatalf("RenderImage() failed: %v", err)
+		}
+		return raytracerScene.CameraConfig.Center.Z
+	}
+	if z := cameraZ(); z != 5 {
+		t.Errorf("Expected the main camera to render, got camera z %g", z)
+	}
+
+	if err := sm.SelectCamera("overhead"); err != nil {
+		t.Fatalf("SelectCamera() failed: %v", err)
+	}
+	if z := cameraZ(); z != 0.1 {
+		t.Errorf("Expected the overhead camera to render, got camera z %g", z)
+	}
+
+	// Camera tools change the active camera, and the other keeps its settings
+	if err := sm.DollyCamera(2); err != nil {
+		t.Fatalf("DollyCamera() failed: %v", err)
+	}
+	if err := sm.SelectCamera("main"); err != nil {
+		t.Fatalf("SelectCamera() failed: %v", err)
+	}
+	if z := cameraZ(); z != 5 {
+		t.Errorf("Expected main to be unchanged, got camera z %g", z)
+	}
+	if moved, _ := sm.CameraByID("overhead"); moved.Center[1] != 20 {
+		t.Errorf("Expected the overhead camera to keep its dolly, got %v", moved.Center)
+	}
+
+	// Cameras travel with the scene state
+	branch := NewSceneManager()
+	branch.ReplaceState(sm.Snapshot())
+	if !reflect.DeepEqual(branch.CameraIDs(), []string{"main", "overhead"}) {
+		t.Errorf("Expected both cameras to round-trip, got %v", branch.CameraIDs())
+	}
+
+	if err := sm.RemoveCamera("main"); err == nil {
+		t.Error("Expected removing the active camera to fail")
+	}
+	if err := sm.RemoveCamera("overhead"); err != nil {
+		t.Fatalf("RemoveCamera() failed: %v", err)
+	}
+	if !reflect.DeepEqual(sm.CameraIDs(), []string{"main"}) {
+		t.Errorf("Expected only main to remain, got %v", sm.CameraIDs())
+	}
+}
+
+func TestAddCameraValidation(t *testing.T) {
+	sm := NewSceneManager()
+	valid := CameraInfo{Center: []float64{0, 1, 5}, LookAt: []float64{0, 0, 0}, VFov: 45}
+
+	tests := []struct {
+		name     string
+		id       string
+		camera   CameraInfo
+		expected string
+	}{
+		{"missing id", "", valid, "id is required"},
+		{"taken by the active camera", "main", valid, "already exists"},
+		{"center equals look_at", "side", CameraInfo{Center: []float64{0, 0, 0}, LookAt: []float64{0, 0, 0}, VFov: 45}, "look_at"},
+		{"bad vfov", "side", CameraInfo{Center: []float64{0, 1, 5}, LookAt: []float64{0, 0, 0}, VFov: 200}, "vfov"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := sm.AddCamera(tt.id, tt.camera)
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("Expected an error containing %q, got %v", tt.expected, err)
+			}
+		})
+	}
+	if len(sm.CameraIDs()) != 1 {
+		t.Errorf("Expected no cameras to be added, got %v", sm.CameraIDs())
+	}
+
+	if err := sm.SelectCamera("nowhere"); err == nil || !strings.Contains(err.Error(), "cameras: main") {
+		t.Errorf("Expected an unknown camera to list the cameras, got %v", err)
+	}
+}
+
+func TestCameraTools(t *testing.T) {
+	events := make(chan AgentEvent, 100)
+	agent := NewWithProvider(events, &MockProvider{}, "mock-model")
+	agent.sceneManager = newRenderableSceneManager(t)
+
+	call := func(name string, args map[string]interface{}) ToolResult {
+		req := parseToolRequestFromFunctionCall(&llm.FunctionCall{Name: name, Arguments: args})
+		return agent.executeToolRequests(context.Background(), req, "test_call")
+	}
+
+	result := call("add_camera", map[string]interface{}{
+		"id": "side", "center": []interface{}{8.0, 1.0, 0.0}, "look_direction": []interface{}{-1.0, 0.0, 0.0},
+	})
+	if !result.Success {
+		t.Fatalf("Expected add_camera to succeed, got errors: %v", result.Errors)
+	}
+	camera := result.Result.(map[string]interface{})["camera"].(CameraInfo)
+	if camera.LookAt[0] != 7 || camera.VFov != 45 {
+		t.Errorf("Expected look_direction converted and the default vfov, got %+v", camera)
+	}
+
+	if result := call("select_camera", map[string]interface{}{"id": "side"}); !result.Success {
+		t.Fatalf("Expected select_camera to succeed, got errors: %v", result.Errors)
+	}
+	if result := call("render_scene", map[string]interface{}{}); !result.Success {
+		t.Errorf("Expected the side camera to render, got errors: %v", result.Errors)
+	}
+	if result := call("remove_camera", map[string]interface{}{"id": "side"}); result.Success {
+		t.Error("Expected remove_camera on the active camera to fail")
+	}
+	if result := call("remove_camera", map[string]interface{}{"id": "main"}); !result.Success {
+		t.Errorf("Expected remove_camera to succeed, got errors: %v", result.Errors)
+	}
+	if state := agent.sceneManager.GetSceneState(); state["active_camera"] != "side" {
+		t.Errorf("Expected get_scene_state to report the active camera, got %v", state["active_camera"])
+	}
+}
@@ -0,0 +1,97 @@
+package agent
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxCheckpoints bounds how many labeled checkpoints a scene keeps, since each is a full copy
+const maxCheckpoints = 50
+
+// sceneCheckpoint is a labeled copy of the scene state
+type sceneCheckpoint struct {
+	state   *SceneState
+	created time.Time
+	seq     int // Orders checkpoints by when they were saved
+}
+
+// CheckpointInfo describes a saved checkpoint without its state
+type CheckpointInfo struct {
+	Label      string    `json:"label"`
+	ShapeCount int       `json:"shape_count"`
+	LightCount int       `json:"light_count"`
+	Created    time.Time `json:"created"`
+}
+
+// Checkpoint saves a deep copy of the scene under a label
+// A label that is already in use is only replaced when overwrite is true, so a checkpoint
+// can't be lost by accident. Checkpoints belong to the scene manager rather than the scene
+// state: restoring one keeps all the others.
+func (sm *SceneManager) Checkpoint(label string, overwrite bool) (CheckpointInfo, error) {
+	label = strings.TrimSpace(label)
+	if label == "" {
+		return CheckpointInfo{}, fmt.Errorf("checkpoint label is required")
+	}
+	if _, exists := sm.checkpoints[label]; exists && !overwrite {
+		return CheckpointInfo{}, fmt.Errorf("checkpoint '%s' already exists - set overwrite to true to replace it", label)
+	} else if !exists && len(sm.checkpoints) >= maxCheckpoints {
+		return CheckpointInfo{}, fmt.Errorf("the scene already has %d checkpoints - overwrite one instead", maxCheckpoints)
+	}
+
+	if sm.checkpoints == nil {
+		sm.checkpoints = make(map[string]sceneCheckpoint)
+	}
+	sm.checkpointSeq++
+	checkpoint := sceneCheckpoint{state: sm.Snapshot(), created: time.Now(), seq: sm.checkpointSeq}
+	sm.checkpoints[label] = checkpoint
+	return checkpoint.info(label), nil
+}
+
+// RestoreCheckpoint replaces the scene with a copy of a saved checkpoint
+// The checkpoint itself is kept, so it can be restored again after further edits.
+func (sm *SceneManager) RestoreCheckpoint(label string) (CheckpointInfo, error) {
+	label = strings.TrimSpace(label)
+	checkpoint, ok := sm.checkpoints[label]
+	if !ok {
+		if len(sm.checkpoints) == 0 {
+			return CheckpointInfo{}, fmt.Errorf("checkpoint '%s' not found - no checkpoints have been saved", label)
+		}
+		labels := make([]string, 0, len(sm.checkpoints))
+		for _, info := range sm.Checkpoints() {
+			labels = append(labels, info.Label)
+		}
+		return CheckpointInfo{}, fmt.Errorf("checkpoint '%s' not found (checkpoints: %s)", label, strings.Join(labels, ", "))
+	}
+
+	sm.ReplaceState(checkpoint.state)
+	return checkpoint.info(label), nil
+}
+
+// Checkpoints returns the saved checkpoints, oldest first
+func (sm *SceneManager) Checkpoints() []CheckpointInfo {
+	labels := make([]string, 0, len(sm.checkpoints))
+	for label := range sm.checkpoints {
+		labels = append(labels, label)
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		return sm.checkpoints[labels[i]].seq < sm.checkpoints[labels[j]].seq
+	})
+
+	infos := make([]CheckpointInfo, 0, len(labels))
+	for _, label := range labels {
+		infos = append(infos, sm.checkpoints[label].info(label))
+	}
+	return infos
+}
+
+// info summarizes a checkpoint saved under label
+func (c sceneCheckpoint) info(label string) CheckpointInfo {
+	return CheckpointInfo{
+		Label:      label,
+		ShapeCount: len(c.state.Shapes),
+		LightCount: len(c.state.Lights),
+		Created:    c.created,
+	}
+}
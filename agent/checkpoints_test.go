@@ -0,0 +1,109 @@
+package agent
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/df07/scene-llm/agent/llm"
+)
+
+func TestCheckpoints(t *testing.T) {
+	sm := newRenderableSceneManager(t)
+	if _, err := sm.Checkpoint("  ", false); err == nil {
+		t.Error("Expected a blank label to be rejected")
+	}
+	if _, err := sm.Checkpoint("one ball", false); err != nil {
+		t.Fatalf("Checkpoint() failed: %v", err)
+	}
+
+	// Later edits, including to nested properties, don't reach the checkpoint
+	shapeCount := len(sm.state.Shapes)
+	center := sm.state.Shapes[0].Properties["center"]
+	if err := sm.UpdateShape(sm.state.Shapes[0].ID, map[string]interface{}{"center": []interface{}{3.0, 0.0, 0.0}}); err != nil {
+		t.Fatalf("UpdateShape() failed: %v", err)
+	}
+	if err := sm.AddShapes([]ShapeRequest{{ID: "extra", Type: "sphere", Properties: map[string]interface{}{
+		"center": []interface{}{0.0, 2.0, 0.0},
+		"radius": 0.5,
+	}}}); err != nil {
+		t.Fatalf("AddShapes() failed: %v", err)
+	}
+
+	if _, err := sm.Checkpoint("one ball", false); err == nil || !strings.Contains(err.Error(), "overwrite") {
+		t.Errorf("Expected a duplicate label to need overwrite, got %v", err)
+	}
+	if _, err := sm.Checkpoint("two balls", false); err != nil {
+		t.Fatalf("Checkpoint() failed: %v", err)
+	}
+
+	info, err := sm.RestoreCheckpoint("one ball")
+	if err != nil {
+		t.Fatalf("RestoreCheckpoint() failed: %v", err)
+	}
+	if info.ShapeCount != shapeCount || len(sm.state.Shapes) != shapeCount || sm.FindShape("extra") != nil {
+		t.Errorf("Expected the first checkpoint's %d shapes back, got %d", shapeCount, len(sm.state.Shapes))
+	}
+	if got := sm.state.Shapes[0].Properties["center"]; !reflect.DeepEqual(got, center) {
+		t.Errorf("Expected the original center %v back, got %v", center, got)
+	}
+
+	// Restoring copies, so editing the restored scene leaves the checkpoint intact
+	if err := sm.RemoveShape(sm.state.Shapes[0].ID); err != nil {
+		t.Fatalf("RemoveShape() failed: %v", err)
+	}
+	if info, _ := sm.RestoreCheckpoint("one ball"); info.ShapeCount != shapeCount || len(sm.state.Shapes) != shapeCount {
+		t.Errorf("Expected the checkpoint to survive edits after restoring, got %d shapes", len(sm.state.Shapes))
+	}
+
+	checkpoints := sm.Checkpoints()
+	if len(checkpoints) != 2 || checkpoints[0].Label != "one ball" || checkpoints[1].Label != "two balls" {
+		t.Errorf("Expected both checkpoints oldest first, got %+v", checkpoints)
+	}
+	if _, err := sm.RestoreCheckpoint("three balls"); err == nil || !strings.Contains(err.Error(), "one ball, two balls") {
+		t.Errorf("Expected an unknown checkpoint to list the saved ones, got %v", err)
+	}
+
+	// Overwriting moves a checkpoint to the end
+	if _, err := sm.Checkpoint("one ball", true); err != nil {
+		t.Fatalf("Checkpoint() with overwrite failed: %v", err)
+	}
+	if checkpoints := sm.Checkpoints(); checkpoints[1].Label != "one ball" {
+		t.Errorf("Expected the overwritten checkpoint to be newest, got %+v", checkpoints)
+	}
+}
+
+func TestCheckpointTools(t *testing.T) {
+	events := make(chan AgentEvent, 100)
+	agent := NewWithProvider(events, &MockProvider{}, "mock-model")
+	agent.sceneManager = newRenderableSceneManager(t)
+	call := func(name string, args map[string]interface{}) ToolResult {
+		req := parseToolRequestFromFunctionCall(&llm.FunctionCall{Name: name, Arguments: args})
+		return agent.executeToolRequests(context.Background(), req, "test_call")
+	}
+
+	if result := call("restore_checkpoint", map[string]interface{}{"label": "start"}); result.Success {
+		t.Error("Expected restoring before any checkpoint to fail")
+	}
+	if result := call("checkpoint_scene", map[string]interface{}{"label": "start"}); !result.Success {
+		t.Fatalf("Expected checkpoint_scene to succeed, got errors: %v", result.Errors)
+	}
+	if result := call("remove_shape", map[string]interface{}{"id": "sphere"}); !result.Success {
+		t.Fatalf("Expected remove_shape to succeed, got errors: %v", result.Errors)
+	}
+
+	result := call("restore_checkpoint", map[string]interface{}{"label": "start"})
+	if !result.Success {
+		t.Fatalf("Expected restore_checkpoint to succeed, got errors: %v", result.Errors)
+	}
+	restored := result.Result.(map[string]interface{})["restored"].(CheckpointInfo)
+	if restored.ShapeCount != 1 || agent.sceneManager.FindShape("sphere") == nil {
+		t.Errorf("Expected the removed sphere back, got %+v", restored)
+	}
+
+	result = call("list_checkpoints", map[string]interface{}{})
+	if checkpoints := result.Result.(map[string]interface{})["checkpoints"].([]CheckpointInfo); len(checkpoints) != 1 || checkpoints[0].Label != "start" {
+		t.Errorf("Expected the one checkpoint to be listed, got %+v", checkpoints)
+	}
+}
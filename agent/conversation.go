@@ -0,0 +1,143 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/df07/scene-llm/agent/llm"
+)
+
+// maxConversationTokens bounds the conversation history sent to the provider
+// It leaves room in typical context windows for the system prompt, tool declarations, and the response.
+const maxConversationTokens = 100000
+
+const (
+	charsPerToken = 4    // Rough average for English text and JSON
+	imageTokens   = 1500 // Rough cost of one rendered image
+)
+
+// estimateTokens roughly estimates how many tokens a message costs
+// It only needs to be accurate enough to keep the history well inside the context window.
+func estimateTokens(msg llm.Message) int {
+	chars := 0
+	tokens := 0
+	for _, part := range msg.Parts {
+		chars += len(part.Text)
+		if part.FunctionCall != nil {
+			chars += len(part.FunctionCall.Name) + jsonLength(part.FunctionCall.Arguments)
+		}
+		if part.FunctionResp != nil {
+			chars += len(part.FunctionResp.Name) + jsonLength(part.FunctionResp.Response)
+		}
+		if part.ImageData != nil {
+			tokens += imageTokens
+		}
+	}
+	return tokens + chars/charsPerToken + 1
+}
+
+// jsonLength returns the length of a value's JSON encoding, or 0 if it can't be encoded
+func jsonLength(value interface{}) int {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return 0
+	}
+	return len(data)
+}
+
+// startsTurn reports whether a message is a new user turn rather than tool results sent back to the model
+func startsTurn(msg llm.Message) bool {
+	if msg.Role != llm.RoleUser {
+		return false
+	}
+	hasText := false
+	for _, part := range msg.Parts {
+		if part.FunctionResp != nil {
+			return false
+		}
+		if part.Type == llm.PartTypeText {
+			hasText = true
+		}
+	}
+	return hasText
+}
+
+// trimConversation drops the oldest turns until the history fits in maxTokensApprox
+// A turn is a user message plus every assistant reply and tool result up to the next user message.
+// Dropping whole turns keeps function calls with their responses and keeps the history starting
+// with a user message. The most recent turn is always kept, even if it alone exceeds the budget.
+// When turns are dropped, a note is prepended to the first remaining user message. The scene itself
+// is never lost, since the system prompt describes the current scene state.
+// messages is not modified.
+func trimConversation(messages []llm.Message, maxTokensApprox int) []llm.Message {
+	total := 0
+	for _, msg := range messages {
+		total += estimateTokens(msg)
+	}
+	if total <= maxTokensApprox {
+		return messages
+	}
+
+	// Find where each turn starts
+	var turnStarts []int
+	for i, msg := range messages {
+		if startsTurn(msg) {
+			turnStarts = append(turnStarts, i)
+		}
+	}
+	if len(turnStarts) == 0 {
+		return messages // No user message to anchor on
+	}
+
+	// Keep the latest turn, then add earlier turns while they fit
+	keepFrom := turnStarts[len(turnStarts)-1]
+	used := 0
+	for _, msg := range messages[keepFrom:] {
+		used += estimateTokens(msg)
+	}
+	for t := len(turnStarts) - 2; t >= 0; t-- {
+		turnTokens := 0
+		for _, msg := range messages[turnStarts[t]:turnStarts[t+1]] {
+			turnTokens += estimateTokens(msg)
+		}
+		if used+turnTokens > maxTokensApprox {
+			break
+		}
+		used += turnTokens
+		keepFrom = turnStarts[t]
+	}
+
+	if keepFrom == 0 {
+		return messages
+	}
+
+	trimmed := make([]llm.Message, 0, len(messages)-keepFrom)
+	first := messages[keepFrom]
+	note := llm.Part{
+		Type: llm.PartTypeText,
+		Text: fmt.Sprintf("[Earlier conversation trimmed: %d older messages were omitted to fit the context window. The current scene state is in the system prompt.]", keepFrom),
+	}
+	trimmed = append(trimmed, llm.Message{
+		Role:  first.Role,
+		Parts: append([]llm.Part{note}, first.Parts...),
+	})
+	return append(trimmed, messages[keepFrom+1:]...)
+}
+
+// withoutImages returns the messages with image parts removed, for providers that can't accept them
+// Messages left with no parts are dropped. messages is not modified.
+func withoutImages(messages []llm.Message) []llm.Message {
+	result := make([]llm.Message, 0, len(messages))
+	for _, msg := range messages {
+		parts := make([]llm.Part, 0, len(msg.Parts))
+		for _, part := range msg.Parts {
+			if part.Type != llm.PartTypeImage {
+				parts = append(parts, part)
+			}
+		}
+		if len(parts) > 0 {
+			result = append(result, llm.Message{Role: msg.Role, Parts: parts})
+		}
+	}
+	return result
+}
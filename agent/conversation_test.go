@@ -0,0 +1,146 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/df07/scene-llm/agent/llm"
+)
+
+// buildConversation creates turns of user text, an assistant tool call, its response, and an assistant reply
+func buildConversation(turns int, padding int) []llm.Message {
+	var messages []llm.Message
+	for i := 0; i < turns; i++ {
+		callID := fmt.Sprintf("call_%d", i)
+		messages = append(messages,
+			llm.Message{Role: llm.RoleUser, Parts: []llm.Part{{Type: llm.PartTypeText, Text: fmt.Sprintf("request %d %s", i, strings.Repeat("x", padding))}}},
+			llm.Message{Role: llm.RoleAssistant, Parts: []llm.Part{{
+				Type:         llm.PartTypeFunctionCall,
+				FunctionCall: &llm.FunctionCall{ID: callID, Name: "create_shape", Arguments: map[string]interface{}{"id": callID}},
+			}}},
+			llm.Message{Role: llm.RoleUser, Parts: []llm.Part{{
+				Type:         llm.PartTypeFunctionResponse,
+				FunctionResp: &llm.FunctionResponse{ID: callID, Name: "create_shape", Response: map[string]interface{}{"success": true}},
+			}}},
+			llm.Message{Role: llm.RoleAssistant, Parts: []llm.Part{{Type: llm.PartTypeText, Text: fmt.Sprintf("done %d", i)}}},
+		)
+	}
+	return messages
+}
+
+// checkConversationInvariants verifies a history is valid to send to a provider
+func checkConversationInvariants(t *testing.T, messages []llm.Message) {
+	t.Helper()
+	if len(messages) == 0 {
+		t.Fatal("Expected a non-empty conversation")
+	}
+	if !startsTurn(messages[0]) {
+		t.Errorf("Expected conversation to start with a user message, got %+v", messages[0])
+	}
+
+	calls := make(map[string]bool)
+	responses := make(map[string]bool)
+	for _, msg := range messages {
+		for _, part := range msg.Parts {
+			if part.FunctionCall != nil {
+				calls[part.FunctionCall.ID] = true
+			}
+			if part.FunctionResp != nil {
+				if !calls[part.FunctionResp.ID] {
+					t.Errorf("Function response %s has no preceding call", part.FunctionResp.ID)
+				}
+				responses[part.FunctionResp.ID] = true
+			}
+		}
+	}
+	for id := range calls {
+		if !responses[id] {
+			t.Errorf("Function call %s has no response", id)
+		}
+	}
+}
+
+func TestTrimConversationUnderBudget(t *testing.T) {
+	messages := buildConversation(3, 10)
+	trimmed := trimConversation(messages, maxConversationTokens)
+	if len(trimmed) != len(messages) {
+		t.Errorf("Expected history under budget to be unchanged, got %d of %d messages", len(trimmed), len(messages))
+	}
+}
+
+func TestTrimConversationDropsOldestTurns(t *testing.T) {
+	messages := buildConversation(20, 400) // Roughly 130 tokens per turn
+	original := len(messages[0].Parts)
+
+	trimmed := trimConversation(messages, 1000)
+
+	if len(trimmed) >= len(messages) {
+		t.Fatalf("Expected history to be trimmed, got %d of %d messages", len(trimmed), len(messages))
+	}
+	if len(trimmed)%4 != 0 {
+		t.Errorf("Expected whole turns to be kept, got %d messages", len(trimmed))
+	}
+	checkConversationInvariants(t, trimmed)
+
+	// The most recent turn is kept
+	last := trimmed[len(trimmed)-4].Parts
+	if !strings.HasPrefix(last[len(last)-1].Text, "request 19") {
+		t.Errorf("Expected the most recent user message to be kept, got %q", last[len(last)-1].Text)
+	}
+
+	// A note explains the omission
+	if !strings.Contains(trimmed[0].Parts[0].Text, "Earlier conversation trimmed") {
+		t.Errorf("Expected a trim note on the first message, got %q", trimmed[0].Parts[0].Text)
+	}
+
+	// Fits the budget
+	total := 0
+	for _, msg := range trimmed {
+		total += estimateTokens(msg)
+	}
+	if total > 1000+estimateTokens(trimmed[0]) {
+		t.Errorf("Expected trimmed history near the budget, got %d tokens", total)
+	}
+
+	// The input is not modified
+	if len(messages[0].Parts) != original || len(messages) != 80 {
+		t.Error("Expected the original conversation to be left unchanged")
+	}
+}
+
+func TestTrimConversationKeepsLatestTurnOverBudget(t *testing.T) {
+	messages := buildConversation(3, 10)
+	// The latest turn is mid tool use, with a rendered image that alone exceeds the budget
+	messages = messages[:len(messages)-1]
+	response := &messages[len(messages)-1]
+	response.Parts = append(response.Parts, llm.Part{Type: llm.PartTypeImage, ImageData: &llm.ImageData{Data: []byte{1}, MIMEType: "image/png"}})
+
+	trimmed := trimConversation(messages, 100)
+
+	if len(trimmed) != 3 {
+		t.Fatalf("Expected only the latest turn to remain, got %d messages", len(trimmed))
+	}
+	checkConversationInvariants(t, trimmed)
+}
+
+func TestTrimConversationKeepsParallelCallsTogether(t *testing.T) {
+	messages := buildConversation(10, 400)
+
+	// A turn where the model made two calls at once and got both responses back
+	messages = append(messages,
+		llm.Message{Role: llm.RoleUser, Parts: []llm.Part{{Type: llm.PartTypeText, Text: "make two spheres"}}},
+		llm.Message{Role: llm.RoleAssistant, Parts: []llm.Part{
+			{Type: llm.PartTypeFunctionCall, FunctionCall: &llm.FunctionCall{ID: "a", Name: "create_shape"}},
+			{Type: llm.PartTypeFunctionCall, FunctionCall: &llm.FunctionCall{ID: "b", Name: "create_shape"}},
+		}},
+		llm.Message{Role: llm.RoleUser, Parts: []llm.Part{
+			{Type: llm.PartTypeFunctionResponse, FunctionResp: &llm.FunctionResponse{ID: "a", Name: "create_shape"}},
+			{Type: llm.PartTypeFunctionResponse, FunctionResp: &llm.FunctionResponse{ID: "b", Name: "create_shape"}},
+		}},
+	)
+
+	for _, budget := range []int{50, 300, 600, 1000} {
+		checkConversationInvariants(t, trimConversation(messages, budget))
+	}
+}
@@ -0,0 +1,48 @@
+package agent
+
+import "fmt"
+
+// SetDefaultMaterial sets the material given to shapes that have neither a material nor a color
+// Presets are expanded and a random albedo is resolved once, so every such shape shares one
+// color. A nil or empty material restores the gray lambertian default. The default is part of
+// the scene state, so it is kept by Snapshot and ReplaceState.
+func (sm *SceneManager) SetDefaultMaterial(mat map[string]interface{}) error {
+	if len(mat) == 0 {
+		sm.state.DefaultMaterial = nil
+		return nil
+	}
+
+	mat = expandMaterialPreset(mat)
+	if mat["type"] == shadowCatcherMaterial {
+		return fmt.Errorf("the default material can't be a shadow_catcher, which only applies to quads")
+	}
+	var errors ValidationErrors
+	validateMaterial(&errors, resolveRandomAlbedo(mat, placeholderRandomColor, 0), "default")
+	if len(errors) > 0 {
+		return errors
+	}
+
+	sm.state.DefaultMaterial = resolveRandomAlbedo(mat, sm.nextRandomColor, 0)
+	return nil
+}
+
+// DefaultMaterial returns a copy of the scene's default material, or nil for gray lambertian
+func (sm *SceneManager) DefaultMaterial() map[string]interface{} {
+	return deepCopyProperties(sm.state.DefaultMaterial)
+}
+
+// withDefaultMaterial returns properties with the scene's default material filled in
+// The default applies only to shapes with neither a material nor a color; properties is
+// returned as is otherwise, and copied rather than modified when the default is added.
+func (sm *SceneManager) withDefaultMaterial(properties map[string]interface{}) map[string]interface{} {
+	if sm.state.DefaultMaterial == nil || hasProperty(properties, "material") || hasProperty(properties, "color") {
+		return properties
+	}
+
+	withDefault := make(map[string]interface{}, len(properties)+1)
+	for key, value := range properties {
+		withDefault[key] = value
+	}
+	withDefault["material"] = deepCopyProperties(sm.state.DefaultMaterial)
+	return withDefault
+}
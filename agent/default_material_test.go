@@ -0,0 +1,104 @@
+package agent
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/df07/go-progressive-raytracer/pkg/material"
+	"github.com/df07/scene-llm/agent/llm"
+)
+
+func TestDefaultMaterial(t *testing.T) {
+	sm := NewSceneManager()
+	err := sm.AddShapes([]ShapeRequest{
+		{ID: "plain", Type: "sphere", Properties: map[string]interface{}{
+			"center": []interface{}{0.0, 1.0, 0.0}, "radius": 1.0}},
+		{ID: "colored", Type: "sphere", Properties: map[string]interface{}{
+			"center": []interface{}{2.0, 1.0, 0.0}, "radius": 1.0, "color": []interface{}{1.0, 0.0, 0.0}}},
+		{ID: "glass", Type: "sphere", Properties: map[string]interface{}{
+			"center": []interface{}{-2.0, 1.0, 0.0}, "radius": 1.0, "material": map[string]interface{}{"preset": "glass"}}},
+	})
+	if err != nil {
+		t.Fatalf("AddShapes() failed: %v", err)
+	}
+
+	if err := sm.SetDefaultMaterial(map[string]interface{}{"preset": "chrome"}); err != nil {
+		t.Fatalf("SetDefaultMaterial() failed: %v", err)
+	}
+	if sm.DefaultMaterial()["type"] != "metal" {
+		t.Errorf("Expected the chrome preset to be expanded, got %v", sm.DefaultMaterial())
+	}
+
+	if _, err := sm.ToRaytracerScene(); err != nil {
+		t.Fatalf("ToRaytracerScene() failed: %v", err)
+	}
+	converted := func(id string) material.Material {
+		return createShapeMaterial(sm.withDefaultMaterial(sm.FindShape(id).Properties))
+	}
+	if _, ok := converted("plain").(*material.Metal); !ok {
+		t.Errorf("Expected the material-less shape to pick up the default, got %T", converted("plain"))
+	}
+	if _, ok := converted("colored").(*material.Lambertian); !ok {
+		t.Errorf("Expected a shape with a color to keep the gray fallback, got %T", converted("colored"))
+	}
+	if _, ok := converted("glass").(*material.Dielectric); !ok {
+		t.Errorf("Expected a shape's own material to win, got %T", converted("glass"))
+	}
+	if _, ok := sm.FindShape("plain").Properties["material"]; ok {
+		t.Error("Expected the default to be applied at conversion, not stored on the shape")
+	}
+
+	// The default travels with the scene state
+	branch := NewSceneManager()
+	branch.ReplaceState(sm.Snapshot())
+	if !reflect.DeepEqual(branch.DefaultMaterial(), sm.DefaultMaterial()) {
+		t.Errorf("Expected the default material to round-trip, got %v", branch.DefaultMaterial())
+	}
+
+	// Invalid defaults are rejected and leave the current one in place
+	for _, bad := range []map[string]interface{}{
+		{"type": "metal", "albedo": []interface{}{2.0, 0.0, 0.0}},
+		{"preset": "unobtainium"},
+		{"type": "shadow_catcher"},
+	} {
+		if err := sm.SetDefaultMaterial(bad); err == nil {
+			t.Errorf("Expected SetDefaultMaterial(%v) to fail", bad)
+		}
+	}
+	if sm.DefaultMaterial()["type"] != "metal" {
+		t.Errorf("Expected the chrome default to remain, got %v", sm.DefaultMaterial())
+	}
+
+	if err := sm.SetDefaultMaterial(nil); err != nil || sm.DefaultMaterial() != nil {
+		t.Errorf("Expected a nil material to restore the gray default, got %v, %v", sm.DefaultMaterial(), err)
+	}
+}
+
+func TestSetDefaultMaterialTool(t *testing.T) {
+	events := make(chan AgentEvent, 100)
+	agent := NewWithProvider(events, &MockProvider{}, "mock-model")
+	agent.sceneManager = newRenderableSceneManager(t)
+
+	req := parseToolRequestFromFunctionCall(&llm.FunctionCall{Name: "set_default_material", Arguments: map[string]interface{}{
+		"material": map[string]interface{}{"type": "lambertian", "albedo": "random"},
+	}})
+	result := agent.executeToolRequests(context.Background(), req, "test_call_1")
+	if !result.Success {
+		t.Fatalf("Expected set_default_material to succeed, got errors: %v", result.Errors)
+	}
+	resultMap := result.Result.(map[string]interface{})
+	if resultMap["shapes_using_default"] != 1 {
+		t.Errorf("Expected one shape to use the default, got %v", resultMap)
+	}
+	if _, ok := extractFloatArray(agent.sceneManager.DefaultMaterial(), "albedo", 3); !ok {
+		t.Errorf("Expected the random albedo to be resolved, got %v", agent.sceneManager.DefaultMaterial())
+	}
+
+	req = parseToolRequestFromFunctionCall(&llm.FunctionCall{Name: "set_default_material", Arguments: map[string]interface{}{
+		"material": map[string]interface{}{"type": "velvet"},
+	}})
+	if result := agent.executeToolRequests(context.Background(), req, "test_call_2"); result.Success {
+		t.Error("Expected an unknown material type to fail")
+	}
+}
@@ -0,0 +1,81 @@
+package agent
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// Bilateral filter parameters for Denoise
+const (
+	denoiseRadius       = 2    // Pixels on each side of the center pixel
+	denoiseSpatialSigma = 1.5  // Falloff with distance, in pixels
+	denoiseRangeSigma   = 0.12 // Falloff with color difference, with channels in [0, 1]
+)
+
+// Denoise smooths sampling noise with an edge-aware bilateral filter
+// Each pixel becomes a weighted average of its neighbours, where the weight falls off with
+// both distance and color difference. Noisy flat areas are smoothed, while neighbours across
+// an edge differ too much in color to contribute, so edges stay sharp. Fine texture and
+// low-contrast detail are softened along with the noise.
+func Denoise(img image.Image) *image.RGBA {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	// Read every pixel once as alpha-premultiplied channels in [0, 1]
+	pixels := make([][4]float64, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			r, g, b, a := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			pixels[y*width+x] = [4]float64{float64(r) / 0xffff, float64(g) / 0xffff, float64(b) / 0xffff, float64(a) / 0xffff}
+		}
+	}
+
+	const size = 2*denoiseRadius + 1
+	var spatial [size][size]float64
+	for dy := -denoiseRadius; dy <= denoiseRadius; dy++ {
+		for dx := -denoiseRadius; dx <= denoiseRadius; dx++ {
+			spatial[dy+denoiseRadius][dx+denoiseRadius] = math.Exp(-float64(dx*dx+dy*dy) / (2 * denoiseSpatialSigma * denoiseSpatialSigma))
+		}
+	}
+
+	out := image.NewRGBA(bounds)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			center := pixels[y*width+x]
+			var sum [4]float64
+			var total float64
+			for dy := -denoiseRadius; dy <= denoiseRadius; dy++ {
+				ny := y + dy
+				if ny < 0 || ny >= height {
+					continue
+				}
+				for dx := -denoiseRadius; dx <= denoiseRadius; dx++ {
+					nx := x + dx
+					if nx < 0 || nx >= width {
+						continue
+					}
+					neighbour := pixels[ny*width+nx]
+					var distance float64
+					for c := 0; c < 3; c++ {
+						d := neighbour[c] - center[c]
+						distance += d * d
+					}
+					weight := spatial[dy+denoiseRadius][dx+denoiseRadius] *
+						math.Exp(-distance/(2*denoiseRangeSigma*denoiseRangeSigma))
+					for c := range sum {
+						sum[c] += weight * neighbour[c]
+					}
+					total += weight
+				}
+			}
+
+			// The center pixel always has weight 1, so total is never zero
+			toByte := func(v float64) uint8 {
+				return uint8(math.Round(math.Max(0, math.Min(1, v/total)) * 255))
+			}
+			out.SetRGBA(bounds.Min.X+x, bounds.Min.Y+y, color.RGBA{toByte(sum[0]), toByte(sum[1]), toByte(sum[2]), toByte(sum[3])})
+		}
+	}
+	return out
+}
@@ -0,0 +1,124 @@
+package agent
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+
+	"github.com/df07/scene-llm/agent/llm"
+)
+
+// noisyTwoToneImage returns a dark left half and a bright right half, both with Gaussian noise
+func noisyTwoToneImage(width, height int, dark, bright, noise float64) *image.RGBA {
+	rng := rand.New(rand.NewSource(1))
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			base := dark
+			if x >= width/2 {
+				base = bright
+			}
+			v := uint8(255 * min(1, max(0, base+rng.NormFloat64()*noise)))
+			img.SetRGBA(x, y, color.RGBA{v, v, v, 255})
+		}
+	}
+	return img
+}
+
+// regionStats returns the mean and variance of the red channel over columns [x0, x1)
+func regionStats(img *image.RGBA, x0, x1 int) (mean, variance float64) {
+	var sum, sumSq float64
+	n := 0
+	for y := img.Bounds().Min.Y; y < img.Bounds().Max.Y; y++ {
+		for x := x0; x < x1; x++ {
+			v := float64(img.RGBAAt(x, y).R) / 255
+			sum += v
+			sumSq += v * v
+			n++
+		}
+	}
+	mean = sum / float64(n)
+	return mean, sumSq/float64(n) - mean*mean
+}
+
+func TestDenoiseReducesNoiseAndKeepsEdges(t *testing.T) {
+	const width, height = 64, 32
+	const dark, bright = 0.2, 0.8
+	noisy := noisyTwoToneImage(width, height, dark, bright, 0.05)
+	denoised := Denoise(noisy)
+
+	if denoised.Bounds() != noisy.Bounds() {
+		t.Fatalf("Expected bounds %v, got %v", noisy.Bounds(), denoised.Bounds())
+	}
+
+	// Flat areas away from the edge are much smoother
+	for _, region := range []struct {
+		name   string
+		x0, x1 int
+	}{
+		{"dark", 0, width/2 - 3},
+		{"bright", width/2 + 3, width},
+	} {
+		_, before := regionStats(noisy, region.x0, region.x1)
+		_, after := regionStats(denoised, region.x0, region.x1)
+		if after > before/4 {
+			t.Errorf("Expected %s region variance to drop by at least 4x, got %.5f -> %.5f", region.name, before, after)
+		}
+	}
+
+	// The columns either side of the edge keep their own side's brightness
+	leftMean, _ := regionStats(denoised, width/2-1, width/2)
+	rightMean, _ := regionStats(denoised, width/2, width/2+1)
+	if leftMean > dark+0.05 || rightMean < bright-0.05 {
+		t.Errorf("Expected the edge to stay sharp (%.2f | %.2f), got %.2f | %.2f", dark, bright, leftMean, rightMean)
+	}
+}
+
+func TestDenoiseKeepsTransparency(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	denoised := Denoise(img)
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			if c := denoised.RGBAAt(x, y); c != (color.RGBA{}) {
+				t.Fatalf("Expected a transparent pixel at (%d, %d), got %v", x, y, c)
+			}
+		}
+	}
+}
+
+func TestSetRenderQualityDenoise(t *testing.T) {
+	events := make(chan AgentEvent, 100)
+	agent := NewWithProvider(events, &MockProvider{}, "mock-model")
+
+	if agent.Denoise() {
+		t.Fatal("Expected denoising to be off by default")
+	}
+
+	setQuality := func(args map[string]interface{}) map[string]interface{} {
+		t.Helper()
+		req := parseToolRequestFromFunctionCall(&llm.FunctionCall{Name: "set_render_quality", Arguments: args})
+		result := agent.executeToolRequests(context.Background(), req, "test_call_1")
+		if !result.Success {
+			t.Fatalf("Expected set_render_quality to succeed, got errors: %v", result.Errors)
+		}
+		return result.Result.(map[string]interface{})
+	}
+
+	result := setQuality(map[string]interface{}{"quality": "draft", "denoise": true})
+	if !agent.Denoise() || !result["settings"].(RenderSettings).Denoise {
+		t.Errorf("Expected denoising on, got agent %v and settings %+v", agent.Denoise(), result["settings"])
+	}
+
+	// Omitting denoise keeps the current setting
+	setQuality(map[string]interface{}{"quality": "preview"})
+	if !agent.Denoise() {
+		t.Error("Expected denoising to stay on when denoise is omitted")
+	}
+
+	setQuality(map[string]interface{}{"quality": "preview", "denoise": false})
+	if agent.Denoise() {
+		t.Error("Expected denoising to be turned off")
+	}
+}
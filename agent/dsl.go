@@ -0,0 +1,329 @@
+package agent
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The scene DSL is a terse alternative to create_shape's JSON, one shape per line:
+//
+//	# A table with a ball on it
+//	box table at 0,0.5,0 size 2,1,1 color 0.6,0.4,0.2
+//	sphere red_ball at 0,1.5,0 r 0.5 red metal fuzz 0.2
+//	cylinder post from 2,0,0 to 2,2,0 r 0.1 open
+//
+// Each line is a shape type and an ID, then words in any order: keywords followed by a value
+// (a number, or comma-separated numbers for vectors), a color name or 'color r,g,b', and a
+// material. Blank lines and text after '#' are ignored.
+
+// dslValue is the kind of value a DSL keyword takes
+type dslValue int
+
+const (
+	dslFloat dslValue = iota
+	dslVec2
+	dslVec3
+)
+
+// dslKeyword maps a DSL keyword to the shape property it sets
+type dslKeyword struct {
+	property string
+	value    dslValue
+}
+
+// dslShapeKeywords lists the keywords each shape type takes
+var dslShapeKeywords = map[string]map[string]dslKeyword{
+	"sphere":    {"at": {"center", dslVec3}, "r": {"radius", dslFloat}},
+	"ellipsoid": {"at": {"center", dslVec3}, "radii": {"radii", dslVec3}},
+	"box":       {"at": {"center", dslVec3}, "size": {"dimensions", dslVec3}},
+	"pyramid":   {"at": {"center", dslVec3}, "base": {"base_size", dslVec2}, "height": {"height", dslFloat}},
+	"quad":      {"corner": {"corner", dslVec3}, "u": {"u", dslVec3}, "v": {"v", dslVec3}},
+	"disc":      {"at": {"center", dslVec3}, "normal": {"normal", dslVec3}, "r": {"radius", dslFloat}},
+	"cylinder":  {"from": {"base_center", dslVec3}, "to": {"top_center", dslVec3}, "r": {"radius", dslFloat}},
+	"cone":      {"from": {"base_center", dslVec3}, "to": {"top_center", dslVec3}, "r": {"base_radius", dslFloat}, "top": {"top_radius", dslFloat}},
+}
+
+// dslColors are the color names the DSL accepts
+var dslColors = map[string][]interface{}{
+	"red":    {0.8, 0.1, 0.1},
+	"orange": {0.9, 0.5, 0.1},
+	"yellow": {0.9, 0.8, 0.1},
+	"green":  {0.1, 0.7, 0.2},
+	"cyan":   {0.1, 0.7, 0.8},
+	"blue":   {0.1, 0.2, 0.8},
+	"purple": {0.5, 0.2, 0.7},
+	"pink":   {0.9, 0.5, 0.6},
+	"brown":  {0.45, 0.3, 0.15},
+	"white":  {0.9, 0.9, 0.9},
+	"gray":   {0.5, 0.5, 0.5},
+	"grey":   {0.5, 0.5, 0.5},
+	"black":  {0.05, 0.05, 0.05},
+}
+
+// dslMaterials are the plain material names; the material presets are accepted by name as well
+var dslMaterials = map[string]bool{"matte": true, "metal": true}
+
+// dslDefaultAlbedo returns the color of a matte or metal material when the line gives none
+func dslDefaultAlbedo() []interface{} {
+	return []interface{}{0.8, 0.8, 0.8}
+}
+
+// dslDefaultFuzz is the fuzz of a metal material when the line gives none
+const dslDefaultFuzz = 0.1
+
+// dslCommaSpace matches whitespace around commas, so "0, 1, 0" reads as a single vector
+var dslCommaSpace = regexp.MustCompile(`\s*,\s*`)
+
+// dslShape is a shape parsed from the DSL, with the line it came from
+type dslShape struct {
+	line  int
+	shape ShapeRequest
+}
+
+// ImportDSL adds the shapes described in the scene DSL
+// Every line is parsed and validated before anything is added, and the errors of all bad lines
+// are reported together, each prefixed with its line number. Either every shape is added or
+// none is. Returns the IDs of the added shapes in the order they were written.
+func (sm *SceneManager) ImportDSL(text string) ([]string, error) {
+	parsed, errors := parseSceneDSL(text)
+	for _, p := range parsed {
+		for _, message := range sm.ValidateShape(p.shape) {
+			errors = append(errors, fmt.Sprintf("line %d: %s", p.line, message))
+		}
+	}
+	if len(errors) > 0 {
+		return nil, errors
+	}
+	if len(parsed) == 0 {
+		return nil, fmt.Errorf("the DSL text has no shapes - write one shape per line, e.g. 'sphere ball at 0,1,0 r 1 red'")
+	}
+
+	shapes := make([]ShapeRequest, len(parsed))
+	ids := make([]string, len(parsed))
+	for i, p := range parsed {
+		shapes[i] = p.shape
+		ids[i] = p.shape.ID
+	}
+	if err := sm.AddShapes(shapes); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// parseSceneDSL parses every line of the DSL, collecting the errors of all bad lines
+func parseSceneDSL(text string) ([]dslShape, ValidationErrors) {
+	var shapes []dslShape
+	var errors ValidationErrors
+	lineOf := make(map[string]int) // Line each ID was first used on
+	for i, line := range strings.Split(text, "\n") {
+		if comment := strings.Index(line, "#"); comment >= 0 {
+			line = line[:comment]
+		}
+		words := strings.Fields(dslCommaSpace.ReplaceAllString(line, ","))
+		if len(words) == 0 {
+			continue
+		}
+
+		shape, err := parseDSLLine(words)
+		if err != nil {
+			errors = append(errors, fmt.Sprintf("line %d: %v", i+1, err))
+			continue
+		}
+		if first, seen := lineOf[shape.ID]; seen {
+			errors = append(errors, fmt.Sprintf("line %d: ID '%s' is already used on line %d", i+1, shape.ID, first))
+			continue
+		}
+		lineOf[shape.ID] = i + 1
+		shapes = append(shapes, dslShape{line: i + 1, shape: shape})
+	}
+	return shapes, errors
+}
+
+// parseDSLLine parses the words of one line into a shape
+// Required properties that are missing are left for shape validation to report.
+func parseDSLLine(words []string) (ShapeRequest, error) {
+	shapeType := strings.ToLower(words[0])
+	keywords, ok := dslShapeKeywords[shapeType]
+	if !ok {
+		return ShapeRequest{}, fmt.Errorf("unknown shape '%s' (supported: %s)", words[0], strings.Join(sortedKeys(dslShapeKeywords), ", "))
+	}
+	if len(words) < 2 {
+		return ShapeRequest{}, fmt.Errorf("%s needs an ID after the shape type", shapeType)
+	}
+	id := words[1]
+	if _, isKeyword := keywords[strings.ToLower(id)]; isKeyword || dslColors[strings.ToLower(id)] != nil {
+		return ShapeRequest{}, fmt.Errorf("%s needs an ID after the shape type, got the keyword '%s'", shapeType, id)
+	}
+
+	properties := make(map[string]interface{})
+	if shapeType == "cylinder" || shapeType == "cone" {
+		properties["capped"] = true
+	}
+	if shapeType == "cone" {
+		properties["top_radius"] = 0.0
+	}
+
+	var color interface{} // A color name's or 'color' keyword's value, or "random"
+	var materialName string
+	var fuzz interface{}
+	set := make(map[string]bool) // Words used so far, to reject repeats
+
+	for i := 2; i < len(words); i++ {
+		word := strings.ToLower(words[i])
+		if set[word] {
+			return ShapeRequest{}, fmt.Errorf("'%s' is given more than once", word)
+		}
+		set[word] = true
+
+		// Words that take a value
+		keyword, isKeyword := keywords[word]
+		if word == "color" {
+			keyword, isKeyword = dslKeyword{"color", dslVec3}, true
+		} else if word == "fuzz" {
+			keyword, isKeyword = dslKeyword{"fuzz", dslFloat}, true
+		}
+		if isKeyword {
+			if i+1 >= len(words) {
+				return ShapeRequest{}, fmt.Errorf("'%s' needs a value", word)
+			}
+			i++
+			value, err := parseDSLValue(words[i], keyword.value)
+			if err != nil {
+				return ShapeRequest{}, fmt.Errorf("'%s': %v", word, err)
+			}
+			switch word {
+			case "color":
+				if color != nil {
+					return ShapeRequest{}, fmt.Errorf("more than one color given")
+				}
+				color = value
+			case "fuzz":
+				fuzz = value
+			default:
+				properties[keyword.property] = value
+			}
+			continue
+		}
+
+		// Flags
+		switch {
+		case word == "open" && (shapeType == "cylinder" || shapeType == "cone"):
+			properties["capped"] = false
+		case dslColors[word] != nil || word == randomColorValue:
+			if color != nil {
+				return ShapeRequest{}, fmt.Errorf("more than one color given")
+			}
+			if word == randomColorValue {
+				color = randomColorValue
+			} else {
+				color = append([]interface{}(nil), dslColors[word]...) // Shapes must not share a slice
+			}
+		case dslMaterials[word] || materialPresets[word] != nil:
+			if materialName != "" {
+				return ShapeRequest{}, fmt.Errorf("more than one material given ('%s' and '%s')", materialName, word)
+			}
+			materialName = word
+		default:
+			return ShapeRequest{}, fmt.Errorf("unknown word '%s' for a %s (keywords: %s; also color names, 'color r,g,b', materials and presets: %s)",
+				words[i], shapeType, strings.Join(sortedKeys(keywords), ", "), strings.Join(dslMaterialNames(), ", "))
+		}
+	}
+
+	material, err := dslMaterial(materialName, color, fuzz)
+	if err != nil {
+		return ShapeRequest{}, err
+	}
+	if material != nil {
+		properties["material"] = material
+	} else if color != nil {
+		properties["color"] = color
+	}
+	return ShapeRequest{ID: id, Type: shapeType, Properties: properties}, nil
+}
+
+// dslMaterial builds the material for a line's material name, color and fuzz
+// A color tints the material's albedo; without a material it is left as the shape's color and
+// nil is returned.
+func dslMaterial(name string, color, fuzz interface{}) (map[string]interface{}, error) {
+	matType := name
+	if preset, ok := materialPresets[name]; ok {
+		matType, _ = preset["type"].(string)
+	}
+	if fuzz != nil && matType != "metal" {
+		return nil, fmt.Errorf("'fuzz' only applies to metal materials")
+	}
+
+	switch {
+	case name == "":
+		return nil, nil
+	case name == "matte":
+		return map[string]interface{}{"type": "lambertian", "albedo": orDefault(color, dslDefaultAlbedo())}, nil
+	case name == "metal":
+		return map[string]interface{}{"type": "metal", "albedo": orDefault(color, dslDefaultAlbedo()), "fuzz": orDefault(fuzz, dslDefaultFuzz)}, nil
+	}
+
+	material := map[string]interface{}{"preset": name}
+	if color != nil {
+		if matType == "dielectric" {
+			return nil, fmt.Errorf("%s is clear and can't be colored", name)
+		}
+		material["albedo"] = color
+	}
+	if fuzz != nil {
+		material["fuzz"] = fuzz
+	}
+	return material, nil
+}
+
+// parseDSLValue parses a number, or comma-separated numbers for a vector
+func parseDSLValue(word string, kind dslValue) (interface{}, error) {
+	parts := strings.Split(word, ",")
+	components := map[dslValue]int{dslFloat: 1, dslVec2: 2, dslVec3: 3}[kind]
+	if len(parts) != components {
+		if components == 1 {
+			return nil, fmt.Errorf("expected a number, got '%s'", word)
+		}
+		return nil, fmt.Errorf("expected %d comma-separated numbers, got '%s'", components, word)
+	}
+
+	values := make([]interface{}, len(parts))
+	for i, part := range parts {
+		value, err := strconv.ParseFloat(part, 64)
+		if err != nil || !isFinite(value) {
+			return nil, fmt.Errorf("'%s' is not a number", part)
+		}
+		values[i] = value
+	}
+	if kind == dslFloat {
+		return values[0], nil
+	}
+	return values, nil
+}
+
+// dslMaterialNames lists the material words the DSL accepts
+func dslMaterialNames() []string {
+	names := append(sortedKeys(materialPresets), sortedKeys(dslMaterials)...)
+	sort.Strings(names)
+	return names
+}
+
+// orDefault returns value, or fallback if value is nil
+func orDefault(value, fallback interface{}) interface{} {
+	if value == nil {
+		return fallback
+	}
+	return value
+}
+
+// sortedKeys returns a map's keys in sorted order, for listing the accepted words in errors
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,151 @@
+package agent
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/df07/scene-llm/agent/llm"
+)
+
+func TestImportDSL(t *testing.T) {
+	sm := NewSceneManager()
+	ids, err := sm.ImportDSL(`
+# A table with things on it
+box table at 0, 0.5, 0 size 2,1,1 brown
+sphere red_ball at 0,1.5,0 r 0.5 red metal fuzz 0.2
+cylinder post from 2,0,0 to 2,2,0 r 0.1 open   # no caps
+cone hat from -1,1,0 to -1,1.5,0 r 0.3 gold
+quad floor corner -5,0,-5 u 10,0,0 v 0,0,10 matte
+`)
+	if err != nil {
+		t.Fatalf("ImportDSL() failed: %v", err)
+	}
+	if want := []string{"table", "red_ball", "post", "hat", "floor"}; !reflect.DeepEqual(ids, want) {
+		t.Fatalf("Expected IDs %v, got %v", want, ids)
+	}
+
+	table := sm.FindShape("table")
+	if table.Type != "box" || !reflect.DeepEqual(table.Properties["center"], []interface{}{0.0, 0.5, 0.0}) ||
+		!reflect.DeepEqual(table.Properties["dimensions"], []interface{}{2.0, 1.0, 1.0}) {
+		t.Errorf("Expected a box at [0 0.5 0] sized [2 1 1], got %+v", table)
+	}
+	if !reflect.DeepEqual(table.Properties["color"], dslColors["brown"]) {
+		t.Errorf("Expected a color name without a material to set the shape's color, got %v", table.Properties["color"])
+	}
+
+	ball := sm.FindShape("red_ball").Properties["material"].(map[string]interface{})
+	if ball["type"] != "metal" || ball["fuzz"] != 0.2 || !reflect.DeepEqual(ball["albedo"], dslColors["red"]) {
+		t.Errorf("Expected a red metal with fuzz 0.2, got %v", ball)
+	}
+
+	if post := sm.FindShape("post"); post.Properties["capped"] != false || post.Properties["radius"] != 0.1 {
+		t.Errorf("Expected an open cylinder of radius 0.1, got %v", post.Properties)
+	}
+
+	hat := sm.FindShape("hat")
+	if hat.Properties["base_radius"] != 0.3 || hat.Properties["top_radius"] != 0.0 || hat.Properties["capped"] != true {
+		t.Errorf("Expected a capped cone with top radius 0 by default, got %v", hat.Properties)
+	}
+	if mat := hat.Properties["material"].(map[string]interface{}); mat["type"] != "metal" {
+		t.Errorf("Expected the gold preset to be expanded, got %v", mat)
+	}
+
+	if mat := sm.FindShape("floor").Properties["material"].(map[string]interface{}); mat["type"] != "lambertian" {
+		t.Errorf("Expected a matte floor, got %v", mat)
+	}
+}
+
+func TestImportDSLErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		dsl  string
+		want []string // Substrings expected in the error
+	}{
+		{
+			name: "reports every bad line by number",
+			dsl:  "sphere a at 0,0,0 r 1\ntorus b at 0,0,0\nbox c at 0,0 size 1,1,1\nsphere d at 0,0,0 r 1 sparkly",
+			want: []string{"line 2: unknown shape 'torus'", "line 3: 'at': expected 3 comma-separated numbers", "line 4: unknown word 'sparkly'"},
+		},
+		{
+			name: "validates parsed shapes",
+			dsl:  "sphere a at 0,0,0\nbox b at 0,0,0 size 1,-1,1",
+			want: []string{"line 1:", "radius", "line 2:", "dimensions"},
+		},
+		{
+			name: "missing value",
+			dsl:  "sphere a at 0,0,0 r",
+			want: []string{"line 1: 'r' needs a value"},
+		},
+		{
+			name: "not a number",
+			dsl:  "sphere a at 0,0,0 r big",
+			want: []string{"line 1: 'r': 'big' is not a number"},
+		},
+		{
+			name: "duplicate ID",
+			dsl:  "sphere a at 0,0,0 r 1\nsphere a at 0,2,0 r 1",
+			want: []string{"line 2: ID 'a' is already used on line 1"},
+		},
+		{
+			name: "two materials",
+			dsl:  "sphere a at 0,0,0 r 1 metal glass",
+			want: []string{"more than one material"},
+		},
+		{
+			name: "colored glass",
+			dsl:  "sphere a at 0,0,0 r 1 glass red",
+			want: []string{"glass is clear"},
+		},
+		{
+			name: "fuzz without metal",
+			dsl:  "sphere a at 0,0,0 r 1 matte fuzz 0.3",
+			want: []string{"'fuzz' only applies to metal"},
+		},
+		{
+			name: "no shapes",
+			dsl:  "# nothing here\n\n",
+			want: []string{"no shapes"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sm := NewSceneManager()
+			_, err := sm.ImportDSL(tt.dsl)
+			if err == nil {
+				t.Fatal("Expected an error")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Expected error to contain %q, got %q", want, err.Error())
+				}
+			}
+			if len(sm.state.Shapes) != 0 {
+				t.Errorf("Expected no shapes to be added when any line fails, got %d", len(sm.state.Shapes))
+			}
+		})
+	}
+}
+
+func TestImportDSLTool(t *testing.T) {
+	agent := NewWithProvider(make(chan AgentEvent, 100), &MockProvider{}, "mock-model")
+	req := parseToolRequestFromFunctionCall(&llm.FunctionCall{Name: "import_dsl", Arguments: map[string]interface{}{
+		"dsl": "sphere ball at 0,1,0 r 1 random\nbox crate at 3,0.5,0 size 1,1,1 plastic color 0.2,0.4,0.9",
+	}})
+	result := agent.executeToolRequests(context.Background(), req, "call-1")
+	if !result.Success {
+		t.Fatalf("Expected import_dsl to succeed, got errors: %v", result.Errors)
+	}
+	if ids := req.(*ImportDSLRequest).CreatedIds; !reflect.DeepEqual(ids, []string{"ball", "crate"}) {
+		t.Errorf("Expected created IDs [ball crate], got %v", ids)
+	}
+	if _, ok := extractFloatArray(agent.sceneManager.FindShape("ball").Properties, "color", 3); !ok {
+		t.Errorf("Expected the random color to be resolved")
+	}
+	crate := agent.sceneManager.FindShape("crate").Properties["material"].(map[string]interface{})
+	if !reflect.DeepEqual(crate["albedo"], []interface{}{0.2, 0.4, 0.9}) {
+		t.Errorf("Expected the color to tint the plastic preset, got %v", crate)
+	}
+}
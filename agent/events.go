@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/df07/go-progressive-raytracer/pkg/scene"
+	"github.com/df07/scene-llm/agent/llm"
 )
 
 // AgentEvent is the interface that all agent events implement
@@ -42,7 +43,7 @@ type ToolCallEvent struct {
 	Error         string      `json:"error,omitempty"`
 	Duration      int64       `json:"duration"`                 // Tool request duration in ms
 	Timestamp     time.Time   `json:"timestamp"`                // When the tool request occurred
-	RenderedImage []byte      `json:"rendered_image,omitempty"` // Image data for render_scene tool
+	RenderedImage []byte      `json:"rendered_image,omitempty"` // Image data for render_scene and preview_material
 }
 
 func (e ToolCallEvent) EventType() string { return "function_calls" }
@@ -55,10 +56,32 @@ func (e SceneUpdateEvent) EventType() string { return "scene_update" }
 
 type SceneRenderEvent struct {
 	RaytracerScene *scene.Scene `json:"-"` // Ready-to-render scene, not serialized
+	SceneRenderSettings
 }
 
 func (e SceneRenderEvent) EventType() string { return "scene_render" }
 
+// RenderCancelledEvent indicates a render was aborted because its context was cancelled
+type RenderCancelledEvent struct {
+	ID      string `json:"id,omitempty"` // Tool call ID if the render came from render_scene
+	Message string `json:"message"`
+}
+
+func (e RenderCancelledEvent) EventType() string { return "render_cancelled" }
+
+// TelemetryEvent summarizes one turn of the agentic loop (one model call and the tools it
+// invoked), for observing the agent's behavior and cost
+type TelemetryEvent struct {
+	ModelID     string     `json:"model_id"`
+	Turn        int        `json:"turn"`            // 1-based turn number within the message
+	Tools       []string   `json:"tools"`           // Tools invoked this turn, in call order
+	RenderCount int        `json:"render_count"`    // render_scene and preview_material calls that produced an image
+	Usage       *llm.Usage `json:"usage,omitempty"` // Token counts, if the provider reported them
+	ElapsedMs   int64      `json:"elapsed_ms"`      // Wall time for the model call and tools
+}
+
+func (e TelemetryEvent) EventType() string { return "telemetry" }
+
 type ErrorEvent struct {
 	Message string `json:"message"`
 }
@@ -105,8 +128,12 @@ func NewSceneUpdateEvent(scene *SceneState) SceneUpdateEvent {
 	return SceneUpdateEvent{Scene: scene}
 }
 
-func NewSceneRenderEvent(raytracerScene *scene.Scene) SceneRenderEvent {
-	return SceneRenderEvent{RaytracerScene: raytracerScene}
+func NewSceneRenderEvent(raytracerScene *scene.Scene, settings SceneRenderSettings) SceneRenderEvent {
+	return SceneRenderEvent{RaytracerScene: raytracerScene, SceneRenderSettings: settings}
+}
+
+func NewRenderCancelledEvent(id string) RenderCancelledEvent {
+	return RenderCancelledEvent{ID: id, Message: "Render cancelled"}
 }
 
 func NewErrorEvent(err error) ErrorEvent {
@@ -0,0 +1,78 @@
+package agent
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+)
+
+// ClampFireflies suppresses fireflies: isolated pixels far brighter than everything around them
+// Fireflies come from rare indirect paths that happen to find a bright light, usually through
+// glass or off polished metal, and one sample can outweigh the rest of the pixel. The raytracer's
+// integrators don't expose a per-sample clamp, so this works on the finished 8-bit image instead,
+// and on all of a pixel's light rather than only its indirect light: a pixel's luminance may
+// exceed the brightest of its eight neighbours by at most threshold (with channels in [0, 1]),
+// and brighter pixels are scaled down to that limit, keeping their hue.
+//
+// Direct lighting, highlights and lights themselves cover several pixels, so their neighbours are
+// just as bright and they pass through untouched. The cost is bias: the energy removed from a
+// clamped pixel is lost rather than spread out, so small genuine sparkles (a tiny specular glint,
+// a distant light one pixel wide) are dimmed too, and lower thresholds dim more of them.
+func ClampFireflies(img image.Image, threshold float64) *image.RGBA {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	pixels := make([][4]float64, width*height)
+	luminance := make([]float64, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			r, g, b, a := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			p := [4]float64{float64(r) / 0xffff, float64(g) / 0xffff, float64(b) / 0xffff, float64(a) / 0xffff}
+			pixels[y*width+x] = p
+			luminance[y*width+x] = 0.2126*p[0] + 0.7152*p[1] + 0.0722*p[2]
+		}
+	}
+
+	out := image.NewRGBA(bounds)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			p := pixels[y*width+x]
+			lum := luminance[y*width+x]
+
+			// Images smaller than 2x2 have pixels without neighbours, which are left alone
+			brightest, neighbours := 0.0, 0
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					nx, ny := x+dx, y+dy
+					if (dx == 0 && dy == 0) || nx < 0 || nx >= width || ny < 0 || ny >= height {
+						continue
+					}
+					brightest = math.Max(brightest, luminance[ny*width+nx])
+					neighbours++
+				}
+			}
+			if limit := brightest + threshold; neighbours > 0 && lum > limit {
+				scale := limit / lum
+				for c := 0; c < 3; c++ {
+					p[c] *= scale
+				}
+			}
+
+			toByte := func(v float64) uint8 {
+				return uint8(math.Round(math.Max(0, math.Min(1, v)) * 255))
+			}
+			out.SetRGBA(bounds.Min.X+x, bounds.Min.Y+y, color.RGBA{toByte(p[0]), toByte(p[1]), toByte(p[2]), toByte(p[3])})
+		}
+	}
+	return out
+}
+
+// validateClampIndirect checks a firefly clamp threshold, where 0 turns clamping off
+// ClampFireflies works on colors in [0, 1], so a threshold of 1 or more could never clamp anything.
+func validateClampIndirect(threshold float64) error {
+	if !(threshold >= 0 && threshold < 1) {
+		return fmt.Errorf("clamp_indirect must be at least 0 and below 1, or 0 to turn clamping off, got %g", threshold)
+	}
+	return nil
+}
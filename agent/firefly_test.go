@@ -0,0 +1,110 @@
+package agent
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/df07/scene-llm/agent/llm"
+)
+
+func TestClampFireflies(t *testing.T) {
+	// A dim gray floor with one isolated firefly and a 3x3 patch of genuinely bright light
+	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			img.SetRGBA(x, y, color.RGBA{51, 51, 51, 255})
+		}
+	}
+	img.SetRGBA(4, 4, color.RGBA{255, 230, 200, 255})
+	for y := 10; y < 13; y++ {
+		for x := 10; x < 13; x++ {
+			img.SetRGBA(x, y, color.RGBA{255, 255, 255, 255})
+		}
+	}
+
+	clamped := ClampFireflies(img, 0.2)
+	if clamped.Bounds() != img.Bounds() {
+		t.Fatalf("Expected bounds %v, got %v", img.Bounds(), clamped.Bounds())
+	}
+
+	// The firefly is pulled down to its neighbours' brightness plus the threshold, keeping its hue
+	firefly := clamped.RGBAAt(4, 4)
+	lum := (0.2126*float64(firefly.R) + 0.7152*float64(firefly.G) + 0.0722*float64(firefly.B)) / 255
+	if math.Abs(lum-0.4) > 0.01 {
+		t.Errorf("Expected the firefly clamped to luminance 0.4, got %.3f (%v)", lum, firefly)
+	}
+	if !(firefly.R > firefly.G && firefly.G > firefly.B) {
+		t.Errorf("Expected the firefly to keep its warm tint, got %v", firefly)
+	}
+
+	// Everything else, including the bright patch's edges and corners, is untouched
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			if x == 4 && y == 4 {
+				continue
+			}
+			if clamped.RGBAAt(x, y) != img.RGBAAt(x, y) {
+				t.Fatalf("Expected pixel (%d, %d) unchanged, got %v from %v", x, y, clamped.RGBAAt(x, y), img.RGBAAt(x, y))
+			}
+		}
+	}
+
+	// A higher threshold lets more through
+	if loose := ClampFireflies(img, 0.5).RGBAAt(4, 4); loose.G <= firefly.G {
+		t.Errorf("Expected a higher threshold to clamp less, got %v vs %v", loose, firefly)
+	}
+}
+
+func TestValidateClampIndirect(t *testing.T) {
+	for _, valid := range []float64{0, 0.2, 0.99} {
+		if err := validateClampIndirect(valid); err != nil {
+			t.Errorf("validateClampIndirect(%g) = %v, expected valid", valid, err)
+		}
+	}
+	for _, invalid := range []float64{-0.1, 1, 5, math.NaN(), math.Inf(1)} {
+		if err := validateClampIndirect(invalid); err == nil {
+			t.Errorf("validateClampIndirect(%g) expected an error", invalid)
+		}
+	}
+}
+
+func TestClampIndirectSetting(t *testing.T) {
+	events := make(chan AgentEvent, 100)
+	agent := NewWithProvider(events, &MockProvider{}, "mock-model")
+	agent.sceneManager = newRenderableSceneManager(t)
+
+	call := func(name string, args map[string]interface{}) ToolResult {
+		req := parseToolRequestFromFunctionCall(&llm.FunctionCall{Name: name, Arguments: args})
+		return agent.executeToolRequests(context.Background(), req, "test_call")
+	}
+
+	if result := call("set_render_quality", map[string]interface{}{"quality": "preview", "clamp_indirect": 0.2}); !result.Success {
+		t.Fatalf("Expected set_render_quality to succeed, got errors: %v", result.Errors)
+	}
+	if agent.ClampIndirect() != 0.2 {
+		t.Errorf("Expected clamp_indirect 0.2, got %g", agent.ClampIndirect())
+	}
+	if result := call("render_scene", map[string]interface{}{}); !result.Success || result.Result.(map[string]interface{})["clamp_indirect"] != 0.2 {
+		t.Errorf("Expected the render to use the clamp, got %+v", result)
+	}
+	if result := call("render_scene", map[string]interface{}{"clamp_indirect": 0.0}); !result.Success || result.Result.(map[string]interface{})["clamp_indirect"] != 0.0 {
+		t.Errorf("Expected the per-render override to turn clamping off, got %+v", result)
+	}
+
+	// Thresholds outside [0, 1) are rejected without changing the setting
+	if result := call("set_render_quality", map[string]interface{}{"quality": "preview", "clamp_indirect": -1.0}); result.Success {
+		t.Error("Expected a negative clamp_indirect to fail")
+	}
+	if result := call("set_render_quality", map[string]interface{}{"quality": "preview", "clamp_indirect": 1.0}); result.Success {
+		t.Error("Expected a clamp_indirect of 1, which can never clamp, to fail")
+	}
+	if result := call("render_scene", map[string]interface{}{"clamp_indirect": -1.0}); result.Success {
+		t.Error("Expected render_scene with a negative clamp_indirect to fail")
+	}
+	if agent.ClampIndirect() != 0.2 {
+		t.Errorf("Expected clamp_indirect to remain 0.2, got %g", agent.ClampIndirect())
+	}
+}
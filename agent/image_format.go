@@ -0,0 +1,90 @@
+package agent
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"path/filepath"
+	"strings"
+)
+
+// Image formats a render can be encoded as
+const (
+	ImageFormatPNG  = "png"
+	ImageFormatJPEG = "jpeg"
+)
+
+// DefaultImageQuality is the JPEG quality used when none is given
+const DefaultImageQuality = 90
+
+// ParseImageFormat normalizes an image format name, accepting "jpg" for jpeg
+// An empty name is PNG.
+func ParseImageFormat(format string) (string, error) {
+	switch strings.ToLower(format) {
+	case "", ImageFormatPNG:
+		return ImageFormatPNG, nil
+	case ImageFormatJPEG, "jpg":
+		return ImageFormatJPEG, nil
+	default:
+		return "", fmt.Errorf("unknown image format '%s' (supported: png, jpeg)", format)
+	}
+}
+
+// validateImageQuality checks a lossy encoding quality, where 0 means DefaultImageQuality
+func validateImageQuality(quality int) error {
+	if quality < 0 || quality > 100 {
+		return fmt.Errorf("image_quality must be from 1 to 100, or 0 for the default of %d, got %d", DefaultImageQuality, quality)
+	}
+	return nil
+}
+
+// ValidateImageEncoding checks an image format and quality before anything is rendered
+func ValidateImageEncoding(format string, quality int) error {
+	if _, err := ParseImageFormat(format); err != nil {
+		return err
+	}
+	return validateImageQuality(quality)
+}
+
+// ImageFormatForPath returns the image format a file name's extension calls for
+func ImageFormatForPath(path string) (string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".png":
+		return ImageFormatPNG, nil
+	case ".jpg", ".jpeg":
+		return ImageFormatJPEG, nil
+	default:
+		return "", fmt.Errorf("'%s' must end in .png, .jpg or .jpeg", path)
+	}
+}
+
+// EncodeImage encodes a render in an image format and returns it with its MIME type
+// quality applies only to lossy formats; PNG ignores it.
+func EncodeImage(img image.Image, format string, quality int) ([]byte, string, error) {
+	format, err := ParseImageFormat(format)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := validateImageQuality(quality); err != nil {
+		return nil, "", err
+	}
+
+	var buf bytes.Buffer
+	switch format {
+	case ImageFormatJPEG:
+		if quality == 0 {
+			quality = DefaultImageQuality
+		}
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+			return nil, "", fmt.Errorf("failed to encode image: %w", err)
+		}
+		return buf.Bytes(), "image/jpeg", nil
+	default:
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, "", fmt.Errorf("failed to encode image: %w", err)
+		}
+		return buf.Bytes(), "image/png", nil
+	}
+}
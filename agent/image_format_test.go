@@ -0,0 +1,124 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/df07/scene-llm/agent/llm"
+)
+
+func TestEncodeImage(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 32, 24))
+	for y := 0; y < 24; y++ {
+		for x := 0; x < 32; x++ {
+			img.SetRGBA(x, y, color.RGBA{uint8(x * 8), uint8(y * 10), 128, 255})
+		}
+	}
+
+	tests := []struct {
+		format   string
+		quality  int
+		mimeType string
+		decode   func([]byte) (image.Image, error)
+	}{
+		{"", 0, "image/png", func(b []byte) (image.Image, error) { return png.Decode(bytes.NewReader(b)) }},
+		{"png", 50, "image/png", func(b []byte) (image.Image, error) { return png.Decode(bytes.NewReader(b)) }},
+		{"jpeg", 0, "image/jpeg", func(b []byte) (image.Image, error) { return jpeg.Decode(bytes.NewReader(b)) }},
+		{"jpg", 40, "image/jpeg", func(b []byte) (image.Image, error) { return jpeg.Decode(bytes.NewReader(b)) }},
+	}
+	for _, tt := range tests {
+		data, mimeType, err := EncodeImage(img, tt.format, tt.quality)
+		if err != nil {
+			t.Fatalf("EncodeImage(%q, %d) failed: %v", tt.format, tt.quality, err)
+		}
+		if mimeType != tt.mimeType {
+			t.Errorf("EncodeImage(%q) MIME type = %q, want %q", tt.format, mimeType, tt.mimeType)
+		}
+		decoded, err := tt.decode(data)
+		if err != nil {
+			t.Fatalf("EncodeImage(%q) produced an undecodable image: %v", tt.format, err)
+		}
+		if decoded.Bounds() != img.Bounds() {
+			t.Errorf("EncodeImage(%q) bounds = %v, want %v", tt.format, decoded.Bounds(), img.Bounds())
+		}
+	}
+
+	// Lower quality trades detail for size
+	high, _, _ := EncodeImage(img, "jpeg", 95)
+	low, _, _ := EncodeImage(img, "jpeg", 10)
+	if len(low) >= len(high) {
+		t.Errorf("Expected quality 10 to be smaller than quality 95, got %d and %d bytes", len(low), len(high))
+	}
+
+	for _, bad := range []struct {
+		format   string
+		quality  int
+		expected string
+	}{
+		{"webp", 0, "unknown image format"},
+		{"gif", 0, "unknown image format"},
+		{"jpeg", 101, "image_quality"},
+		{"jpeg", -1, "image_quality"},
+	} {
+		if _, _, err := EncodeImage(img, bad.format, bad.quality); err == nil || !strings.Contains(err.Error(), bad.expected) {
+			t.Errorf("EncodeImage(%q, %d): expected an error containing %q, got %v", bad.format, bad.quality, bad.expected, err)
+		}
+	}
+}
+
+func TestRenderSceneImageFormat(t *testing.T) {
+	events := make(chan AgentEvent, 100)
+	agent := NewWithProvider(events, &MockProvider{}, "mock-model")
+	dir := t.TempDir()
+	agent.SetOutputDir(dir)
+	if err := agent.sceneManager.AddShapes([]ShapeRequest{{ID: "ball", Type: "sphere", Properties: map[string]interface{}{
+		"center": []interface{}{0.0, 0.0, 0.0},
+		"radius": 1.0,
+	}}}); err != nil {
+		t.Fatalf("Failed to add shape: %v", err)
+	}
+	render := func(args map[string]interface{}) (ToolRequest, ToolResult) {
+		args["mode"] = "wireframe"
+		req := parseToolRequestFromFunctionCall(&llm.FunctionCall{Name: "render_scene", Arguments: args})
+		return req, agent.executeToolRequests(context.Background(), req, "test_call")
+	}
+
+	// The file is a JPEG, but the model still sees a PNG
+	req, result := render(map[string]interface{}{"output_path": "ball.jpg", "image_quality": 80.0})
+	if !result.Success {
+		t.Fatalf("Expected render_scene to succeed, got errors: %v", result.Errors)
+	}
+	if format := result.Result.(map[string]interface{})["image_format"]; format != ImageFormatJPEG {
+		t.Errorf("Expected the extension to pick jpeg, got %v", format)
+	}
+	written, err := os.ReadFile(filepath.Join(dir, "ball.jpg"))
+	if err != nil {
+		t.Fatalf("Expected the render to be written: %v", err)
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(written)); err != nil {
+		t.Errorf("Expected a JPEG file: %v", err)
+	}
+	if _, err := png.Decode(bytes.NewReader(req.(*RenderSceneRequest).RenderedImage)); err != nil {
+		t.Errorf("Expected the model's image to stay a PNG: %v", err)
+	}
+
+	for name, args := range map[string]map[string]interface{}{
+		"format mismatch":   {"output_path": "ball.png", "image_format": "jpeg"},
+		"unknown format":    {"output_path": "ball.jpg", "image_format": "webp"},
+		"without a file":    {"image_format": "jpeg"},
+		"quality too high":  {"output_path": "ball.jpg", "image_quality": 150.0},
+		"unknown extension": {"output_path": "ball.webp"},
+	} {
+		if _, result := render(args); result.Success {
+			t.Errorf("%s: expected render_scene to fail", name)
+		}
+	}
+}
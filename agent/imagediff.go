@@ -0,0 +1,60 @@
+package agent
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+)
+
+// ImageDiff is the result of comparing two renders pixel by pixel
+type ImageDiff struct {
+	// Image holds the absolute per-channel difference of each pixel, so identical pixels are black
+	Image *image.RGBA
+	// RMSE is the root-mean-square error over the RGB channels, scaled to 0 (identical) to 1
+	RMSE float64
+	// DifferentPixels counts the pixels where any channel differs
+	DifferentPixels int
+}
+
+// DiffImages compares two images of the same size
+// Alpha is ignored: renders are opaque, and a JPEG round trip doesn't keep it anyway.
+func DiffImages(a, b image.Image) (*ImageDiff, error) {
+	boundsA, boundsB := a.Bounds(), b.Bounds()
+	if boundsA.Dx() != boundsB.Dx() || boundsA.Dy() != boundsB.Dy() {
+		return nil, fmt.Errorf("images have different dimensions: %dx%d and %dx%d", boundsA.Dx(), boundsA.Dy(), boundsB.Dx(), boundsB.Dy())
+	}
+	width, height := boundsA.Dx(), boundsA.Dy()
+	if width == 0 || height == 0 {
+		return nil, fmt.Errorf("images are empty")
+	}
+
+	diff := &ImageDiff{Image: image.NewRGBA(image.Rect(0, 0, width, height))}
+	var sumSquares float64
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			ca := color.NRGBAModel.Convert(a.At(boundsA.Min.X+x, boundsA.Min.Y+y)).(color.NRGBA)
+			cb := color.NRGBAModel.Convert(b.At(boundsB.Min.X+x, boundsB.Min.Y+y)).(color.NRGBA)
+
+			channels := [3]uint8{absDiff(ca.R, cb.R), absDiff(ca.G, cb.G), absDiff(ca.B, cb.B)}
+			for _, d := range channels {
+				sumSquares += float64(d) * float64(d)
+			}
+			if channels != [3]uint8{} {
+				diff.DifferentPixels++
+			}
+			diff.Image.SetRGBA(x, y, color.RGBA{R: channels[0], G: channels[1], B: channels[2], A: 255})
+		}
+	}
+
+	diff.RMSE = math.Sqrt(sumSquares/float64(width*height*3)) / 255
+	return diff, nil
+}
+
+// absDiff returns |a-b| for 8-bit channel values
+func absDiff(a, b uint8) uint8 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
@@ -0,0 +1,59 @@
+package agent
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestDiffImages(t *testing.T) {
+	solid := func(width, height int, c color.RGBA) *image.RGBA {
+		img := image.NewRGBA(image.Rect(0, 0, width, height))
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				img.SetRGBA(x, y, c)
+			}
+		}
+		return img
+	}
+	gray := color.RGBA{R: 100, G: 100, B: 100, A: 255}
+
+	diff, err := DiffImages(solid(4, 4, gray), solid(4, 4, gray))
+	if err != nil {
+		t.Fatalf("DiffImages() failed: %v", err)
+	}
+	if diff.RMSE != 0 || diff.DifferentPixels != 0 {
+		t.Errorf("Expected identical images to have no difference, got RMSE %v and %d different pixels", diff.RMSE, diff.DifferentPixels)
+	}
+
+	// One pixel's red channel is off by 51 (0.2): RMSE = sqrt(0.2² / (16 pixels * 3 channels))
+	changed := solid(4, 4, gray)
+	changed.SetRGBA(1, 2, color.RGBA{R: 151, G: 100, B: 100, A: 255})
+	diff, err = DiffImages(solid(4, 4, gray), changed)
+	if err != nil {
+		t.Fatalf("DiffImages() failed: %v", err)
+	}
+	if diff.DifferentPixels != 1 {
+		t.Errorf("Expected 1 different pixel, got %d", diff.DifferentPixels)
+	}
+	if want := math.Sqrt(0.04 / 48); math.Abs(diff.RMSE-want) > 1e-9 {
+		t.Errorf("Expected RMSE %v, got %v", want, diff.RMSE)
+	}
+	if got := diff.Image.RGBAAt(1, 2); got != (color.RGBA{R: 51, A: 255}) {
+		t.Errorf("Expected the diff image to show the red difference, got %v", got)
+	}
+	if got := diff.Image.RGBAAt(0, 0); got != (color.RGBA{A: 255}) {
+		t.Errorf("Expected an unchanged pixel to be black, got %v", got)
+	}
+
+	// Images compare by size, not by where their bounds start
+	offset := solid(4, 4, gray).SubImage(image.Rect(1, 1, 3, 3))
+	if _, err := DiffImages(offset, solid(2, 2, gray)); err != nil {
+		t.Errorf("Expected same-sized images with different origins to compare, got %v", err)
+	}
+
+	if _, err := DiffImages(solid(4, 4, gray), solid(4, 3, gray)); err == nil {
+		t.Error("Expected images of different sizes to be rejected")
+	}
+}
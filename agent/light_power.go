@@ -0,0 +1,104 @@
+package agent
+
+import (
+	"fmt"
+	"math"
+)
+
+// Area lights take power_mode to say how their emission is meant. The raytracer expects
+// radiance, the light leaving each unit of surface, so resizing a light changes how much light
+// it gives off overall. In total_power mode emission is the light's total output instead and is
+// divided by the emitting area before rendering, so a light keeps its brightness when resized.
+const (
+	powerModeRadiance   = "radiance" // Default
+	powerModeTotalPower = "total_power"
+)
+
+// isAreaLight reports whether a light type has an emitting surface and takes power_mode
+func isAreaLight(lightType string) bool {
+	switch lightType {
+	case "area_quad_light", "area_rect_light", "area_sphere_light", "disc_spot_light", "area_disc_spot_light":
+		return true
+	}
+	return false
+}
+
+// lightArea returns the emitting surface area of an area light
+// Two-sided quad and rect lights emit from both faces, so they count their area twice.
+// ok is false for lights without an area and for area lights missing their size properties.
+func lightArea(light LightRequest) (float64, bool) {
+	props := light.Properties
+	twoSided, _ := props["two_sided"].(bool)
+	faces := 1.0
+	if twoSided {
+		faces = 2
+	}
+
+	switch light.Type {
+	case "area_quad_light":
+		u := vec3Property(props, "u", vec3{})
+		v := vec3Property(props, "v", vec3{})
+		return u.cross(v).length() * faces, true
+
+	case "area_rect_light":
+		size, ok := extractFloatArray(props, "size", 2)
+		if !ok {
+			return 0, false
+		}
+		return size[0] * size[1] * faces, true
+
+	case "area_sphere_light":
+		radius, ok := extractFloat(props, "radius")
+		if !ok {
+			return 0, false
+		}
+		return 4 * math.Pi * radius * radius, true
+
+	case "disc_spot_light", "area_disc_spot_light":
+		radius, ok := extractFloat(props, "radius")
+		if !ok {
+			return 0, false
+		}
+		return math.Pi * radius * radius, true
+	}
+	return 0, false
+}
+
+// withRadianceEmission returns a light whose emission is radiance
+// A total_power light gets a copy of its properties with emission divided by its area and
+// power_mode removed; any other light is returned as is.
+func withRadianceEmission(light LightRequest) LightRequest {
+	if mode, _ := light.Properties["power_mode"].(string); mode != powerModeTotalPower {
+		return light
+	}
+	area, ok := lightArea(light)
+	emission, hasEmission := extractFloatArray(light.Properties, "emission", 3)
+	if !ok || !(area > 0) || !hasEmission {
+		return light // Invalid; left for validation to report
+	}
+
+	properties := make(map[string]interface{}, len(light.Properties))
+	for key, value := range light.Properties {
+		properties[key] = value
+	}
+	delete(properties, "power_mode")
+	properties["emission"] = []interface{}{emission[0] / area, emission[1] / area, emission[2] / area}
+	light.Properties = properties
+	return light
+}
+
+// validatePowerMode validates a light's optional power_mode, which only area lights take
+func validatePowerMode(errors *ValidationErrors, light LightRequest) {
+	if !hasProperty(light.Properties, "power_mode") {
+		return
+	}
+	if !isAreaLight(light.Type) {
+		*errors = append(*errors, fmt.Sprintf("%s '%s' has no area and doesn't take 'power_mode' - only area lights do", light.Type, light.ID))
+		return
+	}
+	switch mode := light.Properties["power_mode"]; mode {
+	case powerModeRadiance, powerModeTotalPower:
+	default:
+		*errors = append(*errors, fmt.Sprintf("%s '%s' power_mode must be '%s' or '%s', got %v", light.Type, light.ID, powerModeRadiance, powerModeTotalPower, mode))
+	}
+}
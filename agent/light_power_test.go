@@ -0,0 +1,102 @@
+package agent
+
+import (
+	"math"
+	"testing"
+)
+
+func TestAreaLightPowerMode(t *testing.T) {
+	quadLight := func(side float64, mode string) LightRequest {
+		props := map[string]interface{}{
+			"corner":   []interface{}{0.0, 3.0, 0.0},
+			"u":        []interface{}{side, 0.0, 0.0},
+			"v":        []interface{}{0.0, 0.0, side},
+			"emission": []interface{}{16.0, 16.0, 16.0},
+		}
+		if mode != "" {
+			props["power_mode"] = mode
+		}
+		return LightRequest{ID: "panel", Type: "area_quad_light", Properties: props}
+	}
+	// totalPower adds up the shadow catcher's samples, which weigh radiance by area
+	totalPower := func(light LightRequest) float64 {
+		total := 0.0
+		for _, sample := range lightSamples(light) {
+			total += sample.weight
+		}
+		return total
+	}
+
+	t.Run("equal total power regardless of size", func(t *testing.T) {
+		small, large := quadLight(1, powerModeTotalPower), quadLight(4, powerModeTotalPower)
+		if got := vec3Property(withRadianceEmission(small).Properties, "emission", vec3{}); got != (vec3{16, 16, 16}) {
+			t.Errorf("Expected a 1x1 light to keep its emission as radiance, got %v", got)
+		}
+		if got := vec3Property(withRadianceEmission(large).Properties, "emission", vec3{}); got != (vec3{1, 1, 1}) {
+			t.Errorf("Expected a 4x4 light to spread its emission over 16 units of area, got %v", got)
+		}
+		if smallPower, largePower := totalPower(small), totalPower(large); math.Abs(smallPower-largePower) > 1e-9 {
+			t.Errorf("Expected equal total power, got %v and %v", smallPower, largePower)
+		}
+		if _, ok := small.Properties["power_mode"]; !ok {
+			t.Error("Expected the light's own properties to be left alone")
+		}
+	})
+
+	t.Run("radiance scales with size", func(t *testing.T) {
+		for _, mode := range []string{"", powerModeRadiance} {
+			small, large := quadLight(1, mode), quadLight(4, mode)
+			if got := totalPower(large) / totalPower(small); math.Abs(got-16) > 1e-9 {
+				t.Errorf("power_mode %q: expected the 4x4 light to give off 16 times the power, got %v", mode, got)
+			}
+		}
+	})
+
+	t.Run("area per light type", func(t *testing.T) {
+		sphere := LightRequest{Type: "area_sphere_light", Properties: map[string]interface{}{"radius": 2.0}}
+		disc := LightRequest{Type: "area_disc_spot_light", Properties: map[string]interface{}{"radius": 2.0}}
+		rect := LightRequest{Type: "area_rect_light", Properties: map[string]interface{}{"size": []interface{}{2.0, 3.0}, "two_sided": true}}
+		tests := map[string]struct {
+			light LightRequest
+			area  float64
+		}{
+			"sphere":         {sphere, 16 * math.Pi},
+			"disc":           {disc, 4 * math.Pi},
+			"two-sided rect": {rect, 12},
+		}
+		for name, tt := range tests {
+			if area, ok := lightArea(tt.light); !ok || math.Abs(area-tt.area) > 1e-9 {
+				t.Errorf("%s: expected area %v, got %v (ok=%v)", name, tt.area, area, ok)
+			}
+		}
+		if _, ok := lightArea(LightRequest{Type: "point_light", Properties: map[string]interface{}{}}); ok {
+			t.Error("Expected a point light to have no area")
+		}
+	})
+
+	t.Run("rect lights are divided once", func(t *testing.T) {
+		rect := LightRequest{ID: "panel", Type: "area_rect_light", Properties: map[string]interface{}{
+			"center": []interface{}{0.0, 3.0, 0.0}, "size": []interface{}{2.0, 2.0}, "normal": []interface{}{0.0, -1.0, 0.0},
+			"emission": []interface{}{8.0, 8.0, 8.0}, "power_mode": powerModeTotalPower,
+		}}
+		quad := withRadianceEmission(mustRectLightAsQuad(t, withRadianceEmission(rect)))
+		if got := vec3Property(quad.Properties, "emission", vec3{}); got != (vec3{2, 2, 2}) {
+			t.Errorf("Expected radiance 2 from 8 units of power over 4 units of area, got %v", got)
+		}
+	})
+
+	t.Run("validation", func(t *testing.T) {
+		if err := validateLightProperties(quadLight(1, powerModeTotalPower)); err != nil {
+			t.Errorf("Expected total_power to be valid, got %v", err)
+		}
+		if err := validateLightProperties(quadLight(1, "watts")); err == nil {
+			t.Error("Expected an unknown power_mode to be rejected")
+		}
+		point := LightRequest{ID: "bulb", Type: "point_light", Properties: map[string]interface{}{
+			"center": []interface{}{0.0, 3.0, 0.0}, "emission": []interface{}{1.0, 1.0, 1.0}, "power_mode": powerModeTotalPower,
+		}}
+		if err := validateLightProperties(point); err == nil {
+			t.Error("Expected power_mode on a point light to be rejected")
+		}
+	})
+}
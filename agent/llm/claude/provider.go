@@ -2,6 +2,7 @@ package claude
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 
@@ -60,7 +61,12 @@ func (p *Provider) GenerateContent(ctx context.Context, req *llm.GenerateRequest
 	// Call Claude API
 	resp, err := p.client.Messages.New(ctx, params)
 	if err != nil {
-		return nil, fmt.Errorf("Claude API error: %w", err)
+		err = fmt.Errorf("Claude API error: %w", err)
+		var apiErr *anthropic.Error
+		if errors.As(err, &apiErr) {
+			return nil, &llm.APIError{StatusCode: apiErr.StatusCode, Err: err}
+		}
+		return nil, err
 	}
 
 	// Convert response to internal format
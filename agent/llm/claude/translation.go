@@ -21,6 +21,10 @@ func ToInternalResponse(resp *anthropic.Message) (*llm.Response, error) {
 	return &llm.Response{
 		Parts:      parts,
 		StopReason: string(resp.StopReason),
+		Usage: &llm.Usage{
+			InputTokens:  int(resp.Usage.InputTokens),
+			OutputTokens: int(resp.Usage.OutputTokens),
+		},
 	}, nil
 }
 
@@ -0,0 +1,166 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// FallbackProvider serves each request from the first of an ordered list of providers that
+// succeeds. When a provider fails with a retryable error, such as a rate limit or an outage,
+// the same request is sent to the next one.
+//
+// Providers serve different models, so each provider is asked for the request's model if it
+// lists it, or otherwise for the first model it lists.
+type FallbackProvider struct {
+	providers []LLMProvider
+}
+
+// NewFallbackProvider creates a provider that tries providers in order
+func NewFallbackProvider(providers ...LLMProvider) (*FallbackProvider, error) {
+	if len(providers) == 0 {
+		return nil, fmt.Errorf("fallback provider needs at least one provider")
+	}
+	return &FallbackProvider{providers: providers}, nil
+}
+
+// FallbackHandler is told when a provider failed and the request is being retried with the next
+type FallbackHandler func(from, to string, err error)
+
+type fallbackHandlerKey struct{}
+
+// WithFallbackHandler returns a context that reports fallbacks during GenerateContent to handler
+func WithFallbackHandler(ctx context.Context, handler FallbackHandler) context.Context {
+	return context.WithValue(ctx, fallbackHandlerKey{}, handler)
+}
+
+// ErrTimeout reports a model call that took longer than the caller's per-call timeout
+// It is distinct from the caller's own context ending, so it can be retried.
+var ErrTimeout = errors.New("model call timed out")
+
+// APIError is an error response from a provider's API, carrying its HTTP status code so
+// failures can be told apart without knowing which SDK produced them
+type APIError struct {
+	StatusCode int
+	Err        error
+}
+
+func (e *APIError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// IsRetryable reports whether a failed request might succeed with another provider or attempt
+// Rate limits, server errors (5xx), request timeouts, network errors and calls that hit the
+// per-call timeout are worth trying again. Cancellation and deadlines are final, as is any
+// other failure, such as an invalid request or a rejected API key, which would fail the same
+// way everywhere.
+func IsRetryable(err error) bool {
+	if errors.Is(err, ErrTimeout) {
+		return true
+	}
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusTooManyRequests ||
+			apiErr.StatusCode == http.StatusRequestTimeout ||
+			apiErr.StatusCode >= http.StatusInternalServerError
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// GenerateContent sends the request to each provider in turn until one succeeds
+// If every provider fails, the last provider's error is returned.
+func (f *FallbackProvider) GenerateContent(ctx context.Context, req *GenerateRequest) (*Response, error) {
+	handler, _ := ctx.Value(fallbackHandlerKey{}).(FallbackHandler)
+
+	var err error
+	for i, provider := range f.providers {
+		attempt := *req
+		attempt.Model = modelFor(provider, req.Model)
+
+		var response *Response
+		if response, err = provider.GenerateContent(ctx, &attempt); err == nil {
+			return response, nil
+		}
+		if !IsRetryable(err) || ctx.Err() != nil {
+			return nil, err
+		}
+		if i+1 < len(f.providers) && handler != nil {
+			handler(provider.Name(), f.providers[i+1].Name(), err)
+		}
+	}
+	if len(f.providers) > 1 {
+		return nil, fmt.Errorf("all %d providers failed, last error: %w", len(f.providers), err)
+	}
+	return nil, err
+}
+
+// modelFor returns the model to request from provider: model itself if the provider serves
+// it, otherwise the provider's first model
+func modelFor(provider LLMProvider, model string) string {
+	models := provider.ListModels()
+	for _, m := range models {
+		if m.ID == model {
+			return model
+		}
+	}
+	if len(models) > 0 {
+		return models[0].ID
+	}
+	return model
+}
+
+// ListModels returns every model served by any of the providers, in provider order
+func (f *FallbackProvider) ListModels() []ModelInfo {
+	seen := make(map[string]bool)
+	var models []ModelInfo
+	for _, provider := range f.providers {
+		for _, model := range provider.ListModels() {
+			if !seen[model.ID] {
+				seen[model.ID] = true
+				models = append(models, model)
+			}
+		}
+	}
+	return models
+}
+
+// Name returns the provider names in fallback order, e.g. "fallback(google,anthropic)"
+func (f *FallbackProvider) Name() string {
+	names := make([]string, len(f.providers))
+	for i, provider := range f.providers {
+		names[i] = provider.Name()
+	}
+	return "fallback(" + strings.Join(names, ",") + ")"
+}
+
+// SupportsVision is true only if every provider supports vision, since any of them may answer
+func (f *FallbackProvider) SupportsVision() bool {
+	for _, provider := range f.providers {
+		if !provider.SupportsVision() {
+			return false
+		}
+	}
+	return true
+}
+
+// SupportsThinking is true only if every provider supports extended reasoning
+func (f *FallbackProvider) SupportsThinking() bool {
+	for _, provider := range f.providers {
+		if !provider.SupportsThinking() {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,190 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+// failingProvider fails every request with err and records the models it was asked for
+type failingProvider struct {
+	MockProvider
+	err       error
+	requested []string
+}
+
+func (f *failingProvider) GenerateContent(ctx context.Context, req *GenerateRequest) (*Response, error) {
+	f.requested = append(f.requested, req.Model)
+	return nil, f.err
+}
+
+// recordingProvider answers every request and records the models it was asked for
+type recordingProvider struct {
+	MockProvider
+	vision    bool
+	requested []string
+}
+
+func (r *recordingProvider) GenerateContent(ctx context.Context, req *GenerateRequest) (*Response, error) {
+	r.requested = append(r.requested, req.Model)
+	return r.MockProvider.GenerateContent(ctx, req)
+}
+
+func (r *recordingProvider) SupportsVision() bool {
+	return r.vision
+}
+
+func newFallbackPair(primaryErr error) (*failingProvider, *recordingProvider, *FallbackProvider) {
+	primary := &failingProvider{
+		MockProvider: MockProvider{name: "primary", models: []ModelInfo{{ID: "primary-model"}, {ID: "shared-model"}}},
+		err:          primaryErr,
+	}
+	secondary := &recordingProvider{
+		MockProvider: MockProvider{name: "secondary", models: []ModelInfo{{ID: "secondary-model"}, {ID: "shared-model"}}},
+	}
+	fallback, err := NewFallbackProvider(primary, secondary)
+	if err != nil {
+		panic(err)
+	}
+	return primary, secondary, fallback
+}
+
+func TestFallbackProviderFallsBack(t *testing.T) {
+	primary, secondary, fallback := newFallbackPair(&APIError{StatusCode: 429, Err: errors.New("429 rate limited")})
+
+	var fallbacks []string
+	ctx := WithFallbackHandler(context.Background(), func(from, to string, err error) {
+		fallbacks = append(fallbacks, from+"->"+to+": "+err.Error())
+	})
+
+	response, err := fallback.GenerateContent(ctx, &GenerateRequest{Model: "primary-model"})
+	if err != nil {
+		t.Fatalf("Expected the secondary provider to answer, got %v", err)
+	}
+	if response.Parts[0].Text != "mock response" {
+		t.Errorf("Expected the secondary provider's response, got %+v", response)
+	}
+	if len(fallbacks) != 1 || fallbacks[0] != "primary->secondary: 429 rate limited" {
+		t.Errorf("Expected one fallback to be reported, got %v", fallbacks)
+	}
+
+	// The secondary doesn't serve primary-model, so it's asked for its own first model
+	if len(primary.requested) != 1 || primary.requested[0] != "primary-model" {
+		t.Errorf("Expected the primary to be asked for primary-model, got %v", primary.requested)
+	}
+	if len(secondary.requested) != 1 || secondary.requested[0] != "secondary-model" {
+		t.Errorf("Expected the secondary to be asked for secondary-model, got %v", secondary.requested)
+	}
+
+	// A model both serve is kept
+	if _, err := fallback.GenerateContent(context.Background(), &GenerateRequest{Model: "shared-model"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if last := secondary.requested[len(secondary.requested)-1]; last != "shared-model" {
+		t.Errorf("Expected the secondary to be asked for shared-model, got %s", last)
+	}
+}
+
+func TestFallbackProviderStopsOnCancellation(t *testing.T) {
+	_, secondary, fallback := newFallbackPair(context.Canceled)
+
+	_, err := fallback.GenerateContent(context.Background(), &GenerateRequest{Model: "primary-model"})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the cancellation to be returned, got %v", err)
+	}
+	if len(secondary.requested) != 0 {
+		t.Errorf("Expected no fallback after cancellation, got requests %v", secondary.requested)
+	}
+}
+
+func TestFallbackProviderStopsOnFinalError(t *testing.T) {
+	invalid := &APIError{StatusCode: 400, Err: errors.New("400 invalid request")}
+	_, secondary, fallback := newFallbackPair(invalid)
+
+	_, err := fallback.GenerateContent(context.Background(), &GenerateRequest{Model: "primary-model"})
+	if !errors.Is(err, invalid) {
+		t.Errorf("Expected the invalid request error to be returned, got %v", err)
+	}
+	if len(secondary.requested) != 0 {
+		t.Errorf("Expected an invalid request not to be sent to the next provider, got requests %v", secondary.requested)
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"rate limited", &APIError{StatusCode: 429, Err: errors.New("rate limited")}, true},
+		{"server error", fmt.Errorf("wrapped: %w", &APIError{StatusCode: 500, Err: errors.New("internal")}), true},
+		{"overloaded", &APIError{StatusCode: 529, Err: errors.New("overloaded")}, true},
+		{"request timeout", &APIError{StatusCode: 408, Err: errors.New("timeout")}, true},
+		{"network error", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, true},
+		{"connection dropped", fmt.Errorf("reading response: %w", io.ErrUnexpectedEOF), true},
+		{"call timed out", fmt.Errorf("%w after 1m", ErrTimeout), true},
+		{"invalid request", &APIError{StatusCode: 400, Err: errors.New("invalid")}, false},
+		{"bad API key", &APIError{StatusCode: 401, Err: errors.New("unauthorized")}, false},
+		{"forbidden", &APIError{StatusCode: 403, Err: errors.New("forbidden")}, false},
+		{"unclassified", errors.New("malformed response"), false},
+		{"cancelled", context.Canceled, false},
+		{"deadline", fmt.Errorf("call: %w", context.DeadlineExceeded), false},
+		{"nil", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRetryable(tt.err); got != tt.want {
+				t.Errorf("IsRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFallbackProviderAllFail(t *testing.T) {
+	primaryErr := &APIError{StatusCode: 503, Err: errors.New("primary down")}
+	secondaryErr := &APIError{StatusCode: 500, Err: errors.New("secondary down")}
+	primary := &failingProvider{MockProvider: MockProvider{name: "primary"}, err: primaryErr}
+	secondary := &failingProvider{MockProvider: MockProvider{name: "secondary"}, err: secondaryErr}
+	fallback, err := NewFallbackProvider(primary, secondary)
+	if err != nil {
+		t.Fatalf("NewFallbackProvider() failed: %v", err)
+	}
+
+	_, err = fallback.GenerateContent(context.Background(), &GenerateRequest{Model: "any"})
+	if !errors.Is(err, secondaryErr) || !strings.Contains(err.Error(), "all 2 providers failed") {
+		t.Errorf("Expected the last provider's error, got %v", err)
+	}
+}
+
+func TestFallbackProviderCapabilities(t *testing.T) {
+	_, secondary, fallback := newFallbackPair(nil)
+
+	models := fallback.ListModels()
+	var ids []string
+	for _, model := range models {
+		ids = append(ids, model.ID)
+	}
+	if strings.Join(ids, ",") != "primary-model,shared-model,secondary-model" {
+		t.Errorf("Expected the union of both providers' models, got %v", ids)
+	}
+	if fallback.Name() != "fallback(primary,secondary)" {
+		t.Errorf("Unexpected name %q", fallback.Name())
+	}
+
+	// Capabilities are only claimed if every provider has them
+	secondary.vision = true
+	if fallback.SupportsVision() {
+		t.Error("Expected no vision support when the primary lacks it")
+	}
+	if fallback.SupportsThinking() {
+		t.Error("Expected no thinking support when neither provider has it")
+	}
+
+	if _, err := NewFallbackProvider(); err == nil {
+		t.Error("Expected an error with no providers")
+	}
+}
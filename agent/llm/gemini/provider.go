@@ -2,6 +2,7 @@ package gemini
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"regexp"
 	"strings"
@@ -46,7 +47,23 @@ func (p *Provider) GenerateContent(ctx context.Context, req *llm.GenerateRequest
 	// Convert internal format to genai format
 	genaiMessages := FromInternalMessages(messages)
 
-	// Prepare config
+	// Call Gemini API
+	resp, err := p.client.Models.GenerateContent(ctx, req.Model, genaiMessages, buildGenerateConfig(req))
+	if err != nil {
+		err = fmt.Errorf("Gemini API error: %w", err)
+		var apiErr genai.APIError
+		if errors.As(err, &apiErr) {
+			return nil, &llm.APIError{StatusCode: apiErr.Code, Err: err}
+		}
+		return nil, err
+	}
+
+	// Convert response back to internal format
+	return ToInternalResponse(resp)
+}
+
+// buildGenerateConfig converts request options into a Gemini generation config
+func buildGenerateConfig(req *llm.GenerateRequest) *genai.GenerateContentConfig {
 	config := &genai.GenerateContentConfig{}
 
 	// Add tools if provided
@@ -55,14 +72,16 @@ func (p *Provider) GenerateContent(ctx context.Context, req *llm.GenerateRequest
 		config.Tools = []*genai.Tool{{FunctionDeclarations: genaiTools}}
 	}
 
-	// Call Gemini API
-	resp, err := p.client.Models.GenerateContent(ctx, req.Model, genaiMessages, config)
-	if err != nil {
-		return nil, fmt.Errorf("Gemini API error: %w", err)
+	// Add thinking budget if provided (0 disables thinking)
+	if req.ThinkingBudget != nil {
+		budget := int32(*req.ThinkingBudget)
+		config.ThinkingConfig = &genai.ThinkingConfig{
+			IncludeThoughts: budget > 0, // Return thought parts so they can be flagged as thoughts
+			ThinkingBudget:  &budget,
+		}
 	}
 
-	// Convert response back to internal format
-	return ToInternalResponse(resp)
+	return config
 }
 
 // ListModels returns the models available from Gemini by querying the API
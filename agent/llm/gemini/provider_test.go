@@ -2,6 +2,8 @@ package gemini
 
 import (
 	"testing"
+
+	"github.com/df07/scene-llm/agent/llm"
 )
 
 func TestProvider_Name(t *testing.T) {
@@ -28,6 +30,45 @@ func TestProvider_SupportsThinking(t *testing.T) {
 	}
 }
 
+func TestBuildGenerateConfig_ThinkingBudget(t *testing.T) {
+	intPtr := func(v int) *int { return &v }
+
+	tests := []struct {
+		name            string
+		budget          *int
+		expectConfig    bool
+		expectBudget    int32
+		includeThoughts bool
+	}{
+		{name: "provider default", budget: nil, expectConfig: false},
+		{name: "thinking disabled", budget: intPtr(0), expectConfig: true, expectBudget: 0, includeThoughts: false},
+		{name: "positive budget", budget: intPtr(1024), expectConfig: true, expectBudget: 1024, includeThoughts: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := buildGenerateConfig(&llm.GenerateRequest{ThinkingBudget: tt.budget})
+
+			if !tt.expectConfig {
+				if config.ThinkingConfig != nil {
+					t.Errorf("Expected no thinking config, got %+v", config.ThinkingConfig)
+				}
+				return
+			}
+
+			if config.ThinkingConfig == nil || config.ThinkingConfig.ThinkingBudget == nil {
+				t.Fatal("Expected thinking config with a budget")
+			}
+			if *config.ThinkingConfig.ThinkingBudget != tt.expectBudget {
+				t.Errorf("Expected budget %d, got %d", tt.expectBudget, *config.ThinkingConfig.ThinkingBudget)
+			}
+			if config.ThinkingConfig.IncludeThoughts != tt.includeThoughts {
+				t.Errorf("Expected IncludeThoughts=%v, got %v", tt.includeThoughts, config.ThinkingConfig.IncludeThoughts)
+			}
+		})
+	}
+}
+
 // TestProvider_ListModels is skipped because it requires a real API call to Gemini.
 // ListModels queries the Gemini API dynamically and cannot be tested without valid credentials.
 // Integration tests with real API credentials should be run separately.
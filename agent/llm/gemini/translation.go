@@ -3,17 +3,44 @@ package gemini
 import (
 	"fmt"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/df07/scene-llm/agent/llm"
 	"google.golang.org/genai"
 )
 
+// callSeq numbers the IDs generated for function calls, keeping them unique within a response
+var callSeq atomic.Int64
+
+// newCallID generates an ID for a function call Gemini didn't give one
+func newCallID() string {
+	return fmt.Sprintf("call_%d_%d", time.Now().UnixNano(), callSeq.Add(1))
+}
+
 // ToInternalMessages converts genai.Content messages to internal llm.Message format
+// Gemini doesn't always set IDs, so a function response without one takes the ID of the
+// function call it answers: the earliest unanswered call of the same name.
 func ToInternalMessages(contents []*genai.Content) []llm.Message {
 	messages := make([]llm.Message, len(contents))
+	var pending []*llm.FunctionCall // Calls not answered yet, in order
 	for i, content := range contents {
 		messages[i] = ToInternalMessage(content)
+		for _, part := range messages[i].Parts {
+			switch {
+			case part.FunctionCall != nil:
+				pending = append(pending, part.FunctionCall)
+			case part.FunctionResp != nil:
+				resp := part.FunctionResp
+				for k, call := range pending {
+					if resp.ID == call.ID || (resp.ID == "" && resp.Name == call.Name) {
+						resp.ID = call.ID
+						pending = append(pending[:k], pending[k+1:]...)
+						break
+					}
+				}
+			}
+		}
 	}
 	return messages
 }
@@ -58,7 +85,7 @@ func ToInternalPart(part *genai.Part) llm.Part {
 		// Generate ID if not provided by Gemini SDK
 		id := part.FunctionCall.ID
 		if id == "" {
-			id = fmt.Sprintf("call_%d", time.Now().UnixNano())
+			id = newCallID()
 		}
 
 		return llm.Part{
@@ -73,16 +100,12 @@ func ToInternalPart(part *genai.Part) llm.Part {
 
 	// Function response
 	if part.FunctionResponse != nil {
-		// Generate ID if not provided
-		id := part.FunctionResponse.ID
-		if id == "" {
-			id = fmt.Sprintf("resp_%d", time.Now().UnixNano())
-		}
-
+		// Without the call it answers there's nothing to match, so a missing ID stays empty;
+		// ToInternalMessages fills it in from the conversation
 		return llm.Part{
 			Type: llm.PartTypeFunctionResponse,
 			FunctionResp: &llm.FunctionResponse{
-				ID:       id,
+				ID:       part.FunctionResponse.ID,
 				Name:     part.FunctionResponse.Name,
 				Response: part.FunctionResponse.Response,
 			},
@@ -341,8 +364,15 @@ func ToInternalResponse(resp *genai.GenerateContentResponse) (*llm.Response, err
 		stopReason = string(candidate.FinishReason)
 	}
 
-	return &llm.Response{
+	response := &llm.Response{
 		Parts:      parts,
 		StopReason: stopReason,
-	}, nil
+	}
+	if usage := resp.UsageMetadata; usage != nil {
+		response.Usage = &llm.Usage{
+			InputTokens:  int(usage.PromptTokenCount),
+			OutputTokens: int(usage.CandidatesTokenCount + usage.ThoughtsTokenCount),
+		}
+	}
+	return response, nil
 }
@@ -1,6 +1,7 @@
 package gemini
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/df07/scene-llm/agent/llm"
@@ -122,6 +123,51 @@ func TestToInternalPart_FunctionResponse(t *testing.T) {
 	}
 }
 
+func TestToInternalResponse_GeneratesDistinctCallIDs(t *testing.T) {
+	resp := &genai.GenerateContentResponse{Candidates: []*genai.Candidate{{Content: &genai.Content{
+		Role: "model",
+		Parts: []*genai.Part{
+			{FunctionCall: &genai.FunctionCall{Name: "create_shape"}},
+			{FunctionCall: &genai.FunctionCall{Name: "create_shape"}},
+		},
+	}}}}
+
+	result, err := ToInternalResponse(resp)
+	if err != nil {
+		t.Fatalf("ToInternalResponse failed: %v", err)
+	}
+	first, second := result.Parts[0].FunctionCall.ID, result.Parts[1].FunctionCall.ID
+	if first == "" || first == second {
+		t.Errorf("Expected distinct generated IDs, got '%s' and '%s'", first, second)
+	}
+}
+
+func TestToInternalMessages_MatchesResponsesToCalls(t *testing.T) {
+	contents := []*genai.Content{
+		{Role: "model", Parts: []*genai.Part{
+			{FunctionCall: &genai.FunctionCall{ID: "call-1", Name: "create_shape"}},
+			{FunctionCall: &genai.FunctionCall{ID: "call-2", Name: "create_light"}},
+			{FunctionCall: &genai.FunctionCall{ID: "call-3", Name: "create_shape"}},
+		}},
+		{Role: "user", Parts: []*genai.Part{
+			{FunctionResponse: &genai.FunctionResponse{Name: "create_light"}},
+			{FunctionResponse: &genai.FunctionResponse{ID: "call-3", Name: "create_shape"}},
+			{FunctionResponse: &genai.FunctionResponse{Name: "create_shape"}},
+		}},
+	}
+
+	messages := ToInternalMessages(contents)
+
+	var ids []string
+	for _, part := range messages[1].Parts {
+		ids = append(ids, part.FunctionResp.ID)
+	}
+	want := []string{"call-2", "call-3", "call-1"}
+	if strings.Join(ids, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected response IDs %v, got %v", want, ids)
+	}
+}
+
 func TestToInternalPart_Image(t *testing.T) {
 	imageData := []byte{0x89, 0x50, 0x4E, 0x47} // PNG header
 	genaiPart := &genai.Part{
@@ -389,6 +435,31 @@ func TestToInternalResponse(t *testing.T) {
 	if result.StopReason != "STOP" {
 		t.Errorf("Expected stop reason 'STOP', got '%s'", result.StopReason)
 	}
+	if result.Usage != nil {
+		t.Errorf("Expected no usage without usage metadata, got %+v", result.Usage)
+	}
+}
+
+func TestToInternalResponse_Usage(t *testing.T) {
+	genaiResp := &genai.GenerateContentResponse{
+		Candidates: []*genai.Candidate{
+			{Content: &genai.Content{Role: "model", Parts: []*genai.Part{{Text: "Response text"}}}},
+		},
+		UsageMetadata: &genai.GenerateContentResponseUsageMetadata{
+			PromptTokenCount:     1200,
+			CandidatesTokenCount: 80,
+			ThoughtsTokenCount:   20,
+		},
+	}
+
+	result, err := ToInternalResponse(genaiResp)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// Thinking tokens are billed as output
+	if result.Usage == nil || result.Usage.InputTokens != 1200 || result.Usage.OutputTokens != 100 {
+		t.Errorf("Expected 1200 input and 100 output tokens, got %+v", result.Usage)
+	}
 }
 
 func TestToInternalResponse_NoCandidates(t *testing.T) {
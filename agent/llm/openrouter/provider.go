@@ -2,6 +2,7 @@ package openrouter
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 
@@ -46,7 +47,15 @@ func (p *Provider) GenerateContent(ctx context.Context, req *llm.GenerateRequest
 	// Call OpenRouter API
 	resp, err := p.client.CreateChatCompletion(ctx, orRequest)
 	if err != nil {
-		return nil, fmt.Errorf("OpenRouter API error: %w", err)
+		err = fmt.Errorf("OpenRouter API error: %w", err)
+		var apiErr *openrouter.APIError
+		var reqErr *openrouter.RequestError
+		if errors.As(err, &apiErr) {
+			return nil, &llm.APIError{StatusCode: apiErr.HTTPStatusCode, Err: err}
+		} else if errors.As(err, &reqErr) {
+			return nil, &llm.APIError{StatusCode: reqErr.HTTPStatusCode, Err: err}
+		}
+		return nil, err
 	}
 
 	// Convert response back to internal format
@@ -39,8 +39,7 @@ func fromInternalMessage(msg llm.Message) openrouter.ChatCompletionMessage {
 	// Process parts to build content
 	var textParts []string
 	var toolCalls []openrouter.ToolCall
-	var hasImage bool
-	var imageURL string
+	var imageURLs []string
 
 	for _, part := range msg.Parts {
 		switch part.Type {
@@ -82,44 +81,33 @@ func fromInternalMessage(msg llm.Message) openrouter.ChatCompletionMessage {
 			}
 
 		case llm.PartTypeImage:
-			if part.ImageData != nil && !hasImage {
+			if part.ImageData != nil {
 				// Convert to base64 data URL
 				b64 := base64.StdEncoding.EncodeToString(part.ImageData.Data)
-				imageURL = fmt.Sprintf("data:%s;base64,%s", part.ImageData.MIMEType, b64)
-				hasImage = true
+				imageURLs = append(imageURLs, fmt.Sprintf("data:%s;base64,%s", part.ImageData.MIMEType, b64))
 			}
 		}
 	}
 
 	// Build content based on what we have
-	if hasImage && len(textParts) > 0 {
-		// Multimodal message with text and image
-		orMsg.Content = openrouter.Content{
-			Multi: []openrouter.ChatMessagePart{
-				{
-					Type: "text",
-					Text: joinTextParts(textParts),
-				},
-				{
-					Type: "image_url",
-					ImageURL: &openrouter.ChatMessageImageURL{
-						URL: imageURL,
-					},
-				},
-			},
+	if len(imageURLs) > 0 {
+		// Multimodal message: text (if any) followed by each image
+		var multi []openrouter.ChatMessagePart
+		if len(textParts) > 0 {
+			multi = append(multi, openrouter.ChatMessagePart{
+				Type: "text",
+				Text: joinTextParts(textParts),
+			})
 		}
-	} else if hasImage {
-		// Image only
-		orMsg.Content = openrouter.Content{
-			Multi: []openrouter.ChatMessagePart{
-				{
-					Type: "image_url",
-					ImageURL: &openrouter.ChatMessageImageURL{
-						URL: imageURL,
-					},
+		for _, url := range imageURLs {
+			multi = append(multi, openrouter.ChatMessagePart{
+				Type: "image_url",
+				ImageURL: &openrouter.ChatMessageImageURL{
+					URL: url,
 				},
-			},
+			})
 		}
+		orMsg.Content = openrouter.Content{Multi: multi}
 	} else {
 		// Text only
 		orMsg.Content = openrouter.Content{
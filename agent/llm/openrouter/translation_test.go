@@ -210,6 +210,42 @@ func TestFromInternalMessages_FunctionCall(t *testing.T) {
 	}
 }
 
+func TestFromInternalMessages_MultipleImages(t *testing.T) {
+	messages := []llm.Message{
+		{
+			Role: llm.RoleUser,
+			Parts: []llm.Part{
+				{Type: llm.PartTypeText, Text: "Compare these"},
+				{Type: llm.PartTypeImage, ImageData: &llm.ImageData{MIMEType: "image/png", Data: []byte("first")}},
+				{Type: llm.PartTypeImage, ImageData: &llm.ImageData{MIMEType: "image/jpeg", Data: []byte("second")}},
+			},
+		},
+	}
+
+	orMessages := FromInternalMessages(messages, "")
+	if len(orMessages) != 1 {
+		t.Fatalf("Expected 1 message, got %d", len(orMessages))
+	}
+
+	multi := orMessages[0].Content.Multi
+	if len(multi) != 3 {
+		t.Fatalf("Expected text part plus 2 image parts, got %d parts", len(multi))
+	}
+	if multi[0].Type != "text" || multi[0].Text != "Compare these" {
+		t.Errorf("Expected first part to be the text, got %+v", multi[0])
+	}
+	wantURLs := []string{"data:image/png;base64,Zmlyc3Q=", "data:image/jpeg;base64,c2Vjb25k"}
+	for i, want := range wantURLs {
+		part := multi[i+1]
+		if part.Type != "image_url" || part.ImageURL == nil {
+			t.Fatalf("Expected part %d to be an image, got %+v", i+1, part)
+		}
+		if part.ImageURL.URL != want {
+			t.Errorf("Image %d URL = %q, want %q", i+1, part.ImageURL.URL, want)
+		}
+	}
+}
+
 func TestJoinTextParts(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -85,10 +85,48 @@ type Schema struct {
 	Required    []string // Required property names (only for object type)
 }
 
+// JSONSchema converts the schema to a plain JSON Schema object for serialization
+func (s *Schema) JSONSchema() map[string]interface{} {
+	result := make(map[string]interface{})
+	if s == nil {
+		return result
+	}
+
+	result["type"] = string(s.Type)
+	if s.Description != "" {
+		result["description"] = s.Description
+	}
+	if s.Properties != nil {
+		props := make(map[string]interface{})
+		for name, propSchema := range s.Properties {
+			props[name] = propSchema.JSONSchema()
+		}
+		result["properties"] = props
+	}
+	if s.Items != nil {
+		result["items"] = s.Items.JSONSchema()
+	}
+	if len(s.Enum) > 0 {
+		result["enum"] = s.Enum
+	}
+	if len(s.Required) > 0 {
+		result["required"] = s.Required
+	}
+
+	return result
+}
+
 // Response represents the LLM's response to a generation request
 type Response struct {
 	Parts      []Part
 	StopReason string // "stop", "max_tokens", "tool_use", etc.
+	Usage      *Usage // Tokens used by the request, nil if the provider didn't report them
+}
+
+// Usage reports the tokens a generation request consumed
+type Usage struct {
+	InputTokens  int `json:"input_tokens"`  // Prompt tokens, including the conversation history
+	OutputTokens int `json:"output_tokens"` // Generated tokens, including any reasoning
 }
 
 // ModelInfo provides metadata about an available model
@@ -107,6 +145,10 @@ type GenerateRequest struct {
 	SystemPrompt string    // System prompt (separate from conversation)
 	Messages     []Message // Conversation history
 	Tools        []Tool    // Available tools
+
+	// ThinkingBudget limits reasoning tokens for providers that support thinking
+	// nil uses the provider default, 0 disables thinking
+	ThinkingBudget *int
 }
 
 // LLMProvider defines the interface that all LLM providers must implement
@@ -0,0 +1,41 @@
+package llm
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSchemaJSONSchema(t *testing.T) {
+	schema := &Schema{
+		Type: TypeObject,
+		Properties: map[string]*Schema{
+			"mode": {
+				Type:        TypeString,
+				Description: "Render mode",
+				Enum:        []string{"shaded", "wireframe"},
+			},
+			"center": {
+				Type:  TypeArray,
+				Items: &Schema{Type: TypeNumber},
+			},
+		},
+		Required: []string{"mode"},
+	}
+
+	data, err := json.Marshal(schema.JSONSchema())
+	if err != nil {
+		t.Fatalf("Failed to marshal schema: %v", err)
+	}
+
+	expected := `{"properties":{"center":{"items":{"type":"number"},"type":"array"},"mode":{"description":"Render mode","enum":["shaded","wireframe"],"type":"string"}},"required":["mode"],"type":"object"}`
+	if string(data) != expected {
+		t.Errorf("Unexpected JSON schema:\n got: %s\nwant: %s", data, expected)
+	}
+}
+
+func TestSchemaJSONSchemaNil(t *testing.T) {
+	var schema *Schema
+	if got := schema.JSONSchema(); len(got) != 0 {
+		t.Errorf("Expected empty map for nil schema, got %v", got)
+	}
+}
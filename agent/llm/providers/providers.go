@@ -0,0 +1,65 @@
+// Package providers builds an LLM provider registry from API keys in the environment
+// It is shared by the web server and the CLI, and lives apart from package llm because it
+// imports every provider implementation.
+package providers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/df07/scene-llm/agent/llm"
+	"github.com/df07/scene-llm/agent/llm/claude"
+	"github.com/df07/scene-llm/agent/llm/gemini"
+	"github.com/df07/scene-llm/agent/llm/openrouter"
+)
+
+// FromEnv creates a registry with a provider for each API key that is set:
+// GOOGLE_API_KEY, ANTHROPIC_API_KEY and OPENROUTER_API_KEY
+// A provider that fails to initialize is logged and skipped. It is an error if none are available.
+// When several are configured, a request that fails with a retryable error, such as a rate
+// limit or an outage, is retried with the others in the order above; see llm.Registry.SetFallback.
+func FromEnv(ctx context.Context) (*llm.Registry, error) {
+	registry := llm.NewRegistry()
+	registry.SetFallback(true)
+
+	// Try to add Gemini provider
+	if apiKey := os.Getenv("GOOGLE_API_KEY"); apiKey != "" {
+		provider, err := gemini.NewProvider(ctx, apiKey)
+		if err != nil {
+			log.Printf("Warning: Failed to initialize Gemini provider: %v", err)
+		} else {
+			registry.Add(provider)
+			log.Printf("Initialized Gemini provider")
+		}
+	}
+
+	// Try to add Claude provider
+	if os.Getenv("ANTHROPIC_API_KEY") != "" {
+		provider, err := claude.NewProvider()
+		if err != nil {
+			log.Printf("Warning: Failed to initialize Claude provider: %v", err)
+		} else {
+			registry.Add(provider)
+			log.Printf("Initialized Claude provider")
+		}
+	}
+
+	// Try to add OpenRouter provider
+	if os.Getenv("OPENROUTER_API_KEY") != "" {
+		provider, err := openrouter.NewProvider("")
+		if err != nil {
+			log.Printf("Warning: Failed to initialize OpenRouter provider: %v", err)
+		} else {
+			registry.Add(provider)
+			log.Printf("Initialized OpenRouter provider")
+		}
+	}
+
+	// Validate at least one provider is available
+	if len(registry.ListModels()) == 0 {
+		return nil, fmt.Errorf("no LLM providers available - set GOOGLE_API_KEY, ANTHROPIC_API_KEY, or OPENROUTER_API_KEY environment variable")
+	}
+	return registry, nil
+}
@@ -0,0 +1,18 @@
+package providers
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestFromEnvWithoutKeys(t *testing.T) {
+	for _, key := range []string{"GOOGLE_API_KEY", "ANTHROPIC_API_KEY", "OPENROUTER_API_KEY"} {
+		t.Setenv(key, "")
+	}
+
+	registry, err := FromEnv(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "no LLM providers available") {
+		t.Errorf("Expected an error naming the missing keys, got registry %v and error %v", registry, err)
+	}
+}
@@ -11,6 +11,8 @@ import (
 type Registry struct {
 	providers map[string]LLMProvider // provider name -> provider
 	models    map[string]string      // model ID -> provider name
+	order     []string               // provider names in the order they were added
+	fallback  bool                   // Whether GetProviderForModel chains the other providers behind the model's
 }
 
 // NewRegistry creates a new provider registry
@@ -23,6 +25,9 @@ func NewRegistry() *Registry {
 
 // Add registers a provider and indexes its models
 func (r *Registry) Add(provider LLMProvider) {
+	if _, exists := r.providers[provider.Name()]; !exists {
+		r.order = append(r.order, provider.Name())
+	}
 	r.providers[provider.Name()] = provider
 
 	// Index all models from this provider
@@ -31,14 +36,37 @@ func (r *Registry) Add(provider LLMProvider) {
 	}
 }
 
+// SetFallback turns provider fallback on or off for GetProviderForModel
+// With fallback on and more than one provider, a model's provider is wrapped in a
+// FallbackProvider that retries failed requests with the other providers in the order
+// they were added.
+func (r *Registry) SetFallback(enabled bool) {
+	r.fallback = enabled
+}
+
 // GetProviderForModel returns the provider that serves a given model
+// If fallback is on, the provider falls back to the other providers; see SetFallback.
 func (r *Registry) GetProviderForModel(modelID string) (LLMProvider, error) {
 	providerName, exists := r.models[modelID]
 	if !exists {
 		return nil, fmt.Errorf("model %s not found", modelID)
 	}
 
-	return r.providers[providerName], nil
+	provider := r.providers[providerName]
+	if !r.fallback || len(r.order) < 2 {
+		return provider, nil
+	}
+	chain := []LLMProvider{provider}
+	for _, name := range r.order {
+		if name != providerName {
+			chain = append(chain, r.providers[name])
+		}
+	}
+	fallback, err := NewFallbackProvider(chain...)
+	if err != nil {
+		return nil, err
+	}
+	return fallback, nil
 }
 
 // ListModels returns all available model IDs sorted reverse alphabetically
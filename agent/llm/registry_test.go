@@ -2,6 +2,7 @@ package llm
 
 import (
 	"context"
+	"errors"
 	"testing"
 )
 
@@ -125,3 +126,50 @@ func TestMultipleProviders(t *testing.T) {
 		t.Errorf("Expected provider2 for model3, got %s", p2.Name())
 	}
 }
+
+func TestGetProviderForModelWithFallback(t *testing.T) {
+	registry := NewRegistry()
+	primary := &failingProvider{
+		MockProvider: MockProvider{name: "primary", models: []ModelInfo{{ID: "primary-model"}}},
+		err:          &APIError{StatusCode: 503, Err: errors.New("503 overloaded")},
+	}
+	secondary := &recordingProvider{
+		MockProvider: MockProvider{name: "secondary", models: []ModelInfo{{ID: "secondary-model"}}},
+	}
+	registry.Add(primary)
+	registry.Add(secondary)
+
+	// Without fallback the model's own provider is returned
+	p, err := registry.GetProviderForModel("primary-model")
+	if err != nil || p != primary {
+		t.Fatalf("Expected the primary provider without fallback, got %v, %v", p, err)
+	}
+
+	registry.SetFallback(true)
+	p, err = registry.GetProviderForModel("primary-model")
+	if err != nil {
+		t.Fatalf("GetProviderForModel failed: %v", err)
+	}
+	if p.Name() != "fallback(primary,secondary)" {
+		t.Errorf("Expected the model's provider first, then the others in order, got %s", p.Name())
+	}
+	if _, err := p.GenerateContent(context.Background(), &GenerateRequest{Model: "primary-model"}); err != nil {
+		t.Fatalf("Expected the request to fall back to the secondary provider, got %v", err)
+	}
+	if len(primary.requested) != 1 || len(secondary.requested) != 1 || secondary.requested[0] != "secondary-model" {
+		t.Errorf("Expected one attempt on each provider, got %v and %v", primary.requested, secondary.requested)
+	}
+
+	// A model on a later provider puts that provider first
+	if p, _ := registry.GetProviderForModel("secondary-model"); p.Name() != "fallback(secondary,primary)" {
+		t.Errorf("Expected the secondary provider first, got %s", p.Name())
+	}
+
+	// A single provider needs no fallback
+	single := NewRegistry()
+	single.SetFallback(true)
+	single.Add(secondary)
+	if p, _ := single.GetProviderForModel("secondary-model"); p != secondary {
+		t.Errorf("Expected a lone provider to be returned as is, got %v", p)
+	}
+}
@@ -0,0 +1,56 @@
+package agent
+
+// materialPresets are known-good parameters for common materials, selected with the
+// material's "preset" key instead of guessing albedo, fuzz, or refractive index
+var materialPresets = map[string]map[string]interface{}{
+	"gold":    {"type": "metal", "albedo": []interface{}{1.0, 0.78, 0.34}, "fuzz": 0.05},
+	"copper":  {"type": "metal", "albedo": []interface{}{0.95, 0.64, 0.54}, "fuzz": 0.1},
+	"chrome":  {"type": "metal", "albedo": []interface{}{0.9, 0.9, 0.9}, "fuzz": 0.0},
+	"glass":   {"type": "dielectric", "refractive_index": 1.5},
+	"plastic": {"type": "lambertian", "albedo": []interface{}{0.8, 0.8, 0.8}},
+}
+
+// expandMaterialPreset replaces a material's preset with the preset's parameters
+// Fields set alongside the preset override the preset's values, e.g. {preset: 'plastic', albedo: [1,0,0]}
+// for red plastic. Presets inside mix materials are expanded too. Materials without a preset are
+// returned as is, and unknown presets are left in place for validation to report. mat is not modified.
+func expandMaterialPreset(mat map[string]interface{}) map[string]interface{} {
+	expanded := make(map[string]interface{}, len(mat))
+	if name, ok := mat["preset"].(string); ok {
+		preset, known := materialPresets[name]
+		if !known {
+			return mat
+		}
+		for key, value := range preset {
+			expanded[key] = value
+		}
+	}
+
+	for key, value := range mat {
+		if key == "preset" {
+			continue
+		}
+		if nested, ok := value.(map[string]interface{}); ok && (key == "material_a" || key == "material_b") {
+			value = expandMaterialPreset(nested)
+		}
+		expanded[key] = value
+	}
+	return expanded
+}
+
+// expandShapeMaterialPreset returns the shape with any material preset expanded
+// The shape's properties are copied rather than modified.
+func expandShapeMaterialPreset(shape ShapeRequest) ShapeRequest {
+	mat, ok := extractMaterial(shape.Properties)
+	if !ok {
+		return shape
+	}
+
+	properties := make(map[string]interface{}, len(shape.Properties))
+	for key, value := range shape.Properties {
+		properties[key] = value
+	}
+	properties["material"] = expandMaterialPreset(mat)
+	shape.Properties = properties
+	return shape
+}
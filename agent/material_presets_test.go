@@ -0,0 +1,120 @@
+package agent
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExpandMaterialPreset(t *testing.T) {
+	tests := []struct {
+		name     string
+		material map[string]interface{}
+		expected map[string]interface{}
+	}{
+		{
+			name:     "gold",
+			material: map[string]interface{}{"preset": "gold"},
+			expected: map[string]interface{}{"type": "metal", "albedo": []interface{}{1.0, 0.78, 0.34}, "fuzz": 0.05},
+		},
+		{
+			name:     "glass",
+			material: map[string]interface{}{"preset": "glass"},
+			expected: map[string]interface{}{"type": "dielectric", "refractive_index": 1.5},
+		},
+		{
+			name:     "override a field",
+			material: map[string]interface{}{"preset": "gold", "fuzz": 0.3},
+			expected: map[string]interface{}{"type": "metal", "albedo": []interface{}{1.0, 0.78, 0.34}, "fuzz": 0.3},
+		},
+		{
+			name:     "no preset",
+			material: map[string]interface{}{"type": "lambertian", "albedo": []interface{}{0.1, 0.2, 0.3}},
+			expected: map[string]interface{}{"type": "lambertian", "albedo": []interface{}{0.1, 0.2, 0.3}},
+		},
+		{
+			name:     "unknown preset is left for validation",
+			material: map[string]interface{}{"preset": "unobtainium"},
+			expected: map[string]interface{}{"preset": "unobtainium"},
+		},
+		{
+			name: "presets inside a mix",
+			material: map[string]interface{}{
+				"type":       "mix",
+				"factor":     0.5,
+				"material_a": map[string]interface{}{"preset": "copper"},
+				"material_b": map[string]interface{}{"preset": "glass"},
+			},
+			expected: map[string]interface{}{
+				"type":       "mix",
+				"factor":     0.5,
+				"material_a": map[string]interface{}{"type": "metal", "albedo": []interface{}{0.95, 0.64, 0.54}, "fuzz": 0.1},
+				"material_b": map[string]interface{}{"type": "dielectric", "refractive_index": 1.5},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := expandMaterialPreset(tt.material); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expandMaterialPreset() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestExpandMaterialPresetDoesNotModifyInput(t *testing.T) {
+	material := map[string]interface{}{"preset": "chrome", "fuzz": 0.2}
+	expandMaterialPreset(material)
+	if len(material) != 2 || material["preset"] != "chrome" {
+		t.Errorf("Expected input to be unchanged, got %v", material)
+	}
+}
+
+func TestMaterialPresetsValidate(t *testing.T) {
+	for name := range materialPresets {
+		shape := ShapeRequest{ID: "s", Type: "sphere", Properties: map[string]interface{}{
+			"center": []interface{}{0.0, 0.0, 0.0}, "radius": 1.0, "material": map[string]interface{}{"preset": name},
+		}}
+		if errors := NewSceneManager().ValidateShape(shape); len(errors) > 0 {
+			t.Errorf("preset %s: expected a valid material, got %v", name, errors)
+		}
+	}
+}
+
+func TestShapeMaterialPresets(t *testing.T) {
+	sm := NewSceneManager()
+	shape := ShapeRequest{ID: "ring", Type: "sphere", Properties: map[string]interface{}{
+		"center": []interface{}{0.0, 0.0, 0.0}, "radius": 1.0, "material": map[string]interface{}{"preset": "gold"},
+	}}
+	if err := sm.AddShapes([]ShapeRequest{shape}); err != nil {
+		t.Fatalf("AddShapes() failed: %v", err)
+	}
+
+	// The scene stores the expanded parameters, and the caller's request is left alone
+	stored, _ := extractMaterial(sm.FindShape("ring").Properties)
+	if stored["type"] != "metal" || stored["fuzz"] != 0.05 || stored["preset"] != nil {
+		t.Errorf("Expected stored material to be expanded gold, got %v", stored)
+	}
+	if requested, _ := extractMaterial(shape.Properties); requested["preset"] != "gold" {
+		t.Errorf("Expected request material to be unchanged, got %v", requested)
+	}
+
+	// Updating the material expands presets too
+	updates := map[string]interface{}{"properties": map[string]interface{}{"material": map[string]interface{}{"preset": "glass"}}}
+	if err := sm.UpdateShape("ring", updates); err != nil {
+		t.Fatalf("UpdateShape() failed: %v", err)
+	}
+	if updated, _ := extractMaterial(sm.FindShape("ring").Properties); updated["type"] != "dielectric" {
+		t.Errorf("Expected updated material to be glass, got %v", updated)
+	}
+
+	// Unknown presets are rejected by name
+	bad := ShapeRequest{ID: "bad", Type: "sphere", Properties: map[string]interface{}{
+		"center": []interface{}{0.0, 0.0, 0.0}, "radius": 1.0, "material": map[string]interface{}{"preset": "unobtainium"},
+	}}
+	err := sm.AddShapes([]ShapeRequest{bad})
+	if err == nil || !strings.Contains(err.Error(), "unknown material preset 'unobtainium'") {
+		t.Errorf("Expected unknown preset error, got %v", err)
+	}
+}
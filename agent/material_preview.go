@@ -0,0 +1,71 @@
+package agent
+
+// materialPreviewSphereID names the sphere in a material preview, so validation errors point at it
+const materialPreviewSphereID = "preview_sphere"
+
+// newMaterialPreviewScene builds a throwaway scene showing material on a unit sphere
+// The sphere rests on a gray floor under a soft overhead key light, with a dim uniform fill
+// as a neutral background, seen from slightly above. The material is validated first, so an
+// invalid material returns every problem at once and nothing is built.
+func newMaterialPreviewScene(material map[string]interface{}) (*SceneManager, error) {
+	sm := NewSceneManager()
+
+	sphere := ShapeRequest{
+		ID:   materialPreviewSphereID,
+		Type: "sphere",
+		Properties: map[string]interface{}{
+			"center":   []interface{}{0.0, 1.0, 0.0},
+			"radius":   1.0,
+			"material": material,
+		},
+	}
+	if errors := sm.ValidateShape(sphere); len(errors) > 0 {
+		return nil, ValidationErrors(errors)
+	}
+
+	floor := ShapeRequest{
+		ID:   "preview_floor",
+		Type: "quad",
+		Properties: map[string]interface{}{
+			"corner":   []interface{}{-6.0, 0.0, -6.0},
+			"u":        []interface{}{0.0, 0.0, 12.0},
+			"v":        []interface{}{12.0, 0.0, 0.0},
+			"material": map[string]interface{}{"type": "lambertian", "albedo": []interface{}{0.5, 0.5, 0.5}},
+		},
+	}
+	if err := sm.AddShapes([]ShapeRequest{sphere, floor}); err != nil {
+		return nil, err
+	}
+
+	// A softbox above and in front of the sphere, facing down (u × v points to -Y)
+	key := LightRequest{
+		ID:   "preview_key",
+		Type: "area_quad_light",
+		Properties: map[string]interface{}{
+			"corner":   []interface{}{-1.5, 5.0, -0.5},
+			"u":        []interface{}{3.0, 0.0, 0.0},
+			"v":        []interface{}{0.0, 0.0, 3.0},
+			"emission": []interface{}{4.0, 4.0, 4.0},
+		},
+	}
+	if err := sm.AddLights([]LightRequest{key}); err != nil {
+		return nil, err
+	}
+	if err := sm.SetEnvironmentLighting("uniform", nil, nil, []float64{0.3, 0.3, 0.3}, nil, 0, 1, false); err != nil {
+		return nil, err
+	}
+
+	camera := CameraInfo{Center: []float64{0, 2, 5}, LookAt: []float64{0, 0.9, 0}, VFov: 35, AspectRatio: 1}
+	if err := sm.SetCamera(camera); err != nil {
+		return nil, err
+	}
+	return sm, nil
+}
+
+// materialPreviewSettings renders a small square swatch
+// It keeps draft's bounce depth so glass and mirrors read correctly, and denoises the
+// few samples rather than spending time on more.
+func materialPreviewSettings() RenderSettings {
+	return RenderSettings{Width: 160, Height: 160, SamplesPerPixel: 16, MaxDepth: 8,
+		AdaptiveMinSamples: defaultAdaptiveMinSamples, AdaptiveThreshold: defaultAdaptiveThreshold, Denoise: true}
+}
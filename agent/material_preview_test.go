@@ -0,0 +1,76 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/df07/scene-llm/agent/llm"
+)
+
+func TestPreviewMaterial(t *testing.T) {
+	events := make(chan AgentEvent, 100)
+	agent := NewWithProvider(events, &MockProvider{}, "mock-model")
+
+	req := parseToolRequestFromFunctionCall(&llm.FunctionCall{
+		Name:      "preview_material",
+		Arguments: map[string]interface{}{"material": map[string]interface{}{"preset": "gold"}},
+	})
+	result := agent.executeToolRequests(context.Background(), req, "test_call_1")
+	if !result.Success {
+		t.Fatalf("Expected preview_material to succeed, got errors: %v", result.Errors)
+	}
+
+	preview := req.(*PreviewMaterialRequest)
+	img, err := png.Decode(bytes.NewReader(preview.RenderedImage))
+	if err != nil {
+		t.Fatalf("Expected a PNG swatch, got decode error: %v", err)
+	}
+	settings := materialPreviewSettings()
+	if img.Bounds().Dx() != settings.Width || img.Bounds().Dy() != settings.Height {
+		t.Errorf("Expected a %dx%d swatch, got %v", settings.Width, settings.Height, img.Bounds())
+	}
+
+	// The preset is reported expanded, as it was rendered
+	material := result.Result.(map[string]interface{})["material"].(map[string]interface{})
+	if material["type"] != "metal" {
+		t.Errorf("Expected the gold preset to expand to a metal material, got %v", material)
+	}
+
+	if len(agent.sceneManager.state.Shapes) != 0 || len(agent.sceneManager.state.Lights) != 0 {
+		t.Errorf("Expected the scene to be untouched, got %d shapes and %d lights",
+			len(agent.sceneManager.state.Shapes), len(agent.sceneManager.state.Lights))
+	}
+}
+
+func TestPreviewMaterialValidatesFirst(t *testing.T) {
+	events := make(chan AgentEvent, 100)
+	agent := NewWithProvider(events, &MockProvider{}, "mock-model")
+
+	tests := []struct {
+		name     string
+		args     map[string]interface{}
+		expected string
+	}{
+		{"missing material", map[string]interface{}{}, "requires a material"},
+		{"unknown type", map[string]interface{}{"material": map[string]interface{}{"type": "velvet"}}, "velvet"},
+		{"shadow catcher", map[string]interface{}{"material": map[string]interface{}{"type": "shadow_catcher"}}, "shadow_catcher"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := parseToolRequestFromFunctionCall(&llm.FunctionCall{Name: "preview_material", Arguments: tt.args})
+			result := agent.executeToolRequests(context.Background(), req, "test_call_1")
+			if result.Success {
+				t.Fatal("Expected preview_material to fail")
+			}
+			if !strings.Contains(strings.Join(result.Errors, "; "), tt.expected) {
+				t.Errorf("Expected an error mentioning %q, got %v", tt.expected, result.Errors)
+			}
+			if req.(*PreviewMaterialRequest).RenderedImage != nil {
+				t.Error("Expected no image for an invalid material")
+			}
+		})
+	}
+}
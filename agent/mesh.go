@@ -0,0 +1,155 @@
+package agent
+
+import (
+	"math"
+
+	"github.com/df07/go-progressive-raytracer/pkg/core"
+	"github.com/df07/go-progressive-raytracer/pkg/geometry"
+	"github.com/df07/go-progressive-raytracer/pkg/material"
+)
+
+// triangleMesh is a shape the raytracer has no primitive for, tessellated into triangles
+// Each group of three face indices is one triangle, wound counter-clockwise seen from outside.
+// Curved meshes give a unit normal per vertex so the raytracer shades them smoothly; flat-faced
+// meshes leave normals nil and are shaded by their face normals.
+type triangleMesh struct {
+	vertices []vec3
+	faces    []int
+	normals  []vec3
+}
+
+// toRaytracer converts the mesh to a raytracer shape with a single material
+func (m triangleMesh) toRaytracer(mat material.Material) geometry.Shape {
+	vertices := make([]core.Vec3, len(m.vertices))
+	for i, v := range m.vertices {
+		vertices[i] = core.NewVec3(v[0], v[1], v[2])
+	}
+	var options *geometry.TriangleMeshOptions
+	if m.normals != nil {
+		normals := make([]core.Vec3, len(m.normals))
+		for i, n := range m.normals {
+			normals[i] = core.NewVec3(n[0], n[1], n[2])
+		}
+		options = &geometry.TriangleMeshOptions{Normals: normals}
+	}
+	return geometry.NewTriangleMesh(vertices, m.faces, mat, options)
+}
+
+// Ellipsoids are tessellated into slices around their Y axis and stacks from pole to pole
+const (
+	ellipsoidSlices = 32
+	ellipsoidStacks = 16
+
+	// ellipsoidTriangles is the triangle count of a tessellated ellipsoid: one per slice in
+	// each polar cap, and two per slice in every band between them
+	ellipsoidTriangles = 2 * ellipsoidSlices * (ellipsoidStacks - 1)
+)
+
+// ellipsoidMesh tessellates an axis-aligned ellipsoid, a unit sphere scaled by radii
+// The raytracer has no instance or transform shape to scale a sphere non-uniformly (see
+// specs/shapes.md), so ellipsoids are rendered as meshes.
+// Each vertex's normal is the gradient of the implicit surface, (p - center) / radii², so the
+// facets are shaded as the smooth surface they approximate.
+func ellipsoidMesh(center, radii vec3) triangleMesh {
+	point := func(stack, slice int) vec3 {
+		theta := math.Pi * float64(stack) / ellipsoidStacks // Angle from the +Y pole
+		phi := 2 * math.Pi * float64(slice) / ellipsoidSlices
+		sinTheta := math.Sin(theta)
+		return center.add(vec3{
+			radii[0] * sinTheta * math.Cos(phi),
+			radii[1] * math.Cos(theta),
+			radii[2] * sinTheta * math.Sin(phi),
+		})
+	}
+
+	// The two poles come first, then a ring of vertices at each boundary between stacks
+	const top, bottom = 0, 1
+	mesh := triangleMesh{vertices: []vec3{point(0, 0), point(ellipsoidStacks, 0)}}
+	for stack := 1; stack < ellipsoidStacks; stack++ {
+		for slice := 0; slice < ellipsoidSlices; slice++ {
+			mesh.vertices = append(mesh.vertices, point(stack, slice))
+		}
+	}
+	ring := func(stack, slice int) int {
+		return 2 + (stack-1)*ellipsoidSlices + slice%ellipsoidSlices
+	}
+
+	lastRing := ellipsoidStacks - 1
+	for slice := 0; slice < ellipsoidSlices; slice++ {
+		mesh.faces = append(mesh.faces, top, ring(1, slice+1), ring(1, slice))
+		for stack := 1; stack < lastRing; stack++ {
+			a, b := ring(stack, slice), ring(stack, slice+1)
+			c, d := ring(stack+1, slice), ring(stack+1, slice+1)
+			mesh.faces = append(mesh.faces, a, d, c, a, b, d)
+		}
+		mesh.faces = append(mesh.faces, bottom, ring(lastRing, slice), ring(lastRing, slice+1))
+	}
+
+	mesh.normals = make([]vec3, len(mesh.vertices))
+	for i, v := range mesh.vertices {
+		p := v.sub(center)
+		mesh.normals[i] = vec3{p[0] / (radii[0] * radii[0]), p[1] / (radii[1] * radii[1]), p[2] / (radii[2] * radii[2])}.normalize()
+	}
+	return mesh
+}
+
+// pyramidTriangles is the triangle count of a pyramid: two for its square base and one per side
+const pyramidTriangles = 6
+
+// pyramidVertices returns a pyramid's four base corners, counter-clockwise seen from above,
+// followed by its apex. center is the middle of the base, which lies flat in the XZ plane.
+func pyramidVertices(center vec3, width, depth, height float64) []vec3 {
+	w, d := width/2, depth/2
+	return []vec3{
+		center.add(vec3{-w, 0, d}),
+		center.add(vec3{w, 0, d}),
+		center.add(vec3{w, 0, -d}),
+		center.add(vec3{-w, 0, -d}),
+		center.add(vec3{0, height, 0}),
+	}
+}
+
+// pyramidMesh tessellates a square-based pyramid into its base and four triangular sides
+func pyramidMesh(center vec3, width, depth, height float64) triangleMesh {
+	const apex = 4
+	return triangleMesh{
+		vertices: pyramidVertices(center, width, depth, height),
+		faces: []int{
+			0, 2, 1, 0, 3, 2, // Base, facing down
+			0, 1, apex,
+			1, 2, apex,
+			2, 3, apex,
+			3, 0, apex,
+		},
+	}
+}
+
+// intersect finds the nearest intersection of a ray with the mesh (Möller–Trumbore)
+// dir must be normalized. Normals face the side the triangle is wound toward.
+func (m triangleMesh) intersect(origin, dir vec3) (surfaceHit, bool) {
+	var best surfaceHit
+	found := false
+	for i := 0; i+2 < len(m.faces); i += 3 {
+		a, b, c := m.vertices[m.faces[i]], m.vertices[m.faces[i+1]], m.vertices[m.faces[i+2]]
+		e1, e2 := b.sub(a), c.sub(a)
+		p := dir.cross(e2)
+		det := e1.dot(p)
+		if math.Abs(det) < 1e-12 {
+			continue // Parallel to the triangle
+		}
+		s := origin.sub(a)
+		u := s.dot(p) / det
+		if u < 0 || u > 1 {
+			continue
+		}
+		q := s.cross(e1)
+		v := dir.dot(q) / det
+		if v < 0 || u+v > 1 {
+			continue
+		}
+		if t := e2.dot(q) / det; t > hitEpsilon {
+			best, found = closer(best, found, surfaceHit{t: t, normal: e1.cross(e2).normalize()}, true)
+		}
+	}
+	return best, found
+}
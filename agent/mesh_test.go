@@ -0,0 +1,89 @@
+package agent
+
+import (
+	"math"
+	"testing"
+)
+
+// checkOutwardWinding fails if any triangle of a convex mesh faces toward inside, a point within it
+func checkOutwardWinding(t *testing.T, mesh triangleMesh, inside vec3) {
+	t.Helper()
+	for i := 0; i < len(mesh.faces); i += 3 {
+		a, b, c := mesh.vertices[mesh.faces[i]], mesh.vertices[mesh.faces[i+1]], mesh.vertices[mesh.faces[i+2]]
+		normal := b.sub(a).cross(c.sub(a))
+		centroid := a.add(b).add(c).scale(1.0 / 3)
+		if normal.dot(centroid.sub(inside)) <= 0 {
+			t.Fatalf("Triangle %d faces inward", i/3)
+		}
+	}
+}
+
+func TestEllipsoidMesh(t *testing.T) {
+	center := vec3{1, 2, 3}
+	radii := vec3{2, 1, 0.5}
+	mesh := ellipsoidMesh(center, radii)
+
+	if len(mesh.faces) != 3*ellipsoidTriangles {
+		t.Fatalf("Expected %d triangles, got %d", ellipsoidTriangles, len(mesh.faces)/3)
+	}
+
+	// Every vertex lies on the ellipsoid's surface
+	for i, v := range mesh.vertices {
+		p := v.sub(center)
+		if f := math.Pow(p[0]/radii[0], 2) + math.Pow(p[1]/radii[1], 2) + math.Pow(p[2]/radii[2], 2); math.Abs(f-1) > 1e-9 {
+			t.Fatalf("Vertex %d %v is off the surface", i, v)
+		}
+	}
+
+	checkOutwardWinding(t, mesh, center)
+
+	// Every vertex has a unit normal facing outward; at the end of an axis it is that axis
+	if len(mesh.normals) != len(mesh.vertices) {
+		t.Fatalf("Expected %d normals, got %d", len(mesh.vertices), len(mesh.normals))
+	}
+	for i, n := range mesh.normals {
+		if math.Abs(n.length()-1) > 1e-9 || n.dot(mesh.vertices[i].sub(center)) <= 0 {
+			t.Fatalf("Normal %d %v is not a unit vector facing outward", i, n)
+		}
+	}
+	if top := mesh.normals[0]; top.sub(vec3{0, 1, 0}).length() > 1e-9 {
+		t.Errorf("Expected the top pole's normal to point straight up, got %v", top)
+	}
+
+	// Away from the axes, the normal follows the surface rather than the direction from the center.
+	// On a flattened ellipsoid it leans further toward the short axis.
+	for i, v := range mesh.vertices {
+		p := v.sub(center)
+		if math.Abs(p[0]) > 0.5 && math.Abs(p[2]) > 0.1 {
+			n, radial := mesh.normals[i], p.normalize()
+			if math.Abs(n[2]) <= math.Abs(radial[2]) {
+				t.Errorf("Expected normal %d %v to lean more toward z than the radial direction %v", i, n, radial)
+			}
+			break
+		}
+	}
+}
+
+func TestPyramidMesh(t *testing.T) {
+	center := vec3{1, 0, -2}
+	mesh := pyramidMesh(center, 4, 2, 3)
+
+	if len(mesh.faces) != 3*pyramidTriangles {
+		t.Fatalf("Expected %d triangles, got %d", pyramidTriangles, len(mesh.faces)/3)
+	}
+	if apex := mesh.vertices[4]; apex != (vec3{1, 3, -2}) {
+		t.Errorf("Expected the apex straight above the center, got %v", apex)
+	}
+	checkOutwardWinding(t, mesh, center.add(vec3{0, 1, 0}))
+
+	// A ray straight down hits the sloped side, not the base beneath it
+	hit, ok := mesh.intersect(vec3{1.5, 10, -2}, vec3{0, -1, 0})
+	if !ok || math.Abs(hit.t-(10-2.25)) > 1e-9 || hit.normal[1] <= 0 {
+		t.Errorf("Expected to hit the side at y=2.25 with an upward normal, got %+v (hit=%v)", hit, ok)
+	}
+
+	// Rays beside the base miss
+	if _, ok := mesh.intersect(vec3{3.5, 10, -2}, vec3{0, -1, 0}); ok {
+		t.Error("Expected a ray beside the pyramid to miss")
+	}
+}
@@ -0,0 +1,290 @@
+package agent
+
+import (
+	"fmt"
+	"math"
+)
+
+// ShapesContainingPoint returns the IDs of the solid shapes that contain p, in scene order
+// Points on a shape's surface count as inside. Flat shapes (quads and discs) have no volume
+// and never contain a point. Cylinders and cones count as solid whether or not they're capped.
+func (sm *SceneManager) ShapesContainingPoint(p [3]float64) []string {
+	ids := []string{}
+	for _, shape := range sm.state.Shapes {
+		if shapeContains(shape, vec3(p)) {
+			ids = append(ids, shape.ID)
+		}
+	}
+	return ids
+}
+
+// shapeContains reports whether p is inside or on the surface of a solid shape
+func shapeContains(shape ShapeRequest, p vec3) bool {
+	props := shape.Properties
+	switch shape.Type {
+	case "sphere":
+		center := vec3Property(props, "center", vec3{})
+		radius, _ := extractFloat(props, "radius")
+		return p.sub(center).length() <= radius
+
+	case "ellipsoid":
+		center := vec3Property(props, "center", vec3{})
+		radii := vec3Property(props, "radii", vec3{1, 1, 1})
+		d := p.sub(center)
+		unit := vec3{d[0] / radii[0], d[1] / radii[1], d[2] / radii[2]}
+		return unit.dot(unit) <= 1
+
+	case "box":
+		center := vec3Property(props, "center", vec3{})
+		half := vec3Property(props, "dimensions", vec3{}).scale(0.5)
+		rotation := vec3Property(props, "rotation", vec3{})
+		local := p.sub(center).unrotateXYZ(rotation)
+		for axis := 0; axis < 3; axis++ {
+			if math.Abs(local[axis]) > half[axis] {
+				return false
+			}
+		}
+		return true
+
+	case "pyramid":
+		center := vec3Property(props, "center", vec3{})
+		baseSize, ok := extractFloatArray(props, "base_size", 2)
+		if !ok {
+			return false
+		}
+		height, _ := extractFloat(props, "height")
+		local := p.sub(center)
+		if height <= 0 || local[1] < 0 || local[1] > height {
+			return false
+		}
+		// The cross-section shrinks linearly from the base to a point at the apex
+		shrink := 1 - local[1]/height
+		return math.Abs(local[0]) <= baseSize[0]/2*shrink && math.Abs(local[2]) <= baseSize[1]/2*shrink
+
+	case "cylinder":
+		base := vec3Property(props, "base_center", vec3{})
+		top := vec3Property(props, "top_center", vec3{})
+		radius, _ := extractFloat(props, "radius")
+		return frustumContains(base, top, radius, radius, p)
+
+	case "cone":
+		base := vec3Property(props, "base_center", vec3{})
+		top := vec3Property(props, "top_center", vec3{})
+		baseRadius, _ := extractFloat(props, "base_radius")
+		topRadius, _ := extractFloat(props, "top_radius")
+		return frustumContains(base, top, baseRadius, topRadius, p)
+	}
+
+	return false
+}
+
+// frustumContains reports whether p is inside a solid cylinder or cone between base and top
+// The radius varies linearly along the axis from baseRadius to topRadius.
+func frustumContains(base, top vec3, baseRadius, topRadius float64, p vec3) bool {
+	axis := top.sub(base)
+	height := axis.length()
+	if height == 0 {
+		return false
+	}
+	axis = axis.scale(1 / height)
+
+	d := p.sub(base)
+	along := d.dot(axis)
+	if along < 0 || along > height {
+		return false
+	}
+	radius := baseRadius + (topRadius-baseRadius)*along/height
+	return d.sub(axis.scale(along)).length() <= radius
+}
+
+// BoundsOverlap reports whether the axis-aligned bounding boxes of two shapes intersect
+// This is approximate: shapes whose boxes overlap may not actually touch, e.g. two spheres
+// near each other's corners. Boxes that only meet face to face, like a vase standing on a
+// table, don't count as overlapping.
+func (sm *SceneManager) BoundsOverlap(idA, idB string) (bool, error) {
+	aMin, aMax, bMin, bMax, err := sm.shapeBoundsPair(idA, idB)
+	if err != nil {
+		return false, err
+	}
+	_, _, overlap := boundsIntersection(aMin, aMax, bMin, bMax)
+	return overlap, nil
+}
+
+// OverlapReport describes how the bounding boxes of two shapes relate, for check_overlap
+// It always includes both boxes, and adds overlap_bounds when they overlap or gap, the
+// shortest distance between them, when they don't.
+func (sm *SceneManager) OverlapReport(idA, idB string) (map[string]interface{}, error) {
+	aMin, aMax, bMin, bMax, err := sm.shapeBoundsPair(idA, idB)
+	if err != nil {
+		return nil, err
+	}
+
+	report := map[string]interface{}{
+		"shape_a":  idA,
+		"shape_b":  idB,
+		"bounds_a": map[string]interface{}{"min": aMin, "max": aMax},
+		"bounds_b": map[string]interface{}{"min": bMin, "max": bMax},
+	}
+	min, max, overlap := boundsIntersection(aMin, aMax, bMin, bMax)
+	report["overlap"] = overlap
+	if overlap {
+		report["overlap_bounds"] = map[string]interface{}{"min": min, "max": max, "size": max.sub(min)}
+	} else {
+		report["gap"] = boundsGap(aMin, aMax, bMin, bMax)
+	}
+	return report, nil
+}
+
+// SnapToShape moves a shape along dir until its bounding box just touches the target's
+// The mover slides along the direction as far as needed for the boxes to meet face to face, so
+// curved shapes can be left a little apart. If the boxes already overlap, the mover backs up
+// along dir until they only touch. It is an error for the mover to miss the target (passing
+// beside it) or for the target to be behind it.
+func (sm *SceneManager) SnapToShape(moverID, targetID string, dir [3]float64) error {
+	d := vec3(dir)
+	if d.length() == 0 {
+		return fmt.Errorf("direction must be non-zero")
+	}
+	mMin, mMax, tMin, tMax, err := sm.shapeBoundsPair(moverID, targetID)
+	if err != nil {
+		return err
+	}
+
+	// Sweep the mover's box along d: on each axis the boxes overlap between an entry and exit
+	// time, and they touch at the latest entry as long as it comes before the earliest exit
+	enter, exit := math.Inf(-1), math.Inf(1)
+	for i := range d {
+		switch {
+		case d[i] > 0:
+			enter = math.Max(enter, (tMin[i]-mMax[i])/d[i])
+			exit = math.Min(exit, (tMax[i]-mMin[i])/d[i])
+		case d[i] < 0:
+			enter = math.Max(enter, (tMax[i]-mMin[i])/d[i])
+			exit = math.Min(exit, (tMin[i]-mMax[i])/d[i])
+		case mMin[i] >= tMax[i] || mMax[i] <= tMin[i]:
+			return fmt.Errorf("shape '%s' would pass beside '%s' without touching it - pick a direction that points at it", moverID, targetID)
+		}
+	}
+	if enter >= exit {
+		return fmt.Errorf("shape '%s' would pass beside '%s' without touching it - pick a direction that points at it", moverID, targetID)
+	}
+	if exit <= 0 {
+		return fmt.Errorf("shape '%s' is behind '%s' along the direction %v - reverse the direction", targetID, moverID, dir)
+	}
+
+	mover, err := sm.GetShapeCopy(moverID)
+	if err != nil {
+		return err
+	}
+	translateShape(mover, d.scale(enter))
+	moved := make(map[string]interface{})
+	for _, key := range positionProperties {
+		if value, ok := mover.Properties[key]; ok {
+			moved[key] = value
+		}
+	}
+	return sm.UpdateShape(moverID, map[string]interface{}{"properties": moved})
+}
+
+// DistanceTarget is one end of a distance measurement: a shape or an explicit point
+// Exactly one of ShapeID and Point must be set.
+type DistanceTarget struct {
+	ShapeID string    `json:"shape_id,omitempty"`
+	Point   []float64 `json:"point,omitempty"`
+}
+
+// Distance returns the straight-line distance between two targets and the delta from a to b
+// A shape is measured from its center property if it has one (for a pyramid, the center of
+// its base), otherwise from the middle of its bounding box.
+func (sm *SceneManager) Distance(a, b DistanceTarget) (float64, [3]float64, error) {
+	from, err := sm.resolveDistanceTarget(a, "from")
+	if err != nil {
+		return 0, [3]float64{}, err
+	}
+	to, err := sm.resolveDistanceTarget(b, "to")
+	if err != nil {
+		return 0, [3]float64{}, err
+	}
+	delta := to.sub(from)
+	return delta.length(), delta, nil
+}
+
+// resolveDistanceTarget returns the position of a distance target; name labels it in errors
+func (sm *SceneManager) resolveDistanceTarget(target DistanceTarget, name string) (vec3, error) {
+	switch {
+	case target.ShapeID != "" && len(target.Point) > 0:
+		return vec3{}, fmt.Errorf("%s must be a shape or a point, not both", name)
+	case target.ShapeID != "":
+		shape := sm.FindShape(target.ShapeID)
+		if shape == nil {
+			return vec3{}, fmt.Errorf("shape with ID '%s' not found", target.ShapeID)
+		}
+		if center, ok := extractFloatArray(shape.Properties, "center", 3); ok {
+			return vec3{center[0], center[1], center[2]}, nil
+		}
+		min, max, ok := shapeBounds(*shape)
+		if !ok {
+			return vec3{}, fmt.Errorf("shape '%s' has no position to measure from", target.ShapeID)
+		}
+		return min.add(max).scale(0.5), nil
+	case len(target.Point) > 0:
+		if len(target.Point) != 3 {
+			return vec3{}, fmt.Errorf("%s point must be a 3-element array [x, y, z], got %d elements", name, len(target.Point))
+		}
+		return vec3{target.Point[0], target.Point[1], target.Point[2]}, nil
+	default:
+		return vec3{}, fmt.Errorf("%s requires a shape ID or a point", name)
+	}
+}
+
+// shapeBoundsPair returns the bounding boxes of two different shapes
+func (sm *SceneManager) shapeBoundsPair(idA, idB string) (aMin, aMax, bMin, bMax vec3, err error) {
+	if idA == idB {
+		return aMin, aMax, bMin, bMax, fmt.Errorf("cannot check shape '%s' against itself - provide two different shape IDs", idA)
+	}
+	if aMin, aMax, err = sm.shapeBoundsByID(idA); err != nil {
+		return aMin, aMax, bMin, bMax, err
+	}
+	bMin, bMax, err = sm.shapeBoundsByID(idB)
+	return aMin, aMax, bMin, bMax, err
+}
+
+// shapeBoundsByID returns the bounding box of a shape, or an error if it doesn't exist or has no extent
+func (sm *SceneManager) shapeBoundsByID(id string) (min, max vec3, err error) {
+	shape := sm.FindShape(id)
+	if shape == nil {
+		return min, max, fmt.Errorf("shape with ID '%s' not found", id)
+	}
+	min, max, ok := shapeBounds(*shape)
+	if !ok {
+		return min, max, fmt.Errorf("shape '%s' has no extent to compare", id)
+	}
+	return min, max, nil
+}
+
+// boundsIntersection returns the box shared by two boxes; ok is false unless they overlap
+// with positive depth on every axis. A box that is flat along an axis, like a floor quad,
+// overlaps along it only where it passes strictly through the other box.
+func boundsIntersection(aMin, aMax, bMin, bMax vec3) (min, max vec3, ok bool) {
+	for i := range min {
+		min[i] = math.Max(aMin[i], bMin[i])
+		max[i] = math.Min(aMax[i], bMax[i])
+		switch {
+		case min[i] < max[i]:
+		case aMin[i] == aMax[i] && aMin[i] > bMin[i] && aMin[i] < bMax[i]:
+		case bMin[i] == bMax[i] && bMin[i] > aMin[i] && bMin[i] < aMax[i]:
+		default:
+			return vec3{}, vec3{}, false
+		}
+	}
+	return min, max, true
+}
+
+// boundsGap returns the shortest distance between two boxes, 0 if they touch or overlap
+func boundsGap(aMin, aMax, bMin, bMax vec3) float64 {
+	var gap vec3
+	for i := range gap {
+		gap[i] = math.Max(0, math.Max(bMin[i]-aMax[i], aMin[i]-bMax[i]))
+	}
+	return gap.length()
+}
@@ -0,0 +1,446 @@
+package agent
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/df07/scene-llm/agent/llm"
+)
+
+func TestShapeContains(t *testing.T) {
+	shape := func(shapeType string, props map[string]interface{}) ShapeRequest {
+		return ShapeRequest{ID: shapeType, Type: shapeType, Properties: props}
+	}
+	sphere := shape("sphere", map[string]interface{}{"center": []interface{}{0.0, 1.0, 0.0}, "radius": 1.0})
+	ellipsoid := shape("ellipsoid", map[string]interface{}{"center": []interface{}{0.0, 0.0, 0.0}, "radii": []interface{}{2.0, 0.5, 1.0}})
+	box := shape("box", map[string]interface{}{"center": []interface{}{0.0, 0.0, 0.0}, "dimensions": []interface{}{2.0, 2.0, 2.0}})
+	rotatedBox := shape("box", map[string]interface{}{
+		"center": []interface{}{0.0, 0.0, 0.0}, "dimensions": []interface{}{4.0, 0.2, 0.2}, "rotation": []interface{}{0.0, 0.0, math.Pi / 2},
+	})
+	pyramid := shape("pyramid", map[string]interface{}{"center": []interface{}{0.0, 0.0, 0.0}, "base_size": []interface{}{2.0, 2.0}, "height": 2.0})
+	cylinder := shape("cylinder", map[string]interface{}{
+		"base_center": []interface{}{0.0, 0.0, 0.0}, "top_center": []interface{}{0.0, 2.0, 0.0}, "radius": 0.5,
+	})
+	cone := shape("cone", map[string]interface{}{
+		"base_center": []interface{}{0.0, 0.0, 0.0}, "top_center": []interface{}{2.0, 0.0, 0.0}, "base_radius": 1.0, "top_radius": 0.0,
+	})
+	quad := shape("quad", map[string]interface{}{
+		"corner": []interface{}{-1.0, 0.0, -1.0}, "u": []interface{}{2.0, 0.0, 0.0}, "v": []interface{}{0.0, 0.0, 2.0},
+	})
+
+	tests := []struct {
+		name     string
+		shape    ShapeRequest
+		point    vec3
+		expected bool
+	}{
+		{"sphere center", sphere, vec3{0, 1, 0}, true},
+		{"sphere surface", sphere, vec3{0, 2, 0}, true},
+		{"sphere outside", sphere, vec3{0.8, 1.8, 0}, false},
+		{"ellipsoid long axis", ellipsoid, vec3{1.9, 0, 0}, true},
+		{"ellipsoid short axis", ellipsoid, vec3{0, 0.6, 0}, false},
+		{"box inside", box, vec3{0.9, -0.9, 0.9}, true},
+		{"box outside", box, vec3{1.1, 0, 0}, false},
+		{"rotated box along y", rotatedBox, vec3{0, 1.9, 0}, true},
+		{"rotated box along x", rotatedBox, vec3{1.9, 0, 0}, false},
+		{"pyramid near base", pyramid, vec3{0.9, 0.1, 0.9}, true},
+		{"pyramid near apex", pyramid, vec3{0.5, 1.5, 0}, false},
+		{"pyramid below base", pyramid, vec3{0, -0.1, 0}, false},
+		{"cylinder inside", cylinder, vec3{0.4, 1.9, 0}, true},
+		{"cylinder beyond top", cylinder, vec3{0, 2.1, 0}, false},
+		{"cylinder beside", cylinder, vec3{0.6, 1, 0}, false},
+		{"cone wide end", cone, vec3{0.1, 0.8, 0}, true},
+		{"cone narrow end", cone, vec3{1.5, 0.8, 0}, false},
+		{"quad has no volume", quad, vec3{0, 0, 0}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := shapeContains(tt.shape, tt.point); got != tt.expected {
+				t.Errorf("shapeContains(%v) = %v, expected %v", tt.point, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestIsPointOccupiedTool(t *testing.T) {
+	events := make(chan AgentEvent, 100)
+	agent := NewWithProvider(events, &MockProvider{}, "mock-model")
+	err := agent.sceneManager.AddShapes([]ShapeRequest{
+		{ID: "table", Type: "box", Properties: map[string]interface{}{
+			"center": []interface{}{0.0, 0.5, 0.0}, "dimensions": []interface{}{2.0, 1.0, 2.0},
+		}},
+		{ID: "ball", Type: "sphere", Properties: map[string]interface{}{
+			"center": []interface{}{0.5, 1.0, 0.0}, "radius": 0.5,
+		}},
+		{ID: "floor", Type: "quad", Properties: map[string]interface{}{
+			"corner": []interface{}{-5.0, 0.0, -5.0}, "u": []interface{}{10.0, 0.0, 0.0}, "v": []interface{}{0.0, 0.0, 10.0},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to add shapes: %v", err)
+	}
+
+	query := func(point []interface{}) map[string]interface{} {
+		t.Helper()
+		req := parseToolRequestFromFunctionCall(&llm.FunctionCall{
+			Name:      "is_point_occupied",
+			Arguments: map[string]interface{}{"point": point},
+		})
+		result := agent.executeToolRequests(context.Background(), req, "test_call_1")
+		if !result.Success {
+			t.Fatalf("Expected is_point_occupied to succeed, got errors: %v", result.Errors)
+		}
+		return result.Result.(map[string]interface{})
+	}
+
+	if result := query([]interface{}{0.5, 0.9, 0.0}); result["occupied"] != true || !reflect.DeepEqual(result["shape_ids"], []string{"table", "ball"}) {
+		t.Errorf("Expected the point to be inside the table and the ball, got %v", result)
+	}
+	if result := query([]interface{}{3.0, 0.0, 0.0}); result["occupied"] != false || len(result["shape_ids"].([]string)) != 0 {
+		t.Errorf("Expected a point on the floor quad to be unoccupied, got %v", result)
+	}
+
+	req := parseToolRequestFromFunctionCall(&llm.FunctionCall{
+		Name:      "is_point_occupied",
+		Arguments: map[string]interface{}{"point": []interface{}{1.0, 2.0}},
+	})
+	if result := agent.executeToolRequests(context.Background(), req, "test_call_2"); result.Success {
+		t.Error("Expected a 2-element point to be rejected")
+	}
+}
+
+func TestBoundsOverlap(t *testing.T) {
+	sm := NewSceneManager()
+	box := func(id string, center, dimensions []interface{}) ShapeRequest {
+		return ShapeRequest{ID: id, Type: "box", Properties: map[string]interface{}{"center": center, "dimensions": dimensions}}
+	}
+	err := sm.AddShapes([]ShapeRequest{
+		box("table", []interface{}{0.0, 0.5, 0.0}, []interface{}{2.0, 1.0, 2.0}),
+		box("vase", []interface{}{0.0, 1.5, 0.0}, []interface{}{0.5, 1.0, 0.5}),      // Standing on the table
+		box("chair", []interface{}{0.8, 0.5, 0.0}, []interface{}{1.0, 1.0, 1.0}),     // Pushed into the table
+		box("lamp", []interface{}{4.0, 0.5, 0.0}, []interface{}{1.0, 1.0, 1.0}),      // 2.5 from the table
+		box("buried", []interface{}{-4.0, 0.0, 0.0}, []interface{}{1.0, 1.0, 1.0}),   // Half below the floor
+		box("on_floor", []interface{}{-4.0, 0.5, 3.0}, []interface{}{1.0, 1.0, 1.0}), // Resting on the floor
+		{ID: "floor", Type: "quad", Properties: map[string]interface{}{
+			"corner": []interface{}{-5.0, 0.0, -5.0}, "u": []interface{}{10.0, 0.0, 0.0}, "v": []interface{}{0.0, 0.0, 10.0},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to add shapes: %v", err)
+	}
+
+	tests := []struct {
+		a, b     string
+		expected bool
+	}{
+		{"table", "vase", false},
+		{"table", "chair", true},
+		{"chair", "table", true},
+		{"table", "lamp", false},
+		{"buried", "floor", true},
+		{"on_floor", "floor", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+"/"+tt.b, func(t *testing.T) {
+			overlap, err := sm.BoundsOverlap(tt.a, tt.b)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if overlap != tt.expected {
+				t.Errorf("BoundsOverlap(%q, %q) = %v, expected %v", tt.a, tt.b, overlap, tt.expected)
+			}
+		})
+	}
+
+	for _, ids := range [][2]string{{"table", "missing"}, {"missing", "table"}, {"table", "table"}} {
+		if _, err := sm.BoundsOverlap(ids[0], ids[1]); err == nil {
+			t.Errorf("Expected an error for %v", ids)
+		}
+	}
+}
+
+func TestCheckOverlapTool(t *testing.T) {
+	events := make(chan AgentEvent, 100)
+	agent := NewWithProvider(events, &MockProvider{}, "mock-model")
+	err := agent.sceneManager.AddShapes([]ShapeRequest{
+		{ID: "a", Type: "box", Properties: map[string]interface{}{"center": []interface{}{0.0, 0.0, 0.0}, "dimensions": []interface{}{2.0, 2.0, 2.0}}},
+		{ID: "b", Type: "box", Properties: map[string]interface{}{"center": []interface{}{1.5, 0.0, 0.0}, "dimensions": []interface{}{2.0, 2.0, 2.0}}},
+		{ID: "c", Type: "box", Properties: map[string]interface{}{"center": []interface{}{0.0, 0.0, 5.0}, "dimensions": []interface{}{2.0, 2.0, 2.0}}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to add shapes: %v", err)
+	}
+
+	check := func(a, b string) ToolResult {
+		req := parseToolRequestFromFunctionCall(&llm.FunctionCall{
+			Name:      "check_overlap",
+			Arguments: map[string]interface{}{"shape_a": a, "shape_b": b},
+		})
+		return agent.executeToolRequests(context.Background(), req, "test_call_1")
+	}
+
+	result := check("a", "b")
+	if !result.Success {
+		t.Fatalf("Expected check_overlap to succeed, got errors: %v", result.Errors)
+	}
+	report := result.Result.(map[string]interface{})
+	if report["overlap"] != true {
+		t.Fatalf("Expected a and b to overlap, got %v", report)
+	}
+	overlapBounds := report["overlap_bounds"].(map[string]interface{})
+	if size := overlapBounds["size"].(vec3); math.Abs(size[0]-0.5) > 1e-9 || math.Abs(size[1]-2) > 1e-9 {
+		t.Errorf("Expected a 0.5 x 2 x 2 overlap, got %v", size)
+	}
+
+	result = check("a", "c")
+	report = result.Result.(map[string]interface{})
+	if report["overlap"] != false || math.Abs(report["gap"].(float64)-3) > 1e-9 {
+		t.Errorf("Expected a and c to be 3 apart, got %v", report)
+	}
+
+	if result := check("a", "missing"); result.Success || !strings.Contains(strings.Join(result.Errors, "; "), "not found") {
+		t.Errorf("Expected a missing shape to be reported, got %+v", result)
+	}
+}
+
+func TestDistance(t *testing.T) {
+	sm := NewSceneManager()
+	err := sm.AddShapes([]ShapeRequest{
+		{ID: "ball", Type: "sphere", Properties: map[string]interface{}{"center": []interface{}{1.0, 2.0, 3.0}, "radius": 0.5}},
+		{ID: "post", Type: "cylinder", Properties: map[string]interface{}{
+			"base_center": []interface{}{4.0, 0.0, 3.0}, "top_center": []interface{}{4.0, 4.0, 3.0}, "radius": 0.2, "capped": true}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to add shapes: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		a, b     DistanceTarget
+		distance float64
+		delta    [3]float64
+	}{
+		{"points", DistanceTarget{Point: []float64{0, 0, 0}}, DistanceTarget{Point: []float64{3, 4, 0}}, 5, [3]float64{3, 4, 0}},
+		{"shape center to point", DistanceTarget{ShapeID: "ball"}, DistanceTarget{Point: []float64{1, 2, 0}}, 3, [3]float64{0, 0, -3}},
+		// A cylinder has no center property, so the middle of its axis is used
+		{"shape to shape without center", DistanceTarget{ShapeID: "ball"}, DistanceTarget{ShapeID: "post"}, 3, [3]float64{3, 0, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			distance, delta, err := sm.Distance(tt.a, tt.b)
+			if err != nil {
+				t.Fatalf("Distance failed: %v", err)
+			}
+			if math.Abs(distance-tt.distance) > 1e-9 {
+				t.Errorf("Expected distance %g, got %g", tt.distance, distance)
+			}
+			for i := range delta {
+				if math.Abs(delta[i]-tt.delta[i]) > 1e-9 {
+					t.Errorf("Expected delta %v, got %v", tt.delta, delta)
+					break
+				}
+			}
+		})
+	}
+
+	errorTests := []struct {
+		name     string
+		a, b     DistanceTarget
+		expected string
+	}{
+		{"missing shape", DistanceTarget{ShapeID: "ball"}, DistanceTarget{ShapeID: "missing"}, "'missing' not found"},
+		{"no target", DistanceTarget{}, DistanceTarget{ShapeID: "ball"}, "from requires a shape ID or a point"},
+		{"both", DistanceTarget{ShapeID: "ball"}, DistanceTarget{ShapeID: "post", Point: []float64{0, 0, 0}}, "to must be a shape or a point, not both"},
+		{"short point", DistanceTarget{Point: []float64{1, 2}}, DistanceTarget{ShapeID: "ball"}, "3-element"},
+	}
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := sm.Distance(tt.a, tt.b); err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("Expected an error containing %q, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestMeasureDistanceTool(t *testing.T) {
+	events := make(chan AgentEvent, 100)
+	agent := NewWithProvider(events, &MockProvider{}, "mock-model")
+	err := agent.sceneManager.AddShapes([]ShapeRequest{
+		{ID: "ball", Type: "sphere", Properties: map[string]interface{}{"center": []interface{}{0.0, 1.0, 0.0}, "radius": 1.0}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to add shapes: %v", err)
+	}
+
+	req := parseToolRequestFromFunctionCall(&llm.FunctionCall{
+		Name:      "measure_distance",
+		Arguments: map[string]interface{}{"from_shape": "ball", "to_point": []interface{}{0.0, 1.0, -2.0}},
+	})
+	result := agent.executeToolRequests(context.Background(), req, "test_call_1")
+	if !result.Success {
+		t.Fatalf("Expected measure_distance to succeed, got errors: %v", result.Errors)
+	}
+	report := result.Result.(map[string]interface{})
+	if report["distance"] != 2.0 {
+		t.Errorf("Expected a distance of 2, got %v", report["distance"])
+	}
+	if delta := report["delta"].([]float64); delta[2] != -2 {
+		t.Errorf("Expected delta [0 0 -2], got %v", delta)
+	}
+
+	req = parseToolRequestFromFunctionCall(&llm.FunctionCall{
+		Name:      "measure_distance",
+		Arguments: map[string]interface{}{"from_shape": "ball"},
+	})
+	if result := agent.executeToolRequests(context.Background(), req, "test_call_2"); result.Success {
+		t.Error("Expected measure_distance without a second target to fail")
+	}
+}
+
+func TestSnapToShape(t *testing.T) {
+	newScene := func(t *testing.T) *SceneManager {
+		t.Helper()
+		sm := NewSceneManager()
+		err := sm.AddShapes([]ShapeRequest{
+			{ID: "box", Type: "box", Properties: map[string]interface{}{"center": []interface{}{-5.0, 1.0, 0.0}, "dimensions": []interface{}{2.0, 2.0, 2.0}}},
+			{ID: "ball", Type: "sphere", Properties: map[string]interface{}{"center": []interface{}{0.0, 1.0, 0.0}, "radius": 1.0}},
+			{ID: "table", Type: "quad", Properties: map[string]interface{}{
+				"corner": []interface{}{-8.0, 0.0, -2.0}, "u": []interface{}{16.0, 0.0, 0.0}, "v": []interface{}{0.0, 0.0, 4.0},
+			}},
+			{ID: "post", Type: "cylinder", Properties: map[string]interface{}{
+				"base_center": []interface{}{0.0, 5.0, 0.0}, "top_center": []interface{}{0.0, 7.0, 0.0}, "radius": 0.5, "capped": true,
+			}},
+		})
+		if err != nil {
+			t.Fatalf("AddShapes() failed: %v", err)
+		}
+		return sm
+	}
+	position := func(sm *SceneManager, id, key string) vec3 {
+		return vec3Property(sm.FindShape(id).Properties, key, vec3{math.NaN()})
+	}
+	near := func(a, b vec3) bool { return a.sub(b).length() < 1e-9 }
+
+	tests := []struct {
+		name     string
+		mover    string
+		target   string
+		dir      [3]float64
+		key      string
+		expected vec3
+	}{
+		// The box's right face (x=-4) slides up to the ball's left bound (x=-1)
+		{"push along +x", "box", "ball", [3]float64{1, 0, 0}, "center", vec3{-2, 1, 0}},
+		// Direction length doesn't matter
+		{"unnormalized direction", "box", "ball", [3]float64{10, 0, 0}, "center", vec3{-2, 1, 0}},
+		// The post drops until its base rests on the ball's top bound
+		{"drop onto", "post", "ball", [3]float64{0, -1, 0}, "base_center", vec3{0, 2, 0}},
+		// The ball overlaps nothing along x but already rests on the table, so snapping down keeps it there
+		{"already touching", "ball", "table", [3]float64{0, -1, 0}, "center", vec3{0, 1, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sm := newScene(t)
+			if err := sm.SnapToShape(tt.mover, tt.target, tt.dir); err != nil {
+				t.Fatalf("SnapToShape() failed: %v", err)
+			}
+			if got := position(sm, tt.mover, tt.key); !near(got, tt.expected) {
+				t.Errorf("Expected %s %v, got %v", tt.key, tt.expected, got)
+			}
+		})
+	}
+
+	t.Run("overlapping shapes back out", func(t *testing.T) {
+		sm := newScene(t)
+		if err := sm.UpdateShape("box", map[string]interface{}{"properties": map[string]interface{}{"center": []interface{}{-1.5, 1.0, 0.0}}}); err != nil {
+			t.Fatalf("UpdateShape() failed: %v", err)
+		}
+		if err := sm.SnapToShape("box", "ball", [3]float64{1, 0, 0}); err != nil {
+			t.Fatalf("SnapToShape() failed: %v", err)
+		}
+		if got := position(sm, "box", "center"); !near(got, vec3{-2, 1, 0}) {
+			t.Errorf("Expected the box to back out to center [-2 1 0], got %v", got)
+		}
+		if overlap, _ := sm.BoundsOverlap("box", "ball"); overlap {
+			t.Error("Expected the box to only touch the ball")
+		}
+	})
+
+	t.Run("snap can be reverted", func(t *testing.T) {
+		sm := newScene(t)
+		if err := sm.SnapToShape("box", "ball", [3]float64{1, 0, 0}); err != nil {
+			t.Fatalf("SnapToShape() failed: %v", err)
+		}
+		if err := sm.RevertShape("box"); err != nil {
+			t.Fatalf("RevertShape() failed: %v", err)
+		}
+		if got := position(sm, "box", "center"); !near(got, vec3{-5, 1, 0}) {
+			t.Errorf("Expected revert to restore the box, got %v", got)
+		}
+	})
+
+	errorTests := []struct {
+		name   string
+		mover  string
+		target string
+		dir    [3]float64
+		errMsg string
+	}{
+		{"zero direction", "box", "ball", [3]float64{}, "non-zero"},
+		{"unknown mover", "missing", "ball", [3]float64{1, 0, 0}, "not found"},
+		{"unknown target", "box", "missing", [3]float64{1, 0, 0}, "not found"},
+		{"same shape", "box", "box", [3]float64{1, 0, 0}, "itself"},
+		{"passes beside", "box", "ball", [3]float64{0, 0, 1}, "pass beside"},
+		{"misses diagonally", "box", "post", [3]float64{1, 0, 0}, "pass beside"},
+		{"target behind", "box", "ball", [3]float64{-1, 0, 0}, "behind"},
+	}
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			sm := newScene(t)
+			err := sm.SnapToShape(tt.mover, tt.target, tt.dir)
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("Expected an error containing %q, got %v", tt.errMsg, err)
+			}
+			if got := position(sm, "box", "center"); !near(got, vec3{-5, 1, 0}) {
+				t.Errorf("Expected a failed snap to leave the box alone, got %v", got)
+			}
+		})
+	}
+}
+
+func TestSnapShapesTool(t *testing.T) {
+	events := make(chan AgentEvent, 100)
+	agent := NewWithProvider(events, &MockProvider{}, "mock-model")
+	err := agent.sceneManager.AddShapes([]ShapeRequest{
+		{ID: "floor", Type: "box", Properties: map[string]interface{}{"center": []interface{}{0.0, -0.5, 0.0}, "dimensions": []interface{}{10.0, 1.0, 10.0}}},
+		{ID: "ball", Type: "sphere", Properties: map[string]interface{}{"center": []interface{}{1.0, 4.0, 0.0}, "radius": 1.0}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to add shapes: %v", err)
+	}
+
+	req := parseToolRequestFromFunctionCall(&llm.FunctionCall{
+		Name:      "snap_shapes",
+		Arguments: map[string]interface{}{"mover_id": "ball", "target_id": "floor", "direction": []interface{}{0.0, -1.0, 0.0}},
+	})
+	result := agent.executeToolRequests(context.Background(), req, "test_call_1")
+	if !result.Success {
+		t.Fatalf("Expected snap_shapes to succeed, got errors: %v", result.Errors)
+	}
+	position := result.Result.(map[string]interface{})["position"].(map[string]interface{})
+	if center := vec3Property(position, "center", vec3{}); center != (vec3{1, 1, 0}) {
+		t.Errorf("Expected the ball to rest on the floor at [1 1 0], got %v", position["center"])
+	}
+
+	req = parseToolRequestFromFunctionCall(&llm.FunctionCall{
+		Name:      "snap_shapes",
+		Arguments: map[string]interface{}{"mover_id": "ball", "target_id": "floor", "direction": []interface{}{0.0, -1.0}},
+	})
+	if result := agent.executeToolRequests(context.Background(), req, "test_call_2"); result.Success {
+		t.Error("Expected snap_shapes with a 2-element direction to fail")
+	}
+}
@@ -0,0 +1,90 @@
+package agent
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+)
+
+// PostProcess holds stylistic adjustments applied to a finished beauty render
+// The zero value leaves the image unchanged.
+type PostProcess struct {
+	Vignette   float64 `json:"vignette"`   // Darkening toward the corners, in [0, 1]
+	Contrast   float64 `json:"contrast"`   // In [-1, 1]: -1 is flat gray, 1 doubles contrast
+	Saturation float64 `json:"saturation"` // In [-1, 1]: -1 is grayscale, 1 doubles saturation
+}
+
+// validate checks post-processing settings supplied by the model
+func (p PostProcess) validate() error {
+	var errors ValidationErrors
+	if !(p.Vignette >= 0 && p.Vignette <= 1) {
+		errors = append(errors, fmt.Sprintf("vignette must be in [0, 1], got %g", p.Vignette))
+	}
+	if !(p.Contrast >= -1 && p.Contrast <= 1) {
+		errors = append(errors, fmt.Sprintf("contrast must be in [-1, 1], got %g", p.Contrast))
+	}
+	if !(p.Saturation >= -1 && p.Saturation <= 1) {
+		errors = append(errors, fmt.Sprintf("saturation must be in [-1, 1], got %g", p.Saturation))
+	}
+	if len(errors) > 0 {
+		return errors
+	}
+	return nil
+}
+
+// Effects returns the names of the effects that change the image, in the order they are applied
+func (p PostProcess) Effects() []string {
+	effects := []string{}
+	if p.Saturation != 0 {
+		effects = append(effects, "saturation")
+	}
+	if p.Contrast != 0 {
+		effects = append(effects, "contrast")
+	}
+	if p.Vignette != 0 {
+		effects = append(effects, "vignette")
+	}
+	return effects
+}
+
+// Apply returns img with the post-processing effects applied, or img itself if there are none
+// Colors are adjusted after the raytracer's tone mapping, so they work on display values in
+// [0, 1]. Alpha is left alone.
+func (p PostProcess) Apply(img image.Image) image.Image {
+	if p == (PostProcess{}) {
+		return img
+	}
+
+	bounds := img.Bounds()
+	out := image.NewNRGBA(bounds)
+	halfWidth, halfHeight := float64(bounds.Dx())/2, float64(bounds.Dy())/2
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			rgb := vec3{float64(c.R) / 255, float64(c.G) / 255, float64(c.B) / 255}
+
+			// Scale each channel's distance from the pixel's luminance
+			luminance := 0.2126*rgb[0] + 0.7152*rgb[1] + 0.0722*rgb[2]
+			for i := range rgb {
+				rgb[i] = luminance + (rgb[i]-luminance)*(1+p.Saturation)
+			}
+
+			// Scale each channel's distance from mid-gray
+			for i := range rgb {
+				rgb[i] = 0.5 + (rgb[i]-0.5)*(1+p.Contrast)
+			}
+
+			// Darken with the squared distance from the center, reaching 1 - vignette in the corners
+			dx := (float64(x-bounds.Min.X) + 0.5 - halfWidth) / halfWidth
+			dy := (float64(y-bounds.Min.Y) + 0.5 - halfHeight) / halfHeight
+			rgb = rgb.scale(1 - p.Vignette*(dx*dx+dy*dy)/2)
+
+			toByte := func(v float64) uint8 {
+				return uint8(math.Round(math.Max(0, math.Min(1, v)) * 255))
+			}
+			out.SetNRGBA(x, y, color.NRGBA{toByte(rgb[0]), toByte(rgb[1]), toByte(rgb[2]), c.A})
+		}
+	}
+	return out
+}
@@ -0,0 +1,113 @@
+package agent
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+
+	"github.com/df07/scene-llm/agent/llm"
+)
+
+// uniformImage returns an opaque image filled with one color
+func uniformImage(width, height int, c color.NRGBA) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestPostProcessNeutral(t *testing.T) {
+	img := uniformImage(4, 4, color.NRGBA{200, 100, 50, 255})
+	if got := (PostProcess{}).Apply(img); got != image.Image(img) {
+		t.Error("Expected a neutral post-process to return the image unchanged")
+	}
+	if effects := (PostProcess{}).Effects(); len(effects) != 0 {
+		t.Errorf("Expected no effects, got %v", effects)
+	}
+}
+
+func TestPostProcessApply(t *testing.T) {
+	orange := color.NRGBA{200, 100, 50, 255}
+	at := func(p PostProcess, x, y int) color.NRGBA {
+		return p.Apply(uniformImage(8, 8, orange)).(*image.NRGBA).NRGBAAt(x, y)
+	}
+
+	if c := at(PostProcess{Saturation: -1}, 4, 4); c.R != c.G || c.G != c.B {
+		t.Errorf("Expected saturation -1 to be grayscale, got %v", c)
+	}
+	if c := at(PostProcess{Saturation: 0.5}, 4, 4); !(c.R > orange.R && c.B < orange.B) {
+		t.Errorf("Expected more saturation to push channels apart, got %v", c)
+	}
+	if c := at(PostProcess{Contrast: -1}, 4, 4); c != (color.NRGBA{128, 128, 128, 255}) {
+		t.Errorf("Expected contrast -1 to flatten to mid-gray, got %v", c)
+	}
+	if c := at(PostProcess{Contrast: 0.5}, 4, 4); !(c.R > orange.R && c.B < orange.B) {
+		t.Errorf("Expected more contrast to push channels away from mid-gray, got %v", c)
+	}
+
+	vignette := PostProcess{Vignette: 1}
+	center, corner := at(vignette, 4, 4), at(vignette, 0, 0)
+	if center.R < 195 || corner.R > center.R/2 {
+		t.Errorf("Expected vignette to darken the corners but not the center, got center %v and corner %v", center, corner)
+	}
+	if corner.A != 255 {
+		t.Errorf("Expected alpha to be kept, got %d", corner.A)
+	}
+}
+
+func TestPostProcessValidate(t *testing.T) {
+	if err := (PostProcess{Vignette: 1, Contrast: -1, Saturation: 1}).validate(); err != nil {
+		t.Errorf("Expected the range limits to be valid, got %v", err)
+	}
+	err := (PostProcess{Vignette: 1.5, Contrast: -2, Saturation: 3}).validate()
+	if errs, ok := err.(ValidationErrors); !ok || len(errs) != 3 {
+		t.Errorf("Expected three validation errors, got %v", err)
+	}
+}
+
+func TestSetPostProcessTool(t *testing.T) {
+	events := make(chan AgentEvent, 100)
+	agent := NewWithProvider(events, &MockProvider{}, "mock-model")
+	agent.sceneManager = newRenderableSceneManager(t)
+
+	setPostProcess := func(args map[string]interface{}) ToolResult {
+		req := parseToolRequestFromFunctionCall(&llm.FunctionCall{Name: "set_post_process", Arguments: args})
+		return agent.executeToolRequests(context.Background(), req, "test_call_1")
+	}
+
+	result := setPostProcess(map[string]interface{}{"vignette": 0.4, "saturation": -0.5})
+	if !result.Success {
+		t.Fatalf("Expected set_post_process to succeed, got errors: %v", result.Errors)
+	}
+	if want := (PostProcess{Vignette: 0.4, Saturation: -0.5}); agent.PostProcess() != want {
+		t.Errorf("Expected %+v, got %+v", want, agent.PostProcess())
+	}
+
+	// Beauty renders report the effects they applied
+	agent.renderQuality = QualityPreview
+	render := agent.executeToolRequests(context.Background(), &RenderSceneRequest{BaseToolRequest: BaseToolRequest{ToolType: "render_scene"}}, "test_call_2")
+	if !render.Success {
+		t.Fatalf("Expected render to succeed, got errors: %v", render.Errors)
+	}
+	if effects := render.Result.(map[string]interface{})["post_process"]; !reflect.DeepEqual(effects, []string{"saturation", "vignette"}) {
+		t.Errorf("Expected saturation and vignette to be applied, got %v", effects)
+	}
+
+	// Out-of-range values are rejected and leave the current effects in place
+	if result := setPostProcess(map[string]interface{}{"contrast": 1.5}); result.Success {
+		t.Error("Expected contrast 1.5 to be rejected")
+	}
+	if agent.PostProcess().Vignette != 0.4 {
+		t.Errorf("Expected a rejected call to keep the current effects, got %+v", agent.PostProcess())
+	}
+
+	// Omitted effects are turned off
+	if result := setPostProcess(map[string]interface{}{}); !result.Success || agent.PostProcess() != (PostProcess{}) {
+		t.Errorf("Expected an empty call to clear all effects, got %+v", agent.PostProcess())
+	}
+}
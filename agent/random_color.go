@@ -0,0 +1,120 @@
+package agent
+
+import (
+	"math"
+	"math/rand"
+)
+
+// randomColorValue is the value that asks for a random color in a shape's color or a material's albedo
+const randomColorValue = "random"
+
+// randomColorSeed seeds each scene's random colors, so a session that makes the same calls
+// gets the same colors
+const randomColorSeed = 1
+
+// nextRandomColor returns the next color in the scene's random sequence
+// Colors are drawn in HSV with moderate saturation and brightness, so they are distinct from
+// one another without being muddy or blown out.
+func (sm *SceneManager) nextRandomColor() []interface{} {
+	if sm.colorRand == nil {
+		sm.colorRand = rand.New(rand.NewSource(randomColorSeed))
+	}
+	hue := sm.colorRand.Float64() * 360
+	saturation := 0.5 + sm.colorRand.Float64()*0.4
+	value := 0.6 + sm.colorRand.Float64()*0.35
+	rgb := hsvToRGB(hue, saturation, value)
+	return []interface{}{rgb[0], rgb[1], rgb[2]}
+}
+
+// placeholderRandomColor stands in for a random color when a shape is only being validated,
+// so validation doesn't use up the scene's sequence
+func placeholderRandomColor() []interface{} {
+	return []interface{}{0.5, 0.5, 0.5}
+}
+
+// resolveRandomColors replaces "random" in a shape's color and material albedos with colors from next
+// Albedos inside mix materials are resolved too. The shape's properties are copied rather than modified.
+func resolveRandomColors(shape ShapeRequest, next func() []interface{}) ShapeRequest {
+	shape.Properties = resolveRandomColorProperties(shape.Properties, next)
+	return shape
+}
+
+// resolveRandomColorProperties resolves random colors in a property bag, copying it if anything changes
+func resolveRandomColorProperties(properties map[string]interface{}, next func() []interface{}) map[string]interface{} {
+	color := properties["color"] == randomColorValue
+	mat, hasMaterial := properties["material"].(map[string]interface{})
+	if !color && !(hasMaterial && materialHasRandomAlbedo(mat, 0)) {
+		return properties
+	}
+
+	resolved := make(map[string]interface{}, len(properties))
+	for key, value := range properties {
+		resolved[key] = value
+	}
+	if color {
+		resolved["color"] = next()
+	}
+	if hasMaterial {
+		resolved["material"] = resolveRandomAlbedo(mat, next, 0)
+	}
+	return resolved
+}
+
+// materialHasRandomAlbedo reports whether a material or any material it mixes asks for a random albedo
+func materialHasRandomAlbedo(mat map[string]interface{}, depth int) bool {
+	if mat["albedo"] == randomColorValue {
+		return true
+	}
+	if depth >= maxMaterialNestingDepth {
+		return false
+	}
+	for _, key := range []string{"material_a", "material_b"} {
+		if nested, ok := mat[key].(map[string]interface{}); ok && materialHasRandomAlbedo(nested, depth+1) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveRandomAlbedo returns a copy of mat with each random albedo replaced by a color from next
+func resolveRandomAlbedo(mat map[string]interface{}, next func() []interface{}, depth int) map[string]interface{} {
+	resolved := make(map[string]interface{}, len(mat))
+	for key, value := range mat {
+		resolved[key] = value
+	}
+	if mat["albedo"] == randomColorValue {
+		resolved["albedo"] = next()
+	}
+	if depth < maxMaterialNestingDepth {
+		for _, key := range []string{"material_a", "material_b"} {
+			if nested, ok := mat[key].(map[string]interface{}); ok {
+				resolved[key] = resolveRandomAlbedo(nested, next, depth+1)
+			}
+		}
+	}
+	return resolved
+}
+
+// hsvToRGB converts a hue in degrees and saturation and value in [0, 1] to RGB in [0, 1]
+func hsvToRGB(hue, saturation, value float64) vec3 {
+	chroma := value * saturation
+	x := chroma * (1 - math.Abs(math.Mod(hue/60, 2)-1))
+	m := value - chroma
+
+	var rgb vec3
+	switch {
+	case hue < 60:
+		rgb = vec3{chroma, x, 0}
+	case hue < 120:
+		rgb = vec3{x, chroma, 0}
+	case hue < 180:
+		rgb = vec3{0, chroma, x}
+	case hue < 240:
+		rgb = vec3{0, x, chroma}
+	case hue < 300:
+		rgb = vec3{x, 0, chroma}
+	default:
+		rgb = vec3{chroma, 0, x}
+	}
+	return rgb.add(vec3{m, m, m})
+}
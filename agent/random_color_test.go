@@ -0,0 +1,114 @@
+package agent
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestRandomColors(t *testing.T) {
+	candies := func() []ShapeRequest {
+		var shapes []ShapeRequest
+		for i := 0; i < 4; i++ {
+			shapes = append(shapes, ShapeRequest{ID: fmt.Sprintf("candy_%d", i), Type: "sphere", Properties: map[string]interface{}{
+				"center":   []interface{}{float64(i), 0.5, 0.0},
+				"radius":   0.4,
+				"color":    "random",
+				"material": map[string]interface{}{"preset": "plastic", "albedo": "random"},
+			}})
+		}
+		return shapes
+	}
+
+	sm := NewSceneManager()
+	if err := sm.AddShapes(candies()); err != nil {
+		t.Fatalf("AddShapes() failed: %v", err)
+	}
+
+	seen := map[string]bool{}
+	for _, shape := range sm.state.Shapes {
+		color, ok := extractFloatArray(shape.Properties, "color", 3)
+		if !ok {
+			t.Fatalf("Expected %s to get a color, got %v", shape.ID, shape.Properties["color"])
+		}
+		albedo, ok := extractFloatArray(shape.Properties["material"].(map[string]interface{}), "albedo", 3)
+		if !ok {
+			t.Fatalf("Expected %s to get an albedo, got %v", shape.ID, shape.Properties["material"])
+		}
+		for _, c := range append(color, albedo...) {
+			if c < 0 || c > 1 {
+				t.Errorf("Expected color components in [0, 1], got color %v albedo %v", color, albedo)
+			}
+		}
+		seen[fmt.Sprint(albedo)] = true
+	}
+	if len(seen) != len(sm.state.Shapes) {
+		t.Errorf("Expected a different albedo for each shape, got %d distinct", len(seen))
+	}
+
+	// Another session making the same calls gets the same colors
+	other := NewSceneManager()
+	if err := other.AddShapes(candies()); err != nil {
+		t.Fatalf("AddShapes() failed: %v", err)
+	}
+	if !reflect.DeepEqual(other.state.Shapes, sm.state.Shapes) {
+		t.Error("Expected the same random colors for the same sequence of calls")
+	}
+
+	// Validating doesn't use up the sequence
+	fresh, reference := NewSceneManager(), NewSceneManager()
+	if errors := fresh.ValidateShape(candies()[0]); len(errors) > 0 {
+		t.Fatalf("Expected a random color to be valid, got %v", errors)
+	}
+	if !reflect.DeepEqual(fresh.nextRandomColor(), reference.nextRandomColor()) {
+		t.Error("Expected ValidateShape to leave the random sequence alone")
+	}
+}
+
+func TestRandomColorsInUpdatesAndMixes(t *testing.T) {
+	sm := NewSceneManager()
+	err := sm.AddShapes([]ShapeRequest{{ID: "ball", Type: "sphere", Properties: map[string]interface{}{
+		"center": []interface{}{0.0, 1.0, 0.0}, "radius": 1.0}}})
+	if err != nil {
+		t.Fatalf("AddShapes() failed: %v", err)
+	}
+
+	mix := map[string]interface{}{
+		"type":       "mix",
+		"material_a": map[string]interface{}{"type": "lambertian", "albedo": "random"},
+		"material_b": map[string]interface{}{"type": "metal", "albedo": []interface{}{0.9, 0.9, 0.9}, "fuzz": 0.1},
+		"factor":     0.3,
+	}
+	if err := sm.UpdateShape("ball", map[string]interface{}{"properties": map[string]interface{}{"material": mix}}); err != nil {
+		t.Fatalf("UpdateShape() failed: %v", err)
+	}
+
+	material := sm.FindShape("ball").Properties["material"].(map[string]interface{})
+	if _, ok := extractFloatArray(material["material_a"].(map[string]interface{}), "albedo", 3); !ok {
+		t.Errorf("Expected the mixed albedo to be resolved, got %v", material["material_a"])
+	}
+	if mix["material_a"].(map[string]interface{})["albedo"] != "random" {
+		t.Error("Expected the caller's material to be left unmodified")
+	}
+	if err := validateShapeProperties(*sm.FindShape("ball")); err != nil {
+		t.Errorf("Expected the resolved shape to be valid, got %v", err)
+	}
+}
+
+func TestHSVToRGB(t *testing.T) {
+	tests := []struct {
+		hue, saturation, value float64
+		expected               vec3
+	}{
+		{0, 1, 1, vec3{1, 0, 0}},
+		{120, 1, 1, vec3{0, 1, 0}},
+		{240, 1, 1, vec3{0, 0, 1}},
+		{60, 1, 1, vec3{1, 1, 0}},
+		{0, 0, 0.5, vec3{0.5, 0.5, 0.5}},
+	}
+	for _, tt := range tests {
+		if got := hsvToRGB(tt.hue, tt.saturation, tt.value); got.sub(tt.expected).length() > 1e-9 {
+			t.Errorf("hsvToRGB(%g, %g, %g) = %v, expected %v", tt.hue, tt.saturation, tt.value, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,370 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"math"
+	"sync"
+
+	"github.com/df07/go-progressive-raytracer/pkg/geometry"
+	"github.com/df07/go-progressive-raytracer/pkg/integrator"
+	"github.com/df07/go-progressive-raytracer/pkg/renderer"
+	"github.com/df07/go-progressive-raytracer/pkg/scene"
+)
+
+// RenderQuality represents different rendering quality presets
+type RenderQuality string
+
+const (
+	QualityPreview RenderQuality = "preview"
+	QualityDraft   RenderQuality = "draft"
+	QualityHigh    RenderQuality = "high"
+	// QualityAuto picks samples and bounces from the scene's complexity; see RenderSettingsFor
+	QualityAuto RenderQuality = "auto"
+)
+
+// Adaptive sampling defaults, used by every quality preset
+const (
+	defaultAdaptiveMinSamples = 0.1
+	defaultAdaptiveThreshold  = 0.05
+)
+
+// RenderSettings holds the renderer configuration for a quality preset
+//
+// Adaptive sampling lets pixels stop early once they have converged. Every pixel takes at
+// least AdaptiveMinSamples (a fraction of SamplesPerPixel), then stops once its estimated
+// relative error drops below AdaptiveThreshold. A lower minimum or higher threshold renders
+// faster but leaves more noise in dark or detailed areas; a higher minimum or lower
+// threshold is slower but cleaner.
+//
+// Denoise runs an edge-aware filter over the finished image. It hides most of the grain in
+// low-sample renders but softens fine detail, so it is off by default. PostProcess is applied
+// by the caller after shadow catchers, just before encoding, and is neutral by default.
+//
+// Integrator picks the light transport algorithm; empty means path tracing, currently the only one.
+// ClampIndirect suppresses fireflies at the cost of some energy, and is 0 (off) by default;
+// see ClampFireflies.
+type RenderSettings struct {
+	Width              int         `json:"width"`
+	Height             int         `json:"height"`
+	SamplesPerPixel    int         `json:"samples_per_pixel"`
+	MaxDepth           int         `json:"max_depth"`
+	AdaptiveMinSamples float64     `json:"adaptive_min_samples"` // In (0, 1]; 1 disables early stopping
+	AdaptiveThreshold  float64     `json:"adaptive_threshold"`   // Greater than 0
+	Denoise            bool        `json:"denoise"`
+	PostProcess        PostProcess `json:"post_process"`
+	Integrator         string      `json:"integrator,omitempty"`
+	ClampIndirect      float64     `json:"clamp_indirect,omitempty"` // Firefly threshold, 0 for off
+	ImageFormat        string      `json:"image_format,omitempty"`   // Encoding of the finished image, empty for PNG
+	ImageQuality       int         `json:"image_quality,omitempty"`  // JPEG quality from 1 to 100, 0 for the default
+}
+
+// WithAspectRatio returns the settings resized to a width-to-height ratio
+// The pixel count stays about the same, so a widescreen or square render takes as long
+// as the 4:3 one it replaces.
+func (s RenderSettings) WithAspectRatio(aspect float64) RenderSettings {
+	if !(aspect > 0) {
+		return s
+	}
+	pixels := float64(s.Width * s.Height)
+	s.Width = max(1, int(math.Round(math.Sqrt(pixels*aspect))))
+	s.Height = max(1, int(math.Round(float64(s.Width)/aspect)))
+	return s
+}
+
+// SceneRenderSettings are the model's choices for rendering a scene, along with what the scene
+// itself contributes. The quality picks the RenderSettings, and Apply carries the rest onto them.
+type SceneRenderSettings struct {
+	Quality        RenderQuality      `json:"quality,omitempty"`        // Quality chosen by the model, empty to use the client's setting
+	Denoise        bool               `json:"denoise,omitempty"`        // Whether the model turned on denoising
+	Integrator     string             `json:"integrator,omitempty"`     // Integrator chosen by the model, empty for the default
+	ClampIndirect  float64            `json:"clamp_indirect,omitempty"` // Firefly clamp threshold chosen by the model, 0 for off
+	PostProcess    PostProcess        `json:"post_process"`             // Effects chosen by the model
+	ShadowCatchers *ShadowCatcherPass `json:"-"`                        // Shadows to draw after rendering, nil if the scene has no shadow catchers
+	Complexity     SceneComplexity    `json:"-"`                        // The scene's complexity, for auto quality
+}
+
+// Apply returns settings with the model's denoise, integrator, firefly clamp and post-processing
+func (s SceneRenderSettings) Apply(settings RenderSettings) RenderSettings {
+	settings.Denoise = s.Denoise
+	settings.Integrator = s.Integrator
+	settings.ClampIndirect = s.ClampIndirect
+	settings.PostProcess = s.PostProcess
+	return settings
+}
+
+// ParseRenderQuality converts a client-supplied quality string to a RenderQuality
+// Unknown or empty values default to draft
+func ParseRenderQuality(quality string) RenderQuality {
+	switch RenderQuality(quality) {
+	case QualityPreview, QualityHigh, QualityAuto:
+		return RenderQuality(quality)
+	default:
+		return QualityDraft
+	}
+}
+
+// parseRenderQualityStrict converts a tool argument to a RenderQuality, rejecting unknown values
+func parseRenderQualityStrict(quality string) (RenderQuality, error) {
+	switch RenderQuality(quality) {
+	case QualityPreview, QualityDraft, QualityHigh, QualityAuto:
+		return RenderQuality(quality), nil
+	default:
+		return "", fmt.Errorf("unsupported quality '%s' (supported: preview, draft, high, auto)", quality)
+	}
+}
+
+// GetRenderSettings returns the render settings for a quality preset
+//
+// Preview renders at half resolution with 2 samples and 4 bounces for near-instant
+// feedback while iterating. The result is very noisy, and effects that need many
+// bounces (glass, mirrors reflecting mirrors) may look dark or incomplete.
+//
+// Auto depends on the scene, so without one it falls back to draft; use RenderSettingsFor.
+func GetRenderSettings(quality RenderQuality) RenderSettings {
+	switch quality {
+	case QualityPreview:
+		return RenderSettings{Width: 200, Height: 150, SamplesPerPixel: 2, MaxDepth: 4,
+			AdaptiveMinSamples: defaultAdaptiveMinSamples, AdaptiveThreshold: defaultAdaptiveThreshold}
+	case QualityHigh:
+		return RenderSettings{Width: 400, Height: 300, SamplesPerPixel: 500, MaxDepth: 8,
+			AdaptiveMinSamples: defaultAdaptiveMinSamples, AdaptiveThreshold: defaultAdaptiveThreshold}
+	default:
+		return RenderSettings{Width: 400, Height: 300, SamplesPerPixel: 10, MaxDepth: 8,
+			AdaptiveMinSamples: defaultAdaptiveMinSamples, AdaptiveThreshold: defaultAdaptiveThreshold}
+	}
+}
+
+// Auto quality settings; see RenderSettingsFor
+const (
+	autoBaseSamples       = 50    // Matte scenes converge quickly
+	autoMetalSamples      = 100   // Glossy reflections need more samples to smooth out
+	autoDielectricSamples = 200   // Glass refracts light into caustics and is the noisiest to converge
+	autoDielectricDepth   = 12    // Rays pass through several glass surfaces before leaving
+	autoMinSamples        = 25    // Heavy scenes never drop below this
+	autoHeavyShapes       = 100   // Shape count above which samples are halved
+	autoHeavyTriangles    = 10000 // Triangle count above which samples are halved
+)
+
+// SceneComplexity is what auto quality looks at in a scene, taken from SceneManager.Statistics
+type SceneComplexity struct {
+	Shapes      int
+	Triangles   int // Estimated; see Statistics
+	Metals      int // Shapes with a metal material
+	Dielectrics int // Shapes with a dielectric (glass, water) material
+}
+
+// RenderSettingsFor returns the render settings for a quality preset in a scene
+//
+// Auto renders at the draft and high resolution, choosing samples and bounces from the scene:
+//   - 50 samples for matte scenes, 100 when any shape is metal, and 200 with 12 bounces
+//     when any shape is a dielectric, since glass needs the most samples and bounces
+//   - samples are halved, to no fewer than 25, for scenes over 100 shapes or 10,000
+//     triangles, where each sample costs the most
+//
+// An empty scene renders at draft. Every other quality ignores the scene.
+func RenderSettingsFor(quality RenderQuality, complexity SceneComplexity) RenderSettings {
+	if quality != QualityAuto || complexity.Shapes == 0 {
+		return GetRenderSettings(quality)
+	}
+
+	settings := GetRenderSettings(QualityDraft)
+	settings.SamplesPerPixel = autoBaseSamples
+	if complexity.Metals > 0 {
+		settings.SamplesPerPixel = autoMetalSamples
+	}
+	if complexity.Dielectrics > 0 {
+		settings.SamplesPerPixel = autoDielectricSamples
+		settings.MaxDepth = autoDielectricDepth
+	}
+	if complexity.Shapes > autoHeavyShapes || complexity.Triangles > autoHeavyTriangles {
+		settings.SamplesPerPixel = max(autoMinSamples, settings.SamplesPerPixel/2)
+	}
+	return settings
+}
+
+// GetThumbnailSettings returns the settings for the quick thumbnail shown while a preview renders
+// It is half the preview's width and height, so it renders almost instantly.
+func GetThumbnailSettings() RenderSettings {
+	return RenderSettings{Width: 100, Height: 75, SamplesPerPixel: 2, MaxDepth: 4,
+		AdaptiveMinSamples: defaultAdaptiveMinSamples, AdaptiveThreshold: defaultAdaptiveThreshold}
+}
+
+// validateAdaptiveSampling checks adaptive sampling settings supplied by the model
+func validateAdaptiveSampling(minSamples, threshold float64) error {
+	var errors ValidationErrors
+	if !(minSamples > 0 && minSamples <= 1) {
+		errors = append(errors, fmt.Sprintf("adaptive_min_samples must be in (0, 1], got %g", minSamples))
+	}
+	if !(threshold > 0) || math.IsInf(threshold, 1) {
+		errors = append(errors, fmt.Sprintf("adaptive_threshold must be greater than 0, got %g", threshold))
+	}
+	if len(errors) > 0 {
+		return errors
+	}
+	return nil
+}
+
+// RenderProgress is called as a render proceeds with the percentage of its passes finished
+type RenderProgress func(percent int)
+
+// renderPasses is how many progressive passes a render's samples are split into
+// The raytracer has no cancel hook: RenderPass takes no context and always renders every tile
+// of its pass. Splitting the samples into passes is what lets a cancelled render stop early.
+const renderPasses = 4
+
+// Integrators that can render a scene
+const (
+	// IntegratorPath traces paths from the camera only. It handles most scenes well and is the default.
+	IntegratorPath = "path"
+)
+
+// validateIntegrator checks an integrator name; empty selects the default
+func validateIntegrator(name string) error {
+	switch name {
+	case "", IntegratorPath:
+		return nil
+	}
+	return fmt.Errorf("unknown integrator '%s' (supported: %s)", name, IntegratorPath)
+}
+
+// integratorName returns the integrator a name selects, resolving empty to the default
+func integratorName(name string) string {
+	if name == "" {
+		return IntegratorPath
+	}
+	return name
+}
+
+// RenderImage renders a raytracer scene using the given settings
+// If ctx is cancelled before the render completes, RenderImage returns immediately with
+// an error wrapping ctx.Err() and the partially rendered image is discarded. The image is
+// denoised before it is returned if settings.Denoise is set.
+func RenderImage(ctx context.Context, raytracerScene *scene.Scene, settings RenderSettings) (image.Image, error) {
+	return RenderImageWithProgress(ctx, raytracerScene, settings, nil)
+}
+
+// RenderImageWithProgress renders like RenderImage, reporting progress as passes finish
+// The samples are rendered in up to renderPasses progressive passes, and progress is called
+// after each one with the percentage of passes done, in increasing order and ending with 100.
+// It runs on the render's goroutine, so it should return quickly, and it is never called after
+// RenderImageWithProgress returns. The raytracer
+// can't be interrupted mid-pass, so after a cancellation the background render keeps using the
+// CPU until its current pass finishes, then starts no more. A pass is a quarter of the samples,
+// or more for renders with fewer than renderPasses samples per pixel.
+func RenderImageWithProgress(ctx context.Context, raytracerScene *scene.Scene, settings RenderSettings, progress RenderProgress) (image.Image, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("render cancelled: %w", err)
+	}
+
+	applyRenderSettings(raytracerScene, settings)
+
+	config := renderer.DefaultProgressiveConfig()
+	config.MaxPasses = max(min(renderPasses, settings.SamplesPerPixel), 1)
+	config.MaxSamplesPerPixel = settings.SamplesPerPixel
+
+	if err := validateIntegrator(settings.Integrator); err != nil {
+		return nil, err
+	}
+
+	logger := renderer.NewDefaultLogger()
+	integ := integrator.NewPathTracingIntegrator(raytracerScene.SamplingConfig)
+
+	raytracer, err := renderer.NewProgressiveRaytracer(raytracerScene, config, integ, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create raytracer: %w", err)
+	}
+
+	passDone, stop := newPassProgress(config.MaxPasses, progress)
+	defer stop()
+
+	// Run the passes in the background so we can stop waiting as soon as ctx is done
+	type passResult struct {
+		img image.Image
+		err error
+	}
+	done := make(chan passResult, 1) // Buffered so an abandoned render doesn't leak a blocked goroutine
+	go func() {
+		var img image.Image
+		for pass := 1; pass <= config.MaxPasses; pass++ {
+			if ctx.Err() != nil {
+				return // RenderImageWithProgress has already returned
+			}
+			passImg, _, err := raytracer.RenderPass(pass, nil)
+			if err != nil {
+				done <- passResult{err: err}
+				return
+			}
+			img = passImg
+			passDone(pass)
+		}
+		done <- passResult{img: img}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, fmt.Errorf("render cancelled: %w", ctx.Err())
+	case result := <-done:
+		if result.err != nil {
+			return nil, fmt.Errorf("render failed: %w", result.err)
+		}
+		img := result.img
+		if settings.ClampIndirect > 0 {
+			// Before denoising, which would otherwise smear each firefly into a blotch
+			img = ClampFireflies(img, settings.ClampIndirect)
+		}
+		if settings.Denoise {
+			img = Denoise(img)
+		}
+		return img, nil
+	}
+}
+
+// newPassProgress returns a function to call as each pass finishes, which reports it to
+// progress, and a stop function after which it reports nothing more
+// A nil progress gives functions that do nothing.
+func newPassProgress(passes int, progress RenderProgress) (func(pass int), func()) {
+	var mu sync.Mutex
+	stopped := false
+	stop := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		stopped = true
+	}
+	passDone := func(pass int) {
+		mu.Lock()
+		defer mu.Unlock()
+		if progress != nil && !stopped {
+			progress(min(pass*100/max(passes, 1), 100))
+		}
+	}
+	return passDone, stop
+}
+
+// applyRenderSettings updates the scene's sampling config and camera to match the settings
+func applyRenderSettings(raytracerScene *scene.Scene, settings RenderSettings) {
+	raytracerScene.SamplingConfig.SamplesPerPixel = settings.SamplesPerPixel
+	raytracerScene.SamplingConfig.MaxDepth = settings.MaxDepth
+
+	// Zero leaves the adaptive sampling defaults from ToRaytracerScene in place
+	if settings.AdaptiveMinSamples > 0 {
+		raytracerScene.SamplingConfig.AdaptiveMinSamples = settings.AdaptiveMinSamples
+	}
+	if settings.AdaptiveThreshold > 0 {
+		raytracerScene.SamplingConfig.AdaptiveThreshold = settings.AdaptiveThreshold
+	}
+
+	// Rebuild the camera only if the resolution changed
+	if raytracerScene.SamplingConfig.Width == settings.Width && raytracerScene.SamplingConfig.Height == settings.Height {
+		return
+	}
+	raytracerScene.SamplingConfig.Width = settings.Width
+	raytracerScene.SamplingConfig.Height = settings.Height
+
+	cameraConfig := raytracerScene.CameraConfig
+	cameraConfig.Width = settings.Width
+	cameraConfig.AspectRatio = float64(settings.Width) / float64(settings.Height)
+	raytracerScene.CameraConfig = cameraConfig
+	raytracerScene.Camera = geometry.NewCamera(cameraConfig)
+}
@@ -0,0 +1,418 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/df07/scene-llm/agent/llm"
+)
+
+func TestParseRenderQuality(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected RenderQuality
+	}{
+		{"preview", QualityPreview},
+		{"draft", QualityDraft},
+		{"high", QualityHigh},
+		{"auto", QualityAuto},
+		{"", QualityDraft},
+		{"ultra", QualityDraft},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := ParseRenderQuality(tt.input); got != tt.expected {
+				t.Errorf("ParseRenderQuality(%q) = %q, expected %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGetRenderSettings(t *testing.T) {
+	preview := GetRenderSettings(QualityPreview)
+	draft := GetRenderSettings(QualityDraft)
+	high := GetRenderSettings(QualityHigh)
+
+	if draft.SamplesPerPixel != 10 {
+		t.Errorf("Expected draft to use 10 samples, got %d", draft.SamplesPerPixel)
+	}
+	if high.SamplesPerPixel != 500 {
+		t.Errorf("Expected high to use 500 samples, got %d", high.SamplesPerPixel)
+	}
+
+	// Preview must be cheaper than draft on every axis
+	if preview.SamplesPerPixel >= draft.SamplesPerPixel {
+		t.Errorf("Expected preview samples (%d) < draft samples (%d)", preview.SamplesPerPixel, draft.SamplesPerPixel)
+	}
+	if preview.Width >= draft.Width || preview.Height >= draft.Height {
+		t.Errorf("Expected preview resolution %dx%d to be smaller than draft %dx%d", preview.Width, preview.Height, draft.Width, draft.Height)
+	}
+	if preview.MaxDepth >= draft.MaxDepth {
+		t.Errorf("Expected preview max depth (%d) < draft max depth (%d)", preview.MaxDepth, draft.MaxDepth)
+	}
+
+	// Preview keeps the same aspect ratio as the default camera
+	if preview.Width*draft.Height != draft.Width*preview.Height {
+		t.Errorf("Expected preview aspect ratio to match draft, got %dx%d vs %dx%d", preview.Width, preview.Height, draft.Width, draft.Height)
+	}
+
+	// Thumbnails are smaller than previews, with the same aspect ratio
+	thumbnail := GetThumbnailSettings()
+	if thumbnail.Width >= preview.Width || thumbnail.SamplesPerPixel > preview.SamplesPerPixel {
+		t.Errorf("Expected thumbnail %dx%d at %d samples to be cheaper than preview", thumbnail.Width, thumbnail.Height, thumbnail.SamplesPerPixel)
+	}
+	if thumbnail.Width*preview.Height != preview.Width*thumbnail.Height {
+		t.Errorf("Expected thumbnail aspect ratio to match preview, got %dx%d", thumbnail.Width, thumbnail.Height)
+	}
+}
+
+func TestAutoRenderSettings(t *testing.T) {
+	shape := func(id string, material map[string]interface{}) ShapeRequest {
+		props := map[string]interface{}{"center": []interface{}{0.0, 0.0, 0.0}, "radius": 1.0}
+		if material != nil {
+			props["material"] = material
+		}
+		return ShapeRequest{ID: id, Type: "sphere", Properties: props}
+	}
+	metal := map[string]interface{}{"type": "metal", "albedo": []interface{}{0.8, 0.8, 0.8}, "fuzz": 0.1}
+	glass := map[string]interface{}{"type": "dielectric", "refractive_index": 1.5}
+
+	tests := []struct {
+		name     string
+		shapes   []ShapeRequest
+		samples  int
+		maxDepth int
+	}{
+		{"empty scene renders at draft", nil, 10, 8},
+		{"matte", []ShapeRequest{shape("ball", nil)}, 50, 8},
+		{"metal", []ShapeRequest{shape("ball", nil), shape("mirror", metal)}, 100, 8},
+		{"glass", []ShapeRequest{shape("mirror", metal), shape("lens", glass)}, 200, 12},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sm := NewSceneManager()
+			if err := sm.AddShapes(tt.shapes); err != nil {
+				t.Fatalf("AddShapes() failed: %v", err)
+			}
+			settings := sm.RenderSettings(QualityAuto)
+			if settings.SamplesPerPixel != tt.samples || settings.MaxDepth != tt.maxDepth {
+				t.Errorf("Expected %d samples and depth %d, got %d and %d", tt.samples, tt.maxDepth, settings.SamplesPerPixel, settings.MaxDepth)
+			}
+			if draft := GetRenderSettings(QualityDraft); settings.Width != draft.Width || settings.Height != draft.Height {
+				t.Errorf("Expected auto to render at the draft size, got %dx%d", settings.Width, settings.Height)
+			}
+		})
+	}
+
+	// Heavy scenes halve their samples, but not below the minimum
+	if got := RenderSettingsFor(QualityAuto, SceneComplexity{Shapes: 150, Dielectrics: 1}).SamplesPerPixel; got != 100 {
+		t.Errorf("Expected a heavy glass scene to use 100 samples, got %d", got)
+	}
+	if got := RenderSettingsFor(QualityAuto, SceneComplexity{Shapes: 1, Triangles: 20000}).SamplesPerPixel; got != 25 {
+		t.Errorf("Expected a heavy matte scene to use 25 samples, got %d", got)
+	}
+
+	// Other qualities ignore the scene
+	if got, want := RenderSettingsFor(QualityHigh, SceneComplexity{Shapes: 1, Dielectrics: 1}), GetRenderSettings(QualityHigh); got != want {
+		t.Errorf("Expected high quality to ignore the scene, got %+v", got)
+	}
+}
+
+func TestRenderSettingsWithAspectRatio(t *testing.T) {
+	draft := GetRenderSettings(QualityDraft)
+	if got := draft.WithAspectRatio(4.0 / 3); got != draft {
+		t.Errorf("Expected 4:3 to keep the draft size, got %dx%d", got.Width, got.Height)
+	}
+	if got := draft.WithAspectRatio(0); got != draft {
+		t.Errorf("Expected an unset aspect ratio to keep the draft size, got %dx%d", got.Width, got.Height)
+	}
+
+	pixels := float64(draft.Width * draft.Height)
+	for _, aspect := range []float64{16.0 / 9, 1, 9.0 / 16, 2.39} {
+		got := draft.WithAspectRatio(aspect)
+		if ratio := float64(got.Width) / float64(got.Height); math.Abs(ratio-aspect) > 0.01 {
+			t.Errorf("WithAspectRatio(%v) gave %dx%d, ratio %v", aspect, got.Width, got.Height, ratio)
+		}
+		if area := float64(got.Width * got.Height); math.Abs(area-pixels)/pixels > 0.01 {
+			t.Errorf("WithAspectRatio(%v) gave %dx%d, expected about %v pixels", aspect, got.Width, got.Height, pixels)
+		}
+		if got.SamplesPerPixel != draft.SamplesPerPixel {
+			t.Errorf("Expected WithAspectRatio to keep the other settings, got %+v", got)
+		}
+	}
+}
+
+func TestApplyRenderSettingsResizesCamera(t *testing.T) {
+	sm := newRenderableSceneManager(t)
+	raytracerScene, err := sm.ToRaytracerScene()
+	if err != nil {
+		t.Fatalf("ToRaytracerScene failed: %v", err)
+	}
+
+	settings := GetRenderSettings(QualityPreview)
+	applyRenderSettings(raytracerScene, settings)
+
+	if raytracerScene.SamplingConfig.Width != settings.Width || raytracerScene.SamplingConfig.Height != settings.Height {
+		t.Errorf("Expected sampling config %dx%d, got %dx%d", settings.Width, settings.Height,
+			raytracerScene.SamplingConfig.Width, raytracerScene.SamplingConfig.Height)
+	}
+	if raytracerScene.SamplingConfig.SamplesPerPixel != settings.SamplesPerPixel {
+		t.Errorf("Expected %d samples, got %d", settings.SamplesPerPixel, raytracerScene.SamplingConfig.SamplesPerPixel)
+	}
+	if raytracerScene.SamplingConfig.MaxDepth != settings.MaxDepth {
+		t.Errorf("Expected max depth %d, got %d", settings.MaxDepth, raytracerScene.SamplingConfig.MaxDepth)
+	}
+	if raytracerScene.CameraConfig.Width != settings.Width {
+		t.Errorf("Expected camera width %d, got %d", settings.Width, raytracerScene.CameraConfig.Width)
+	}
+}
+
+func TestApplyRenderSettingsAdaptiveSampling(t *testing.T) {
+	sm := newRenderableSceneManager(t)
+	raytracerScene, err := sm.ToRaytracerScene()
+	if err != nil {
+		t.Fatalf("ToRaytracerScene failed: %v", err)
+	}
+
+	// Unset values keep the defaults
+	applyRenderSettings(raytracerScene, RenderSettings{Width: 400, Height: 300, SamplesPerPixel: 10, MaxDepth: 8})
+	if got := raytracerScene.SamplingConfig; got.AdaptiveMinSamples != defaultAdaptiveMinSamples || got.AdaptiveThreshold != defaultAdaptiveThreshold {
+		t.Errorf("Expected default adaptive sampling, got min %v threshold %v", got.AdaptiveMinSamples, got.AdaptiveThreshold)
+	}
+
+	settings := GetRenderSettings(QualityDraft)
+	settings.AdaptiveMinSamples = 0.5
+	settings.AdaptiveThreshold = 0.01
+	applyRenderSettings(raytracerScene, settings)
+	if got := raytracerScene.SamplingConfig; got.AdaptiveMinSamples != 0.5 || got.AdaptiveThreshold != 0.01 {
+		t.Errorf("Expected min 0.5 threshold 0.01, got min %v threshold %v", got.AdaptiveMinSamples, got.AdaptiveThreshold)
+	}
+}
+
+func TestValidateAdaptiveSampling(t *testing.T) {
+	tests := []struct {
+		minSamples, threshold float64
+		valid                 bool
+	}{
+		{defaultAdaptiveMinSamples, defaultAdaptiveThreshold, true},
+		{1, 0.5, true},
+		{0, 0.05, false},
+		{1.5, 0.05, false},
+		{0.1, 0, false},
+		{0.1, -1, false},
+		{math.NaN(), 0.05, false},
+	}
+
+	for _, tt := range tests {
+		err := validateAdaptiveSampling(tt.minSamples, tt.threshold)
+		if (err == nil) != tt.valid {
+			t.Errorf("validateAdaptiveSampling(%v, %v) = %v, want valid=%v", tt.minSamples, tt.threshold, err, tt.valid)
+		}
+	}
+}
+
+func TestValidateIntegrator(t *testing.T) {
+	for _, name := range []string{"", IntegratorPath} {
+		if err := validateIntegrator(name); err != nil {
+			t.Errorf("validateIntegrator(%q) = %v, expected it to be accepted", name, err)
+		}
+	}
+	for _, name := range []string{"direct", "bdpt"} {
+		if err := validateIntegrator(name); err == nil || !strings.Contains(err.Error(), "supported: path") {
+			t.Errorf("Expected integrator %q to be rejected with the supported list, got %v", name, err)
+		}
+	}
+}
+
+func TestIntegratorSetting(t *testing.T) {
+	events := make(chan AgentEvent, 100)
+	agent := NewWithProvider(events, &MockProvider{}, "mock-model")
+	agent.sceneManager = newRenderableSceneManager(t)
+
+	render := func(args map[string]interface{}) ToolResult {
+		req := parseToolRequestFromFunctionCall(&llm.FunctionCall{Name: "render_scene", Arguments: args})
+		return agent.executeToolRequests(context.Background(), req, "render")
+	}
+	setQuality := func(args map[string]interface{}) ToolResult {
+		req := parseToolRequestFromFunctionCall(&llm.FunctionCall{Name: "set_render_quality", Arguments: args})
+		return agent.executeToolRequests(context.Background(), req, "quality")
+	}
+
+	if result := setQuality(map[string]interface{}{"quality": "preview"}); !result.Success {
+		t.Fatalf("Expected set_render_quality to succeed, got errors: %v", result.Errors)
+	}
+	if result := render(map[string]interface{}{}); !result.Success || result.Result.(map[string]interface{})["integrator"] != IntegratorPath {
+		t.Errorf("Expected renders to default to path tracing, got %+v", result)
+	}
+
+	if result := setQuality(map[string]interface{}{"quality": "preview", "integrator": "path"}); !result.Success {
+		t.Fatalf("Expected set_render_quality to succeed, got errors: %v", result.Errors)
+	}
+	if agent.Integrator() != IntegratorPath {
+		t.Errorf("Expected the path integrator, got %q", agent.Integrator())
+	}
+	if result := render(map[string]interface{}{"integrator": "path"}); !result.Success || result.Result.(map[string]interface{})["integrator"] != IntegratorPath {
+		t.Errorf("Expected the per-render integrator to be used, got %+v", result)
+	}
+
+	// Unknown integrators are rejected without changing anything
+	if result := setQuality(map[string]interface{}{"quality": "draft", "integrator": "bdpt"}); result.Success {
+		t.Error("Expected an unknown integrator to fail")
+	}
+	if agent.Integrator() != IntegratorPath || agent.RenderQuality() != QualityPreview {
+		t.Errorf("Expected the settings to be unchanged, got %q at %q", agent.Integrator(), agent.RenderQuality())
+	}
+	if result := render(map[string]interface{}{"integrator": "direct"}); result.Success {
+		t.Error("Expected render_scene with an unknown integrator to fail")
+	}
+}
+
+// newRenderableSceneManager returns a scene manager with a single lit sphere
+func newRenderableSceneManager(t *testing.T) *SceneManager {
+	t.Helper()
+	sm := NewSceneManager()
+	err := sm.AddShapes([]ShapeRequest{{
+		ID:   "sphere",
+		Type: "sphere",
+		Properties: map[string]interface{}{
+			"center": []interface{}{0.0, 0.0, 0.0},
+			"radius": 1.0,
+		},
+	}})
+	if err != nil {
+		t.Fatalf("Failed to add shape: %v", err)
+	}
+	return sm
+}
+
+func TestRenderImageCancelledMidRender(t *testing.T) {
+	sm := newRenderableSceneManager(t)
+	raytracerScene, err := sm.ToRaytracerScene()
+	if err != nil {
+		t.Fatalf("ToRaytracerScene failed: %v", err)
+	}
+
+	// High quality takes seconds in several passes; cancel as soon as the first pass finishes
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var cancelledAt time.Time
+	img, err := RenderImageWithProgress(ctx, raytracerScene, GetRenderSettings(QualityHigh), func(int) {
+		if cancelledAt.IsZero() {
+			cancelledAt = time.Now()
+			cancel()
+		}
+	})
+	elapsed := time.Since(cancelledAt)
+
+	if err == nil {
+		t.Fatal("Expected render to be cancelled")
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected error to wrap context.Canceled, got %v", err)
+	}
+	if img != nil {
+		t.Error("Expected no image from a cancelled render")
+	}
+	if cancelledAt.IsZero() {
+		t.Fatal("Expected a progress report before the render ended")
+	}
+	if elapsed > time.Second {
+		t.Errorf("Expected cancelled render to return promptly, took %v after the cancel", elapsed)
+	}
+}
+
+func TestPassProgress(t *testing.T) {
+	var reports []int
+	passDone, stop := newPassProgress(4, func(percent int) { reports = append(reports, percent) })
+	for pass := 1; pass <= 4; pass++ {
+		passDone(pass)
+	}
+	if want := []int{25, 50, 75, 100}; !reflect.DeepEqual(reports, want) {
+		t.Errorf("Expected progress %v, got %v", want, reports)
+	}
+
+	// Passes still running after the render returned are ignored
+	reports = nil
+	stop()
+	passDone(1)
+	if len(reports) != 0 {
+		t.Errorf("Expected no progress after stop, got %v", reports)
+	}
+
+	// Without a progress function there is nothing to report to
+	passDone, stop = newPassProgress(4, nil)
+	passDone(1)
+	stop()
+}
+
+func TestRenderImageWithProgress(t *testing.T) {
+	sm := newRenderableSceneManager(t)
+	raytracerScene, err := sm.ToRaytracerScene()
+	if err != nil {
+		t.Fatalf("ToRaytracerScene failed: %v", err)
+	}
+
+	var reports []int
+	_, err = RenderImageWithProgress(context.Background(), raytracerScene, GetRenderSettings(QualityDraft),
+		func(percent int) { reports = append(reports, percent) })
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if len(reports) == 0 || reports[len(reports)-1] != 100 {
+		t.Errorf("Expected progress to end at 100, got %v", reports)
+	}
+}
+
+func TestRenderSceneToolCancelled(t *testing.T) {
+	events := make(chan AgentEvent, 100)
+	agent := NewWithProvider(events, &MockProvider{}, "mock-model")
+	agent.sceneManager = newRenderableSceneManager(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	req := &RenderSceneRequest{
+		BaseToolRequest: BaseToolRequest{ToolType: "render_scene"},
+	}
+	result := agent.executeToolRequests(ctx, req, "test_call_1")
+
+	if result.Success {
+		t.Fatal("Expected render_scene to fail when context is cancelled")
+	}
+	if req.RenderedImage != nil {
+		t.Error("Expected no rendered image for a cancelled render")
+	}
+
+	foundCancelled := false
+	for len(events) > 0 {
+		if e, ok := (<-events).(RenderCancelledEvent); ok {
+			foundCancelled = true
+			if e.ID != "test_call_1" {
+				t.Errorf("Expected cancelled event ID 'test_call_1', got %q", e.ID)
+			}
+		}
+	}
+	if !foundCancelled {
+		t.Error("Expected a RenderCancelledEvent")
+	}
+}
+
+func TestSceneRenderSettingsApply(t *testing.T) {
+	scene := SceneRenderSettings{Quality: QualityHigh, Denoise: true, Integrator: IntegratorPath, ClampIndirect: 0.2, PostProcess: PostProcess{Vignette: 0.3}}
+	base := GetRenderSettings(QualityDraft)
+	settings := scene.Apply(base)
+	if !settings.Denoise || settings.Integrator != IntegratorPath || settings.ClampIndirect != 0.2 || settings.PostProcess != scene.PostProcess {
+		t.Errorf("Expected the scene's choices to be applied, got %+v", settings)
+	}
+	// The resolution and samples still come from the quality's settings
+	if settings.Width != base.Width || settings.SamplesPerPixel != base.SamplesPerPixel {
+		t.Errorf("Expected the base resolution and samples to be kept, got %+v", settings)
+	}
+}
@@ -0,0 +1,85 @@
+package agent
+
+// defaultShapeAlbedo is the gray of the lambertian material given to shapes without one
+var defaultShapeAlbedo = []float64{0.5, 0.5, 0.5}
+
+// ResolvedState returns a copy of the scene with the values ToRaytracerScene will render
+// Optional properties are filled in with their defaults, and values the conversion derives
+// are added alongside the ones they come from: a box's half_extents, and the cutoff_angle and
+// falloff_exponent a spot light's inner_angle and outer_angle become. Aliases and material
+// presets are already normalized when shapes are added, so they need no work here.
+func (sm *SceneManager) ResolvedState() *SceneState {
+	state := sm.Snapshot()
+	for i := range state.Shapes {
+		state.Shapes[i].Properties = sm.withDefaultMaterial(state.Shapes[i].Properties)
+		resolveShapeDefaults(&state.Shapes[i])
+	}
+	for i := range state.Lights {
+		resolveLightDefaults(&state.Lights[i])
+	}
+	state.Camera.AspectRatio = state.Camera.aspectRatio()
+	return state
+}
+
+// ResolvedSceneState returns the resolved scene as a JSON-friendly map, like GetSceneState
+// When no light is on, default_environment describes the sky the renderer falls back to.
+func (sm *SceneManager) ResolvedSceneState() map[string]interface{} {
+	state := sm.ResolvedState()
+	result := map[string]interface{}{
+		"shapes":       state.Shapes,
+		"lights":       state.Lights,
+		"camera":       state.Camera,
+		"revision":     sm.revisions.revision,
+		"soloed_light": sm.soloedLight(),
+		"resolved":     true,
+	}
+	if len(sm.renderedLights()) == 0 {
+		result["default_environment"] = map[string]interface{}{
+			"type":         "infinite_gradient_light",
+			"top_color":    defaultSkyTop,
+			"bottom_color": defaultSkyBottom,
+		}
+	}
+	return result
+}
+
+// resolveShapeDefaults fills in the optional properties of a shape, mirroring ToRaytracerScene
+func resolveShapeDefaults(shape *ShapeRequest) {
+	props := shape.Properties
+	setDefault := func(key string, value interface{}) {
+		if !hasProperty(props, key) {
+			props[key] = value
+		}
+	}
+
+	setDefault("material", map[string]interface{}{"type": "lambertian", "albedo": append([]float64(nil), defaultShapeAlbedo...)})
+	setDefault("opacity", 1.0)
+
+	switch shape.Type {
+	case "box":
+		setDefault("rotation", []float64{0, 0, 0})
+		half := vec3Property(props, "dimensions", vec3{}).scale(0.5)
+		props["half_extents"] = half[:]
+	case "disc":
+		setDefault("normal", []float64{0, 1, 0})
+	}
+}
+
+// resolveLightDefaults fills in the optional properties of a light, mirroring addLightToScene
+func resolveLightDefaults(light *LightRequest) {
+	for key, value := range resolvedLightDefaults(*light) {
+		light.Properties[key] = value
+	}
+
+	switch light.Type {
+	case "point_spot_light", "area_disc_spot_light":
+		// Inner and outer angles are rendered as the cutoff and falloff they convert to
+		if hasProperty(light.Properties, "outer_angle") {
+			cutoff, falloff := spotCone(light.Properties, 0, 0)
+			light.Properties["cutoff_angle"] = cutoff
+			light.Properties["falloff_exponent"] = falloff
+		}
+	case "infinite_gradient_light", "infinite_uniform_light", "infinite_physical_sky_light":
+		light.Properties["intensity"] = environmentIntensity(light.Properties)
+	}
+}
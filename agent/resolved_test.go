@@ -0,0 +1,112 @@
+package agent
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/df07/scene-llm/agent/llm"
+)
+
+func TestResolvedState(t *testing.T) {
+	sm := NewSceneManager()
+	err := sm.AddShapes([]ShapeRequest{
+		{ID: "crate", Type: "box", Properties: map[string]interface{}{
+			"center": []interface{}{0.0, 0.5, 0.0}, "dimensions": []interface{}{2.0, 1.0, 4.0}}},
+		{ID: "ball", Type: "sphere", Properties: map[string]interface{}{
+			"center": []interface{}{2.0, 1.0, 0.0}, "radius": 1.0, "opacity": 0.5,
+			"material": map[string]interface{}{"preset": "gold"}}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to add shapes: %v", err)
+	}
+	err = sm.AddLights([]LightRequest{
+		{ID: "spot", Type: "point_spot_light", Properties: map[string]interface{}{
+			"center": []interface{}{0.0, 5.0, 0.0}, "emission": []interface{}{10.0, 10.0, 10.0},
+			"inner_angle": 20.0, "outer_angle": 30.0}},
+		{ID: "panel", Type: "area_quad_light", Properties: map[string]interface{}{
+			"corner": []interface{}{-1.0, 4.0, -1.0}, "u": []interface{}{2.0, 0.0, 0.0}, "v": []interface{}{0.0, 0.0, 2.0},
+			"emission": []interface{}{3.0, 3.0, 3.0}}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to add lights: %v", err)
+	}
+
+	state := sm.ResolvedState()
+
+	crate := state.Shapes[0].Properties
+	if !reflect.DeepEqual(crate["half_extents"], []float64{1, 0.5, 2}) {
+		t.Errorf("Expected half_extents [1 0.5 2], got %v", crate["half_extents"])
+	}
+	if !reflect.DeepEqual(crate["rotation"], []float64{0, 0, 0}) || crate["opacity"] != 1.0 {
+		t.Errorf("Expected default rotation and opacity, got %v", crate)
+	}
+	if material := crate["material"].(map[string]interface{}); material["type"] != "lambertian" {
+		t.Errorf("Expected the default lambertian material, got %v", material)
+	}
+
+	// Values the shape sets are left alone
+	ball := state.Shapes[1].Properties
+	if ball["opacity"] != 0.5 || ball["material"].(map[string]interface{})["type"] != "metal" {
+		t.Errorf("Expected the ball's own opacity and expanded gold preset, got %v", ball)
+	}
+
+	spot := state.Lights[0].Properties
+	cutoff, falloff := spotCone(sm.FindLight("spot").Properties, 0, 0)
+	if spot["cutoff_angle"] != cutoff || spot["falloff_exponent"] != falloff || math.IsNaN(falloff) {
+		t.Errorf("Expected the cone the inner and outer angles render as, got %v", spot)
+	}
+	if !reflect.DeepEqual(spot["direction"], defaultSpotDirection) || spot["enabled"] != true {
+		t.Errorf("Expected the default direction and enabled, got %v", spot)
+	}
+	if panel := state.Lights[1].Properties; panel["two_sided"] != false {
+		t.Errorf("Expected two_sided to default to false, got %v", panel)
+	}
+
+	if state.Camera.AspectRatio != defaultAspectRatio {
+		t.Errorf("Expected the default aspect ratio, got %g", state.Camera.AspectRatio)
+	}
+
+	// Resolving works on a copy
+	if _, ok := sm.FindShape("crate").Properties["half_extents"]; ok {
+		t.Error("Expected the scene's own properties to be untouched")
+	}
+	if _, ok := sm.FindLight("spot").Properties["cutoff_angle"]; ok {
+		t.Error("Expected the scene's own light to be untouched")
+	}
+}
+
+func TestGetSceneStateResolved(t *testing.T) {
+	events := make(chan AgentEvent, 100)
+	agent := NewWithProvider(events, &MockProvider{}, "mock-model")
+	err := agent.sceneManager.AddShapes([]ShapeRequest{{ID: "ball", Type: "sphere", Properties: map[string]interface{}{
+		"center": []interface{}{0.0, 1.0, 0.0}, "radius": 1.0}}})
+	if err != nil {
+		t.Fatalf("Failed to add shape: %v", err)
+	}
+
+	req := parseToolRequestFromFunctionCall(&llm.FunctionCall{Name: "get_scene_state", Arguments: map[string]interface{}{"resolved": true}})
+	result := agent.executeToolRequests(context.Background(), req, "test_call_1")
+	if !result.Success {
+		t.Fatalf("Expected get_scene_state to succeed, got errors: %v", result.Errors)
+	}
+	state := result.Result.(map[string]interface{})
+	if state["resolved"] != true {
+		t.Errorf("Expected a resolved state, got %v", state)
+	}
+	if _, ok := state["default_environment"]; !ok {
+		t.Error("Expected default_environment for a scene without lights")
+	}
+	ball := state["shapes"].([]ShapeRequest)[0].Properties
+	if _, ok := ball["material"]; !ok {
+		t.Errorf("Expected the default material to be filled in, got %v", ball)
+	}
+
+	req = parseToolRequestFromFunctionCall(&llm.FunctionCall{Name: "get_scene_state", Arguments: map[string]interface{}{"resolved": true, "since": 0.0}})
+	result = agent.executeToolRequests(context.Background(), req, "test_call_2")
+	if result.Success || !strings.Contains(strings.Join(result.Errors, "; "), "can't be combined") {
+		t.Errorf("Expected resolved with since to fail, got %+v", result)
+	}
+}
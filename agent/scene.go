@@ -1,7 +1,12 @@
 package agent
 
 import (
+	"encoding/json"
 	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
 
 	"github.com/df07/go-progressive-raytracer/pkg/core"
 	"github.com/df07/go-progressive-raytracer/pkg/geometry"
@@ -14,6 +19,14 @@ type SceneState struct {
 	Shapes []ShapeRequest `json:"shapes"`
 	Lights []LightRequest `json:"lights"`
 	Camera CameraInfo     `json:"camera"`
+
+	// DefaultMaterial is given to shapes with neither a material nor a color, nil for gray lambertian
+	DefaultMaterial map[string]interface{} `json:"default_material,omitempty"`
+
+	// Camera is the active camera, named ActiveCamera ("main" if empty). Cameras holds the
+	// other named cameras, which select_camera swaps in. See cameras.go.
+	ActiveCamera string                `json:"active_camera,omitempty"`
+	Cameras      map[string]CameraInfo `json:"cameras,omitempty"`
 }
 
 // CameraInfo represents camera information
@@ -22,11 +35,48 @@ type CameraInfo struct {
 	LookAt   []float64 `json:"look_at"`
 	VFov     float64   `json:"vfov"`     // Vertical field of view in degrees
 	Aperture float64   `json:"aperture"` // Lens aperture for depth of field
+	// FStop, if set, is the f-number the aperture was derived from; see fstopToAperture
+	FStop float64 `json:"fstop,omitempty"`
+	// AspectRatio is the image's width divided by its height, or 0 for the default 4:3
+	AspectRatio float64 `json:"aspect_ratio,omitempty"`
+	// LookDirection, if set, replaces LookAt in SetCamera: look_at becomes center + direction.
+	// It is never stored, so LookAt stays the canonical form.
+	LookDirection []float64 `json:"look_direction,omitempty"`
+}
+
+// defaultAspectRatio matches the 4:3 resolutions of the render quality presets
+const defaultAspectRatio = 4.0 / 3.0
+
+// aspectRatio returns the camera's width-to-height ratio, defaulting to 4:3
+func (c CameraInfo) aspectRatio() float64 {
+	if c.AspectRatio > 0 {
+		return c.AspectRatio
+	}
+	return defaultAspectRatio
 }
 
+// MaxShapes is the most shapes a scene may hold, which keeps render times bounded
+const MaxShapes = 1000
+
 // SceneManager handles all scene state and operations
 type SceneManager struct {
-	state *SceneState
+	state     *SceneState
+	revisions sceneRevisions // Change tracking for incremental scene state
+
+	// soloLightID is a light rendered on its own for debugging, or "" when no light is soloed
+	// Other lights keep their stored state and come back when the solo ends.
+	soloLightID string
+
+	// shapeSnapshots holds each shape as it was before its last UpdateShape, keyed by its
+	// current ID, for RevertShape
+	shapeSnapshots map[string]ShapeRequest
+
+	// colorRand draws the colors for "random" colors and albedos; see nextRandomColor
+	colorRand *rand.Rand
+
+	// checkpoints are labeled copies of the scene state; see checkpoints.go
+	checkpoints   map[string]sceneCheckpoint
+	checkpointSeq int
 }
 
 // NewSceneManager creates a new scene manager with default scene
@@ -51,6 +101,17 @@ func (sm *SceneManager) AddShapes(shapes []ShapeRequest) error {
 		return nil
 	}
 
+	if len(sm.state.Shapes)+len(shapes) > MaxShapes {
+		return fmt.Errorf("adding %d shapes would exceed the limit of %d shapes (scene has %d)", len(shapes), MaxShapes, len(sm.state.Shapes))
+	}
+
+	// Expand material presets, property aliases and random colors into canonical properties before validating
+	expanded := make([]ShapeRequest, len(shapes))
+	for i, shape := range shapes {
+		expanded[i] = resolveRandomColors(normalizeShapeAliases(expandShapeMaterialPreset(shape)), sm.nextRandomColor)
+	}
+	shapes = expanded
+
 	// Validate unique IDs and shape properties
 	for _, newShape := range shapes {
 		// Validate shape properties
@@ -66,10 +127,40 @@ func (sm *SceneManager) AddShapes(shapes []ShapeRequest) error {
 
 	// Add shapes to scene
 	sm.state.Shapes = append(sm.state.Shapes, shapes...)
+	for _, shape := range shapes {
+		sm.revisions.shapeChanged(shape.ID)
+	}
 
 	return nil
 }
 
+// ValidateShape reports every reason AddShapes would reject the shape, without modifying the scene
+func (sm *SceneManager) ValidateShape(shape ShapeRequest) []string {
+	shape = resolveRandomColors(normalizeShapeAliases(expandShapeMaterialPreset(shape)), placeholderRandomColor)
+	errors := validationErrorList(validateShapeProperties(shape))
+	if shape.ID != "" && sm.FindShape(shape.ID) != nil {
+		errors = append(errors, fmt.Sprintf("shape with ID '%s' already exists", shape.ID))
+	}
+	return errors
+}
+
+// ShapeWarnings describes likely modeling mistakes in a shape, taking the scene's default
+// material into account. Presets are expanded first, so a "glass" quad is caught too.
+func (sm *SceneManager) ShapeWarnings(shape ShapeRequest) []string {
+	shape = expandShapeMaterialPreset(shape)
+	shape.Properties = sm.withDefaultMaterial(shape.Properties)
+	return shapeWarnings(shape)
+}
+
+// ValidateLight reports every reason AddLights would reject the light, without modifying the scene
+func (sm *SceneManager) ValidateLight(light LightRequest) []string {
+	errors := validationErrorList(validateLightProperties(light))
+	if light.ID != "" && sm.FindLight(light.ID) != nil {
+		errors = append(errors, fmt.Sprintf("light with ID '%s' already exists", light.ID))
+	}
+	return errors
+}
+
 // GetState returns a deep copy of the current scene state
 func (sm *SceneManager) GetState() *SceneState {
 	// Return a deep copy to prevent external mutation
@@ -77,6 +168,10 @@ func (sm *SceneManager) GetState() *SceneState {
 		Shapes: make([]ShapeRequest, len(sm.state.Shapes)),
 		Lights: make([]LightRequest, len(sm.state.Lights)),
 		Camera: sm.state.Camera,
+
+		DefaultMaterial: deepCopyProperties(sm.state.DefaultMaterial),
+		ActiveCamera:    sm.state.ActiveCamera,
+		Cameras:         copyCameras(sm.state.Cameras),
 	}
 
 	// Deep copy each shape including its properties map
@@ -114,13 +209,236 @@ func (sm *SceneManager) GetState() *SceneState {
 	return stateCopy
 }
 
+// Snapshot returns a copy of the scene state that shares nothing with the scene
+// Unlike GetState, nested materials and arrays are copied too, so the snapshot stays
+// unchanged however the scene is edited afterwards.
+func (sm *SceneManager) Snapshot() *SceneState {
+	return copySceneState(sm.state)
+}
+
+// ReplaceState replaces the whole scene with a copy of state
+// Every old shape and light is recorded as removed and every new one as changed, so
+// incremental scene state reports the swap. Any soloed light is cleared.
+func (sm *SceneManager) ReplaceState(state *SceneState) {
+	for _, shape := range sm.state.Shapes {
+		sm.revisions.shapeRemoved(shape.ID)
+	}
+	for _, light := range sm.state.Lights {
+		sm.revisions.lightRemoved(light.ID)
+	}
+
+	sm.state = copySceneState(state)
+	sm.soloLightID = ""
+	sm.shapeSnapshots = nil
+
+	for _, shape := range sm.state.Shapes {
+		sm.revisions.shapeChanged(shape.ID)
+	}
+	for _, light := range sm.state.Lights {
+		sm.revisions.lightChanged(light.ID)
+	}
+	sm.revisions.cameraChanged()
+}
+
+// copySceneState deep copies a scene state, including every property value
+func copySceneState(state *SceneState) *SceneState {
+	stateCopy := &SceneState{
+		Shapes: make([]ShapeRequest, len(state.Shapes)),
+		Lights: make([]LightRequest, len(state.Lights)),
+		Camera: state.Camera,
+
+		DefaultMaterial: deepCopyProperties(state.DefaultMaterial),
+		ActiveCamera:    state.ActiveCamera,
+		Cameras:         copyCameras(state.Cameras),
+	}
+	stateCopy.Camera.Center = append([]float64(nil), state.Camera.Center...)
+	stateCopy.Camera.LookAt = append([]float64(nil), state.Camera.LookAt...)
+
+	for i, shape := range state.Shapes {
+		stateCopy.Shapes[i] = ShapeRequest{ID: shape.ID, Type: shape.Type, Properties: deepCopyProperties(shape.Properties)}
+	}
+	for i, light := range state.Lights {
+		stateCopy.Lights[i] = LightRequest{ID: light.ID, Type: light.Type, Properties: deepCopyProperties(light.Properties)}
+	}
+	return stateCopy
+}
+
 // GetSceneState returns the complete scene state as a JSON-friendly map
 func (sm *SceneManager) GetSceneState() map[string]interface{} {
 	return map[string]interface{}{
-		"shapes": sm.state.Shapes,
-		"lights": sm.state.Lights,
-		"camera": sm.state.Camera,
+		"shapes":   sm.state.Shapes,
+		"lights":   sm.state.Lights,
+		"camera":   sm.state.Camera,
+		"revision": sm.revisions.revision,
+
+		// Name of the camera above, and every camera select_camera can switch to
+		"active_camera": sm.ActiveCameraID(),
+		"cameras":       sm.CameraIDs(),
+
+		// Material for shapes with neither a material nor a color, nil for gray lambertian
+		"default_material": sm.state.DefaultMaterial,
+
+		// Light rendered alone by solo_light, empty when every enabled light renders
+		"soloed_light": sm.soloedLight(),
+
+		// Direction each area quad light emits toward, derived from its u×v winding
+		"light_emission_normals": sm.lightEmissionNormals(),
+
+		// Likely problems worth fixing, such as lights pointing away from every shape
+		"warnings": sm.sceneWarnings(),
+	}
+}
+
+// sceneWarnings describes likely mistakes in the scene, for get_scene_state
+func (sm *SceneManager) sceneWarnings() []string {
+	warnings := []string{}
+	for _, id := range sm.DetectLightsFacingAway() {
+		warnings = append(warnings, fmt.Sprintf("light '%s' points away from the center of the scene, so it may light nothing; check its direction or normal (for area quad lights, swap u and v or set two_sided)", id))
 	}
+	for _, shape := range sm.state.Shapes {
+		warnings = append(warnings, sm.ShapeWarnings(shape)...)
+	}
+	return warnings
+}
+
+// DetectLightsFacingAway returns the IDs of enabled lights that emit to one side only and
+// point away from the center of the scene's bounding box
+// This is a heuristic: a light aimed away from the center can still light a shape off to
+// the side, but far more often it is a flipped normal or winding that leaves the scene dark.
+func (sm *SceneManager) DetectLightsFacingAway() []string {
+	min, max, ok := sm.SceneBounds()
+	if !ok {
+		return nil
+	}
+	center := vec3{(min[0] + max[0]) / 2, (min[1] + max[1]) / 2, (min[2] + max[2]) / 2}
+
+	var facingAway []string
+	for _, light := range sm.state.Lights {
+		if !lightEnabled(light) {
+			continue
+		}
+		position, direction, aimed := lightAim(light)
+		if aimed && direction.dot(center.sub(position)) < 0 {
+			facingAway = append(facingAway, light.ID)
+		}
+	}
+	return facingAway
+}
+
+// lightAim returns where a light sits and the direction it emits toward, for lights that
+// only emit to one side. ok is false for lights that emit in every direction, two-sided
+// area lights, and environment lights.
+func lightAim(light LightRequest) (position, direction vec3, ok bool) {
+	props := light.Properties
+	switch light.Type {
+	case "area_quad_light":
+		if twoSided, _ := props["two_sided"].(bool); twoSided {
+			return vec3{}, vec3{}, false
+		}
+		normal, hasNormal := quadLightNormal(light)
+		if !hasNormal {
+			return vec3{}, vec3{}, false
+		}
+		corner := vec3Property(props, "corner", vec3{})
+		u := vec3Property(props, "u", vec3{})
+		v := vec3Property(props, "v", vec3{})
+		return corner.add(u.scale(0.5)).add(v.scale(0.5)), vec3{normal[0], normal[1], normal[2]}, true
+
+	case "area_rect_light":
+		if quad, err := rectLightAsQuad(light); err == nil {
+			return lightAim(quad)
+		}
+
+	case "disc_spot_light", "area_disc_spot_light":
+		return vec3Property(props, "center", vec3{}), vec3Property(props, "normal", vec3{}), true
+
+	case "point_spot_light":
+		defaultDirection := vec3{defaultSpotDirection[0], defaultSpotDirection[1], defaultSpotDirection[2]}
+		return vec3Property(props, "center", vec3{}), vec3Property(props, "direction", defaultDirection), true
+	}
+	return vec3{}, vec3{}, false
+}
+
+// lightEmissionNormals returns the emission normal of every area quad light, keyed by light ID
+func (sm *SceneManager) lightEmissionNormals() map[string][]float64 {
+	normals := make(map[string][]float64)
+	for _, light := range sm.state.Lights {
+		if normal, ok := quadLightNormal(light); ok {
+			normals[light.ID] = normal
+		}
+	}
+	return normals
+}
+
+// quadLightNormal returns the unit normal an area quad light emits toward (normalized u×v)
+// Two-sided lights also emit in the opposite direction.
+func quadLightNormal(light LightRequest) ([]float64, bool) {
+	if light.Type == "area_rect_light" {
+		quad, err := rectLightAsQuad(light)
+		if err != nil {
+			return nil, false
+		}
+		light = quad
+	}
+	if light.Type != "area_quad_light" {
+		return nil, false
+	}
+	u := vec3Property(light.Properties, "u", vec3{})
+	v := vec3Property(light.Properties, "v", vec3{})
+	n := u.cross(v)
+	if n.length() == 0 {
+		return nil, false
+	}
+	n = n.normalize()
+	return []float64{n[0], n[1], n[2]}, true
+}
+
+// rectLightAsQuad converts an area rect light to the area quad light it renders as
+// The rect's width runs along u and its height along v, with u×v along the normal so the
+// quad emits the same way. u is kept horizontal unless the normal points straight up or down,
+// in which case the width runs along x. Every other property is carried over unchanged.
+func rectLightAsQuad(light LightRequest) (LightRequest, error) {
+	center, ok := extractFloatArray(light.Properties, "center", 3)
+	if !ok {
+		return LightRequest{}, fmt.Errorf("area_rect_light requires center property")
+	}
+	size, ok := extractFloatArray(light.Properties, "size", 2)
+	if !ok {
+		return LightRequest{}, fmt.Errorf("area_rect_light requires size property [width, height]")
+	}
+	if size[0] <= 0 || size[1] <= 0 {
+		return LightRequest{}, fmt.Errorf("area_rect_light size values must be positive")
+	}
+	normal := vec3Property(light.Properties, "normal", vec3{})
+	if normal.length() < degenerateEpsilon {
+		return LightRequest{}, fmt.Errorf("area_rect_light requires a non-zero normal property")
+	}
+
+	// Build an orthonormal basis with u×v = n
+	n := normal.normalize()
+	helper := vec3{0, 1, 0}
+	if math.Abs(n[1]) > 0.999 {
+		helper = vec3{0, 0, 1}
+	}
+	uDir := helper.cross(n).normalize()
+	vDir := n.cross(uDir)
+
+	u := uDir.scale(size[0])
+	v := vDir.scale(size[1])
+	corner := vec3{center[0], center[1], center[2]}.sub(u.scale(0.5)).sub(v.scale(0.5))
+
+	properties := make(map[string]interface{}, len(light.Properties))
+	for key, value := range light.Properties {
+		switch key {
+		case "center", "size", "normal":
+		default:
+			properties[key] = value
+		}
+	}
+	properties["corner"] = []interface{}{corner[0], corner[1], corner[2]}
+	properties["u"] = []interface{}{u[0], u[1], u[2]}
+	properties["v"] = []interface{}{v[0], v[1], v[2]}
+	return LightRequest{ID: light.ID, Type: "area_quad_light", Properties: properties}, nil
 }
 
 // BuildContext creates a context string describing the current scene state
@@ -148,6 +466,11 @@ func (sm *SceneManager) BuildContext() string {
 				size = radius
 			} else if dimsArray, ok := extractFloatArray(shape.Properties, "dimensions", 3); ok {
 				size = dimsArray[0] // Use first dimension as representative size
+			} else if radii, ok := extractFloatArray(shape.Properties, "radii", 3); ok {
+				size = math.Max(radii[0], math.Max(radii[1], radii[2])) // Use the largest radius
+			} else if baseSize, ok := extractFloatArray(shape.Properties, "base_size", 2); ok {
+				height, _ := extractFloat(shape.Properties, "height")
+				size = math.Max(baseSize[0], math.Max(baseSize[1], height)) // Use the largest dimension
 			}
 
 			// Extract color
@@ -167,14 +490,24 @@ func (sm *SceneManager) BuildContext() string {
 }
 
 // ClearScene resets the scene to empty state
+// The default camera becomes the only camera, named "main" again, and the default material is cleared.
 func (sm *SceneManager) ClearScene() {
+	for _, shape := range sm.state.Shapes {
+		sm.revisions.shapeRemoved(shape.ID)
+	}
 	sm.state.Shapes = []ShapeRequest{}
+	sm.soloLightID = ""
+	sm.shapeSnapshots = nil
+	sm.state.DefaultMaterial = nil
 	sm.state.Camera = CameraInfo{
 		Center:   []float64{0, 0, 5},
 		LookAt:   []float64{0, 0, 0},
 		VFov:     45.0,
 		Aperture: 0.0,
 	}
+	sm.state.ActiveCamera = ""
+	sm.state.Cameras = nil
+	sm.revisions.cameraChanged()
 }
 
 // GetShapeCount returns the number of shapes in the scene
@@ -182,6 +515,23 @@ func (sm *SceneManager) GetShapeCount() int {
 	return len(sm.state.Shapes)
 }
 
+// RenderableShapeCount returns the number of shapes that can show up in a render
+// Shadow catchers aren't rendered as surfaces, and shapes with opacity 0 let all light through,
+// so neither counts.
+func (sm *SceneManager) RenderableShapeCount() int {
+	count := 0
+	for _, shape := range sm.state.Shapes {
+		if isShadowCatcher(shape) {
+			continue
+		}
+		if opacity, ok := extractFloat(shape.Properties, "opacity"); ok && opacity <= 0 {
+			continue
+		}
+		count++
+	}
+	return count
+}
+
 // FindShape finds a shape by ID, returns nil if not found
 func (sm *SceneManager) FindShape(id string) *ShapeRequest {
 	for i := range sm.state.Shapes {
@@ -192,12 +542,220 @@ func (sm *SceneManager) FindShape(id string) *ShapeRequest {
 	return nil
 }
 
+// GetShapeCopy returns a deep copy of a shape by ID, or an error if not found
+func (sm *SceneManager) GetShapeCopy(id string) (*ShapeRequest, error) {
+	shape := sm.FindShape(id)
+	if shape == nil {
+		return nil, fmt.Errorf("shape with ID '%s' not found", id)
+	}
+
+	shapeCopy := ShapeRequest{
+		ID:         shape.ID,
+		Type:       shape.Type,
+		Properties: deepCopyProperties(shape.Properties),
+	}
+	return &shapeCopy, nil
+}
+
+// FindShapesByTag returns copies of the shapes tagged with tag, in scene order
+// Tags match case-insensitively, so "Head" finds a shape tagged "head".
+func (sm *SceneManager) FindShapesByTag(tag string) []ShapeRequest {
+	shapes := []ShapeRequest{}
+	for _, shape := range sm.state.Shapes {
+		tags, _ := extractStringArray(shape.Properties, "tags")
+		for _, t := range tags {
+			if strings.EqualFold(t, tag) {
+				shapes = append(shapes, ShapeRequest{ID: shape.ID, Type: shape.Type, Properties: deepCopyProperties(shape.Properties)})
+				break
+			}
+		}
+	}
+	return shapes
+}
+
+// shapeSnippet is a shape's portable JSON form, used by export_shape and import_shape
+// It leaves out the ID so a snippet can be imported under any name.
+type shapeSnippet struct {
+	Type       string                 `json:"type"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// ExportShape returns a shape as a JSON snippet that ImportShape can add to any scene
+func (sm *SceneManager) ExportShape(id string) (string, error) {
+	shape := sm.FindShape(id)
+	if shape == nil {
+		return "", fmt.Errorf("shape with ID '%s' not found", id)
+	}
+	data, err := json.Marshal(shapeSnippet{Type: shape.Type, Properties: shape.Properties})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode shape '%s': %w", id, err)
+	}
+	return string(data), nil
+}
+
+// ImportShape adds the shape in a JSON snippet from ExportShape under a new ID
+// The shape is validated like any other new shape. An id field in the snippet is ignored.
+func (sm *SceneManager) ImportShape(snippet, newID string) (*ShapeRequest, error) {
+	if newID == "" {
+		return nil, fmt.Errorf("new_id is required")
+	}
+
+	var parsed shapeSnippet
+	if err := json.Unmarshal([]byte(snippet), &parsed); err != nil {
+		return nil, fmt.Errorf("shape snippet is not valid JSON: %w", err)
+	}
+	if parsed.Type == "" || parsed.Properties == nil {
+		return nil, fmt.Errorf("shape snippet must be an object with type and properties, like the output of export_shape")
+	}
+
+	if err := sm.AddShapes([]ShapeRequest{{ID: newID, Type: parsed.Type, Properties: parsed.Properties}}); err != nil {
+		return nil, err
+	}
+	return sm.GetShapeCopy(newID)
+}
+
+// positionProperties lists the properties that place a shape in space, across all shape types
+var positionProperties = []string{"center", "corner", "base_center", "top_center"}
+
+// translateShape moves a shape by offset, shifting whichever position properties it has
+func translateShape(shape *ShapeRequest, offset vec3) {
+	for _, key := range positionProperties {
+		if _, ok := extractFloatArray(shape.Properties, key, 3); !ok {
+			continue
+		}
+		position := vec3Property(shape.Properties, key, vec3{}).add(offset)
+		shape.Properties[key] = []interface{}{position[0], position[1], position[2]}
+	}
+}
+
+// ArrayShapes fills a grid with copies of a shape
+// counts gives the number of cells along each axis and spacing the distance between them. The source
+// shape occupies cell (0,0,0); every other cell gets a copy with ID "<source>_<i>_<j>_<k>".
+// Either every copy is added or none are. Returns the IDs of the created shapes.
+func (sm *SceneManager) ArrayShapes(sourceID string, counts [3]int, spacing [3]float64) ([]string, error) {
+	source, err := sm.GetShapeCopy(sourceID)
+	if err != nil {
+		return nil, err
+	}
+
+	total := 1
+	for axis, count := range counts {
+		if count < 1 {
+			return nil, fmt.Errorf("array count along axis %d must be at least 1, got %d", axis, count)
+		}
+		total *= count
+		if total > MaxShapes {
+			break // Avoid overflow, the limit check below reports the error
+		}
+	}
+	if total == 1 {
+		return nil, fmt.Errorf("array counts %v create no copies, use a count greater than 1 on at least one axis", counts)
+	}
+	if len(sm.state.Shapes)+total-1 > MaxShapes {
+		return nil, fmt.Errorf("array of %v would add %d shapes, exceeding the limit of %d shapes (scene has %d)", counts, total-1, MaxShapes, len(sm.state.Shapes))
+	}
+
+	var clones []ShapeRequest
+	for i := 0; i < counts[0]; i++ {
+		for j := 0; j < counts[1]; j++ {
+			for k := 0; k < counts[2]; k++ {
+				if i == 0 && j == 0 && k == 0 {
+					continue // The source shape fills this cell
+				}
+				clone := ShapeRequest{
+					ID:         fmt.Sprintf("%s_%d_%d_%d", sourceID, i, j, k),
+					Type:       source.Type,
+					Properties: deepCopyProperties(source.Properties),
+				}
+				translateShape(&clone, vec3{float64(i) * spacing[0], float64(j) * spacing[1], float64(k) * spacing[2]})
+				clones = append(clones, clone)
+			}
+		}
+	}
+
+	if err := sm.AddShapes(clones); err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, len(clones))
+	for i, clone := range clones {
+		ids[i] = clone.ID
+	}
+	return ids, nil
+}
+
+// shapePropertyAliases maps alternative property names the model uses to the canonical
+// property, per shape type
+var shapePropertyAliases = map[string]map[string]string{
+	"box":    {"size": "dimensions"},
+	"sphere": {"size": "radius"},
+}
+
+// normalizeShapeAliases returns the shape with aliased properties renamed to their canonical names
+func normalizeShapeAliases(shape ShapeRequest) ShapeRequest {
+	shape.Properties = renameShapeAliases(shape.Type, shape.Properties)
+	return shape
+}
+
+// renameShapeAliases renames aliased properties to their canonical names, copying properties if anything changes
+// An alias is left in place if the canonical property is also set, so validation can report the conflict.
+func renameShapeAliases(shapeType string, properties map[string]interface{}) map[string]interface{} {
+	renamed, copied := properties, false
+	for alias, canonical := range shapePropertyAliases[shapeType] {
+		value, hasAlias := properties[alias]
+		if _, hasCanonical := properties[canonical]; !hasAlias || hasCanonical {
+			continue
+		}
+		if !copied {
+			renamed = make(map[string]interface{}, len(properties))
+			for key, v := range properties {
+				renamed[key] = v
+			}
+			copied = true
+		}
+		delete(renamed, alias)
+		renamed[canonical] = value
+	}
+	return renamed
+}
+
+// deepCopyProperties copies a property bag including nested maps (materials) and arrays
+func deepCopyProperties(properties map[string]interface{}) map[string]interface{} {
+	if properties == nil {
+		return nil
+	}
+	result := make(map[string]interface{}, len(properties))
+	for key, value := range properties {
+		result[key] = deepCopyValue(value)
+	}
+	return result
+}
+
+// deepCopyValue copies JSON-like values so the copy shares no mutable state with the original
+func deepCopyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return deepCopyProperties(v)
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = deepCopyValue(item)
+		}
+		return result
+	case []float64:
+		return append([]float64(nil), v...)
+	default:
+		return v
+	}
+}
+
 // UpdateShape updates an existing shape by ID
 func (sm *SceneManager) UpdateShape(id string, updates map[string]interface{}) error {
 	// Find the shape
 	for i := range sm.state.Shapes {
 		if sm.state.Shapes[i].ID == id {
 			shape := &sm.state.Shapes[i]
+			before := ShapeRequest{ID: shape.ID, Type: shape.Type, Properties: deepCopyProperties(shape.Properties)}
 
 			// Apply updates
 			if newID, ok := updates["id"].(string); ok && newID != "" {
@@ -205,6 +763,9 @@ func (sm *SceneManager) UpdateShape(id string, updates map[string]interface{}) e
 				if newID != shape.ID && sm.FindShape(newID) != nil {
 					return fmt.Errorf("shape with ID '%s' already exists", newID)
 				}
+				if newID != shape.ID {
+					sm.revisions.shapeRemoved(shape.ID)
+				}
 				shape.ID = newID
 			}
 
@@ -217,11 +778,24 @@ func (sm *SceneManager) UpdateShape(id string, updates map[string]interface{}) e
 				if shape.Properties == nil {
 					shape.Properties = make(map[string]interface{})
 				}
+				newProps = resolveRandomColorProperties(renameShapeAliases(shape.Type, newProps), sm.nextRandomColor)
 				for key, value := range newProps {
+					if key == "material" {
+						if mat, ok := value.(map[string]interface{}); ok {
+							value = expandMaterialPreset(mat)
+						}
+					}
 					shape.Properties[key] = value
 				}
 			}
 
+			if sm.shapeSnapshots == nil {
+				sm.shapeSnapshots = make(map[string]ShapeRequest)
+			}
+			delete(sm.shapeSnapshots, id)
+			sm.shapeSnapshots[shape.ID] = before
+
+			sm.revisions.shapeChanged(shape.ID)
 			return nil
 		}
 	}
@@ -229,12 +803,114 @@ func (sm *SceneManager) UpdateShape(id string, updates map[string]interface{}) e
 	return fmt.Errorf("shape with ID '%s' not found", id)
 }
 
+// RevertShape undoes the last UpdateShape of a shape, restoring it as it was before
+// Only one change is kept per shape, so a second revert fails until the shape is updated again.
+// A shape renamed by its last update gets its old ID back.
+func (sm *SceneManager) RevertShape(id string) error {
+	shape := sm.FindShape(id)
+	if shape == nil {
+		return fmt.Errorf("shape with ID '%s' not found", id)
+	}
+	before, ok := sm.shapeSnapshots[id]
+	if !ok {
+		return fmt.Errorf("shape '%s' has no previous state to revert to - it hasn't been updated since it was created or last reverted", id)
+	}
+	if before.ID != id && sm.FindShape(before.ID) != nil {
+		return fmt.Errorf("cannot revert shape '%s' to its old ID '%s': another shape now has that ID", id, before.ID)
+	}
+
+	*shape = before
+	delete(sm.shapeSnapshots, id)
+	if before.ID != id {
+		sm.revisions.shapeRemoved(id)
+	}
+	sm.revisions.shapeChanged(before.ID)
+	return nil
+}
+
+// ShapeSnapshot returns a copy of the state RevertShape would restore for a shape, if any
+func (sm *SceneManager) ShapeSnapshot(id string) (ShapeRequest, bool) {
+	before, ok := sm.shapeSnapshots[id]
+	if !ok {
+		return ShapeRequest{}, false
+	}
+	return ShapeRequest{ID: before.ID, Type: before.Type, Properties: deepCopyProperties(before.Properties)}, true
+}
+
+// RenameShapes gives shapes new IDs, mapping each old ID to its new one
+// The whole mapping is checked before anything is renamed, so either every shape is renamed
+// or none is. IDs may be swapped or chained (a->b, b->c) as long as every shape ends up with a
+// unique ID. Entries that map an ID to itself are ignored.
+func (sm *SceneManager) RenameShapes(mapping map[string]string) error {
+	var errors ValidationErrors
+
+	// Check every entry, in a stable order so errors are reproducible
+	oldIDs := make([]string, 0, len(mapping))
+	for oldID := range mapping {
+		oldIDs = append(oldIDs, oldID)
+	}
+	sort.Strings(oldIDs)
+	for _, oldID := range oldIDs {
+		if sm.FindShape(oldID) == nil {
+			errors = append(errors, fmt.Sprintf("shape with ID '%s' not found", oldID))
+		}
+		if mapping[oldID] == "" {
+			errors = append(errors, fmt.Sprintf("new ID for shape '%s' must be a non-empty string", oldID))
+		}
+	}
+	if len(errors) > 0 {
+		return errors
+	}
+
+	// Every shape's ID after the renames must be unique
+	owners := make(map[string]string, len(sm.state.Shapes))
+	for _, shape := range sm.state.Shapes {
+		newID := shape.ID
+		if renamed, ok := mapping[shape.ID]; ok {
+			newID = renamed
+		}
+		if other, taken := owners[newID]; taken {
+			errors = append(errors, fmt.Sprintf("shapes '%s' and '%s' would both be named '%s'", other, shape.ID, newID))
+			continue
+		}
+		owners[newID] = shape.ID
+	}
+	if len(errors) > 0 {
+		return errors
+	}
+
+	// Snapshots follow their shapes to the new IDs, so a later revert keeps the new name
+	snapshots := make(map[string]ShapeRequest, len(sm.shapeSnapshots))
+	for id, snapshot := range sm.shapeSnapshots {
+		if renamed, ok := mapping[id]; ok {
+			id = renamed
+			snapshot.ID = renamed
+		}
+		snapshots[id] = snapshot
+	}
+	sm.shapeSnapshots = snapshots
+
+	for i := range sm.state.Shapes {
+		shape := &sm.state.Shapes[i]
+		newID, ok := mapping[shape.ID]
+		if !ok || newID == shape.ID {
+			continue
+		}
+		sm.revisions.shapeRemoved(shape.ID)
+		shape.ID = newID
+		sm.revisions.shapeChanged(newID)
+	}
+	return nil
+}
+
 // RemoveShape removes a shape by ID
 func (sm *SceneManager) RemoveShape(id string) error {
 	for i := range sm.state.Shapes {
 		if sm.state.Shapes[i].ID == id {
 			// Remove shape by slicing
 			sm.state.Shapes = append(sm.state.Shapes[:i], sm.state.Shapes[i+1:]...)
+			delete(sm.shapeSnapshots, id)
+			sm.revisions.shapeRemoved(id)
 			return nil
 		}
 	}
@@ -242,6 +918,19 @@ func (sm *SceneManager) RemoveShape(id string) error {
 	return fmt.Errorf("shape with ID '%s' not found", id)
 }
 
+// RemoveShapes removes each shape by ID, continuing past failures
+// Returns the outcome for every requested ID; a nil error means the shape was removed
+func (sm *SceneManager) RemoveShapes(ids []string) map[string]error {
+	results := make(map[string]error, len(ids))
+	for _, id := range ids {
+		if _, done := results[id]; done {
+			continue // Duplicate ID, already handled
+		}
+		results[id] = sm.RemoveShape(id)
+	}
+	return results
+}
+
 // AddLights adds lights to the scene
 func (sm *SceneManager) AddLights(lights []LightRequest) error {
 	if len(lights) == 0 {
@@ -263,6 +952,9 @@ func (sm *SceneManager) AddLights(lights []LightRequest) error {
 
 	// Add all lights if validation passes
 	sm.state.Lights = append(sm.state.Lights, lights...)
+	for _, light := range lights {
+		sm.revisions.lightChanged(light.ID)
+	}
 	return nil
 }
 
@@ -297,6 +989,12 @@ func (sm *SceneManager) UpdateLight(id string, updates map[string]interface{}) e
 				return fmt.Errorf("light with ID '%s' already exists", newID)
 			}
 
+			if newID != light.ID {
+				sm.revisions.lightRemoved(light.ID)
+				if sm.soloLightID == light.ID {
+					sm.soloLightID = newID
+				}
+			}
 			light.ID = newID
 
 		case "type":
@@ -316,6 +1014,7 @@ func (sm *SceneManager) UpdateLight(id string, updates map[string]interface{}) e
 			if light.Properties == nil {
 				light.Properties = make(map[string]interface{})
 			}
+			clearReplacedSpotCone(light.Properties, newProps)
 			for propKey, propValue := range newProps {
 				light.Properties[propKey] = propValue
 			}
@@ -325,6 +1024,9 @@ func (sm *SceneManager) UpdateLight(id string, updates map[string]interface{}) e
 		}
 	}
 
+	// Record the change before validating since updates have already been applied
+	sm.revisions.lightChanged(light.ID)
+
 	// Validate the updated light
 	if err := validateLightProperties(*light); err != nil {
 		return fmt.Errorf("updated light validation failed: %w", err)
@@ -333,12 +1035,87 @@ func (sm *SceneManager) UpdateLight(id string, updates map[string]interface{}) e
 	return nil
 }
 
+// GetLightCopy returns a deep copy of a light by ID, or an error if not found
+func (sm *SceneManager) GetLightCopy(id string) (*LightRequest, error) {
+	light := sm.FindLight(id)
+	if light == nil {
+		return nil, fmt.Errorf("light with ID '%s' not found", id)
+	}
+
+	lightCopy := LightRequest{
+		ID:         light.ID,
+		Type:       light.Type,
+		Properties: deepCopyProperties(light.Properties),
+	}
+	return &lightCopy, nil
+}
+
+// SetLightEnabled switches a light on or off without removing it from the scene
+// Disabled lights stay in the scene state but are left out of renders.
+func (sm *SceneManager) SetLightEnabled(id string, enabled bool) error {
+	light := sm.FindLight(id)
+	if light == nil {
+		return fmt.Errorf("light with ID '%s' not found", id)
+	}
+	if light.Properties == nil {
+		light.Properties = make(map[string]interface{})
+	}
+	light.Properties["enabled"] = enabled
+	sm.revisions.lightChanged(light.ID)
+	return nil
+}
+
+// SoloLight renders only the given light until UnsoloLight is called, for finding which light
+// causes an effect. The other lights keep their stored state. Returns the IDs of the enabled
+// lights that are suppressed by the solo.
+func (sm *SceneManager) SoloLight(id string) ([]string, error) {
+	if sm.FindLight(id) == nil {
+		return nil, fmt.Errorf("light with ID '%s' not found", id)
+	}
+	sm.soloLightID = id
+
+	suppressed := []string{}
+	for _, light := range sm.state.Lights {
+		if light.ID != id && lightEnabled(light) {
+			suppressed = append(suppressed, light.ID)
+		}
+	}
+	return suppressed, nil
+}
+
+// UnsoloLight ends a solo so every enabled light renders again
+// Returns the ID of the light that was soloed, or "" if none was.
+func (sm *SceneManager) UnsoloLight() string {
+	id := sm.soloedLight()
+	sm.soloLightID = ""
+	return id
+}
+
+// soloedLight returns the ID of the soloed light, or "" if no light is soloed
+// A solo whose light has since been removed is ignored.
+func (sm *SceneManager) soloedLight() string {
+	if sm.soloLightID == "" || sm.FindLight(sm.soloLightID) == nil {
+		return ""
+	}
+	return sm.soloLightID
+}
+
+// lightEnabled reports whether a light is on, which is the default
+func lightEnabled(light LightRequest) bool {
+	enabled, ok := light.Properties["enabled"].(bool)
+	return !ok || enabled
+}
+
 // RemoveLight removes a light from the scene by its ID
 func (sm *SceneManager) RemoveLight(id string) error {
 	for i := range sm.state.Lights {
 		if sm.state.Lights[i].ID == id {
 			// Remove light by slicing
 			sm.state.Lights = append(sm.state.Lights[:i], sm.state.Lights[i+1:]...)
+			sm.revisions.lightRemoved(id)
+			if sm.soloLightID == id {
+				sm.soloLightID = ""
+			}
 			return nil
 		}
 	}
@@ -348,26 +1125,356 @@ func (sm *SceneManager) RemoveLight(id string) error {
 
 // SetCamera updates the camera configuration
 func (sm *SceneManager) SetCamera(camera CameraInfo) error {
+	camera, err := sm.validateCamera(camera)
+	if err != nil {
+		return err
+	}
+	sm.state.Camera = camera
+	sm.revisions.cameraChanged()
+	return nil
+}
+
+// validateCamera checks a camera for SetCamera or AddCamera and returns it in stored form
+// A look_direction becomes look_at, an fstop becomes an aperture, and a missing aspect ratio
+// is taken from the active camera.
+func (sm *SceneManager) validateCamera(camera CameraInfo) (CameraInfo, error) {
 	var errors ValidationErrors
 
+	hasDirection := camera.LookDirection != nil
+	if hasDirection {
+		switch {
+		case camera.LookAt != nil:
+			errors = append(errors, "look_at and look_direction are mutually exclusive - set one or the other")
+		case len(camera.LookDirection) != 3:
+			errors = append(errors, "camera look_direction must have exactly 3 values")
+		case !isFinite(vec3(camera.LookDirection).length()):
+			validateVec3Finite(&errors, camera.LookDirection, "camera look_direction")
+		case vec3(camera.LookDirection).length() == 0:
+			errors = append(errors, "camera look_direction must be non-zero")
+		case len(camera.Center) == 3:
+			lookAt := vec3(camera.Center).add(vec3(camera.LookDirection))
+			camera.LookAt = lookAt[:]
+		}
+		camera.LookDirection = nil
+	}
+
 	validateVec3Required(&errors, camera.Center, "camera center")
-	validateVec3Required(&errors, camera.LookAt, "camera look_at")
+	if !hasDirection {
+		validateVec3Required(&errors, camera.LookAt, "camera look_at")
+	}
 	validateVec3NotEqual(&errors, camera.Center, camera.LookAt, "camera center", "camera look_at")
 	validateFloatRangeExclusive(&errors, camera.VFov, 0, 180, "vfov")
 	validateFloatRangeInclusive(&errors, camera.Aperture, 0, 100, "aperture")
+	if camera.AspectRatio < 0 || !isFinite(camera.AspectRatio) {
+		errors = append(errors, fmt.Sprintf("aspect_ratio must be positive, got %g", camera.AspectRatio))
+	}
+	if camera.FStop < 0 || !isFinite(camera.FStop) {
+		errors = append(errors, fmt.Sprintf("fstop must be positive, got %g", camera.FStop))
+	} else if camera.FStop > 0 && camera.Aperture > 0 && camera.Aperture != fstopToAperture(camera.FStop, camera.VFov) {
+		// A stored fstop camera carries its derived aperture, so only reject an aperture that
+		// didn't come from the fstop - it is recomputed below
+		errors = append(errors, "fstop and aperture are mutually exclusive - set one or the other")
+	}
 
 	// Return all errors if any
 	if len(errors) > 0 {
-		return errors
+		return CameraInfo{}, errors
 	}
 
-	// Update camera state
-	sm.state.Camera = camera
+	if camera.FStop > 0 {
+		camera.Aperture = fstopToAperture(camera.FStop, camera.VFov)
+	}
+	if camera.AspectRatio == 0 {
+		camera.AspectRatio = sm.state.Camera.AspectRatio // Only SetAspectRatio changes it
+	}
+	return camera, nil
+}
+
+// SetAspectRatio sets the camera's width-to-height ratio, which renders keep by resizing
+// their width and height; see RenderSettings.WithAspectRatio
+func (sm *SceneManager) SetAspectRatio(aspect float64) error {
+	if !(aspect > 0) || math.IsInf(aspect, 0) {
+		return fmt.Errorf("aspect_ratio must be positive, got %g", aspect)
+	}
+	sm.state.Camera.AspectRatio = aspect
+	sm.revisions.cameraChanged()
 	return nil
 }
 
+// GetCamera returns a copy of the current camera
+func (sm *SceneManager) GetCamera() CameraInfo {
+	camera := sm.state.Camera
+	camera.Center = append([]float64(nil), camera.Center...)
+	camera.LookAt = append([]float64(nil), camera.LookAt...)
+	return camera
+}
+
+// minCameraDistance is the closest DollyCamera will move the camera to look_at
+const minCameraDistance = 1e-3
+
+// DollyCamera moves the camera along its view direction, keeping look_at fixed
+// The distance to look_at is multiplied by factor, so factor < 1 moves closer and factor > 1 moves away.
+// Since factor must be positive, the camera can never reach or cross look_at.
+func (sm *SceneManager) DollyCamera(factor float64) error {
+	if factor <= 0 || math.IsNaN(factor) || math.IsInf(factor, 0) {
+		return fmt.Errorf("zoom factor must be a positive number, got %v", factor)
+	}
+
+	camera := sm.GetCamera()
+	center := vec3{camera.Center[0], camera.Center[1], camera.Center[2]}
+	lookAt := vec3{camera.LookAt[0], camera.LookAt[1], camera.LookAt[2]}
+	offset := center.sub(lookAt).scale(factor)
+	if offset.length() < minCameraDistance {
+		return fmt.Errorf("zoom factor %v would move the camera too close to look_at (distance %.4g)", factor, offset.length())
+	}
+
+	newCenter := lookAt.add(offset)
+	camera.Center = []float64{newCenter[0], newCenter[1], newCenter[2]}
+	return sm.SetCamera(camera)
+}
+
+// SceneBounds returns the axis-aligned bounding box of all shapes in the scene
+// ok is false when the scene has no shapes.
+func (sm *SceneManager) SceneBounds() (min, max []float64, ok bool) {
+	var lo, hi vec3
+	for _, shape := range sm.state.Shapes {
+		shapeMin, shapeMax, hasBounds := shapeBounds(shape)
+		if !hasBounds {
+			continue
+		}
+		if !ok {
+			lo, hi, ok = shapeMin, shapeMax, true
+			continue
+		}
+		for i := range lo {
+			lo[i] = math.Min(lo[i], shapeMin[i])
+			hi[i] = math.Max(hi[i], shapeMax[i])
+		}
+	}
+	if !ok {
+		return nil, nil, false
+	}
+	return []float64{lo[0], lo[1], lo[2]}, []float64{hi[0], hi[1], hi[2]}, true
+}
+
+// shapeTriangles is the triangle count a shape tessellates to
+// Ellipsoids and pyramids are rendered as meshes. Other flat-faced shapes count the
+// triangles they would tessellate to; other curved shapes (spheres, discs, cylinders, cones)
+// are intersected analytically and never tessellated, so they add no triangles.
+var shapeTriangles = map[string]int{
+	"quad":      2,
+	"box":       12,
+	"ellipsoid": ellipsoidTriangles,
+	"pyramid":   pyramidTriangles,
+}
+
+// Statistics summarizes the scene's size and makeup as a JSON-friendly map, for get_scene_statistics
+// Shapes without a material count as lambertian, their default. bounds is omitted when the
+// scene has no shapes.
+func (sm *SceneManager) Statistics() map[string]interface{} {
+	shapesByType := make(map[string]int)
+	materialsByType := make(map[string]int)
+	triangles := 0
+	for _, shape := range sm.state.Shapes {
+		shapesByType[shape.Type]++
+		triangles += shapeTriangles[shape.Type]
+
+		materialType := "lambertian"
+		if mat, ok := extractMaterial(sm.withDefaultMaterial(shape.Properties)); ok {
+			if matType, ok := mat["type"].(string); ok {
+				materialType = matType
+			}
+		}
+		materialsByType[materialType]++
+	}
+
+	lightsByType := make(map[string]int)
+	for _, light := range sm.state.Lights {
+		lightsByType[light.Type]++
+	}
+
+	stats := map[string]interface{}{
+		"shape_count":         len(sm.state.Shapes),
+		"shapes_by_type":      shapesByType,
+		"materials_by_type":   materialsByType,
+		"light_count":         len(sm.state.Lights),
+		"lights_by_type":      lightsByType,
+		"estimated_triangles": triangles,
+	}
+	if min, max, ok := sm.SceneBounds(); ok {
+		stats["bounds"] = map[string]interface{}{"min": min, "max": max}
+	}
+	return stats
+}
+
+// Complexity summarizes the scene for auto quality, from Statistics
+func (sm *SceneManager) Complexity() SceneComplexity {
+	stats := sm.Statistics()
+	materials, _ := stats["materials_by_type"].(map[string]int)
+	triangles, _ := stats["estimated_triangles"].(int)
+	return SceneComplexity{
+		Shapes:      len(sm.state.Shapes),
+		Triangles:   triangles,
+		Metals:      materials["metal"],
+		Dielectrics: materials["dielectric"],
+	}
+}
+
+// RenderSettings returns the render settings for a quality preset in this scene
+func (sm *SceneManager) RenderSettings(quality RenderQuality) RenderSettings {
+	return RenderSettingsFor(quality, sm.Complexity())
+}
+
+// SetCameraPreset points the camera at the center of the scene from a named viewpoint
+// The camera is placed far enough away for the scene's bounding sphere to fit the vertical
+// field of view. vfov and aperture are kept.
+func (sm *SceneManager) SetCameraPreset(name string) error {
+	direction, ok := cameraPresets[name]
+	if !ok {
+		return fmt.Errorf("unknown camera preset '%s' (supported: front, back, left, right, top, iso)", name)
+	}
+
+	min, max, ok := sm.SceneBounds()
+	if !ok {
+		return fmt.Errorf("cannot frame an empty scene - add shapes first")
+	}
+	lo := vec3{min[0], min[1], min[2]}
+	hi := vec3{max[0], max[1], max[2]}
+	center := lo.add(hi).scale(0.5)
+	radius := hi.sub(lo).length() / 2
+	if radius == 0 {
+		radius = 1 // A single point - pick a sensible viewing distance
+	}
+
+	camera := sm.GetCamera()
+	distance := presetFramingMargin * radius / math.Sin(camera.VFov*math.Pi/360)
+	position := center.add(direction.normalize().scale(distance))
+
+	camera.Center = []float64{position[0], position[1], position[2]}
+	camera.LookAt = []float64{center[0], center[1], center[2]}
+	return sm.SetCamera(camera)
+}
+
+// FrameShape moves the camera so a single shape fills the view, keeping the current view direction
+// The shape's bounding sphere (its own sphere, for spheres) is fit in the narrower of the vertical
+// and horizontal fields of view, enlarged by margin as a fraction of its radius. The camera keeps
+// its vfov and aperture, and looks along its current direction at the shape's center, or from the
+// front if it has no direction.
+func (sm *SceneManager) FrameShape(id string, margin float64) error {
+	if !(margin >= 0) || math.IsInf(margin, 1) {
+		return fmt.Errorf("margin must be >= 0, got %g", margin)
+	}
+	shape := sm.FindShape(id)
+	if shape == nil {
+		return fmt.Errorf("shape '%s' not found", id)
+	}
+	lo, hi, ok := shapeBounds(*shape)
+	if !ok {
+		return fmt.Errorf("shape '%s' has no extent to frame", id)
+	}
+
+	center := lo.add(hi).scale(0.5)
+	radius := hi.sub(lo).length() / 2
+	if shape.Type == "sphere" {
+		radius, _ = extractFloat(shape.Properties, "radius")
+	}
+	if radius == 0 {
+		radius = 1 // A single point - pick a sensible viewing distance
+	}
+
+	camera := sm.GetCamera()
+	direction := vec3{camera.Center[0], camera.Center[1], camera.Center[2]}.
+		sub(vec3{camera.LookAt[0], camera.LookAt[1], camera.LookAt[2]})
+	if direction.length() == 0 {
+		direction = cameraPresets["front"]
+	}
+
+	halfFov := camera.VFov * math.Pi / 360
+	halfFov = math.Min(halfFov, math.Atan(camera.aspectRatio()*math.Tan(halfFov)))
+	distance := (1 + margin) * radius / math.Sin(halfFov)
+	position := center.add(direction.normalize().scale(distance))
+
+	camera.Center = []float64{position[0], position[1], position[2]}
+	camera.LookAt = []float64{center[0], center[1], center[2]}
+	return sm.SetCamera(camera)
+}
+
+// LightShape adds a light that shines on a shape from a direction
+// The light is placed distance away from the shape's center along direction (pointing from the
+// shape toward the light), and a point_spot_light is aimed back at the center. An empty lightID
+// becomes '<shape>_light', numbered if that is taken; an empty lightType is a point_spot_light.
+func (sm *SceneManager) LightShape(shapeID, lightID, lightType string, direction []float64, distance float64, emission []float64) (*LightRequest, error) {
+	shape := sm.FindShape(shapeID)
+	if shape == nil {
+		return nil, fmt.Errorf("shape '%s' not found", shapeID)
+	}
+	if len(direction) != 3 {
+		return nil, fmt.Errorf("direction must have exactly 3 values [x, y, z]")
+	}
+	lo, hi, ok := shapeBounds(*shape)
+	if !ok {
+		return nil, fmt.Errorf("shape '%s' has no extent to light", shapeID)
+	}
+
+	if lightID == "" {
+		lightID = shapeID + "_light"
+		for n := 2; sm.FindLight(lightID) != nil; n++ {
+			lightID = fmt.Sprintf("%s_light_%d", shapeID, n)
+		}
+	}
+	return sm.addAimedLight(lo.add(hi).scale(0.5), lightID, lightType, vec3{direction[0], direction[1], direction[2]}, distance, emission)
+}
+
+// addAimedLight adds a point or spot light distance away from target along direction
+// A point_spot_light (the default for an empty lightType) is aimed back at target.
+func (sm *SceneManager) addAimedLight(target vec3, lightID, lightType string, direction vec3, distance float64, emission []float64) (*LightRequest, error) {
+	if length := direction.length(); !isFinite(length) || length == 0 {
+		return nil, fmt.Errorf("direction must be a non-zero vector, got %v", direction[:])
+	}
+	if !(distance > 0) || math.IsInf(distance, 1) {
+		return nil, fmt.Errorf("distance must be positive, got %g", distance)
+	}
+	switch lightType {
+	case "":
+		lightType = "point_spot_light"
+	case "point_light", "point_spot_light":
+	default:
+		return nil, fmt.Errorf("only point_light and point_spot_light can be aimed at a target, got '%s'", lightType)
+	}
+
+	position := target.add(direction.normalize().scale(distance))
+	emissionInterface := make([]interface{}, len(emission))
+	for i, v := range emission {
+		emissionInterface[i] = v
+	}
+	properties := map[string]interface{}{
+		"center":   []interface{}{position[0], position[1], position[2]},
+		"emission": emissionInterface,
+	}
+	if lightType == "point_spot_light" {
+		aim := direction.normalize().scale(-1)
+		properties["direction"] = []interface{}{aim[0], aim[1], aim[2]}
+	}
+
+	if err := sm.AddLights([]LightRequest{{ID: lightID, Type: lightType, Properties: properties}}); err != nil {
+		return nil, err
+	}
+	return sm.GetLightCopy(lightID)
+}
+
 // SetEnvironmentLighting sets the background/environment lighting for the scene
-func (sm *SceneManager) SetEnvironmentLighting(lightingType string, topColor, bottomColor, emission []float64) error {
+// When replace is true, existing environment lights are removed first. When false, the new
+// light is stacked with the existing ones (e.g. a gradient sky plus a dim uniform fill), but
+// the scene may hold at most one environment light of each type.
+// Physical sky and daylight use sunDirection (toward the sun) and turbidity; other types
+// ignore them. Daylight adds a gradient sky and a separate sun light (see setDaylight).
+// Intensity multiplies the light's colors or emission when it is rendered, so the colors can
+// stay in [0, 1]; it is only stored when it isn't 1.
+func (sm *SceneManager) SetEnvironmentLighting(lightingType string, topColor, bottomColor, emission, sunDirection []float64, turbidity, intensity float64, replace bool) error {
+	if lightingType != "none" && (!(intensity >= 0) || math.IsInf(intensity, 1)) {
+		return fmt.Errorf("intensity must be >= 0, got %g", intensity)
+	}
+
 	// Validate lighting type
 	switch lightingType {
 	case "gradient":
@@ -386,8 +1493,10 @@ func (sm *SceneManager) SetEnvironmentLighting(lightingType string, topColor, bo
 			}
 		}
 
-		// Remove any existing environment lights and add gradient
-		sm.removeEnvironmentLights()
+		// Remove any existing environment lights (or make room to stack) and add gradient
+		if err := sm.prepareEnvironmentLight("infinite_gradient_light", replace); err != nil {
+			return err
+		}
 
 		// Convert to interface{} arrays for storage
 		topColorInterface := make([]interface{}, len(topColor))
@@ -399,13 +1508,14 @@ func (sm *SceneManager) SetEnvironmentLighting(lightingType string, topColor, bo
 			bottomColorInterface[i] = v
 		}
 
+		sm.revisions.lightChanged("environment_gradient")
 		sm.state.Lights = append(sm.state.Lights, LightRequest{
 			ID:   "environment_gradient",
 			Type: "infinite_gradient_light",
-			Properties: map[string]interface{}{
+			Properties: withEnvironmentIntensity(map[string]interface{}{
 				"top_color":    topColorInterface,
 				"bottom_color": bottomColorInterface,
-			},
+			}, intensity),
 		})
 
 	case "uniform":
@@ -419,8 +1529,10 @@ func (sm *SceneManager) SetEnvironmentLighting(lightingType string, topColor, bo
 			}
 		}
 
-		// Remove any existing environment lights and add uniform
-		sm.removeEnvironmentLights()
+		// Remove any existing environment lights (or make room to stack) and add uniform
+		if err := sm.prepareEnvironmentLight("infinite_uniform_light", replace); err != nil {
+			return err
+		}
 
 		// Convert to interface{} array for storage
 		emissionInterface := make([]interface{}, len(emission))
@@ -428,16 +1540,48 @@ func (sm *SceneManager) SetEnvironmentLighting(lightingType string, topColor, bo
 			emissionInterface[i] = v
 		}
 
+		sm.revisions.lightChanged("environment_uniform")
 		sm.state.Lights = append(sm.state.Lights, LightRequest{
 			ID:   "environment_uniform",
 			Type: "infinite_uniform_light",
-			Properties: map[string]interface{}{
+			Properties: withEnvironmentIntensity(map[string]interface{}{
 				"emission": emissionInterface,
-			},
+			}, intensity),
 		})
 
+	case "physical_sky":
+		if len(sunDirection) != 3 {
+			return fmt.Errorf("physical_sky lighting requires sun_direction as [x,y,z] array")
+		}
+		if sunDirection[0] == 0 && sunDirection[1] == 0 && sunDirection[2] == 0 {
+			return fmt.Errorf("sun_direction must not be the zero vector")
+		}
+		if turbidity < minTurbidity || turbidity > maxTurbidity {
+			return fmt.Errorf("turbidity must be between %g and %g, got %g", minTurbidity, maxTurbidity, turbidity)
+		}
+
+		// Remove any existing environment lights (or make room to stack) and add the sky
+		if err := sm.prepareEnvironmentLight("infinite_physical_sky_light", replace); err != nil {
+			return err
+		}
+
+		sm.revisions.lightChanged("environment_physical_sky")
+		sm.state.Lights = append(sm.state.Lights, LightRequest{
+			ID:   "environment_physical_sky",
+			Type: "infinite_physical_sky_light",
+			Properties: withEnvironmentIntensity(map[string]interface{}{
+				"sun_direction": []interface{}{sunDirection[0], sunDirection[1], sunDirection[2]},
+				"turbidity":     turbidity,
+			}, intensity),
+		})
+
+	case "daylight":
+		if err := sm.setDaylight(topColor, bottomColor, sunDirection, turbidity, intensity, replace); err != nil {
+			return err
+		}
+
 	case "none":
-		// Remove all environment lights
+		// Remove all environment lights, regardless of replace
 		sm.removeEnvironmentLights()
 
 	default:
@@ -447,30 +1591,111 @@ func (sm *SceneManager) SetEnvironmentLighting(lightingType string, topColor, bo
 	return nil
 }
 
-// removeEnvironmentLights removes all infinite lights from the scene
+// environmentLightIDs returns the IDs of the lights an environment lighting type creates
+func environmentLightIDs(lightingType string) []string {
+	switch lightingType {
+	case "gradient":
+		return []string{"environment_gradient"}
+	case "uniform":
+		return []string{"environment_uniform"}
+	case "physical_sky":
+		return []string{"environment_physical_sky"}
+	case "daylight":
+		return []string{"environment_gradient", daylightSunID}
+	}
+	return []string{}
+}
+
+// withEnvironmentIntensity adds intensity to an environment light's properties unless it is the default of 1
+func withEnvironmentIntensity(props map[string]interface{}, intensity float64) map[string]interface{} {
+	if intensity != 1 {
+		props["intensity"] = intensity
+	}
+	return props
+}
+
+// environmentIntensity returns an environment light's intensity multiplier, 1 if it has none
+func environmentIntensity(props map[string]interface{}) float64 {
+	if intensity, ok := extractFloat(props, "intensity"); ok {
+		return intensity
+	}
+	return 1
+}
+
+// prepareEnvironmentLight clears the way for a new environment light of lightType
+// Replacing removes all environment lights; stacking fails if one of the same type already
+// exists, since two identical backgrounds would just double the light.
+func (sm *SceneManager) prepareEnvironmentLight(lightType string, replace bool) error {
+	if replace {
+		sm.removeEnvironmentLights()
+		return nil
+	}
+
+	for _, light := range sm.state.Lights {
+		if light.Type == lightType {
+			return fmt.Errorf("scene already has a %s environment light ('%s') - set replace to true to change it", lightType, light.ID)
+		}
+	}
+	return nil
+}
+
+// removeEnvironmentLights removes all infinite lights from the scene, and the daylight sun
 func (sm *SceneManager) removeEnvironmentLights() {
 	filtered := make([]LightRequest, 0, len(sm.state.Lights))
 	for _, light := range sm.state.Lights {
-		if light.Type != "infinite_gradient_light" && light.Type != "infinite_uniform_light" {
+		if !isEnvironmentLightType(light.Type) && light.ID != daylightSunID {
 			filtered = append(filtered, light)
+		} else {
+			sm.revisions.lightRemoved(light.ID)
+			if sm.soloLightID == light.ID {
+				sm.soloLightID = ""
+			}
 		}
 	}
 	sm.state.Lights = filtered
 }
 
+// isEnvironmentLightType reports whether a light type is an infinite environment light
+func isEnvironmentLightType(lightType string) bool {
+	switch lightType {
+	case "infinite_gradient_light", "infinite_uniform_light", "infinite_physical_sky_light":
+		return true
+	}
+	return false
+}
+
+// renderedLights returns the lights that light renders: the soloed light if there is one,
+// which renders even if it is disabled, and otherwise every enabled light
+func (sm *SceneManager) renderedLights() []LightRequest {
+	soloID := sm.soloedLight()
+	var lights []LightRequest
+	for _, light := range sm.state.Lights {
+		if soloID != "" {
+			if light.ID == soloID {
+				lights = append(lights, light)
+			}
+		} else if lightEnabled(light) {
+			lights = append(lights, light)
+		}
+	}
+	return lights
+}
+
 // addLightsToScene adds all lights from the scene state to the raytracer scene
 func (sm *SceneManager) addLightsToScene(raytracerScene *scene.Scene) error {
-	// If no lights are defined, add default gradient lighting
-	if len(sm.state.Lights) == 0 {
+	enabled := sm.renderedLights()
+
+	// If no lights are on, add default gradient lighting
+	if len(enabled) == 0 {
 		raytracerScene.AddGradientInfiniteLight(
-			core.NewVec3(0.5, 0.7, 1.0), // topColor (blue sky)
-			core.NewVec3(1.0, 1.0, 1.0), // bottomColor (white horizon)
+			core.NewVec3(defaultSkyTop[0], defaultSkyTop[1], defaultSkyTop[2]),          // Blue sky
+			core.NewVec3(defaultSkyBottom[0], defaultSkyBottom[1], defaultSkyBottom[2]), // White horizon
 		)
 		return nil
 	}
 
-	// Add lights from scene state
-	for _, lightReq := range sm.state.Lights {
+	// Add lights from scene state (environment lights may be stacked, each is added separately)
+	for _, lightReq := range enabled {
 		err := sm.addLightToScene(raytracerScene, lightReq)
 		if err != nil {
 			return fmt.Errorf("failed to add light '%s': %w", lightReq.ID, err)
@@ -480,8 +1705,109 @@ func (sm *SceneManager) addLightsToScene(raytracerScene *scene.Scene) error {
 	return nil
 }
 
+// Defaults for optional point_spot_light properties
+var defaultSpotDirection = []float64{0, -1, 0} // Straight down
+
+const (
+	defaultSpotCutoffAngle     = 45.0
+	defaultSpotFalloffExponent = 5.0 // Sharp falloff
+)
+
+// disc_spot_light is rendered as a spot light with a wide cone and gentle falloff to approximate
+// a disc area light. Its cone isn't configurable.
+const (
+	discSpotCutoffAngle     = 170.0
+	discSpotFalloffExponent = 2.0
+)
+
+// point_light is rendered as a spot light whose cone covers every direction, with no falloff
+// toward its edge, since the raytracer has no omnidirectional point light
+const (
+	pointLightCutoffAngle     = 180.0
+	pointLightFalloffExponent = 0.0
+)
+
+// Spot lights shape their cone with one of these property pairs. cutoff_angle and
+// falloff_exponent map directly to the raytracer; inner_angle and outer_angle describe a
+// linear penumbra and are converted by spotCone.
+var spotConeProperties = [2][2]string{{"cutoff_angle", "falloff_exponent"}, {"inner_angle", "outer_angle"}}
+
+// spotCone returns the cutoff angle and falloff exponent to render a spot light with
+// A penumbra is approximated by cutting off at outer_angle with the falloff exponent that,
+// like a linear fade from inner_angle to outer_angle, gives half intensity halfway between.
+// This assumes the raytracer scales intensity by t^exponent, where t runs (in cosine) from 0
+// at the cone's edge to 1 on its axis. Missing cutoff and falloff values use the defaults.
+func spotCone(props map[string]interface{}, defaultCutoff, defaultFalloff float64) (cutoff, falloff float64) {
+	inner, hasInner := extractFloat(props, "inner_angle")
+	outer, hasOuter := extractFloat(props, "outer_angle")
+	if hasInner && hasOuter {
+		cosOuter := math.Cos(outer * math.Pi / 180)
+		cosMid := math.Cos((inner + outer) / 2 * math.Pi / 180)
+		t := (cosMid - cosOuter) / (1 - cosOuter)
+		return outer, math.Log(0.5) / math.Log(t)
+	}
+
+	cutoff, ok := extractFloat(props, "cutoff_angle")
+	if !ok {
+		cutoff = defaultCutoff
+	}
+	falloff, ok = extractFloat(props, "falloff_exponent")
+	if !ok {
+		falloff = defaultFalloff
+	}
+	return cutoff, falloff
+}
+
+// clearReplacedSpotCone removes a spot light's cone properties when an update sets the other
+// pair, so a light can switch between cutoff/falloff and inner/outer angles. Updates that set
+// both pairs are left for validation to reject.
+func clearReplacedSpotCone(props, updates map[string]interface{}) {
+	setsPair := func(pair [2]string) bool {
+		return hasProperty(updates, pair[0]) || hasProperty(updates, pair[1])
+	}
+	for i, pair := range spotConeProperties {
+		other := spotConeProperties[1-i]
+		if setsPair(pair) && !setsPair(other) {
+			delete(props, other[0])
+			delete(props, other[1])
+		}
+	}
+}
+
+// resolvedLightDefaults returns the values used for optional light properties the light doesn't set
+func resolvedLightDefaults(light LightRequest) map[string]interface{} {
+	defaults := map[string]interface{}{}
+	setDefault := func(key string, value interface{}) {
+		if !hasProperty(light.Properties, key) {
+			defaults[key] = value
+		}
+	}
+
+	setDefault("enabled", true)
+	switch light.Type {
+	case "point_spot_light":
+		setDefault("direction", append([]float64(nil), defaultSpotDirection...))
+		if !hasProperty(light.Properties, "outer_angle") {
+			setDefault("cutoff_angle", defaultSpotCutoffAngle)
+			setDefault("falloff_exponent", defaultSpotFalloffExponent)
+		}
+	case "disc_spot_light":
+		defaults["cutoff_angle"] = discSpotCutoffAngle
+		defaults["falloff_exponent"] = discSpotFalloffExponent
+	case "area_quad_light", "area_rect_light":
+		setDefault("two_sided", false)
+	}
+	if isAreaLight(light.Type) {
+		setDefault("power_mode", powerModeRadiance)
+	}
+	return defaults
+}
+
 // addLightToScene adds a single light to the raytracer scene
 func (sm *SceneManager) addLightToScene(raytracerScene *scene.Scene, lightReq LightRequest) error {
+	// Area lights given in total power are rendered at the radiance that spreads it over their area
+	lightReq = withRadianceEmission(lightReq)
+
 	switch lightReq.Type {
 	case "infinite_gradient_light":
 		// Extract top and bottom colors
@@ -494,9 +1820,11 @@ func (sm *SceneManager) addLightToScene(raytracerScene *scene.Scene, lightReq Li
 			return fmt.Errorf("gradient light requires bottom_color property")
 		}
 
+		intensity := environmentIntensity(lightReq.Properties)
+		top, bottom := vec3(topColor).scale(intensity), vec3(bottomColor).scale(intensity)
 		raytracerScene.AddGradientInfiniteLight(
-			core.NewVec3(topColor[0], topColor[1], topColor[2]),
-			core.NewVec3(bottomColor[0], bottomColor[1], bottomColor[2]),
+			core.NewVec3(top[0], top[1], top[2]),
+			core.NewVec3(bottom[0], bottom[1], bottom[2]),
 		)
 
 	case "infinite_uniform_light":
@@ -506,8 +1834,57 @@ func (sm *SceneManager) addLightToScene(raytracerScene *scene.Scene, lightReq Li
 			return fmt.Errorf("uniform light requires emission property")
 		}
 
+		color := vec3(emission).scale(environmentIntensity(lightReq.Properties))
 		raytracerScene.AddUniformInfiniteLight(
+			core.NewVec3(color[0], color[1], color[2]),
+		)
+
+	case "infinite_physical_sky_light":
+		sunDirection, ok := extractFloatArray(lightReq.Properties, "sun_direction", 3)
+		if !ok {
+			return fmt.Errorf("physical sky light requires sun_direction property")
+		}
+		turbidity, ok := extractFloat(lightReq.Properties, "turbidity")
+		if !ok {
+			return fmt.Errorf("physical sky light requires turbidity property")
+		}
+
+		// Approximate the sky with a gradient from horizon to zenith, plus a sun disc while it is up
+		sky := newPhysicalSky(sunDirection, turbidity)
+		intensity := environmentIntensity(lightReq.Properties)
+		zenith, horizon := sky.zenithColor().scale(intensity), sky.horizonColor().scale(intensity)
+		raytracerScene.AddGradientInfiniteLight(
+			core.NewVec3(zenith[0], zenith[1], zenith[2]),
+			core.NewVec3(horizon[0], horizon[1], horizon[2]),
+		)
+		if sky.sunAboveHorizon() {
+			center, radius := sky.sunCenter()
+			emission := sky.sunEmission().scale(intensity)
+			raytracerScene.AddSphereLight(
+				core.NewVec3(center[0], center[1], center[2]),
+				radius,
+				core.NewVec3(emission[0], emission[1], emission[2]),
+			)
+		}
+
+	case "point_light":
+		center, ok := extractFloatArray(lightReq.Properties, "center", 3)
+		if !ok {
+			return fmt.Errorf("point_light requires center property")
+		}
+		emission, ok := extractFloatArray(lightReq.Properties, "emission", 3)
+		if !ok {
+			return fmt.Errorf("point_light requires emission property")
+		}
+
+		// The cone covers every direction, so which way it points doesn't matter
+		raytracerScene.AddPointSpotLight(
+			core.NewVec3(center[0], center[1], center[2]),
+			core.NewVec3(center[0], center[1]-1, center[2]),
 			core.NewVec3(emission[0], emission[1], emission[2]),
+			pointLightCutoffAngle,
+			pointLightFalloffExponent,
+			0.0, // Point light has no radius
 		)
 
 	case "point_spot_light":
@@ -521,21 +1898,12 @@ func (sm *SceneManager) addLightToScene(raytracerScene *scene.Scene, lightReq Li
 			return fmt.Errorf("point_spot_light requires emission property")
 		}
 
-		// Extract optional properties
+		// Extract optional properties, using defaults for any that aren't set
 		direction, hasDirection := extractFloatArray(lightReq.Properties, "direction", 3)
-		cutoffAngle, hasCutoff := extractFloat(lightReq.Properties, "cutoff_angle")
-		falloffExponent, hasFalloff := extractFloat(lightReq.Properties, "falloff_exponent")
-
-		// Set defaults for optional parameters
 		if !hasDirection {
-			direction = []float64{0, -1, 0} // Default downward direction
-		}
-		if !hasCutoff {
-			cutoffAngle = 45.0 // Default 45 degree cone
-		}
-		if !hasFalloff {
-			falloffExponent = 5.0 // Default sharp falloff
+			direction = defaultSpotDirection
 		}
+		cutoffAngle, falloffExponent := spotCone(lightReq.Properties, defaultSpotCutoffAngle, defaultSpotFalloffExponent)
 
 		// Calculate target point from center and direction
 		to := core.NewVec3(
@@ -579,6 +1947,25 @@ func (sm *SceneManager) addLightToScene(raytracerScene *scene.Scene, lightReq Li
 			core.NewVec3(emission[0], emission[1], emission[2]),
 		)
 
+		// Quad lights emit toward u×v; a two-sided light adds a second quad with the
+		// winding reversed so it also emits from the back face
+		if twoSided, _ := lightReq.Properties["two_sided"].(bool); twoSided {
+			raytracerScene.AddQuadLight(
+				core.NewVec3(corner[0], corner[1], corner[2]),
+				core.NewVec3(v[0], v[1], v[2]),
+				core.NewVec3(u[0], u[1], u[2]),
+				core.NewVec3(emission[0], emission[1], emission[2]),
+			)
+		}
+
+	case "area_rect_light":
+		// Rect lights are quad lights described by their center, size and facing
+		quad, err := rectLightAsQuad(lightReq)
+		if err != nil {
+			return err
+		}
+		return sm.addLightToScene(raytracerScene, quad)
+
 	case "disc_spot_light":
 		// For now, we'll create a disc light using spot light with wide angle
 		// Extract required properties
@@ -611,8 +1998,8 @@ func (sm *SceneManager) addLightToScene(raytracerScene *scene.Scene, lightReq Li
 			core.NewVec3(center[0], center[1], center[2]),
 			to,
 			core.NewVec3(emission[0], emission[1], emission[2]),
-			170.0, // Wide cone angle to approximate disc area light
-			2.0,   // Gentle falloff
+			discSpotCutoffAngle,
+			discSpotFalloffExponent,
 			radius,
 		)
 
@@ -655,14 +2042,10 @@ func (sm *SceneManager) addLightToScene(raytracerScene *scene.Scene, lightReq Li
 		if !ok {
 			return fmt.Errorf("area_disc_spot_light requires emission property")
 		}
-		cutoffAngle, ok := extractFloat(lightReq.Properties, "cutoff_angle")
-		if !ok {
-			return fmt.Errorf("area_disc_spot_light requires cutoff_angle property")
-		}
-		falloffExponent, ok := extractFloat(lightReq.Properties, "falloff_exponent")
-		if !ok {
-			return fmt.Errorf("area_disc_spot_light requires falloff_exponent property")
+		if !hasProperty(lightReq.Properties, "cutoff_angle") && !hasProperty(lightReq.Properties, "outer_angle") {
+			return fmt.Errorf("area_disc_spot_light requires cutoff_angle and falloff_exponent, or inner_angle and outer_angle")
 		}
+		cutoffAngle, falloffExponent := spotCone(lightReq.Properties, 0, 0)
 
 		// Calculate target point from center and normal
 		to := core.NewVec3(
@@ -687,26 +2070,82 @@ func (sm *SceneManager) addLightToScene(raytracerScene *scene.Scene, lightReq Li
 	return nil
 }
 
-// RenderQuality represents different rendering quality presets
-type RenderQuality string
+// createMaterial builds a raytracer material from a validated material property bag
+// depth tracks mix nesting so malformed input can't recurse without bound
+func createMaterial(mat map[string]interface{}, depth int) material.Material {
+	matType, _ := mat["type"].(string)
+	switch matType {
+	case "lambertian":
+		albedo, _ := extractFloatArray(mat, "albedo", 3)
+		return material.NewLambertian(core.NewVec3(albedo[0], albedo[1], albedo[2]))
+	case "metal":
+		albedo, _ := extractFloatArray(mat, "albedo", 3)
+		fuzz, _ := extractFloat(mat, "fuzz")
+		return material.NewMetal(core.NewVec3(albedo[0], albedo[1], albedo[2]), fuzz)
+	case "dielectric":
+		refractiveIndex, _ := extractFloat(mat, "refractive_index")
+		return material.NewDielectric(refractiveIndex)
+	case "mix":
+		materialA, okA := mat["material_a"].(map[string]interface{})
+		materialB, okB := mat["material_b"].(map[string]interface{})
+		if okA && okB && depth < maxMaterialNestingDepth {
+			factor, _ := extractFloat(mat, "factor")
+			return newMixMaterial(createMaterial(materialA, depth+1), createMaterial(materialB, depth+1), factor)
+		}
+	}
 
-const (
-	QualityDraft RenderQuality = "draft"
-	QualityHigh  RenderQuality = "high"
-)
+	// Unknown material type - use default gray Lambertian
+	return material.NewLambertian(core.NewVec3(0.5, 0.5, 0.5))
+}
+
+// createShapeMaterial builds a shape's material, applying its optional opacity
+func createShapeMaterial(properties map[string]interface{}) material.Material {
+	var shapeMaterial material.Material
+	if mat, hasMaterial := extractMaterial(properties); hasMaterial {
+		shapeMaterial = createMaterial(mat, 0)
+	} else {
+		// No material specified - use default gray Lambertian
+		shapeMaterial = material.NewLambertian(core.NewVec3(0.5, 0.5, 0.5))
+	}
+
+	if opacity, ok := extractFloat(properties, "opacity"); ok && opacity < 1 {
+		shapeMaterial = applyOpacity(shapeMaterial, opacity)
+	}
+	return shapeMaterial
+}
+
+// applyOpacity makes a material translucent without refraction
+// A dielectric with refractive index 1.0 matches the surrounding air, so rays it transmits
+// continue straight through unbent. Mixing it in lets a (1 - opacity) share of rays pass
+// through the surface while the rest are shaded by the original material. The light that
+// passes through isn't tinted: the color comes only from the opaque share. The dielectric
+// also keeps its Fresnel reflection, which is zero head-on at index 1.0 but grows towards
+// glancing angles, so edge-on surfaces show a faint sheen.
+func applyOpacity(base material.Material, opacity float64) material.Material {
+	passThrough := material.NewDielectric(1.0)
+	return newMixMaterial(base, passThrough, 1-opacity)
+}
+
+// newMixMaterial blends two materials: each ray that hits the surface is shaded by b with
+// probability factor and by a otherwise, so factor 0 is all a and 1 is all b
+// This is the only use of the raytracer's material.NewMix (see specs/materials.md).
+func newMixMaterial(a, b material.Material, factor float64) material.Material {
+	return material.NewMix(a, b, factor)
+}
 
 // ToRaytracerScene converts the scene state to a raytracer scene
 func (sm *SceneManager) ToRaytracerScene() (*scene.Scene, error) {
 	// Standard scene configuration
 	// Quality-specific rendering settings are applied by the renderer, not here
+	size := GetRenderSettings(QualityDraft).WithAspectRatio(sm.state.Camera.aspectRatio())
 	samplingConfig := scene.SamplingConfig{
-		Width:                     400,
-		Height:                    300,
+		Width:                     size.Width,
+		Height:                    size.Height,
 		SamplesPerPixel:           10,
 		MaxDepth:                  8,
 		RussianRouletteMinBounces: 3,
-		AdaptiveMinSamples:        0.1,
-		AdaptiveThreshold:         0.05,
+		AdaptiveMinSamples:        defaultAdaptiveMinSamples,
+		AdaptiveThreshold:         defaultAdaptiveThreshold,
 	}
 
 	// Camera using our scene's camera settings
@@ -725,6 +2164,11 @@ func (sm *SceneManager) ToRaytracerScene() (*scene.Scene, error) {
 	// Create shapes
 	var sceneShapes []geometry.Shape
 	for _, shapeReq := range sm.state.Shapes {
+		// Shadow catchers are invisible to the path tracer; ShadowCatcherPass draws their shadows
+		if isShadowCatcher(shapeReq) {
+			continue
+		}
+
 		// Extract common properties
 		var size float64 = 1.0 // Default size
 
@@ -736,29 +2180,7 @@ func (sm *SceneManager) ToRaytracerScene() (*scene.Scene, error) {
 		}
 
 		// Create material from shape properties
-		var shapeMaterial material.Material
-		if mat, hasMaterial := extractMaterial(shapeReq.Properties); hasMaterial {
-			// Extract material from shape properties
-			matType, _ := mat["type"].(string)
-			switch matType {
-			case "lambertian":
-				albedo, _ := extractFloatArray(mat, "albedo", 3)
-				shapeMaterial = material.NewLambertian(core.NewVec3(albedo[0], albedo[1], albedo[2]))
-			case "metal":
-				albedo, _ := extractFloatArray(mat, "albedo", 3)
-				fuzz, _ := extractFloat(mat, "fuzz")
-				shapeMaterial = material.NewMetal(core.NewVec3(albedo[0], albedo[1], albedo[2]), fuzz)
-			case "dielectric":
-				refractiveIndex, _ := extractFloat(mat, "refractive_index")
-				shapeMaterial = material.NewDielectric(refractiveIndex)
-			default:
-				// Unknown material type - use default gray Lambertian
-				shapeMaterial = material.NewLambertian(core.NewVec3(0.5, 0.5, 0.5))
-			}
-		} else {
-			// No material specified - use default gray Lambertian
-			shapeMaterial = material.NewLambertian(core.NewVec3(0.5, 0.5, 0.5))
-		}
+		shapeMaterial := createShapeMaterial(sm.withDefaultMaterial(shapeReq.Properties))
 
 		// Create geometry based on type
 		var shape geometry.Shape
@@ -770,11 +2192,22 @@ func (sm *SceneManager) ToRaytracerScene() (*scene.Scene, error) {
 				copy(center[:], centerArray)
 			}
 
+			// Optional rotation orients the sphere's surface frame. No current material varies
+			// across the surface, so it is kept in the properties (and round-trips) but not applied yet.
 			shape = geometry.NewSphere(
 				core.NewVec3(center[0], center[1], center[2]),
 				size,
 				shapeMaterial,
 			)
+		case "ellipsoid":
+			center := vec3Property(shapeReq.Properties, "center", vec3{})
+			radii := vec3Property(shapeReq.Properties, "radii", vec3{1, 1, 1})
+			shape = ellipsoidMesh(center, radii).toRaytracer(shapeMaterial)
+		case "pyramid":
+			center := vec3Property(shapeReq.Properties, "center", vec3{})
+			baseSize, _ := extractFloatArray(shapeReq.Properties, "base_size", 2)
+			height, _ := extractFloat(shapeReq.Properties, "height")
+			shape = pyramidMesh(center, baseSize[0], baseSize[1], height).toRaytracer(shapeMaterial)
 		case "box":
 			// Extract center
 			var center [3]float64
@@ -1,6 +1,10 @@
 package agent
 
 import (
+	"context"
+	"math"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/df07/scene-llm/agent/llm"
@@ -59,7 +63,7 @@ func TestSetEnvironmentLighting(t *testing.T) {
 			// Clear lights before each test
 			sm.removeEnvironmentLights()
 
-			err := sm.SetEnvironmentLighting(tt.lightingType, tt.topColor, tt.bottomColor, tt.emission)
+			err := sm.SetEnvironmentLighting(tt.lightingType, tt.topColor, tt.bottomColor, tt.emission, nil, 0, 1, true)
 
 			if tt.shouldError {
 				if err == nil {
@@ -124,9 +128,13 @@ func TestSetEnvironmentLightingToolCall(t *testing.T) {
 		t.Errorf("Expected bottom_color length 3, got %d", len(operation.BottomColor))
 	}
 
+	if !operation.Replace {
+		t.Error("Expected replace to default to true")
+	}
+
 	// Test execution
 	sm := NewSceneManager()
-	err := sm.SetEnvironmentLighting(operation.LightingType, operation.TopColor, operation.BottomColor, operation.Emission)
+	err := sm.SetEnvironmentLighting(operation.LightingType, operation.TopColor, operation.BottomColor, operation.Emission, nil, 0, operation.Intensity, operation.Replace)
 	if err != nil {
 		t.Errorf("Failed to execute environment lighting operation: %v", err)
 	}
@@ -221,7 +229,7 @@ func TestLightReplacement(t *testing.T) {
 	sm := NewSceneManager()
 
 	// Add gradient lighting
-	err := sm.SetEnvironmentLighting("gradient", []float64{1.0, 0.5, 0.0}, []float64{0.0, 0.5, 1.0}, []float64{0.0, 0.0, 0.0})
+	err := sm.SetEnvironmentLighting("gradient", []float64{1.0, 0.5, 0.0}, []float64{0.0, 0.5, 1.0}, []float64{0.0, 0.0, 0.0}, nil, 0, 1, true)
 	if err != nil {
 		t.Fatalf("Failed to set gradient lighting: %v", err)
 	}
@@ -231,7 +239,7 @@ func TestLightReplacement(t *testing.T) {
 	}
 
 	// Replace with uniform lighting
-	err = sm.SetEnvironmentLighting("uniform", nil, nil, []float64{0.9, 0.9, 0.9})
+	err = sm.SetEnvironmentLighting("uniform", nil, nil, []float64{0.9, 0.9, 0.9}, nil, 0, 1, true)
 	if err != nil {
 		t.Fatalf("Failed to set uniform lighting: %v", err)
 	}
@@ -245,7 +253,7 @@ func TestLightReplacement(t *testing.T) {
 	}
 
 	// Remove all lighting
-	err = sm.SetEnvironmentLighting("none", nil, nil, nil)
+	err = sm.SetEnvironmentLighting("none", nil, nil, nil, nil, 0, 1, true)
 	if err != nil {
 		t.Fatalf("Failed to remove lighting: %v", err)
 	}
@@ -285,7 +293,7 @@ func TestSceneConversionWithLights(t *testing.T) {
 			sm := NewSceneManager()
 
 			// Set lighting
-			err := sm.SetEnvironmentLighting(tt.lightingType, tt.topColor, tt.bottomColor, tt.emission)
+			err := sm.SetEnvironmentLighting(tt.lightingType, tt.topColor, tt.bottomColor, tt.emission, nil, 0, 1, true)
 			if err != nil {
 				t.Fatalf("Failed to set lighting: %v", err)
 			}
@@ -312,24 +320,24 @@ func TestEnvironmentLightingValidation(t *testing.T) {
 	sm := NewSceneManager()
 
 	// Test negative color values
-	err := sm.SetEnvironmentLighting("gradient", []float64{-1.0, 0.5, 1.0}, []float64{1.0, 1.0, 1.0}, nil)
+	err := sm.SetEnvironmentLighting("gradient", []float64{-1.0, 0.5, 1.0}, []float64{1.0, 1.0, 1.0}, nil, nil, 0, 1, true)
 	if err == nil {
 		t.Error("Expected error for negative color values")
 	}
 
 	// Test wrong array length
-	err = sm.SetEnvironmentLighting("gradient", []float64{1.0, 0.5}, []float64{1.0, 1.0, 1.0}, nil)
+	err = sm.SetEnvironmentLighting("gradient", []float64{1.0, 0.5}, []float64{1.0, 1.0, 1.0}, nil, nil, 0, 1, true)
 	if err == nil {
 		t.Error("Expected error for wrong array length")
 	}
 
 	// Test nil arrays where required
-	err = sm.SetEnvironmentLighting("gradient", nil, []float64{1.0, 1.0, 1.0}, nil)
+	err = sm.SetEnvironmentLighting("gradient", nil, []float64{1.0, 1.0, 1.0}, nil, nil, 0, 1, true)
 	if err == nil {
 		t.Error("Expected error for missing top_color")
 	}
 
-	err = sm.SetEnvironmentLighting("uniform", nil, nil, nil)
+	err = sm.SetEnvironmentLighting("uniform", nil, nil, nil, nil, 0, 1, true)
 	if err == nil {
 		t.Error("Expected error for missing emission")
 	}
@@ -831,3 +839,973 @@ func TestSceneConversionWithPositionedLights(t *testing.T) {
 		})
 	}
 }
+
+func TestStackedEnvironmentLights(t *testing.T) {
+	sm := NewSceneManager()
+
+	// Gradient sky plus a dim uniform fill
+	if err := sm.SetEnvironmentLighting("gradient", []float64{0.5, 0.7, 1.0}, []float64{1.0, 1.0, 1.0}, nil, nil, 0, 1, true); err != nil {
+		t.Fatalf("Failed to set gradient lighting: %v", err)
+	}
+	if err := sm.SetEnvironmentLighting("uniform", nil, nil, []float64{0.1, 0.1, 0.1}, nil, 0, 1, false); err != nil {
+		t.Fatalf("Failed to stack uniform lighting: %v", err)
+	}
+
+	if len(sm.state.Lights) != 2 {
+		t.Fatalf("Expected 2 stacked environment lights, got %d", len(sm.state.Lights))
+	}
+
+	// Stacking a second light of the same type is rejected and leaves the scene unchanged
+	err := sm.SetEnvironmentLighting("gradient", []float64{1.0, 0.5, 0.0}, []float64{0.0, 0.5, 1.0}, nil, nil, 0, 1, false)
+	if err == nil {
+		t.Error("Expected error stacking a second gradient light")
+	}
+	if len(sm.state.Lights) != 2 {
+		t.Errorf("Expected 2 lights after rejected stack, got %d", len(sm.state.Lights))
+	}
+
+	// Both infinite lights make it into the raytracer scene
+	if _, err := sm.ToRaytracerScene(); err != nil {
+		t.Errorf("Failed to convert scene with stacked lights: %v", err)
+	}
+
+	// Replacing removes both
+	if err := sm.SetEnvironmentLighting("uniform", nil, nil, []float64{0.5, 0.5, 0.5}, nil, 0, 1, true); err != nil {
+		t.Fatalf("Failed to replace lighting: %v", err)
+	}
+	if len(sm.state.Lights) != 1 || sm.state.Lights[0].Type != "infinite_uniform_light" {
+		t.Errorf("Expected a single uniform light after replace, got %+v", sm.state.Lights)
+	}
+
+	// Other lights are untouched when stacking
+	pointLight := LightRequest{
+		ID:   "lamp",
+		Type: "point_spot_light",
+		Properties: map[string]interface{}{
+			"center":   []interface{}{0.0, 5.0, 0.0},
+			"emission": []interface{}{10.0, 10.0, 10.0},
+		},
+	}
+	if err := sm.AddLights([]LightRequest{pointLight}); err != nil {
+		t.Fatalf("Failed to add point light: %v", err)
+	}
+	if err := sm.SetEnvironmentLighting("gradient", []float64{0.5, 0.7, 1.0}, []float64{1.0, 1.0, 1.0}, nil, nil, 0, 1, false); err != nil {
+		t.Fatalf("Failed to stack gradient lighting: %v", err)
+	}
+	if len(sm.state.Lights) != 3 {
+		t.Errorf("Expected 3 lights, got %d", len(sm.state.Lights))
+	}
+}
+
+func TestParseSetEnvironmentLightingReplace(t *testing.T) {
+	operation := parseSetEnvironmentLightingRequest(&llm.FunctionCall{
+		Name: "set_environment_lighting",
+		Arguments: map[string]interface{}{
+			"type":     "uniform",
+			"emission": []interface{}{0.1, 0.1, 0.1},
+			"replace":  false,
+		},
+	})
+	if operation.Replace {
+		t.Error("Expected replace=false to be parsed")
+	}
+}
+
+func TestEnvironmentLightIntensity(t *testing.T) {
+	sm := NewSceneManager()
+	if err := sm.SetEnvironmentLighting("uniform", nil, nil, []float64{0.5, 0.5, 0.5}, nil, 0, 1, true); err != nil {
+		t.Fatalf("Failed to set uniform lighting: %v", err)
+	}
+	if _, ok := sm.state.Lights[0].Properties["intensity"]; ok {
+		t.Errorf("Expected the default intensity not to be stored, got %v", sm.state.Lights[0].Properties)
+	}
+	base := lightSamples(sm.state.Lights[0])
+
+	operation := parseSetEnvironmentLightingRequest(&llm.FunctionCall{
+		Name: "set_environment_lighting",
+		Arguments: map[string]interface{}{
+			"type":      "uniform",
+			"emission":  []interface{}{0.5, 0.5, 0.5},
+			"intensity": 3.0,
+		},
+	})
+	if err := sm.SetEnvironmentLighting(operation.LightingType, operation.TopColor, operation.BottomColor, operation.Emission, nil, 0, operation.Intensity, operation.Replace); err != nil {
+		t.Fatalf("Failed to set uniform lighting with intensity: %v", err)
+	}
+	light := sm.state.Lights[0]
+	if intensity, _ := extractFloat(light.Properties, "intensity"); intensity != 3 {
+		t.Errorf("Expected intensity 3 to be stored, got %v", light.Properties)
+	}
+
+	// The emission is scaled, not the stored color
+	if emission := vec3Property(light.Properties, "emission", vec3{}); emission != (vec3{0.5, 0.5, 0.5}) {
+		t.Errorf("Expected the stored emission to stay [0.5 0.5 0.5], got %v", emission)
+	}
+	scaled := lightSamples(light)
+	if len(scaled) != len(base) || len(base) == 0 {
+		t.Fatalf("Expected matching light samples, got %d and %d", len(base), len(scaled))
+	}
+	for i := range base {
+		if math.Abs(scaled[i].weight-3*base[i].weight) > 1e-9 {
+			t.Fatalf("Expected sample %d weight to triple, got %v -> %v", i, base[i].weight, scaled[i].weight)
+		}
+	}
+	if _, err := sm.ToRaytracerScene(); err != nil {
+		t.Errorf("Failed to convert scene with intensity: %v", err)
+	}
+
+	if err := sm.SetEnvironmentLighting("gradient", []float64{1, 1, 1}, []float64{1, 1, 1}, nil, nil, 0, -1, true); err == nil {
+		t.Error("Expected an error for negative intensity")
+	}
+
+	// Omitting intensity defaults to 1
+	operation = parseSetEnvironmentLightingRequest(&llm.FunctionCall{
+		Name:      "set_environment_lighting",
+		Arguments: map[string]interface{}{"type": "uniform", "emission": []interface{}{0.5, 0.5, 0.5}},
+	})
+	if operation.Intensity != 1 {
+		t.Errorf("Expected default intensity 1, got %v", operation.Intensity)
+	}
+}
+
+func TestTwoSidedQuadLight(t *testing.T) {
+	quadLight := func(twoSided interface{}) LightRequest {
+		props := map[string]interface{}{
+			"corner":   []interface{}{-1.0, 3.0, -1.0},
+			"u":        []interface{}{2.0, 0.0, 0.0},
+			"v":        []interface{}{0.0, 0.0, 2.0},
+			"emission": []interface{}{5.0, 5.0, 5.0},
+		}
+		if twoSided != nil {
+			props["two_sided"] = twoSided
+		}
+		return LightRequest{ID: "panel", Type: "area_quad_light", Properties: props}
+	}
+
+	t.Run("validation", func(t *testing.T) {
+		if err := validateLightProperties(quadLight(true)); err != nil {
+			t.Errorf("Expected two_sided=true to be valid, got %v", err)
+		}
+		if err := validateLightProperties(quadLight("yes")); err == nil {
+			t.Error("Expected non-boolean two_sided to be rejected")
+		}
+	})
+
+	t.Run("conversion", func(t *testing.T) {
+		tests := []struct {
+			twoSided       interface{}
+			expectedLights int
+		}{
+			{nil, 1},
+			{false, 1},
+			{true, 2},
+		}
+		for _, tt := range tests {
+			sm := NewSceneManager()
+			if err := sm.AddLights([]LightRequest{quadLight(tt.twoSided)}); err != nil {
+				t.Fatalf("Failed to add quad light: %v", err)
+			}
+			raytracerScene, err := sm.ToRaytracerScene()
+			if err != nil {
+				t.Fatalf("ToRaytracerScene() failed: %v", err)
+			}
+			if len(raytracerScene.Lights) != tt.expectedLights {
+				t.Errorf("two_sided=%v: expected %d raytracer lights, got %d", tt.twoSided, tt.expectedLights, len(raytracerScene.Lights))
+			}
+		}
+	})
+
+	t.Run("emission normal in scene state", func(t *testing.T) {
+		sm := NewSceneManager()
+		if err := sm.AddLights([]LightRequest{quadLight(nil)}); err != nil {
+			t.Fatalf("Failed to add quad light: %v", err)
+		}
+
+		normals := sm.GetSceneState()["light_emission_normals"].(map[string][]float64)
+		normal, ok := normals["panel"]
+		if !ok {
+			t.Fatal("Expected an emission normal for the quad light")
+		}
+		// u=(2,0,0) × v=(0,0,2) points down
+		expected := []float64{0, -1, 0}
+		for i := range expected {
+			if math.Abs(normal[i]-expected[i]) > 1e-9 {
+				t.Fatalf("Expected emission normal %v, got %v", expected, normal)
+			}
+		}
+	})
+}
+
+func TestLightEnabled(t *testing.T) {
+	newLight := func(id string) LightRequest {
+		return LightRequest{ID: id, Type: "area_sphere_light", Properties: map[string]interface{}{
+			"center": []interface{}{0.0, 3.0, 0.0}, "radius": 0.5, "emission": []interface{}{5.0, 5.0, 5.0},
+		}}
+	}
+	raytracerLights := func(t *testing.T, sm *SceneManager) int {
+		raytracerScene, err := sm.ToRaytracerScene()
+		if err != nil {
+			t.Fatalf("ToRaytracerScene() failed: %v", err)
+		}
+		return len(raytracerScene.Lights)
+	}
+
+	t.Run("validation", func(t *testing.T) {
+		light := newLight("lamp")
+		light.Properties["enabled"] = false
+		if err := validateLightProperties(light); err != nil {
+			t.Errorf("Expected enabled=false to be valid, got %v", err)
+		}
+		light.Properties["enabled"] = "no"
+		if err := validateLightProperties(light); err == nil {
+			t.Error("Expected non-boolean enabled to be rejected")
+		}
+	})
+
+	t.Run("disabled lights are skipped", func(t *testing.T) {
+		sm := NewSceneManager()
+		if err := sm.AddLights([]LightRequest{newLight("key"), newLight("fill")}); err != nil {
+			t.Fatalf("AddLights() failed: %v", err)
+		}
+		if got := raytracerLights(t, sm); got != 2 {
+			t.Fatalf("Expected 2 raytracer lights, got %d", got)
+		}
+
+		revision := sm.Revision()
+		if err := sm.SetLightEnabled("fill", false); err != nil {
+			t.Fatalf("SetLightEnabled() failed: %v", err)
+		}
+		if sm.Revision() == revision {
+			t.Error("Expected disabling a light to bump the revision")
+		}
+		if got := raytracerLights(t, sm); got != 1 {
+			t.Errorf("Expected 1 raytracer light with fill disabled, got %d", got)
+		}
+
+		// The disabled light is still part of the scene state
+		lights := sm.GetSceneState()["lights"].([]LightRequest)
+		if len(lights) != 2 {
+			t.Errorf("Expected 2 lights in scene state, got %d", len(lights))
+		}
+
+		if err := sm.SetLightEnabled("fill", true); err != nil {
+			t.Fatalf("SetLightEnabled() failed: %v", err)
+		}
+		if got := raytracerLights(t, sm); got != 2 {
+			t.Errorf("Expected 2 raytracer lights after re-enabling fill, got %d", got)
+		}
+	})
+
+	t.Run("default lighting when every light is off", func(t *testing.T) {
+		sm := NewSceneManager()
+		if err := sm.AddLights([]LightRequest{newLight("key"), newLight("fill")}); err != nil {
+			t.Fatalf("AddLights() failed: %v", err)
+		}
+		for _, id := range []string{"key", "fill"} {
+			if err := sm.SetLightEnabled(id, false); err != nil {
+				t.Fatalf("SetLightEnabled() failed: %v", err)
+			}
+		}
+		// Only the default gradient remains
+		if got := raytracerLights(t, sm); got != 1 {
+			t.Errorf("Expected the default gradient light, got %d raytracer lights", got)
+		}
+	})
+
+	t.Run("unknown light", func(t *testing.T) {
+		if err := NewSceneManager().SetLightEnabled("missing", false); err == nil {
+			t.Error("Expected error for unknown light")
+		}
+	})
+}
+
+func TestSetLightEnabledTool(t *testing.T) {
+	agent := NewWithProvider(make(chan AgentEvent, 100), &MockProvider{}, "mock-model")
+	if err := agent.sceneManager.SetEnvironmentLighting("uniform", nil, nil, []float64{0.2, 0.2, 0.2}, nil, 0, 1, true); err != nil {
+		t.Fatalf("SetEnvironmentLighting() failed: %v", err)
+	}
+	id := agent.sceneManager.state.Lights[0].ID
+
+	req := parseToolRequestFromFunctionCall(&llm.FunctionCall{Name: "set_light_enabled", Arguments: map[string]interface{}{"id": id, "enabled": false}})
+	result := agent.executeToolRequests(context.Background(), req, "test_call_1")
+	if !result.Success {
+		t.Fatalf("Expected set_light_enabled to succeed, got errors: %v", result.Errors)
+	}
+	if light := result.Result.(LightRequest); lightEnabled(light) {
+		t.Errorf("Expected returned light to be disabled, got %v", light.Properties)
+	}
+
+	// A missing enabled flag is an error rather than silently switching the light off
+	bad := parseToolRequestFromFunctionCall(&llm.FunctionCall{Name: "set_light_enabled", Arguments: map[string]interface{}{"id": id}})
+	if result := agent.executeToolRequests(context.Background(), bad, "test_call_2"); result.Success {
+		t.Error("Expected set_light_enabled without enabled to fail")
+	}
+}
+
+func TestSoloLight(t *testing.T) {
+	newScene := func(t *testing.T) *SceneManager {
+		sm := NewSceneManager()
+		lights := []LightRequest{
+			{ID: "key", Type: "area_sphere_light", Properties: map[string]interface{}{
+				"center": []interface{}{2.0, 3.0, 0.0}, "radius": 0.5, "emission": []interface{}{5.0, 5.0, 5.0},
+			}},
+			{ID: "fill", Type: "area_sphere_light", Properties: map[string]interface{}{
+				"center": []interface{}{-2.0, 3.0, 0.0}, "radius": 0.5, "emission": []interface{}{2.0, 2.0, 2.0},
+			}},
+			{ID: "rim", Type: "area_sphere_light", Properties: map[string]interface{}{
+				"center": []interface{}{0.0, 3.0, -2.0}, "radius": 0.5, "emission": []interface{}{2.0, 2.0, 2.0}, "enabled": false,
+			}},
+		}
+		if err := sm.AddLights(lights); err != nil {
+			t.Fatalf("AddLights() failed: %v", err)
+		}
+		return sm
+	}
+	raytracerLights := func(t *testing.T, sm *SceneManager) int {
+		raytracerScene, err := sm.ToRaytracerScene()
+		if err != nil {
+			t.Fatalf("ToRaytracerScene() failed: %v", err)
+		}
+		return len(raytracerScene.Lights)
+	}
+
+	t.Run("solo and unsolo", func(t *testing.T) {
+		sm := newScene(t)
+		suppressed, err := sm.SoloLight("key")
+		if err != nil {
+			t.Fatalf("SoloLight() failed: %v", err)
+		}
+		// The disabled rim light was already off, so only fill is suppressed
+		if len(suppressed) != 1 || suppressed[0] != "fill" {
+			t.Errorf("Expected [fill] suppressed, got %v", suppressed)
+		}
+		if got := raytracerLights(t, sm); got != 1 {
+			t.Errorf("Expected only the soloed light to render, got %d lights", got)
+		}
+		if got := sm.GetSceneState()["soloed_light"]; got != "key" {
+			t.Errorf("Expected soloed_light key in scene state, got %v", got)
+		}
+
+		if id := sm.UnsoloLight(); id != "key" {
+			t.Errorf("Expected UnsoloLight to return key, got %q", id)
+		}
+		if got := raytracerLights(t, sm); got != 2 {
+			t.Errorf("Expected both enabled lights after unsolo, got %d lights", got)
+		}
+		if id := sm.UnsoloLight(); id != "" {
+			t.Errorf("Expected nothing to unsolo, got %q", id)
+		}
+	})
+
+	t.Run("soloed light renders even if disabled", func(t *testing.T) {
+		sm := newScene(t)
+		if _, err := sm.SoloLight("rim"); err != nil {
+			t.Fatalf("SoloLight() failed: %v", err)
+		}
+		if got := raytracerLights(t, sm); got != 1 {
+			t.Errorf("Expected the soloed rim light to render, got %d lights", got)
+		}
+	})
+
+	t.Run("follows renames and ends on removal", func(t *testing.T) {
+		sm := newScene(t)
+		if _, err := sm.SoloLight("key"); err != nil {
+			t.Fatalf("SoloLight() failed: %v", err)
+		}
+		if err := sm.UpdateLight("key", map[string]interface{}{"id": "main"}); err != nil {
+			t.Fatalf("UpdateLight() failed: %v", err)
+		}
+		if got := sm.GetSceneState()["soloed_light"]; got != "main" {
+			t.Errorf("Expected solo to follow the rename, got %v", got)
+		}
+		if err := sm.RemoveLight("main"); err != nil {
+			t.Fatalf("RemoveLight() failed: %v", err)
+		}
+		if got := raytracerLights(t, sm); got != 1 {
+			t.Errorf("Expected the remaining enabled light after removing the soloed one, got %d lights", got)
+		}
+	})
+
+	t.Run("cleared by ClearScene", func(t *testing.T) {
+		sm := newScene(t)
+		if _, err := sm.SoloLight("key"); err != nil {
+			t.Fatalf("SoloLight() failed: %v", err)
+		}
+		sm.ClearScene()
+		if got := sm.GetSceneState()["soloed_light"]; got != "" {
+			t.Errorf("Expected ClearScene to end the solo, got %v", got)
+		}
+	})
+
+	t.Run("unknown light", func(t *testing.T) {
+		if _, err := newScene(t).SoloLight("missing"); err == nil {
+			t.Error("Expected error for unknown light")
+		}
+	})
+}
+
+func TestSoloLightTools(t *testing.T) {
+	agent := NewWithProvider(make(chan AgentEvent, 100), &MockProvider{}, "mock-model")
+	lights := []LightRequest{
+		{ID: "key", Type: "point_spot_light", Properties: map[string]interface{}{"center": []interface{}{0.0, 3.0, 0.0}, "emission": []interface{}{5.0, 5.0, 5.0}}},
+		{ID: "fill", Type: "point_spot_light", Properties: map[string]interface{}{"center": []interface{}{2.0, 3.0, 0.0}, "emission": []interface{}{1.0, 1.0, 1.0}}},
+	}
+	if err := agent.sceneManager.AddLights(lights); err != nil {
+		t.Fatalf("AddLights() failed: %v", err)
+	}
+
+	solo := parseToolRequestFromFunctionCall(&llm.FunctionCall{Name: "solo_light", Arguments: map[string]interface{}{"id": "fill"}})
+	result := agent.executeToolRequests(context.Background(), solo, "test_call_1")
+	if !result.Success {
+		t.Fatalf("Expected solo_light to succeed, got errors: %v", result.Errors)
+	}
+	suppressed := result.Result.(map[string]interface{})["suppressed"].([]string)
+	if len(suppressed) != 1 || suppressed[0] != "key" {
+		t.Errorf("Expected [key] suppressed, got %v", suppressed)
+	}
+
+	unsolo := parseToolRequestFromFunctionCall(&llm.FunctionCall{Name: "unsolo_light", Arguments: map[string]interface{}{}})
+	result = agent.executeToolRequests(context.Background(), unsolo, "test_call_2")
+	if !result.Success || result.Result.(map[string]string)["unsoloed_light"] != "fill" {
+		t.Errorf("Expected unsolo_light to end the fill solo, got %+v", result)
+	}
+}
+
+func TestGetLightCopy(t *testing.T) {
+	sm := NewSceneManager()
+	err := sm.AddLights([]LightRequest{{ID: "spot", Type: "point_spot_light", Properties: map[string]interface{}{
+		"center": []interface{}{0.0, 4.0, 0.0}, "emission": []interface{}{10.0, 10.0, 10.0}, "cutoff_angle": 30.0,
+	}}})
+	if err != nil {
+		t.Fatalf("AddLights() failed: %v", err)
+	}
+
+	lightCopy, err := sm.GetLightCopy("spot")
+	if err != nil {
+		t.Fatalf("GetLightCopy() failed: %v", err)
+	}
+	if lightCopy.ID != "spot" || lightCopy.Type != "point_spot_light" {
+		t.Errorf("Copied wrong light: %+v", lightCopy)
+	}
+
+	// Mutating the copy (including nested values) must not affect the scene
+	lightCopy.Properties["center"].([]interface{})[1] = 99.0
+	lightCopy.Properties["cutoff_angle"] = 90.0
+	original := sm.FindLight("spot")
+	if center, _ := extractFloatArray(original.Properties, "center", 3); center[1] != 4.0 {
+		t.Errorf("Original center was mutated through copy: %v", center)
+	}
+	if cutoff, _ := extractFloat(original.Properties, "cutoff_angle"); cutoff != 30.0 {
+		t.Errorf("Original cutoff_angle was mutated through copy: %v", cutoff)
+	}
+
+	if _, err := sm.GetLightCopy("missing"); err == nil {
+		t.Error("Expected error for missing light")
+	}
+}
+
+func TestResolvedLightDefaults(t *testing.T) {
+	tests := []struct {
+		name     string
+		light    LightRequest
+		expected map[string]interface{}
+	}{
+		{
+			name: "spot light with one optional property set",
+			light: LightRequest{Type: "point_spot_light", Properties: map[string]interface{}{
+				"center": []interface{}{0.0, 4.0, 0.0}, "emission": []interface{}{1.0, 1.0, 1.0}, "cutoff_angle": 30.0,
+			}},
+			expected: map[string]interface{}{
+				"enabled": true, "direction": []float64{0, -1, 0}, "falloff_exponent": defaultSpotFalloffExponent,
+			},
+		},
+		{
+			name:     "disc spot light reports its fixed cone",
+			light:    LightRequest{Type: "disc_spot_light", Properties: map[string]interface{}{"enabled": false}},
+			expected: map[string]interface{}{"cutoff_angle": discSpotCutoffAngle, "falloff_exponent": discSpotFalloffExponent, "power_mode": "radiance"},
+		},
+		{
+			name:     "quad light",
+			light:    LightRequest{Type: "area_quad_light", Properties: map[string]interface{}{}},
+			expected: map[string]interface{}{"enabled": true, "two_sided": false, "power_mode": "radiance"},
+		},
+		{
+			name:     "sphere light",
+			light:    LightRequest{Type: "area_sphere_light", Properties: map[string]interface{}{}},
+			expected: map[string]interface{}{"enabled": true, "power_mode": "radiance"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := resolvedLightDefaults(tt.light); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("resolvedLightDefaults() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGetLightTool(t *testing.T) {
+	agent := NewWithProvider(make(chan AgentEvent, 100), &MockProvider{}, "mock-model")
+	err := agent.sceneManager.AddLights([]LightRequest{{ID: "spot", Type: "point_spot_light", Properties: map[string]interface{}{
+		"center": []interface{}{0.0, 4.0, 0.0}, "emission": []interface{}{10.0, 10.0, 10.0},
+	}}})
+	if err != nil {
+		t.Fatalf("AddLights() failed: %v", err)
+	}
+
+	req := parseToolRequestFromFunctionCall(&llm.FunctionCall{Name: "get_light", Arguments: map[string]interface{}{"id": "spot"}})
+	result := agent.executeToolRequests(context.Background(), req, "test_call_1")
+	if !result.Success {
+		t.Fatalf("Expected get_light to succeed, got errors: %v", result.Errors)
+	}
+	resultMap := result.Result.(map[string]interface{})
+	if light := resultMap["light"].(*LightRequest); light.ID != "spot" {
+		t.Errorf("Expected the spot light, got %+v", light)
+	}
+	if defaults := resultMap["defaults"].(map[string]interface{}); defaults["cutoff_angle"] != defaultSpotCutoffAngle {
+		t.Errorf("Expected default cutoff_angle %v, got %v", defaultSpotCutoffAngle, defaults["cutoff_angle"])
+	}
+
+	missing := parseToolRequestFromFunctionCall(&llm.FunctionCall{Name: "get_light", Arguments: map[string]interface{}{"id": "missing"}})
+	if result := agent.executeToolRequests(context.Background(), missing, "test_call_2"); result.Success {
+		t.Error("Expected get_light for a missing light to fail")
+	}
+}
+
+func TestAreaRectLight(t *testing.T) {
+	rectLight := func(props map[string]interface{}) LightRequest {
+		merged := map[string]interface{}{
+			"center":   []interface{}{0.0, 3.0, 0.0},
+			"size":     []interface{}{2.0, 1.0},
+			"normal":   []interface{}{0.0, -2.0, 0.0},
+			"emission": []interface{}{5.0, 5.0, 5.0},
+		}
+		for key, value := range props {
+			merged[key] = value
+		}
+		return LightRequest{ID: "panel", Type: "area_rect_light", Properties: merged}
+	}
+
+	t.Run("conversion matches the equivalent quad light", func(t *testing.T) {
+		quad, err := rectLightAsQuad(rectLight(map[string]interface{}{"two_sided": true}))
+		if err != nil {
+			t.Fatalf("rectLightAsQuad() failed: %v", err)
+		}
+		// A 2x1 panel centered at (0,3,0) facing down: width along x, height along z, u×v down
+		expected := map[string]vec3{"corner": {-1, 3, -0.5}, "u": {2, 0, 0}, "v": {0, 0, 1}}
+		for key, want := range expected {
+			if got := vec3Property(quad.Properties, key, vec3{math.NaN()}); got.sub(want).length() > 1e-9 {
+				t.Errorf("Expected %s %v, got %v", key, want, got)
+			}
+		}
+		if quad.Type != "area_quad_light" || quad.Properties["two_sided"] != true {
+			t.Errorf("Expected a two-sided area_quad_light, got %+v", quad)
+		}
+		if err := validateLightProperties(quad); err != nil {
+			t.Errorf("Expected the converted quad light to be valid, got %v", err)
+		}
+		if !reflect.DeepEqual(lightSamples(rectLight(nil)), lightSamples(mustRectLightAsQuad(t, rectLight(nil)))) {
+			t.Error("Expected the rect light to sample like its quad light")
+		}
+	})
+
+	t.Run("basis follows the normal", func(t *testing.T) {
+		normals := [][]interface{}{{0.0, 0.0, 1.0}, {1.0, 0.0, 0.0}, {0.0, 1.0, 0.0}, {1.0, 1.0, -1.0}}
+		for _, normal := range normals {
+			quad := mustRectLightAsQuad(t, rectLight(map[string]interface{}{"normal": normal}))
+			u := vec3Property(quad.Properties, "u", vec3{})
+			v := vec3Property(quad.Properties, "v", vec3{})
+			corner := vec3Property(quad.Properties, "corner", vec3{})
+			n := vec3{normal[0].(float64), normal[1].(float64), normal[2].(float64)}.normalize()
+
+			if math.Abs(u.length()-2) > 1e-9 || math.Abs(v.length()-1) > 1e-9 || math.Abs(u.dot(v)) > 1e-9 {
+				t.Errorf("normal %v: expected perpendicular edges of length 2 and 1, got u=%v v=%v", normal, u, v)
+			}
+			if u.cross(v).normalize().sub(n).length() > 1e-9 {
+				t.Errorf("normal %v: expected u×v along the normal, got %v", normal, u.cross(v))
+			}
+			if center := corner.add(u.scale(0.5)).add(v.scale(0.5)); center.sub(vec3{0, 3, 0}).length() > 1e-9 {
+				t.Errorf("normal %v: expected the quad centered at (0,3,0), got %v", normal, center)
+			}
+		}
+	})
+
+	t.Run("validation", func(t *testing.T) {
+		if err := validateLightProperties(rectLight(nil)); err != nil {
+			t.Errorf("Expected the rect light to be valid, got %v", err)
+		}
+		invalid := map[string]map[string]interface{}{
+			"zero normal":   {"normal": []interface{}{0.0, 0.0, 0.0}},
+			"zero width":    {"size": []interface{}{0.0, 1.0}},
+			"negative size": {"size": []interface{}{2.0, -1.0}},
+			"short size":    {"size": []interface{}{2.0}},
+		}
+		for name, props := range invalid {
+			if err := validateLightProperties(rectLight(props)); err == nil {
+				t.Errorf("%s: expected a validation error", name)
+			}
+		}
+	})
+
+	t.Run("renders as a quad light", func(t *testing.T) {
+		sm := NewSceneManager()
+		if err := sm.AddLights([]LightRequest{rectLight(map[string]interface{}{"two_sided": true})}); err != nil {
+			t.Fatalf("Failed to add rect light: %v", err)
+		}
+		raytracerScene, err := sm.ToRaytracerScene()
+		if err != nil {
+			t.Fatalf("ToRaytracerScene() failed: %v", err)
+		}
+		if len(raytracerScene.Lights) != 2 {
+			t.Errorf("Expected a two-sided rect light to add 2 raytracer lights, got %d", len(raytracerScene.Lights))
+		}
+		if normal := sm.GetSceneState()["light_emission_normals"].(map[string][]float64)["panel"]; !reflect.DeepEqual(normal, []float64{0, -1, 0}) {
+			t.Errorf("Expected emission normal [0 -1 0], got %v", normal)
+		}
+	})
+}
+
+// mustRectLightAsQuad converts a rect light, failing the test on error
+func mustRectLightAsQuad(t *testing.T, light LightRequest) LightRequest {
+	t.Helper()
+	quad, err := rectLightAsQuad(light)
+	if err != nil {
+		t.Fatalf("rectLightAsQuad() failed: %v", err)
+	}
+	return quad
+}
+
+func TestDetectLightsFacingAway(t *testing.T) {
+	panel := func(id string, u, v []interface{}) LightRequest {
+		return LightRequest{ID: id, Type: "area_quad_light", Properties: map[string]interface{}{
+			"corner": []interface{}{-1.0, 4.0, -1.0}, "u": u, "v": v, "emission": []interface{}{5.0, 5.0, 5.0},
+		}}
+	}
+	x := []interface{}{2.0, 0.0, 0.0}
+	z := []interface{}{0.0, 0.0, 2.0}
+
+	sm := NewSceneManager()
+	if err := sm.AddShapes([]ShapeRequest{{ID: "ball", Type: "sphere", Properties: map[string]interface{}{
+		"center": []interface{}{0.0, 1.0, 0.0}, "radius": 1.0,
+	}}}); err != nil {
+		t.Fatalf("AddShapes() failed: %v", err)
+	}
+
+	flippedTwoSided := panel("flipped_two_sided", z, x)
+	flippedTwoSided.Properties["two_sided"] = true
+	flippedDisabled := panel("flipped_disabled", z, x)
+	flippedDisabled.Properties["enabled"] = false
+	lights := []LightRequest{
+		panel("down", x, z),    // u×v points down at the ball
+		panel("flipped", z, x), // Winding reversed, so it points up into empty space
+		flippedTwoSided,
+		flippedDisabled,
+		{ID: "spot_up", Type: "point_spot_light", Properties: map[string]interface{}{
+			"center": []interface{}{0.0, 4.0, 0.0}, "direction": []interface{}{0.0, 1.0, 0.0}, "emission": []interface{}{5.0, 5.0, 5.0},
+		}},
+		{ID: "spot_default", Type: "point_spot_light", Properties: map[string]interface{}{
+			"center": []interface{}{0.0, 4.0, 0.0}, "emission": []interface{}{5.0, 5.0, 5.0},
+		}},
+		{ID: "bulb", Type: "area_sphere_light", Properties: map[string]interface{}{
+			"center": []interface{}{0.0, 4.0, 0.0}, "radius": 0.5, "emission": []interface{}{5.0, 5.0, 5.0},
+		}},
+	}
+	if err := sm.AddLights(lights); err != nil {
+		t.Fatalf("AddLights() failed: %v", err)
+	}
+
+	if got := sm.DetectLightsFacingAway(); !reflect.DeepEqual(got, []string{"flipped", "spot_up"}) {
+		t.Errorf("Expected flipped and spot_up to be flagged, got %v", got)
+	}
+
+	warnings := sm.GetSceneState()["warnings"].([]string)
+	if len(warnings) != 2 || !strings.Contains(warnings[0], "'flipped'") {
+		t.Errorf("Expected a warning per flagged light, got %v", warnings)
+	}
+
+	// Without shapes there is nothing to point at
+	empty := NewSceneManager()
+	if err := empty.AddLights([]LightRequest{panel("flipped", z, x)}); err != nil {
+		t.Fatalf("AddLights() failed: %v", err)
+	}
+	if got := empty.DetectLightsFacingAway(); len(got) != 0 {
+		t.Errorf("Expected no lights flagged in an empty scene, got %v", got)
+	}
+}
+
+func TestPointLight(t *testing.T) {
+	bulb := func(extra map[string]interface{}) LightRequest {
+		props := map[string]interface{}{
+			"center":   []interface{}{0.0, 3.0, 0.0},
+			"emission": []interface{}{10.0, 10.0, 10.0},
+		}
+		for key, value := range extra {
+			props[key] = value
+		}
+		return LightRequest{ID: "bulb", Type: "point_light", Properties: props}
+	}
+
+	t.Run("validation", func(t *testing.T) {
+		if err := validateLightProperties(bulb(nil)); err != nil {
+			t.Errorf("Expected a point light with center and emission to be valid, got %v", err)
+		}
+
+		noEmission := bulb(nil)
+		delete(noEmission.Properties, "emission")
+		if err := validateLightProperties(noEmission); err == nil || !strings.Contains(err.Error(), "emission") {
+			t.Errorf("Expected missing emission to be rejected, got %v", err)
+		}
+
+		err := validateLightProperties(bulb(map[string]interface{}{"cutoff_angle": 30.0}))
+		if err == nil || !strings.Contains(err.Error(), "use point_spot_light") {
+			t.Errorf("Expected a cone property to point at point_spot_light, got %v", err)
+		}
+	})
+
+	t.Run("renders as one light", func(t *testing.T) {
+		sm := NewSceneManager()
+		if err := sm.AddLights([]LightRequest{bulb(nil)}); err != nil {
+			t.Fatalf("AddLights() failed: %v", err)
+		}
+		raytracerScene, err := sm.ToRaytracerScene()
+		if err != nil {
+			t.Fatalf("ToRaytracerScene() failed: %v", err)
+		}
+		if len(raytracerScene.Lights) != 1 {
+			t.Errorf("Expected 1 raytracer light, got %d", len(raytracerScene.Lights))
+		}
+	})
+
+	t.Run("shines in every direction", func(t *testing.T) {
+		samples := lightSamples(bulb(nil))
+		if len(samples) != 1 || samples[0].spotAxis != (vec3{}) {
+			t.Fatalf("Expected one sample without a cone, got %+v", samples)
+		}
+
+		// Never flagged as facing away, even with shapes above it
+		sm := NewSceneManager()
+		if err := sm.AddShapes([]ShapeRequest{{ID: "ball", Type: "sphere", Properties: map[string]interface{}{
+			"center": []interface{}{0.0, 10.0, 0.0}, "radius": 1.0,
+		}}}); err != nil {
+			t.Fatalf("AddShapes() failed: %v", err)
+		}
+		if err := sm.AddLights([]LightRequest{bulb(nil)}); err != nil {
+			t.Fatalf("AddLights() failed: %v", err)
+		}
+		if got := sm.DetectLightsFacingAway(); len(got) != 0 {
+			t.Errorf("Expected a point light not to be flagged, got %v", got)
+		}
+	})
+}
+
+func TestSpotPenumbra(t *testing.T) {
+	spot := func(extra map[string]interface{}) LightRequest {
+		props := map[string]interface{}{
+			"center":   []interface{}{0.0, 3.0, 0.0},
+			"emission": []interface{}{10.0, 10.0, 10.0},
+		}
+		for key, value := range extra {
+			props[key] = value
+		}
+		return LightRequest{ID: "spot", Type: "point_spot_light", Properties: props}
+	}
+
+	t.Run("validation", func(t *testing.T) {
+		tests := []struct {
+			name       string
+			extra      map[string]interface{}
+			errorMatch string // Empty if the light is valid
+		}{
+			{"penumbra", map[string]interface{}{"inner_angle": 20.0, "outer_angle": 35.0}, ""},
+			{"full hemisphere", map[string]interface{}{"inner_angle": 0.0, "outer_angle": 180.0}, ""},
+			{"inner not less than outer", map[string]interface{}{"inner_angle": 35.0, "outer_angle": 35.0}, "must be less than outer_angle"},
+			{"outer too wide", map[string]interface{}{"inner_angle": 20.0, "outer_angle": 200.0}, "outer_angle must be between 0 and 180"},
+			{"outer missing", map[string]interface{}{"inner_angle": 20.0}, "requires 'outer_angle'"},
+			{"mixed with cutoff", map[string]interface{}{"inner_angle": 20.0, "outer_angle": 35.0, "cutoff_angle": 30.0}, "can't combine cutoff_angle"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				err := validateLightProperties(spot(tt.extra))
+				if tt.errorMatch == "" {
+					if err != nil {
+						t.Errorf("Expected no error, got %v", err)
+					}
+					return
+				}
+				if err == nil || !strings.Contains(err.Error(), tt.errorMatch) {
+					t.Errorf("Expected error containing %q, got %v", tt.errorMatch, err)
+				}
+			})
+		}
+
+		// Area disc spot lights accept the angles in place of their required cutoff and falloff
+		disc := LightRequest{ID: "disc", Type: "area_disc_spot_light", Properties: map[string]interface{}{
+			"center": []interface{}{0.0, 3.0, 0.0}, "normal": []interface{}{0.0, -1.0, 0.0}, "radius": 0.5,
+			"emission": []interface{}{10.0, 10.0, 10.0}, "inner_angle": 20.0, "outer_angle": 35.0,
+		}}
+		if err := validateLightProperties(disc); err != nil {
+			t.Errorf("Expected an area disc spot light with a penumbra to be valid, got %v", err)
+		}
+	})
+
+	t.Run("converts to cutoff and falloff", func(t *testing.T) {
+		// A fade across the whole hemisphere is half bright at 90 degrees, which is t = 0.5
+		cutoff, falloff := spotCone(spot(map[string]interface{}{"inner_angle": 0.0, "outer_angle": 180.0}).Properties, 0, 0)
+		if cutoff != 180 || math.Abs(falloff-1) > 1e-9 {
+			t.Errorf("Expected cutoff 180 and falloff 1, got %v and %v", cutoff, falloff)
+		}
+
+		// A narrower penumbra keeps more of the cone at full brightness, so the exponent drops
+		_, soft := spotCone(spot(map[string]interface{}{"inner_angle": 0.0, "outer_angle": 45.0}).Properties, 0, 0)
+		_, hard := spotCone(spot(map[string]interface{}{"inner_angle": 40.0, "outer_angle": 45.0}).Properties, 0, 0)
+		if !(hard < soft) {
+			t.Errorf("Expected a narrow penumbra to use a smaller exponent than a wide one, got %v and %v", hard, soft)
+		}
+
+		// Without angles, cutoff and falloff are used as before
+		if cutoff, falloff := spotCone(spot(nil).Properties, defaultSpotCutoffAngle, defaultSpotFalloffExponent); cutoff != defaultSpotCutoffAngle || falloff != defaultSpotFalloffExponent {
+			t.Errorf("Expected the defaults, got %v and %v", cutoff, falloff)
+		}
+	})
+
+	t.Run("update switches cone style", func(t *testing.T) {
+		sm := NewSceneManager()
+		if err := sm.AddLights([]LightRequest{spot(map[string]interface{}{"cutoff_angle": 30.0, "falloff_exponent": 2.0})}); err != nil {
+			t.Fatalf("AddLights() failed: %v", err)
+		}
+		err := sm.UpdateLight("spot", map[string]interface{}{
+			"properties": map[string]interface{}{"inner_angle": 15.0, "outer_angle": 30.0},
+		})
+		if err != nil {
+			t.Fatalf("Expected switching to inner/outer angles to succeed, got %v", err)
+		}
+		props := sm.FindLight("spot").Properties
+		if hasProperty(props, "cutoff_angle") || hasProperty(props, "falloff_exponent") {
+			t.Errorf("Expected cutoff_angle and falloff_exponent to be replaced, got %v", props)
+		}
+		if _, hasDefault := resolvedLightDefaults(*sm.FindLight("spot"))["cutoff_angle"]; hasDefault {
+			t.Error("Expected no cutoff_angle default for a light with a penumbra")
+		}
+	})
+}
+
+func TestLightShape(t *testing.T) {
+	newScene := func() *SceneManager {
+		sm := NewSceneManager()
+		if err := sm.AddShapes([]ShapeRequest{{ID: "ball", Type: "sphere", Properties: map[string]interface{}{
+			"center": []interface{}{1.0, 2.0, 3.0},
+			"radius": 1.0,
+		}}}); err != nil {
+			t.Fatalf("AddShapes() failed: %v", err)
+		}
+		return sm
+	}
+	near := func(got []float64, want vec3) bool {
+		for i := range want {
+			if math.Abs(got[i]-want[i]) > 1e-9 {
+				return false
+			}
+		}
+		return len(got) == 3
+	}
+
+	t.Run("spot from above", func(t *testing.T) {
+		sm := newScene()
+		light, err := sm.LightShape("ball", "", "", []float64{0, 2, 0}, 5, []float64{10, 10, 10})
+		if err != nil {
+			t.Fatalf("LightShape() failed: %v", err)
+		}
+		if light.ID != "ball_light" || light.Type != "point_spot_light" {
+			t.Errorf("Expected a point_spot_light named ball_light, got %s '%s'", light.Type, light.ID)
+		}
+		if center, _ := extractFloatArray(light.Properties, "center", 3); !near(center, vec3{1, 7, 3}) {
+			t.Errorf("Expected the light 5 above the shape's center, got %v", center)
+		}
+		if direction, _ := extractFloatArray(light.Properties, "direction", 3); !near(direction, vec3{0, -1, 0}) {
+			t.Errorf("Expected the spot to be aimed down at the shape, got %v", direction)
+		}
+		if sm.FindLight("ball_light") == nil {
+			t.Error("Expected the light to be added to the scene")
+		}
+	})
+
+	t.Run("point light with numbered default ID", func(t *testing.T) {
+		sm := newScene()
+		if _, err := sm.LightShape("ball", "", "point_light", []float64{1, 0, 0}, 2, []float64{1, 1, 1}); err != nil {
+			t.Fatalf("LightShape() failed: %v", err)
+		}
+		light, err := sm.LightShape("ball", "", "point_light", []float64{-1, 0, 0}, 2, []float64{1, 1, 1})
+		if err != nil {
+			t.Fatalf("LightShape() failed: %v", err)
+		}
+		if light.ID != "ball_light_2" {
+			t.Errorf("Expected the second light to be numbered, got '%s'", light.ID)
+		}
+		if _, hasDirection := light.Properties["direction"]; hasDirection {
+			t.Errorf("Expected a point light without a direction, got %v", light.Properties)
+		}
+		if center, _ := extractFloatArray(light.Properties, "center", 3); !near(center, vec3{-1, 2, 3}) {
+			t.Errorf("Expected the light 2 to the left of the shape, got %v", center)
+		}
+	})
+
+	errorCases := []struct {
+		name      string
+		shapeID   string
+		lightType string
+		direction []float64
+		distance  float64
+		emission  []float64
+		want      string
+	}{
+		{"missing shape", "nope", "", []float64{0, 1, 0}, 5, []float64{1, 1, 1}, "shape 'nope' not found"},
+		{"zero direction", "ball", "", []float64{0, 0, 0}, 5, []float64{1, 1, 1}, "non-zero"},
+		{"short direction", "ball", "", []float64{0, 1}, 5, []float64{1, 1, 1}, "3 values"},
+		{"non-positive distance", "ball", "", []float64{0, 1, 0}, 0, []float64{1, 1, 1}, "distance must be positive"},
+		{"area light", "ball", "area_quad_light", []float64{0, 1, 0}, 5, []float64{1, 1, 1}, "only point_light and point_spot_light"},
+		{"missing emission", "ball", "", []float64{0, 1, 0}, 5, nil, "emission"},
+	}
+	for _, tt := range errorCases {
+		t.Run(tt.name, func(t *testing.T) {
+			sm := newScene()
+			_, err := sm.LightShape(tt.shapeID, "", tt.lightType, tt.direction, tt.distance, tt.emission)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected an error containing %q, got %v", tt.want, err)
+			}
+			if len(sm.state.Lights) != 0 {
+				t.Errorf("Expected no light to be added, got %d", len(sm.state.Lights))
+			}
+		})
+	}
+}
+
+func TestLightShapeTool(t *testing.T) {
+	agent := NewWithProvider(make(chan AgentEvent, 100), &MockProvider{}, "mock-model")
+	if err := agent.sceneManager.AddShapes([]ShapeRequest{{ID: "crate", Type: "box", Properties: map[string]interface{}{
+		"center":     []interface{}{0.0, 0.5, 0.0},
+		"dimensions": []interface{}{1.0, 1.0, 1.0},
+	}}}); err != nil {
+		t.Fatalf("AddShapes() failed: %v", err)
+	}
+
+	req := parseToolRequestFromFunctionCall(&llm.FunctionCall{Name: "light_shape", Arguments: map[string]interface{}{
+		"id":        "crate",
+		"light_id":  "key",
+		"direction": []interface{}{-1.0, 1.0, 1.0},
+		"distance":  4.0,
+		"emission":  []interface{}{8.0, 8.0, 8.0},
+	}})
+	result := agent.executeToolRequests(context.Background(), req, "call-1")
+	if !result.Success {
+		t.Fatalf("Expected light_shape to succeed, got errors: %v", result.Errors)
+	}
+	light := req.(*LightShapeRequest).Light
+	if light == nil || light.ID != "key" || agent.sceneManager.FindLight("key") == nil {
+		t.Fatalf("Expected the light 'key' to be created, got %+v", light)
+	}
+	center, _ := extractFloatArray(light.Properties, "center", 3)
+	if distance := (vec3{center[0], center[1], center[2]}).sub(vec3{0, 0.5, 0}).length(); math.Abs(distance-4) > 1e-9 {
+		t.Errorf("Expected the light 4 from the crate's center, got %g", distance)
+	}
+}
@@ -0,0 +1,120 @@
+package agent
+
+import "sort"
+
+// sceneRevisions tracks when each shape, light, and the camera last changed
+// Every mutation bumps the scene revision so callers can ask for only what changed since a revision they've seen
+type sceneRevisions struct {
+	revision       int
+	shapes         map[string]int // Shape ID -> revision it was last added or updated
+	lights         map[string]int // Light ID -> revision it was last added or updated
+	removedShapes  map[string]int // Shape ID -> revision it was removed
+	removedLights  map[string]int // Light ID -> revision it was removed
+	cameraRevision int
+}
+
+// SceneChanges describes everything that changed in the scene after a given revision
+type SceneChanges struct {
+	Since         int            `json:"since"`    // Revision the changes are relative to
+	Revision      int            `json:"revision"` // Current revision, pass as 'since' next time
+	Shapes        []ShapeRequest `json:"shapes"`   // Shapes added or updated since the revision
+	Lights        []LightRequest `json:"lights"`   // Lights added or updated since the revision
+	RemovedShapes []string       `json:"removed_shapes"`
+	RemovedLights []string       `json:"removed_lights"`
+	Camera        *CameraInfo    `json:"camera,omitempty"` // Set only if the camera changed
+}
+
+// Revision returns the current scene revision, which increases with every mutation
+func (sm *SceneManager) Revision() int {
+	return sm.revisions.revision
+}
+
+// ChangesSince returns the shapes, lights, and camera that changed after revision rev
+// Objects removed and then re-added are reported as changed rather than removed
+func (sm *SceneManager) ChangesSince(rev int) *SceneChanges {
+	r := &sm.revisions
+	changes := &SceneChanges{
+		Since:         rev,
+		Revision:      r.revision,
+		Shapes:        []ShapeRequest{},
+		Lights:        []LightRequest{},
+		RemovedShapes: []string{},
+		RemovedLights: []string{},
+	}
+
+	for _, shape := range sm.state.Shapes {
+		if r.shapes[shape.ID] > rev {
+			changes.Shapes = append(changes.Shapes, shape)
+		}
+	}
+	for _, light := range sm.state.Lights {
+		if r.lights[light.ID] > rev {
+			changes.Lights = append(changes.Lights, light)
+		}
+	}
+
+	for id, removedAt := range r.removedShapes {
+		if removedAt > rev && sm.FindShape(id) == nil {
+			changes.RemovedShapes = append(changes.RemovedShapes, id)
+		}
+	}
+	for id, removedAt := range r.removedLights {
+		if removedAt > rev && sm.FindLight(id) == nil {
+			changes.RemovedLights = append(changes.RemovedLights, id)
+		}
+	}
+	sort.Strings(changes.RemovedShapes)
+	sort.Strings(changes.RemovedLights)
+
+	if r.cameraRevision > rev {
+		camera := sm.state.Camera
+		changes.Camera = &camera
+	}
+
+	return changes
+}
+
+// bump advances the scene revision and returns the new value
+func (r *sceneRevisions) bump() int {
+	r.revision++
+	return r.revision
+}
+
+// shapeChanged records that a shape was added or updated
+func (r *sceneRevisions) shapeChanged(id string) {
+	if r.shapes == nil {
+		r.shapes = make(map[string]int)
+	}
+	r.shapes[id] = r.bump()
+}
+
+// shapeRemoved records that a shape was removed
+func (r *sceneRevisions) shapeRemoved(id string) {
+	if r.removedShapes == nil {
+		r.removedShapes = make(map[string]int)
+	}
+	delete(r.shapes, id)
+	r.removedShapes[id] = r.bump()
+}
+
+// lightChanged records that a light was added or updated
+func (r *sceneRevisions) lightChanged(id string) {
+	if r.lights == nil {
+		r.lights = make(map[string]int)
+	}
+	r.lights[id] = r.bump()
+}
+
+// lightRemoved records that a light was removed
+func (r *sceneRevisions) lightRemoved(id string) {
+	if r.removedLights == nil {
+		r.removedLights = make(map[string]int)
+	}
+	delete(r.lights, id)
+	r.removedLights[id] = r.bump()
+}
+
+// cameraChanged records that the camera was updated
+func (r *sceneRevisions) cameraChanged() {
+	r.cameraRevision = r.bump()
+}
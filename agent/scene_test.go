@@ -1,9 +1,15 @@
 package agent
 
 import (
+	"fmt"
+	"math"
+	"reflect"
 	"regexp"
 	"strings"
 	"testing"
+
+	"github.com/df07/go-progressive-raytracer/pkg/material"
+	"github.com/df07/scene-llm/agent/llm"
 )
 
 // Helper function to compare CameraInfo structs (since slices can't be compared with ==)
@@ -180,6 +186,17 @@ func TestClearScene(t *testing.T) {
 	}
 	sm.AddShapes(shapes)
 
+	// A user camera made active, with the default one kept aside, and a default material
+	if err := sm.AddCamera("closeup", CameraInfo{Center: []float64{0, 1, 2}, LookAt: []float64{0, 0, 0}, VFov: 30}); err != nil {
+		t.Fatalf("AddCamera() failed: %v", err)
+	}
+	if err := sm.SelectCamera("closeup"); err != nil {
+		t.Fatalf("SelectCamera() failed: %v", err)
+	}
+	if err := sm.SetDefaultMaterial(map[string]interface{}{"type": "metal", "albedo": []interface{}{0.8, 0.8, 0.8}, "fuzz": 0.1}); err != nil {
+		t.Fatalf("SetDefaultMaterial() failed: %v", err)
+	}
+
 	if sm.GetShapeCount() != 1 {
 		t.Errorf("Expected 1 shape before clear, got %d", sm.GetShapeCount())
 	}
@@ -202,6 +219,23 @@ func TestClearScene(t *testing.T) {
 	if !cameraEqual(state.Camera, expectedCamera) {
 		t.Errorf("Expected camera reset to %+v, got %+v", expectedCamera, state.Camera)
 	}
+
+	// The reset camera is the only one and doesn't keep the user camera's name
+	if id := sm.ActiveCameraID(); id != mainCameraID {
+		t.Errorf("Expected the active camera to be %q after clear, got %q", mainCameraID, id)
+	}
+	if ids := sm.CameraIDs(); len(ids) != 1 {
+		t.Errorf("Expected only the default camera after clear, got %v", ids)
+	}
+	if err := sm.SelectCamera("main"); err != nil {
+		t.Errorf("Expected selecting the active camera to be a no-op, got %v", err)
+	}
+	if !cameraEqual(sm.GetCamera(), expectedCamera) {
+		t.Errorf("Expected the default camera to stay active, got %+v", sm.GetCamera())
+	}
+	if state.DefaultMaterial != nil {
+		t.Errorf("Expected the default material to be cleared, got %v", state.DefaultMaterial)
+	}
 }
 
 func TestGetStateReturnsImmutableCopy(t *testing.T) {
@@ -340,6 +374,59 @@ func TestFindShape(t *testing.T) {
 	}
 }
 
+func TestGetShapeCopy(t *testing.T) {
+	sm := NewSceneManager()
+
+	err := sm.AddShapes([]ShapeRequest{
+		{
+			ID:   "metal_sphere",
+			Type: "sphere",
+			Properties: map[string]interface{}{
+				"center": []interface{}{1.0, 2.0, 3.0},
+				"radius": 1.0,
+				"material": map[string]interface{}{
+					"type":   "metal",
+					"albedo": []interface{}{0.9, 0.9, 0.9},
+					"fuzz":   0.1,
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("AddShapes() returned error: %v", err)
+	}
+
+	shapeCopy, err := sm.GetShapeCopy("metal_sphere")
+	if err != nil {
+		t.Fatalf("GetShapeCopy() returned error: %v", err)
+	}
+	if shapeCopy.ID != "metal_sphere" || shapeCopy.Type != "sphere" {
+		t.Errorf("Copied wrong shape: %+v", shapeCopy)
+	}
+
+	// Mutating the copy (including nested values) must not affect the scene
+	shapeCopy.ID = "renamed"
+	shapeCopy.Properties["center"].([]interface{})[0] = 99.0
+	shapeCopy.Properties["material"].(map[string]interface{})["fuzz"] = 0.9
+
+	original := sm.FindShape("metal_sphere")
+	if original == nil {
+		t.Fatal("Original shape should still exist under its ID")
+	}
+	if center, _ := extractFloatArray(original.Properties, "center", 3); center[0] != 1.0 {
+		t.Errorf("Original center was mutated through copy: %v", center)
+	}
+	mat, _ := extractMaterial(original.Properties)
+	if fuzz, _ := extractFloat(mat, "fuzz"); fuzz != 0.1 {
+		t.Errorf("Original material was mutated through copy: fuzz=%v", fuzz)
+	}
+
+	// Missing shapes return an error
+	if _, err := sm.GetShapeCopy("missing"); err == nil {
+		t.Error("Expected error for missing shape")
+	}
+}
+
 func TestUpdateShape(t *testing.T) {
 	sm := NewSceneManager()
 
@@ -420,6 +507,163 @@ func TestUpdateShape(t *testing.T) {
 	}
 }
 
+func TestRevertShape(t *testing.T) {
+	sm := NewSceneManager()
+	sphere := ShapeRequest{
+		ID:   "ball",
+		Type: "sphere",
+		Properties: map[string]interface{}{
+			"center":   []interface{}{0.0, 1.0, 0.0},
+			"radius":   1.0,
+			"material": map[string]interface{}{"type": "lambertian", "albedo": []interface{}{0.8, 0.1, 0.1}},
+		},
+	}
+	if err := sm.AddShapes([]ShapeRequest{sphere}); err != nil {
+		t.Fatalf("AddShapes() failed: %v", err)
+	}
+
+	if err := sm.RevertShape("ball"); err == nil || !strings.Contains(err.Error(), "no previous state") {
+		t.Errorf("Expected an error reverting a shape that was never updated, got %v", err)
+	}
+
+	// Only the last update is undone
+	if err := sm.UpdateShape("ball", map[string]interface{}{"properties": map[string]interface{}{"radius": 2.0}}); err != nil {
+		t.Fatalf("UpdateShape() failed: %v", err)
+	}
+	if err := sm.UpdateShape("ball", map[string]interface{}{"properties": map[string]interface{}{
+		"radius":   3.0,
+		"material": map[string]interface{}{"type": "metal", "albedo": []interface{}{0.9, 0.9, 0.9}, "fuzz": 0.0},
+	}}); err != nil {
+		t.Fatalf("UpdateShape() failed: %v", err)
+	}
+	if err := sm.RevertShape("ball"); err != nil {
+		t.Fatalf("RevertShape() failed: %v", err)
+	}
+	ball := sm.FindShape("ball")
+	if radius, _ := extractFloat(ball.Properties, "radius"); radius != 2 {
+		t.Errorf("Expected radius 2 after revert, got %v", radius)
+	}
+	if material := ball.Properties["material"].(map[string]interface{}); material["type"] != "lambertian" {
+		t.Errorf("Expected the lambertian material back, got %v", material)
+	}
+
+	if err := sm.RevertShape("ball"); err == nil {
+		t.Error("Expected a second revert in a row to fail")
+	}
+
+	// A rename is undone too
+	if err := sm.UpdateShape("ball", map[string]interface{}{"id": "moon"}); err != nil {
+		t.Fatalf("UpdateShape() failed: %v", err)
+	}
+	if err := sm.RevertShape("ball"); err == nil {
+		t.Error("Expected reverting by the old ID to fail")
+	}
+	if err := sm.RevertShape("moon"); err != nil {
+		t.Fatalf("RevertShape() failed: %v", err)
+	}
+	if sm.FindShape("ball") == nil || sm.FindShape("moon") != nil {
+		t.Errorf("Expected the shape to be called 'ball' again, got %+v", sm.state.Shapes)
+	}
+
+	// Removing a shape forgets its history, so a new shape with the same ID starts fresh
+	if err := sm.UpdateShape("ball", map[string]interface{}{"properties": map[string]interface{}{"radius": 4.0}}); err != nil {
+		t.Fatalf("UpdateShape() failed: %v", err)
+	}
+	if err := sm.RemoveShape("ball"); err != nil {
+		t.Fatalf("RemoveShape() failed: %v", err)
+	}
+	if err := sm.AddShapes([]ShapeRequest{sphere}); err != nil {
+		t.Fatalf("AddShapes() failed: %v", err)
+	}
+	if err := sm.RevertShape("ball"); err == nil {
+		t.Error("Expected a re-added shape to have no previous state")
+	}
+}
+
+func TestRenameShapes(t *testing.T) {
+	newScene := func(t *testing.T) *SceneManager {
+		t.Helper()
+		sm := NewSceneManager()
+		var shapes []ShapeRequest
+		for i, id := range []string{"a", "b", "c"} {
+			shapes = append(shapes, ShapeRequest{ID: id, Type: "sphere", Properties: map[string]interface{}{
+				"center": []interface{}{float64(i), 0.0, 0.0},
+				"radius": 0.5,
+			}})
+		}
+		if err := sm.AddShapes(shapes); err != nil {
+			t.Fatalf("AddShapes() failed: %v", err)
+		}
+		return sm
+	}
+	ids := func(sm *SceneManager) []string {
+		var ids []string
+		for _, shape := range sm.state.Shapes {
+			ids = append(ids, shape.ID)
+		}
+		return ids
+	}
+
+	t.Run("swap and chain", func(t *testing.T) {
+		sm := newScene(t)
+		if err := sm.RenameShapes(map[string]string{"a": "b", "b": "a", "c": "d"}); err != nil {
+			t.Fatalf("RenameShapes() failed: %v", err)
+		}
+		if got := ids(sm); !reflect.DeepEqual(got, []string{"b", "a", "d"}) {
+			t.Errorf("Expected IDs [b a d], got %v", got)
+		}
+		// The shape first called 'a' sits at x=0
+		if center := vec3Property(sm.FindShape("b").Properties, "center", vec3{}); center[0] != 0 {
+			t.Errorf("Expected 'b' to be the shape that was 'a', got center %v", center)
+		}
+	})
+
+	tests := []struct {
+		name     string
+		mapping  map[string]string
+		expected string
+	}{
+		{"collision with an unrenamed shape", map[string]string{"a": "x", "b": "c"}, "shapes 'b' and 'c' would both be named 'c'"},
+		{"two shapes to one ID", map[string]string{"a": "x", "b": "x"}, "would both be named 'x'"},
+		{"missing shape", map[string]string{"a": "x", "zzz": "y"}, "shape with ID 'zzz' not found"},
+		{"empty new ID", map[string]string{"a": ""}, "new ID for shape 'a' must be a non-empty string"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sm := newScene(t)
+			err := sm.RenameShapes(tt.mapping)
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Fatalf("Expected an error containing %q, got %v", tt.expected, err)
+			}
+			// Nothing is renamed when any entry is invalid
+			if got := ids(sm); !reflect.DeepEqual(got, []string{"a", "b", "c"}) {
+				t.Errorf("Expected the scene to be unchanged, got %v", got)
+			}
+		})
+	}
+
+	t.Run("revert history follows the rename", func(t *testing.T) {
+		sm := newScene(t)
+		if err := sm.UpdateShape("a", map[string]interface{}{"properties": map[string]interface{}{"radius": 2.0}}); err != nil {
+			t.Fatalf("UpdateShape() failed: %v", err)
+		}
+		if err := sm.RenameShapes(map[string]string{"a": "moon"}); err != nil {
+			t.Fatalf("RenameShapes() failed: %v", err)
+		}
+		if err := sm.RevertShape("moon"); err != nil {
+			t.Fatalf("Expected the renamed shape to keep its revert history, got %v", err)
+		}
+		// The revert undoes the update but keeps the new name
+		moon := sm.FindShape("moon")
+		if moon == nil || sm.FindShape("a") != nil {
+			t.Fatalf("Expected the reverted shape to still be 'moon', got IDs %v", ids(sm))
+		}
+		if radius, _ := extractFloat(moon.Properties, "radius"); radius != 0.5 {
+			t.Errorf("Expected the revert to restore radius 0.5, got %g", radius)
+		}
+	})
+}
+
 func TestRemoveShape(t *testing.T) {
 	// Set up initial shapes
 	initialShapes := []ShapeRequest{
@@ -540,6 +784,157 @@ func TestRemoveShape(t *testing.T) {
 
 // Tests for helper functions
 
+func TestArrayShapes(t *testing.T) {
+	newScene := func(t *testing.T) *SceneManager {
+		t.Helper()
+		sm := NewSceneManager()
+		err := sm.AddShapes([]ShapeRequest{{
+			ID:   "tile",
+			Type: "box",
+			Properties: map[string]interface{}{
+				"center":     []interface{}{0.0, 0.0, 0.0},
+				"dimensions": []interface{}{0.9, 0.1, 0.9},
+				"material":   map[string]interface{}{"type": "lambertian", "albedo": []interface{}{0.8, 0.8, 0.8}},
+			},
+		}})
+		if err != nil {
+			t.Fatalf("Failed to add source shape: %v", err)
+		}
+		return sm
+	}
+
+	t.Run("grid", func(t *testing.T) {
+		sm := newScene(t)
+		ids, err := sm.ArrayShapes("tile", [3]int{3, 1, 2}, [3]float64{1, 0, 2})
+		if err != nil {
+			t.Fatalf("ArrayShapes() failed: %v", err)
+		}
+		if len(ids) != 5 || sm.GetShapeCount() != 6 {
+			t.Fatalf("Expected 5 copies and 6 shapes, got %v and %d shapes", ids, sm.GetShapeCount())
+		}
+
+		clone := sm.FindShape("tile_2_0_1")
+		if clone == nil {
+			t.Fatalf("Expected shape tile_2_0_1, got %v", ids)
+		}
+		center, _ := extractFloatArray(clone.Properties, "center", 3)
+		if center[0] != 2 || center[1] != 0 || center[2] != 2 {
+			t.Errorf("Expected tile_2_0_1 at [2 0 2], got %v", center)
+		}
+
+		// Copies don't share state with the source
+		clone.Properties["material"].(map[string]interface{})["albedo"] = []interface{}{1.0, 0.0, 0.0}
+		albedo, _ := extractFloatArray(sm.FindShape("tile").Properties["material"].(map[string]interface{}), "albedo", 3)
+		if albedo[0] != 0.8 || albedo[1] != 0.8 {
+			t.Errorf("Expected source material to be unchanged, got %v", albedo)
+		}
+	})
+
+	t.Run("translates every position property", func(t *testing.T) {
+		sm := NewSceneManager()
+		err := sm.AddShapes([]ShapeRequest{{ID: "post", Type: "cylinder", Properties: map[string]interface{}{
+			"base_center": []interface{}{0.0, 0.0, 0.0}, "top_center": []interface{}{0.0, 2.0, 0.0}, "radius": 0.1, "capped": true,
+		}}})
+		if err != nil {
+			t.Fatalf("Failed to add source shape: %v", err)
+		}
+		if _, err := sm.ArrayShapes("post", [3]int{2, 1, 1}, [3]float64{3, 0, 0}); err != nil {
+			t.Fatalf("ArrayShapes() failed: %v", err)
+		}
+		clone := sm.FindShape("post_1_0_0")
+		base, _ := extractFloatArray(clone.Properties, "base_center", 3)
+		top, _ := extractFloatArray(clone.Properties, "top_center", 3)
+		if base[0] != 3 || top[0] != 3 || top[1] != 2 {
+			t.Errorf("Expected both ends moved by 3 along x, got base %v top %v", base, top)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			sourceID string
+			counts   [3]int
+		}{
+			{"missing source", "nope", [3]int{2, 1, 1}},
+			{"zero count", "tile", [3]int{2, 0, 1}},
+			{"no copies", "tile", [3]int{1, 1, 1}},
+			{"exceeds MaxShapes", "tile", [3]int{MaxShapes, 2, 1}},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				sm := newScene(t)
+				if _, err := sm.ArrayShapes(tt.sourceID, tt.counts, [3]float64{1, 1, 1}); err == nil {
+					t.Error("Expected error, got none")
+				}
+				if sm.GetShapeCount() != 1 {
+					t.Errorf("Expected no shapes to be added, got %d", sm.GetShapeCount())
+				}
+			})
+		}
+	})
+
+	t.Run("ID collision adds nothing", func(t *testing.T) {
+		sm := newScene(t)
+		err := sm.AddShapes([]ShapeRequest{{ID: "tile_1_0_0", Type: "sphere", Properties: map[string]interface{}{
+			"center": []interface{}{5.0, 0.0, 0.0}, "radius": 0.5,
+		}}})
+		if err != nil {
+			t.Fatalf("Failed to add blocking shape: %v", err)
+		}
+		if _, err := sm.ArrayShapes("tile", [3]int{3, 1, 1}, [3]float64{1, 0, 0}); err == nil {
+			t.Error("Expected ID collision error")
+		}
+		if sm.GetShapeCount() != 2 {
+			t.Errorf("Expected no copies to be added, got %d shapes", sm.GetShapeCount())
+		}
+	})
+}
+
+func TestAddShapesRespectsMaxShapes(t *testing.T) {
+	sm := NewSceneManager()
+	shapes := make([]ShapeRequest, MaxShapes+1)
+	for i := range shapes {
+		shapes[i] = ShapeRequest{ID: fmt.Sprintf("s%d", i), Type: "sphere", Properties: map[string]interface{}{
+			"center": []interface{}{float64(i), 0.0, 0.0}, "radius": 0.4,
+		}}
+	}
+	if err := sm.AddShapes(shapes); err == nil {
+		t.Error("Expected adding more than MaxShapes shapes to fail")
+	}
+	if err := sm.AddShapes(shapes[:MaxShapes]); err != nil {
+		t.Errorf("Expected exactly MaxShapes shapes to be allowed, got %v", err)
+	}
+}
+
+func TestRemoveShapes(t *testing.T) {
+	sm := NewSceneManager()
+	err := sm.AddShapes([]ShapeRequest{
+		{ID: "shape1", Type: "sphere", Properties: map[string]interface{}{"center": []interface{}{0.0, 0.0, 0.0}, "radius": 1.0}},
+		{ID: "shape2", Type: "sphere", Properties: map[string]interface{}{"center": []interface{}{2.0, 0.0, 0.0}, "radius": 1.0}},
+		{ID: "shape3", Type: "sphere", Properties: map[string]interface{}{"center": []interface{}{4.0, 0.0, 0.0}, "radius": 1.0}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to add shapes: %v", err)
+	}
+
+	// A missing ID doesn't stop the others from being removed
+	results := sm.RemoveShapes([]string{"shape1", "missing", "shape3", "shape1"})
+
+	if len(results) != 3 {
+		t.Errorf("Expected a result for each distinct ID, got %v", results)
+	}
+	if results["shape1"] != nil || results["shape3"] != nil {
+		t.Errorf("Expected shape1 and shape3 to be removed, got %v", results)
+	}
+	if results["missing"] == nil {
+		t.Error("Expected an error for the missing ID")
+	}
+
+	if sm.GetShapeCount() != 1 || sm.FindShape("shape2") == nil {
+		t.Errorf("Expected only shape2 to remain, got %d shapes", sm.GetShapeCount())
+	}
+}
+
 func TestExtractFloatArray(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -843,6 +1238,39 @@ func TestToRaytracerSceneWithRotation(t *testing.T) {
 	// (The actual raytracer functionality is tested by the raytracer library itself)
 }
 
+func TestSphereRotationRoundTrip(t *testing.T) {
+	sm := NewSceneManager()
+
+	shape := ShapeRequest{
+		ID:   "globe",
+		Type: "sphere",
+		Properties: map[string]interface{}{
+			"center":   []interface{}{0.0, 1.0, 0.0},
+			"radius":   1.0,
+			"rotation": []interface{}{0.1, 0.2, 0.3},
+		},
+	}
+	if err := sm.AddShapes([]ShapeRequest{shape}); err != nil {
+		t.Fatalf("AddShapes() with sphere rotation failed: %v", err)
+	}
+
+	rotation, ok := extractFloatArray(sm.GetState().Shapes[0].Properties, "rotation", 3)
+	if !ok {
+		t.Fatal("Expected sphere rotation to be preserved")
+	}
+	if rotation[0] != 0.1 || rotation[1] != 0.2 || rotation[2] != 0.3 {
+		t.Errorf("Expected rotation [0.1 0.2 0.3], got %v", rotation)
+	}
+
+	raytracerScene, err := sm.ToRaytracerScene()
+	if err != nil {
+		t.Fatalf("ToRaytracerScene() failed: %v", err)
+	}
+	if len(raytracerScene.Shapes) != 1 {
+		t.Errorf("Expected 1 shape in raytracer scene, got %d", len(raytracerScene.Shapes))
+	}
+}
+
 func TestQuadAndDiscCreation(t *testing.T) {
 	sm := NewSceneManager()
 
@@ -931,6 +1359,32 @@ func TestValidateShapeProperties(t *testing.T) {
 			},
 			shouldError: false,
 		},
+		{
+			name: "valid sphere with rotation",
+			shape: ShapeRequest{
+				ID:   "rotated_sphere",
+				Type: "sphere",
+				Properties: map[string]interface{}{
+					"center":   []interface{}{0.0, 1.0, 2.0},
+					"radius":   1.5,
+					"rotation": []interface{}{0.0, 1.57, 0.0},
+				},
+			},
+			shouldError: false,
+		},
+		{
+			name: "sphere with invalid rotation",
+			shape: ShapeRequest{
+				ID:   "bad_rotation",
+				Type: "sphere",
+				Properties: map[string]interface{}{
+					"center":   []interface{}{0.0, 1.0, 2.0},
+					"radius":   1.5,
+					"rotation": []interface{}{0.0, 1.57},
+				},
+			},
+			shouldError: true,
+		},
 		{
 			name: "valid box",
 			shape: ShapeRequest{
@@ -1188,6 +1642,44 @@ func TestValidateShapeProperties(t *testing.T) {
 	})
 }
 
+func TestQuadDegeneracyValidation(t *testing.T) {
+	tests := []struct {
+		name          string
+		u, v          []interface{}
+		expectedError string // Empty for a valid quad
+	}{
+		{"valid", []interface{}{1.0, 0.0, 0.0}, []interface{}{0.0, 0.0, 1.0}, ""},
+		{"small but valid", []interface{}{1e-3, 0.0, 0.0}, []interface{}{0.0, 1e-3, 0.0}, ""},
+		{"zero-length u", []interface{}{0.0, 0.0, 0.0}, []interface{}{0.0, 0.0, 1.0}, "u must be non-zero"},
+		{"zero-length v", []interface{}{1.0, 0.0, 0.0}, []interface{}{0.0, 0.0, 0.0}, "v must be non-zero"},
+		{"v is a multiple of u", []interface{}{1.0, 2.0, 0.0}, []interface{}{2.5, 5.0, 0.0}, "u and v must not be parallel"},
+		{"v is a negative multiple of u", []interface{}{0.0, 1.0, 1.0}, []interface{}{0.0, -3.0, -3.0}, "u and v must not be parallel"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shape := ShapeRequest{ID: "panel", Type: "quad", Properties: map[string]interface{}{
+				"corner": []interface{}{0.0, 0.0, 0.0}, "u": tt.u, "v": tt.v,
+			}}
+			light := LightRequest{ID: "panel_light", Type: "area_quad_light", Properties: map[string]interface{}{
+				"corner": []interface{}{0.0, 0.0, 0.0}, "u": tt.u, "v": tt.v, "emission": []interface{}{5.0, 5.0, 5.0},
+			}}
+
+			for name, err := range map[string]error{"quad": validateShapeProperties(shape), "area_quad_light": validateLightProperties(light)} {
+				if tt.expectedError == "" {
+					if err != nil {
+						t.Errorf("%s: expected no error, got %v", name, err)
+					}
+					continue
+				}
+				if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+					t.Errorf("%s: expected error containing %q, got %v", name, tt.expectedError, err)
+				}
+			}
+		})
+	}
+}
+
 func TestShapeWithLambertianMaterial(t *testing.T) {
 	sm := NewSceneManager()
 
@@ -1669,54 +2161,587 @@ func TestDielectricMaterialValidation(t *testing.T) {
 	}
 }
 
-func TestSetCamera(t *testing.T) {
+func TestMixMaterialValidation(t *testing.T) {
 	sm := NewSceneManager()
 
-	// Test valid camera configuration
-	newCamera := CameraInfo{
-		Center:   []float64{10, 5, 15},
-		LookAt:   []float64{0, 0, 0},
-		VFov:     60.0,
-		Aperture: 0.1,
+	lambertian := map[string]interface{}{
+		"type":   "lambertian",
+		"albedo": []interface{}{0.8, 0.2, 0.2},
 	}
-
-	err := sm.SetCamera(newCamera)
-	if err != nil {
-		t.Fatalf("SetCamera() with valid config returned error: %v", err)
+	metal := map[string]interface{}{
+		"type":   "metal",
+		"albedo": []interface{}{0.9, 0.9, 0.9},
+		"fuzz":   0.1,
 	}
-
-	state := sm.GetState()
-	if !cameraEqual(state.Camera, newCamera) {
-		t.Errorf("Camera not updated correctly. Expected %+v, got %+v", newCamera, state.Camera)
+	mixShape := func(mat map[string]interface{}) ShapeRequest {
+		return ShapeRequest{
+			ID:   "test",
+			Type: "sphere",
+			Properties: map[string]interface{}{
+				"center":   []interface{}{0.0, 0.0, 0.0},
+				"radius":   1.0,
+				"material": mat,
+			},
+		}
+	}
+	mix := func(a, b map[string]interface{}, factor interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"type":       "mix",
+			"material_a": a,
+			"material_b": b,
+			"factor":     factor,
+		}
 	}
-}
-
-func TestSetCameraValidation(t *testing.T) {
-	sm := NewSceneManager()
 
 	tests := []struct {
-		name         string
-		camera       CameraInfo
-		expectError  bool
-		errorPattern string
+		name        string
+		shape       ShapeRequest
+		expectError bool
+		errorMatch  string
 	}{
 		{
-			name: "valid camera",
-			camera: CameraInfo{
-				Center:   []float64{1, 2, 3},
-				LookAt:   []float64{0, 0, 0},
-				VFov:     45.0,
-				Aperture: 0.0,
-			},
+			name:        "valid mix",
+			shape:       mixShape(mix(lambertian, metal, 0.3)),
 			expectError: false,
 		},
 		{
-			name: "invalid vfov - too low",
-			camera: CameraInfo{
-				Center:   []float64{1, 2, 3},
-				LookAt:   []float64{0, 0, 0},
-				VFov:     0.0,
-				Aperture: 0.0,
+			name:        "valid nested mix",
+			shape:       mixShape(mix(mix(lambertian, metal, 0.5), lambertian, 1.0)),
+			expectError: false,
+		},
+		{
+			name:        "factor below range",
+			shape:       mixShape(mix(lambertian, metal, -0.1)),
+			expectError: true,
+			errorMatch:  "factor must be >= 0.0",
+		},
+		{
+			name:        "factor above range",
+			shape:       mixShape(mix(lambertian, metal, 1.5)),
+			expectError: true,
+			errorMatch:  "factor must be <= 1.0",
+		},
+		{
+			name: "missing factor",
+			shape: mixShape(map[string]interface{}{
+				"type":       "mix",
+				"material_a": lambertian,
+				"material_b": metal,
+			}),
+			expectError: true,
+			errorMatch:  "requires 'factor' property",
+		},
+		{
+			name: "missing material_b",
+			shape: mixShape(map[string]interface{}{
+				"type":       "mix",
+				"material_a": lambertian,
+				"factor":     0.5,
+			}),
+			expectError: true,
+			errorMatch:  "requires 'material_b' to be a material object",
+		},
+		{
+			name: "invalid nested sub-material",
+			shape: mixShape(mix(lambertian, map[string]interface{}{
+				"type":   "metal",
+				"albedo": []interface{}{0.9, 0.9, 0.9},
+			}, 0.5)),
+			expectError: true,
+			errorMatch:  "requires 'fuzz' property",
+		},
+		{
+			name:        "nesting too deep",
+			shape:       mixShape(mix(mix(mix(mix(lambertian, metal, 0.5), metal, 0.5), metal, 0.5), metal, 0.5)),
+			expectError: true,
+			errorMatch:  "nested at most 3 levels deep",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := sm.AddShapes([]ShapeRequest{tt.shape})
+			if tt.expectError {
+				if err == nil {
+					t.Error("Expected error but got none")
+				} else if !strings.Contains(err.Error(), tt.errorMatch) {
+					t.Errorf("Expected error containing '%s', got: %v", tt.errorMatch, err)
+				}
+			} else {
+				if err != nil {
+					t.Errorf("Expected no error, got: %v", err)
+				}
+			}
+			// Clear shapes for next test
+			sm.state.Shapes = []ShapeRequest{}
+		})
+	}
+}
+
+func TestToRaytracerSceneWithMixMaterial(t *testing.T) {
+	sm := NewSceneManager()
+
+	shape := ShapeRequest{
+		ID:   "wet_stone",
+		Type: "sphere",
+		Properties: map[string]interface{}{
+			"center": []interface{}{0.0, 1.0, 0.0},
+			"radius": 1.0,
+			"material": map[string]interface{}{
+				"type":   "mix",
+				"factor": 0.25,
+				"material_a": map[string]interface{}{
+					"type":   "lambertian",
+					"albedo": []interface{}{0.4, 0.4, 0.4},
+				},
+				"material_b": map[string]interface{}{
+					"type":             "dielectric",
+					"refractive_index": 1.33,
+				},
+			},
+		},
+	}
+
+	if err := sm.AddShapes([]ShapeRequest{shape}); err != nil {
+		t.Fatalf("AddShapes() with mix material failed: %v", err)
+	}
+
+	raytracerScene, err := sm.ToRaytracerScene()
+	if err != nil {
+		t.Fatalf("ToRaytracerScene() failed: %v", err)
+	}
+	if len(raytracerScene.Shapes) != 1 {
+		t.Errorf("Expected 1 shape in raytracer scene, got %d", len(raytracerScene.Shapes))
+	}
+}
+
+func TestShapeOpacity(t *testing.T) {
+	sphere := func(opacity interface{}) ShapeRequest {
+		props := map[string]interface{}{
+			"center": []interface{}{0.0, 1.0, 0.0},
+			"radius": 1.0,
+			"material": map[string]interface{}{
+				"type":   "lambertian",
+				"albedo": []interface{}{0.8, 0.1, 0.1},
+			},
+		}
+		if opacity != nil {
+			props["opacity"] = opacity
+		}
+		return ShapeRequest{ID: "film", Type: "sphere", Properties: props}
+	}
+
+	t.Run("validation", func(t *testing.T) {
+		for _, opacity := range []interface{}{0.0, 0.5, 1.0} {
+			if err := validateShapeProperties(sphere(opacity)); err != nil {
+				t.Errorf("Expected opacity %v to be valid, got %v", opacity, err)
+			}
+		}
+		for _, opacity := range []interface{}{-0.1, 1.5, "half"} {
+			if err := validateShapeProperties(sphere(opacity)); err == nil {
+				t.Errorf("Expected opacity %v to be rejected", opacity)
+			}
+		}
+	})
+
+	t.Run("round trip", func(t *testing.T) {
+		sm := NewSceneManager()
+		if err := sm.AddShapes([]ShapeRequest{sphere(0.4)}); err != nil {
+			t.Fatalf("AddShapes() failed: %v", err)
+		}
+		if opacity, _ := extractFloat(sm.FindShape("film").Properties, "opacity"); opacity != 0.4 {
+			t.Errorf("Expected opacity 0.4 to round-trip, got %v", opacity)
+		}
+		if _, err := sm.ToRaytracerScene(); err != nil {
+			t.Fatalf("ToRaytracerScene() failed: %v", err)
+		}
+	})
+
+	t.Run("applied at conversion", func(t *testing.T) {
+		if _, ok := createShapeMaterial(sphere(0.4).Properties).(*material.Mix); !ok {
+			t.Error("Expected a translucent shape to use a mixed material")
+		}
+		if _, ok := createShapeMaterial(sphere(1.0).Properties).(*material.Lambertian); !ok {
+			t.Error("Expected a fully opaque shape to keep its material unchanged")
+		}
+		if _, ok := createShapeMaterial(sphere(nil).Properties).(*material.Lambertian); !ok {
+			t.Error("Expected a shape without opacity to keep its material unchanged")
+		}
+	})
+}
+
+func TestShapeTags(t *testing.T) {
+	head := func(tags, description interface{}) ShapeRequest {
+		props := map[string]interface{}{
+			"center": []interface{}{0.0, 2.5, 0.0},
+			"radius": 0.5,
+		}
+		if tags != nil {
+			props["tags"] = tags
+		}
+		if description != nil {
+			props["description"] = description
+		}
+		return ShapeRequest{ID: "head", Type: "sphere", Properties: props}
+	}
+
+	t.Run("validation", func(t *testing.T) {
+		if err := validateShapeProperties(head([]interface{}{"snowman", "head"}, "the character's head")); err != nil {
+			t.Errorf("Expected tags and description to be valid, got %v", err)
+		}
+		if err := validateShapeProperties(head([]interface{}{}, nil)); err != nil {
+			t.Errorf("Expected empty tags to be valid, got %v", err)
+		}
+		for _, tags := range []interface{}{"snowman", []interface{}{"snowman", 3.0}, []interface{}{""}} {
+			if err := validateShapeProperties(head(tags, nil)); err == nil || !strings.Contains(err.Error(), "'tags' must be an array of non-empty strings") {
+				t.Errorf("Expected tags %v to be rejected, got %v", tags, err)
+			}
+		}
+		if err := validateShapeProperties(head(nil, 42.0)); err == nil || !strings.Contains(err.Error(), "'description' must be a string") {
+			t.Errorf("Expected a non-string description to be rejected, got %v", err)
+		}
+	})
+
+	t.Run("preserved", func(t *testing.T) {
+		sm := NewSceneManager()
+		if err := sm.AddShapes([]ShapeRequest{head([]interface{}{"snowman", "Head"}, "the character's head")}); err != nil {
+			t.Fatalf("AddShapes() failed: %v", err)
+		}
+		if err := sm.UpdateShape("head", map[string]interface{}{"properties": map[string]interface{}{"radius": 0.6}}); err != nil {
+			t.Fatalf("UpdateShape() failed: %v", err)
+		}
+		if found := sm.FindShapesByTag("head"); len(found) != 1 || found[0].Properties["description"] != "the character's head" {
+			t.Errorf("Expected tags and description to survive an update, got %+v", found)
+		}
+
+		snippet, err := sm.ExportShape("head")
+		if err != nil {
+			t.Fatalf("ExportShape() failed: %v", err)
+		}
+		imported, err := sm.ImportShape(snippet, "head_2")
+		if err != nil {
+			t.Fatalf("ImportShape() failed: %v", err)
+		}
+		if tags, _ := extractStringArray(imported.Properties, "tags"); !reflect.DeepEqual(tags, []string{"snowman", "Head"}) {
+			t.Errorf("Expected tags to round-trip through export and import, got %v", imported.Properties["tags"])
+		}
+		if found := sm.FindShapesByTag("SNOWMAN"); len(found) != 2 {
+			t.Errorf("Expected both shapes to match case-insensitively, got %d", len(found))
+		}
+		if found := sm.FindShapesByTag("hat"); len(found) != 0 {
+			t.Errorf("Expected no shapes tagged hat, got %+v", found)
+		}
+		if _, err := sm.ToRaytracerScene(); err != nil {
+			t.Errorf("Expected tags to be ignored when rendering, got %v", err)
+		}
+	})
+}
+
+func TestSetCamera(t *testing.T) {
+	sm := NewSceneManager()
+
+	// Test valid camera configuration
+	newCamera := CameraInfo{
+		Center:   []float64{10, 5, 15},
+		LookAt:   []float64{0, 0, 0},
+		VFov:     60.0,
+		Aperture: 0.1,
+	}
+
+	err := sm.SetCamera(newCamera)
+	if err != nil {
+		t.Fatalf("SetCamera() with valid config returned error: %v", err)
+	}
+
+	state := sm.GetState()
+	if !cameraEqual(state.Camera, newCamera) {
+		t.Errorf("Camera not updated correctly. Expected %+v, got %+v", newCamera, state.Camera)
+	}
+}
+
+func TestGetCameraReturnsCopy(t *testing.T) {
+	sm := NewSceneManager()
+	if err := sm.SetCamera(CameraInfo{Center: []float64{0, 2, 10}, LookAt: []float64{0, 2, 0}, VFov: 40}); err != nil {
+		t.Fatalf("SetCamera() failed: %v", err)
+	}
+
+	camera := sm.GetCamera()
+	camera.Center[0] = 99
+	camera.LookAt[0] = 99
+
+	if sm.state.Camera.Center[0] != 0 || sm.state.Camera.LookAt[0] != 0 {
+		t.Errorf("Expected modifying the returned camera to leave the scene unchanged, got %v", sm.state.Camera)
+	}
+}
+
+func TestDeriveCameraDetails(t *testing.T) {
+	details := deriveCameraDetails(CameraInfo{Center: []float64{3, 2, 0}, LookAt: []float64{0, 2, -4}, VFov: 45})
+
+	if details.Distance != 5 {
+		t.Errorf("Expected distance 5, got %v", details.Distance)
+	}
+	if details.FocusDistance != 5 {
+		t.Errorf("Expected focus distance to default to the look_at distance, got %v", details.FocusDistance)
+	}
+	expected := vec3{-0.6, 0, -0.8}
+	if got := (vec3{details.ViewDirection[0], details.ViewDirection[1], details.ViewDirection[2]}); !vecNear(got, expected) {
+		t.Errorf("Expected view direction %v, got %v", expected, got)
+	}
+	if details.VFov != 45 {
+		t.Errorf("Expected camera fields to be included, got vfov %v", details.VFov)
+	}
+}
+
+func TestDollyCamera(t *testing.T) {
+	tests := []struct {
+		name           string
+		factor         float64
+		expectError    bool
+		expectedCenter []float64
+	}{
+		{"move closer", 0.5, false, []float64{0, 2, 5}},
+		{"move away", 2, false, []float64{0, 2, 20}},
+		{"zero factor", 0, true, nil},
+		{"negative factor would cross look_at", -1, true, nil},
+		{"too close to look_at", 1e-6, true, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sm := NewSceneManager()
+			if err := sm.SetCamera(CameraInfo{Center: []float64{0, 2, 10}, LookAt: []float64{0, 2, 0}, VFov: 40}); err != nil {
+				t.Fatalf("SetCamera() failed: %v", err)
+			}
+			revision := sm.Revision()
+
+			err := sm.DollyCamera(tt.factor)
+			if tt.expectError {
+				if err == nil {
+					t.Fatal("Expected error, got none")
+				}
+				if sm.state.Camera.Center[2] != 10 || sm.Revision() != revision {
+					t.Errorf("Expected camera to be unchanged after a rejected zoom, got %v", sm.state.Camera.Center)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			camera := sm.GetCamera()
+			for i := range tt.expectedCenter {
+				if math.Abs(camera.Center[i]-tt.expectedCenter[i]) > 1e-9 {
+					t.Fatalf("Expected center %v, got %v", tt.expectedCenter, camera.Center)
+				}
+			}
+			if camera.LookAt[2] != 0 || camera.VFov != 40 {
+				t.Errorf("Expected look_at and vfov unchanged, got %+v", camera)
+			}
+		})
+	}
+}
+
+func TestSceneBounds(t *testing.T) {
+	sm := NewSceneManager()
+	if _, _, ok := sm.SceneBounds(); ok {
+		t.Error("Expected no bounds for an empty scene")
+	}
+
+	shapes := []ShapeRequest{
+		{ID: "ball", Type: "sphere", Properties: map[string]interface{}{"center": []interface{}{0.0, 1.0, 0.0}, "radius": 1.0}},
+		{ID: "crate", Type: "box", Properties: map[string]interface{}{"center": []interface{}{3.0, 0.5, 0.0}, "dimensions": []interface{}{2.0, 1.0, 2.0}}},
+	}
+	if err := sm.AddShapes(shapes); err != nil {
+		t.Fatalf("AddShapes() failed: %v", err)
+	}
+
+	min, max, ok := sm.SceneBounds()
+	if !ok {
+		t.Fatal("Expected bounds for a scene with shapes")
+	}
+	expectedMin := []float64{-1, 0, -1}
+	expectedMax := []float64{4, 2, 1}
+	for i := range expectedMin {
+		if math.Abs(min[i]-expectedMin[i]) > 1e-9 || math.Abs(max[i]-expectedMax[i]) > 1e-9 {
+			t.Fatalf("Expected bounds %v to %v, got %v to %v", expectedMin, expectedMax, min, max)
+		}
+	}
+}
+
+func TestSetCameraPreset(t *testing.T) {
+	newScene := func(t *testing.T) *SceneManager {
+		sm := NewSceneManager()
+		shape := ShapeRequest{ID: "ball", Type: "sphere", Properties: map[string]interface{}{"center": []interface{}{0.0, 0.0, 0.0}, "radius": 1.0}}
+		if err := sm.AddShapes([]ShapeRequest{shape}); err != nil {
+			t.Fatalf("AddShapes() failed: %v", err)
+		}
+		if err := sm.SetCamera(CameraInfo{Center: []float64{0, 0, 10}, LookAt: []float64{0, 0, 0}, VFov: 60, Aperture: 0.1}); err != nil {
+			t.Fatalf("SetCamera() failed: %v", err)
+		}
+		return sm
+	}
+
+	// The bounding box's half-diagonal is sqrt(3), which must fit in half the 60 degree vfov
+	distance := presetFramingMargin * math.Sqrt(3) / math.Sin(math.Pi/6)
+	diagonal := distance / math.Sqrt(3)
+
+	tests := []struct {
+		preset         string
+		expectedCenter []float64
+	}{
+		{"front", []float64{0, 0, distance}},
+		{"back", []float64{0, 0, -distance}},
+		{"left", []float64{-distance, 0, 0}},
+		{"right", []float64{distance, 0, 0}},
+		{"iso", []float64{diagonal, diagonal, diagonal}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.preset, func(t *testing.T) {
+			sm := newScene(t)
+			if err := sm.SetCameraPreset(tt.preset); err != nil {
+				t.Fatalf("SetCameraPreset() failed: %v", err)
+			}
+
+			camera := sm.GetCamera()
+			for i := range tt.expectedCenter {
+				if math.Abs(camera.Center[i]-tt.expectedCenter[i]) > 1e-9 || camera.LookAt[i] != 0 {
+					t.Fatalf("Expected camera at %v looking at the origin, got %+v", tt.expectedCenter, camera)
+				}
+			}
+			if camera.VFov != 60 || camera.Aperture != 0.1 {
+				t.Errorf("Expected vfov and aperture unchanged, got %+v", camera)
+			}
+		})
+	}
+
+	t.Run("top", func(t *testing.T) {
+		sm := newScene(t)
+		if err := sm.SetCameraPreset("top"); err != nil {
+			t.Fatalf("SetCameraPreset() failed: %v", err)
+		}
+		camera := sm.GetCamera()
+		if math.Abs(camera.Center[1]-distance) > 1e-3 || math.Abs(camera.Center[0]) > 1e-9 {
+			t.Errorf("Expected camera above the scene, got %v", camera.Center)
+		}
+	})
+
+	t.Run("unknown preset", func(t *testing.T) {
+		sm := newScene(t)
+		revision := sm.Revision()
+		if err := sm.SetCameraPreset("underneath"); err == nil {
+			t.Fatal("Expected error for unknown preset")
+		}
+		if sm.state.Camera.Center[2] != 10 || sm.Revision() != revision {
+			t.Errorf("Expected camera to be unchanged, got %v", sm.state.Camera.Center)
+		}
+	})
+
+	t.Run("empty scene", func(t *testing.T) {
+		sm := NewSceneManager()
+		if err := sm.SetCameraPreset("front"); err == nil {
+			t.Fatal("Expected error for an empty scene")
+		}
+	})
+}
+
+func TestFrameShape(t *testing.T) {
+	sm := NewSceneManager()
+	err := sm.AddShapes([]ShapeRequest{
+		{ID: "ball", Type: "sphere", Properties: map[string]interface{}{"center": []interface{}{4.0, 1.0, 0.0}, "radius": 0.5}},
+		{ID: "crate", Type: "box", Properties: map[string]interface{}{"center": []interface{}{-3.0, 1.0, 0.0}, "dimensions": []interface{}{2.0, 2.0, 2.0}}},
+	})
+	if err != nil {
+		t.Fatalf("AddShapes() failed: %v", err)
+	}
+	if err := sm.SetCamera(CameraInfo{Center: []float64{0, 5, 10}, LookAt: []float64{0, 1, 0}, VFov: 60, Aperture: 0.1}); err != nil {
+		t.Fatalf("SetCamera() failed: %v", err)
+	}
+	direction := vec3{0, 4, 10}.normalize()
+
+	// The sphere's own radius fits in half the 60 degree vfov, the narrower one at 4:3
+	if err := sm.FrameShape("ball", 0); err != nil {
+		t.Fatalf("FrameShape() failed: %v", err)
+	}
+	camera := sm.GetCamera()
+	expected := vec3{4, 1, 0}.add(direction.scale(0.5 / math.Sin(math.Pi/6)))
+	for i := range expected {
+		if math.Abs(camera.Center[i]-expected[i]) > 1e-9 {
+			t.Fatalf("Expected camera at %v, got %v", expected, camera.Center)
+		}
+	}
+	if camera.LookAt[0] != 4 || camera.LookAt[1] != 1 || camera.LookAt[2] != 0 {
+		t.Errorf("Expected camera to look at the ball, got %v", camera.LookAt)
+	}
+	if camera.VFov != 60 || camera.Aperture != 0.1 {
+		t.Errorf("Expected vfov and aperture unchanged, got %+v", camera)
+	}
+
+	// A margin backs the camera off, and the view direction carries over to the next shape
+	if err := sm.FrameShape("crate", 0.5); err != nil {
+		t.Fatalf("FrameShape() failed: %v", err)
+	}
+	camera = sm.GetCamera()
+	offset := vec3{camera.Center[0], camera.Center[1], camera.Center[2]}.sub(vec3{-3, 1, 0})
+	if want := 1.5 * math.Sqrt(3) / math.Sin(math.Pi/6); math.Abs(offset.length()-want) > 1e-9 {
+		t.Errorf("Expected camera %g from the crate, got %g", want, offset.length())
+	}
+	if offset.normalize().sub(direction).length() > 1e-9 {
+		t.Errorf("Expected the view direction kept, got %v", offset.normalize())
+	}
+
+	// Portrait images are limited by the horizontal field of view
+	if err := sm.SetAspectRatio(0.5); err != nil {
+		t.Fatalf("SetAspectRatio() failed: %v", err)
+	}
+	if err := sm.FrameShape("ball", 0); err != nil {
+		t.Fatalf("FrameShape() failed: %v", err)
+	}
+	camera = sm.GetCamera()
+	halfHFov := math.Atan(0.5 * math.Tan(math.Pi/6))
+	offset = vec3{camera.Center[0], camera.Center[1], camera.Center[2]}.sub(vec3{4, 1, 0})
+	if want := 0.5 / math.Sin(halfHFov); math.Abs(offset.length()-want) > 1e-9 {
+		t.Errorf("Expected camera %g from the ball, got %g", want, offset.length())
+	}
+
+	revision := sm.Revision()
+	if err := sm.FrameShape("missing", 0.1); err == nil {
+		t.Error("Expected an error for a missing shape")
+	}
+	if err := sm.FrameShape("ball", -1); err == nil {
+		t.Error("Expected an error for a negative margin")
+	}
+	if sm.Revision() != revision {
+		t.Error("Expected failed framing to leave the camera alone")
+	}
+}
+
+func TestSetCameraValidation(t *testing.T) {
+	sm := NewSceneManager()
+
+	tests := []struct {
+		name         string
+		camera       CameraInfo
+		expectError  bool
+		errorPattern string
+	}{
+		{
+			name: "valid camera",
+			camera: CameraInfo{
+				Center:   []float64{1, 2, 3},
+				LookAt:   []float64{0, 0, 0},
+				VFov:     45.0,
+				Aperture: 0.0,
+			},
+			expectError: false,
+		},
+		{
+			name: "invalid vfov - too low",
+			camera: CameraInfo{
+				Center:   []float64{1, 2, 3},
+				LookAt:   []float64{0, 0, 0},
+				VFov:     0.0,
+				Aperture: 0.0,
 			},
 			expectError:  true,
 			errorPattern: `vfov.*range`,
@@ -1764,6 +2789,122 @@ func TestSetCameraValidation(t *testing.T) {
 			},
 			expectError: false,
 		},
+		{
+			name: "fstop with aperture",
+			camera: CameraInfo{
+				Center:   []float64{1, 2, 3},
+				LookAt:   []float64{0, 0, 0},
+				VFov:     45.0,
+				Aperture: 0.2,
+				FStop:    2.8,
+			},
+			expectError:  true,
+			errorPattern: `mutually exclusive`,
+		},
+		{
+			name: "invalid fstop - negative",
+			camera: CameraInfo{
+				Center: []float64{1, 2, 3},
+				LookAt: []float64{0, 0, 0},
+				VFov:   45.0,
+				FStop:  -2,
+			},
+			expectError:  true,
+			errorPattern: `fstop must be positive`,
+		},
+		{
+			name: "look_direction with look_at",
+			camera: CameraInfo{
+				Center:        []float64{1, 2, 3},
+				LookAt:        []float64{0, 0, 0},
+				LookDirection: []float64{0, 0, -1},
+				VFov:          45.0,
+			},
+			expectError:  true,
+			errorPattern: `look_at and look_direction are mutually exclusive`,
+		},
+		{
+			name: "zero look_direction",
+			camera: CameraInfo{
+				Center:        []float64{1, 2, 3},
+				LookDirection: []float64{0, 0, 0},
+				VFov:          45.0,
+			},
+			expectError:  true,
+			errorPattern: `^camera look_direction must be non-zero$`,
+		},
+		{
+			name: "short look_direction",
+			camera: CameraInfo{
+				Center:        []float64{1, 2, 3},
+				LookDirection: []float64{0, -1},
+				VFov:          45.0,
+			},
+			expectError:  true,
+			errorPattern: `look_direction must have exactly 3 values`,
+		},
+		{
+			name: "NaN center",
+			camera: CameraInfo{
+				Center: []float64{1, math.NaN(), 3},
+				LookAt: []float64{0, 0, 0},
+				VFov:   45.0,
+			},
+			expectError:  true,
+			errorPattern: `camera center must be finite numbers`,
+		},
+		{
+			name: "infinite look_at",
+			camera: CameraInfo{
+				Center: []float64{1, 2, 3},
+				LookAt: []float64{0, 0, math.Inf(1)},
+				VFov:   45.0,
+			},
+			expectError:  true,
+			errorPattern: `camera look_at must be finite numbers`,
+		},
+		{
+			name: "infinite look_direction",
+			camera: CameraInfo{
+				Center:        []float64{1, 2, 3},
+				LookDirection: []float64{0, math.Inf(1), 0},
+				VFov:          45.0,
+			},
+			expectError:  true,
+			errorPattern: `camera look_direction must be finite numbers`,
+		},
+		{
+			name: "NaN vfov",
+			camera: CameraInfo{
+				Center: []float64{1, 2, 3},
+				LookAt: []float64{0, 0, 0},
+				VFov:   math.NaN(),
+			},
+			expectError:  true,
+			errorPattern: `vfov must be in range`,
+		},
+		{
+			name: "infinite aperture",
+			camera: CameraInfo{
+				Center:   []float64{1, 2, 3},
+				LookAt:   []float64{0, 0, 0},
+				VFov:     45.0,
+				Aperture: math.Inf(1),
+			},
+			expectError:  true,
+			errorPattern: `aperture must be in range`,
+		},
+		{
+			name: "NaN fstop",
+			camera: CameraInfo{
+				Center: []float64{1, 2, 3},
+				LookAt: []float64{0, 0, 0},
+				VFov:   45.0,
+				FStop:  math.NaN(),
+			},
+			expectError:  true,
+			errorPattern: `fstop must be positive`,
+		},
 	}
 
 	for _, tt := range tests {
@@ -1790,6 +2931,53 @@ func TestSetCameraValidation(t *testing.T) {
 	}
 }
 
+func TestSetCameraLookDirection(t *testing.T) {
+	sm := NewSceneManager()
+
+	req := parseSetCameraRequest(&llm.FunctionCall{Name: "set_camera", Arguments: map[string]interface{}{
+		"center":         []interface{}{1.0, 2.0, 3.0},
+		"look_direction": []interface{}{0.0, -1.0, -4.0},
+	}})
+	if err := sm.SetCamera(req.Camera); err != nil {
+		t.Fatalf("SetCamera() failed: %v", err)
+	}
+
+	// look_at is stored in place of the direction
+	camera := sm.GetCamera()
+	if !reflect.DeepEqual(camera.LookAt, []float64{1, 1, -1}) {
+		t.Errorf("Expected look_at = center + look_direction = [1 1 -1], got %v", camera.LookAt)
+	}
+	if camera.LookDirection != nil {
+		t.Errorf("Expected look_direction not to be stored, got %v", camera.LookDirection)
+	}
+}
+
+func TestFStopToAperture(t *testing.T) {
+	// A 45 degree vfov on a full-frame sensor is a ~29mm lens, so f/2 opens ~14.5mm wide
+	focalLength := 0.012 / math.Tan(22.5*math.Pi/180)
+	if got := fstopToAperture(2, 45); math.Abs(got-focalLength/2) > 1e-12 || math.Abs(got-0.01448) > 1e-4 {
+		t.Errorf("fstopToAperture(2, 45) = %v, want %v", got, focalLength/2)
+	}
+
+	// Doubling the f-number halves the aperture
+	if ratio := fstopToAperture(2, 45) / fstopToAperture(4, 45); math.Abs(ratio-2) > 1e-12 {
+		t.Errorf("Expected f/2 to be twice as wide as f/4, got ratio %v", ratio)
+	}
+
+	// Zooming in (narrower vfov) means a longer lens and a wider aperture at the same f-number
+	if fstopToAperture(2, 20) <= fstopToAperture(2, 45) {
+		t.Errorf("Expected a narrower vfov to give a wider aperture")
+	}
+
+	sm := NewSceneManager()
+	if err := sm.SetCamera(CameraInfo{Center: []float64{0, 0, 5}, LookAt: []float64{0, 0, 0}, VFov: 45, FStop: 2}); err != nil {
+		t.Fatalf("SetCamera() failed: %v", err)
+	}
+	if camera := sm.GetCamera(); camera.Aperture != fstopToAperture(2, 45) || camera.FStop != 2 {
+		t.Errorf("Expected SetCamera to store fstop 2 and its aperture, got %+v", camera)
+	}
+}
+
 func TestSetCameraMultipleErrors(t *testing.T) {
 	sm := NewSceneManager()
 
@@ -1822,7 +3010,41 @@ func TestSetCameraMultipleErrors(t *testing.T) {
 	if errMsg == "" {
 		t.Error("Error message should not be empty")
 	}
-	t.Logf("Error message: %s", errMsg)
+	t.Logf("Error message: %s", errMsg)
+}
+
+func TestNonFiniteShapeAndLightValues(t *testing.T) {
+	tests := []struct {
+		name     string
+		validate func() error
+		expected string
+	}{
+		{"NaN sphere center", func() error {
+			return validateShapeProperties(ShapeRequest{ID: "ball", Type: "sphere", Properties: map[string]interface{}{
+				"center": []interface{}{0.0, math.NaN(), 0.0}, "radius": 1.0}})
+		}, "sphere 'ball' center[1] must be a finite number"},
+		{"infinite sphere radius", func() error {
+			return validateShapeProperties(ShapeRequest{ID: "ball", Type: "sphere", Properties: map[string]interface{}{
+				"center": []interface{}{0.0, 0.0, 0.0}, "radius": math.Inf(1)}})
+		}, "sphere 'ball' radius must be a finite number"},
+		{"NaN cone top radius", func() error {
+			return validateShapeProperties(ShapeRequest{ID: "tree", Type: "cone", Properties: map[string]interface{}{
+				"base_center": []interface{}{0.0, 0.0, 0.0}, "top_center": []interface{}{0.0, 2.0, 0.0},
+				"base_radius": 1.0, "top_radius": math.NaN(), "capped": true}})
+		}, "cone 'tree' top_radius must be a finite number"},
+		{"infinite light position", func() error {
+			return validateLightProperties(LightRequest{ID: "bulb", Type: "point_spot_light", Properties: map[string]interface{}{
+				"center": []interface{}{math.Inf(1), 4.0, 0.0}, "emission": []interface{}{5.0, 5.0, 5.0}}})
+		}, "center[0] must be a finite number"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.validate()
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("Expected an error containing %q, got %v", tt.expected, err)
+			}
+		})
+	}
 }
 
 func TestValidateShapeMultipleErrors(t *testing.T) {
@@ -1891,3 +3113,604 @@ func TestValidateLightMultipleErrors(t *testing.T) {
 
 	t.Logf("Error message: %s", err.Error())
 }
+
+func TestRevisionIncrementsOnMutation(t *testing.T) {
+	sm := NewSceneManager()
+	if sm.Revision() != 0 {
+		t.Fatalf("Expected new scene to be at revision 0, got %d", sm.Revision())
+	}
+
+	sphere := ShapeRequest{
+		ID:   "sphere1",
+		Type: "sphere",
+		Properties: map[string]interface{}{
+			"center": []interface{}{0.0, 0.0, 0.0},
+			"radius": 1.0,
+		},
+	}
+	if err := sm.AddShapes([]ShapeRequest{sphere}); err != nil {
+		t.Fatalf("AddShapes() failed: %v", err)
+	}
+	afterAdd := sm.Revision()
+	if afterAdd <= 0 {
+		t.Errorf("Expected revision to increase after add, got %d", afterAdd)
+	}
+
+	// Failed mutations shouldn't bump the revision
+	if err := sm.RemoveShape("missing"); err == nil {
+		t.Fatal("Expected error removing missing shape")
+	}
+	if sm.Revision() != afterAdd {
+		t.Errorf("Expected revision %d after failed remove, got %d", afterAdd, sm.Revision())
+	}
+
+	if err := sm.SetCamera(CameraInfo{Center: []float64{0, 1, 5}, LookAt: []float64{0, 0, 0}, VFov: 45}); err != nil {
+		t.Fatalf("SetCamera() failed: %v", err)
+	}
+	if sm.Revision() <= afterAdd {
+		t.Errorf("Expected revision to increase after camera change, got %d", sm.Revision())
+	}
+}
+
+func TestChangesSince(t *testing.T) {
+	sm := NewSceneManager()
+
+	newSphere := func(id string) ShapeRequest {
+		return ShapeRequest{
+			ID:   id,
+			Type: "sphere",
+			Properties: map[string]interface{}{
+				"center": []interface{}{0.0, 0.0, 0.0},
+				"radius": 1.0,
+			},
+		}
+	}
+	light := LightRequest{
+		ID:   "light1",
+		Type: "point_spot_light",
+		Properties: map[string]interface{}{
+			"center":   []interface{}{0.0, 5.0, 0.0},
+			"emission": []interface{}{10.0, 10.0, 10.0},
+		},
+	}
+
+	if err := sm.AddShapes([]ShapeRequest{newSphere("keep"), newSphere("update_me"), newSphere("remove_me")}); err != nil {
+		t.Fatalf("AddShapes() failed: %v", err)
+	}
+	if err := sm.AddLights([]LightRequest{light}); err != nil {
+		t.Fatalf("AddLights() failed: %v", err)
+	}
+	base := sm.Revision()
+
+	// Nothing has changed yet
+	changes := sm.ChangesSince(base)
+	if len(changes.Shapes) != 0 || len(changes.Lights) != 0 || len(changes.RemovedShapes) != 0 || changes.Camera != nil {
+		t.Errorf("Expected no changes since current revision, got %+v", changes)
+	}
+
+	// Mutate after the base revision
+	if err := sm.UpdateShape("update_me", map[string]interface{}{
+		"properties": map[string]interface{}{"radius": 2.0},
+	}); err != nil {
+		t.Fatalf("UpdateShape() failed: %v", err)
+	}
+	if err := sm.RemoveShape("remove_me"); err != nil {
+		t.Fatalf("RemoveShape() failed: %v", err)
+	}
+	if err := sm.AddShapes([]ShapeRequest{newSphere("added")}); err != nil {
+		t.Fatalf("AddShapes() failed: %v", err)
+	}
+	if err := sm.RemoveLight("light1"); err != nil {
+		t.Fatalf("RemoveLight() failed: %v", err)
+	}
+
+	changes = sm.ChangesSince(base)
+	if changes.Since != base {
+		t.Errorf("Expected since %d, got %d", base, changes.Since)
+	}
+	if changes.Revision != sm.Revision() {
+		t.Errorf("Expected revision %d, got %d", sm.Revision(), changes.Revision)
+	}
+
+	changedIDs := []string{}
+	for _, shape := range changes.Shapes {
+		changedIDs = append(changedIDs, shape.ID)
+	}
+	if strings.Join(changedIDs, ",") != "update_me,added" {
+		t.Errorf("Expected changed shapes [update_me added], got %v", changedIDs)
+	}
+	if strings.Join(changes.RemovedShapes, ",") != "remove_me" {
+		t.Errorf("Expected removed shapes [remove_me], got %v", changes.RemovedShapes)
+	}
+	if strings.Join(changes.RemovedLights, ",") != "light1" {
+		t.Errorf("Expected removed lights [light1], got %v", changes.RemovedLights)
+	}
+	if changes.Camera != nil {
+		t.Error("Expected camera to be omitted when unchanged")
+	}
+
+	// Changes since revision 0 include everything currently in the scene
+	all := sm.ChangesSince(0)
+	if len(all.Shapes) != 3 {
+		t.Errorf("Expected 3 shapes since revision 0, got %d", len(all.Shapes))
+	}
+
+	// A removed shape that is re-added is reported as changed, not removed
+	if err := sm.AddShapes([]ShapeRequest{newSphere("remove_me")}); err != nil {
+		t.Fatalf("AddShapes() failed: %v", err)
+	}
+	changes = sm.ChangesSince(base)
+	if len(changes.RemovedShapes) != 0 {
+		t.Errorf("Expected re-added shape not to be reported as removed, got %v", changes.RemovedShapes)
+	}
+	if len(changes.Shapes) != 3 {
+		t.Errorf("Expected 3 changed shapes after re-adding, got %d", len(changes.Shapes))
+	}
+}
+
+func TestChangesSinceRenamedShape(t *testing.T) {
+	sm := NewSceneManager()
+
+	shape := ShapeRequest{
+		ID:   "old_name",
+		Type: "sphere",
+		Properties: map[string]interface{}{
+			"center": []interface{}{0.0, 0.0, 0.0},
+			"radius": 1.0,
+		},
+	}
+	if err := sm.AddShapes([]ShapeRequest{shape}); err != nil {
+		t.Fatalf("AddShapes() failed: %v", err)
+	}
+	base := sm.Revision()
+
+	if err := sm.UpdateShape("old_name", map[string]interface{}{"id": "new_name"}); err != nil {
+		t.Fatalf("UpdateShape() failed: %v", err)
+	}
+
+	changes := sm.ChangesSince(base)
+	if len(changes.Shapes) != 1 || changes.Shapes[0].ID != "new_name" {
+		t.Errorf("Expected new_name to be reported as changed, got %+v", changes.Shapes)
+	}
+	if strings.Join(changes.RemovedShapes, ",") != "old_name" {
+		t.Errorf("Expected old_name to be reported as removed, got %v", changes.RemovedShapes)
+	}
+}
+
+func TestSnapshotAndReplaceState(t *testing.T) {
+	source := NewSceneManager()
+	shape := ShapeRequest{
+		ID:   "ball",
+		Type: "sphere",
+		Properties: map[string]interface{}{
+			"center":   []interface{}{0.0, 1.0, 0.0},
+			"radius":   1.0,
+			"material": map[string]interface{}{"type": "lambertian", "albedo": []interface{}{0.8, 0.1, 0.1}},
+		},
+	}
+	if err := source.AddShapes([]ShapeRequest{shape}); err != nil {
+		t.Fatalf("AddShapes() failed: %v", err)
+	}
+	snapshot := source.Snapshot()
+
+	// Editing the source in place must not reach the snapshot
+	source.state.Shapes[0].Properties["material"].(map[string]interface{})["albedo"].([]interface{})[0] = 0.0
+	source.state.Camera.Center[0] = 9
+	albedo := snapshot.Shapes[0].Properties["material"].(map[string]interface{})["albedo"].([]interface{})
+	if albedo[0] != 0.8 || snapshot.Camera.Center[0] != 0 {
+		t.Errorf("Expected snapshot to be unaffected by edits to the source, got albedo %v and camera %v", albedo, snapshot.Camera.Center)
+	}
+
+	dest := NewSceneManager()
+	if err := dest.AddShapes([]ShapeRequest{{ID: "old", Type: "sphere", Properties: map[string]interface{}{
+		"center": []interface{}{0.0, 0.0, 0.0},
+		"radius": 1.0,
+	}}}); err != nil {
+		t.Fatalf("AddShapes() failed: %v", err)
+	}
+	base := dest.Revision()
+	dest.ReplaceState(snapshot)
+
+	if dest.FindShape("ball") == nil || dest.FindShape("old") != nil {
+		t.Errorf("Expected the scene to be replaced, got shapes %+v", dest.state.Shapes)
+	}
+
+	// The replaced scene shares nothing with the snapshot either
+	snapshot.Shapes[0].Properties["radius"] = 5.0
+	if radius := dest.FindShape("ball").Properties["radius"]; radius != 1.0 {
+		t.Errorf("Expected replaced scene to own its state, got radius %v", radius)
+	}
+
+	changes := dest.ChangesSince(base)
+	if len(changes.Shapes) != 1 || strings.Join(changes.RemovedShapes, ",") != "old" || changes.Camera == nil {
+		t.Errorf("Expected ball changed, old removed and camera changed, got %+v", changes)
+	}
+}
+
+func TestShapeSizeAlias(t *testing.T) {
+	sm := NewSceneManager()
+	shapes := []ShapeRequest{
+		{ID: "cube", Type: "box", Properties: map[string]interface{}{
+			"center": []interface{}{3.0, 0.0, 0.0},
+			"size":   []interface{}{1.0, 2.0, 3.0},
+		}},
+		{ID: "ball", Type: "sphere", Properties: map[string]interface{}{
+			"center": []interface{}{-3.0, 0.0, 0.0},
+			"size":   0.5,
+		}},
+	}
+	if err := sm.AddShapes(shapes); err != nil {
+		t.Fatalf("AddShapes() failed: %v", err)
+	}
+
+	// Stored under the canonical keys
+	cube := sm.FindShape("cube")
+	if _, ok := cube.Properties["size"]; ok {
+		t.Errorf("Expected size to be renamed, got %v", cube.Properties)
+	}
+	if dims, ok := extractFloatArray(cube.Properties, "dimensions", 3); !ok || dims[0] != 1 || dims[1] != 2 || dims[2] != 3 {
+		t.Errorf("Expected dimensions [1 2 3], got %v", cube.Properties["dimensions"])
+	}
+	if radius, ok := extractFloat(sm.FindShape("ball").Properties, "radius"); !ok || radius != 0.5 {
+		t.Errorf("Expected radius 0.5, got %v", sm.FindShape("ball").Properties)
+	}
+
+	// The caller's properties are left alone
+	if _, ok := shapes[0].Properties["size"]; !ok {
+		t.Error("Expected AddShapes not to modify the caller's properties")
+	}
+
+	// The geometry uses the given size rather than a default
+	min, max, ok := sm.SceneBounds()
+	if !ok {
+		t.Fatal("Expected scene bounds")
+	}
+	expectedMin, expectedMax := []float64{-3.5, -1.0, -1.5}, []float64{3.5, 1.0, 1.5}
+	for i := range expectedMin {
+		if math.Abs(min[i]-expectedMin[i]) > 1e-9 || math.Abs(max[i]-expectedMax[i]) > 1e-9 {
+			t.Fatalf("Expected bounds %v to %v, got %v to %v", expectedMin, expectedMax, min, max)
+		}
+	}
+	if raytracerScene, err := sm.ToRaytracerScene(); err != nil || len(raytracerScene.Shapes) != 2 {
+		t.Errorf("Expected both shapes to convert, got err %v", err)
+	}
+
+	// Updates accept the alias too
+	if err := sm.UpdateShape("cube", map[string]interface{}{"properties": map[string]interface{}{"size": []interface{}{2.0, 2.0, 2.0}}}); err != nil {
+		t.Fatalf("UpdateShape() failed: %v", err)
+	}
+	if dims, _ := extractFloatArray(sm.FindShape("cube").Properties, "dimensions", 3); dims[0] != 2 {
+		t.Errorf("Expected update to change dimensions, got %v", sm.FindShape("cube").Properties)
+	}
+
+	// Setting both is ambiguous
+	both := ShapeRequest{ID: "both", Type: "box", Properties: map[string]interface{}{
+		"center":     []interface{}{0.0, 0.0, 0.0},
+		"dimensions": []interface{}{1.0, 1.0, 1.0},
+		"size":       []interface{}{2.0, 2.0, 2.0},
+	}}
+	if errors := sm.ValidateShape(both); !strings.Contains(strings.Join(errors, "; "), "alias 'size'") {
+		t.Errorf("Expected a conflict error, got %v", errors)
+	}
+}
+
+func TestDifferenceShapeValidation(t *testing.T) {
+	sphere := func(radius interface{}) map[string]interface{} {
+		return map[string]interface{}{"type": "sphere", "properties": map[string]interface{}{
+			"center": []interface{}{0.0, 0.0, 0.0},
+			"radius": radius,
+		}}
+	}
+	difference := func(base, subtract interface{}) ShapeRequest {
+		props := map[string]interface{}{}
+		if base != nil {
+			props["base"] = base
+		}
+		if subtract != nil {
+			props["subtract"] = subtract
+		}
+		return ShapeRequest{ID: "bowl", Type: "difference", Properties: props}
+	}
+
+	tests := []struct {
+		name    string
+		shape   ShapeRequest
+		errors  []string // Substrings expected among the errors
+		nErrors int
+	}{
+		{
+			name:    "valid operands are still unsupported",
+			shape:   difference(sphere(1.0), sphere(0.9)),
+			errors:  []string{"difference 'bowl' is not supported yet"},
+			nErrors: 1,
+		},
+		{
+			name:    "invalid operand is reported by its path",
+			shape:   difference(sphere(1.0), sphere(-1.0)),
+			errors:  []string{"bowl.subtract", "not supported yet"},
+			nErrors: 2,
+		},
+		{
+			name:    "missing operand",
+			shape:   difference(sphere(1.0), nil),
+			errors:  []string{"requires 'subtract' to be a shape object", "not supported yet"},
+			nErrors: 2,
+		},
+		{
+			name: "operands accept aliases",
+			shape: difference(map[string]interface{}{"type": "box", "properties": map[string]interface{}{
+				"center": []interface{}{0.0, 0.0, 0.0},
+				"size":   []interface{}{1.0, 1.0, 1.0},
+			}}, sphere(0.5)),
+			errors:  []string{"not supported yet"},
+			nErrors: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errors := NewSceneManager().ValidateShape(tt.shape)
+			joined := strings.Join(errors, "; ")
+			for _, expected := range tt.errors {
+				if !strings.Contains(joined, expected) {
+					t.Errorf("Expected an error containing %q, got %v", expected, errors)
+				}
+			}
+			if len(errors) != tt.nErrors {
+				t.Errorf("Expected %d errors, got %v", tt.nErrors, errors)
+			}
+		})
+	}
+
+	if err := NewSceneManager().AddShapes([]ShapeRequest{difference(sphere(1.0), sphere(0.9))}); err == nil {
+		t.Error("Expected AddShapes to reject difference shapes")
+	}
+}
+
+func TestSceneStatistics(t *testing.T) {
+	sm := NewSceneManager()
+
+	// An empty scene has zero counts and no bounds
+	empty := sm.Statistics()
+	if empty["shape_count"] != 0 || empty["estimated_triangles"] != 0 {
+		t.Errorf("Expected zero counts for an empty scene, got %v", empty)
+	}
+	if _, hasBounds := empty["bounds"]; hasBounds {
+		t.Errorf("Expected no bounds for an empty scene, got %v", empty["bounds"])
+	}
+
+	shapes := []ShapeRequest{
+		{ID: "floor", Type: "quad", Properties: map[string]interface{}{
+			"corner": []interface{}{-5.0, 0.0, -5.0}, "u": []interface{}{10.0, 0.0, 0.0}, "v": []interface{}{0.0, 0.0, 10.0},
+		}},
+		{ID: "crate", Type: "box", Properties: map[string]interface{}{
+			"center": []interface{}{0.0, 1.0, 0.0}, "dimensions": []interface{}{2.0, 2.0, 2.0},
+			"material": map[string]interface{}{"type": "metal", "albedo": []interface{}{0.8, 0.8, 0.8}, "fuzz": 0.1},
+		}},
+		{ID: "ball", Type: "sphere", Properties: map[string]interface{}{
+			"center": []interface{}{3.0, 1.0, 0.0}, "radius": 1.0,
+			"material": map[string]interface{}{"type": "metal", "albedo": []interface{}{0.9, 0.6, 0.2}, "fuzz": 0.0},
+		}},
+	}
+	if err := sm.AddShapes(shapes); err != nil {
+		t.Fatalf("AddShapes() failed: %v", err)
+	}
+	light := LightRequest{ID: "lamp", Type: "point_spot_light", Properties: map[string]interface{}{
+		"center": []interface{}{0.0, 5.0, 0.0}, "emission": []interface{}{10.0, 10.0, 10.0},
+	}}
+	if err := sm.AddLights([]LightRequest{light}); err != nil {
+		t.Fatalf("AddLights() failed: %v", err)
+	}
+
+	stats := sm.Statistics()
+	if stats["shape_count"] != 3 || stats["light_count"] != 1 {
+		t.Errorf("Expected 3 shapes and 1 light, got %v", stats)
+	}
+	if got := stats["shapes_by_type"].(map[string]int); got["quad"] != 1 || got["box"] != 1 || got["sphere"] != 1 {
+		t.Errorf("Unexpected shapes_by_type: %v", got)
+	}
+	if got := stats["materials_by_type"].(map[string]int); got["lambertian"] != 1 || got["metal"] != 2 {
+		t.Errorf("Expected the unset floor material to count as lambertian, got %v", got)
+	}
+	if got := stats["lights_by_type"].(map[string]int); got["point_spot_light"] != 1 {
+		t.Errorf("Unexpected lights_by_type: %v", got)
+	}
+	// 2 for the quad and 12 for the box; the sphere isn't tessellated
+	if stats["estimated_triangles"] != 14 {
+		t.Errorf("Expected 14 estimated triangles, got %v", stats["estimated_triangles"])
+	}
+
+	bounds, ok := stats["bounds"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected bounds, got %v", stats["bounds"])
+	}
+	wantMin, wantMax := []float64{-5, 0, -5}, []float64{5, 2, 5}
+	for i := range wantMin {
+		if bounds["min"].([]float64)[i] != wantMin[i] || bounds["max"].([]float64)[i] != wantMax[i] {
+			t.Errorf("Expected bounds %v to %v, got %v to %v", wantMin, wantMax, bounds["min"], bounds["max"])
+			break
+		}
+	}
+}
+
+func TestEllipsoidShape(t *testing.T) {
+	egg := func(radii []interface{}) ShapeRequest {
+		return ShapeRequest{ID: "egg", Type: "ellipsoid", Properties: map[string]interface{}{
+			"center": []interface{}{0.0, 1.0, 0.0},
+			"radii":  radii,
+		}}
+	}
+
+	t.Run("validation", func(t *testing.T) {
+		sm := NewSceneManager()
+		if errors := sm.ValidateShape(egg([]interface{}{0.6, 0.8, 0.6})); len(errors) > 0 {
+			t.Errorf("Expected a valid ellipsoid, got %v", errors)
+		}
+
+		errors := sm.ValidateShape(egg([]interface{}{0.6, 0.0, -1.0}))
+		joined := strings.Join(errors, "; ")
+		if !strings.Contains(joined, "radii[1] must be positive") || !strings.Contains(joined, "radii[2] must be positive") {
+			t.Errorf("Expected zero and negative radii to be rejected, got %v", errors)
+		}
+
+		missing := egg(nil)
+		delete(missing.Properties, "radii")
+		if errors := sm.ValidateShape(missing); !strings.Contains(strings.Join(errors, "; "), "requires 'radii'") {
+			t.Errorf("Expected missing radii to be rejected, got %v", errors)
+		}
+	})
+
+	t.Run("conversion", func(t *testing.T) {
+		sm := NewSceneManager()
+		if err := sm.AddShapes([]ShapeRequest{egg([]interface{}{0.6, 0.8, 0.6})}); err != nil {
+			t.Fatalf("AddShapes() failed: %v", err)
+		}
+
+		raytracerScene, err := sm.ToRaytracerScene()
+		if err != nil {
+			t.Fatalf("ToRaytracerScene() failed: %v", err)
+		}
+		if len(raytracerScene.Shapes) != 1 {
+			t.Errorf("Expected the ellipsoid to become one raytracer shape, got %d", len(raytracerScene.Shapes))
+		}
+
+		// The largest radius stands in for its size
+		if context := sm.BuildContext(); !strings.Contains(context, "ellipsoid (ID: egg) at [0.0,1.0,0.0] size 0.8") {
+			t.Errorf("Expected the context to report the largest radius, got %q", context)
+		}
+
+		min, max, ok := sm.SceneBounds()
+		if !ok || math.Abs(min[1]-0.2) > 1e-9 || math.Abs(max[1]-1.8) > 1e-9 || math.Abs(max[0]-0.6) > 1e-9 {
+			t.Errorf("Expected bounds from the radii, got %v to %v", min, max)
+		}
+	})
+}
+
+func TestPyramidShape(t *testing.T) {
+	roof := func(baseSize []interface{}, height interface{}) ShapeRequest {
+		return ShapeRequest{ID: "roof", Type: "pyramid", Properties: map[string]interface{}{
+			"center":    []interface{}{0.0, 2.0, 0.0},
+			"base_size": baseSize,
+			"height":    height,
+		}}
+	}
+
+	t.Run("validation", func(t *testing.T) {
+		tests := []struct {
+			name       string
+			shape      ShapeRequest
+			errorMatch string // Empty if the shape is valid
+		}{
+			{"valid", roof([]interface{}{4.0, 3.0}, 1.5), ""},
+			{"zero width", roof([]interface{}{0.0, 3.0}, 1.5), "base_size values must be positive"},
+			{"three base values", roof([]interface{}{4.0, 3.0, 2.0}, 1.5), "requires 'base_size' property [width, depth]"},
+			{"negative height", roof([]interface{}{4.0, 3.0}, -1.0), "height must be positive"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				errors := NewSceneManager().ValidateShape(tt.shape)
+				if tt.errorMatch == "" {
+					if len(errors) > 0 {
+						t.Errorf("Expected no errors, got %v", errors)
+					}
+					return
+				}
+				if !strings.Contains(strings.Join(errors, "; "), tt.errorMatch) {
+					t.Errorf("Expected error containing %q, got %v", tt.errorMatch, errors)
+				}
+			})
+		}
+	})
+
+	t.Run("conversion", func(t *testing.T) {
+		sm := NewSceneManager()
+		if err := sm.AddShapes([]ShapeRequest{roof([]interface{}{4.0, 3.0}, 1.5)}); err != nil {
+			t.Fatalf("AddShapes() failed: %v", err)
+		}
+
+		raytracerScene, err := sm.ToRaytracerScene()
+		if err != nil {
+			t.Fatalf("ToRaytracerScene() failed: %v", err)
+		}
+		if len(raytracerScene.Shapes) != 1 {
+			t.Errorf("Expected the pyramid to become one raytracer shape, got %d", len(raytracerScene.Shapes))
+		}
+
+		if context := sm.BuildContext(); !strings.Contains(context, "pyramid (ID: roof) at [0.0,2.0,0.0] size 4.0") {
+			t.Errorf("Expected the context to report the largest dimension, got %q", context)
+		}
+
+		min, max, ok := sm.SceneBounds()
+		want := [2][]float64{{-2, 2, -1.5}, {2, 3.5, 1.5}}
+		if !ok || fmt.Sprint(min, max) != fmt.Sprint(want[0], want[1]) {
+			t.Errorf("Expected bounds %v to %v, got %v to %v", want[0], want[1], min, max)
+		}
+		if got := sm.Statistics()["estimated_triangles"]; got != pyramidTriangles {
+			t.Errorf("Expected %d estimated triangles, got %v", pyramidTriangles, got)
+		}
+	})
+}
+
+func TestParseAspectRatio(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64 // 0 if the input is invalid
+	}{
+		{"16:9", 16.0 / 9},
+		{"1:1", 1},
+		{" 4 : 3 ", 4.0 / 3},
+		{"2.39", 2.39},
+		{"0:1", 0},
+		{"16:0", 0},
+		{"-4:3", 0},
+		{"wide", 0},
+		{"16:nine", 0},
+		{"", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := parseAspectRatio(tt.input)
+			if tt.expected == 0 {
+				if err == nil {
+					t.Errorf("Expected an error, got %v", got)
+				}
+				return
+			}
+			if err != nil || math.Abs(got-tt.expected) > 1e-12 {
+				t.Errorf("parseAspectRatio(%q) = %v, %v; expected %v", tt.input, got, err, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSetAspectRatio(t *testing.T) {
+	sm := NewSceneManager()
+	if aspect := sm.GetCamera().aspectRatio(); aspect != 4.0/3 {
+		t.Errorf("Expected the default aspect ratio to be 4:3, got %v", aspect)
+	}
+
+	if err := sm.SetAspectRatio(16.0 / 9); err != nil {
+		t.Fatalf("SetAspectRatio() failed: %v", err)
+	}
+	raytracerScene, err := sm.ToRaytracerScene()
+	if err != nil {
+		t.Fatalf("ToRaytracerScene() failed: %v", err)
+	}
+	if aspect := float64(raytracerScene.SamplingConfig.Width) / float64(raytracerScene.SamplingConfig.Height); math.Abs(aspect-16.0/9) > 0.01 {
+		t.Errorf("Expected a 16:9 raytracer scene, got %dx%d", raytracerScene.SamplingConfig.Width, raytracerScene.SamplingConfig.Height)
+	}
+
+	// set_camera doesn't expose the aspect ratio, so it keeps the current one
+	if err := sm.SetCamera(CameraInfo{Center: []float64{0, 0, 5}, LookAt: []float64{0, 0, 0}, VFov: 45}); err != nil {
+		t.Fatalf("SetCamera() failed: %v", err)
+	}
+	if aspect := sm.GetCamera().AspectRatio; aspect != 16.0/9 {
+		t.Errorf("Expected SetCamera to keep the 16:9 aspect ratio, got %v", aspect)
+	}
+
+	for _, bad := range []float64{0, -1, math.Inf(1), math.NaN()} {
+		if err := sm.SetAspectRatio(bad); err == nil {
+			t.Errorf("Expected SetAspectRatio(%v) to fail", bad)
+		}
+	}
+}
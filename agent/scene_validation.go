@@ -2,6 +2,7 @@ package agent
 
 import (
 	"fmt"
+	"math"
 	"strings"
 )
 
@@ -18,6 +19,45 @@ func (ve ValidationErrors) Error() string {
 	return fmt.Sprintf("%d validation errors: %s", len(ve), strings.Join(ve, "; "))
 }
 
+// validationErrorList flattens a validation error into individual messages (empty if err is nil)
+func validationErrorList(err error) []string {
+	if err == nil {
+		return []string{}
+	}
+	if validationErrs, ok := err.(ValidationErrors); ok {
+		return append([]string{}, validationErrs...)
+	}
+	return []string{err.Error()}
+}
+
+// zeroThicknessShapeTypes are shapes with no interior, which a dielectric can't refract through
+var zeroThicknessShapeTypes = map[string]bool{"quad": true, "disc": true}
+
+// shapeWarnings describes likely modeling mistakes in a shape that is otherwise valid
+// Unlike validation errors, warnings don't stop the shape from being added: a glass quad
+// renders, it just looks like a flat mirror-ish sheet rather than glass.
+func shapeWarnings(shape ShapeRequest) []string {
+	warnings := []string{}
+	mat, _ := shape.Properties["material"].(map[string]interface{})
+	if zeroThicknessShapeTypes[shape.Type] && materialUsesDielectric(mat) {
+		warnings = append(warnings, fmt.Sprintf("shape '%s' is a %s with a dielectric material, but a %s has no thickness to refract through, so it won't look like glass; use a thin box for a pane or a sphere for a lens", shape.ID, shape.Type, shape.Type))
+	}
+	return warnings
+}
+
+// materialUsesDielectric reports whether a material is dielectric or mixes one in
+func materialUsesDielectric(mat map[string]interface{}) bool {
+	switch mat["type"] {
+	case "dielectric":
+		return true
+	case "mix":
+		a, _ := mat["material_a"].(map[string]interface{})
+		b, _ := mat["material_b"].(map[string]interface{})
+		return materialUsesDielectric(a) || materialUsesDielectric(b)
+	}
+	return false
+}
+
 // validateShapeProperties validates that a shape has the required properties for its type
 func validateShapeProperties(shape ShapeRequest) error {
 	var errors ValidationErrors
@@ -36,6 +76,27 @@ func validateShapeProperties(shape ShapeRequest) error {
 	case "sphere":
 		validateVec3PropertyRequired(&errors, shape.Properties, "center", nil, nil, "sphere", shape.ID)
 		validatePositiveFloatRequired(&errors, shape.Properties, "radius", "sphere", shape.ID)
+		validateVec3PropertyOptional(&errors, shape.Properties, "rotation", nil, nil, "sphere", shape.ID)
+
+	case "ellipsoid":
+		validateVec3PropertyRequired(&errors, shape.Properties, "center", nil, nil, "ellipsoid", shape.ID)
+		validateVec3PropertyRequired(&errors, shape.Properties, "radii", nil, nil, "ellipsoid", shape.ID)
+		if radii, ok := extractFloatArray(shape.Properties, "radii", 3); ok {
+			for i, r := range radii {
+				if r <= 0 {
+					errors = append(errors, fmt.Sprintf("ellipsoid '%s' radii[%d] must be positive", shape.ID, i))
+				}
+			}
+		}
+
+	case "pyramid":
+		validateVec3PropertyRequired(&errors, shape.Properties, "center", nil, nil, "pyramid", shape.ID)
+		if baseSize, ok := extractFloatArray(shape.Properties, "base_size", 2); !ok {
+			errors = append(errors, fmt.Sprintf("pyramid '%s' requires 'base_size' property [width, depth]", shape.ID))
+		} else if baseSize[0] <= 0 || baseSize[1] <= 0 {
+			errors = append(errors, fmt.Sprintf("pyramid '%s' base_size values must be positive", shape.ID))
+		}
+		validatePositiveFloatRequired(&errors, shape.Properties, "height", "pyramid", shape.ID)
 
 	case "box":
 		validateVec3PropertyRequired(&errors, shape.Properties, "center", nil, nil, "box", shape.ID)
@@ -45,6 +106,7 @@ func validateShapeProperties(shape ShapeRequest) error {
 		validateVec3PropertyRequired(&errors, shape.Properties, "corner", nil, nil, "quad", shape.ID)
 		validateVec3PropertyRequired(&errors, shape.Properties, "u", nil, nil, "quad", shape.ID)
 		validateVec3PropertyRequired(&errors, shape.Properties, "v", nil, nil, "quad", shape.ID)
+		validateQuadEdges(&errors, shape.Properties, "quad", shape.ID)
 
 	case "disc":
 		validateVec3PropertyRequired(&errors, shape.Properties, "center", nil, nil, "disc", shape.ID)
@@ -73,18 +135,49 @@ func validateShapeProperties(shape ShapeRequest) error {
 			}
 		}
 
+	case "difference":
+		// Validate the operands so every mistake is reported, then reject the shape itself
+		for _, key := range []string{"base", "subtract"} {
+			operand, ok := differenceOperand(shape, key)
+			if !ok {
+				errors = append(errors, fmt.Sprintf("difference '%s' requires '%s' to be a shape object {type, properties}", shape.ID, key))
+				continue
+			}
+			if err := validateShapeProperties(operand); err != nil {
+				errors = append(errors, validationErrorList(err)...)
+			}
+		}
+		errors = append(errors, fmt.Sprintf("difference '%s' is not supported yet: the raytracer has no CSG, so shapes can't be subtracted. For a pipe use an uncapped cylinder; for a cup, an uncapped cylinder with a disc for the bottom", shape.ID))
+
 	case "":
 		// Already handled above
 	default:
 		errors = append(errors, fmt.Sprintf("unsupported shape type '%s' for shape '%s'", shape.Type, shape.ID))
 	}
 
+	// Aliases are renamed before validation, so one still present conflicts with its canonical property
+	for alias, canonical := range shapePropertyAliases[shape.Type] {
+		if _, ok := shape.Properties[alias]; ok {
+			errors = append(errors, fmt.Sprintf("%s '%s' sets both '%s' and its alias '%s' - use only '%s'", shape.Type, shape.ID, canonical, alias, canonical))
+		}
+	}
+
 	// Validate color if present (optional property)
 	validateVec3PropertyOptional(&errors, shape.Properties, "color", &zero, &one, "shape", shape.ID)
 
+	// Validate opacity if present (optional property, 1 = fully opaque)
+	validateFloatPropertyOptional(&errors, shape.Properties, "opacity", &zero, &one, "shape", shape.ID, "")
+
+	// Annotations for the user and model; they don't affect rendering
+	validateStringArrayPropertyOptional(&errors, shape.Properties, "tags", "shape", shape.ID)
+	validateStringPropertyOptional(&errors, shape.Properties, "description", "shape", shape.ID)
+
 	// Validate material if present (optional property)
 	if mat, ok := extractMaterial(shape.Properties); ok {
 		validateMaterial(&errors, mat, shape.ID)
+		if mat["type"] == shadowCatcherMaterial && shape.Type != "quad" {
+			errors = append(errors, fmt.Sprintf("shape '%s' is a %s, but the shadow_catcher material only applies to quads", shape.ID, shape.Type))
+		}
 	}
 
 	if len(errors) > 0 {
@@ -93,6 +186,18 @@ func validateShapeProperties(shape ShapeRequest) error {
 	return nil
 }
 
+// differenceOperand returns the base or subtract operand of a difference shape as a shape of
+// its own, with an ID like "cup.base" so errors point at it
+func differenceOperand(shape ShapeRequest, key string) (ShapeRequest, bool) {
+	definition, ok := shape.Properties[key].(map[string]interface{})
+	if !ok {
+		return ShapeRequest{}, false
+	}
+	shapeType, _ := definition["type"].(string)
+	properties, _ := definition["properties"].(map[string]interface{})
+	return normalizeShapeAliases(ShapeRequest{ID: shape.ID + "." + key, Type: shapeType, Properties: properties}), true
+}
+
 // validateLightProperties validates a light's structure and properties
 func validateLightProperties(light LightRequest) error {
 	var errors ValidationErrors
@@ -107,20 +212,52 @@ func validateLightProperties(light LightRequest) error {
 		return errors // can't validate further without Properties
 	}
 
+	// Any light can be switched off without removing it
+	validateBoolPropertyOptional(&errors, light.Properties, "enabled", light.Type, light.ID)
+	validatePowerMode(&errors, light)
+
 	// Validate type-specific properties
 	switch light.Type {
+	case "point_light":
+		validateVec3PropertyRequired(&errors, light.Properties, "center", nil, nil, "point_light", light.ID)
+		validateVec3PropertyRequired(&errors, light.Properties, "emission", &zero, nil, "point_light", light.ID)
+		for _, key := range []string{"direction", "cutoff_angle", "falloff_exponent"} {
+			if hasProperty(light.Properties, key) {
+				errors = append(errors, fmt.Sprintf("point_light '%s' shines in every direction and doesn't take '%s' - use point_spot_light for a cone", light.ID, key))
+			}
+		}
+
 	case "point_spot_light":
 		validateVec3PropertyRequired(&errors, light.Properties, "center", nil, nil, "point_spot_light", light.ID)
 		validateVec3PropertyRequired(&errors, light.Properties, "emission", &zero, nil, "point_spot_light", light.ID)
 		validateVec3PropertyOptional(&errors, light.Properties, "direction", nil, nil, "point_spot_light", light.ID)
-		validateFloatPropertyOptional(&errors, light.Properties, "cutoff_angle", &zero, &maxAngle, "point_spot_light", light.ID, "cutoff_angle must be between 0 and 180 degrees")
-		validateFloatPropertyOptional(&errors, light.Properties, "falloff_exponent", &zero, nil, "point_spot_light", light.ID, "")
+		if !validateSpotPenumbra(&errors, light) {
+			validateFloatPropertyOptional(&errors, light.Properties, "cutoff_angle", &zero, &maxAngle, "point_spot_light", light.ID, "cutoff_angle must be between 0 and 180 degrees")
+			validateFloatPropertyOptional(&errors, light.Properties, "falloff_exponent", &zero, nil, "point_spot_light", light.ID, "")
+		}
 
 	case "area_quad_light":
 		validateVec3PropertyRequired(&errors, light.Properties, "corner", nil, nil, "area_quad_light", light.ID)
 		validateVec3PropertyRequired(&errors, light.Properties, "u", nil, nil, "area_quad_light", light.ID)
 		validateVec3PropertyRequired(&errors, light.Properties, "v", nil, nil, "area_quad_light", light.ID)
+		validateQuadEdges(&errors, light.Properties, "area_quad_light", light.ID)
 		validateVec3PropertyRequired(&errors, light.Properties, "emission", &zero, nil, "area_quad_light", light.ID)
+		validateBoolPropertyOptional(&errors, light.Properties, "two_sided", "area_quad_light", light.ID)
+
+	case "area_rect_light":
+		// Required: center, size, normal, emission
+		validateVec3PropertyRequired(&errors, light.Properties, "center", nil, nil, "area_rect_light", light.ID)
+		if size, ok := extractFloatArray(light.Properties, "size", 2); !ok {
+			errors = append(errors, fmt.Sprintf("area_rect_light '%s' requires 'size' property [width, height]", light.ID))
+		} else if size[0] <= 0 || size[1] <= 0 {
+			errors = append(errors, fmt.Sprintf("area_rect_light '%s' size values must be positive", light.ID))
+		}
+		validateVec3PropertyRequired(&errors, light.Properties, "normal", nil, nil, "area_rect_light", light.ID)
+		if normal, ok := extractFloatArray(light.Properties, "normal", 3); ok && vec3(normal).length() < degenerateEpsilon {
+			errors = append(errors, fmt.Sprintf("area_rect_light '%s' normal must be non-zero", light.ID))
+		}
+		validateVec3PropertyRequired(&errors, light.Properties, "emission", &zero, nil, "area_rect_light", light.ID)
+		validateBoolPropertyOptional(&errors, light.Properties, "two_sided", "area_rect_light", light.ID)
 
 	case "disc_spot_light":
 		// Required: center, normal, radius, emission
@@ -136,13 +273,15 @@ func validateLightProperties(light LightRequest) error {
 		validateVec3PropertyRequired(&errors, light.Properties, "emission", &zero, nil, "area_sphere_light", light.ID)
 
 	case "area_disc_spot_light":
-		// Required: center, normal, radius, emission, cutoff_angle, falloff_exponent
+		// Required: center, normal, radius, emission, and cutoff_angle with falloff_exponent or inner_angle with outer_angle
 		validateVec3PropertyRequired(&errors, light.Properties, "center", nil, nil, "area_disc_spot_light", light.ID)
 		validateVec3PropertyRequired(&errors, light.Properties, "normal", nil, nil, "area_disc_spot_light", light.ID)
 		validatePositiveFloatRequired(&errors, light.Properties, "radius", "area_disc_spot_light", light.ID)
 		validateVec3PropertyRequired(&errors, light.Properties, "emission", &zero, nil, "area_disc_spot_light", light.ID)
-		validateFloatPropertyRequired(&errors, light.Properties, "cutoff_angle", &zero, &maxAngle, "area_disc_spot_light", light.ID, "cutoff_angle must be between 0 and 180 degrees")
-		validateFloatPropertyRequired(&errors, light.Properties, "falloff_exponent", &zero, nil, "area_disc_spot_light", light.ID, "")
+		if !validateSpotPenumbra(&errors, light) {
+			validateFloatPropertyRequired(&errors, light.Properties, "cutoff_angle", &zero, &maxAngle, "area_disc_spot_light", light.ID, "cutoff_angle must be between 0 and 180 degrees")
+			validateFloatPropertyRequired(&errors, light.Properties, "falloff_exponent", &zero, nil, "area_disc_spot_light", light.ID, "")
+		}
 
 	case "":
 		// Already handled above
@@ -156,8 +295,22 @@ func validateLightProperties(light LightRequest) error {
 	return nil
 }
 
+// maxMaterialNestingDepth limits how deeply mix materials may be nested
+const maxMaterialNestingDepth = 3
+
 // validateMaterial validates material properties
 func validateMaterial(errors *ValidationErrors, mat map[string]interface{}, shapeID string) {
+	validateMaterialAtDepth(errors, mat, shapeID, 0)
+}
+
+// validateMaterialAtDepth validates a material that is nested depth levels inside mix materials
+func validateMaterialAtDepth(errors *ValidationErrors, mat map[string]interface{}, shapeID string, depth int) {
+	// Known presets have already been expanded, so any preset left is unknown
+	if preset, ok := mat["preset"]; ok {
+		*errors = append(*errors, fmt.Sprintf("shape '%s' has unknown material preset '%v' (supported: gold, copper, chrome, glass, plastic)", shapeID, preset))
+		return
+	}
+
 	// Material type is required
 	matType, ok := mat["type"].(string)
 	if !ok {
@@ -181,8 +334,29 @@ func validateMaterial(errors *ValidationErrors, mat map[string]interface{}, shap
 	case "dielectric":
 		validateFloatPropertyRequired(errors, mat, "refractive_index", &minRefractiveIndex, nil, matType+" material", shapeID, "")
 
+	case shadowCatcherMaterial:
+		// Shadow catchers have no fields; they are drawn after rendering, so they can't be mixed
+		if depth > 0 {
+			*errors = append(*errors, fmt.Sprintf("shape '%s' cannot use shadow_catcher inside a mix material", shapeID))
+		}
+
+	case "mix":
+		if depth >= maxMaterialNestingDepth {
+			*errors = append(*errors, fmt.Sprintf("shape '%s' mix materials can be nested at most %d levels deep", shapeID, maxMaterialNestingDepth))
+			return
+		}
+		validateFloatPropertyRequired(errors, mat, "factor", &zero, &one, matType+" material", shapeID, "")
+		for _, key := range []string{"material_a", "material_b"} {
+			subMaterial, ok := mat[key].(map[string]interface{})
+			if !ok {
+				*errors = append(*errors, fmt.Sprintf("mix material '%s' requires '%s' to be a material object", shapeID, key))
+				continue
+			}
+			validateMaterialAtDepth(errors, subMaterial, shapeID, depth+1)
+		}
+
 	default:
-		*errors = append(*errors, fmt.Sprintf("shape '%s' has unsupported material type '%s' (supported: lambertian, metal, dielectric)", shapeID, matType))
+		*errors = append(*errors, fmt.Sprintf("shape '%s' has unsupported material type '%s' (supported: lambertian, metal, dielectric, mix, shadow_catcher)", shapeID, matType))
 	}
 }
 
@@ -198,6 +372,22 @@ func validateVec3Required(errors *ValidationErrors, vec []float64, fieldName str
 		*errors = append(*errors, fmt.Sprintf("%s must have exactly 3 values", fieldName))
 		return
 	}
+	validateVec3Finite(errors, vec, fieldName)
+}
+
+// validateVec3Finite validates that no element of a Vec3 is NaN or infinite
+func validateVec3Finite(errors *ValidationErrors, vec []float64, fieldName string) {
+	for _, v := range vec {
+		if !isFinite(v) {
+			*errors = append(*errors, fmt.Sprintf("%s must be finite numbers, got %v", fieldName, vec))
+			return
+		}
+	}
+}
+
+// isFinite reports whether f is neither NaN nor infinite
+func isFinite(f float64) bool {
+	return !math.IsNaN(f) && !math.IsInf(f, 0)
 }
 
 // validateVec3NotEqual validates that two Vec3 arrays are not identical
@@ -211,14 +401,14 @@ func validateVec3NotEqual(errors *ValidationErrors, vec1, vec2 []float64, name1,
 
 // validateFloatRangeInclusive validates that a float is within an inclusive range [min, max]
 func validateFloatRangeInclusive(errors *ValidationErrors, value, min, max float64, fieldName string) {
-	if value < min || value > max {
+	if !(value >= min && value <= max) {
 		*errors = append(*errors, fmt.Sprintf("%s must be in range [%.1f, %.1f]", fieldName, min, max))
 	}
 }
 
 // validateFloatRangeExclusive validates that a float is within an exclusive range (min < value < max)
 func validateFloatRangeExclusive(errors *ValidationErrors, value, min, max float64, fieldName string) {
-	if value <= min || value >= max {
+	if !(value > min && value < max) {
 		*errors = append(*errors, fmt.Sprintf("%s must be in range (%.1f, %.1f)", fieldName, min, max))
 	}
 }
@@ -245,6 +435,10 @@ func validateVec3PropertyRequired(errors *ValidationErrors, properties map[strin
 			*errors = append(*errors, fmt.Sprintf("%s[%d] must be a number", fieldName, i))
 			return
 		}
+		if !isFinite(f) {
+			*errors = append(*errors, fmt.Sprintf("%s[%d] must be a finite number, got %v", fieldName, i, f))
+			return
+		}
 		// Validate range if specified
 		if minVal != nil && f < *minVal {
 			*errors = append(*errors, fmt.Sprintf("%s[%d] must be >= %.1f", fieldName, i, *minVal))
@@ -276,6 +470,10 @@ func validateFloatPropertyRequired(errors *ValidationErrors, properties map[stri
 		*errors = append(*errors, fmt.Sprintf("%s '%s' %s must be a number", objType, objID, key))
 		return
 	}
+	if !isFinite(val) {
+		*errors = append(*errors, fmt.Sprintf("%s '%s' %s must be a finite number, got %v", objType, objID, key, val))
+		return
+	}
 
 	// Validate range if specified
 	if constraintErrMsg != "" && minVal != nil && maxVal != nil && (val < *minVal || val > *maxVal) {
@@ -312,6 +510,10 @@ func validatePositiveFloatRequired(errors *ValidationErrors, properties map[stri
 		*errors = append(*errors, fmt.Sprintf("%s '%s' %s must be a number", objType, objID, key))
 		return
 	}
+	if !isFinite(val) {
+		*errors = append(*errors, fmt.Sprintf("%s '%s' %s must be a finite number, got %v", objType, objID, key, val))
+		return
+	}
 
 	if val <= 0 {
 		*errors = append(*errors, fmt.Sprintf("%s '%s' %s must be positive", objType, objID, key))
@@ -330,6 +532,10 @@ func validateNonNegativeFloatRequired(errors *ValidationErrors, properties map[s
 		*errors = append(*errors, fmt.Sprintf("%s '%s' %s must be a number", objType, objID, key))
 		return
 	}
+	if !isFinite(val) {
+		*errors = append(*errors, fmt.Sprintf("%s '%s' %s must be a finite number, got %v", objType, objID, key, val))
+		return
+	}
 
 	if val < 0 {
 		*errors = append(*errors, fmt.Sprintf("%s '%s' %s must be non-negative", objType, objID, key))
@@ -350,6 +556,92 @@ func validateBoolPropertyRequired(errors *ValidationErrors, properties map[strin
 	}
 }
 
+// degenerateEpsilon is how close to zero a quad edge length, or the sine of the angle
+// between its edges, may be before the quad is treated as having no area
+const degenerateEpsilon = 1e-9
+
+// validateSpotPenumbra validates a spot light's inner_angle and outer_angle
+// It returns false if the light sets neither, so the caller validates cutoff_angle and
+// falloff_exponent instead. The two ways of shaping the cone can't be mixed.
+func validateSpotPenumbra(errors *ValidationErrors, light LightRequest) bool {
+	props := light.Properties
+	if !hasProperty(props, "inner_angle") && !hasProperty(props, "outer_angle") {
+		return false
+	}
+
+	zero, maxAngle := 0.0, 180.0
+	validateFloatPropertyRequired(errors, props, "inner_angle", &zero, &maxAngle, light.Type, light.ID, "inner_angle must be between 0 and 180 degrees")
+	validateFloatPropertyRequired(errors, props, "outer_angle", &zero, &maxAngle, light.Type, light.ID, "outer_angle must be between 0 and 180 degrees")
+	inner, innerOK := props["inner_angle"].(float64)
+	outer, outerOK := props["outer_angle"].(float64)
+	if innerOK && outerOK && inner >= outer {
+		*errors = append(*errors, fmt.Sprintf("%s '%s' inner_angle (%g) must be less than outer_angle (%g)", light.Type, light.ID, inner, outer))
+	}
+
+	for _, key := range spotConeProperties[0] {
+		if hasProperty(props, key) {
+			*errors = append(*errors, fmt.Sprintf("%s '%s' can't combine %s with inner_angle/outer_angle - use cutoff_angle and falloff_exponent or inner_angle and outer_angle", light.Type, light.ID, key))
+		}
+	}
+	return true
+}
+
+// validateQuadEdges validates that a quad's u and v edges span a non-zero area
+// Zero-length or parallel edges produce a quad with no area, which renders as nothing.
+// Malformed u or v are left to validateVec3PropertyRequired.
+func validateQuadEdges(errors *ValidationErrors, properties map[string]interface{}, objType, objID string) {
+	uValues, okU := extractFloatArray(properties, "u", 3)
+	vValues, okV := extractFloatArray(properties, "v", 3)
+	if !okU || !okV {
+		return
+	}
+
+	u := vec3{uValues[0], uValues[1], uValues[2]}
+	v := vec3{vValues[0], vValues[1], vValues[2]}
+	uLength, vLength := u.length(), v.length()
+	if uLength < degenerateEpsilon {
+		*errors = append(*errors, fmt.Sprintf("%s '%s' u must be non-zero (the quad has no area)", objType, objID))
+	}
+	if vLength < degenerateEpsilon {
+		*errors = append(*errors, fmt.Sprintf("%s '%s' v must be non-zero (the quad has no area)", objType, objID))
+	}
+	if uLength < degenerateEpsilon || vLength < degenerateEpsilon {
+		return
+	}
+
+	if u.cross(v).length()/(uLength*vLength) < degenerateEpsilon {
+		*errors = append(*errors, fmt.Sprintf("%s '%s' u and v must not be parallel (the quad has no area)", objType, objID))
+	}
+}
+
+// validateBoolPropertyOptional validates an optional boolean property (only if present)
+func validateBoolPropertyOptional(errors *ValidationErrors, properties map[string]interface{}, key string, objType, objID string) {
+	if !hasProperty(properties, key) {
+		return // Property is optional and not present
+	}
+	validateBoolPropertyRequired(errors, properties, key, objType, objID)
+}
+
+// validateStringPropertyOptional validates an optional string property (only if present)
+func validateStringPropertyOptional(errors *ValidationErrors, properties map[string]interface{}, key string, objType, objID string) {
+	if !hasProperty(properties, key) {
+		return // Property is optional and not present
+	}
+	if _, ok := properties[key].(string); !ok {
+		*errors = append(*errors, fmt.Sprintf("%s '%s' property '%s' must be a string", objType, objID, key))
+	}
+}
+
+// validateStringArrayPropertyOptional validates an optional array of non-empty strings (only if present)
+func validateStringArrayPropertyOptional(errors *ValidationErrors, properties map[string]interface{}, key string, objType, objID string) {
+	if !hasProperty(properties, key) {
+		return // Property is optional and not present
+	}
+	if _, ok := extractStringArray(properties, key); !ok {
+		*errors = append(*errors, fmt.Sprintf("%s '%s' property '%s' must be an array of non-empty strings", objType, objID, key))
+	}
+}
+
 // validateStringRequired validates that a string is non-empty
 func validateStringRequired(errors *ValidationErrors, value string, fieldName string) {
 	if value == "" {
@@ -397,6 +689,23 @@ func hasProperty(properties map[string]interface{}, key string) bool {
 	return exists
 }
 
+// extractStringArray extracts an array of non-empty strings from properties
+func extractStringArray(properties map[string]interface{}, key string) ([]string, bool) {
+	val, ok := properties[key].([]interface{})
+	if !ok {
+		return nil, false
+	}
+	result := make([]string, len(val))
+	for i, v := range val {
+		str, ok := v.(string)
+		if !ok || str == "" {
+			return nil, false
+		}
+		result[i] = str
+	}
+	return result, true
+}
+
 // extractMaterial extracts material specification from shape properties
 // Returns (materialMap, exists)
 func extractMaterial(properties map[string]interface{}) (map[string]interface{}, bool) {
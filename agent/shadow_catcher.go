@@ -0,0 +1,340 @@
+package agent
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// The raytracer has no shadow catcher material, so shadow catchers are handled outside it:
+// shadow catcher quads are left out of the path traced scene, and ShadowCatcherPass paints
+// their shadows onto the finished render. For each pixel that sees a shadow catcher first, it
+// estimates how much of the light reaching that point is blocked by other shapes, using the
+// same ray casting as the AOV renderer. The pixel becomes black with that much opacity, so
+// the catcher is transparent where it is fully lit, ready to composite over a photo.
+
+// shadowCatcherMaterial is the material type that marks a quad as a shadow catcher
+const shadowCatcherMaterial = "shadow_catcher"
+
+const (
+	shadowRayOffset       = 1e-4 // Lifts shadow rays off the catcher to avoid hitting it
+	environmentSamples    = 32   // Directions sampled over the sky for environment lights
+	lightGridSamples      = 3    // Samples per side for area quad lights
+	sphereLightSamples    = 7    // Center plus six points around it for area sphere lights
+	sphereLightSampleSpan = 0.7  // How far from the center, in radii, sphere light samples sit
+)
+
+// isShadowCatcher reports whether a shape uses the shadow catcher material
+func isShadowCatcher(shape ShapeRequest) bool {
+	mat, ok := extractMaterial(shape.Properties)
+	if !ok {
+		return false
+	}
+	matType, _ := mat["type"].(string)
+	return matType == shadowCatcherMaterial
+}
+
+// lightSample is one point (or, for environment lights, one direction) lights are sampled at
+// Its weight approximates the irradiance it delivers to a surface facing it head on.
+type lightSample struct {
+	position  vec3 // Point on the light
+	direction vec3 // Direction toward the light, for infinite lights
+	infinite  bool
+
+	emitNormal vec3 // Direction an area light emits toward, zero for lights that emit in all directions
+	twoSided   bool // Area light emits from both faces
+
+	spotAxis  vec3    // Spot direction, zero for lights without a cone
+	cosCutoff float64 // Cosine of the spot cone's half angle
+
+	weight float64
+}
+
+// ShadowCatcherPass paints the shadows on a scene's shadow catchers onto a render of the scene
+// It holds its own copy of everything it needs, so it can be applied after the scene changes.
+// A nil pass leaves images unchanged.
+type ShadowCatcherPass struct {
+	camera    CameraInfo
+	catchers  []ShapeRequest
+	occluders []ShapeRequest
+	samples   []lightSample
+}
+
+// ShadowCatcherPass returns a pass for the scene's shadow catchers, or nil if it has none
+func (sm *SceneManager) ShadowCatcherPass() *ShadowCatcherPass {
+	state := sm.GetState()
+	pass := &ShadowCatcherPass{camera: state.Camera}
+	for _, shape := range state.Shapes {
+		if isShadowCatcher(shape) {
+			pass.catchers = append(pass.catchers, shape)
+		} else {
+			pass.occluders = append(pass.occluders, shape)
+		}
+	}
+	if len(pass.catchers) == 0 {
+		return nil
+	}
+
+	pass.camera.Center = append([]float64(nil), state.Camera.Center...)
+	pass.camera.LookAt = append([]float64(nil), state.Camera.LookAt...)
+
+	lights := sm.renderedLights()
+	if len(lights) == 0 {
+		pass.samples = environmentLightSamples(defaultSkyTop, defaultSkyBottom)
+	}
+	for _, light := range lights {
+		pass.samples = append(pass.samples, lightSamples(light)...)
+	}
+	return pass
+}
+
+// Apply returns img with the shadow catchers' shadows painted on
+// Large images are traced in tiles on every core.
+func (p *ShadowCatcherPass) Apply(img image.Image) image.Image {
+	if p == nil {
+		return img
+	}
+	bounds := img.Bounds()
+	return p.apply(img, renderWorkers(bounds.Dx(), bounds.Dy()))
+}
+
+// apply is Apply with an explicit number of tracing workers
+func (p *ShadowCatcherPass) apply(img image.Image, workers int) image.Image {
+
+	bounds := img.Bounds()
+	out := image.NewNRGBA(bounds)
+	draw.Draw(out, bounds, img, bounds.Min, draw.Src)
+
+	camera := newSceneCamera(p.camera, bounds.Dx(), bounds.Dy())
+	renderTiles(bounds, workers, func(tile image.Rectangle) {
+		for y := tile.Min.Y; y < tile.Max.Y; y++ {
+			for x := tile.Min.X; x < tile.Max.X; x++ {
+				dir := camera.ray(float64(x-bounds.Min.X)+0.5, float64(y-bounds.Min.Y)+0.5)
+				shadow, onCatcher := p.shadowAt(camera.origin, dir)
+				if onCatcher {
+					out.SetNRGBA(x, y, color.NRGBA{0, 0, 0, uint8(math.Round(shadow * 255))})
+				}
+			}
+		}
+	})
+	return out
+}
+
+// shadowAt returns how shadowed the first surface along a camera ray is, from 0 (fully lit) to
+// 1 (fully shadowed). onCatcher is false if the ray hits something else first, or nothing.
+func (p *ShadowCatcherPass) shadowAt(origin, dir vec3) (shadow float64, onCatcher bool) {
+	var catcherHit, occluderHit surfaceHit
+	foundCatcher, foundOccluder := false, false
+	for _, shape := range p.catchers {
+		hit, ok := intersectShape(shape, origin, dir)
+		catcherHit, foundCatcher = closer(catcherHit, foundCatcher, hit, ok)
+	}
+	if !foundCatcher {
+		return 0, false
+	}
+	for _, shape := range p.occluders {
+		hit, ok := intersectShape(shape, origin, dir)
+		occluderHit, foundOccluder = closer(occluderHit, foundOccluder, hit, ok)
+	}
+	if foundOccluder && occluderHit.t < catcherHit.t {
+		return 0, false
+	}
+
+	// Shade the side facing the camera
+	normal := catcherHit.normal
+	if normal.dot(dir) > 0 {
+		normal = normal.scale(-1)
+	}
+	point := origin.add(dir.scale(catcherHit.t)).add(normal.scale(shadowRayOffset))
+
+	total, blocked := 0.0, 0.0
+	for _, sample := range p.samples {
+		toLight, distance := sample.direction, math.Inf(1)
+		if !sample.infinite {
+			offset := sample.position.sub(point)
+			distance = offset.length()
+			if distance == 0 {
+				continue
+			}
+			toLight = offset.scale(1 / distance)
+		}
+
+		weight := sample.irradiance(toLight, distance) * math.Max(0, normal.dot(toLight))
+		if weight == 0 {
+			continue
+		}
+		total += weight
+		if p.occluded(point, toLight, distance) {
+			blocked += weight
+		}
+	}
+	if total == 0 {
+		return 0, true // No light reaches this point, so there is no shadow to show
+	}
+	return blocked / total, true
+}
+
+// irradiance returns how much light a sample delivers along toLight (pointing from the surface
+// to the light) at the given distance, before the receiving surface's cosine
+func (s lightSample) irradiance(toLight vec3, distance float64) float64 {
+	if s.infinite {
+		return s.weight
+	}
+
+	weight := s.weight / (distance * distance)
+	fromLight := toLight.scale(-1)
+	if s.emitNormal != (vec3{}) {
+		cosine := s.emitNormal.dot(fromLight)
+		if s.twoSided {
+			cosine = math.Abs(cosine)
+		}
+		weight *= math.Max(0, cosine)
+	}
+	if s.spotAxis != (vec3{}) && s.spotAxis.dot(fromLight) < s.cosCutoff {
+		return 0
+	}
+	return weight
+}
+
+// occluded reports whether any non-catcher shape blocks the ray before distance
+func (p *ShadowCatcherPass) occluded(origin, dir vec3, distance float64) bool {
+	for _, shape := range p.occluders {
+		if hit, ok := intersectShape(shape, origin, dir); ok && hit.t < distance {
+			return true
+		}
+	}
+	return false
+}
+
+// luminance returns the perceived brightness of a linear RGB color
+func luminance(c vec3) float64 {
+	return 0.2126*c[0] + 0.7152*c[1] + 0.0722*c[2]
+}
+
+// Default sky used when no lights are on, matching addLightsToScene
+var (
+	defaultSkyTop    = vec3{0.5, 0.7, 1.0}
+	defaultSkyBottom = vec3{1.0, 1.0, 1.0}
+)
+
+// lightSamples returns the samples shadow catchers use for a light
+func lightSamples(light LightRequest) []lightSample {
+	light = withRadianceEmission(light)
+	props := light.Properties
+	emission := luminance(vec3Property(props, "emission", vec3{}))
+
+	switch light.Type {
+	case "infinite_gradient_light":
+		intensity := environmentIntensity(props)
+		return environmentLightSamples(vec3Property(props, "top_color", vec3{}).scale(intensity), vec3Property(props, "bottom_color", vec3{}).scale(intensity))
+
+	case "infinite_uniform_light":
+		color := vec3Property(props, "emission", vec3{}).scale(environmentIntensity(props))
+		return environmentLightSamples(color, color)
+
+	case "infinite_physical_sky_light":
+		sunDirection, _ := extractFloatArray(props, "sun_direction", 3)
+		turbidity, _ := extractFloat(props, "turbidity")
+		if len(sunDirection) != 3 {
+			return nil
+		}
+		sky := newPhysicalSky(sunDirection, turbidity)
+		intensity := environmentIntensity(props)
+		samples := environmentLightSamples(sky.zenithColor().scale(intensity), sky.horizonColor().scale(intensity))
+		if sky.sunAboveHorizon() {
+			// The sun is far enough away to treat as a direction
+			disc := math.Pi * math.Pow(math.Sin(sunAngularRadius), 2)
+			samples = append(samples, lightSample{direction: sky.sunDirection, infinite: true, weight: luminance(sky.sunEmission()) * intensity * disc})
+		}
+		return samples
+
+	case "point_light":
+		return []lightSample{{position: vec3Property(props, "center", vec3{}), weight: emission}}
+
+	case "point_spot_light":
+		direction := vec3Property(props, "direction", vec3{defaultSpotDirection[0], defaultSpotDirection[1], defaultSpotDirection[2]})
+		cutoff, _ := spotCone(props, defaultSpotCutoffAngle, defaultSpotFalloffExponent)
+		return []lightSample{{
+			position:  vec3Property(props, "center", vec3{}),
+			spotAxis:  direction.normalize(),
+			cosCutoff: math.Cos(cutoff * math.Pi / 180),
+			weight:    emission,
+		}}
+
+	case "area_quad_light":
+		corner := vec3Property(props, "corner", vec3{})
+		u := vec3Property(props, "u", vec3{})
+		v := vec3Property(props, "v", vec3{})
+		twoSided, _ := props["two_sided"].(bool)
+		area := u.cross(v).length()
+
+		samples := make([]lightSample, 0, lightGridSamples*lightGridSamples)
+		for i := 0; i < lightGridSamples; i++ {
+			for j := 0; j < lightGridSamples; j++ {
+				s := (float64(i) + 0.5) / lightGridSamples
+				t := (float64(j) + 0.5) / lightGridSamples
+				samples = append(samples, lightSample{
+					position:   corner.add(u.scale(s)).add(v.scale(t)),
+					emitNormal: u.cross(v).normalize(),
+					twoSided:   twoSided,
+					weight:     emission * area / (lightGridSamples * lightGridSamples),
+				})
+			}
+		}
+		return samples
+
+	case "area_rect_light":
+		if quad, err := rectLightAsQuad(light); err == nil {
+			return lightSamples(quad)
+		}
+		return nil
+
+	case "area_sphere_light":
+		center := vec3Property(props, "center", vec3{})
+		radius, _ := extractFloat(props, "radius")
+		weight := emission * math.Pi * radius * radius / sphereLightSamples
+
+		samples := []lightSample{{position: center, weight: weight}}
+		for _, axis := range []vec3{{1, 0, 0}, {-1, 0, 0}, {0, 1, 0}, {0, -1, 0}, {0, 0, 1}, {0, 0, -1}} {
+			samples = append(samples, lightSample{position: center.add(axis.scale(sphereLightSampleSpan * radius)), weight: weight})
+		}
+		return samples
+
+	case "disc_spot_light", "area_disc_spot_light":
+		normal := vec3Property(props, "normal", vec3{0, -1, 0}).normalize()
+		radius, _ := extractFloat(props, "radius")
+		cutoff := discSpotCutoffAngle
+		if light.Type == "area_disc_spot_light" {
+			cutoff, _ = spotCone(props, 0, 0)
+		}
+		return []lightSample{{
+			position:   vec3Property(props, "center", vec3{}),
+			emitNormal: normal,
+			spotAxis:   normal,
+			cosCutoff:  math.Cos(cutoff * math.Pi / 180),
+			weight:     emission * math.Pi * radius * radius,
+		}}
+	}
+
+	return nil
+}
+
+// environmentLightSamples samples a sky that blends from bottom at the horizon to top overhead
+// Directions are spread evenly over the upper hemisphere with a Fibonacci spiral.
+func environmentLightSamples(top, bottom vec3) []lightSample {
+	goldenAngle := math.Pi * (3 - math.Sqrt(5))
+	solidAngle := 2 * math.Pi / environmentSamples
+
+	samples := make([]lightSample, 0, environmentSamples)
+	for i := 0; i < environmentSamples; i++ {
+		y := 1 - (float64(i)+0.5)/environmentSamples
+		r := math.Sqrt(1 - y*y)
+		angle := goldenAngle * float64(i)
+		direction := vec3{r * math.Cos(angle), y, r * math.Sin(angle)}
+
+		sky := bottom.scale(1 - y).add(top.scale(y))
+		samples = append(samples, lightSample{direction: direction, infinite: true, weight: luminance(sky) * solidAngle})
+	}
+	return samples
+}
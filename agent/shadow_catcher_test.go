@@ -0,0 +1,191 @@
+package agent
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+// shadowCatcherScene returns a scene with a ball floating over a shadow catcher ground,
+// lit from directly above
+func shadowCatcherScene(t testing.TB) *SceneManager {
+	t.Helper()
+	sm := NewSceneManager()
+	if err := sm.SetCamera(CameraInfo{Center: []float64{0, 4, 6}, LookAt: []float64{0, 0, 0}, VFov: 45}); err != nil {
+		t.Fatalf("SetCamera() failed: %v", err)
+	}
+	shapes := []ShapeRequest{
+		{ID: "ground", Type: "quad", Properties: map[string]interface{}{
+			"corner":   []interface{}{-5.0, 0.0, -5.0},
+			"u":        []interface{}{10.0, 0.0, 0.0},
+			"v":        []interface{}{0.0, 0.0, 10.0},
+			"material": map[string]interface{}{"type": "shadow_catcher"},
+		}},
+		{ID: "ball", Type: "sphere", Properties: map[string]interface{}{
+			"center": []interface{}{0.0, 1.0, 0.0},
+			"radius": 0.5,
+		}},
+	}
+	if err := sm.AddShapes(shapes); err != nil {
+		t.Fatalf("AddShapes() failed: %v", err)
+	}
+	light := LightRequest{ID: "lamp", Type: "area_sphere_light", Properties: map[string]interface{}{
+		"center":   []interface{}{0.0, 5.0, 0.0},
+		"radius":   0.2,
+		"emission": []interface{}{10.0, 10.0, 10.0},
+	}}
+	if err := sm.AddLights([]LightRequest{light}); err != nil {
+		t.Fatalf("AddLights() failed: %v", err)
+	}
+	return sm
+}
+
+func TestShadowCatcherValidation(t *testing.T) {
+	catcher := map[string]interface{}{"type": "shadow_catcher"}
+
+	tests := []struct {
+		name       string
+		shape      ShapeRequest
+		errorMatch string // Empty if the shape is valid
+	}{
+		{
+			name: "quad",
+			shape: ShapeRequest{ID: "ground", Type: "quad", Properties: map[string]interface{}{
+				"corner": []interface{}{-5.0, 0.0, -5.0}, "u": []interface{}{10.0, 0.0, 0.0}, "v": []interface{}{0.0, 0.0, 10.0},
+				"material": catcher,
+			}},
+		},
+		{
+			name: "sphere",
+			shape: ShapeRequest{ID: "ball", Type: "sphere", Properties: map[string]interface{}{
+				"center": []interface{}{0.0, 0.0, 0.0}, "radius": 1.0,
+				"material": catcher,
+			}},
+			errorMatch: "only applies to quads",
+		},
+		{
+			name: "inside mix",
+			shape: ShapeRequest{ID: "ground", Type: "quad", Properties: map[string]interface{}{
+				"corner": []interface{}{-5.0, 0.0, -5.0}, "u": []interface{}{10.0, 0.0, 0.0}, "v": []interface{}{0.0, 0.0, 10.0},
+				"material": map[string]interface{}{
+					"type":       "mix",
+					"factor":     0.5,
+					"material_a": catcher,
+					"material_b": map[string]interface{}{"type": "lambertian", "albedo": []interface{}{0.5, 0.5, 0.5}},
+				},
+			}},
+			errorMatch: "inside a mix material",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errors := NewSceneManager().ValidateShape(tt.shape)
+			if tt.errorMatch == "" {
+				if len(errors) > 0 {
+					t.Errorf("Expected no errors, got %v", errors)
+				}
+				return
+			}
+			if !strings.Contains(strings.Join(errors, "; "), tt.errorMatch) {
+				t.Errorf("Expected error containing %q, got %v", tt.errorMatch, errors)
+			}
+		})
+	}
+}
+
+func TestShadowCatcherHiddenFromRaytracer(t *testing.T) {
+	sm := shadowCatcherScene(t)
+	raytracerScene, err := sm.ToRaytracerScene()
+	if err != nil {
+		t.Fatalf("ToRaytracerScene() failed: %v", err)
+	}
+	if len(raytracerScene.Shapes) != 1 {
+		t.Errorf("Expected only the ball to be path traced, got %d shapes", len(raytracerScene.Shapes))
+	}
+}
+
+func TestShadowCatcherPassWithoutCatchers(t *testing.T) {
+	sm := shadowCatcherScene(t)
+	if err := sm.RemoveShape("ground"); err != nil {
+		t.Fatalf("RemoveShape() failed: %v", err)
+	}
+	pass := sm.ShadowCatcherPass()
+	if pass != nil {
+		t.Fatalf("Expected no pass without shadow catchers")
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, 4, 3))
+	if got := pass.Apply(img); got != img {
+		t.Errorf("Expected a nil pass to return the image unchanged")
+	}
+}
+
+func TestShadowCatcherPass(t *testing.T) {
+	sm := shadowCatcherScene(t)
+	pass := sm.ShadowCatcherPass()
+	if pass == nil {
+		t.Fatal("Expected a pass for a scene with a shadow catcher")
+	}
+
+	const width, height = 80, 60
+	camera := newSceneCamera(pass.camera, width, height)
+	pixelAt := func(p vec3) (int, int) {
+		x, y := camera.project(camera.toCamera(p))
+		return int(x), int(y)
+	}
+
+	// Start from an opaque gray render so untouched pixels are easy to spot
+	gray := color.NRGBA{128, 128, 128, 255}
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetNRGBA(x, y, gray)
+		}
+	}
+	out := pass.Apply(img).(*image.NRGBA)
+
+	// The ground right under the ball is in its shadow
+	x, y := pixelAt(vec3{0, 0, 0})
+	if shadow := out.NRGBAAt(x, y); shadow.A < 200 || shadow.R != 0 {
+		t.Errorf("Expected a dark, mostly opaque shadow under the ball, got %v", shadow)
+	}
+
+	// Open ground away from the ball is lit, so it's transparent
+	x, y = pixelAt(vec3{3, 0, 2})
+	if lit := out.NRGBAAt(x, y); lit.A > 10 {
+		t.Errorf("Expected lit ground to be transparent, got %v", lit)
+	}
+
+	// The ball itself is left as rendered
+	x, y = pixelAt(vec3{0, 1, 0.5})
+	if ball := out.NRGBAAt(x, y); ball != gray {
+		t.Errorf("Expected the ball's pixel to be unchanged, got %v", ball)
+	}
+
+	// The pass holds its own copy of the scene
+	if err := sm.RemoveShape("ball"); err != nil {
+		t.Fatalf("RemoveShape() failed: %v", err)
+	}
+	x, y = pixelAt(vec3{0, 0, 0})
+	if shadow := pass.Apply(img).(*image.NRGBA).NRGBAAt(x, y); shadow.A < 200 {
+		t.Errorf("Expected the pass to keep the ball's shadow after it was removed, got %v", shadow)
+	}
+}
+
+func TestShadowCatcherEnvironmentLight(t *testing.T) {
+	// With only the default sky, a ball resting on the ground still darkens the contact point
+	sm := shadowCatcherScene(t)
+	if err := sm.RemoveLight("lamp"); err != nil {
+		t.Fatalf("RemoveLight() failed: %v", err)
+	}
+	pass := sm.ShadowCatcherPass()
+
+	camera := newSceneCamera(pass.camera, 80, 60)
+	near, _ := pass.shadowAt(camera.origin, vec3{0, 0, 0.6}.sub(camera.origin).normalize())
+	far, _ := pass.shadowAt(camera.origin, vec3{4, 0, 0}.sub(camera.origin).normalize())
+	if near <= far {
+		t.Errorf("Expected ground near the ball (%v) to be more shadowed than open ground (%v)", near, far)
+	}
+}
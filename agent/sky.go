@@ -0,0 +1,250 @@
+package agent
+
+import (
+	"fmt"
+	"math"
+)
+
+// The raytracer has no native sky model, so physical_sky is approximated from lights it does
+// support: the Preetham analytic daylight model (a simpler predecessor of Hosek-Wilkie) is
+// evaluated at the zenith and horizon to build a gradient environment light, and the sun is a
+// small, very bright sphere light placed far away in the sun's direction.
+
+const (
+	minTurbidity     = 2.0  // Clear, dry air
+	maxTurbidity     = 10.0 // Thick haze
+	defaultTurbidity = 3.0  // Clear day
+
+	skyExposure       = 1.0 / 30.0 // Scales sky luminance (kcd/m²) so a clear noon zenith is about 1
+	sunDistance       = 1000.0     // How far away the sun sphere is placed
+	sunAngularRadius  = 1.5 * math.Pi / 180
+	sunIrradiance     = 3.0 // Light a surface facing the noon sun receives, relative to the sky
+	maxSkyZenithAngle = 89 * math.Pi / 180
+)
+
+// physicalSky is a daylight sky for a given sun direction and turbidity
+type physicalSky struct {
+	sunDirection vec3    // Unit vector pointing toward the sun
+	turbidity    float64 // Haziness, from minTurbidity to maxTurbidity
+}
+
+func newPhysicalSky(sunDirection []float64, turbidity float64) physicalSky {
+	dir := vec3{sunDirection[0], sunDirection[1], sunDirection[2]}.normalize()
+	return physicalSky{sunDirection: dir, turbidity: turbidity}
+}
+
+// sunAboveHorizon reports whether the sun is up and should be added as a light
+func (s physicalSky) sunAboveHorizon() bool {
+	return s.sunDirection[1] > 0
+}
+
+// sunZenithAngle returns the sun's angle from straight up, clamped just above the horizon
+// since the model isn't defined for a sun below it
+func (s physicalSky) sunZenithAngle() float64 {
+	return math.Min(math.Acos(math.Max(-1, math.Min(1, s.sunDirection[1]))), maxSkyZenithAngle)
+}
+
+// perez is the Perez sky distribution for one channel, with coefficients A-E
+type perez [5]float64
+
+// eval returns the relative brightness at zenith angle theta and angle gamma from the sun
+func (p perez) eval(theta, gamma float64) float64 {
+	cosTheta := math.Max(math.Cos(theta), 0.01) // Avoid the singularity at the horizon
+	cosGamma := math.Cos(gamma)
+	return (1 + p[0]*math.Exp(p[1]/cosTheta)) * (1 + p[2]*math.Exp(p[3]*gamma) + p[4]*cosGamma*cosGamma)
+}
+
+// distributions returns the Perez coefficients for luminance (Y) and chromaticity (x, y)
+func (s physicalSky) distributions() (luminance, chromaX, chromaY perez) {
+	t := s.turbidity
+	luminance = perez{0.1787*t - 1.4630, -0.3554*t + 0.4275, -0.0227*t + 5.3251, 0.1206*t - 2.5771, -0.0670*t + 0.3703}
+	chromaX = perez{-0.0193*t - 0.2592, -0.0665*t + 0.0008, -0.0004*t + 0.2125, -0.0641*t - 0.8989, -0.0033*t + 0.0452}
+	chromaY = perez{-0.0167*t - 0.2608, -0.0950*t + 0.0092, -0.0079*t + 0.2102, -0.0441*t - 1.6537, -0.0109*t + 0.0529}
+	return luminance, chromaX, chromaY
+}
+
+// zenithValues returns the zenith luminance (kcd/m²) and chromaticity for the sun position
+func (s physicalSky) zenithValues() (luminance, x, y float64) {
+	t := s.turbidity
+	theta := s.sunZenithAngle()
+	chi := (4.0/9.0 - t/120.0) * (math.Pi - 2*theta)
+	luminance = (4.0453*t-4.9710)*math.Tan(chi) - 0.2155*t + 2.4192
+
+	t2, th2, th3 := t*t, theta*theta, theta*theta*theta
+	x = t2*(0.00166*th3-0.00375*th2+0.00209*theta) +
+		t*(-0.02903*th3+0.06377*th2-0.03202*theta+0.00394) +
+		(0.11693*th3 - 0.21196*th2 + 0.06052*theta + 0.25886)
+	y = t2*(0.00275*th3-0.00610*th2+0.00317*theta) +
+		t*(-0.04214*th3+0.08970*th2-0.04153*theta+0.00516) +
+		(0.15346*th3 - 0.26756*th2 + 0.06670*theta + 0.26688)
+	return luminance, x, y
+}
+
+// radiance returns the linear RGB sky color looking in direction dir (unit vector)
+func (s physicalSky) radiance(dir vec3) vec3 {
+	thetaSun := s.sunZenithAngle()
+	sun := vec3{math.Sin(thetaSun), math.Cos(thetaSun), 0}
+	if horizontal := math.Hypot(s.sunDirection[0], s.sunDirection[2]); horizontal > 0 {
+		sun = vec3{s.sunDirection[0] / horizontal * math.Sin(thetaSun), math.Cos(thetaSun), s.sunDirection[2] / horizontal * math.Sin(thetaSun)}
+	}
+
+	theta := math.Acos(math.Max(-1, math.Min(1, dir[1])))
+	gamma := math.Acos(math.Max(-1, math.Min(1, dir.dot(sun))))
+
+	lumDist, xDist, yDist := s.distributions()
+	zenithY, zenithX, zenithChromaY := s.zenithValues()
+	luminance := zenithY * lumDist.eval(theta, gamma) / lumDist.eval(0, thetaSun)
+	x := zenithX * xDist.eval(theta, gamma) / xDist.eval(0, thetaSun)
+	y := zenithChromaY * yDist.eval(theta, gamma) / yDist.eval(0, thetaSun)
+
+	return xyYToRGB(x, y, math.Max(luminance, 0)*skyExposure)
+}
+
+// zenithColor returns the sky color straight up
+func (s physicalSky) zenithColor() vec3 {
+	return s.radiance(vec3{0, 1, 0})
+}
+
+// horizonColor returns the sky color just above the horizon, averaged around the compass
+func (s physicalSky) horizonColor() vec3 {
+	const samples = 16
+	const elevation = 5 * math.Pi / 180
+
+	var sum vec3
+	for i := 0; i < samples; i++ {
+		azimuth := 2 * math.Pi * float64(i) / samples
+		dir := vec3{math.Cos(elevation) * math.Cos(azimuth), math.Sin(elevation), math.Cos(elevation) * math.Sin(azimuth)}
+		sum = sum.add(s.radiance(dir))
+	}
+	return sum.scale(1.0 / samples)
+}
+
+// sunEmission returns the radiance of the sun sphere
+// Sunlight is reddened by the air it passes through: each channel is attenuated by Rayleigh
+// scattering (stronger for blue) plus haze that grows with turbidity, over the air mass for
+// the sun's elevation. The result is scaled so the sun's disc delivers sunIrradiance.
+func (s physicalSky) sunEmission() vec3 {
+	rayleigh := vec3{0.05, 0.11, 0.25}
+	haze := 0.03 * s.turbidity
+
+	// Kasten-Young air mass, relative to looking straight up
+	zenithDegrees := math.Acos(math.Max(0, math.Min(1, s.sunDirection[1]))) * 180 / math.Pi
+	airMass := 1 / (math.Cos(zenithDegrees*math.Pi/180) + 0.50572*math.Pow(96.07995-zenithDegrees, -1.6364))
+
+	var transmittance vec3
+	for i := range transmittance {
+		transmittance[i] = math.Exp(-(rayleigh[i] + haze) * airMass)
+	}
+
+	// A disc of angular radius r subtends pi*sin²(r) of irradiance per unit radiance
+	discFactor := math.Pi * math.Pow(math.Sin(sunAngularRadius), 2)
+	return transmittance.scale(sunIrradiance / discFactor)
+}
+
+// sunCenter returns where the sun sphere is placed and its radius
+func (s physicalSky) sunCenter() (vec3, float64) {
+	return s.sunDirection.scale(sunDistance), sunDistance * math.Tan(sunAngularRadius)
+}
+
+// xyYToRGB converts CIE xyY to linear sRGB, clamping negative channels
+func xyYToRGB(x, y, luminance float64) vec3 {
+	if y <= 0 {
+		return vec3{}
+	}
+	bigX := x / y * luminance
+	bigZ := (1 - x - y) / y * luminance
+
+	rgb := vec3{
+		3.2406*bigX - 1.5372*luminance - 0.4986*bigZ,
+		-0.9689*bigX + 1.8758*luminance + 0.0415*bigZ,
+		0.0557*bigX - 0.2040*luminance + 1.0570*bigZ,
+	}
+	for i := range rgb {
+		rgb[i] = math.Max(rgb[i], 0)
+	}
+	return rgb
+}
+
+// daylight is a preset that pairs a gradient sky with a separate sun light the model can see and
+// adjust. The raytracer has no directional light, so the sun is a distant area_sphere_light like
+// physical_sky's; it is removed along with the environment lights so switching presets can't
+// leave a stray sun behind.
+const (
+	daylightSunID       = "daylight_sun"
+	defaultSunElevation = 45.0 // Degrees above the horizon
+	defaultSunAzimuth   = 45.0 // Degrees around from +z toward +x
+)
+
+// sunDirectionFromAngles converts a sun elevation and azimuth in degrees to a direction toward it
+// Azimuth 0 is +z, where the default camera sits, and 90 is +x.
+func sunDirectionFromAngles(elevation, azimuth float64) ([]float64, error) {
+	if !(elevation > 0 && elevation <= 90) {
+		return nil, fmt.Errorf("sun_elevation must be greater than 0 and at most 90 degrees, got %g", elevation)
+	}
+	if !(azimuth >= 0 && azimuth <= 360) {
+		return nil, fmt.Errorf("sun_azimuth must be from 0 to 360 degrees, got %g", azimuth)
+	}
+	el, az := elevation*math.Pi/180, azimuth*math.Pi/180
+	return []float64{math.Cos(el) * math.Sin(az), math.Sin(el), math.Cos(el) * math.Cos(az)}, nil
+}
+
+// setDaylight adds the daylight preset's gradient sky and sun lights
+// The sky's colors follow the sun's height as physical_sky's do, unless topColor and
+// bottomColor are given. With replace false, it fails if either light already exists.
+func (sm *SceneManager) setDaylight(topColor, bottomColor, sunDirection []float64, turbidity, intensity float64, replace bool) error {
+	if len(sunDirection) != 3 || !(sunDirection[1] > 0) {
+		return fmt.Errorf("daylight requires a sun above the horizon")
+	}
+	if turbidity < minTurbidity || turbidity > maxTurbidity {
+		return fmt.Errorf("turbidity must be between %g and %g, got %g", minTurbidity, maxTurbidity, turbidity)
+	}
+	if (topColor == nil) != (bottomColor == nil) {
+		return fmt.Errorf("daylight takes both top_color and bottom_color to override the sky, or neither")
+	}
+	for _, c := range append(append([]float64(nil), topColor...), bottomColor...) {
+		if c < 0 {
+			return fmt.Errorf("daylight sky colors must be >= 0")
+		}
+	}
+	if topColor != nil && (len(topColor) != 3 || len(bottomColor) != 3) {
+		return fmt.Errorf("top_color and bottom_color must be [r,g,b] arrays")
+	}
+
+	if err := sm.prepareEnvironmentLight("infinite_gradient_light", replace); err != nil {
+		return err
+	}
+	if !replace && sm.FindLight(daylightSunID) != nil {
+		return fmt.Errorf("scene already has a daylight sun ('%s') - set replace to true to change it", daylightSunID)
+	}
+
+	sky := newPhysicalSky(sunDirection, turbidity)
+	if topColor == nil {
+		zenith, horizon := sky.zenithColor(), sky.horizonColor()
+		topColor, bottomColor = zenith[:], horizon[:]
+	}
+	center, radius := sky.sunCenter()
+	emission := sky.sunEmission().scale(intensity)
+
+	sm.revisions.lightChanged("environment_gradient")
+	sm.revisions.lightChanged(daylightSunID)
+	sm.state.Lights = append(sm.state.Lights,
+		LightRequest{
+			ID:   "environment_gradient",
+			Type: "infinite_gradient_light",
+			Properties: withEnvironmentIntensity(map[string]interface{}{
+				"top_color":    []interface{}{topColor[0], topColor[1], topColor[2]},
+				"bottom_color": []interface{}{bottomColor[0], bottomColor[1], bottomColor[2]},
+			}, intensity),
+		},
+		LightRequest{
+			ID:   daylightSunID,
+			Type: "area_sphere_light",
+			Properties: map[string]interface{}{
+				"center":   []interface{}{center[0], center[1], center[2]},
+				"radius":   radius,
+				"emission": []interface{}{emission[0], emission[1], emission[2]},
+			},
+		},
+	)
+	return nil
+}
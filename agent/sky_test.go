@@ -0,0 +1,209 @@
+package agent
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/df07/scene-llm/agent/llm"
+)
+
+func TestPhysicalSkyColors(t *testing.T) {
+	noon := newPhysicalSky([]float64{0, 1, 0}, 3)
+	evening := newPhysicalSky([]float64{1, 0.05, 0}, 3)
+
+	zenith := noon.zenithColor()
+	if zenith[2] <= zenith[0] {
+		t.Errorf("Expected a blue zenith at noon, got %v", zenith)
+	}
+	if evening.zenithColor().length() >= zenith.length() {
+		t.Errorf("Expected the evening sky to be dimmer than noon, got %v vs %v", evening.zenithColor(), zenith)
+	}
+
+	noonSun, eveningSun := noon.sunEmission(), evening.sunEmission()
+	if eveningSun[0]/eveningSun[2] <= noonSun[0]/noonSun[2] {
+		t.Errorf("Expected a low sun to be redder than a noon sun, got %v vs %v", eveningSun, noonSun)
+	}
+
+	hazy := newPhysicalSky([]float64{0, 1, 0}, maxTurbidity)
+	if hazy.sunEmission()[0] >= noonSun[0] {
+		t.Errorf("Expected haze to dim the sun, got %v vs %v", hazy.sunEmission(), noonSun)
+	}
+}
+
+func TestSetPhysicalSky(t *testing.T) {
+	t.Run("stores sky light", func(t *testing.T) {
+		sm := NewSceneManager()
+		if err := sm.SetEnvironmentLighting("physical_sky", nil, nil, nil, []float64{1, 2, 0}, 4, 1, true); err != nil {
+			t.Fatalf("SetEnvironmentLighting() failed: %v", err)
+		}
+
+		light := sm.FindLight("environment_physical_sky")
+		if light == nil || light.Type != "infinite_physical_sky_light" {
+			t.Fatalf("Expected an infinite_physical_sky_light, got %v", sm.state.Lights)
+		}
+		if turbidity, _ := extractFloat(light.Properties, "turbidity"); turbidity != 4 {
+			t.Errorf("Expected turbidity 4, got %v", turbidity)
+		}
+
+		raytracerScene, err := sm.ToRaytracerScene()
+		if err != nil {
+			t.Fatalf("ToRaytracerScene() failed: %v", err)
+		}
+		if len(raytracerScene.Lights) != 2 {
+			t.Errorf("Expected a sky gradient and a sun light, got %d lights", len(raytracerScene.Lights))
+		}
+
+		// Other environment lighting replaces the sky
+		if err := sm.SetEnvironmentLighting("uniform", nil, nil, []float64{0.2, 0.2, 0.2}, nil, 0, 1, true); err != nil {
+			t.Fatalf("SetEnvironmentLighting() failed: %v", err)
+		}
+		if sm.FindLight("environment_physical_sky") != nil {
+			t.Error("Expected the physical sky to be replaced")
+		}
+	})
+
+	t.Run("sun below horizon", func(t *testing.T) {
+		sm := NewSceneManager()
+		if err := sm.SetEnvironmentLighting("physical_sky", nil, nil, nil, []float64{1, -0.2, 0}, 3, 1, true); err != nil {
+			t.Fatalf("SetEnvironmentLighting() failed: %v", err)
+		}
+		raytracerScene, err := sm.ToRaytracerScene()
+		if err != nil {
+			t.Fatalf("ToRaytracerScene() failed: %v", err)
+		}
+		if len(raytracerScene.Lights) != 1 {
+			t.Errorf("Expected only the sky gradient once the sun has set, got %d lights", len(raytracerScene.Lights))
+		}
+	})
+
+	t.Run("validation", func(t *testing.T) {
+		tests := []struct {
+			name         string
+			sunDirection []float64
+			turbidity    float64
+		}{
+			{"missing sun direction", nil, 3},
+			{"zero sun direction", []float64{0, 0, 0}, 3},
+			{"turbidity too low", []float64{0, 1, 0}, 1},
+			{"turbidity too high", []float64{0, 1, 0}, 12},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				sm := NewSceneManager()
+				if err := sm.SetEnvironmentLighting("physical_sky", nil, nil, nil, tt.sunDirection, tt.turbidity, 1, true); err == nil {
+					t.Error("Expected error, got none")
+				}
+				if len(sm.state.Lights) != 0 {
+					t.Errorf("Expected no lights to be added, got %v", sm.state.Lights)
+				}
+			})
+		}
+	})
+}
+
+func TestParsePhysicalSkyDefaultTurbidity(t *testing.T) {
+	operation := parseSetEnvironmentLightingRequest(&llm.FunctionCall{
+		Name: "set_environment_lighting",
+		Arguments: map[string]interface{}{
+			"type":          "physical_sky",
+			"sun_direction": []interface{}{0.0, 1.0, 0.0},
+		},
+	})
+	if operation.Turbidity != defaultTurbidity {
+		t.Errorf("Expected default turbidity %v, got %v", defaultTurbidity, operation.Turbidity)
+	}
+	if len(operation.SunDirection) != 3 {
+		t.Errorf("Expected sun_direction to be parsed, got %v", operation.SunDirection)
+	}
+}
+
+func TestSunDirectionFromAngles(t *testing.T) {
+	tests := []struct {
+		elevation, azimuth float64
+		expected           []float64
+	}{
+		{90, 0, []float64{0, 1, 0}},
+		{45, 0, []float64{0, math.Sqrt2 / 2, math.Sqrt2 / 2}},
+		{45, 90, []float64{math.Sqrt2 / 2, math.Sqrt2 / 2, 0}},
+		{30, 180, []float64{0, 0.5, -math.Sqrt(3) / 2}},
+	}
+	for _, tt := range tests {
+		direction, err := sunDirectionFromAngles(tt.elevation, tt.azimuth)
+		if err != nil {
+			t.Fatalf("sunDirectionFromAngles(%g, %g) failed: %v", tt.elevation, tt.azimuth, err)
+		}
+		for i := range direction {
+			if math.Abs(direction[i]-tt.expected[i]) > 1e-9 {
+				t.Errorf("sunDirectionFromAngles(%g, %g) = %v, want %v", tt.elevation, tt.azimuth, direction, tt.expected)
+				break
+			}
+		}
+	}
+
+	for _, bad := range [][2]float64{{0, 0}, {-10, 0}, {91, 0}, {45, -1}, {45, 361}, {math.NaN(), 0}} {
+		if _, err := sunDirectionFromAngles(bad[0], bad[1]); err == nil {
+			t.Errorf("sunDirectionFromAngles(%g, %g): expected an error", bad[0], bad[1])
+		}
+	}
+}
+
+func TestDaylightTool(t *testing.T) {
+	events := make(chan AgentEvent, 100)
+	agent := NewWithProvider(events, &MockProvider{}, "mock-model")
+	call := func(args map[string]interface{}) ToolResult {
+		req := parseToolRequestFromFunctionCall(&llm.FunctionCall{Name: "set_environment_lighting", Arguments: args})
+		return agent.executeToolRequests(context.Background(), req, "test_call")
+	}
+
+	result := call(map[string]interface{}{"type": "daylight", "sun_elevation": 30.0, "sun_azimuth": 90.0})
+	if !result.Success {
+		t.Fatalf("Expected daylight to succeed, got errors: %v", result.Errors)
+	}
+	ids := result.Result.(map[string]interface{})["light_ids"]
+	if !reflect.DeepEqual(ids, []string{"environment_gradient", daylightSunID}) {
+		t.Errorf("Expected the sky and sun light IDs, got %v", ids)
+	}
+
+	sm := agent.sceneManager
+	if sky := sm.FindLight("environment_gradient"); sky == nil || sky.Type != "infinite_gradient_light" {
+		t.Fatalf("Expected a gradient sky, got %v", sm.state.Lights)
+	}
+	sun := sm.FindLight(daylightSunID)
+	if sun == nil || sun.Type != "area_sphere_light" {
+		t.Fatalf("Expected a sun light, got %v", sm.state.Lights)
+	}
+	center, _ := extractFloatArray(sun.Properties, "center", 3)
+	if !(center[0] > 0 && center[1] > 0 && math.Abs(center[2]) < 1e-6) {
+		t.Errorf("Expected a low sun in +x, got center %v", center)
+	}
+	raytracerScene, err := sm.ToRaytracerScene()
+	if err != nil {
+		t.Fatalf("ToRaytracerScene() failed: %v", err)
+	}
+	if len(raytracerScene.Lights) != 2 {
+		t.Errorf("Expected a sky gradient and a sun light, got %d lights", len(raytracerScene.Lights))
+	}
+
+	// Stacking a second daylight fails, and other environment lighting removes the sun too
+	if result := call(map[string]interface{}{"type": "daylight", "replace": false}); result.Success {
+		t.Error("Expected stacking a second daylight to fail")
+	}
+	if result := call(map[string]interface{}{"type": "uniform", "emission": []interface{}{0.2, 0.2, 0.2}}); !result.Success {
+		t.Fatalf("Expected uniform lighting to succeed, got errors: %v", result.Errors)
+	}
+	if sm.FindLight(daylightSunID) != nil || sm.FindLight("environment_gradient") != nil {
+		t.Errorf("Expected the daylight lights to be replaced, got %v", sm.state.Lights)
+	}
+
+	for name, args := range map[string]map[string]interface{}{
+		"sun below horizon": {"type": "daylight", "sun_elevation": -5.0},
+		"azimuth too large": {"type": "daylight", "sun_azimuth": 400.0},
+		"only top_color":    {"type": "daylight", "top_color": []interface{}{0.5, 0.7, 1.0}},
+	} {
+		if result := call(args); result.Success {
+			t.Errorf("%s: expected daylight to fail", name)
+		}
+	}
+}
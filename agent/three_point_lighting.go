@@ -0,0 +1,100 @@
+package agent
+
+import (
+	"fmt"
+	"math"
+)
+
+// threePointLight is one light of the three-point setup, placed relative to the camera's view
+// of the target
+type threePointLight struct {
+	id        string
+	azimuth   float64 // Degrees around the target from the camera, positive toward the camera's right
+	elevation float64 // Degrees above the horizontal
+	weight    float64 // Brightness relative to the key light
+}
+
+// threePointLights are the conventional studio positions: the key light in front and to one
+// side, a dimmer fill on the other side to soften the key's shadows, and a rim light behind
+// the target to separate it from the background.
+var threePointLights = []threePointLight{
+	{id: "key_light", azimuth: 45, elevation: 35, weight: 1},
+	{id: "fill_light", azimuth: -60, elevation: 15, weight: 0.5},
+	{id: "rim_light", azimuth: -150, elevation: 45, weight: 0.75},
+}
+
+const (
+	// defaultThreePointIntensity is the key light's default brightness at the target
+	defaultThreePointIntensity = 3.0
+	// threePointDistance places the lights this many target radii from its center
+	threePointDistance = 3.0
+)
+
+// SetupThreePointLighting lights a shape, or the whole scene for an empty targetID, with key,
+// fill and rim spot lights placed around it relative to the camera
+// intensity is the key light's brightness where it reaches the target: each light's emission is
+// scaled by its squared distance, so the result doesn't depend on the target's size. Lights
+// already using the setup's IDs are replaced. Returns the created lights.
+func (sm *SceneManager) SetupThreePointLighting(targetID string, intensity float64) ([]LightRequest, error) {
+	if !(intensity >= 0) || math.IsInf(intensity, 1) {
+		return nil, fmt.Errorf("intensity must be >= 0, got %g", intensity)
+	}
+
+	var lo, hi vec3
+	if targetID != "" {
+		shape := sm.FindShape(targetID)
+		if shape == nil {
+			return nil, fmt.Errorf("shape '%s' not found", targetID)
+		}
+		var ok bool
+		if lo, hi, ok = shapeBounds(*shape); !ok {
+			return nil, fmt.Errorf("shape '%s' has no extent to light", targetID)
+		}
+	} else {
+		min, max, ok := sm.SceneBounds()
+		if !ok {
+			return nil, fmt.Errorf("cannot light an empty scene - add shapes first")
+		}
+		lo, hi = vec3{min[0], min[1], min[2]}, vec3{max[0], max[1], max[2]}
+	}
+	target := lo.add(hi).scale(0.5)
+	radius := hi.sub(lo).length() / 2
+	if radius == 0 {
+		radius = 1 // A single point - pick a sensible lighting distance
+	}
+	distance := threePointDistance * radius
+
+	// Work in the horizontal plane from the target toward the camera, so the lights stay level
+	// however the camera is tilted
+	up := vec3{0, 1, 0}
+	camera := sm.GetCamera()
+	toCamera := vec3{camera.Center[0], 0, camera.Center[2]}.sub(vec3{target[0], 0, target[2]})
+	if toCamera.length() < 1e-9 {
+		toCamera = cameraPresets["front"] // Camera straight above or below - light from the front
+	}
+	toCamera = toCamera.normalize()
+	right := toCamera.scale(-1).cross(up)
+
+	for _, light := range threePointLights {
+		if sm.FindLight(light.id) != nil {
+			if err := sm.RemoveLight(light.id); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	created := make([]LightRequest, 0, len(threePointLights))
+	for _, light := range threePointLights {
+		azimuth, elevation := light.azimuth*math.Pi/180, light.elevation*math.Pi/180
+		horizontal := toCamera.scale(math.Cos(azimuth)).add(right.scale(math.Sin(azimuth)))
+		direction := horizontal.scale(math.Cos(elevation)).add(up.scale(math.Sin(elevation)))
+
+		power := intensity * light.weight * distance * distance
+		added, err := sm.addAimedLight(target, light.id, "point_spot_light", direction, distance, []float64{power, power, power})
+		if err != nil {
+			return nil, err
+		}
+		created = append(created, *added)
+	}
+	return created, nil
+}
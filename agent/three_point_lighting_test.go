@@ -0,0 +1,143 @@
+package agent
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/df07/scene-llm/agent/llm"
+)
+
+func TestSetupThreePointLighting(t *testing.T) {
+	newScene := func() *SceneManager {
+		sm := NewSceneManager()
+		if err := sm.AddShapes([]ShapeRequest{{ID: "bust", Type: "sphere", Properties: map[string]interface{}{
+			"center": []interface{}{0.0, 1.0, 0.0},
+			"radius": 1.0,
+		}}}); err != nil {
+			t.Fatalf("AddShapes() failed: %v", err)
+		}
+		if err := sm.SetCamera(CameraInfo{Center: []float64{0, 1, 10}, LookAt: []float64{0, 1, 0}, VFov: 40}); err != nil {
+			t.Fatalf("SetCamera() failed: %v", err)
+		}
+		return sm
+	}
+	position := func(light LightRequest) vec3 {
+		center, _ := extractFloatArray(light.Properties, "center", 3)
+		return vec3{center[0], center[1], center[2]}
+	}
+
+	sm := newScene()
+	lights, err := sm.SetupThreePointLighting("bust", 2)
+	if err != nil {
+		t.Fatalf("SetupThreePointLighting() failed: %v", err)
+	}
+	if len(lights) != 3 || lights[0].ID != "key_light" || lights[1].ID != "fill_light" || lights[2].ID != "rim_light" {
+		t.Fatalf("Expected key, fill and rim lights, got %+v", lights)
+	}
+
+	target := vec3{0, 1, 0}
+	key, fill, rim := position(lights[0]), position(lights[1]), position(lights[2])
+	if key[2] <= 0 || key[0] <= 0 || key[1] <= target[1] {
+		t.Errorf("Expected the key light in front, to the camera's right and above, got %v", key)
+	}
+	if fill[2] <= 0 || fill[0] >= 0 {
+		t.Errorf("Expected the fill light in front and to the camera's left, got %v", fill)
+	}
+	if rim[2] >= 0 || rim[1] <= target[1] {
+		t.Errorf("Expected the rim light behind the target and above, got %v", rim)
+	}
+
+	for _, light := range lights {
+		if light.Type != "point_spot_light" {
+			t.Errorf("Expected %s to be a spot light, got %s", light.ID, light.Type)
+		}
+		// Each spot is aimed at the target
+		toTarget := target.sub(position(light)).normalize()
+		direction, _ := extractFloatArray(light.Properties, "direction", 3)
+		if toTarget.dot(vec3{direction[0], direction[1], direction[2]}) < 1-1e-9 {
+			t.Errorf("Expected %s to be aimed at the target, got direction %v", light.ID, direction)
+		}
+	}
+
+	// intensity is the key light's brightness at the target, and the others are scaled to match
+	distance := position(lights[0]).sub(target).length()
+	emission, _ := extractFloatArray(lights[0].Properties, "emission", 3)
+	if got := emission[0] / (distance * distance); math.Abs(got-2) > 1e-9 {
+		t.Errorf("Expected the key light to reach the target at intensity 2, got %g", got)
+	}
+	fillEmission, _ := extractFloatArray(lights[1].Properties, "emission", 3)
+	if fillEmission[0] >= emission[0] {
+		t.Errorf("Expected the fill light dimmer than the key light, got %g and %g", fillEmission[0], emission[0])
+	}
+
+	// Running it again replaces the lights rather than failing on their IDs
+	if err := sm.AddLights([]LightRequest{{ID: "lamp", Type: "point_light", Properties: map[string]interface{}{
+		"center":   []interface{}{0.0, 5.0, 0.0},
+		"emission": []interface{}{1.0, 1.0, 1.0},
+	}}}); err != nil {
+		t.Fatalf("AddLights() failed: %v", err)
+	}
+	if _, err := sm.SetupThreePointLighting("", 1); err != nil {
+		t.Fatalf("SetupThreePointLighting() failed the second time: %v", err)
+	}
+	if len(sm.state.Lights) != 4 || sm.FindLight("lamp") == nil {
+		t.Errorf("Expected the three lights to be replaced and other lights kept, got %+v", sm.state.Lights)
+	}
+
+	errorCases := []struct {
+		name      string
+		targetID  string
+		intensity float64
+		empty     bool
+		want      string
+	}{
+		{name: "missing shape", targetID: "nope", intensity: 1, want: "shape 'nope' not found"},
+		{name: "negative intensity", targetID: "bust", intensity: -1, want: "intensity must be >= 0"},
+		{name: "empty scene", intensity: 1, empty: true, want: "empty scene"},
+	}
+	for _, tt := range errorCases {
+		t.Run(tt.name, func(t *testing.T) {
+			sm := newScene()
+			if tt.empty {
+				sm.ClearScene()
+			}
+			_, err := sm.SetupThreePointLighting(tt.targetID, tt.intensity)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected an error containing %q, got %v", tt.want, err)
+			}
+			if sm.FindLight("key_light") != nil {
+				t.Error("Expected no lights to be added")
+			}
+		})
+	}
+}
+
+func TestSetupThreePointLightingTool(t *testing.T) {
+	agent := NewWithProvider(make(chan AgentEvent, 100), &MockProvider{}, "mock-model")
+	if err := agent.sceneManager.AddShapes([]ShapeRequest{{ID: "crate", Type: "box", Properties: map[string]interface{}{
+		"center":     []interface{}{0.0, 0.5, 0.0},
+		"dimensions": []interface{}{1.0, 1.0, 1.0},
+	}}}); err != nil {
+		t.Fatalf("AddShapes() failed: %v", err)
+	}
+
+	req := parseToolRequestFromFunctionCall(&llm.FunctionCall{Name: "setup_three_point_lighting", Arguments: map[string]interface{}{"id": "crate"}})
+	result := agent.executeToolRequests(context.Background(), req, "call-1")
+	if !result.Success {
+		t.Fatalf("Expected setup_three_point_lighting to succeed, got errors: %v", result.Errors)
+	}
+	op := req.(*SetupThreePointLightingRequest)
+	if op.Intensity != defaultThreePointIntensity {
+		t.Errorf("Expected the default intensity %g, got %g", defaultThreePointIntensity, op.Intensity)
+	}
+	if len(op.Lights) != 3 {
+		t.Fatalf("Expected three lights in the result, got %+v", op.Lights)
+	}
+	for _, id := range []string{"key_light", "fill_light", "rim_light"} {
+		if agent.sceneManager.FindLight(id) == nil {
+			t.Errorf("Expected %s in the scene", id)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package agent
+
+import (
+	"image"
+	"runtime"
+	"sync"
+)
+
+// The tile pool runs the parts of render_scene this package traces itself: normal and depth
+// AOV buffers and the shadow catcher pass. Path-traced beauty renders go through the raytracer
+// library, which can't render a tile on its own; see specs/future-enhancements.md.
+
+// renderTileSize is the edge length of the square tiles large traces are split into
+const renderTileSize = 64
+
+// tiledRenderThreshold is the pixel count above which traces are split into tiles and
+// rendered on every core. Smaller renders finish faster than the workers take to start.
+const tiledRenderThreshold = 256 * 256
+
+// renderWorkers returns how many workers to render a width x height image with
+func renderWorkers(width, height int) int {
+	if width*height > tiledRenderThreshold {
+		return runtime.NumCPU()
+	}
+	return 1
+}
+
+// splitTiles splits bounds into tiles of at most size x size pixels, in row-major order
+// Tiles on the right and bottom edges are cropped to bounds.
+func splitTiles(bounds image.Rectangle, size int) []image.Rectangle {
+	var tiles []image.Rectangle
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += size {
+		for x := bounds.Min.X; x < bounds.Max.X; x += size {
+			tiles = append(tiles, image.Rect(x, y, x+size, y+size).Intersect(bounds))
+		}
+	}
+	return tiles
+}
+
+// renderTiles calls renderTile for every tile of bounds on a pool of workers goroutines
+// and returns once all tiles are done. renderTile writes its tile straight into the caller's
+// framebuffer, so tiles must only touch their own pixels. Since each tile is computed
+// independently, the result doesn't depend on the number of workers or the order tiles finish.
+func renderTiles(bounds image.Rectangle, workers int, renderTile func(tile image.Rectangle)) {
+	tiles := splitTiles(bounds, renderTileSize)
+	if workers > len(tiles) {
+		workers = len(tiles)
+	}
+	if workers <= 1 {
+		for _, tile := range tiles {
+			renderTile(tile)
+		}
+		return
+	}
+
+	queue := make(chan image.Rectangle, len(tiles))
+	for _, tile := range tiles {
+		queue <- tile
+	}
+	close(queue)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for tile := range queue {
+				renderTile(tile)
+			}
+		}()
+	}
+	wg.Wait()
+}
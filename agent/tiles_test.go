@@ -0,0 +1,156 @@
+package agent
+
+import (
+	"image"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestSplitTiles(t *testing.T) {
+	bounds := image.Rect(0, 0, 150, 70)
+	tiles := splitTiles(bounds, 64)
+
+	// 3 columns (64, 64, 22) by 2 rows (64, 6)
+	if len(tiles) != 6 {
+		t.Fatalf("Expected 6 tiles, got %d: %v", len(tiles), tiles)
+	}
+	if last := tiles[len(tiles)-1]; last != image.Rect(128, 64, 150, 70) {
+		t.Errorf("Expected the last tile cropped to the bounds, got %v", last)
+	}
+
+	// Every pixel is covered by exactly one tile
+	covered := make(map[image.Point]int)
+	for _, tile := range tiles {
+		for y := tile.Min.Y; y < tile.Max.Y; y++ {
+			for x := tile.Min.X; x < tile.Max.X; x++ {
+				covered[image.Pt(x, y)]++
+			}
+		}
+	}
+	if len(covered) != bounds.Dx()*bounds.Dy() {
+		t.Errorf("Expected %d pixels covered, got %d", bounds.Dx()*bounds.Dy(), len(covered))
+	}
+	for p, count := range covered {
+		if count != 1 {
+			t.Fatalf("Pixel %v covered %d times", p, count)
+		}
+	}
+}
+
+func TestRenderWorkers(t *testing.T) {
+	if workers := renderWorkers(200, 150); workers != 1 {
+		t.Errorf("Expected small renders to use 1 worker, got %d", workers)
+	}
+	if workers := renderWorkers(800, 600); workers != runtime.NumCPU() {
+		t.Errorf("Expected large renders to use %d workers, got %d", runtime.NumCPU(), workers)
+	}
+}
+
+func TestTiledAOVMatchesSingleThreaded(t *testing.T) {
+	state := benchmarkAOVScene()
+	for _, aov := range []AOV{AOVNormal, AOVDepth} {
+		single, err := renderAOV(state, aov, 300, 200, 1)
+		if err != nil {
+			t.Fatalf("renderAOV failed: %v", err)
+		}
+		tiled, err := renderAOV(state, aov, 300, 200, 8)
+		if err != nil {
+			t.Fatalf("renderAOV failed: %v", err)
+		}
+		if !reflect.DeepEqual(single, tiled) {
+			t.Errorf("%s: tiled render differs from single-threaded render", aov)
+		}
+	}
+}
+
+func TestRenderAOVTilesLargeImages(t *testing.T) {
+	// 640x480 is over the threshold, so RenderAOV splits it across every core
+	state := benchmarkAOVScene()
+	tiled, err := RenderAOV(state, AOVNormal, 640, 480)
+	if err != nil {
+		t.Fatalf("RenderAOV failed: %v", err)
+	}
+	single, err := renderAOV(state, AOVNormal, 640, 480, 1)
+	if err != nil {
+		t.Fatalf("renderAOV failed: %v", err)
+	}
+	if !reflect.DeepEqual(single, tiled) {
+		t.Error("Tiled render differs from single-threaded render")
+	}
+}
+
+func TestTiledShadowCatcherMatchesSingleThreaded(t *testing.T) {
+	pass := shadowCatcherScene(t).ShadowCatcherPass()
+	img := image.NewNRGBA(image.Rect(0, 0, 640, 480))
+	for i := range img.Pix {
+		img.Pix[i] = 128
+	}
+	// Running twice with many workers also checks the result doesn't depend on tile order
+	single := pass.apply(img, 1)
+	for i := 0; i < 2; i++ {
+		if tiled := pass.apply(img, 8); !reflect.DeepEqual(single, tiled) {
+			t.Fatal("Tiled shadow catcher pass differs from single-threaded pass")
+		}
+	}
+}
+
+// benchmarkAOVScene is a small scene with every shape type
+func benchmarkAOVScene() *SceneState {
+	return &SceneState{
+		Camera: CameraInfo{Center: []float64{0, 2, 8}, LookAt: []float64{0, 0, 0}, VFov: 45},
+		Shapes: []ShapeRequest{
+			{ID: "floor", Type: "quad", Properties: map[string]interface{}{
+				"corner": []interface{}{-5.0, -1.0, -5.0}, "u": []interface{}{10.0, 0.0, 0.0}, "v": []interface{}{0.0, 0.0, 10.0},
+			}},
+			{ID: "ball", Type: "sphere", Properties: map[string]interface{}{"center": []interface{}{-1.5, 0.0, 0.0}, "radius": 1.0}},
+			{ID: "crate", Type: "box", Properties: map[string]interface{}{
+				"center": []interface{}{1.5, 0.0, 0.0}, "dimensions": []interface{}{1.5, 2.0, 1.5}, "rotation": []interface{}{0.0, 0.5, 0.0},
+			}},
+			{ID: "pillar", Type: "cylinder", Properties: map[string]interface{}{
+				"base_center": []interface{}{0.0, -1.0, -2.0}, "top_center": []interface{}{0.0, 2.0, -2.0}, "radius": 0.4, "capped": true,
+			}},
+			{ID: "spike", Type: "cone", Properties: map[string]interface{}{
+				"base_center": []interface{}{3.0, -1.0, -2.0}, "base_radius": 0.6, "top_center": []interface{}{3.0, 1.0, -2.0}, "top_radius": 0.0, "capped": true,
+			}},
+			{ID: "plate", Type: "disc", Properties: map[string]interface{}{
+				"center": []interface{}{-3.0, 1.0, -2.0}, "normal": []interface{}{0.0, 0.0, 1.0}, "radius": 0.8,
+			}},
+		},
+	}
+}
+
+func BenchmarkRenderAOVSingleThreaded(b *testing.B) {
+	state := benchmarkAOVScene()
+	for i := 0; i < b.N; i++ {
+		if _, err := renderAOV(state, AOVDepth, 800, 600, 1); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRenderAOVTiled(b *testing.B) {
+	state := benchmarkAOVScene()
+	for i := 0; i < b.N; i++ {
+		if _, err := renderAOV(state, AOVDepth, 800, 600, runtime.NumCPU()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchmarkShadowCatcher(b *testing.B, workers int) {
+	pass := shadowCatcherScene(b).ShadowCatcherPass()
+	img := image.NewNRGBA(image.Rect(0, 0, 800, 600))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		pass.apply(img, workers)
+	}
+}
+
+func BenchmarkShadowCatcherSingleThreaded(b *testing.B) {
+	benchmarkShadowCatcher(b, 1)
+}
+
+func BenchmarkShadowCatcherTiled(b *testing.B) {
+	benchmarkShadowCatcher(b, runtime.NumCPU())
+}
@@ -1,7 +1,13 @@
 package agent
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
 	"github.com/df07/scene-llm/agent/llm"
+	"github.com/df07/scene-llm/agent/llm/gemini"
 	"google.golang.org/genai"
 )
 
@@ -57,17 +63,76 @@ type UpdateShapeRequest struct {
 	After   *ShapeRequest          `json:"after,omitempty"`  // Populated by agent after execution
 }
 
+type RevertShapeRequest struct {
+	BaseToolRequest
+	Before *ShapeRequest `json:"before,omitempty"` // Populated by agent after execution
+	After  *ShapeRequest `json:"after,omitempty"`  // Populated by agent after execution
+}
+
 type RemoveShapeRequest struct {
 	BaseToolRequest
 	RemovedShape *ShapeRequest `json:"removed_shape,omitempty"` // Populated by agent after execution
 }
 
+type RenameShapesRequest struct {
+	BaseToolRequest
+	Mapping map[string]string `json:"mapping,omitempty"` // Old ID -> new ID
+	Ids     []string          `json:"ids,omitempty"`     // Shapes to rename with Prefix and Suffix
+	Prefix  string            `json:"prefix,omitempty"`
+	Suffix  string            `json:"suffix,omitempty"`
+	Renamed map[string]string `json:"renamed,omitempty"` // Populated by agent after execution
+}
+
+type RemoveShapesRequest struct {
+	BaseToolRequest
+	Ids           []string          `json:"ids"`
+	RemovedShapes []ShapeRequest    `json:"removed_shapes,omitempty"` // Populated by agent after execution
+	Results       map[string]string `json:"results,omitempty"`        // Populated by agent after execution
+}
+
+type ArrayShapesRequest struct {
+	BaseToolRequest
+	Counts     []int     `json:"counts"`                // Grid cells along x, y, z
+	Spacing    []float64 `json:"spacing"`               // Distance between cells along x, y, z
+	CreatedIds []string  `json:"created_ids,omitempty"` // Populated by agent after execution
+}
+
+type SnapShapesRequest struct {
+	BaseToolRequest
+	TargetID  string                 `json:"target_id"`
+	Direction []float64              `json:"direction"`          // Direction to move the mover in
+	Position  map[string]interface{} `json:"position,omitempty"` // Mover's new position properties, populated after execution
+}
+
+type ExportShapeRequest struct {
+	BaseToolRequest
+	Snippet string `json:"snippet,omitempty"` // Populated by agent after execution
+}
+
+type ImportShapeRequest struct {
+	BaseToolRequest
+	Snippet string        `json:"snippet"`
+	Shape   *ShapeRequest `json:"shape,omitempty"` // Populated by agent after execution
+}
+
+type ImportDSLRequest struct {
+	BaseToolRequest
+	DSL        string   `json:"dsl"`
+	CreatedIds []string `json:"created_ids,omitempty"` // Populated by agent after execution
+}
+
 type SetEnvironmentLightingRequest struct {
 	BaseToolRequest
 	LightingType string    `json:"lighting_type"`
 	TopColor     []float64 `json:"top_color,omitempty"`
 	BottomColor  []float64 `json:"bottom_color,omitempty"`
 	Emission     []float64 `json:"emission,omitempty"`
+	SunDirection []float64 `json:"sun_direction,omitempty"` // Toward the sun, for physical_sky
+	SunElevation float64   `json:"sun_elevation,omitempty"` // Degrees above the horizon, for daylight (default 45)
+	SunAzimuth   float64   `json:"sun_azimuth,omitempty"`   // Degrees from +z toward +x, for daylight (default 45)
+	Turbidity    float64   `json:"turbidity,omitempty"`     // Haziness, for physical_sky (default 3)
+	Intensity    float64   `json:"intensity"`               // Multiplies the colors or emission (default 1)
+	Replace      bool      `json:"replace"`                 // Remove existing environment lights first (default true)
 }
 
 type CreateLightRequest struct {
@@ -75,6 +140,22 @@ type CreateLightRequest struct {
 	Light LightRequest `json:"light"`
 }
 
+type LightShapeRequest struct {
+	BaseToolRequest
+	LightID   string        `json:"light_id,omitempty"`
+	LightType string        `json:"light_type,omitempty"`
+	Direction []float64     `json:"direction"` // From the shape toward the light
+	Distance  float64       `json:"distance"`
+	Emission  []float64     `json:"emission"`
+	Light     *LightRequest `json:"light,omitempty"` // Populated by agent after execution
+}
+
+type SetupThreePointLightingRequest struct {
+	BaseToolRequest
+	Intensity float64        `json:"intensity"`
+	Lights    []LightRequest `json:"lights,omitempty"` // Populated by agent after execution
+}
+
 type UpdateLightRequest struct {
 	BaseToolRequest
 	Updates map[string]interface{} `json:"updates"`
@@ -87,51 +168,390 @@ type RemoveLightRequest struct {
 	RemovedLight *LightRequest `json:"removed_light,omitempty"` // Populated by agent after execution
 }
 
+type GetLightRequest struct {
+	BaseToolRequest
+	Light *LightRequest `json:"light,omitempty"` // Populated by agent after execution
+}
+
+type SetLightEnabledRequest struct {
+	BaseToolRequest
+	Enabled *bool         `json:"enabled"`
+	Light   *LightRequest `json:"light,omitempty"` // Populated by agent after execution
+}
+
+type SoloLightRequest struct {
+	BaseToolRequest
+	Suppressed []string `json:"suppressed,omitempty"` // Populated by agent after execution
+}
+
+type UnsoloLightRequest struct {
+	BaseToolRequest
+}
+
 type SetCameraRequest struct {
 	BaseToolRequest
 	Camera CameraInfo `json:"camera"`
 }
 
+type AddCameraRequest struct {
+	BaseToolRequest
+	ID     string     `json:"id"`
+	Camera CameraInfo `json:"camera"`
+}
+
+type SelectCameraRequest struct {
+	BaseToolRequest
+	ID     string      `json:"id"`
+	Camera *CameraInfo `json:"camera,omitempty"` // Populated after execution
+}
+
+type RemoveCameraRequest struct {
+	BaseToolRequest
+	ID string `json:"id"`
+}
+
+type CheckpointSceneRequest struct {
+	BaseToolRequest
+	Label     string `json:"label"`
+	Overwrite bool   `json:"overwrite"` // Replace an existing checkpoint with the same label
+}
+
+type RestoreCheckpointRequest struct {
+	BaseToolRequest
+	Label string `json:"label"`
+}
+
+type ListCheckpointsRequest struct {
+	BaseToolRequest
+}
+
 type RenderSceneRequest struct {
 	BaseToolRequest
+	Mode          string `json:"mode,omitempty"`           // "shaded" (default) or "wireframe"
+	AOV           string `json:"aov,omitempty"`            // "beauty" (default), "normal", or "depth"
 	RenderedImage []byte `json:"rendered_image,omitempty"` // Populated after execution
+	OutputPath    string `json:"output_path,omitempty"`    // PNG or JPEG file to write under the agent's output directory, empty for none
+	ImageFormat   string `json:"image_format,omitempty"`   // Format of the output_path file, empty to follow its extension
+	ImageQuality  int    `json:"image_quality,omitempty"`  // JPEG quality of the output_path file from 1 to 100, 0 for the default
+
+	// Adaptive sampling overrides for this render, nil to use the quality's settings
+	AdaptiveMinSamples *float64 `json:"adaptive_min_samples,omitempty"`
+	AdaptiveThreshold  *float64 `json:"adaptive_threshold,omitempty"`
+
+	Denoise    *bool  `json:"denoise,omitempty"`    // Denoise override for this render, nil to use the agent's setting
+	Integrator string `json:"integrator,omitempty"` // Integrator override for this render, empty to use the agent's setting
+
+	ClampIndirect *float64 `json:"clamp_indirect,omitempty"` // Firefly clamp override for this render, nil to use the agent's setting
+}
+
+type PreviewMaterialRequest struct {
+	BaseToolRequest
+	Material      map[string]interface{} `json:"material"`
+	RenderedImage []byte                 `json:"rendered_image,omitempty"` // Populated after execution
+}
+
+type SetDefaultMaterialRequest struct {
+	BaseToolRequest
+	Material map[string]interface{} `json:"material,omitempty"` // nil or empty restores gray lambertian
+}
+
+// imageToolRequest is implemented by requests whose tools render an image for the model to see
+type imageToolRequest interface {
+	ToolRequest
+	renderedImage() []byte
+}
+
+func (r *RenderSceneRequest) renderedImage() []byte     { return r.RenderedImage }
+func (r *PreviewMaterialRequest) renderedImage() []byte { return r.RenderedImage }
+
+type SetRenderQualityRequest struct {
+	BaseToolRequest
+	Quality    string `json:"quality"`              // "preview", "draft", "high", or "auto"
+	Denoise    *bool  `json:"denoise,omitempty"`    // nil leaves the current setting unchanged
+	Integrator string `json:"integrator,omitempty"` // "path", empty leaves the current setting unchanged
+
+	ClampIndirect *float64 `json:"clamp_indirect,omitempty"` // Firefly clamp threshold, 0 for off, nil leaves the current setting unchanged
+}
+
+type SetPostProcessRequest struct {
+	BaseToolRequest
+	Vignette   float64 `json:"vignette"`
+	Contrast   float64 `json:"contrast"`
+	Saturation float64 `json:"saturation"`
+}
+
+type ZoomCameraRequest struct {
+	BaseToolRequest
+	Factor float64     `json:"factor"`
+	Camera *CameraInfo `json:"camera,omitempty"` // Populated after execution
+}
+
+type SetAspectRatioRequest struct {
+	BaseToolRequest
+	AspectRatio string `json:"aspect_ratio"` // "width:height" or a number, e.g. "16:9" or "1.85"
+}
+
+type SetCameraPresetRequest struct {
+	BaseToolRequest
+	Preset string      `json:"preset"`           // "front", "back", "left", "right", "top", or "iso"
+	Camera *CameraInfo `json:"camera,omitempty"` // Populated after execution
+}
+
+type FrameShapeRequest struct {
+	BaseToolRequest
+	ID     string      `json:"id"`
+	Margin float64     `json:"margin"`           // Space around the shape as a fraction of its size
+	Camera *CameraInfo `json:"camera,omitempty"` // Populated after execution
+}
+
+type GetCameraRequest struct {
+	BaseToolRequest
+	Camera *CameraDetails `json:"camera,omitempty"` // Populated after execution
 }
 
 type GetSceneStateRequest struct {
 	BaseToolRequest
+	Since      *int                   `json:"since,omitempty"`       // Only return changes after this revision
+	Resolved   bool                   `json:"resolved,omitempty"`    // Return values with defaults applied, as rendered
 	SceneState map[string]interface{} `json:"scene_state,omitempty"` // Populated after execution
+	Changes    *SceneChanges          `json:"changes,omitempty"`     // Populated after execution when Since is set
+}
+
+type GetSceneStatisticsRequest struct {
+	BaseToolRequest
+	Statistics map[string]interface{} `json:"statistics,omitempty"` // Populated after execution
+}
+
+type FindShapesByTagRequest struct {
+	BaseToolRequest
+	Tag      string   `json:"tag"`
+	ShapeIds []string `json:"shape_ids,omitempty"` // Populated after execution
+}
+
+type SceneIsEmptyRequest struct {
+	BaseToolRequest
+}
+
+type IsPointOccupiedRequest struct {
+	BaseToolRequest
+	Point    []float64 `json:"point"`
+	ShapeIds []string  `json:"shape_ids,omitempty"` // Populated after execution
+}
+
+type CheckOverlapRequest struct {
+	BaseToolRequest
+	ShapeA string                 `json:"shape_a"`
+	ShapeB string                 `json:"shape_b"`
+	Report map[string]interface{} `json:"report,omitempty"` // Populated after execution
+}
+
+type MeasureDistanceRequest struct {
+	BaseToolRequest
+	From     DistanceTarget `json:"from"`
+	To       DistanceTarget `json:"to"`
+	Distance float64        `json:"distance,omitempty"` // Populated after execution
+	Delta    []float64      `json:"delta,omitempty"`    // Populated after execution
+}
+
+type DoneRequest struct {
+	BaseToolRequest
+}
+
+type ValidateShapeRequest struct {
+	BaseToolRequest
+	Shape ShapeRequest `json:"shape"`
+}
+
+type ValidateLightRequest struct {
+	BaseToolRequest
+	Light LightRequest `json:"light"`
+}
+
+// ------------------------------------------------------------
+// Tool registry - pairs each tool's declaration, parser, and handler
+// ------------------------------------------------------------
+
+// toolSpec describes one tool: how it is declared to the LLM, how its calls are
+// parsed, and how the agent executes them. Keeping all three together means a tool
+// can't be declared without a parser or parsed without a handler.
+type toolSpec struct {
+	tool    func() llm.Tool
+	parse   func(call *llm.FunctionCall) ToolRequest
+	execute func(a *Agent, ctx context.Context, op ToolRequest, toolCallID string) (interface{}, error)
+}
+
+// newToolSpec builds a toolSpec from a typed parser and handler
+func newToolSpec[R ToolRequest](
+	tool func() llm.Tool,
+	parse func(call *llm.FunctionCall) R,
+	execute func(a *Agent, ctx context.Context, op R, toolCallID string) (interface{}, error),
+) toolSpec {
+	return toolSpec{
+		tool:  tool,
+		parse: func(call *llm.FunctionCall) ToolRequest { return parse(call) },
+		execute: func(a *Agent, ctx context.Context, op ToolRequest, toolCallID string) (interface{}, error) {
+			typed, ok := op.(R)
+			if !ok {
+				return nil, fmt.Errorf("tool '%s' received unexpected request type %T", op.ToolName(), op)
+			}
+			return execute(a, ctx, typed, toolCallID)
+		},
+	}
+}
+
+// toolRegistry maps each tool name to its spec
+var toolRegistry = map[string]toolSpec{
+	"create_shape":               newToolSpec(createShapeTool, parseCreateShapeRequest, (*Agent).executeCreateShape),
+	"update_shape":               newToolSpec(updateShapeTool, parseUpdateShapeRequest, (*Agent).executeUpdateShape),
+	"revert_shape":               newToolSpec(revertShapeTool, parseRevertShapeRequest, (*Agent).executeRevertShape),
+	"remove_shape":               newToolSpec(removeShapeTool, parseRemoveShapeRequest, (*Agent).executeRemoveShape),
+	"array_shapes":               newToolSpec(arrayShapesTool, parseArrayShapesRequest, (*Agent).executeArrayShapes),
+	"snap_shapes":                newToolSpec(snapShapesTool, parseSnapShapesRequest, (*Agent).executeSnapShapes),
+	"rename_shapes":              newToolSpec(renameShapesTool, parseRenameShapesRequest, (*Agent).executeRenameShapes),
+	"remove_shapes":              newToolSpec(removeShapesTool, parseRemoveShapesRequest, (*Agent).executeRemoveShapes),
+	"export_shape":               newToolSpec(exportShapeTool, parseExportShapeRequest, (*Agent).executeExportShape),
+	"import_shape":               newToolSpec(importShapeTool, parseImportShapeRequest, (*Agent).executeImportShape),
+	"import_dsl":                 newToolSpec(importDSLTool, parseImportDSLRequest, (*Agent).executeImportDSL),
+	"create_light":               newToolSpec(createLightTool, parseCreateLightRequest, (*Agent).executeCreateLight),
+	"light_shape":                newToolSpec(lightShapeTool, parseLightShapeRequest, (*Agent).executeLightShape),
+	"setup_three_point_lighting": newToolSpec(setupThreePointLightingTool, parseSetupThreePointLightingRequest, (*Agent).executeSetupThreePointLighting),
+	"update_light":               newToolSpec(updateLightTool, parseUpdateLightRequest, (*Agent).executeUpdateLight),
+	"remove_light":               newToolSpec(removeLightTool, parseRemoveLightRequest, (*Agent).executeRemoveLight),
+	"get_light":                  newToolSpec(getLightTool, parseGetLightRequest, (*Agent).executeGetLight),
+	"set_light_enabled":          newToolSpec(setLightEnabledTool, parseSetLightEnabledRequest, (*Agent).executeSetLightEnabled),
+	"solo_light":                 newToolSpec(soloLightTool, parseSoloLightRequest, (*Agent).executeSoloLight),
+	"unsolo_light":               newToolSpec(unsoloLightTool, parseUnsoloLightRequest, (*Agent).executeUnsoloLight),
+	"set_environment_lighting":   newToolSpec(setEnvironmentLightingTool, parseSetEnvironmentLightingRequest, (*Agent).executeSetEnvironmentLighting),
+	"set_camera":                 newToolSpec(setCameraTool, parseSetCameraRequest, (*Agent).executeSetCamera),
+	"get_camera":                 newToolSpec(getCameraTool, parseGetCameraRequest, (*Agent).executeGetCamera),
+	"add_camera":                 newToolSpec(addCameraTool, parseAddCameraRequest, (*Agent).executeAddCamera),
+	"select_camera":              newToolSpec(selectCameraTool, parseSelectCameraRequest, (*Agent).executeSelectCamera),
+	"remove_camera":              newToolSpec(removeCameraTool, parseRemoveCameraRequest, (*Agent).executeRemoveCamera),
+	"zoom_camera":                newToolSpec(zoomCameraTool, parseZoomCameraRequest, (*Agent).executeZoomCamera),
+	"set_camera_preset":          newToolSpec(setCameraPresetTool, parseSetCameraPresetRequest, (*Agent).executeSetCameraPreset),
+	"frame_shape":                newToolSpec(frameShapeTool, parseFrameShapeRequest, (*Agent).executeFrameShape),
+	"set_aspect_ratio":           newToolSpec(setAspectRatioTool, parseSetAspectRatioRequest, (*Agent).executeSetAspectRatio),
+	"render_scene":               newToolSpec(renderSceneTool, parseRenderSceneRequest, (*Agent).executeRenderScene),
+	"preview_material":           newToolSpec(previewMaterialTool, parsePreviewMaterialRequest, (*Agent).executePreviewMaterial),
+	"set_default_material":       newToolSpec(setDefaultMaterialTool, parseSetDefaultMaterialRequest, (*Agent).executeSetDefaultMaterial),
+	"set_render_quality":         newToolSpec(setRenderQualityTool, parseSetRenderQualityRequest, (*Agent).executeSetRenderQuality),
+	"set_post_process":           newToolSpec(setPostProcessTool, parseSetPostProcessRequest, (*Agent).executeSetPostProcess),
+	"get_scene_state":            newToolSpec(getSceneStateTool, parseGetSceneStateRequest, (*Agent).executeGetSceneState),
+	"get_scene_statistics":       newToolSpec(getSceneStatisticsTool, parseGetSceneStatisticsRequest, (*Agent).executeGetSceneStatistics),
+	"checkpoint_scene":           newToolSpec(checkpointSceneTool, parseCheckpointSceneRequest, (*Agent).executeCheckpointScene),
+	"restore_checkpoint":         newToolSpec(restoreCheckpointTool, parseRestoreCheckpointRequest, (*Agent).executeRestoreCheckpoint),
+	"list_checkpoints":           newToolSpec(listCheckpointsTool, parseListCheckpointsRequest, (*Agent).executeListCheckpoints),
+	"find_shapes_by_tag":         newToolSpec(findShapesByTagTool, parseFindShapesByTagRequest, (*Agent).executeFindShapesByTag),
+	"scene_is_empty":             newToolSpec(sceneIsEmptyTool, parseSceneIsEmptyRequest, (*Agent).executeSceneIsEmpty),
+	"is_point_occupied":          newToolSpec(isPointOccupiedTool, parseIsPointOccupiedRequest, (*Agent).executeIsPointOccupied),
+	"check_overlap":              newToolSpec(checkOverlapTool, parseCheckOverlapRequest, (*Agent).executeCheckOverlap),
+	"measure_distance":           newToolSpec(measureDistanceTool, parseMeasureDistanceRequest, (*Agent).executeMeasureDistance),
+	"validate_shape":             newToolSpec(validateShapeTool, parseValidateShapeRequest, (*Agent).executeValidateShape),
+	"validate_light":             newToolSpec(validateLightTool, parseValidateLightRequest, (*Agent).executeValidateLight),
+	"done":                       newToolSpec(doneTool, parseDoneRequest, (*Agent).executeDone),
+}
+
+// toolOrder lists the registered tools in the order they are offered to the LLM
+var toolOrder = []string{
+	"create_shape",
+	"update_shape",
+	"revert_shape",
+	"remove_shape",
+	"remove_shapes",
+	"rename_shapes",
+	"array_shapes",
+	"snap_shapes",
+	"export_shape",
+	"import_shape",
+	"import_dsl",
+	"create_light",
+	"light_shape",
+	"setup_three_point_lighting",
+	"update_light",
+	"remove_light",
+	"get_light",
+	"set_light_enabled",
+	"solo_light",
+	"unsolo_light",
+	"set_environment_lighting",
+	"set_camera",
+	"get_camera",
+	"add_camera",
+	"select_camera",
+	"remove_camera",
+	"zoom_camera",
+	"set_camera_preset",
+	"frame_shape",
+	"set_aspect_ratio",
+	"render_scene",
+	"preview_material",
+	"set_default_material",
+	"set_render_quality",
+	"set_post_process",
+	"get_scene_state",
+	"get_scene_statistics",
+	"checkpoint_scene",
+	"restore_checkpoint",
+	"list_checkpoints",
+	"find_shapes_by_tag",
+	"scene_is_empty",
+	"is_point_occupied",
+	"check_overlap",
+	"measure_distance",
+	"validate_shape",
+	"validate_light",
+	"done",
 }
 
 // getAllTools returns all available tool declarations in provider-agnostic format
 func getAllTools() []llm.Tool {
-	return []llm.Tool{
-		createShapeTool(),
-		updateShapeTool(),
-		removeShapeTool(),
-		createLightTool(),
-		updateLightTool(),
-		removeLightTool(),
-		setEnvironmentLightingTool(),
-		setCameraTool(),
-		renderSceneTool(),
-		getSceneStateTool(),
+	tools := make([]llm.Tool, 0, len(toolOrder))
+	for _, name := range toolOrder {
+		tools = append(tools, toolRegistry[name].tool())
+	}
+	return tools
+}
+
+// ToolDefinitions returns every tool the agent can offer, for clients that need to discover them
+func ToolDefinitions() []llm.Tool {
+	return getAllTools()
+}
+
+// getToolsForProvider returns the tools to offer a model
+// render_scene is omitted when the model can't see the images it returns, and its file output
+// parameters are omitted when the agent has no output directory to write to.
+func getToolsForProvider(supportsVision, canWriteFiles bool) []llm.Tool {
+	tools := getAllTools()
+	if !canWriteFiles {
+		for _, tool := range tools {
+			if tool.Name == "render_scene" {
+				for _, name := range renderOutputParameters {
+					delete(tool.Parameters.Properties, name)
+				}
+			}
+		}
+	}
+	if supportsVision {
+		return tools
+	}
+
+	filtered := make([]llm.Tool, 0, len(tools))
+	for _, tool := range tools {
+		if tool.Name != "render_scene" && tool.Name != "preview_material" {
+			filtered = append(filtered, tool)
+		}
 	}
+	return filtered
 }
 
+// renderOutputParameters are the render_scene parameters that only apply when writing a file
+var renderOutputParameters = []string{"output_path", "image_format", "image_quality"}
+
+// getAllToolDeclarations returns every tool as a Gemini function declaration
+// Derived from getAllTools so the two lists can't drift apart.
 // Deprecated: Use getAllTools() instead. Kept for backwards compatibility during migration.
 func getAllToolDeclarations() []*genai.FunctionDeclaration {
-	return []*genai.FunctionDeclaration{
-		createShapeToolDeclaration(),
-		updateShapeToolDeclaration(),
-		removeShapeToolDeclaration(),
-		createLightToolDeclaration(),
-		updateLightToolDeclaration(),
-		removeLightToolDeclaration(),
-		setEnvironmentLightingToolDeclaration(),
-		setCameraToolDeclaration(),
-		renderSceneToolDeclaration(),
-		getSceneStateToolDeclaration(),
-	}
+	return gemini.FromInternalTools(getAllTools())
 }
 
 // ------------------------------------------------------------
@@ -151,12 +571,12 @@ func createShapeTool() llm.Tool {
 				},
 				"type": {
 					Type:        llm.TypeString,
-					Enum:        []string{"sphere", "box", "quad", "disc", "cylinder", "cone"},
+					Enum:        []string{"sphere", "ellipsoid", "box", "pyramid", "quad", "disc", "cylinder", "cone"},
 					Description: "The type of shape to create",
 				},
 				"properties": {
 					Type:        llm.TypeObject,
-					Description: "Shape-specific properties including optional material. For sphere: {center: [x,y,z], radius: number, material?: {...}}. For box: {center: [x,y,z], dimensions: [w,h,d], rotation?: [x,y,z], material?: {...}}. For quad: {corner: [x,y,z], u: [x,y,z], v: [x,y,z], material?: {...}}. For disc: {center: [x,y,z], normal: [x,y,z], radius: number, material?: {...}}. For cylinder: {base_center: [x,y,z], top_center: [x,y,z], radius: number, capped: bool, material?: {...}}. For cone: {base_center: [x,y,z], base_radius: number, top_center: [x,y,z], top_radius: number (0 for pointed cone, >0 for frustum), capped: bool, material?: {...}}. Material defaults to gray lambertian if not specified. Materials: Lambertian {type: 'lambertian', albedo: [r,g,b]}, Metal {type: 'metal', albedo: [r,g,b], fuzz: 0.0-1.0}, Dielectric {type: 'dielectric', refractive_index: number (1.0=air, 1.33=water, 1.5=glass, 2.4=diamond)}",
+					Description: "Shape-specific properties including optional material. For sphere: {center: [x,y,z], radius: number, rotation?: [x,y,z] (radians; stored with the shape but not yet used when rendering), material?: {...}}. For ellipsoid (eggs, lozenges, squashed spheres): {center: [x,y,z], radii: [rx,ry,rz] (all positive, along the x, y and z axes), material?: {...}}. For box: {center: [x,y,z], dimensions: [w,h,d], rotation?: [x,y,z], material?: {...}}. For pyramid (roofs, obelisks): {center: [x,y,z] (middle of the base, which lies flat in the XZ plane), base_size: [w,d], height: number (apex straight above center), material?: {...}}. For quad: {corner: [x,y,z], u: [x,y,z], v: [x,y,z], material?: {...}}. For disc: {center: [x,y,z], normal: [x,y,z], radius: number, material?: {...}}. For cylinder: {base_center: [x,y,z], top_center: [x,y,z], radius: number, capped: bool, material?: {...}}. For cone: {base_center: [x,y,z], base_radius: number, top_center: [x,y,z], top_radius: number (0 for pointed cone, >0 for frustum), capped: bool, material?: {...}}. Any shape also accepts opacity?: 0.0-1.0 (default 1): below 1, that share of light passes straight through the surface without bending or taking on its color, for faint see-through surfaces like gauze, mist or a ghost (the color comes only from the opaque share, so low opacity looks pale, and surfaces seen edge-on still reflect a little). Use dielectric instead for glass and water, which refract. Any shape's color or material albedo (including inside a mix) can be the string 'random' for a distinct, pleasant color chosen for you; the same sequence of calls gets the same colors, and the chosen values are stored, so use this for varied objects like a bowl of candies. Any shape can also be annotated with tags?: [string] (labels like 'snowman' or 'head', searchable with find_shapes_by_tag) and description?: string; these don't affect rendering and are kept when the shape is updated. Material defaults to gray lambertian if not specified. Materials: Lambertian {type: 'lambertian', albedo: [r,g,b]}, Metal {type: 'metal', albedo: [r,g,b], fuzz: 0.0-1.0}, Dielectric {type: 'dielectric', refractive_index: number (1.0=air, 1.33=water, 1.5=glass, 2.4=diamond)}, Mix {type: 'mix', material_a: {...}, material_b: {...}, factor: 0.0-1.0 (0=all material_a, 1=all material_b)} for blended surfaces like wet or partially metallic materials (mixes can nest up to 3 levels). Shadow catcher {type: 'shadow_catcher'} (quads only, no other fields) is meant for a ground-plane quad when compositing over a photo: it renders transparent where lit and darkens where other shapes cast shadows on it. Instead of choosing parameters, a material can name a preset: {preset: 'gold' | 'copper' | 'chrome' | 'glass' | 'plastic'}. Other fields override the preset's values, e.g. {preset: 'plastic', albedo: [0.8, 0.1, 0.1]} for red plastic or {preset: 'gold', fuzz: 0.3} for brushed gold.",
 				},
 			},
 			Required: []string{"id", "type", "properties"},
@@ -185,6 +605,23 @@ func updateShapeTool() llm.Tool {
 	}
 }
 
+func revertShapeTool() llm.Tool {
+	return llm.Tool{
+		Name:        "revert_shape",
+		Description: "Undo the last update_shape on a shape, restoring its previous properties (and its previous ID if the update renamed it). Only the most recent change is kept per shape, so reverting twice in a row fails. Use this when a change made a shape look worse and you want to go back rather than guess the old values.",
+		Parameters: &llm.Schema{
+			Type: llm.TypeObject,
+			Properties: map[string]*llm.Schema{
+				"id": {
+					Type:        llm.TypeString,
+					Description: "Current ID of the shape to revert",
+				},
+			},
+			Required: []string{"id"},
+		},
+	}
+}
+
 func removeShapeTool() llm.Tool {
 	return llm.Tool{
 		Name:        "remove_shape",
@@ -202,204 +639,263 @@ func removeShapeTool() llm.Tool {
 	}
 }
 
-func createLightTool() llm.Tool {
+func exportShapeTool() llm.Tool {
 	return llm.Tool{
-		Name:        "create_light",
-		Description: "Create a light source in the scene",
+		Name:        "export_shape",
+		Description: "Export a shape as a JSON snippet {type, properties}, without its ID, that import_shape can add to any scene. Use this when the user wants to save or share a single object; show them the snippet so they can paste it into another session.",
 		Parameters: &llm.Schema{
 			Type: llm.TypeObject,
 			Properties: map[string]*llm.Schema{
 				"id": {
 					Type:        llm.TypeString,
-					Description: "Unique identifier for the light",
+					Description: "Identifier of the shape to export",
 				},
-				"type": {
+			},
+			Required: []string{"id"},
+		},
+	}
+}
+
+func importShapeTool() llm.Tool {
+	return llm.Tool{
+		Name:        "import_shape",
+		Description: "Add a shape from a JSON snippet made by export_shape, under a new ID. The shape is validated like create_shape, so the snippet can come from another session or the user. Returns the created shape.",
+		Parameters: &llm.Schema{
+			Type: llm.TypeObject,
+			Properties: map[string]*llm.Schema{
+				"snippet": {
 					Type:        llm.TypeString,
-					Enum:        []string{"point_spot_light", "area_quad_light", "disc_spot_light", "area_sphere_light", "area_disc_spot_light"},
-					Description: "Type of light source",
+					Description: "The shape's JSON, e.g. '{\"type\":\"sphere\",\"properties\":{\"center\":[0,1,0],\"radius\":1}}'",
 				},
-				"properties": {
-					Type:        llm.TypeObject,
-					Description: "Light-specific properties. All lights need emission: [r,g,b]. Point lights: {center: [x,y,z], emission: [r,g,b]}. Area lights include size/shape properties.",
+				"new_id": {
+					Type:        llm.TypeString,
+					Description: "Unique identifier for the imported shape",
 				},
 			},
-			Required: []string{"id", "type", "properties"},
+			Required: []string{"snippet", "new_id"},
 		},
 	}
 }
 
-func updateLightTool() llm.Tool {
+func importDSLTool() llm.Tool {
 	return llm.Tool{
-		Name:        "update_light",
-		Description: "Update an existing light by ID. Can update the light's ID, type, or any properties like emission, position, size, etc.",
+		Name:        "import_dsl",
+		Description: "Add several shapes at once from a compact text format, one shape per line: the shape type, a unique ID, then words in any order. Keywords take a value, with vectors written as comma-separated numbers: sphere 'at x,y,z r R'; ellipsoid 'at', 'radii a,b,c'; box 'at', 'size w,h,d'; pyramid 'at', 'base w,d', 'height h'; quad 'corner', 'u', 'v'; disc 'at', 'normal', 'r'; cylinder 'from x,y,z to x,y,z r R' plus 'open' for no caps; cone 'from', 'to', 'r' (base radius), 'top' (top radius, default 0), 'open'. Color: a name (red, orange, yellow, green, cyan, blue, purple, pink, brown, white, gray, black), 'random', or 'color r,g,b'. Material: matte, metal (with optional 'fuzz f'), or a preset (gold, copper, chrome, glass, plastic). Lines starting with '#' are comments. Example: 'sphere red_ball at 0,1,0 r 1 red metal'. All lines are validated first and every bad line is reported by number; either all shapes are added or none are. Returns the created IDs.",
 		Parameters: &llm.Schema{
 			Type: llm.TypeObject,
 			Properties: map[string]*llm.Schema{
-				"id": {
+				"dsl": {
 					Type:        llm.TypeString,
-					Description: "ID of the light to update",
-				},
-				"updates": {
-					Type:        llm.TypeObject,
-					Description: "Object containing fields to update. Examples: {\"id\": \"new_name\"} to rename, {\"properties\": {\"emission\": [2.0, 1.0, 0.5]}} to change emission to warm orange, {\"properties\": {\"center\": [1, 2, 3]}} to move light. Only specified fields will be updated.",
+					Description: "The shapes, one per line, e.g. 'box table at 0,0.5,0 size 2,1,1 brown' on one line and 'sphere ball at 0,1.5,0 r 0.5 glass' on the next",
 				},
 			},
-			Required: []string{"id", "updates"},
+			Required: []string{"dsl"},
 		},
 	}
 }
 
-func removeLightTool() llm.Tool {
+func removeShapesTool() llm.Tool {
 	return llm.Tool{
-		Name:        "remove_light",
-		Description: "Remove a light from the scene by ID",
+		Name:        "remove_shapes",
+		Description: "Remove several shapes from the scene in one call. Each ID is removed independently, so a missing ID doesn't stop the others; the result reports 'removed' or an error for each ID.",
 		Parameters: &llm.Schema{
 			Type: llm.TypeObject,
 			Properties: map[string]*llm.Schema{
-				"id": {
-					Type:        llm.TypeString,
-					Description: "Identifier of the light to remove",
+				"ids": {
+					Type:        llm.TypeArray,
+					Description: "Identifiers of the shapes to remove",
+					Items:       &llm.Schema{Type: llm.TypeString},
 				},
 			},
-			Required: []string{"id"},
+			Required: []string{"ids"},
 		},
 	}
 }
 
-func setEnvironmentLightingTool() llm.Tool {
+func renameShapesTool() llm.Tool {
 	return llm.Tool{
-		Name:        "set_environment_lighting",
-		Description: "Set the background/environment lighting for the scene. This replaces any existing environment lighting.",
+		Name:        "rename_shapes",
+		Description: "Rename several shapes in one call, e.g. to tidy IDs after importing or arraying. Either give mapping {old_id: new_id}, or give ids with a prefix and/or suffix to add to each (e.g. ids ['leg_1', 'leg_2'] with prefix 'table_'). Every new ID is checked before anything is renamed, so all shapes are renamed or none are; swapping two IDs is allowed. Returns the renames applied.",
 		Parameters: &llm.Schema{
 			Type: llm.TypeObject,
 			Properties: map[string]*llm.Schema{
-				"type": {
-					Type:        llm.TypeString,
-					Enum:        []string{"gradient", "uniform", "none"},
-					Description: "Type of environment lighting",
+				"mapping": {
+					Type:        llm.TypeObject,
+					Description: "Old shape ID -> new shape ID, e.g. {\"sphere_1\": \"moon\"}. Not combined with ids, prefix or suffix.",
 				},
-				"top_color": {
+				"ids": {
 					Type:        llm.TypeArray,
-					Items:       &llm.Schema{Type: llm.TypeNumber},
-					Description: "RGB color for gradient top/zenith [r,g,b] (0.0-10.0+). Required for gradient type.",
+					Description: "Shapes to rename with prefix and suffix",
+					Items:       &llm.Schema{Type: llm.TypeString},
 				},
-				"bottom_color": {
-					Type:        llm.TypeArray,
-					Items:       &llm.Schema{Type: llm.TypeNumber},
-					Description: "RGB color for gradient bottom/horizon [r,g,b] (0.0-10.0+). Required for gradient type.",
+				"prefix": {
+					Type:        llm.TypeString,
+					Description: "Text added to the start of each ID in ids",
 				},
-				"emission": {
-					Type:        llm.TypeArray,
-					Items:       &llm.Schema{Type: llm.TypeNumber},
-					Description: "RGB emission color [r,g,b] (0.0-10.0+). Required for uniform type.",
+				"suffix": {
+					Type:        llm.TypeString,
+					Description: "Text added to the end of each ID in ids",
 				},
 			},
-			Required: []string{"type"},
+			Required: []string{},
 		},
 	}
 }
 
-func setCameraTool() llm.Tool {
+func arrayShapesTool() llm.Tool {
 	return llm.Tool{
-		Name:        "set_camera",
-		Description: "Set camera position and properties for viewing the scene",
+		Name:        "array_shapes",
+		Description: "Fill a grid with copies of an existing shape, e.g. floor tiles or a wall of bricks. The source shape is cell (0,0,0); each other cell gets a copy offset by its index times spacing, with ID '<source_id>_<i>_<j>_<k>'. Copies keep the source's size and material. Returns the created IDs. All copies are added or none are, and the scene is limited to " + strconv.Itoa(MaxShapes) + " shapes.",
 		Parameters: &llm.Schema{
 			Type: llm.TypeObject,
 			Properties: map[string]*llm.Schema{
-				"center": {
+				"source_id": {
+					Type:        llm.TypeString,
+					Description: "ID of the shape to copy",
+				},
+				"counts": {
 					Type:        llm.TypeArray,
-					Items:       &llm.Schema{Type: llm.TypeNumber},
-					Description: "Camera position as [x, y, z]",
+					Description: "Number of cells along x, y, and z as [nx, ny, nz], each at least 1 (e.g. [5, 1, 5] for a 5x5 floor)",
+					Items:       &llm.Schema{Type: llm.TypeInteger},
 				},
-				"look_at": {
+				"spacing": {
 					Type:        llm.TypeArray,
+					Description: "Distance between cell centers along x, y, and z as [dx, dy, dz]",
 					Items:       &llm.Schema{Type: llm.TypeNumber},
-					Description: "Point the camera looks at as [x, y, z]",
-				},
-				"vfov": {
-					Type:        llm.TypeNumber,
-					Description: "Vertical field of view in degrees (default: 45.0)",
-				},
-				"aperture": {
-					Type:        llm.TypeNumber,
-					Description: "Lens aperture for depth of field effect (0.0 = no blur, default: 0.0)",
 				},
 			},
-			Required: []string{"center", "look_at"},
+			Required: []string{"source_id", "counts", "spacing"},
 		},
 	}
 }
 
-func renderSceneTool() llm.Tool {
+func snapShapesTool() llm.Tool {
 	return llm.Tool{
-		Name:        "render_scene",
-		Description: "Render the scene at 400x300 resolution with 500 samples to visually verify the result. Returns a PNG image that you can analyze to check colors, materials, lighting, and composition. Use this to verify your work meets the user's request before providing final response. This is expensive (~3-5 seconds), so use strategically.",
+		Name:        "snap_shapes",
+		Description: "Move a shape in a direction until it just touches another, e.g. push a box up against a sphere or drop a vase onto a table with direction [0, -1, 0]. Contact is between bounding boxes, so curved shapes can end up slightly apart. A mover that already overlaps the target backs up until they only touch. Fails if the mover would pass beside the target or the target is behind it. Returns the mover's new position properties.",
 		Parameters: &llm.Schema{
-			Type:       llm.TypeObject,
-			Properties: map[string]*llm.Schema{},
-			Required:   []string{},
+			Type: llm.TypeObject,
+			Properties: map[string]*llm.Schema{
+				"mover_id": {
+					Type:        llm.TypeString,
+					Description: "ID of the shape to move",
+				},
+				"target_id": {
+					Type:        llm.TypeString,
+					Description: "ID of the shape to move it against; it stays where it is",
+				},
+				"direction": {
+					Type:        llm.TypeArray,
+					Description: "Direction to move the mover in as [x, y, z], non-zero; usually toward the target, e.g. [1, 0, 0] to push it along +x",
+					Items:       &llm.Schema{Type: llm.TypeNumber},
+				},
+			},
+			Required: []string{"mover_id", "target_id", "direction"},
 		},
 	}
 }
 
-func getSceneStateTool() llm.Tool {
+func createLightTool() llm.Tool {
 	return llm.Tool{
-		Name:        "get_scene_state",
-		Description: "Get the complete current scene state including all shapes, lights, camera, and environment lighting. Use this when you need to check what's currently in the scene.",
-		Parameters: &llm.Schema{
-			Type:       llm.TypeObject,
-			Properties: map[string]*llm.Schema{},
-			Required:   []string{},
+		Name:        "create_light",
+		Description: "Create a light source in the scene",
+		Parameters: &llm.Schema{
+			Type: llm.TypeObject,
+			Properties: map[string]*llm.Schema{
+				"id": {
+					Type:        llm.TypeString,
+					Description: "Unique identifier for the light",
+				},
+				"type": {
+					Type:        llm.TypeString,
+					Enum:        []string{"point_light", "point_spot_light", "area_quad_light", "area_rect_light", "disc_spot_light", "area_sphere_light", "area_disc_spot_light"},
+					Description: "Type of light source",
+				},
+				"properties": {
+					Type:        llm.TypeObject,
+					Description: "Light-specific properties. All lights need emission: [r,g,b]. Point lights (point_light) shine equally in every direction: {center: [x,y,z], emission: [r,g,b]}. Spot lights (point_spot_light) shine in a cone: {center: [x,y,z], emission: [r,g,b], direction?: [x,y,z] (default straight down), cutoff_angle?: degrees (default 45), falloff_exponent?: number (default 5)}. For a soft-edged spot, give inner_angle and outer_angle (degrees, inner_angle < outer_angle <= 180) instead of cutoff_angle and falloff_exponent: full brightness inside inner_angle fading to zero at outer_angle. Area lights include size/shape properties. Area disc spot lights (area_disc_spot_light): {center: [x,y,z], normal: [x,y,z], radius: number, emission: [r,g,b], cutoff_angle: degrees, falloff_exponent: number}, or inner_angle and outer_angle in place of cutoff_angle and falloff_exponent. Area quad lights: {corner: [x,y,z], u: [x,y,z], v: [x,y,z], emission: [r,g,b], two_sided?: bool} emit only toward u×v (check light_emission_normals in get_scene_state) unless two_sided is true. Area rect lights (area_rect_light) are quad lights placed by their middle: {center: [x,y,z], size: [width, height], normal: [x,y,z], emission: [r,g,b], two_sided?: bool} emit toward normal, e.g. normal [0,-1,0] for a ceiling panel. Area lights (quad, rect, sphere and disc lights) accept power_mode?: 'radiance' (default, emission is the light leaving each unit of surface, so bigger lights are brighter overall) or 'total_power' (emission is the light's total output, spread over its area, so resizing keeps its brightness). Any light accepts enabled?: bool (default true); disabled lights are kept but don't light the scene.",
+				},
+			},
+			Required: []string{"id", "type", "properties"},
 		},
 	}
 }
 
-// ------------------------------------------------------------
-// Deprecated genai-based tool declarations
-// ------------------------------------------------------------
-
-// createShapeToolDeclaration returns the function declaration for shape creation
-func createShapeToolDeclaration() *genai.FunctionDeclaration {
-	return &genai.FunctionDeclaration{
-		Name:        "create_shape",
-		Description: "Create a 3D shape in the scene with a unique ID",
-		Parameters: &genai.Schema{
-			Type: genai.TypeObject,
-			Properties: map[string]*genai.Schema{
+func lightShapeTool() llm.Tool {
+	return llm.Tool{
+		Name:        "light_shape",
+		Description: "Add a light that shines on a shape from a given direction, e.g. 'light the sphere from above' is direction [0, 1, 0]. The light is placed distance away from the shape's center along direction, and a spot light is aimed back at the center. Call it several times for key, fill and rim lights. Returns the created light, which update_light can adjust.",
+		Parameters: &llm.Schema{
+			Type: llm.TypeObject,
+			Properties: map[string]*llm.Schema{
 				"id": {
-					Type:        genai.TypeString,
-					Description: "Unique identifier for the shape (e.g., 'blue_sphere', 'main_building')",
+					Type:        llm.TypeString,
+					Description: "ID of the shape to light",
 				},
-				"type": {
-					Type:        genai.TypeString,
-					Enum:        []string{"sphere", "box", "quad", "disc", "cylinder", "cone"},
-					Description: "The type of shape to create",
+				"direction": {
+					Type:        llm.TypeArray,
+					Description: "Direction the light comes from, pointing from the shape toward the light as [x, y, z], e.g. [0, 1, 0] for above or [-1, 1, 1] for upper left front. Must be non-zero; its length doesn't matter.",
+					Items:       &llm.Schema{Type: llm.TypeNumber},
 				},
-				"properties": {
-					Type:        genai.TypeObject,
-					Description: "Shape-specific properties including optional material. For sphere: {center: [x,y,z], radius: number, material?: {...}}. For box: {center: [x,y,z], dimensions: [w,h,d], rotation?: [x,y,z], material?: {...}}. For quad: {corner: [x,y,z], u: [x,y,z], v: [x,y,z], material?: {...}}. For disc: {center: [x,y,z], normal: [x,y,z], radius: number, material?: {...}}. For cylinder: {base_center: [x,y,z], top_center: [x,y,z], radius: number, capped: bool, material?: {...}}. For cone: {base_center: [x,y,z], base_radius: number, top_center: [x,y,z], top_radius: number (0 for pointed cone, >0 for frustum), capped: bool, material?: {...}}. Material defaults to gray lambertian if not specified. Materials: Lambertian {type: 'lambertian', albedo: [r,g,b]}, Metal {type: 'metal', albedo: [r,g,b], fuzz: 0.0-1.0}, Dielectric {type: 'dielectric', refractive_index: number (1.0=air, 1.33=water, 1.5=glass, 2.4=diamond)}",
+				"distance": {
+					Type:        llm.TypeNumber,
+					Description: "Distance from the shape's center to the light; must be positive and should clear the shape's surface",
+				},
+				"emission": {
+					Type:        llm.TypeArray,
+					Description: "Light color and intensity as [r, g, b]; values above 1 are brighter. Point lights fall off with distance, so farther lights need more.",
+					Items:       &llm.Schema{Type: llm.TypeNumber},
+				},
+				"light_type": {
+					Type:        llm.TypeString,
+					Enum:        []string{"point_spot_light", "point_light"},
+					Description: "point_spot_light (default) shines a cone at the shape; point_light shines in every direction",
+				},
+				"light_id": {
+					Type:        llm.TypeString,
+					Description: "ID for the new light (default '<shape id>_light', numbered if taken)",
 				},
 			},
-			Required: []string{"id", "type", "properties"},
+			Required: []string{"id", "direction", "distance", "emission"},
 		},
 	}
 }
 
-// updateShapeToolDeclaration returns the function declaration for shape updating
-func updateShapeToolDeclaration() *genai.FunctionDeclaration {
-	return &genai.FunctionDeclaration{
-		Name:        "update_shape",
-		Description: "Update an existing shape by ID. Can update the shape's ID, type, or any properties like color, position, size, etc.",
-		Parameters: &genai.Schema{
-			Type: genai.TypeObject,
-			Properties: map[string]*genai.Schema{
+func setupThreePointLightingTool() llm.Tool {
+	return llm.Tool{
+		Name:        "setup_three_point_lighting",
+		Description: "Light a shape, or the whole scene, like a studio portrait: a key light in front and to the camera's right, a fill light at half brightness on the left to soften shadows, and a rim light behind on the left to outline the subject. The spot lights are placed relative to the current camera, so set the camera first. Their IDs are key_light, fill_light and rim_light; lights already using those IDs are replaced, so calling it again after moving the camera re-aims them. Returns the created lights, which update_light can adjust.",
+		Parameters: &llm.Schema{
+			Type: llm.TypeObject,
+			Properties: map[string]*llm.Schema{
 				"id": {
-					Type:        genai.TypeString,
-					Description: "ID of the shape to update",
+					Type:        llm.TypeString,
+					Description: "ID of the shape to light (default: light the whole scene around its center)",
+				},
+				"intensity": {
+					Type:        llm.TypeNumber,
+					Description: "Brightness of the key light where it reaches the target, >= 0 (default " + strconv.FormatFloat(defaultThreePointIntensity, 'g', -1, 64) + "). It is the same however large the target is; the fill and rim lights are scaled to match.",
+				},
+			},
+			Required: []string{},
+		},
+	}
+}
+
+func updateLightTool() llm.Tool {
+	return llm.Tool{
+		Name:        "update_light",
+		Description: "Update an existing light by ID. Can update the light's ID, type, or any properties like emission, position, size, etc.",
+		Parameters: &llm.Schema{
+			Type: llm.TypeObject,
+			Properties: map[string]*llm.Schema{
+				"id": {
+					Type:        llm.TypeString,
+					Description: "ID of the light to update",
 				},
 				"updates": {
-					Type:        genai.TypeObject,
-					Description: "Object containing fields to update. Examples: {\"id\": \"new_name\"} to rename, {\"properties\": {\"position\": [1, 2, 3]}} to move shape, {\"properties\": {\"material\": {\"type\": \"metal\", \"albedo\": [0.9, 0.9, 0.9], \"fuzz\": 0.1}}} to make metallic, {\"properties\": {\"material\": {\"type\": \"dielectric\", \"refractive_index\": 1.5}}} to make glass. Only specified fields will be updated.",
+					Type:        llm.TypeObject,
+					Description: "Object containing fields to update. Examples: {\"id\": \"new_name\"} to rename, {\"properties\": {\"emission\": [2.0, 1.0, 0.5]}} to change emission to warm orange, {\"properties\": {\"center\": [1, 2, 3]}} to move light. Only specified fields will be updated.",
 				},
 			},
 			Required: []string{"id", "updates"},
@@ -407,17 +903,16 @@ func updateShapeToolDeclaration() *genai.FunctionDeclaration {
 	}
 }
 
-// removeShapeToolDeclaration returns the function declaration for shape removal
-func removeShapeToolDeclaration() *genai.FunctionDeclaration {
-	return &genai.FunctionDeclaration{
-		Name:        "remove_shape",
-		Description: "Remove a shape from the scene by its ID",
-		Parameters: &genai.Schema{
-			Type: genai.TypeObject,
-			Properties: map[string]*genai.Schema{
+func removeLightTool() llm.Tool {
+	return llm.Tool{
+		Name:        "remove_light",
+		Description: "Remove a light from the scene by ID",
+		Parameters: &llm.Schema{
+			Type: llm.TypeObject,
+			Properties: map[string]*llm.Schema{
 				"id": {
-					Type:        genai.TypeString,
-					Description: "ID of the shape to remove",
+					Type:        llm.TypeString,
+					Description: "Identifier of the light to remove",
 				},
 			},
 			Required: []string{"id"},
@@ -425,66 +920,124 @@ func removeShapeToolDeclaration() *genai.FunctionDeclaration {
 	}
 }
 
-// createLightToolDeclaration returns the function declaration for light creation
-func createLightToolDeclaration() *genai.FunctionDeclaration {
-	return &genai.FunctionDeclaration{
-		Name:        "create_light",
-		Description: "Create a positioned light in the scene with a unique ID",
-		Parameters: &genai.Schema{
-			Type: genai.TypeObject,
-			Properties: map[string]*genai.Schema{
+func getLightTool() llm.Tool {
+	return llm.Tool{
+		Name:        "get_light",
+		Description: "Get one light by ID: {light, defaults}. light is exactly as stored; defaults lists the values used for optional properties the light doesn't set (e.g. a spot light's direction, cutoff_angle, and falloff_exponent). Use this for targeted update_light edits instead of re-sending the whole light.",
+		Parameters: &llm.Schema{
+			Type: llm.TypeObject,
+			Properties: map[string]*llm.Schema{
 				"id": {
-					Type:        genai.TypeString,
-					Description: "Unique identifier for the light (e.g., 'main_light', 'ceiling_lamp')",
+					Type:        llm.TypeString,
+					Description: "Identifier of the light, including environment lights",
 				},
-				"type": {
-					Type:        genai.TypeString,
-					Enum:        []string{"point_spot_light", "area_quad_light", "disc_spot_light", "area_sphere_light", "area_disc_spot_light"},
-					Description: "The type of light to create",
+			},
+			Required: []string{"id"},
+		},
+	}
+}
+
+func setLightEnabledTool() llm.Tool {
+	return llm.Tool{
+		Name:        "set_light_enabled",
+		Description: "Switch a light on or off without removing it, e.g. to compare lighting setups. Disabled lights keep their settings and stay listed in get_scene_state with enabled: false, but don't light renders. If every light is off, renders fall back to the default sky gradient. Returns the updated light.",
+		Parameters: &llm.Schema{
+			Type: llm.TypeObject,
+			Properties: map[string]*llm.Schema{
+				"id": {
+					Type:        llm.TypeString,
+					Description: "Identifier of the light, including environment lights",
 				},
-				"properties": {
-					Type:        genai.TypeObject,
-					Description: "Light-specific properties. For point_spot_light: {center: [x,y,z], emission: [r,g,b], direction: [x,y,z] (optional), cutoff_angle: degrees (optional), falloff_exponent: number (optional)}. For area_quad_light: {corner: [x,y,z], u: [x,y,z], v: [x,y,z], emission: [r,g,b]}. For disc_spot_light: {center: [x,y,z], normal: [x,y,z], radius: number, emission: [r,g,b]}. For area_sphere_light: {center: [x,y,z], radius: number, emission: [r,g,b]}. For area_disc_spot_light: {center: [x,y,z], normal: [x,y,z], radius: number, emission: [r,g,b], cutoff_angle: degrees, falloff_exponent: number}",
+				"enabled": {
+					Type:        llm.TypeBoolean,
+					Description: "true to switch the light on, false to switch it off",
 				},
 			},
-			Required: []string{"id", "type", "properties"},
+			Required: []string{"id", "enabled"},
 		},
 	}
 }
 
-// setEnvironmentLightingToolDeclaration returns the function declaration for environment lighting
-func setEnvironmentLightingToolDeclaration() *genai.FunctionDeclaration {
-	return &genai.FunctionDeclaration{
+func soloLightTool() llm.Tool {
+	return llm.Tool{
+		Name:        "solo_light",
+		Description: "Render only one light, temporarily suppressing all others, to find which light causes an artifact, hot spot, or color cast. The other lights keep their settings and come back with unsolo_light. A soloed light renders even if it is disabled. Returns the IDs of the suppressed lights.",
+		Parameters: &llm.Schema{
+			Type: llm.TypeObject,
+			Properties: map[string]*llm.Schema{
+				"id": {
+					Type:        llm.TypeString,
+					Description: "Identifier of the light to render alone, including environment lights",
+				},
+			},
+			Required: []string{"id"},
+		},
+	}
+}
+
+func unsoloLightTool() llm.Tool {
+	return llm.Tool{
+		Name:        "unsolo_light",
+		Description: "End solo_light so every enabled light renders again.",
+		Parameters: &llm.Schema{
+			Type:       llm.TypeObject,
+			Properties: map[string]*llm.Schema{},
+			Required:   []string{},
+		},
+	}
+}
+
+func setEnvironmentLightingTool() llm.Tool {
+	return llm.Tool{
 		Name:        "set_environment_lighting",
-		Description: "Set the background/environment lighting for the scene. This replaces any existing environment lighting.",
-		Parameters: &genai.Schema{
-			Type: genai.TypeObject,
-			Properties: map[string]*genai.Schema{
+		Description: "Set the background/environment lighting for the scene. physical_sky simulates daylight from a sun direction: a sky gradient whose colors follow the sun's height, plus a bright sun disc that casts sharp shadows. daylight is a quick outdoor setup from sun_elevation and sun_azimuth: it creates a gradient sky light and a separate sun light ('daylight_sun') that update_light can adjust; the result lists the created light IDs. By default this replaces any existing environment lighting; set replace to false to stack lights, e.g. a gradient sky plus a dim uniform fill (at most one of each type).",
+		Parameters: &llm.Schema{
+			Type: llm.TypeObject,
+			Properties: map[string]*llm.Schema{
 				"type": {
-					Type:        genai.TypeString,
-					Enum:        []string{"gradient", "uniform", "none"},
+					Type:        llm.TypeString,
+					Enum:        []string{"gradient", "uniform", "physical_sky", "daylight", "none"},
 					Description: "Type of environment lighting",
 				},
 				"top_color": {
-					Type:        genai.TypeArray,
-					Description: "RGB color for gradient top/zenith [r,g,b] (0.0-10.0+). Required for gradient type.",
-					Items: &genai.Schema{
-						Type: genai.TypeNumber,
-					},
+					Type:        llm.TypeArray,
+					Items:       &llm.Schema{Type: llm.TypeNumber},
+					Description: "RGB color for gradient top/zenith [r,g,b] (0.0-10.0+). Required for gradient type; optional for daylight, with bottom_color, to override the sky colors.",
 				},
 				"bottom_color": {
-					Type:        genai.TypeArray,
-					Description: "RGB color for gradient bottom/horizon [r,g,b] (0.0-10.0+). Required for gradient type.",
-					Items: &genai.Schema{
-						Type: genai.TypeNumber,
-					},
+					Type:        llm.TypeArray,
+					Items:       &llm.Schema{Type: llm.TypeNumber},
+					Description: "RGB color for gradient bottom/horizon [r,g,b] (0.0-10.0+). Required for gradient type; optional for daylight, with top_color, to override the sky colors.",
 				},
 				"emission": {
-					Type:        genai.TypeArray,
+					Type:        llm.TypeArray,
+					Items:       &llm.Schema{Type: llm.TypeNumber},
 					Description: "RGB emission color [r,g,b] (0.0-10.0+). Required for uniform type.",
-					Items: &genai.Schema{
-						Type: genai.TypeNumber,
-					},
+				},
+				"sun_direction": {
+					Type:        llm.TypeArray,
+					Items:       &llm.Schema{Type: llm.TypeNumber},
+					Description: "Direction toward the sun [x,y,z], need not be normalized (e.g. [0,1,0] for noon overhead, [1,0.1,0] for a low evening sun in +x). Required for physical_sky type. The sun must be above the horizon (y > 0) to appear; lower suns give warmer, dimmer light.",
+				},
+				"sun_elevation": {
+					Type:        llm.TypeNumber,
+					Description: "Sun height for daylight in degrees above the horizon, greater than 0 and at most 90 (default 45). Low suns give long shadows and warm light.",
+				},
+				"sun_azimuth": {
+					Type:        llm.TypeNumber,
+					Description: "Sun compass direction for daylight in degrees, 0 to 360: 0 is +z (behind the default camera), 90 is +x, 180 is -z (backlight) (default 45).",
+				},
+				"turbidity": {
+					Type:        llm.TypeNumber,
+					Description: "Atmospheric haziness for physical_sky and daylight, from 2 (clear, deep blue sky) to 10 (hazy, washed out). Default 3.",
+				},
+				"intensity": {
+					Type:        llm.TypeNumber,
+					Description: "Brightness multiplier applied to the colors or emission (and the physical_sky sun), >= 0 (default 1.0). Keep colors in [0, 1] to set the hue and use intensity to make the environment brighter or dimmer, e.g. 0.3 for a dim fill.",
+				},
+				"replace": {
+					Type:        llm.TypeBoolean,
+					Description: "Whether to remove existing environment lighting first (default true). When false, the new light is added alongside existing ones; fails if one of the same type already exists. Ignored for type 'none'.",
 				},
 			},
 			Required: []string{"type"},
@@ -492,98 +1045,575 @@ func setEnvironmentLightingToolDeclaration() *genai.FunctionDeclaration {
 	}
 }
 
-// updateLightToolDeclaration returns the function declaration for light updating
-func updateLightToolDeclaration() *genai.FunctionDeclaration {
-	return &genai.FunctionDeclaration{
-		Name:        "update_light",
-		Description: "Update an existing light by ID. Can update the light's ID, type, or any properties like emission, position, size, etc.",
-		Parameters: &genai.Schema{
-			Type: genai.TypeObject,
-			Properties: map[string]*genai.Schema{
+func setCameraTool() llm.Tool {
+	return llm.Tool{
+		Name:        "set_camera",
+		Description: "Set camera position and properties for viewing the scene",
+		Parameters: &llm.Schema{
+			Type: llm.TypeObject,
+			Properties: map[string]*llm.Schema{
+				"center": {
+					Type:        llm.TypeArray,
+					Items:       &llm.Schema{Type: llm.TypeNumber},
+					Description: "Camera position as [x, y, z]",
+				},
+				"look_at": {
+					Type:        llm.TypeArray,
+					Items:       &llm.Schema{Type: llm.TypeNumber},
+					Description: "Point the camera looks at as [x, y, z]. Provide either look_at or look_direction.",
+				},
+				"look_direction": {
+					Type:        llm.TypeArray,
+					Items:       &llm.Schema{Type: llm.TypeNumber},
+					Description: "Direction to look along as [x, y, z], instead of look_at, e.g. [0, 0, -1] to look along -Z. Stored as look_at = center + look_direction, so its length sets the focus distance for depth of field.",
+				},
+				"vfov": {
+					Type:        llm.TypeNumber,
+					Description: "Vertical field of view in degrees (default: 45.0)",
+				},
+				"aperture": {
+					Type:        llm.TypeNumber,
+					Description: "Lens aperture for depth of field effect (0.0 = no blur, default: 0.0). Mutually exclusive with fstop.",
+				},
+				"fstop": {
+					Type:        llm.TypeNumber,
+					Description: "Aperture as a photographic f-number, e.g. 1.4 for a very shallow depth of field or 16 for nearly everything sharp. Converted to aperture using the focal length a full-frame camera would need for this vfov, treating scene units as meters. Mutually exclusive with aperture.",
+				},
+			},
+			Required: []string{"center"},
+		},
+	}
+}
+
+func addCameraTool() llm.Tool {
+	// Takes the same camera parameters as set_camera, plus a name
+	properties := map[string]*llm.Schema{
+		"id": {
+			Type:        llm.TypeString,
+			Description: "Name for the camera, e.g. 'closeup' or 'overhead'. The scene's original camera is named 'main'.",
+		},
+	}
+	for name, schema := range setCameraTool().Parameters.Properties {
+		properties[name] = schema
+	}
+
+	return llm.Tool{
+		Name:        "add_camera",
+		Description: "Add a named camera, for scenes viewed from several angles. The new camera is not active: use select_camera to render from it. Takes the same parameters as set_camera. Returns the stored camera and the names of all cameras.",
+		Parameters: &llm.Schema{
+			Type:       llm.TypeObject,
+			Properties: properties,
+			Required:   []string{"id", "center"},
+		},
+	}
+}
+
+func selectCameraTool() llm.Tool {
+	return llm.Tool{
+		Name:        "select_camera",
+		Description: "Make a named camera the active one. Renders, the preview, and the camera tools (set_camera, zoom_camera, frame_shape, ...) all use the active camera; the previously active camera keeps its settings and can be selected again. Returns the newly active camera.",
+		Parameters: &llm.Schema{
+			Type: llm.TypeObject,
+			Properties: map[string]*llm.Schema{
 				"id": {
-					Type:        genai.TypeString,
-					Description: "ID of the light to update",
+					Type:        llm.TypeString,
+					Description: "Name of the camera to activate",
 				},
-				"updates": {
-					Type:        genai.TypeObject,
-					Description: "Object containing fields to update. Examples: {\"id\": \"new_name\"} to rename, {\"properties\": {\"emission\": [2.0, 1.0, 0.5]}} to change emission to warm orange, {\"properties\": {\"center\": [1, 2, 3]}} to move light. Only specified fields will be updated.",
+			},
+			Required: []string{"id"},
+		},
+	}
+}
+
+func removeCameraTool() llm.Tool {
+	return llm.Tool{
+		Name:        "remove_camera",
+		Description: "Remove a named camera. The active camera can't be removed; select another one first.",
+		Parameters: &llm.Schema{
+			Type: llm.TypeObject,
+			Properties: map[string]*llm.Schema{
+				"id": {
+					Type:        llm.TypeString,
+					Description: "Name of the camera to remove",
+				},
+			},
+			Required: []string{"id"},
+		},
+	}
+}
+
+func checkpointSceneTool() llm.Tool {
+	return llm.Tool{
+		Name:        "checkpoint_scene",
+		Description: "Save the whole scene (shapes, lights, cameras and environment) under a label, like a named save point. Use it before a risky or experimental change so restore_checkpoint can bring the scene back. Checkpoints last for the session.",
+		Parameters: &llm.Schema{
+			Type: llm.TypeObject,
+			Properties: map[string]*llm.Schema{
+				"label": {
+					Type:        llm.TypeString,
+					Description: "Name for the checkpoint, e.g. 'before-lighting' or 'v1'",
+				},
+				"overwrite": {
+					Type:        llm.TypeBoolean,
+					Description: "Replace an existing checkpoint with the same label (default false, which fails instead)",
+				},
+			},
+			Required: []string{"label"},
+		},
+	}
+}
+
+func restoreCheckpointTool() llm.Tool {
+	return llm.Tool{
+		Name:        "restore_checkpoint",
+		Description: "Replace the whole scene with a checkpoint saved by checkpoint_scene. Changes made since are discarded unless checkpointed; the checkpoint itself is kept and can be restored again.",
+		Parameters: &llm.Schema{
+			Type: llm.TypeObject,
+			Properties: map[string]*llm.Schema{
+				"label": {
+					Type:        llm.TypeString,
+					Description: "Label of the checkpoint to restore",
+				},
+			},
+			Required: []string{"label"},
+		},
+	}
+}
+
+func listCheckpointsTool() llm.Tool {
+	return llm.Tool{
+		Name:        "list_checkpoints",
+		Description: "List the saved checkpoints, oldest first, with each one's shape and light counts",
+		Parameters: &llm.Schema{
+			Type:       llm.TypeObject,
+			Properties: map[string]*llm.Schema{},
+		},
+	}
+}
+
+func renderSceneTool() llm.Tool {
+	return llm.Tool{
+		Name:        "render_scene",
+		Description: "Render the scene at the quality chosen with set_render_quality (high, 500 samples, until you choose another) to visually verify the result. Returns a PNG image that you can analyze to check colors, materials, lighting, and composition. Use this to verify your work meets the user's request before providing final response. At high quality this is expensive (~3-5 seconds), so use strategically, or switch to preview or draft while iterating. Use mode 'wireframe' for a fast, noise-free outline of every shape when checking placement, overlap, or proportions.",
+		Parameters: &llm.Schema{
+			Type: llm.TypeObject,
+			Properties: map[string]*llm.Schema{
+				"mode": {
+					Type:        llm.TypeString,
+					Description: "Render mode: 'shaded' (default) path traces the scene; 'wireframe' draws only shape edges from the camera, colored by material, and is near-instant",
+					Enum:        []string{"shaded", "wireframe"},
+				},
+				"aov": {
+					Type:        llm.TypeString,
+					Description: "Output buffer for shaded mode: 'beauty' (default) is the final image; 'normal' maps surface normals to RGB (x->red, y->green, z->blue, so upward-facing surfaces look green); 'depth' shows distance from the camera as grayscale (near is bright, far is dark, background is black)",
+					Enum:        []string{"beauty", "normal", "depth"},
+				},
+				"output_path": {
+					Type:        llm.TypeString,
+					Description: "Also save the render at this path, relative to the configured output directory (e.g. 'renders/final.png' or 'renders/final.jpg'). The extension picks PNG or JPEG. The result includes the written path and its size in bytes.",
+				},
+				"image_format": {
+					Type:        llm.TypeString,
+					Description: "Format of the output_path file, which must match its extension (default: from the extension). JPEG files are much smaller but lossy. The image you see is always a PNG.",
+					Enum:        []string{ImageFormatPNG, ImageFormatJPEG},
+				},
+				"image_quality": {
+					Type:        llm.TypeInteger,
+					Description: "JPEG quality of the output_path file, from 1 to 100 (default 90). Ignored for PNG.",
+				},
+				"adaptive_min_samples": {
+					Type:        llm.TypeNumber,
+					Description: "Shaded beauty renders only. Fraction of the quality's samples every pixel takes before it may stop early, in (0, 1] (default 0.1). Lower is faster but noisier in dark or detailed areas; 1 disables early stopping.",
+				},
+				"adaptive_threshold": {
+					Type:        llm.TypeNumber,
+					Description: "Shaded beauty renders only. Relative error below which a pixel stops sampling, greater than 0 (default 0.05). Higher is faster but noisier; lower is cleaner but slower.",
+				},
+				"denoise": {
+					Type:        llm.TypeBoolean,
+					Description: "Shaded beauty renders only. Override the denoise setting from set_render_quality for this render.",
+				},
+				"integrator": {
+					Type:        llm.TypeString,
+					Description: "Shaded beauty renders only. Override the integrator setting from set_render_quality for this render.",
+					Enum:        []string{IntegratorPath},
+				},
+				"clamp_indirect": {
+					Type:        llm.TypeNumber,
+					Description: "Shaded beauty renders only. Override the clamp_indirect setting from set_render_quality for this render, from 0 up to but not including 1; 0 turns clamping off.",
+				},
+			},
+			Required: []string{},
+		},
+	}
+}
+
+func previewMaterialTool() llm.Tool {
+	return llm.Tool{
+		Name:        "preview_material",
+		Description: "Render a small swatch of a material on a sphere resting on a gray floor, under standard studio lighting, and see the image. The scene is not changed. Use this to compare material choices (metal fuzz, dielectric refractive index, mix factors, presets) before applying one to a shape.",
+		Parameters: &llm.Schema{
+			Type: llm.TypeObject,
+			Properties: map[string]*llm.Schema{
+				"material": {
+					Type:        llm.TypeObject,
+					Description: "Material to preview, in the same form as a shape's material, e.g. {type: 'metal', albedo: [0.9, 0.6, 0.2], fuzz: 0.1} or {preset: 'glass'}",
+				},
+			},
+			Required: []string{"material"},
+		},
+	}
+}
+
+func setDefaultMaterialTool() llm.Tool {
+	return llm.Tool{
+		Name:        "set_default_material",
+		Description: "Set the material used by every shape that has neither a material nor a color, instead of gray lambertian, e.g. {preset: 'chrome'} when the user wants everything metal unless they say otherwise. Shapes with their own material are unaffected, and the default applies to existing and future shapes until changed. Call with no material to restore gray lambertian.",
+		Parameters: &llm.Schema{
+			Type: llm.TypeObject,
+			Properties: map[string]*llm.Schema{
+				"material": {
+					Type:        llm.TypeObject,
+					Description: "Default material, in the same form as a shape's material, e.g. {type: 'metal', albedo: [0.9, 0.9, 0.9], fuzz: 0.1} or {preset: 'gold'}. A 'random' albedo picks one color shared by every shape using the default.",
+				},
+			},
+			Required: []string{},
+		},
+	}
+}
+
+func setRenderQualityTool() llm.Tool {
+	return llm.Tool{
+		Name:        "set_render_quality",
+		Description: "Choose the render quality used by render_scene and by the scene preview shown to the user. Use 'preview' or 'draft' for cheap renders while iterating on a scene, and 'high' or 'auto' for a final pass when you're done. The choice persists until changed.",
+		Parameters: &llm.Schema{
+			Type: llm.TypeObject,
+			Properties: map[string]*llm.Schema{
+				"quality": {
+					Type:        llm.TypeString,
+					Description: "preview: 200x150, 2 samples, very noisy but near-instant. draft: 400x300, 10 samples. high: 400x300, 500 samples, slow but clean. auto: 400x300 with samples picked from the scene - 50 for matte scenes, 100 with metal, 200 with glass - halved for very large scenes.",
+					Enum:        []string{"preview", "draft", "high", "auto"},
+				},
+				"denoise": {
+					Type:        llm.TypeBoolean,
+					Description: "Smooth render noise with an edge-aware filter before showing the image. Makes preview and draft renders much easier to read, but softens fine texture and low-contrast detail, so it is off by default. Omit to keep the current setting.",
+				},
+				"integrator": {
+					Type:        llm.TypeString,
+					Description: "Light transport algorithm. 'path' (the default, and currently the only one) traces paths from the camera. Omit to keep the current setting.",
+					Enum:        []string{IntegratorPath},
+				},
+				"clamp_indirect": {
+					Type:        llm.TypeNumber,
+					Description: "Suppress fireflies (isolated very bright pixels, common with glass and polished metal) by limiting how much brighter a pixel's final color may be than its brightest neighbour, with colors in [0, 1]. It works on the finished image, so it dims any lone bright pixel, directly lit or not. Must be below 1; try 0.2. Lower removes more fireflies but also dims small genuine sparkles and glints, so the image loses a little energy. Off by default; 0 turns it off. Omit to keep the current setting.",
+				},
+			},
+			Required: []string{"quality"},
+		},
+	}
+}
+
+func setPostProcessTool() llm.Tool {
+	return llm.Tool{
+		Name:        "set_post_process",
+		Description: "Set stylistic post-processing for shaded renders, applied to the final image in render_scene and the scene preview shown to the user. Every call replaces all three effects, and omitted ones are turned off; call with no arguments to remove all effects. The choice persists until changed.",
+		Parameters: &llm.Schema{
+			Type: llm.TypeObject,
+			Properties: map[string]*llm.Schema{
+				"vignette": {
+					Type:        llm.TypeNumber,
+					Description: "Darkening toward the edges of the frame, in [0, 1] (default 0, none). At 1 the corners are black; 0.3-0.5 is a subtle photographic look.",
+				},
+				"contrast": {
+					Type:        llm.TypeNumber,
+					Description: "Contrast adjustment around mid-gray, in [-1, 1] (default 0, unchanged). -1 flattens the image to gray; 1 doubles contrast.",
+				},
+				"saturation": {
+					Type:        llm.TypeNumber,
+					Description: "Color saturation adjustment, in [-1, 1] (default 0, unchanged). -1 is grayscale; 1 doubles saturation.",
+				},
+			},
+			Required: []string{},
+		},
+	}
+}
+
+func getCameraTool() llm.Tool {
+	return llm.Tool{
+		Name:        "get_camera",
+		Description: "Get the current camera: center, look_at, vfov, and aperture, plus derived view_direction (unit vector from center toward look_at), distance (from center to look_at), and focus_distance. Cheaper than get_scene_state when you only need to reason about framing, e.g. before moving or zooming the camera.",
+		Parameters: &llm.Schema{
+			Type:       llm.TypeObject,
+			Properties: map[string]*llm.Schema{},
+			Required:   []string{},
+		},
+	}
+}
+
+func zoomCameraTool() llm.Tool {
+	return llm.Tool{
+		Name:        "zoom_camera",
+		Description: "Dolly the camera toward or away from its look_at point along the current view direction, keeping look_at and vfov unchanged. The distance to look_at is multiplied by factor. Returns the updated camera. To change the field of view instead, use set_camera's vfov.",
+		Parameters: &llm.Schema{
+			Type: llm.TypeObject,
+			Properties: map[string]*llm.Schema{
+				"factor": {
+					Type:        llm.TypeNumber,
+					Description: "Distance multiplier, must be > 0. Less than 1 moves closer (0.5 halves the distance), greater than 1 moves away (2 doubles it).",
+				},
+			},
+			Required: []string{"factor"},
+		},
+	}
+}
+
+func setCameraPresetTool() llm.Tool {
+	return llm.Tool{
+		Name:        "set_camera_preset",
+		Description: "Frame the whole scene from a named viewpoint. The camera looks at the center of the scene's bounding box from far enough away to fit every shape, keeping the current vfov and aperture. Returns the updated camera. Use set_camera afterwards to fine-tune the framing.",
+		Parameters: &llm.Schema{
+			Type: llm.TypeObject,
+			Properties: map[string]*llm.Schema{
+				"preset": {
+					Type:        llm.TypeString,
+					Description: "Viewpoint: front (from +Z), back (from -Z), left (from -X), right (from +X), top (from above), or iso (diagonal view from above, front and right).",
+					Enum:        []string{"front", "back", "left", "right", "top", "iso"},
+				},
+			},
+			Required: []string{"preset"},
+		},
+	}
+}
+
+func frameShapeTool() llm.Tool {
+	return llm.Tool{
+		Name:        "frame_shape",
+		Description: "Move the camera to frame a single shape, e.g. to zoom in on one object. The camera keeps its current view direction, vfov and aperture, looks at the shape's center, and moves just far enough away for the whole shape to fit. Returns the updated camera.",
+		Parameters: &llm.Schema{
+			Type: llm.TypeObject,
+			Properties: map[string]*llm.Schema{
+				"id": {
+					Type:        llm.TypeString,
+					Description: "ID of the shape to frame",
+				},
+				"margin": {
+					Type:        llm.TypeNumber,
+					Description: "Space to leave around the shape, as a fraction of its size (default 0.1). 0 frames it as tightly as possible; 1 leaves the shape filling about half the frame.",
+				},
+			},
+			Required: []string{"id"},
+		},
+	}
+}
+
+func setAspectRatioTool() llm.Tool {
+	return llm.Tool{
+		Name:        "set_aspect_ratio",
+		Description: "Set the shape of the image, for widescreen, square, or portrait framing (default 4:3). Renders and the preview are resized to the new ratio at about the same pixel count, so render times don't change; the vertical field of view is kept, so a wider ratio shows more of the scene at the sides. Returns the aspect ratio and the resulting width and height at the current render quality. The choice is saved with the camera and persists until changed; set_camera leaves it alone.",
+		Parameters: &llm.Schema{
+			Type: llm.TypeObject,
+			Properties: map[string]*llm.Schema{
+				"aspect_ratio": {
+					Type:        llm.TypeString,
+					Description: "Width to height as 'width:height' or a single number, e.g. '16:9' (widescreen), '1:1' (square), '4:3' (default), '9:16' (portrait), or '2.39'. Must be positive.",
+				},
+			},
+			Required: []string{"aspect_ratio"},
+		},
+	}
+}
+
+func getSceneStateTool() llm.Tool {
+	return llm.Tool{
+		Name:        "get_scene_state",
+		Description: "Get the complete current scene state including all shapes, lights, camera, and environment lighting, plus the current revision number and 'warnings' about likely mistakes, such as spot or area lights pointing away from every shape. Use this when you need to check what's currently in the scene. Pass 'since' with a revision from an earlier call to get only what changed after it: {since, revision, shapes, lights, removed_shapes, removed_lights, camera?}. This keeps results small in long conversations. Pass 'resolved: true' to see the scene exactly as it will render: every optional property filled in with its default (materials, opacity, box rotation, disc normal, spot light direction and cone, light enabled and two_sided, environment intensity), a box's half_extents, the cutoff_angle and falloff_exponent that inner_angle/outer_angle become, the camera's aspect_ratio, and default_environment, the sky used when no light is on.",
+		Parameters: &llm.Schema{
+			Type: llm.TypeObject,
+			Properties: map[string]*llm.Schema{
+				"since": {
+					Type:        llm.TypeInteger,
+					Description: "Optional revision from a previous get_scene_state result. When set, only shapes and lights added, updated, or removed after that revision are returned, and camera is included only if it changed.",
+				},
+				"resolved": {
+					Type:        llm.TypeBoolean,
+					Description: "Optional. If true, return every shape and light with defaults applied, as the renderer sees them. Can't be combined with since.",
+				},
+			},
+			Required: []string{},
+		},
+	}
+}
+
+func getSceneStatisticsTool() llm.Tool {
+	return llm.Tool{
+		Name:        "get_scene_statistics",
+		Description: "Get aggregate numbers about the scene instead of every shape: shape_count, shapes_by_type, materials_by_type (shapes without a material count as lambertian), light_count, lights_by_type, estimated_triangles, and bounds {min, max} of all shapes. Most curved shapes are intersected exactly rather than tessellated, so only quads (2), boxes (12), pyramids (6) and ellipsoids (tessellated meshes) add triangles. Use this to judge whether a scene is getting too heavy to render quickly, e.g. before adding many more shapes or dielectric materials.",
+		Parameters: &llm.Schema{
+			Type:       llm.TypeObject,
+			Properties: map[string]*llm.Schema{},
+			Required:   []string{},
+		},
+	}
+}
+
+func findShapesByTagTool() llm.Tool {
+	return llm.Tool{
+		Name:        "find_shapes_by_tag",
+		Description: "Find the shapes tagged with a label, given in their tags property. Tags match case-insensitively. Returns count and shapes, each with its id, type, tags and description. Use this to pick out the parts of an object (e.g. every shape tagged 'snowman' or 'head') instead of guessing from IDs.",
+		Parameters: &llm.Schema{
+			Type: llm.TypeObject,
+			Properties: map[string]*llm.Schema{
+				"tag": {
+					Type:        llm.TypeString,
+					Description: "Tag to search for",
+				},
+			},
+			Required: []string{"tag"},
+		},
+	}
+}
+
+func sceneIsEmptyTool() llm.Tool {
+	return llm.Tool{
+		Name:        "scene_is_empty",
+		Description: "Cheaply check whether the scene has anything to render. Returns is_empty, shape_count, and renderable_shape_count, which leaves out shadow catchers and shapes with opacity 0 since they don't show up as surfaces. render_scene fails on an empty scene, so check this first when unsure, e.g. at the start of a conversation or after removing shapes.",
+		Parameters: &llm.Schema{
+			Type:       llm.TypeObject,
+			Properties: map[string]*llm.Schema{},
+			Required:   []string{},
+		},
+	}
+}
+
+func doneTool() llm.Tool {
+	return llm.Tool{
+		Name:        "done",
+		Description: "Signal that you have finished the user's request. Processing stops after this turn's tool calls, so call it alongside or after your final changes and summary rather than continuing to make tool calls.",
+		Parameters: &llm.Schema{
+			Type:       llm.TypeObject,
+			Properties: map[string]*llm.Schema{},
+			Required:   []string{},
+		},
+	}
+}
+
+func isPointOccupiedTool() llm.Tool {
+	return llm.Tool{
+		Name:        "is_point_occupied",
+		Description: "Check whether a point is inside any solid shape (sphere, ellipsoid, box, pyramid, cylinder, cone). Returns occupied and the shape_ids containing the point; points on a surface count as inside. Quads and discs have no volume and are never reported. Use this before placing an object to avoid putting it inside another one.",
+		Parameters: &llm.Schema{
+			Type: llm.TypeObject,
+			Properties: map[string]*llm.Schema{
+				"point": {
+					Type:        llm.TypeArray,
+					Description: "The point to test as [x, y, z]",
+					Items:       &llm.Schema{Type: llm.TypeNumber},
+				},
+			},
+			Required: []string{"point"},
+		},
+	}
+}
+
+func checkOverlapTool() llm.Tool {
+	return llm.Tool{
+		Name:        "check_overlap",
+		Description: "Check whether two shapes overlap, using their axis-aligned bounding boxes. Returns overlap, both boxes (bounds_a and bounds_b, each {min, max}), and either overlap_bounds {min, max, size} of the shared region or gap, the shortest distance between the boxes. This is approximate: boxes can overlap while the shapes themselves don't, especially for spheres, cones, and rotated shapes. Boxes that only touch (e.g. an object resting on a table) don't count as overlapping. Use this to check spacing after placing objects near each other.",
+		Parameters: &llm.Schema{
+			Type: llm.TypeObject,
+			Properties: map[string]*llm.Schema{
+				"shape_a": {
+					Type:        llm.TypeString,
+					Description: "ID of the first shape",
+				},
+				"shape_b": {
+					Type:        llm.TypeString,
+					Description: "ID of the second shape",
 				},
 			},
-			Required: []string{"id", "updates"},
+			Required: []string{"shape_a", "shape_b"},
 		},
 	}
 }
 
-// removeLightToolDeclaration returns the function declaration for light removal
-func removeLightToolDeclaration() *genai.FunctionDeclaration {
-	return &genai.FunctionDeclaration{
-		Name:        "remove_light",
-		Description: "Remove a light from the scene by its ID",
-		Parameters: &genai.Schema{
-			Type: genai.TypeObject,
-			Properties: map[string]*genai.Schema{
-				"id": {
-					Type:        genai.TypeString,
-					Description: "ID of the light to remove",
+func measureDistanceTool() llm.Tool {
+	return llm.Tool{
+		Name:        "measure_distance",
+		Description: "Measure the straight-line distance between two targets, each a shape or a point. A shape is measured from its center property (the base center for pyramids), or the middle of its bounding box for shapes without one (quads, cylinders, cones). Returns distance and delta [dx, dy, dz], the offset from the first target to the second. Use this instead of working out distances by hand when arranging objects.",
+		Parameters: &llm.Schema{
+			Type: llm.TypeObject,
+			Properties: map[string]*llm.Schema{
+				"from_shape": {
+					Type:        llm.TypeString,
+					Description: "ID of the shape to measure from. Give this or from_point.",
+				},
+				"from_point": {
+					Type:        llm.TypeArray,
+					Description: "Point [x, y, z] to measure from. Give this or from_shape.",
+					Items:       &llm.Schema{Type: llm.TypeNumber},
+				},
+				"to_shape": {
+					Type:        llm.TypeString,
+					Description: "ID of the shape to measure to. Give this or to_point.",
+				},
+				"to_point": {
+					Type:        llm.TypeArray,
+					Description: "Point [x, y, z] to measure to. Give this or to_shape.",
+					Items:       &llm.Schema{Type: llm.TypeNumber},
 				},
 			},
-			Required: []string{"id"},
 		},
 	}
 }
 
-func setCameraToolDeclaration() *genai.FunctionDeclaration {
-	return &genai.FunctionDeclaration{
-		Name:        "set_camera",
-		Description: "Set camera position and properties for viewing the scene",
-		Parameters: &genai.Schema{
-			Type: genai.TypeObject,
-			Properties: map[string]*genai.Schema{
-				"center": {
-					Type:        genai.TypeArray,
-					Description: "Camera position as [x, y, z]",
-					Items: &genai.Schema{
-						Type: genai.TypeNumber,
-					},
-				},
-				"look_at": {
-					Type:        genai.TypeArray,
-					Description: "Point the camera looks at as [x, y, z]",
-					Items: &genai.Schema{
-						Type: genai.TypeNumber,
-					},
+func validateShapeTool() llm.Tool {
+	return llm.Tool{
+		Name:        "validate_shape",
+		Description: "Check a shape definition without adding it to the scene. Takes the same arguments as create_shape and returns {valid: bool, errors: [...], warnings: [...]} listing every problem at once (missing or out-of-range properties, invalid material, duplicate ID). Warnings flag likely mistakes that don't make the shape invalid, such as a dielectric on a flat quad or disc. Use this to check complex shapes cheaply before calling create_shape.",
+		Parameters: &llm.Schema{
+			Type: llm.TypeObject,
+			Properties: map[string]*llm.Schema{
+				"id": {
+					Type:        llm.TypeString,
+					Description: "Unique identifier the shape would be created with",
 				},
-				"vfov": {
-					Type:        genai.TypeNumber,
-					Description: "Vertical field of view in degrees (default: 45.0)",
+				"type": {
+					Type:        llm.TypeString,
+					Enum:        []string{"sphere", "ellipsoid", "box", "pyramid", "quad", "disc", "cylinder", "cone"},
+					Description: "The type of shape to validate",
 				},
-				"aperture": {
-					Type:        genai.TypeNumber,
-					Description: "Lens aperture for depth of field effect (0.0 = no blur, default: 0.0)",
+				"properties": {
+					Type:        llm.TypeObject,
+					Description: "Shape-specific properties, exactly as they would be passed to create_shape",
 				},
 			},
+			Required: []string{"id", "type", "properties"},
 		},
 	}
 }
 
-func renderSceneToolDeclaration() *genai.FunctionDeclaration {
-	return &genai.FunctionDeclaration{
-		Name:        "render_scene",
-		Description: "Render the scene at high quality and receive the image for visual inspection. You can see the rendered result to verify colors, materials, lighting, and composition. **WARNING: Expensive (500 samples, ~3-5 seconds). Use sparingly.**",
-		Parameters: &genai.Schema{
-			Type:       genai.TypeObject,
-			Properties: map[string]*genai.Schema{},
-		},
-	}
-}
-
-func getSceneStateToolDeclaration() *genai.FunctionDeclaration {
-	return &genai.FunctionDeclaration{
-		Name:        "get_scene_state",
-		Description: "Get the complete current scene state as JSON, including all shapes, lights, camera, and environment settings. Use this when you need to check what's currently in the scene or if you're unsure about the current state.",
-		Parameters: &genai.Schema{
-			Type:       genai.TypeObject,
-			Properties: map[string]*genai.Schema{},
+func validateLightTool() llm.Tool {
+	return llm.Tool{
+		Name:        "validate_light",
+		Description: "Check a light definition without adding it to the scene. Takes the same arguments as create_light and returns {valid: bool, errors: [...]} listing every problem at once.",
+		Parameters: &llm.Schema{
+			Type: llm.TypeObject,
+			Properties: map[string]*llm.Schema{
+				"id": {
+					Type:        llm.TypeString,
+					Description: "Unique identifier the light would be created with",
+				},
+				"type": {
+					Type:        llm.TypeString,
+					Enum:        []string{"point_light", "point_spot_light", "area_quad_light", "area_rect_light", "disc_spot_light", "area_sphere_light", "area_disc_spot_light"},
+					Description: "Type of light source to validate",
+				},
+				"properties": {
+					Type:        llm.TypeObject,
+					Description: "Light-specific properties, exactly as they would be passed to create_light",
+				},
+			},
+			Required: []string{"id", "type", "properties"},
 		},
 	}
 }
@@ -593,31 +1623,13 @@ func getSceneStateToolDeclaration() *genai.FunctionDeclaration {
 // ------------------------------------------------------------
 
 // parseToolRequestFromFunctionCall creates a ToolRequest from any function call
+// Returns nil for tools that aren't registered
 func parseToolRequestFromFunctionCall(call *llm.FunctionCall) ToolRequest {
-	switch call.Name {
-	case "create_shape":
-		return parseCreateShapeRequest(call)
-	case "update_shape":
-		return parseUpdateShapeRequest(call)
-	case "remove_shape":
-		return parseRemoveShapeRequest(call)
-	case "create_light":
-		return parseCreateLightRequest(call)
-	case "update_light":
-		return parseUpdateLightRequest(call)
-	case "remove_light":
-		return parseRemoveLightRequest(call)
-	case "set_environment_lighting":
-		return parseSetEnvironmentLightingRequest(call)
-	case "set_camera":
-		return parseSetCameraRequest(call)
-	case "render_scene":
-		return parseRenderSceneRequest(call)
-	case "get_scene_state":
-		return parseGetSceneStateRequest(call)
-	default:
+	spec, ok := toolRegistry[call.Name]
+	if !ok {
 		return nil
 	}
+	return spec.parse(call)
 }
 
 // parseCreateShapeRequest creates a CreateShapeRequest from a create_shape function call
@@ -641,6 +1653,15 @@ func parseUpdateShapeRequest(call *llm.FunctionCall) *UpdateShapeRequest {
 	}
 }
 
+// parseRevertShapeRequest creates a RevertShapeRequest from a revert_shape function call
+func parseRevertShapeRequest(call *llm.FunctionCall) *RevertShapeRequest {
+	id, _ := extractStringArg(call.Arguments, "id")
+
+	return &RevertShapeRequest{
+		BaseToolRequest: BaseToolRequest{ToolType: "revert_shape", Id: id},
+	}
+}
+
 // parseRemoveShapeRequest creates a RemoveShapeRequest from a remove_shape function call
 func parseRemoveShapeRequest(call *llm.FunctionCall) *RemoveShapeRequest {
 	id, _ := extractStringArg(call.Arguments, "id")
@@ -650,12 +1671,244 @@ func parseRemoveShapeRequest(call *llm.FunctionCall) *RemoveShapeRequest {
 	}
 }
 
+// parseExportShapeRequest creates an ExportShapeRequest from an export_shape function call
+func parseExportShapeRequest(call *llm.FunctionCall) *ExportShapeRequest {
+	id, _ := extractStringArg(call.Arguments, "id")
+
+	return &ExportShapeRequest{
+		BaseToolRequest: BaseToolRequest{ToolType: "export_shape", Id: id},
+	}
+}
+
+// parseImportShapeRequest creates an ImportShapeRequest from an import_shape function call
+// Models sometimes pass the snippet as an object rather than a JSON string, so objects are
+// re-encoded.
+func parseImportShapeRequest(call *llm.FunctionCall) *ImportShapeRequest {
+	newID, _ := extractStringArg(call.Arguments, "new_id")
+	snippet, ok := extractStringArg(call.Arguments, "snippet")
+	if !ok {
+		if obj, isMap := extractMapArg(call.Arguments, "snippet"); isMap {
+			if data, err := json.Marshal(obj); err == nil {
+				snippet = string(data)
+			}
+		}
+	}
+
+	return &ImportShapeRequest{
+		BaseToolRequest: BaseToolRequest{ToolType: "import_shape", Id: newID},
+		Snippet:         snippet,
+	}
+}
+
+// parseImportDSLRequest creates an ImportDSLRequest from an import_dsl function call
+func parseImportDSLRequest(call *llm.FunctionCall) *ImportDSLRequest {
+	dsl, _ := extractStringArg(call.Arguments, "dsl")
+	return &ImportDSLRequest{
+		BaseToolRequest: BaseToolRequest{ToolType: "import_dsl"},
+		DSL:             dsl,
+	}
+}
+
+// parseRemoveShapesRequest creates a RemoveShapesRequest from a remove_shapes function call
+// parseRenameShapesRequest creates a RenameShapesRequest from a rename_shapes function call
+// A mapping value that isn't a string becomes "", which RenameShapes rejects.
+func parseRenameShapesRequest(call *llm.FunctionCall) *RenameShapesRequest {
+	ids, _ := extractStringArrayArg(call.Arguments, "ids")
+	prefix, _ := extractStringArg(call.Arguments, "prefix")
+	suffix, _ := extractStringArg(call.Arguments, "suffix")
+
+	var mapping map[string]string
+	if raw, ok := extractMapArg(call.Arguments, "mapping"); ok {
+		mapping = make(map[string]string, len(raw))
+		for oldID, newID := range raw {
+			mapping[oldID], _ = newID.(string)
+		}
+	}
+
+	return &RenameShapesRequest{
+		BaseToolRequest: BaseToolRequest{ToolType: "rename_shapes"},
+		Mapping:         mapping,
+		Ids:             ids,
+		Prefix:          prefix,
+		Suffix:          suffix,
+	}
+}
+
+func parseRemoveShapesRequest(call *llm.FunctionCall) *RemoveShapesRequest {
+	ids, _ := extractStringArrayArg(call.Arguments, "ids")
+
+	return &RemoveShapesRequest{
+		BaseToolRequest: BaseToolRequest{ToolType: "remove_shapes"},
+		Ids:             ids,
+	}
+}
+
+// parsePreviewMaterialRequest creates a PreviewMaterialRequest from a preview_material function call
+func parsePreviewMaterialRequest(call *llm.FunctionCall) *PreviewMaterialRequest {
+	material, _ := call.Arguments["material"].(map[string]interface{})
+	return &PreviewMaterialRequest{
+		BaseToolRequest: BaseToolRequest{ToolType: "preview_material"},
+		Material:        material,
+	}
+}
+
+// parseSetDefaultMaterialRequest creates a SetDefaultMaterialRequest from a set_default_material function call
+func parseSetDefaultMaterialRequest(call *llm.FunctionCall) *SetDefaultMaterialRequest {
+	material, _ := call.Arguments["material"].(map[string]interface{})
+	return &SetDefaultMaterialRequest{
+		BaseToolRequest: BaseToolRequest{ToolType: "set_default_material"},
+		Material:        material,
+	}
+}
+
+// parseSetRenderQualityRequest creates a SetRenderQualityRequest from a set_render_quality function call
+func parseSetRenderQualityRequest(call *llm.FunctionCall) *SetRenderQualityRequest {
+	quality, _ := extractStringArg(call.Arguments, "quality")
+
+	integrator, _ := extractStringArg(call.Arguments, "integrator")
+
+	req := &SetRenderQualityRequest{
+		BaseToolRequest: BaseToolRequest{ToolType: "set_render_quality"},
+		Quality:         quality,
+		Integrator:      integrator,
+	}
+	if denoise, ok := call.Arguments["denoise"].(bool); ok {
+		req.Denoise = &denoise
+	}
+	if clamp, ok := extractFloatArg(call.Arguments, "clamp_indirect"); ok {
+		req.ClampIndirect = &clamp
+	}
+	return req
+}
+
+// parseSetPostProcessRequest creates a SetPostProcessRequest from a set_post_process function call
+func parseSetPostProcessRequest(call *llm.FunctionCall) *SetPostProcessRequest {
+	vignette, _ := extractFloatArg(call.Arguments, "vignette")
+	contrast, _ := extractFloatArg(call.Arguments, "contrast")
+	saturation, _ := extractFloatArg(call.Arguments, "saturation")
+
+	return &SetPostProcessRequest{
+		BaseToolRequest: BaseToolRequest{ToolType: "set_post_process"},
+		Vignette:        vignette,
+		Contrast:        contrast,
+		Saturation:      saturation,
+	}
+}
+
+// parseGetCameraRequest creates a GetCameraRequest from a get_camera function call
+func parseGetCameraRequest(call *llm.FunctionCall) *GetCameraRequest {
+	return &GetCameraRequest{
+		BaseToolRequest: BaseToolRequest{ToolType: "get_camera"},
+	}
+}
+
+// parseZoomCameraRequest creates a ZoomCameraRequest from a zoom_camera function call
+func parseZoomCameraRequest(call *llm.FunctionCall) *ZoomCameraRequest {
+	factor, _ := extractFloatArg(call.Arguments, "factor")
+
+	return &ZoomCameraRequest{
+		BaseToolRequest: BaseToolRequest{ToolType: "zoom_camera"},
+		Factor:          factor,
+	}
+}
+
+// parseSetCameraPresetRequest creates a SetCameraPresetRequest from a set_camera_preset function call
+func parseSetCameraPresetRequest(call *llm.FunctionCall) *SetCameraPresetRequest {
+	preset, _ := extractStringArg(call.Arguments, "preset")
+
+	return &SetCameraPresetRequest{
+		BaseToolRequest: BaseToolRequest{ToolType: "set_camera_preset"},
+		Preset:          preset,
+	}
+}
+
+// parseFrameShapeRequest creates a FrameShapeRequest from a frame_shape function call
+func parseFrameShapeRequest(call *llm.FunctionCall) *FrameShapeRequest {
+	id, _ := extractStringArg(call.Arguments, "id")
+	margin, ok := extractFloatArg(call.Arguments, "margin")
+	if !ok {
+		margin = defaultFrameMargin
+	}
+
+	return &FrameShapeRequest{
+		BaseToolRequest: BaseToolRequest{ToolType: "frame_shape"},
+		ID:              id,
+		Margin:          margin,
+	}
+}
+
+// parseSetAspectRatioRequest creates a SetAspectRatioRequest from a set_aspect_ratio function call
+// A bare number is accepted as well as a string.
+func parseSetAspectRatioRequest(call *llm.FunctionCall) *SetAspectRatioRequest {
+	aspectRatio, ok := extractStringArg(call.Arguments, "aspect_ratio")
+	if number, isNumber := extractFloatArg(call.Arguments, "aspect_ratio"); !ok && isNumber {
+		aspectRatio = strconv.FormatFloat(number, 'g', -1, 64)
+	}
+
+	return &SetAspectRatioRequest{
+		BaseToolRequest: BaseToolRequest{ToolType: "set_aspect_ratio"},
+		AspectRatio:     aspectRatio,
+	}
+}
+
+// parseSnapShapesRequest creates a SnapShapesRequest from a snap_shapes function call
+func parseSnapShapesRequest(call *llm.FunctionCall) *SnapShapesRequest {
+	moverID, _ := extractStringArg(call.Arguments, "mover_id")
+	targetID, _ := extractStringArg(call.Arguments, "target_id")
+	direction, _ := extractFloatArrayArg(call.Arguments, "direction")
+
+	return &SnapShapesRequest{
+		BaseToolRequest: BaseToolRequest{ToolType: "snap_shapes", Id: moverID},
+		TargetID:        targetID,
+		Direction:       direction,
+	}
+}
+
+// parseArrayShapesRequest creates an ArrayShapesRequest from an array_shapes function call
+func parseArrayShapesRequest(call *llm.FunctionCall) *ArrayShapesRequest {
+	sourceID, _ := extractStringArg(call.Arguments, "source_id")
+	counts, _ := extractFloatArrayArg(call.Arguments, "counts")
+	spacing, _ := extractFloatArrayArg(call.Arguments, "spacing")
+
+	intCounts := make([]int, len(counts))
+	for i, count := range counts {
+		intCounts[i] = int(count)
+	}
+
+	return &ArrayShapesRequest{
+		BaseToolRequest: BaseToolRequest{ToolType: "array_shapes", Id: sourceID},
+		Counts:          intCounts,
+		Spacing:         spacing,
+	}
+}
+
 // parseSetEnvironmentLightingRequest creates a SetEnvironmentLightingRequest from a set_environment_lighting function call
 func parseSetEnvironmentLightingRequest(call *llm.FunctionCall) *SetEnvironmentLightingRequest {
 	lightingType, _ := extractStringArg(call.Arguments, "type")
 	topColor, _ := extractFloatArrayArg(call.Arguments, "top_color")
 	bottomColor, _ := extractFloatArrayArg(call.Arguments, "bottom_color")
 	emission, _ := extractFloatArrayArg(call.Arguments, "emission")
+	sunDirection, _ := extractFloatArrayArg(call.Arguments, "sun_direction")
+	sunElevation, ok := extractFloatArg(call.Arguments, "sun_elevation")
+	if !ok {
+		sunElevation = defaultSunElevation
+	}
+	sunAzimuth, ok := extractFloatArg(call.Arguments, "sun_azimuth")
+	if !ok {
+		sunAzimuth = defaultSunAzimuth
+	}
+	turbidity, ok := extractFloatArg(call.Arguments, "turbidity")
+	if !ok {
+		turbidity = defaultTurbidity
+	}
+	intensity, ok := extractFloatArg(call.Arguments, "intensity")
+	if !ok {
+		intensity = 1
+	}
+	replace, ok := call.Arguments["replace"].(bool)
+	if !ok {
+		replace = true // Replacing is the default
+	}
 
 	return &SetEnvironmentLightingRequest{
 		BaseToolRequest: BaseToolRequest{ToolType: "set_environment_lighting"},
@@ -663,6 +1916,12 @@ func parseSetEnvironmentLightingRequest(call *llm.FunctionCall) *SetEnvironmentL
 		TopColor:        topColor,
 		BottomColor:     bottomColor,
 		Emission:        emission,
+		SunDirection:    sunDirection,
+		SunElevation:    sunElevation,
+		SunAzimuth:      sunAzimuth,
+		Turbidity:       turbidity,
+		Intensity:       intensity,
+		Replace:         replace,
 	}
 }
 
@@ -676,6 +1935,40 @@ func parseCreateLightRequest(call *llm.FunctionCall) *CreateLightRequest {
 	}
 }
 
+// parseLightShapeRequest creates a LightShapeRequest from a light_shape function call
+func parseLightShapeRequest(call *llm.FunctionCall) *LightShapeRequest {
+	id, _ := extractStringArg(call.Arguments, "id")
+	lightID, _ := extractStringArg(call.Arguments, "light_id")
+	lightType, _ := extractStringArg(call.Arguments, "light_type")
+	direction, _ := extractFloatArrayArg(call.Arguments, "direction")
+	distance, _ := extractFloatArg(call.Arguments, "distance")
+	emission, _ := extractFloatArrayArg(call.Arguments, "emission")
+
+	return &LightShapeRequest{
+		BaseToolRequest: BaseToolRequest{ToolType: "light_shape", Id: id},
+		LightID:         lightID,
+		LightType:       lightType,
+		Direction:       direction,
+		Distance:        distance,
+		Emission:        emission,
+	}
+}
+
+// parseSetupThreePointLightingRequest creates a SetupThreePointLightingRequest from a
+// setup_three_point_lighting function call
+func parseSetupThreePointLightingRequest(call *llm.FunctionCall) *SetupThreePointLightingRequest {
+	id, _ := extractStringArg(call.Arguments, "id")
+	intensity, ok := extractFloatArg(call.Arguments, "intensity")
+	if !ok {
+		intensity = defaultThreePointIntensity
+	}
+
+	return &SetupThreePointLightingRequest{
+		BaseToolRequest: BaseToolRequest{ToolType: "setup_three_point_lighting", Id: id},
+		Intensity:       intensity,
+	}
+}
+
 // parseUpdateLightRequest creates an UpdateLightRequest from an update_light function call
 func parseUpdateLightRequest(call *llm.FunctionCall) *UpdateLightRequest {
 	id, _ := extractStringArg(call.Arguments, "id")
@@ -696,11 +1989,58 @@ func parseRemoveLightRequest(call *llm.FunctionCall) *RemoveLightRequest {
 	}
 }
 
+// parseGetLightRequest creates a GetLightRequest from a get_light function call
+func parseGetLightRequest(call *llm.FunctionCall) *GetLightRequest {
+	id, _ := extractStringArg(call.Arguments, "id")
+
+	return &GetLightRequest{
+		BaseToolRequest: BaseToolRequest{ToolType: "get_light", Id: id},
+	}
+}
+
+// parseSetLightEnabledRequest creates a SetLightEnabledRequest from a set_light_enabled function call
+func parseSetLightEnabledRequest(call *llm.FunctionCall) *SetLightEnabledRequest {
+	id, _ := extractStringArg(call.Arguments, "id")
+
+	request := &SetLightEnabledRequest{
+		BaseToolRequest: BaseToolRequest{ToolType: "set_light_enabled", Id: id},
+	}
+	if enabled, ok := call.Arguments["enabled"].(bool); ok {
+		request.Enabled = &enabled
+	}
+	return request
+}
+
+// parseSoloLightRequest creates a SoloLightRequest from a solo_light function call
+func parseSoloLightRequest(call *llm.FunctionCall) *SoloLightRequest {
+	id, _ := extractStringArg(call.Arguments, "id")
+
+	return &SoloLightRequest{
+		BaseToolRequest: BaseToolRequest{ToolType: "solo_light", Id: id},
+	}
+}
+
+// parseUnsoloLightRequest creates an UnsoloLightRequest from an unsolo_light function call
+func parseUnsoloLightRequest(call *llm.FunctionCall) *UnsoloLightRequest {
+	return &UnsoloLightRequest{
+		BaseToolRequest: BaseToolRequest{ToolType: "unsolo_light"},
+	}
+}
+
 func parseSetCameraRequest(call *llm.FunctionCall) *SetCameraRequest {
 	center, _ := extractFloatArrayArg(call.Arguments, "center")
 	lookAt, _ := extractFloatArrayArg(call.Arguments, "look_at")
+	lookDirection, _ := extractFloatArrayArg(call.Arguments, "look_direction")
+	// An empty array means the field was left out; SetCamera rejects look_at and look_direction together
+	if len(lookAt) == 0 {
+		lookAt = nil
+	}
+	if len(lookDirection) == 0 {
+		lookDirection = nil
+	}
 	vfov, hasVFov := extractFloatArg(call.Arguments, "vfov")
 	aperture, _ := extractFloatArg(call.Arguments, "aperture")
+	fstop, _ := extractFloatArg(call.Arguments, "fstop") // SetCamera converts it to an aperture
 
 	// Apply defaults for optional parameters
 	if !hasVFov || vfov == 0 {
@@ -711,24 +2051,205 @@ func parseSetCameraRequest(call *llm.FunctionCall) *SetCameraRequest {
 	return &SetCameraRequest{
 		BaseToolRequest: BaseToolRequest{ToolType: "set_camera"},
 		Camera: CameraInfo{
-			Center:   center,
-			LookAt:   lookAt,
-			VFov:     vfov,
-			Aperture: aperture,
+			Center:        center,
+			LookAt:        lookAt,
+			LookDirection: lookDirection,
+			VFov:          vfov,
+			Aperture:      aperture,
+			FStop:         fstop,
 		},
 	}
 }
 
+// parseAddCameraRequest creates an AddCameraRequest from an add_camera function call
+func parseAddCameraRequest(call *llm.FunctionCall) *AddCameraRequest {
+	id, _ := extractStringArg(call.Arguments, "id")
+
+	return &AddCameraRequest{
+		BaseToolRequest: BaseToolRequest{ToolType: "add_camera"},
+		ID:              id,
+		Camera:          parseSetCameraRequest(call).Camera,
+	}
+}
+
+// parseSelectCameraRequest creates a SelectCameraRequest from a select_camera function call
+func parseSelectCameraRequest(call *llm.FunctionCall) *SelectCameraRequest {
+	id, _ := extractStringArg(call.Arguments, "id")
+
+	return &SelectCameraRequest{
+		BaseToolRequest: BaseToolRequest{ToolType: "select_camera"},
+		ID:              id,
+	}
+}
+
+// parseRemoveCameraRequest creates a RemoveCameraRequest from a remove_camera function call
+func parseRemoveCameraRequest(call *llm.FunctionCall) *RemoveCameraRequest {
+	id, _ := extractStringArg(call.Arguments, "id")
+
+	return &RemoveCameraRequest{
+		BaseToolRequest: BaseToolRequest{ToolType: "remove_camera"},
+		ID:              id,
+	}
+}
+
+// parseCheckpointSceneRequest creates a CheckpointSceneRequest from a checkpoint_scene function call
+func parseCheckpointSceneRequest(call *llm.FunctionCall) *CheckpointSceneRequest {
+	label, _ := extractStringArg(call.Arguments, "label")
+	overwrite, _ := call.Arguments["overwrite"].(bool)
+
+	return &CheckpointSceneRequest{
+		BaseToolRequest: BaseToolRequest{ToolType: "checkpoint_scene"},
+		Label:           label,
+		Overwrite:       overwrite,
+	}
+}
+
+// parseRestoreCheckpointRequest creates a RestoreCheckpointRequest from a restore_checkpoint function call
+func parseRestoreCheckpointRequest(call *llm.FunctionCall) *RestoreCheckpointRequest {
+	label, _ := extractStringArg(call.Arguments, "label")
+
+	return &RestoreCheckpointRequest{
+		BaseToolRequest: BaseToolRequest{ToolType: "restore_checkpoint"},
+		Label:           label,
+	}
+}
+
+// parseListCheckpointsRequest creates a ListCheckpointsRequest from a list_checkpoints function call
+func parseListCheckpointsRequest(call *llm.FunctionCall) *ListCheckpointsRequest {
+	return &ListCheckpointsRequest{
+		BaseToolRequest: BaseToolRequest{ToolType: "list_checkpoints"},
+	}
+}
+
 func parseRenderSceneRequest(call *llm.FunctionCall) *RenderSceneRequest {
-	return &RenderSceneRequest{
+	mode, _ := extractStringArg(call.Arguments, "mode")
+	aov, _ := extractStringArg(call.Arguments, "aov")
+	outputPath, _ := extractStringArg(call.Arguments, "output_path")
+	integrator, _ := extractStringArg(call.Arguments, "integrator")
+	imageFormat, _ := extractStringArg(call.Arguments, "image_format")
+	req := &RenderSceneRequest{
 		BaseToolRequest: BaseToolRequest{ToolType: "render_scene"},
+		Mode:            mode,
+		AOV:             aov,
+		OutputPath:      outputPath,
+		ImageFormat:     imageFormat,
+		Integrator:      integrator,
+	}
+	if imageQuality, ok := extractFloatArg(call.Arguments, "image_quality"); ok {
+		req.ImageQuality = int(imageQuality)
+	}
+	if minSamples, ok := extractFloatArg(call.Arguments, "adaptive_min_samples"); ok {
+		req.AdaptiveMinSamples = &minSamples
 	}
+	if threshold, ok := extractFloatArg(call.Arguments, "adaptive_threshold"); ok {
+		req.AdaptiveThreshold = &threshold
+	}
+	if denoise, ok := call.Arguments["denoise"].(bool); ok {
+		req.Denoise = &denoise
+	}
+	if clamp, ok := extractFloatArg(call.Arguments, "clamp_indirect"); ok {
+		req.ClampIndirect = &clamp
+	}
+	return req
 }
 
 func parseGetSceneStateRequest(call *llm.FunctionCall) *GetSceneStateRequest {
-	return &GetSceneStateRequest{
+	req := &GetSceneStateRequest{
 		BaseToolRequest: BaseToolRequest{ToolType: "get_scene_state"},
 	}
+	if since, ok := extractFloat(call.Arguments, "since"); ok {
+		rev := int(since)
+		req.Since = &rev
+	}
+	req.Resolved, _ = call.Arguments["resolved"].(bool)
+	return req
+}
+
+// parseGetSceneStatisticsRequest creates a GetSceneStatisticsRequest from a get_scene_statistics function call
+func parseGetSceneStatisticsRequest(call *llm.FunctionCall) *GetSceneStatisticsRequest {
+	return &GetSceneStatisticsRequest{
+		BaseToolRequest: BaseToolRequest{ToolType: "get_scene_statistics"},
+	}
+}
+
+// parseFindShapesByTagRequest creates a FindShapesByTagRequest from a find_shapes_by_tag function call
+func parseFindShapesByTagRequest(call *llm.FunctionCall) *FindShapesByTagRequest {
+	tag, _ := extractStringArg(call.Arguments, "tag")
+
+	return &FindShapesByTagRequest{
+		BaseToolRequest: BaseToolRequest{ToolType: "find_shapes_by_tag"},
+		Tag:             tag,
+	}
+}
+
+// parseSceneIsEmptyRequest creates a SceneIsEmptyRequest from a scene_is_empty function call
+func parseSceneIsEmptyRequest(call *llm.FunctionCall) *SceneIsEmptyRequest {
+	return &SceneIsEmptyRequest{
+		BaseToolRequest: BaseToolRequest{ToolType: "scene_is_empty"},
+	}
+}
+
+// parseDoneRequest creates a DoneRequest from a done function call
+func parseDoneRequest(call *llm.FunctionCall) *DoneRequest {
+	return &DoneRequest{
+		BaseToolRequest: BaseToolRequest{ToolType: "done"},
+	}
+}
+
+// parseIsPointOccupiedRequest creates an IsPointOccupiedRequest from an is_point_occupied function call
+func parseIsPointOccupiedRequest(call *llm.FunctionCall) *IsPointOccupiedRequest {
+	point, _ := extractFloatArrayArg(call.Arguments, "point")
+
+	return &IsPointOccupiedRequest{
+		BaseToolRequest: BaseToolRequest{ToolType: "is_point_occupied"},
+		Point:           point,
+	}
+}
+
+// parseCheckOverlapRequest creates a CheckOverlapRequest from a check_overlap function call
+func parseCheckOverlapRequest(call *llm.FunctionCall) *CheckOverlapRequest {
+	shapeA, _ := extractStringArg(call.Arguments, "shape_a")
+	shapeB, _ := extractStringArg(call.Arguments, "shape_b")
+
+	return &CheckOverlapRequest{
+		BaseToolRequest: BaseToolRequest{ToolType: "check_overlap"},
+		ShapeA:          shapeA,
+		ShapeB:          shapeB,
+	}
+}
+
+// parseMeasureDistanceRequest creates a MeasureDistanceRequest from a measure_distance function call
+func parseMeasureDistanceRequest(call *llm.FunctionCall) *MeasureDistanceRequest {
+	fromShape, _ := extractStringArg(call.Arguments, "from_shape")
+	fromPoint, _ := extractFloatArrayArg(call.Arguments, "from_point")
+	toShape, _ := extractStringArg(call.Arguments, "to_shape")
+	toPoint, _ := extractFloatArrayArg(call.Arguments, "to_point")
+
+	return &MeasureDistanceRequest{
+		BaseToolRequest: BaseToolRequest{ToolType: "measure_distance"},
+		From:            DistanceTarget{ShapeID: fromShape, Point: fromPoint},
+		To:              DistanceTarget{ShapeID: toShape, Point: toPoint},
+	}
+}
+
+// parseValidateShapeRequest creates a ValidateShapeRequest from a validate_shape function call
+func parseValidateShapeRequest(call *llm.FunctionCall) *ValidateShapeRequest {
+	shape := extractShapeRequest(call.Arguments)
+
+	return &ValidateShapeRequest{
+		BaseToolRequest: BaseToolRequest{ToolType: "validate_shape", Id: shape.ID},
+		Shape:           shape,
+	}
+}
+
+// parseValidateLightRequest creates a ValidateLightRequest from a validate_light function call
+func parseValidateLightRequest(call *llm.FunctionCall) *ValidateLightRequest {
+	light := extractLightRequest(call.Arguments)
+
+	return &ValidateLightRequest{
+		BaseToolRequest: BaseToolRequest{ToolType: "validate_light", Id: light.ID},
+		Light:           light,
+	}
 }
 
 // ------------------------------------------------------------
@@ -751,6 +2272,26 @@ func extractMapArg(args map[string]interface{}, key string) (map[string]interfac
 	return nil, false
 }
 
+// extractStringArrayArg extracts a string array argument from function call args
+// Non-string elements are skipped
+func extractStringArrayArg(args map[string]interface{}, key string) ([]string, bool) {
+	if val, ok := args[key].([]string); ok {
+		return val, true
+	}
+
+	if val, ok := args[key].([]interface{}); ok {
+		result := make([]string, 0, len(val))
+		for _, v := range val {
+			if str, ok := v.(string); ok {
+				result = append(result, str)
+			}
+		}
+		return result, true
+	}
+
+	return nil, false
+}
+
 func extractFloatArg(args map[string]interface{}, key string) (float64, bool) {
 	if val, ok := args[key].(float64); ok {
 		return val, true
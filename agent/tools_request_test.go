@@ -1,6 +1,7 @@
 package agent
 
 import (
+	"context"
 	"testing"
 	"time"
 )
@@ -32,7 +33,7 @@ func TestExecuteToolRequestWithoutAPI(t *testing.T) {
 		}
 
 		// Execute the operation
-		agent.executeToolRequests(operation, "test_call_1")
+		agent.executeToolRequests(context.Background(), operation, "test_call_1")
 
 		// Check that a ToolCallEvent was emitted
 		select {
@@ -76,7 +77,7 @@ func TestExecuteToolRequestWithoutAPI(t *testing.T) {
 		}
 
 		// Execute the operation
-		agent.executeToolRequests(operation, "test_call_1")
+		agent.executeToolRequests(context.Background(), operation, "test_call_1")
 
 		// Check that a ToolCallEvent was emitted
 		select {
@@ -116,7 +117,7 @@ func TestExecuteToolRequestWithoutAPI(t *testing.T) {
 		}
 
 		// Execute the operation
-		agent.executeToolRequests(operation, "test_call_1")
+		agent.executeToolRequests(context.Background(), operation, "test_call_1")
 
 		// Check that a ToolCallEvent was emitted
 		select {
@@ -162,7 +163,7 @@ func TestExecuteToolRequestWithoutAPI(t *testing.T) {
 		}
 
 		// Execute the operation
-		agent.executeToolRequests(operation, "test_call_1")
+		agent.executeToolRequests(context.Background(), operation, "test_call_1")
 
 		// Check that a failed ToolCallEvent was emitted
 		select {
@@ -1,6 +1,8 @@
 package agent
 
 import (
+	"context"
+	"strings"
 	"testing"
 
 	"github.com/df07/scene-llm/agent/llm"
@@ -136,3 +138,297 @@ func TestParseToolRequestFromFunctionCall(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateShapeTool(t *testing.T) {
+	events := make(chan AgentEvent, 10)
+	agent := NewWithProvider(events, &MockProvider{}, "mock-model")
+
+	// A shape with several problems reports all of them at once
+	call := &llm.FunctionCall{
+		Name: "validate_shape",
+		Arguments: map[string]interface{}{
+			"id":   "bad_sphere",
+			"type": "sphere",
+			"properties": map[string]interface{}{
+				"radius": -1.0,
+				"material": map[string]interface{}{
+					"type": "metal",
+				},
+			},
+		},
+	}
+	req, ok := parseToolRequestFromFunctionCall(call).(*ValidateShapeRequest)
+	if !ok {
+		t.Fatal("Expected *ValidateShapeRequest")
+	}
+	if req.Target() != "bad_sphere" {
+		t.Errorf("Expected target 'bad_sphere', got %q", req.Target())
+	}
+
+	result := agent.executeToolRequests(context.Background(), req, "call_1")
+	if !result.Success {
+		t.Fatalf("Expected validate_shape itself to succeed, got errors: %v", result.Errors)
+	}
+	resultMap := result.Result.(map[string]interface{})
+	if resultMap["valid"] != false {
+		t.Errorf("Expected valid=false, got %v", resultMap["valid"])
+	}
+	if errs := resultMap["errors"].([]string); len(errs) < 3 {
+		t.Errorf("Expected at least 3 errors (center, radius, material), got %v", errs)
+	}
+
+	// Nothing was added to the scene
+	if agent.sceneManager.GetShapeCount() != 0 {
+		t.Errorf("Expected validate_shape not to modify the scene, got %d shapes", agent.sceneManager.GetShapeCount())
+	}
+
+	// A valid shape passes, and still isn't added
+	call.Arguments["properties"] = map[string]interface{}{
+		"center": []interface{}{0.0, 0.0, 0.0},
+		"radius": 1.0,
+	}
+	req = parseToolRequestFromFunctionCall(call).(*ValidateShapeRequest)
+	result = agent.executeToolRequests(context.Background(), req, "call_2")
+	resultMap = result.Result.(map[string]interface{})
+	if resultMap["valid"] != true {
+		t.Errorf("Expected valid=true, got errors %v", resultMap["errors"])
+	}
+	if errs := resultMap["errors"].([]string); len(errs) != 0 {
+		t.Errorf("Expected empty errors list, got %v", errs)
+	}
+	if agent.sceneManager.GetShapeCount() != 0 {
+		t.Errorf("Expected validate_shape not to modify the scene, got %d shapes", agent.sceneManager.GetShapeCount())
+	}
+}
+
+func TestValidateLightTool(t *testing.T) {
+	events := make(chan AgentEvent, 10)
+	agent := NewWithProvider(events, &MockProvider{}, "mock-model")
+
+	existing := LightRequest{
+		ID:   "key",
+		Type: "point_spot_light",
+		Properties: map[string]interface{}{
+			"center":   []interface{}{0.0, 5.0, 0.0},
+			"emission": []interface{}{5.0, 5.0, 5.0},
+		},
+	}
+	if err := agent.sceneManager.AddLights([]LightRequest{existing}); err != nil {
+		t.Fatalf("Failed to add light: %v", err)
+	}
+
+	// Duplicate ID is reported alongside property errors
+	call := &llm.FunctionCall{
+		Name: "validate_light",
+		Arguments: map[string]interface{}{
+			"id":   "key",
+			"type": "area_sphere_light",
+			"properties": map[string]interface{}{
+				"center": []interface{}{0.0, 5.0, 0.0},
+			},
+		},
+	}
+	req := parseToolRequestFromFunctionCall(call).(*ValidateLightRequest)
+	result := agent.executeToolRequests(context.Background(), req, "call_1")
+	resultMap := result.Result.(map[string]interface{})
+	if resultMap["valid"] != false {
+		t.Fatal("Expected valid=false")
+	}
+
+	errs := resultMap["errors"].([]string)
+	foundDuplicate := false
+	for _, e := range errs {
+		if strings.Contains(e, "already exists") {
+			foundDuplicate = true
+		}
+	}
+	if !foundDuplicate {
+		t.Errorf("Expected duplicate ID error, got %v", errs)
+	}
+	if len(errs) < 3 {
+		t.Errorf("Expected radius, emission and duplicate errors, got %v", errs)
+	}
+	if len(agent.sceneManager.state.Lights) != 1 {
+		t.Errorf("Expected validate_light not to modify the scene, got %d lights", len(agent.sceneManager.state.Lights))
+	}
+}
+
+// TestToolDeclarationsMatchParsers verifies every advertised tool has a parser case and vice versa
+func TestToolDeclarationsMatchParsers(t *testing.T) {
+	// Tool names handled by parseToolRequestFromFunctionCall
+	parsed := []string{
+		"create_shape", "update_shape", "revert_shape", "remove_shape", "remove_shapes", "rename_shapes", "array_shapes", "snap_shapes", "export_shape", "import_shape", "import_dsl",
+		"create_light", "light_shape", "setup_three_point_lighting", "update_light", "remove_light", "get_light", "set_light_enabled", "solo_light", "unsolo_light",
+		"set_environment_lighting", "set_camera", "get_camera", "add_camera", "select_camera", "remove_camera", "zoom_camera", "set_camera_preset", "frame_shape", "set_aspect_ratio",
+		"render_scene", "preview_material", "set_default_material", "set_render_quality", "set_post_process", "get_scene_state", "get_scene_statistics",
+		"checkpoint_scene", "restore_checkpoint", "list_checkpoints",
+		"find_shapes_by_tag", "scene_is_empty", "is_point_occupied", "check_overlap", "measure_distance", "validate_shape", "validate_light", "done",
+	}
+
+	declared := make(map[string]bool)
+	for _, decl := range getAllToolDeclarations() {
+		declared[decl.Name] = true
+
+		req := parseToolRequestFromFunctionCall(&llm.FunctionCall{Name: decl.Name, Arguments: map[string]interface{}{}})
+		if req == nil {
+			t.Errorf("Tool '%s' is declared but has no parser case", decl.Name)
+		} else if req.ToolName() != decl.Name {
+			t.Errorf("Tool '%s' parses to request named '%s'", decl.Name, req.ToolName())
+		}
+	}
+
+	for _, name := range parsed {
+		if !declared[name] {
+			t.Errorf("Tool '%s' has a parser case but is not declared", name)
+		}
+		if parseToolRequestFromFunctionCall(&llm.FunctionCall{Name: name, Arguments: map[string]interface{}{}}) == nil {
+			t.Errorf("Tool '%s' is missing from parseToolRequestFromFunctionCall", name)
+		}
+	}
+	if len(declared) != len(parsed) {
+		t.Errorf("Expected %d declared tools, got %d", len(parsed), len(declared))
+	}
+
+	// The Gemini declarations are derived from getAllTools, including render_scene and get_scene_state
+	for _, name := range []string{"render_scene", "get_scene_state"} {
+		if !declared[name] {
+			t.Errorf("Expected '%s' to be declared", name)
+		}
+	}
+}
+
+// TestToolRegistryComplete verifies every registered tool can be declared, parsed, and executed
+func TestToolRegistryComplete(t *testing.T) {
+	if len(toolOrder) != len(toolRegistry) {
+		t.Errorf("toolOrder lists %d tools but the registry has %d", len(toolOrder), len(toolRegistry))
+	}
+
+	seen := make(map[string]bool)
+	for _, name := range toolOrder {
+		if seen[name] {
+			t.Errorf("Tool '%s' is listed twice in toolOrder", name)
+		}
+		seen[name] = true
+		if _, ok := toolRegistry[name]; !ok {
+			t.Errorf("Tool '%s' is in toolOrder but not registered", name)
+		}
+	}
+
+	for name, spec := range toolRegistry {
+		if spec.tool == nil || spec.parse == nil || spec.execute == nil {
+			t.Errorf("Tool '%s' is missing a declaration, parser, or handler", name)
+			continue
+		}
+		if declared := spec.tool().Name; declared != name {
+			t.Errorf("Tool registered as '%s' declares itself as '%s'", name, declared)
+		}
+		req := spec.parse(&llm.FunctionCall{Name: name, Arguments: map[string]interface{}{}})
+		if req == nil || req.ToolName() != name {
+			t.Errorf("Tool '%s' does not parse to a request with the same name", name)
+		}
+	}
+}
+
+func TestToolsForProviderOutputParameters(t *testing.T) {
+	renderParams := func(tools []llm.Tool) map[string]*llm.Schema {
+		for _, tool := range tools {
+			if tool.Name == "render_scene" {
+				return tool.Parameters.Properties
+			}
+		}
+		t.Fatal("Expected render_scene to be offered")
+		return nil
+	}
+
+	// Without an output directory, a file can't be written, so the parameters aren't offered
+	params := renderParams(getToolsForProvider(true, false))
+	for _, name := range renderOutputParameters {
+		if _, ok := params[name]; ok {
+			t.Errorf("Expected '%s' to be omitted without an output directory", name)
+		}
+	}
+	if _, ok := params["mode"]; !ok {
+		t.Error("Expected render_scene to keep its other parameters")
+	}
+
+	params = renderParams(getToolsForProvider(true, true))
+	for _, name := range renderOutputParameters {
+		if _, ok := params[name]; !ok {
+			t.Errorf("Expected '%s' to be offered with an output directory", name)
+		}
+	}
+}
+
+func TestExecuteUnknownTool(t *testing.T) {
+	events := make(chan AgentEvent, 100)
+	agent := NewWithProvider(events, &MockProvider{}, "mock-model")
+
+	req := &BaseToolRequest{ToolType: "launch_rocket"}
+	result := agent.executeToolRequests(context.Background(), req, "test_call_1")
+	if result.Success {
+		t.Error("Expected unknown tool to fail")
+	}
+}
+
+func TestDielectricOnFlatShapeWarns(t *testing.T) {
+	events := make(chan AgentEvent, 10)
+	agent := NewWithProvider(events, &MockProvider{}, "mock-model")
+	call := func(name string, args map[string]interface{}) ToolResult {
+		req := parseToolRequestFromFunctionCall(&llm.FunctionCall{Name: name, Arguments: args})
+		return agent.executeToolRequests(context.Background(), req, "test_call")
+	}
+
+	// The quad is still created, with a warning alongside the result
+	result := call("create_shape", map[string]interface{}{
+		"id":   "pane",
+		"type": "quad",
+		"properties": map[string]interface{}{
+			"corner":   []interface{}{-1.0, 0.0, 0.0},
+			"u":        []interface{}{2.0, 0.0, 0.0},
+			"v":        []interface{}{0.0, 2.0, 0.0},
+			"material": map[string]interface{}{"type": "dielectric", "refractive_index": 1.5},
+		},
+	})
+	if !result.Success {
+		t.Fatalf("Expected create_shape to succeed, got errors: %v", result.Errors)
+	}
+	if len(result.Warnings) != 1 || !strings.Contains(result.Warnings[0], "no thickness") {
+		t.Errorf("Expected a dielectric warning, got %v", result.Warnings)
+	}
+	if warnings := agent.sceneManager.GetSceneState()["warnings"].([]string); len(warnings) != 1 {
+		t.Errorf("Expected get_scene_state to repeat the warning, got %v", warnings)
+	}
+
+	// Presets and mixes count, but a solid glass sphere is fine
+	tests := []struct {
+		name       string
+		shapeType  string
+		properties map[string]interface{}
+		warns      bool
+	}{
+		{"glass preset disc", "disc", map[string]interface{}{
+			"center": []interface{}{0.0, 0.0, 0.0}, "normal": []interface{}{0.0, 1.0, 0.0}, "radius": 1.0,
+			"material": map[string]interface{}{"preset": "glass"},
+		}, true},
+		{"mixed dielectric quad", "quad", map[string]interface{}{
+			"corner": []interface{}{0.0, 0.0, 0.0}, "u": []interface{}{1.0, 0.0, 0.0}, "v": []interface{}{0.0, 1.0, 0.0},
+			"material": map[string]interface{}{"type": "mix", "factor": 0.5,
+				"material_a": map[string]interface{}{"type": "lambertian", "albedo": []interface{}{0.5, 0.5, 0.5}},
+				"material_b": map[string]interface{}{"type": "dielectric", "refractive_index": 1.5}},
+		}, true},
+		{"glass sphere", "sphere", map[string]interface{}{
+			"center": []interface{}{0.0, 0.0, 0.0}, "radius": 1.0,
+			"material": map[string]interface{}{"preset": "glass"},
+		}, false},
+	}
+	for _, tt := range tests {
+		result := call("validate_shape", map[string]interface{}{"id": "check", "type": tt.shapeType, "properties": tt.properties})
+		resultMap := result.Result.(map[string]interface{})
+		if resultMap["valid"] != true {
+			t.Errorf("%s: expected a valid shape, got errors %v", tt.name, resultMap["errors"])
+		}
+		if warnings := resultMap["warnings"].([]string); (len(warnings) > 0) != tt.warns {
+			t.Errorf("%s: expected warning=%v, got %v", tt.name, tt.warns, warnings)
+		}
+	}
+}
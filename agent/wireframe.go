@@ -0,0 +1,270 @@
+package agent
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+)
+
+// RenderMode selects how render_scene draws the scene
+type RenderMode string
+
+const (
+	RenderModeShaded    RenderMode = "shaded"    // Path traced image (default)
+	RenderModeWireframe RenderMode = "wireframe" // Shape edges only, for checking placement and proportions
+)
+
+// ParseRenderMode converts a tool argument to a RenderMode
+// An empty mode defaults to shaded
+func ParseRenderMode(mode string) (RenderMode, error) {
+	switch RenderMode(mode) {
+	case "", RenderModeShaded:
+		return RenderModeShaded, nil
+	case RenderModeWireframe:
+		return RenderModeWireframe, nil
+	default:
+		return "", fmt.Errorf("unsupported render mode '%s' (supported: shaded, wireframe)", mode)
+	}
+}
+
+// circleSegments is the number of line segments used to approximate circles in wireframes
+const circleSegments = 32
+
+// segment is a 3D line segment
+type segment struct {
+	a, b vec3
+}
+
+// drawSegment clips a world-space segment to the near plane and rasterizes it
+func (c sceneCamera) drawSegment(img *image.RGBA, s segment, col color.RGBA) {
+	const near = 1e-3
+
+	a, b := c.toCamera(s.a), c.toCamera(s.b)
+	if a[2] < near && b[2] < near {
+		return // Entirely behind the camera
+	}
+	if a[2] < near {
+		a = a.add(b.sub(a).scale((near - a[2]) / (b[2] - a[2])))
+	} else if b[2] < near {
+		b = b.add(a.sub(b).scale((near - b[2]) / (a[2] - b[2])))
+	}
+
+	x0, y0 := c.project(a)
+	x1, y1 := c.project(b)
+	drawLine(img, x0, y0, x1, y1, col)
+}
+
+// drawLine rasterizes a line by stepping one pixel at a time along its longer axis
+func drawLine(img *image.RGBA, x0, y0, x1, y1 float64, col color.RGBA) {
+	bounds := img.Bounds()
+
+	// Skip lines that are entirely off one side of the image
+	if (x0 < 0 && x1 < 0) || (y0 < 0 && y1 < 0) ||
+		(x0 >= float64(bounds.Max.X) && x1 >= float64(bounds.Max.X)) ||
+		(y0 >= float64(bounds.Max.Y) && y1 >= float64(bounds.Max.Y)) {
+		return
+	}
+
+	dx, dy := x1-x0, y1-y0
+	steps := math.Ceil(math.Max(math.Abs(dx), math.Abs(dy)))
+	if steps == 0 {
+		steps = 1
+	}
+	// Lines that clip the near plane can project far off-screen; cap the work per line
+	steps = math.Min(steps, 4*float64(bounds.Dx()+bounds.Dy()))
+
+	for i := 0.0; i <= steps; i++ {
+		x := int(math.Floor(x0 + dx*i/steps))
+		y := int(math.Floor(y0 + dy*i/steps))
+		if image.Pt(x, y).In(bounds) {
+			img.SetRGBA(x, y, col)
+		}
+	}
+}
+
+// circle returns segments approximating a circle around center with the given normal
+func circle(center, normal vec3, radius float64) []segment {
+	n := normal.normalize()
+
+	// Build an orthonormal basis in the circle's plane
+	helper := vec3{1, 0, 0}
+	if math.Abs(n[0]) > 0.9 {
+		helper = vec3{0, 1, 0}
+	}
+	e1 := n.cross(helper).normalize()
+	e2 := n.cross(e1)
+
+	point := func(i int) vec3 {
+		angle := 2 * math.Pi * float64(i) / circleSegments
+		return center.add(e1.scale(radius * math.Cos(angle))).add(e2.scale(radius * math.Sin(angle)))
+	}
+
+	segments := make([]segment, 0, circleSegments)
+	for i := 0; i < circleSegments; i++ {
+		segments = append(segments, segment{point(i), point(i + 1)})
+	}
+	return segments
+}
+
+// frustumEdges returns the outline of a cylinder or cone between two circular ends
+func frustumEdges(base, top vec3, baseRadius, topRadius float64) []segment {
+	axis := top.sub(base)
+	segments := circle(base, axis, baseRadius)
+	if topRadius > 0 {
+		segments = append(segments, circle(top, axis, topRadius)...)
+	}
+
+	// Four side lines connecting the ends
+	baseRing := circle(base, axis, baseRadius)
+	topRing := circle(top, axis, topRadius)
+	for i := 0; i < circleSegments; i += circleSegments / 4 {
+		segments = append(segments, segment{baseRing[i].a, topRing[i].a})
+	}
+	return segments
+}
+
+// shapeEdges tessellates a shape into line segments
+// Unknown shape types produce no edges
+func shapeEdges(shape ShapeRequest) []segment {
+	props := shape.Properties
+	switch shape.Type {
+	case "sphere":
+		center := vec3Property(props, "center", vec3{})
+		radius, _ := extractFloat(props, "radius")
+		rotation := vec3Property(props, "rotation", vec3{})
+
+		// Three great circles, oriented by the sphere's rotation
+		var segments []segment
+		for _, axis := range []vec3{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}} {
+			segments = append(segments, circle(center, axis.rotateXYZ(rotation), radius)...)
+		}
+		return segments
+
+	case "ellipsoid":
+		center := vec3Property(props, "center", vec3{})
+		radii := vec3Property(props, "radii", vec3{1, 1, 1})
+
+		// The three great circles of a unit sphere, scaled into ellipses
+		scaled := func(p vec3) vec3 { return center.add(vec3{p[0] * radii[0], p[1] * radii[1], p[2] * radii[2]}) }
+		var segments []segment
+		for _, axis := range []vec3{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}} {
+			for _, s := range circle(vec3{}, axis, 1) {
+				segments = append(segments, segment{scaled(s.a), scaled(s.b)})
+			}
+		}
+		return segments
+
+	case "pyramid":
+		center := vec3Property(props, "center", vec3{})
+		baseSize, ok := extractFloatArray(props, "base_size", 2)
+		if !ok {
+			return nil
+		}
+		height, _ := extractFloat(props, "height")
+		corners := pyramidVertices(center, baseSize[0], baseSize[1], height)
+		apex := corners[4]
+
+		// The base outline and an edge from each corner up to the apex
+		var segments []segment
+		for i := 0; i < 4; i++ {
+			segments = append(segments, segment{corners[i], corners[(i+1)%4]}, segment{corners[i], apex})
+		}
+		return segments
+
+	case "box":
+		center := vec3Property(props, "center", vec3{})
+		half := vec3Property(props, "dimensions", vec3{}).scale(0.5)
+		rotation := vec3Property(props, "rotation", vec3{})
+
+		var corners [8]vec3
+		for i := range corners {
+			offset := vec3{half[0], half[1], half[2]}
+			for axis := 0; axis < 3; axis++ {
+				if i&(1<<axis) != 0 {
+					offset[axis] = -offset[axis]
+				}
+			}
+			corners[i] = center.add(offset.rotateXYZ(rotation))
+		}
+
+		// Connect corners that differ in exactly one axis
+		var segments []segment
+		for i := range corners {
+			for axis := 0; axis < 3; axis++ {
+				if j := i | (1 << axis); j != i {
+					segments = append(segments, segment{corners[i], corners[j]})
+				}
+			}
+		}
+		return segments
+
+	case "quad":
+		corner := vec3Property(props, "corner", vec3{})
+		u := vec3Property(props, "u", vec3{})
+		v := vec3Property(props, "v", vec3{})
+		return []segment{
+			{corner, corner.add(u)},
+			{corner.add(u), corner.add(u).add(v)},
+			{corner.add(u).add(v), corner.add(v)},
+			{corner.add(v), corner},
+		}
+
+	case "disc":
+		center := vec3Property(props, "center", vec3{})
+		normal := vec3Property(props, "normal", vec3{0, 1, 0})
+		radius, _ := extractFloat(props, "radius")
+		return circle(center, normal, radius)
+
+	case "cylinder":
+		base := vec3Property(props, "base_center", vec3{})
+		top := vec3Property(props, "top_center", vec3{})
+		radius, _ := extractFloat(props, "radius")
+		return frustumEdges(base, top, radius, radius)
+
+	case "cone":
+		base := vec3Property(props, "base_center", vec3{})
+		top := vec3Property(props, "top_center", vec3{})
+		baseRadius, _ := extractFloat(props, "base_radius")
+		topRadius, _ := extractFloat(props, "top_radius")
+		return frustumEdges(base, top, baseRadius, topRadius)
+	}
+
+	return nil
+}
+
+// wireframeColor picks a line color from the shape's material, brightened so dark materials stay visible
+func wireframeColor(shape ShapeRequest) color.RGBA {
+	albedo := vec3{0.85, 0.85, 0.85}
+	if mat, ok := extractMaterial(shape.Properties); ok {
+		albedo = vec3Property(mat, "albedo", albedo)
+	}
+
+	channel := func(v float64) uint8 {
+		return uint8(math.Round((0.3 + 0.7*math.Min(math.Max(v, 0), 1)) * 255))
+	}
+	return color.RGBA{channel(albedo[0]), channel(albedo[1]), channel(albedo[2]), 255}
+}
+
+// RenderWireframe draws the edges of every shape in the scene as seen from the scene camera
+// It doesn't path trace, so it is near-instant and noise-free, which makes it useful for
+// checking placement, overlap, and proportions rather than appearance.
+func RenderWireframe(state *SceneState, width, height int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	background := color.RGBA{20, 20, 28, 255}
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetRGBA(x, y, background)
+		}
+	}
+
+	camera := newSceneCamera(state.Camera, width, height)
+	for _, shape := range state.Shapes {
+		col := wireframeColor(shape)
+		for _, s := range shapeEdges(shape) {
+			camera.drawSegment(img, s, col)
+		}
+	}
+
+	return img
+}
@@ -0,0 +1,149 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"testing"
+)
+
+func TestParseRenderMode(t *testing.T) {
+	tests := []struct {
+		input       string
+		expected    RenderMode
+		expectError bool
+	}{
+		{"", RenderModeShaded, false},
+		{"shaded", RenderModeShaded, false},
+		{"wireframe", RenderModeWireframe, false},
+		{"xray", "", true},
+	}
+
+	for _, tt := range tests {
+		mode, err := ParseRenderMode(tt.input)
+		if tt.expectError {
+			if err == nil {
+				t.Errorf("ParseRenderMode(%q) expected error, got %q", tt.input, mode)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseRenderMode(%q) unexpected error: %v", tt.input, err)
+		}
+		if mode != tt.expected {
+			t.Errorf("ParseRenderMode(%q) = %q, want %q", tt.input, mode, tt.expected)
+		}
+	}
+}
+
+// countLitPixels counts pixels that differ from the top-left (background) pixel
+func countLitPixels(img image.Image) int {
+	bounds := img.Bounds()
+	background := img.At(bounds.Min.X, bounds.Min.Y)
+	count := 0
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if img.At(x, y) != background {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+func TestRenderWireframe(t *testing.T) {
+	camera := CameraInfo{Center: []float64{0, 0, 5}, LookAt: []float64{0, 0, 0}, VFov: 45}
+
+	shapes := []ShapeRequest{
+		{ID: "sphere", Type: "sphere", Properties: map[string]interface{}{
+			"center": []interface{}{0.0, 0.0, 0.0}, "radius": 1.0,
+		}},
+		{ID: "box", Type: "box", Properties: map[string]interface{}{
+			"center": []interface{}{0.0, 0.0, 0.0}, "dimensions": []interface{}{1.0, 1.0, 1.0},
+			"rotation": []interface{}{0.3, 0.6, 0.0},
+		}},
+		{ID: "quad", Type: "quad", Properties: map[string]interface{}{
+			"corner": []interface{}{-1.0, -1.0, 0.0}, "u": []interface{}{2.0, 0.0, 0.0}, "v": []interface{}{0.0, 2.0, 0.0},
+		}},
+		{ID: "disc", Type: "disc", Properties: map[string]interface{}{
+			"center": []interface{}{0.0, 0.0, 0.0}, "normal": []interface{}{0.0, 0.0, 1.0}, "radius": 1.0,
+		}},
+		{ID: "cylinder", Type: "cylinder", Properties: map[string]interface{}{
+			"base_center": []interface{}{0.0, -1.0, 0.0}, "top_center": []interface{}{0.0, 1.0, 0.0}, "radius": 0.5, "capped": true,
+		}},
+		{ID: "cone", Type: "cone", Properties: map[string]interface{}{
+			"base_center": []interface{}{0.0, -1.0, 0.0}, "top_center": []interface{}{0.0, 1.0, 0.0},
+			"base_radius": 0.5, "top_radius": 0.0, "capped": true,
+		}},
+	}
+
+	for _, shape := range shapes {
+		t.Run(shape.Type, func(t *testing.T) {
+			state := &SceneState{Shapes: []ShapeRequest{shape}, Camera: camera}
+			img := RenderWireframe(state, 200, 150)
+
+			if img.Bounds().Dx() != 200 || img.Bounds().Dy() != 150 {
+				t.Fatalf("Expected 200x150 image, got %v", img.Bounds())
+			}
+			if countLitPixels(img) == 0 {
+				t.Errorf("Expected %s edges to be drawn", shape.Type)
+			}
+		})
+	}
+}
+
+func TestRenderWireframeSkipsShapesBehindCamera(t *testing.T) {
+	state := &SceneState{
+		Camera: CameraInfo{Center: []float64{0, 0, 5}, LookAt: []float64{0, 0, 0}, VFov: 45},
+		Shapes: []ShapeRequest{
+			{ID: "behind", Type: "sphere", Properties: map[string]interface{}{
+				"center": []interface{}{0.0, 0.0, 10.0}, "radius": 1.0,
+			}},
+		},
+	}
+
+	if lit := countLitPixels(RenderWireframe(state, 200, 150)); lit != 0 {
+		t.Errorf("Expected nothing drawn for a shape behind the camera, got %d lit pixels", lit)
+	}
+}
+
+func TestRenderSceneToolWireframe(t *testing.T) {
+	events := make(chan AgentEvent, 100)
+	agent := NewWithProvider(events, &MockProvider{}, "mock-model")
+	agent.sceneManager = newRenderableSceneManager(t)
+
+	req := &RenderSceneRequest{
+		BaseToolRequest: BaseToolRequest{ToolType: "render_scene"},
+		Mode:            "wireframe",
+	}
+	result := agent.executeToolRequests(context.Background(), req, "test_call_1")
+	if !result.Success {
+		t.Fatalf("Expected wireframe render to succeed, got errors: %v", result.Errors)
+	}
+
+	metadata, ok := result.Result.(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected metadata map, got %T", result.Result)
+	}
+	if metadata["mode"] != RenderModeWireframe {
+		t.Errorf("Expected mode wireframe in metadata, got %v", metadata["mode"])
+	}
+
+	img, err := png.Decode(bytes.NewReader(req.RenderedImage))
+	if err != nil {
+		t.Fatalf("Expected a PNG image, got decode error: %v", err)
+	}
+	if countLitPixels(img) == 0 {
+		t.Error("Expected wireframe image to contain edges")
+	}
+
+	// Unknown modes are rejected
+	badReq := &RenderSceneRequest{
+		BaseToolRequest: BaseToolRequest{ToolType: "render_scene"},
+		Mode:            "xray",
+	}
+	if result := agent.executeToolRequests(context.Background(), badReq, "test_call_2"); result.Success {
+		t.Error("Expected unknown render mode to fail")
+	}
+}
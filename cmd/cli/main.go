@@ -0,0 +1,156 @@
+// Command cli generates a scene from a single prompt without the web server
+//
+// It runs the agent until the model finishes, renders the final scene and writes it as a
+// PNG, which makes scene generation scriptable in CI and batch pipelines:
+//
+//	GOOGLE_API_KEY=... go run ./cmd/cli -prompt "a snowman on a frozen lake" -out snowman.png
+//
+// Providers are configured from GOOGLE_API_KEY, ANTHROPIC_API_KEY and OPENROUTER_API_KEY.
+// The model's replies are printed to stdout and progress is logged to stderr. The exit
+// status is non-zero if the agent fails, the scene is empty, or the image can't be written.
+// With -output-dir, the model may also save renders of its own under that directory.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/df07/scene-llm/agent"
+	"github.com/df07/scene-llm/agent/llm"
+	"github.com/df07/scene-llm/agent/llm/providers"
+)
+
+// options holds the command line flags
+type options struct {
+	prompt     string
+	out        string
+	model      string
+	quality    string
+	maxTurns   int
+	llmTimeout time.Duration
+	outputDir  string
+}
+
+func main() {
+	var opts options
+	flag.StringVar(&opts.prompt, "prompt", "", "Description of the scene to create (required)")
+	flag.StringVar(&opts.out, "out", "scene.png", "Path of the image to write; a .jpg or .jpeg extension writes a JPEG, anything else a PNG")
+	flag.StringVar(&opts.model, "model", "", "Model ID to use (default: first available model)")
+	flag.StringVar(&opts.quality, "quality", "high", "Final render quality: preview, draft, high, or auto")
+	flag.IntVar(&opts.maxTurns, "max-turns", 0, fmt.Sprintf("Model calls allowed for the prompt, 1-%d (default 10)", agent.MaxTurnsLimit))
+	flag.DurationVar(&opts.llmTimeout, "llm-timeout", 0, "Longest a single model call may take before it is retried, e.g. 90s (default 5m)")
+	flag.StringVar(&opts.outputDir, "output-dir", "", "Directory the model may save its own renders to with render_scene's output_path (default: none)")
+	flag.Parse()
+
+	// Stop the agent and any render cleanly on Ctrl-C
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := run(ctx, opts); err != nil {
+		log.Printf("Error: %v", err)
+		os.Exit(1)
+	}
+}
+
+// run generates the scene described by opts.prompt and writes its render to opts.out
+func run(ctx context.Context, opts options) error {
+	if opts.prompt == "" {
+		return fmt.Errorf("-prompt is required")
+	}
+	quality := agent.RenderQuality(opts.quality)
+	switch quality {
+	case agent.QualityPreview, agent.QualityDraft, agent.QualityHigh, agent.QualityAuto:
+	default:
+		return fmt.Errorf("unsupported quality '%s' (supported: preview, draft, high, auto)", opts.quality)
+	}
+	if opts.maxTurns < 0 || opts.maxTurns > agent.MaxTurnsLimit {
+		return fmt.Errorf("-max-turns must be between 1 and %d, or 0 for the default", agent.MaxTurnsLimit)
+	}
+
+	registry, err := providers.FromEnv(ctx)
+	if err != nil {
+		return err
+	}
+	modelID := opts.model
+	if modelID == "" {
+		modelID = registry.ListModels()[0]
+	}
+	provider, err := registry.GetProviderForModel(modelID)
+	if err != nil {
+		return err
+	}
+	log.Printf("Using model %s", modelID)
+
+	// Report progress while the agent works
+	events := make(chan agent.AgentEvent, 100)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for event := range events {
+			switch e := event.(type) {
+			case agent.ResponseEvent:
+				if !e.Thought {
+					fmt.Println(e.Text)
+				}
+			case agent.ToolCallEvent:
+				if e.Success {
+					log.Printf("%s: ok (%dms)", e.Request.ToolName(), e.Duration)
+				} else {
+					log.Printf("%s: %s", e.Request.ToolName(), e.Error)
+				}
+			case agent.ErrorEvent:
+				log.Printf("Agent error: %s", e.Message)
+			}
+		}
+	}()
+
+	ag := agent.NewWithProvider(events, provider, modelID)
+	ag.SetMaxTurns(opts.maxTurns)
+	ag.SetCallTimeout(opts.llmTimeout)
+	ag.SetOutputDir(opts.outputDir)
+	conversation := []llm.Message{{Role: llm.RoleUser, Parts: []llm.Part{{Type: llm.PartTypeText, Text: opts.prompt}}}}
+	_, err = ag.ProcessMessage(ctx, conversation)
+	close(events)
+	<-done
+	if err != nil {
+		return fmt.Errorf("agent failed: %w", err)
+	}
+
+	// Render the final scene the way the web UI does
+	raytracerScene, err := ag.GetSceneManager().ToRaytracerScene()
+	if err != nil {
+		return fmt.Errorf("failed to create scene: %w", err)
+	}
+	if len(raytracerScene.Shapes) == 0 {
+		return fmt.Errorf("the agent finished without adding any shapes")
+	}
+	settings := ag.SceneRenderSettings().Apply(ag.GetSceneManager().RenderSettings(quality).WithAspectRatio(raytracerScene.CameraConfig.AspectRatio))
+
+	log.Printf("Rendering %dx%d at %s quality...", settings.Width, settings.Height, quality)
+	img, err := agent.RenderImage(ctx, raytracerScene, settings)
+	if err != nil {
+		return err
+	}
+	img = ag.GetSceneManager().ShadowCatcherPass().Apply(img)
+	img = settings.PostProcess.Apply(img)
+
+	// Any extension other than .jpg or .jpeg is written as a PNG
+	format, err := agent.ImageFormatForPath(opts.out)
+	if err != nil {
+		format = agent.ImageFormatPNG
+	}
+	encoded, _, err := agent.EncodeImage(img, format, 0)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(opts.out, encoded, 0o644); err != nil {
+		return fmt.Errorf("failed to write image: %w", err)
+	}
+	log.Printf("Wrote %s", opts.out)
+	return nil
+}
@@ -7,6 +7,7 @@ replace github.com/df07/go-progressive-raytracer => ../go-progressive-raytracer
 require (
 	github.com/anthropics/anthropic-sdk-go v1.19.0
 	github.com/df07/go-progressive-raytracer v0.0.0-20251028233309-c125732e0a88
+	github.com/revrost/go-openrouter v1.1.5
 	google.golang.org/genai v1.25.0
 )
 
@@ -19,7 +20,6 @@ require (
 	github.com/google/s2a-go v0.1.8 // indirect
 	github.com/googleapis/enterprise-certificate-proxy v0.3.4 // indirect
 	github.com/gorilla/websocket v1.5.3 // indirect
-	github.com/revrost/go-openrouter v1.1.5 // indirect
 	github.com/tidwall/gjson v1.18.0 // indirect
 	github.com/tidwall/match v1.1.1 // indirect
 	github.com/tidwall/pretty v1.2.1 // indirect
@@ -1,13 +1,20 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/df07/scene-llm/web/server"
 )
 
+// shutdownTimeout bounds how long a signal-triggered shutdown waits for sessions and renders
+const shutdownTimeout = 30 * time.Second
+
 func main() {
 	// Parse command line flags
 	port := flag.Int("port", 8081, "Port to serve on")
@@ -19,8 +26,25 @@ func main() {
 	log.Printf("Scene LLM Web Server")
 	log.Printf("Visit http://localhost:%d to start creating scenes", *port)
 
-	if err := webServer.Start(); err != nil {
-		log.Printf("Error starting server: %v", err)
-		os.Exit(1)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errs := make(chan error, 1)
+	go func() { errs <- webServer.Start() }()
+
+	select {
+	case err := <-errs:
+		if err != nil {
+			log.Printf("Error starting server: %v", err)
+			os.Exit(1)
+		}
+	case <-ctx.Done():
+		log.Printf("Shutting down...")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := webServer.Stop(shutdownCtx); err != nil {
+			log.Printf("Error stopping server: %v", err)
+			os.Exit(1)
+		}
 	}
 }
@@ -1,21 +1,19 @@
 package server
 
 import (
-	"bytes"
 	"context"
 	"crypto/rand"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"image/png"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
-	"github.com/df07/go-progressive-raytracer/pkg/integrator"
-	"github.com/df07/go-progressive-raytracer/pkg/renderer"
 	"github.com/df07/go-progressive-raytracer/pkg/scene"
 	"github.com/df07/scene-llm/agent"
 	"github.com/df07/scene-llm/agent/llm"
@@ -23,21 +21,38 @@ import (
 
 // ChatSession represents an ongoing conversation with persistent agent state
 type ChatSession struct {
-	ID       string             `json:"id"`
-	Messages []llm.Message      `json:"messages"`
-	Agent    *agent.Agent       `json:"-"` // Agent with persistent SceneManager
-	Provider llm.LLMProvider    // LLM provider for this session (keeps connection warm)
-	ModelID  string             // Current model ID (e.g., "gemini-2.5-flash")
-	cancel   context.CancelFunc // Function to cancel ongoing processing
-	mutex    sync.Mutex         // Protects cancel function
+	ID         string             `json:"id"`
+	Messages   []llm.Message      `json:"messages"`
+	Agent      *agent.Agent       `json:"-"` // Agent with persistent SceneManager
+	Provider   llm.LLMProvider    // LLM provider for this session (keeps connection warm)
+	ModelID    string             // Current model ID (e.g., "gemini-2.5-flash")
+	cancel     context.CancelFunc // Function to cancel ongoing processing
+	lastRender []byte             // Encoded image of the most recent scene render, nil until the first render
+	renderMIME string             // MIME type of lastRender
+	// imageFormat and imageQuality are how the client wants scene renders encoded, set by
+	// chat and render requests; empty and 0 are PNG
+	imageFormat  string
+	imageQuality int
+	mutex        sync.Mutex         // Protects cancel function, lastRender and the image encoding
+	replay       *eventReplayBuffer // Recent events for reconnecting clients, created by Server.replayBuffer
 }
 
 // ChatMessage represents a chat message request
 type ChatMessage struct {
 	SessionID string `json:"session_id,omitempty"`
 	Message   string `json:"message"`
-	Quality   string `json:"quality,omitempty"`  // Render quality: "draft" or "high"
+	Quality   string `json:"quality,omitempty"`  // Render quality: "preview", "draft", "high" or "auto"
 	ModelID   string `json:"model_id,omitempty"` // Model to use for new sessions
+	// ThinkingBudget sets the session's reasoning token budget (0 disables thinking, negative restores the default)
+	ThinkingBudget *int `json:"thinking_budget,omitempty"`
+	// MaxTurns sets how many model calls the session's agent makes per message, from 1 to agent.MaxTurnsLimit
+	MaxTurns *int `json:"max_turns,omitempty"`
+	// Images are base64-encoded images attached to the message, optionally as data URLs
+	Images []string `json:"images,omitempty"`
+	// ImageFormat and ImageQuality set how the session's scene renders are sent: "png" (default)
+	// or "jpeg", with a JPEG quality from 1 to 100 (0 for the default)
+	ImageFormat  string `json:"image_format,omitempty"`
+	ImageQuality int    `json:"image_quality,omitempty"`
 }
 
 // ChatResponse represents the immediate response to a chat message
@@ -48,9 +63,12 @@ type ChatResponse struct {
 }
 
 // SSEChatEvent represents events sent via SSE
+// Seq numbers the events broadcast to a session, starting at 1; events sent to a single
+// connection, like connection_state and ping, have none.
 type SSEChatEvent struct {
 	Type string      `json:"type"`
 	Data interface{} `json:"data"`
+	Seq  uint64      `json:"seq,omitempty"`
 }
 
 // generateSessionID creates a new random session ID
@@ -128,11 +146,11 @@ func (s *Server) setSSEHeaders(w http.ResponseWriter) {
 
 // sendSSEEvent sends an SSE event to the client
 func (s *Server) sendSSEEvent(w http.ResponseWriter, eventType string, data interface{}) error {
-	event := SSEChatEvent{
-		Type: eventType,
-		Data: data,
-	}
+	return s.writeSSEEvent(w, SSEChatEvent{Type: eventType, Data: data})
+}
 
+// writeSSEEvent writes an event, including its sequence number, to the client
+func (s *Server) writeSSEEvent(w http.ResponseWriter, event SSEChatEvent) error {
 	jsonData, err := json.Marshal(event)
 	if err != nil {
 		return err
@@ -179,16 +197,22 @@ func (s *Server) removeSSEClient(sessionID string, client chan SSEChatEvent) {
 }
 
 // broadcastToSession sends an SSE event to all clients of a session
+// The event is numbered and kept in the session's replay buffer for clients that reconnect.
 func (s *Server) broadcastToSession(sessionID string, event SSEChatEvent) {
-	s.clientMutex.RLock()
-	clients := s.sseClients[sessionID]
-	s.clientMutex.RUnlock()
-
-	if clients == nil {
-		return
+	buffer := s.replayBuffer(sessionID)
+	if buffer == nil {
+		return // No such session, so no clients to send to
 	}
+	buffer.mutex.Lock()
+	defer buffer.mutex.Unlock()
+	event = buffer.add(event)
 
-	for client := range clients {
+	// Hold the lock while sending so removeSSEClient can't close a channel mid-send.
+	// Sends never block, and full clients are removed asynchronously.
+	s.clientMutex.RLock()
+	defer s.clientMutex.RUnlock()
+
+	for client := range s.sseClients[sessionID] {
 		select {
 		case client <- event:
 		default:
@@ -225,6 +249,20 @@ func (s *Server) handleChat(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if chatMsg.MaxTurns != nil && (*chatMsg.MaxTurns < 1 || *chatMsg.MaxTurns > agent.MaxTurnsLimit) {
+		response := ChatResponse{Status: "error", Error: fmt.Sprintf("max_turns must be between 1 and %d", agent.MaxTurnsLimit)}
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	if err := agent.ValidateImageEncoding(chatMsg.ImageFormat, chatMsg.ImageQuality); err != nil {
+		response := ChatResponse{Status: "error", Error: err.Error()}
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
 	// Get or create session
 	session := s.getOrCreateSession(chatMsg.SessionID, chatMsg.ModelID)
 	if session == nil {
@@ -232,15 +270,64 @@ func (s *Server) handleChat(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Add user message to conversation history
+	userMessage, err := buildUserMessage(chatMsg.Message, chatMsg.Images)
+	if err != nil {
+		response := ChatResponse{SessionID: session.ID, Status: "error", Error: err.Error()}
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	// Only one message is processed per session at a time: overlapping turns would interleave
+	// their conversation updates and edit the same scene. Claiming the session and adding the
+	// message happen under one lock so two requests can't both get through.
 	session.mutex.Lock()
-	userMessage := llm.Message{
-		Parts: []llm.Part{{Type: llm.PartTypeText, Text: chatMsg.Message}},
-		Role:  "user",
+	if session.cancel != nil {
+		session.mutex.Unlock()
+		response := ChatResponse{SessionID: session.ID, Status: "busy", Error: "A message is still being processed for this session - wait for it to finish or interrupt it"}
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(response)
+		return
 	}
+	// Only vision models can see attached images. The provider is read under the lock since
+	// set_model can swap it.
+	if len(chatMsg.Images) > 0 && !session.Provider.SupportsVision() {
+		session.mutex.Unlock()
+		response := ChatResponse{SessionID: session.ID, Status: "error", Error: "The selected model does not support image input"}
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+	ctx, cancel := context.WithCancel(s.stopCtx)
+	session.cancel = cancel
 	session.Messages = append(session.Messages, userMessage)
+	// Agent settings are changed while the session is claimed, so no turn is reading them
+	if chatMsg.MaxTurns != nil {
+		session.Agent.SetMaxTurns(*chatMsg.MaxTurns)
+	}
+	if chatMsg.ThinkingBudget != nil {
+		session.Agent.SetThinkingBudget(*chatMsg.ThinkingBudget)
+	}
+	if chatMsg.ImageFormat != "" {
+		session.imageFormat, session.imageQuality = chatMsg.ImageFormat, chatMsg.ImageQuality
+	}
 	session.mutex.Unlock()
 
+	// Parse quality setting (default to draft if not specified)
+	quality := agent.ParseRenderQuality(chatMsg.Quality)
+
+	// Process the message asynchronously (this will stream results via SSE)
+	if !s.startBackground(func() { s.processMessage(ctx, session, quality) }) {
+		cancel()
+		session.mutex.Lock()
+		session.cancel = nil
+		session.mutex.Unlock()
+		response := ChatResponse{SessionID: session.ID, Status: "error", Error: "The server is shutting down"}
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
 	// Return immediate acknowledgment with session ID
 	response := ChatResponse{
 		SessionID: session.ID,
@@ -248,18 +335,44 @@ func (s *Server) handleChat(w http.ResponseWriter, r *http.Request) {
 	}
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(response)
+}
 
-	// Parse quality setting (default to draft if not specified)
-	quality := agent.QualityDraft
-	if chatMsg.Quality == "high" {
-		quality = agent.QualityHigh
-	}
+// buildUserMessage builds a user message from its text and base64-encoded image attachments
+// Images may be plain base64 or data URLs; their type is detected from the decoded bytes.
+func buildUserMessage(text string, images []string) (llm.Message, error) {
+	parts := []llm.Part{{Type: llm.PartTypeText, Text: text}}
+	for i, encoded := range images {
+		// Strip a data URL prefix such as "data:image/png;base64,"
+		if strings.HasPrefix(encoded, "data:") {
+			comma := strings.Index(encoded, ",")
+			if comma < 0 {
+				return llm.Message{}, fmt.Errorf("image %d is not a valid data URL", i+1)
+			}
+			encoded = encoded[comma+1:]
+		}
 
-	// Process the message asynchronously (this will stream results via SSE)
-	go s.processMessage(session, chatMsg.Message, quality)
+		data, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return llm.Message{}, fmt.Errorf("image %d is not valid base64: %v", i+1, err)
+		}
+
+		mimeType := http.DetectContentType(data)
+		if !strings.HasPrefix(mimeType, "image/") {
+			return llm.Message{}, fmt.Errorf("image %d is not a supported image (detected %s)", i+1, mimeType)
+		}
+
+		parts = append(parts, llm.Part{
+			Type:      llm.PartTypeImage,
+			ImageData: &llm.ImageData{MIMEType: mimeType, Data: data},
+		})
+	}
+	return llm.Message{Role: "user", Parts: parts}, nil
 }
 
 // handleChatStream handles SSE connections for real-time chat updates
+// After connection_state, the session's buffered events numbered after the since query
+// parameter (default 0, for all of them) are replayed, so a client that reconnects with
+// the last seq it saw catches up on what it missed.
 func (s *Server) handleChatStream(w http.ResponseWriter, r *http.Request) {
 	s.setSSEHeaders(w)
 
@@ -268,6 +381,14 @@ func (s *Server) handleChatStream(w http.ResponseWriter, r *http.Request) {
 		s.sendSSEEvent(w, "error", "Session ID required")
 		return
 	}
+	var since uint64
+	if value := r.URL.Query().Get("since"); value != "" {
+		var err error
+		if since, err = strconv.ParseUint(value, 10, 64); err != nil {
+			s.sendSSEEvent(w, "error", "since must be an event sequence number")
+			return
+		}
+	}
 
 	// Get session
 	s.mutex.RLock()
@@ -284,10 +405,28 @@ func (s *Server) handleChatStream(w http.ResponseWriter, r *http.Request) {
 	s.addSSEClient(sessionID, clientChan)
 	defer s.removeSSEClient(sessionID, clientChan)
 
+	// Collect the events to replay now that the client is registered; any broadcast after
+	// this point is also queued on clientChan, and is skipped there if it was replayed
+	buffer := s.replayBuffer(sessionID)
+	buffer.mutex.Lock()
+	replay, missed := buffer.since(since)
+	lastSeq := buffer.lastSeq
+	buffer.mutex.Unlock()
+
 	// Send initial connection state
+	session.mutex.Lock()
+	messageCount := len(session.Messages)
+	session.mutex.Unlock()
 	s.sendSSEEvent(w, "connection_state", map[string]interface{}{
-		"message_count": len(session.Messages),
+		"message_count": messageCount,
+		"last_seq":      lastSeq,
+		"missed_events": missed, // Some events after since were too old to replay
 	})
+	for _, event := range replay {
+		if err := s.writeSSEEvent(w, event); err != nil {
+			return // Connection closed
+		}
+	}
 
 	// Listen for events and connection close
 	ctx := r.Context()
@@ -298,11 +437,16 @@ func (s *Server) handleChatStream(w http.ResponseWriter, r *http.Request) {
 		select {
 		case <-ctx.Done():
 			return
+		case <-s.stopCtx.Done():
+			return // Server shutting down
 		case event, ok := <-clientChan:
 			if !ok {
 				return // Channel closed
 			}
-			if err := s.sendSSEEvent(w, event.Type, event.Data); err != nil {
+			if event.Seq <= lastSeq {
+				continue // Already replayed
+			}
+			if err := s.writeSSEEvent(w, event); err != nil {
 				return // Connection closed
 			}
 		case <-ticker.C:
@@ -311,8 +455,19 @@ func (s *Server) handleChatStream(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// processMessage processes a chat message and streams responses via SSE to all connected clients
-func (s *Server) processMessage(session *ChatSession, message string, quality agent.RenderQuality) {
+// processMessage processes the session's latest message and streams responses via SSE to all connected clients
+// The caller claims the session by storing ctx's cancel function in session.cancel; it's
+// cleared once the turn, including any renders it triggered, is finished.
+func (s *Server) processMessage(ctx context.Context, session *ChatSession, quality agent.RenderQuality) {
+	defer func() {
+		s.metrics.messagesProcessed.Add(1)
+
+		// Release the session for the next message
+		session.mutex.Lock()
+		session.cancel = nil
+		session.mutex.Unlock()
+	}()
+
 	// Create channel for agent events
 	agentEvents := make(chan agent.AgentEvent, 10)
 
@@ -326,25 +481,11 @@ func (s *Server) processMessage(session *ChatSession, message string, quality ag
 	// Set the events channel for this message processing
 	ag.SetEventsChannel(agentEvents)
 
-	// Create a cancellable context for this processing
-	ctx, cancel := context.WithCancel(context.Background())
-
-	// Store the cancel function in the session
-	session.mutex.Lock()
-	session.cancel = cancel
-	session.mutex.Unlock()
-
 	// Start agent processing in goroutine
 	// Note: Agent maintains its scene state across messages
 	go func() {
-		defer func() {
-			// Clear the cancel function when processing completes
-			session.mutex.Lock()
-			session.cancel = nil
-			session.mutex.Unlock()
-		}()
-
-		// Read messages under lock
+		// The agent owns the conversation until the turn ends; session.Messages keeps the
+		// history as of the turn's start for readers such as /api/history until then
 		session.mutex.Lock()
 		messages := session.Messages
 		session.mutex.Unlock()
@@ -359,10 +500,12 @@ func (s *Server) processMessage(session *ChatSession, message string, quality ag
 			}
 		}
 
-		// Update session with complete conversation history
-		session.mutex.Lock()
-		session.Messages = updatedMessages
-		session.mutex.Unlock()
+		// Update session with the turn's final conversation (none if the agent couldn't start)
+		if updatedMessages != nil {
+			session.mutex.Lock()
+			session.Messages = updatedMessages
+			session.mutex.Unlock()
+		}
 
 		close(agentEvents)
 	}()
@@ -375,8 +518,12 @@ func (s *Server) processMessage(session *ChatSession, message string, quality ag
 			s.broadcastToSession(session.ID, SSEChatEvent{Type: e.EventType(), Data: e})
 
 		case agent.SceneRenderEvent:
-			// Handle ready-to-render scene from agent (use quality from message)
-			s.renderAndBroadcastScene(session.ID, e.RaytracerScene, quality)
+			// Handle ready-to-render scene from agent (the model's chosen quality overrides the message's)
+			settings := e.SceneRenderSettings
+			if settings.Quality == "" {
+				settings.Quality = quality
+			}
+			s.renderAndBroadcastScene(ctx, session.ID, e.RaytracerScene, settings)
 
 		case agent.ToolCallStartEvent:
 			// Handle tool call start events
@@ -386,8 +533,20 @@ func (s *Server) processMessage(session *ChatSession, message string, quality ag
 			// Handle tool call events with logging and broadcasting
 			s.handleToolCallEvent(session.ID, e)
 
+		case agent.RenderCancelledEvent:
+			s.broadcastToSession(session.ID, SSEChatEvent{Type: e.EventType(), Data: e})
+
 		case agent.ProcessingEvent:
 			s.broadcastToSession(session.ID, SSEChatEvent{Type: e.EventType(), Data: e.Message})
+		case agent.TelemetryEvent:
+			// Log for observing cost, and forward so clients can show it if they want to
+			tokens := "tokens n/a"
+			if e.Usage != nil {
+				tokens = fmt.Sprintf("%d in / %d out tokens", e.Usage.InputTokens, e.Usage.OutputTokens)
+			}
+			log.Printf("INFO  [session:%s] Turn %d: %s, %d tools, %d renders, %s, %dms",
+				session.ID, e.Turn, e.ModelID, len(e.Tools), e.RenderCount, tokens, e.ElapsedMs)
+			s.broadcastToSession(session.ID, SSEChatEvent{Type: e.EventType(), Data: e})
 		case agent.ErrorEvent:
 			s.broadcastToSession(session.ID, SSEChatEvent{Type: e.EventType(), Data: e.Message})
 		case agent.CompleteEvent:
@@ -400,10 +559,18 @@ func (s *Server) processMessage(session *ChatSession, message string, quality ag
 }
 
 // renderAndBroadcastScene renders a raytracer scene and broadcasts to a specific session
-func (s *Server) renderAndBroadcastScene(sessionID string, raytracerScene *scene.Scene, quality agent.RenderQuality) {
+// A quick thumbnail is broadcast first so the UI updates immediately, followed by the render
+// at settings.Quality, with the scene's complexity choosing the settings for auto quality;
+// scene_update events say which one they carry with a thumbnail flag. The render is aborted with
+// a render_cancelled event if ctx is cancelled, including between the two renders. Both renders
+// use the rest of settings, and have the scene's shadow catchers drawn on.
+func (s *Server) renderAndBroadcastScene(ctx context.Context, sessionID string, raytracerScene *scene.Scene, settings agent.SceneRenderSettings) {
 	if len(raytracerScene.Shapes) == 0 {
 		return // No shapes to render
 	}
+	s.metrics.rendersInFlight.Add(1)
+	defer s.metrics.rendersInFlight.Add(-1)
+	quality := settings.Quality
 
 	// Broadcast render start event
 	s.broadcastToSession(sessionID, SSEChatEvent{
@@ -413,45 +580,87 @@ func (s *Server) renderAndBroadcastScene(sessionID string, raytracerScene *scene
 		},
 	})
 
-	// Render the scene with appropriate config based on quality
-	config := renderer.DefaultProgressiveConfig()
-	config.MaxPasses = 1
-	if quality == agent.QualityHigh {
-		config.MaxSamplesPerPixel = 500
-	} else {
-		config.MaxSamplesPerPixel = 10
+	// Skip the thumbnail when the render itself is no bigger
+	// Keep the camera's aspect ratio, which ToRaytracerScene set on the scene
+	aspect := raytracerScene.CameraConfig.AspectRatio
+	render := settings.Apply(agent.RenderSettingsFor(quality, settings.Complexity).WithAspectRatio(aspect))
+	render.ImageFormat, render.ImageQuality = s.sessionImageEncoding(sessionID)
+	if thumbnail := settings.Apply(agent.GetThumbnailSettings().WithAspectRatio(aspect)); thumbnail.Width < render.Width {
+		thumbnail.ImageFormat, thumbnail.ImageQuality = render.ImageFormat, render.ImageQuality
+		if !s.renderAndBroadcastImage(ctx, sessionID, raytracerScene, settings.ShadowCatchers, quality, thumbnail, true) {
+			return
+		}
 	}
-
-	logger := renderer.NewDefaultLogger()
-	integrator := integrator.NewPathTracingIntegrator(raytracerScene.SamplingConfig)
-
-	raytracer, err := renderer.NewProgressiveRaytracer(raytracerScene, config, integrator, logger)
-	if err != nil {
-		log.Printf("Failed to create raytracer for session %s: %v", sessionID, err)
-		return
+	if s.renderAndBroadcastImage(ctx, sessionID, raytracerScene, settings.ShadowCatchers, quality, render, false) {
+		log.Printf("Scene rendered for session %s - %d shapes", sessionID, len(raytracerScene.Shapes))
 	}
+}
 
-	// Render
-	result_img, _, err := raytracer.RenderPass(1, nil)
+// renderAndBroadcastImage renders one image of a scene and broadcasts it as a scene_update
+// Only full renders (not thumbnails) are kept for /api/image, and only they broadcast
+// render_progress events while they run. It returns false if the render was cancelled or failed.
+func (s *Server) renderAndBroadcastImage(ctx context.Context, sessionID string, raytracerScene *scene.Scene, shadowCatchers *agent.ShadowCatcherPass, quality agent.RenderQuality, settings agent.RenderSettings, thumbnail bool) bool {
+	var progress agent.RenderProgress
+	if !thumbnail {
+		progress = func(percent int) {
+			s.broadcastToSession(sessionID, SSEChatEvent{
+				Type: "render_progress",
+				Data: map[string]interface{}{
+					"percent": percent,
+					"quality": string(quality),
+				},
+			})
+		}
+	}
+	start := time.Now()
+	result_img, err := agent.RenderImageWithProgress(ctx, raytracerScene, settings, progress)
 	if err != nil {
+		if ctx.Err() != nil {
+			log.Printf("Render cancelled for session %s", sessionID)
+			s.broadcastToSession(sessionID, SSEChatEvent{
+				Type: "render_cancelled",
+				Data: agent.NewRenderCancelledEvent(""),
+			})
+			return false
+		}
 		log.Printf("Failed to render for session %s: %v", sessionID, err)
-		return
+		return false
+	}
+	result_img = shadowCatchers.Apply(result_img)
+	result_img = settings.PostProcess.Apply(result_img)
+	if !thumbnail {
+		s.metrics.recordRender(time.Since(start))
 	}
 
-	// Encode image to base64
-	var buf bytes.Buffer
-	if err := png.Encode(&buf, result_img); err != nil {
+	// Encode image to base64 in the session's chosen format
+	encoded, mimeType, err := agent.EncodeImage(result_img, settings.ImageFormat, settings.ImageQuality)
+	if err != nil {
 		log.Printf("Failed to encode image for session %s: %v", sessionID, err)
-		return
+		return false
 	}
 
-	imageBase64 := base64.StdEncoding.EncodeToString(buf.Bytes())
+	// Keep the image so it can be downloaded from /api/image
+	if !thumbnail {
+		s.mutex.RLock()
+		session, exists := s.sessions[sessionID]
+		s.mutex.RUnlock()
+		if exists {
+			session.mutex.Lock()
+			session.lastRender = encoded
+			session.renderMIME = mimeType
+			session.mutex.Unlock()
+		}
+	}
+
+	imageBase64 := base64.StdEncoding.EncodeToString(encoded)
 
 	// Extract basic scene info for frontend (simplified representation)
 	sceneInfo := map[string]interface{}{
 		"shape_count":  len(raytracerScene.Shapes),
 		"image_base64": imageBase64,
+		"mime_type":    mimeType,
 		"quality":      string(quality),
+		"thumbnail":    thumbnail,
 	}
 
 	// Broadcast scene update with image
@@ -459,8 +668,54 @@ func (s *Server) renderAndBroadcastScene(sessionID string, raytracerScene *scene
 		Type: "scene_update",
 		Data: sceneInfo,
 	})
+	return true
+}
 
-	log.Printf("Scene rendered for session %s - %d shapes", sessionID, len(raytracerScene.Shapes))
+// sessionImageEncoding returns how a session's scene renders are encoded, PNG if it has no preference
+func (s *Server) sessionImageEncoding(sessionID string) (format string, quality int) {
+	s.mutex.RLock()
+	session, exists := s.sessions[sessionID]
+	s.mutex.RUnlock()
+	if !exists {
+		return "", 0
+	}
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+	return session.imageFormat, session.imageQuality
+}
+
+// handleImage returns the most recently rendered scene image for a session, in the format it was sent in
+func (s *Server) handleImage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	sessionID := r.URL.Query().Get("session_id")
+	if sessionID == "" {
+		http.Error(w, "session_id is required", http.StatusBadRequest)
+		return
+	}
+
+	s.mutex.RLock()
+	session, exists := s.sessions[sessionID]
+	s.mutex.RUnlock()
+	if !exists {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+
+	session.mutex.Lock()
+	image, mimeType := session.lastRender, session.renderMIME
+	session.mutex.Unlock()
+	if image == nil {
+		http.Error(w, "No render available for this session", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", mimeType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(image)))
+	w.Write(image)
 }
 
 // InterruptRequest represents a request to interrupt LLM processing
@@ -521,8 +776,10 @@ func (s *Server) handleInterrupt(w http.ResponseWriter, r *http.Request) {
 
 // RenderRequest represents a request to re-render the scene
 type RenderRequest struct {
-	SessionID string `json:"session_id"`
-	Quality   string `json:"quality"`
+	SessionID    string `json:"session_id"`
+	Quality      string `json:"quality"`                 // "preview" (fastest, noisy, half resolution), "draft", "high" or "auto"
+	ImageFormat  string `json:"image_format,omitempty"`  // "png" (default) or "jpeg"; kept for the session's later renders
+	ImageQuality int    `json:"image_quality,omitempty"` // JPEG quality from 1 to 100, 0 for the default
 }
 
 // handleRender handles requests to re-render the current scene with different quality
@@ -554,22 +811,40 @@ func (s *Server) handleRender(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Parse quality setting
-	quality := agent.QualityDraft
-	if renderReq.Quality == "high" {
-		quality = agent.QualityHigh
+	if err := agent.ValidateImageEncoding(renderReq.ImageFormat, renderReq.ImageQuality); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	// A running turn is still changing the scene and the model's render settings, and renders
+	// the scene itself when it finishes
+	session.mutex.Lock()
+	if session.cancel != nil {
+		session.mutex.Unlock()
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": "A message is still being processed for this session - wait for it to finish or interrupt it"})
+		return
+	}
+	if renderReq.ImageFormat != "" {
+		session.imageFormat, session.imageQuality = renderReq.ImageFormat, renderReq.ImageQuality
 	}
 
 	// Get current scene from agent's scene manager
 	raytracerScene, err := session.Agent.GetSceneManager().ToRaytracerScene()
+	settings := session.Agent.SceneRenderSettings()
+	session.mutex.Unlock()
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to generate scene"})
 		return
 	}
 
-	// Render and broadcast the scene
-	go s.renderAndBroadcastScene(renderReq.SessionID, raytracerScene, quality)
+	// Render and broadcast the scene at the requested quality
+	settings.Quality = agent.ParseRenderQuality(renderReq.Quality)
+	s.startBackground(func() {
+		s.renderAndBroadcastScene(s.stopCtx, renderReq.SessionID, raytracerScene, settings)
+	})
 
 	// Return success
 	w.WriteHeader(http.StatusOK)
@@ -0,0 +1,366 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/df07/scene-llm/agent"
+	"github.com/df07/scene-llm/agent/llm"
+)
+
+func TestBuildUserMessageWithImages(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 2, 2))); err != nil {
+		t.Fatalf("Failed to encode test image: %v", err)
+	}
+	encoded := base64.StdEncoding.EncodeToString(buf.Bytes())
+
+	msg, err := buildUserMessage("Make it look like this", []string{encoded, "data:image/png;base64," + encoded})
+	if err != nil {
+		t.Fatalf("buildUserMessage failed: %v", err)
+	}
+
+	if msg.Role != "user" {
+		t.Errorf("Expected role user, got %q", msg.Role)
+	}
+	if len(msg.Parts) != 3 {
+		t.Fatalf("Expected text part plus 2 image parts, got %d parts", len(msg.Parts))
+	}
+	if msg.Parts[0].Type != llm.PartTypeText || msg.Parts[0].Text != "Make it look like this" {
+		t.Errorf("Expected first part to be the message text, got %+v", msg.Parts[0])
+	}
+	for i, part := range msg.Parts[1:] {
+		if part.Type != llm.PartTypeImage || part.ImageData == nil {
+			t.Fatalf("Expected part %d to be an image, got %+v", i+1, part)
+		}
+		if part.ImageData.MIMEType != "image/png" {
+			t.Errorf("Expected image/png, got %q", part.ImageData.MIMEType)
+		}
+		if !bytes.Equal(part.ImageData.Data, buf.Bytes()) {
+			t.Errorf("Image %d data doesn't match the attachment", i+1)
+		}
+	}
+}
+
+func TestBuildUserMessageRejectsInvalidImages(t *testing.T) {
+	tests := map[string]string{
+		"invalid base64": "not base64!",
+		"not an image":   base64.StdEncoding.EncodeToString([]byte("just some text")),
+		"bad data URL":   "data:image/png;base64",
+	}
+	for name, encoded := range tests {
+		if _, err := buildUserMessage("hi", []string{encoded}); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+// blockingProvider is an LLM provider whose replies wait until release is closed
+type blockingProvider struct {
+	release chan struct{}
+}
+
+func (p *blockingProvider) GenerateContent(ctx context.Context, req *llm.GenerateRequest) (*llm.Response, error) {
+	select {
+	case <-p.release:
+		return &llm.Response{Parts: []llm.Part{{Type: llm.PartTypeText, Text: "Done"}}}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (p *blockingProvider) ListModels() []llm.ModelInfo {
+	return []llm.ModelInfo{{ID: "blocking-model", DisplayName: "Blocking Model", Provider: "blocking"}}
+}
+
+func (p *blockingProvider) Name() string           { return "blocking" }
+func (p *blockingProvider) SupportsVision() bool   { return false }
+func (p *blockingProvider) SupportsThinking() bool { return false }
+
+// newTestServer returns a server whose only model is served by provider
+func newTestServer(provider llm.LLMProvider) *Server {
+	s := NewServer(0)
+	s.registry = llm.NewRegistry()
+	s.registry.Add(provider)
+	return s
+}
+
+// postChat sends a chat message to the server and returns the response code and body
+func postChat(t *testing.T, s *Server, sessionID, message string) (int, ChatResponse) {
+	t.Helper()
+	return postChatMessage(t, s, ChatMessage{SessionID: sessionID, Message: message})
+}
+
+// postChatMessage sends a chat message with any options set and returns the status code and response
+func postChatMessage(t *testing.T, s *Server, chatMsg ChatMessage) (int, ChatResponse) {
+	t.Helper()
+	body, _ := json.Marshal(chatMsg)
+	rec := httptest.NewRecorder()
+	s.handleChat(rec, httptest.NewRequest(http.MethodPost, "/api/chat", bytes.NewReader(body)))
+
+	var response ChatResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode chat response: %v", err)
+	}
+	return rec.Code, response
+}
+
+// waitUntilIdle waits for a session to finish processing its current message
+func waitUntilIdle(t *testing.T, session *ChatSession) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		session.mutex.Lock()
+		idle := session.cancel == nil
+		session.mutex.Unlock()
+		if idle {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("Timed out waiting for the session to finish processing")
+}
+
+func TestHandleChatRejectsOverlappingMessages(t *testing.T) {
+	provider := &blockingProvider{release: make(chan struct{})}
+	s := newTestServer(provider)
+
+	code, first := postChat(t, s, "", "Add a red ball")
+	if code != http.StatusOK || first.Status != "processing" {
+		t.Fatalf("Expected the first message to be accepted, got %d %+v", code, first)
+	}
+
+	// The first message is still waiting on the model. The rejected message's settings must not
+	// reach the agent while the turn is using it.
+	turns, budget := 3, 512
+	code, second := postChatMessage(t, s, ChatMessage{SessionID: first.SessionID, Message: "Make it blue", MaxTurns: &turns, ThinkingBudget: &budget})
+	if code != http.StatusConflict || second.Status != "busy" || !strings.Contains(second.Error, "still being processed") {
+		t.Errorf("Expected 409 busy for an overlapping message, got %d %+v", code, second)
+	}
+
+	session := s.sessions[first.SessionID]
+	session.mutex.Lock()
+	messageCount := len(session.Messages)
+	session.mutex.Unlock()
+	if messageCount != 1 {
+		t.Errorf("Expected the rejected message to stay out of the conversation, got %d messages", messageCount)
+	}
+	if session.Agent.MaxTurns() == turns {
+		t.Errorf("Expected the rejected message's max_turns to be ignored")
+	}
+
+	// Once the turn finishes, the session takes messages again
+	close(provider.release)
+	waitUntilIdle(t, session)
+	if code, third := postChat(t, s, first.SessionID, "Make it blue"); code != http.StatusOK {
+		t.Errorf("Expected a message after the turn finished to be accepted, got %d %+v", code, third)
+	}
+	waitUntilIdle(t, session)
+}
+
+// scriptedProvider is an LLM provider that replies with a fixed sequence of responses, then "Done"
+type scriptedProvider struct {
+	responses []*llm.Response
+	calls     int
+}
+
+func (p *scriptedProvider) GenerateContent(ctx context.Context, req *llm.GenerateRequest) (*llm.Response, error) {
+	if p.calls >= len(p.responses) {
+		return &llm.Response{Parts: []llm.Part{{Type: llm.PartTypeText, Text: "Done"}}}, nil
+	}
+	p.calls++
+	return p.responses[p.calls-1], nil
+}
+
+func (p *scriptedProvider) ListModels() []llm.ModelInfo {
+	return []llm.ModelInfo{{ID: "scripted-model", DisplayName: "Scripted Model", Provider: "scripted"}}
+}
+
+func (p *scriptedProvider) Name() string           { return "scripted" }
+func (p *scriptedProvider) SupportsVision() bool   { return false }
+func (p *scriptedProvider) SupportsThinking() bool { return false }
+
+// TestChatTurnConversation runs a full turn while the conversation is read concurrently;
+// run it with -race to check the session's messages aren't shared with the agent
+func TestChatTurnConversation(t *testing.T) {
+	provider := &scriptedProvider{responses: []*llm.Response{
+		{Parts: []llm.Part{
+			{Type: llm.PartTypeText, Text: "Adding a ball."},
+			{Type: llm.PartTypeFunctionCall, FunctionCall: &llm.FunctionCall{
+				ID:   "call_1",
+				Name: "create_shape",
+				Arguments: map[string]interface{}{
+					"id":   "ball",
+					"type": "sphere",
+					"properties": map[string]interface{}{
+						"center": []interface{}{0.0, 0.0, 0.0},
+						"radius": 1.0,
+					},
+				},
+			}},
+		}},
+	}}
+	s := newTestServer(provider)
+
+	code, response := postChat(t, s, "", "Add a ball")
+	if code != http.StatusOK {
+		t.Fatalf("Expected the message to be accepted, got %d %+v", code, response)
+	}
+	s.mutex.RLock()
+	session := s.sessions[response.SessionID]
+	s.mutex.RUnlock()
+
+	// Read the history and connect event streams until the turn is over
+	stop, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			rec := httptest.NewRecorder()
+			s.handleHistory(rec, httptest.NewRequest(http.MethodGet, "/api/history?session_id="+session.ID, nil))
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel() // Return right after the connection state is sent
+			req := httptest.NewRequest(http.MethodGet, "/api/chat/stream?session_id="+session.ID, nil).WithContext(ctx)
+			s.handleChatStream(httptest.NewRecorder(), req)
+		}
+	}()
+	waitUntilIdle(t, session)
+	close(stop)
+	<-done
+
+	session.mutex.Lock()
+	messages := session.Messages
+	session.mutex.Unlock()
+
+	// user message, tool call, tool result, final reply
+	roles := make([]string, len(messages))
+	for i, msg := range messages {
+		roles[i] = string(msg.Role)
+	}
+	if strings.Join(roles, ",") != "user,assistant,user,assistant" {
+		t.Fatalf("Expected the turn's full conversation to be stored, got roles %v", roles)
+	}
+	if shape := session.Agent.GetSceneManager().FindShape("ball"); shape == nil {
+		t.Error("Expected the turn to create the ball")
+	}
+}
+
+func TestHandleChatMaxTurns(t *testing.T) {
+	// Every reply asks for another tool call, so only the turn limit ends the loop
+	var responses []*llm.Response
+	for i := 0; i < 5; i++ {
+		responses = append(responses, &llm.Response{Parts: []llm.Part{
+			{Type: llm.PartTypeFunctionCall, FunctionCall: &llm.FunctionCall{ID: "call", Name: "get_scene_state", Arguments: map[string]interface{}{}}},
+		}})
+	}
+	provider := &scriptedProvider{responses: responses}
+	s := newTestServer(provider)
+
+	for _, turns := range []int{0, agent.MaxTurnsLimit + 1} {
+		code, response := postChatMessage(t, s, ChatMessage{Message: "Hi", MaxTurns: &turns})
+		if code != http.StatusBadRequest || !strings.Contains(response.Error, "max_turns") {
+			t.Errorf("Expected max_turns %d to be rejected, got %d %+v", turns, code, response)
+		}
+	}
+
+	turns := 2
+	code, response := postChatMessage(t, s, ChatMessage{Message: "Look around", MaxTurns: &turns})
+	if code != http.StatusOK {
+		t.Fatalf("Expected the message to be accepted, got %d %+v", code, response)
+	}
+	s.mutex.RLock()
+	session := s.sessions[response.SessionID]
+	s.mutex.RUnlock()
+	waitUntilIdle(t, session)
+
+	if provider.calls != 2 {
+		t.Errorf("Expected the loop to stop after 2 turns, got %d model calls", provider.calls)
+	}
+	if got := session.Agent.MaxTurns(); got != 2 {
+		t.Errorf("Expected the session to keep max_turns 2, got %d", got)
+	}
+}
+
+func TestRenderBusySession(t *testing.T) {
+	s := NewServer(0)
+	session := &ChatSession{ID: "abc", Agent: agent.NewWithProvider(nil, nil, "mock-model")}
+	s.sessions[session.ID] = session
+
+	// A running turn may still be changing the scene and the model's render settings
+	session.cancel = func() {}
+	rec := httptest.NewRecorder()
+	s.handleRender(rec, httptest.NewRequest(http.MethodPost, "/api/render", bytes.NewBufferString(`{"session_id": "abc", "quality": "draft", "image_format": "jpeg"}`)))
+	if rec.Code != http.StatusConflict {
+		t.Errorf("Expected 409 while the session is processing a message, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if session.imageFormat != "" {
+		t.Errorf("Expected a rejected render to leave the image format alone, got %q", session.imageFormat)
+	}
+}
+
+func TestRenderImageFormat(t *testing.T) {
+	s := NewServer(0)
+	session := &ChatSession{ID: "abc", Agent: agent.NewWithProvider(nil, nil, "mock-model")}
+	if err := session.Agent.GetSceneManager().AddShapes([]agent.ShapeRequest{{ID: "ball", Type: "sphere", Properties: map[string]interface{}{
+		"center": []interface{}{0.0, 0.0, 0.0},
+		"radius": 1.0,
+	}}}); err != nil {
+		t.Fatalf("AddShapes() failed: %v", err)
+	}
+	s.sessions[session.ID] = session
+
+	post := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		s.handleRender(rec, httptest.NewRequest(http.MethodPost, "/api/render", bytes.NewBufferString(body)))
+		return rec
+	}
+	if rec := post(`{"session_id": "abc", "quality": "preview", "image_format": "webp"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected webp to be rejected, got %d", rec.Code)
+	}
+	if rec := post(`{"session_id": "abc", "quality": "preview", "image_format": "jpeg", "image_quality": 70}`); rec.Code != http.StatusOK {
+		t.Fatalf("Expected the render to start, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// The render runs in the background; wait for its full-size scene_update
+	var update map[string]interface{}
+	deadline := time.Now().Add(5 * time.Second)
+	for update == nil && time.Now().Before(deadline) {
+		buffer := s.replayBuffer("abc")
+		buffer.mutex.Lock()
+		events, _ := buffer.since(0)
+		buffer.mutex.Unlock()
+		for _, event := range events {
+			if data, ok := event.Data.(map[string]interface{}); ok && event.Type == "scene_update" && data["thumbnail"] == false {
+				update = data
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if update == nil || update["mime_type"] != "image/jpeg" {
+		t.Fatalf("Expected a JPEG scene_update, got %v", update["mime_type"])
+	}
+
+	rec := httptest.NewRecorder()
+	s.handleImage(rec, httptest.NewRequest(http.MethodGet, "/api/image?session_id=abc", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/jpeg" {
+		t.Fatalf("Expected a JPEG from /api/image, got %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if _, err := jpeg.Decode(rec.Body); err != nil {
+		t.Errorf("Expected /api/image to serve a valid JPEG: %v", err)
+	}
+}
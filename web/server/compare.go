@@ -0,0 +1,115 @@
+package server
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"image"
+	"net/http"
+
+	"github.com/df07/scene-llm/agent"
+)
+
+// CompareRendersRequest asks for the latest renders of two sessions to be compared
+type CompareRendersRequest struct {
+	SessionA string `json:"session_a"`
+	SessionB string `json:"session_b"`
+}
+
+// CompareRendersResponse is the body returned by /api/compare_renders
+type CompareRendersResponse struct {
+	Width           int     `json:"width"`
+	Height          int     `json:"height"`
+	RMSE            float64 `json:"rmse"` // Root-mean-square error over RGB, from 0 (identical) to 1
+	DifferentPixels int     `json:"different_pixels"`
+	TotalPixels     int     `json:"total_pixels"`
+	DiffImage       string  `json:"diff_image"` // Base64 PNG of the absolute per-pixel difference
+	MimeType        string  `json:"mime_type"`
+}
+
+// handleCompareRenders compares the most recent renders of two sessions, for A/B evaluation of
+// scene variations. Both renders must have the same dimensions.
+func (s *Server) handleCompareRenders(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Method not allowed"})
+		return
+	}
+
+	var req CompareRendersRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid JSON"})
+		return
+	}
+	if req.SessionA == "" || req.SessionB == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "session_a and session_b are required"})
+		return
+	}
+
+	imageA, status, err := s.sessionRender(req.SessionA)
+	if err != nil {
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	imageB, status, err := s.sessionRender(req.SessionB)
+	if err != nil {
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	diff, err := agent.DiffImages(imageA, imageB)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": fmt.Sprintf("Cannot compare renders: %v", err)})
+		return
+	}
+	encoded, mimeType, err := agent.EncodeImage(diff.Image, agent.ImageFormatPNG, 0)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	bounds := diff.Image.Bounds()
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(CompareRendersResponse{
+		Width:           bounds.Dx(),
+		Height:          bounds.Dy(),
+		RMSE:            diff.RMSE,
+		DifferentPixels: diff.DifferentPixels,
+		TotalPixels:     bounds.Dx() * bounds.Dy(),
+		DiffImage:       base64.StdEncoding.EncodeToString(encoded),
+		MimeType:        mimeType,
+	})
+}
+
+// sessionRender decodes a session's most recent render
+// On failure it also returns the HTTP status to report.
+func (s *Server) sessionRender(sessionID string) (image.Image, int, error) {
+	s.mutex.RLock()
+	session, exists := s.sessions[sessionID]
+	s.mutex.RUnlock()
+	if !exists {
+		return nil, http.StatusNotFound, fmt.Errorf("Session '%s' not found", sessionID)
+	}
+
+	session.mutex.Lock()
+	encoded := session.lastRender
+	session.mutex.Unlock()
+	if encoded == nil {
+		return nil, http.StatusNotFound, fmt.Errorf("No render available for session '%s'", sessionID)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(encoded))
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("Failed to decode the render for session '%s': %v", sessionID, err)
+	}
+	return img, 0, nil
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/color"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/df07/scene-llm/agent"
+)
+
+func TestHandleCompareRenders(t *testing.T) {
+	s := NewServer(0)
+	addRender := func(id string, width, height int, changed bool) {
+		img := image.NewRGBA(image.Rect(0, 0, width, height))
+		if changed {
+			img.SetRGBA(0, 0, color.RGBA{R: 255, A: 255})
+		}
+		encoded, mimeType, err := agent.EncodeImage(img, agent.ImageFormatPNG, 0)
+		if err != nil {
+			t.Fatalf("EncodeImage() failed: %v", err)
+		}
+		s.sessions[id] = &ChatSession{ID: id, lastRender: encoded, renderMIME: mimeType}
+	}
+	addRender("a", 4, 4, false)
+	addRender("b", 4, 4, true)
+	addRender("small", 2, 2, false)
+	s.sessions["unrendered"] = &ChatSession{ID: "unrendered"}
+
+	post := func(sessionA, sessionB string) (int, map[string]interface{}) {
+		body, _ := json.Marshal(CompareRendersRequest{SessionA: sessionA, SessionB: sessionB})
+		rec := httptest.NewRecorder()
+		s.handleCompareRenders(rec, httptest.NewRequest(http.MethodPost, "/api/compare_renders", bytes.NewReader(body)))
+		var response map[string]interface{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return rec.Code, response
+	}
+
+	code, response := post("a", "b")
+	if code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d %v", code, response)
+	}
+	if response["different_pixels"] != 1.0 || response["total_pixels"] != 16.0 || response["mime_type"] != "image/png" {
+		t.Errorf("Expected 1 of 16 pixels to differ in a PNG diff, got %v", response)
+	}
+	if rmse, _ := response["rmse"].(float64); rmse <= 0 {
+		t.Errorf("Expected a positive RMSE, got %v", response["rmse"])
+	}
+	if diffImage, _ := response["diff_image"].(string); diffImage == "" {
+		t.Error("Expected a diff image")
+	}
+
+	if code, response := post("a", "a"); code != http.StatusOK || response["rmse"] != 0.0 {
+		t.Errorf("Expected a render to match itself, got %d %v", code, response)
+	}
+
+	tests := map[string]struct {
+		sessionA, sessionB string
+		code               int
+	}{
+		"missing session ID":   {"a", "", http.StatusBadRequest},
+		"unknown session":      {"a", "missing", http.StatusNotFound},
+		"no render":            {"unrendered", "a", http.StatusNotFound},
+		"different dimensions": {"a", "small", http.StatusBadRequest},
+	}
+	for name, tt := range tests {
+		if code, response := post(tt.sessionA, tt.sessionB); code != tt.code || response["error"] == nil {
+			t.Errorf("%s: expected %d with an error, got %d %v", name, tt.code, code, response)
+		}
+	}
+}
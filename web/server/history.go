@@ -0,0 +1,120 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/df07/scene-llm/agent/llm"
+)
+
+// HistoryMessage is one conversation message in the form the frontend displays it
+type HistoryMessage struct {
+	Role        string              `json:"role"`
+	Text        string              `json:"text,omitempty"`
+	Thinking    string              `json:"thinking,omitempty"` // Reasoning the model shared, if any
+	ToolCalls   []HistoryToolCall   `json:"tool_calls,omitempty"`
+	ToolResults []HistoryToolResult `json:"tool_results,omitempty"`
+	Images      []HistoryImage      `json:"images,omitempty"`
+}
+
+// HistoryToolCall is a tool call made by the model
+type HistoryToolCall struct {
+	ID        string                 `json:"id,omitempty"`
+	Name      string                 `json:"name"`
+	Arguments map[string]interface{} `json:"arguments"`
+}
+
+// HistoryToolResult is the result of a tool call, matched to it by ID
+type HistoryToolResult struct {
+	ID     string                 `json:"id,omitempty"`
+	Name   string                 `json:"name"`
+	Result map[string]interface{} `json:"result"`
+}
+
+// HistoryImage describes an image in the conversation without its data, which can be large
+type HistoryImage struct {
+	MIMEType string `json:"mime_type"`
+	Size     int    `json:"size"` // Bytes
+}
+
+// HistoryResponse is the body returned by /api/history
+type HistoryResponse struct {
+	SessionID string           `json:"session_id"`
+	Messages  []HistoryMessage `json:"messages"`
+}
+
+// historyMessages converts a conversation to its frontend form
+func historyMessages(messages []llm.Message) []HistoryMessage {
+	history := make([]HistoryMessage, 0, len(messages))
+	for _, msg := range messages {
+		entry := HistoryMessage{Role: string(msg.Role)}
+		var text, thinking []string
+		for _, part := range msg.Parts {
+			switch part.Type {
+			case llm.PartTypeText:
+				if part.Thought {
+					thinking = append(thinking, part.Text)
+				} else {
+					text = append(text, part.Text)
+				}
+			case llm.PartTypeFunctionCall:
+				if part.FunctionCall != nil {
+					entry.ToolCalls = append(entry.ToolCalls, HistoryToolCall{
+						ID:        part.FunctionCall.ID,
+						Name:      part.FunctionCall.Name,
+						Arguments: part.FunctionCall.Arguments,
+					})
+				}
+			case llm.PartTypeFunctionResponse:
+				if part.FunctionResp != nil {
+					entry.ToolResults = append(entry.ToolResults, HistoryToolResult{
+						ID:     part.FunctionResp.ID,
+						Name:   part.FunctionResp.Name,
+						Result: part.FunctionResp.Response,
+					})
+				}
+			case llm.PartTypeImage:
+				if part.ImageData != nil {
+					entry.Images = append(entry.Images, HistoryImage{
+						MIMEType: part.ImageData.MIMEType,
+						Size:     len(part.ImageData.Data),
+					})
+				}
+			}
+		}
+		entry.Text = strings.Join(text, "\n")
+		entry.Thinking = strings.Join(thinking, "\n")
+		history = append(history, entry)
+	}
+	return history
+}
+
+// handleHistory returns a session's full conversation so the frontend can rebuild it, e.g. after a reload
+func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	sessionID := r.URL.Query().Get("session_id")
+	if sessionID == "" {
+		http.Error(w, "session_id is required", http.StatusBadRequest)
+		return
+	}
+
+	s.mutex.RLock()
+	session, exists := s.sessions[sessionID]
+	s.mutex.RUnlock()
+	if !exists {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+
+	session.mutex.Lock()
+	messages := session.Messages
+	session.mutex.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(HistoryResponse{SessionID: session.ID, Messages: historyMessages(messages)})
+}
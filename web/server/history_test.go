@@ -0,0 +1,109 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/df07/scene-llm/agent/llm"
+)
+
+func TestHandleHistory(t *testing.T) {
+	s := NewServer(0)
+	s.sessions["abc"] = &ChatSession{
+		ID: "abc",
+		Messages: []llm.Message{
+			{Role: "user", Parts: []llm.Part{
+				{Type: llm.PartTypeText, Text: "Add a red ball"},
+				{Type: llm.PartTypeImage, ImageData: &llm.ImageData{Data: []byte("not really a png"), MIMEType: "image/png"}},
+			}},
+			{Role: "assistant", Parts: []llm.Part{
+				{Type: llm.PartTypeText, Text: "The user wants a ball.", Thought: true},
+				{Type: llm.PartTypeText, Text: "Adding it now."},
+				{Type: llm.PartTypeFunctionCall, FunctionCall: &llm.FunctionCall{
+					ID:        "call_1",
+					Name:      "create_shape",
+					Arguments: map[string]interface{}{"id": "ball", "type": "sphere"},
+				}},
+			}},
+			{Role: "user", Parts: []llm.Part{
+				{Type: llm.PartTypeFunctionResponse, FunctionResp: &llm.FunctionResponse{
+					ID:       "call_1",
+					Name:     "create_shape",
+					Response: map[string]interface{}{"success": true},
+				}},
+			}},
+		},
+	}
+
+	rec := httptest.NewRecorder()
+	s.handleHistory(rec, httptest.NewRequest(http.MethodGet, "/api/history?session_id=abc", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// Decode generically to check the JSON the frontend sees
+	var body struct {
+		SessionID string                   `json:"session_id"`
+		Messages  []map[string]interface{} `json:"messages"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if body.SessionID != "abc" || len(body.Messages) != 3 {
+		t.Fatalf("Expected 3 messages for session abc, got %+v", body)
+	}
+
+	user := body.Messages[0]
+	if user["role"] != "user" || user["text"] != "Add a red ball" {
+		t.Errorf("Unexpected user message: %v", user)
+	}
+	images, _ := user["images"].([]interface{})
+	if len(images) != 1 {
+		t.Fatalf("Expected one image reference, got %v", user["images"])
+	}
+	image := images[0].(map[string]interface{})
+	if image["mime_type"] != "image/png" || image["size"] != float64(16) || image["data"] != nil {
+		t.Errorf("Expected an image reference without data, got %v", image)
+	}
+
+	assistant := body.Messages[1]
+	if assistant["text"] != "Adding it now." || assistant["thinking"] != "The user wants a ball." {
+		t.Errorf("Expected text and thinking to be split, got %v", assistant)
+	}
+	calls, _ := assistant["tool_calls"].([]interface{})
+	if len(calls) != 1 || calls[0].(map[string]interface{})["name"] != "create_shape" {
+		t.Errorf("Expected a create_shape tool call, got %v", assistant["tool_calls"])
+	}
+
+	results, _ := body.Messages[2]["tool_results"].([]interface{})
+	if len(results) != 1 || results[0].(map[string]interface{})["id"] != "call_1" {
+		t.Errorf("Expected the call_1 tool result, got %v", body.Messages[2]["tool_results"])
+	}
+}
+
+func TestHandleHistoryErrors(t *testing.T) {
+	s := NewServer(0)
+
+	tests := []struct {
+		name   string
+		method string
+		url    string
+		status int
+	}{
+		{"unknown session", http.MethodGet, "/api/history?session_id=missing", http.StatusNotFound},
+		{"missing session_id", http.MethodGet, "/api/history", http.StatusBadRequest},
+		{"wrong method", http.MethodPost, "/api/history?session_id=missing", http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			s.handleHistory(rec, httptest.NewRequest(tt.method, tt.url, nil))
+			if rec.Code != tt.status {
+				t.Errorf("Expected %d, got %d", tt.status, rec.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// serverMetrics counts the server's activity for /api/metrics
+// The counters are updated with atomic operations from request handlers and render goroutines.
+type serverMetrics struct {
+	messagesProcessed atomic.Int64 // Chat messages whose processing has finished, however it ended
+	renders           atomic.Int64 // Completed full-size scene renders; thumbnails aren't counted
+	renderTimeMs      atomic.Int64 // Total time spent on the completed renders
+	rendersInFlight   atomic.Int64 // Scene renders (thumbnail and full) currently running
+}
+
+// recordRender records a completed full-size render that took elapsed
+func (m *serverMetrics) recordRender(elapsed time.Duration) {
+	m.renders.Add(1)
+	m.renderTimeMs.Add(elapsed.Milliseconds())
+}
+
+// MetricsSnapshot is the server's activity at one moment, as reported by /api/metrics
+type MetricsSnapshot struct {
+	ActiveSessions      int     `json:"active_sessions"`
+	MessagesProcessed   int64   `json:"messages_processed"`
+	RendersTotal        int64   `json:"renders_total"`
+	AverageRenderTimeMs float64 `json:"average_render_time_ms"` // 0 before the first render
+	RendersInFlight     int64   `json:"renders_in_flight"`
+}
+
+// metricsSnapshot reads the current metrics
+func (s *Server) metricsSnapshot() MetricsSnapshot {
+	s.mutex.RLock()
+	sessions := len(s.sessions)
+	s.mutex.RUnlock()
+
+	snapshot := MetricsSnapshot{
+		ActiveSessions:    sessions,
+		MessagesProcessed: s.metrics.messagesProcessed.Load(),
+		RendersTotal:      s.metrics.renders.Load(),
+		RendersInFlight:   s.metrics.rendersInFlight.Load(),
+	}
+	if snapshot.RendersTotal > 0 {
+		snapshot.AverageRenderTimeMs = float64(s.metrics.renderTimeMs.Load()) / float64(snapshot.RendersTotal)
+	}
+	return snapshot
+}
+
+// handleMetrics reports the server's metrics as JSON, or in the Prometheus text format with
+// format=prometheus
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	snapshot := s.metricsSnapshot()
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "json":
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(snapshot)
+	case "prometheus":
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(snapshot.prometheus()))
+	default:
+		http.Error(w, fmt.Sprintf("unknown format '%s' (supported: json, prometheus)", format), http.StatusBadRequest)
+	}
+}
+
+// prometheus formats the snapshot in the Prometheus text exposition format
+func (m MetricsSnapshot) prometheus() string {
+	var b strings.Builder
+	metric := func(name, kind, help string, value interface{}) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n%s %v\n", name, help, name, kind, name, value)
+	}
+	metric("scene_llm_active_sessions", "gauge", "Chat sessions held by the server.", m.ActiveSessions)
+	metric("scene_llm_messages_processed_total", "counter", "Chat messages whose processing has finished.", m.MessagesProcessed)
+	metric("scene_llm_renders_total", "counter", "Completed full-size scene renders.", m.RendersTotal)
+	metric("scene_llm_render_time_ms_average", "gauge", "Average time of the completed renders in milliseconds.", m.AverageRenderTimeMs)
+	metric("scene_llm_renders_in_flight", "gauge", "Scene renders currently running.", m.RendersInFlight)
+	return b.String()
+}
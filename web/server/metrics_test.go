@@ -0,0 +1,80 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/df07/scene-llm/agent"
+)
+
+func TestHandleMetrics(t *testing.T) {
+	s := newTestServer(&scriptedProvider{})
+	metrics := func(query string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		s.handleMetrics(rec, httptest.NewRequest(http.MethodGet, "/api/metrics"+query, nil))
+		return rec
+	}
+	snapshot := func() MetricsSnapshot {
+		t.Helper()
+		var snapshot MetricsSnapshot
+		if err := json.Unmarshal(metrics("").Body.Bytes(), &snapshot); err != nil {
+			t.Fatalf("Failed to decode metrics: %v", err)
+		}
+		return snapshot
+	}
+
+	if got := snapshot(); got != (MetricsSnapshot{}) {
+		t.Errorf("Expected an idle server to report zeros, got %+v", got)
+	}
+
+	// A processed message
+	code, response := postChat(t, s, "", "Hello")
+	if code != http.StatusOK {
+		t.Fatalf("Expected the message to be accepted, got %d %+v", code, response)
+	}
+	s.mutex.RLock()
+	session := s.sessions[response.SessionID]
+	s.mutex.RUnlock()
+	waitUntilIdle(t, session)
+
+	// A render of a scene with something in it
+	if err := session.Agent.GetSceneManager().AddShapes([]agent.ShapeRequest{{ID: "ball", Type: "sphere", Properties: map[string]interface{}{
+		"center": []interface{}{0.0, 0.0, 0.0},
+		"radius": 1.0,
+	}}}); err != nil {
+		t.Fatalf("AddShapes() failed: %v", err)
+	}
+	rec := httptest.NewRecorder()
+	body := `{"session_id": "` + session.ID + `", "quality": "preview"}`
+	s.handleRender(rec, httptest.NewRequest(http.MethodPost, "/api/render", bytes.NewBufferString(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected the render to start, got %d: %s", rec.Code, rec.Body.String())
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for s.metrics.renders.Load() == 0 || s.metrics.rendersInFlight.Load() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the render to finish")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	got := snapshot()
+	if got.ActiveSessions != 1 || got.MessagesProcessed != 1 || got.RendersTotal != 1 || got.RendersInFlight != 0 || got.AverageRenderTimeMs < 0 {
+		t.Errorf("Expected one session, message and render, got %+v", got)
+	}
+
+	prometheus := metrics("?format=prometheus").Body.String()
+	for _, line := range []string{"scene_llm_active_sessions 1", "scene_llm_messages_processed_total 1", "scene_llm_renders_total 1", "# TYPE scene_llm_renders_in_flight gauge"} {
+		if !strings.Contains(prometheus, line) {
+			t.Errorf("Expected the Prometheus output to contain %q, got:\n%s", line, prometheus)
+		}
+	}
+	if rec := metrics("?format=xml"); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected an unknown format to be rejected, got %d", rec.Code)
+	}
+}
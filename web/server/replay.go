@@ -0,0 +1,73 @@
+package server
+
+import (
+	"slices"
+	"sync"
+)
+
+// eventReplayCapacity is how many recent events each session keeps for reconnecting clients
+const eventReplayCapacity = 200
+
+// eventReplayBuffer holds a session's most recent SSE events
+// It numbers events as they are broadcast, so a client that reconnects can ask for the ones it
+// missed. Scene updates carry a whole image and each supersedes the last, so only the newest is
+// kept. mutex is held while an event is recorded and sent, so clients receive events in
+// sequence order and a new connection can't miss one between its replay and its first live event.
+type eventReplayBuffer struct {
+	events   []SSEChatEvent // Newest events, oldest first
+	capacity int
+	lastSeq  uint64 // Sequence number of the newest event, 0 before the first
+	evicted  uint64 // Sequence number of the newest event dropped for space, 0 if none
+	mutex    sync.Mutex
+}
+
+// newEventReplayBuffer creates an empty buffer holding up to capacity events
+func newEventReplayBuffer(capacity int) *eventReplayBuffer {
+	return &eventReplayBuffer{events: make([]SSEChatEvent, 0, capacity), capacity: capacity}
+}
+
+// add numbers an event and records it, evicting the oldest event if the buffer is full
+// A scene update replaces the previous one. The caller must hold mutex.
+func (b *eventReplayBuffer) add(event SSEChatEvent) SSEChatEvent {
+	b.lastSeq++
+	event.Seq = b.lastSeq
+	if event.Type == "scene_update" {
+		b.events = slices.DeleteFunc(b.events, func(e SSEChatEvent) bool { return e.Type == "scene_update" })
+	}
+	if len(b.events) >= b.capacity {
+		b.evicted = b.events[0].Seq
+		b.events = slices.Delete(b.events, 0, 1)
+	}
+	b.events = append(b.events, event)
+	return event
+}
+
+// since returns the buffered events numbered after seq, oldest first
+// missed is true if events after seq have already been evicted; a replaced scene update isn't
+// missed, since the newer one is replayed. The caller must hold mutex.
+func (b *eventReplayBuffer) since(seq uint64) (events []SSEChatEvent, missed bool) {
+	for _, event := range b.events {
+		if event.Seq > seq {
+			events = append(events, event)
+		}
+	}
+	return events, seq < b.evicted
+}
+
+// replayBuffer returns a session's event buffer, creating it on first use
+// The buffer belongs to the session and goes away with it. Returns nil if there is no such session.
+func (s *Server) replayBuffer(sessionID string) *eventReplayBuffer {
+	s.mutex.RLock()
+	session, exists := s.sessions[sessionID]
+	s.mutex.RUnlock()
+	if !exists {
+		return nil
+	}
+
+	s.replayMutex.Lock()
+	defer s.replayMutex.Unlock()
+	if session.replay == nil {
+		session.replay = newEventReplayBuffer(eventReplayCapacity)
+	}
+	return session.replay
+}
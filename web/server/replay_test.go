@@ -0,0 +1,128 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEventReplayBuffer(t *testing.T) {
+	buffer := newEventReplayBuffer(3)
+	seqs := func(events []SSEChatEvent) []uint64 {
+		var result []uint64
+		for _, event := range events {
+			result = append(result, event.Seq)
+		}
+		return result
+	}
+
+	if events, missed := buffer.since(0); len(events) != 0 || missed {
+		t.Errorf("Expected an empty buffer to replay nothing, got %v, missed=%v", events, missed)
+	}
+
+	for i := 0; i < 5; i++ {
+		if event := buffer.add(SSEChatEvent{Type: "processing"}); event.Seq != uint64(i+1) {
+			t.Fatalf("Expected event %d to be numbered %d, got %d", i, i+1, event.Seq)
+		}
+	}
+
+	// The two oldest events were evicted, and the rest replay oldest first
+	tests := []struct {
+		since    uint64
+		expected []uint64
+		missed   bool
+	}{
+		{0, []uint64{3, 4, 5}, true},
+		{1, []uint64{3, 4, 5}, true},
+		{2, []uint64{3, 4, 5}, false},
+		{4, []uint64{5}, false},
+		{5, nil, false},
+	}
+	for _, tt := range tests {
+		events, missed := buffer.since(tt.since)
+		if !reflect.DeepEqual(seqs(events), tt.expected) || missed != tt.missed {
+			t.Errorf("since(%d) = %v, missed=%v; expected %v, missed=%v", tt.since, seqs(events), missed, tt.expected, tt.missed)
+		}
+	}
+}
+
+func TestEventReplayBufferKeepsLatestSceneUpdate(t *testing.T) {
+	buffer := newEventReplayBuffer(3)
+	for _, eventType := range []string{"scene_update", "llm_response", "scene_update", "scene_update"} {
+		buffer.add(SSEChatEvent{Type: eventType})
+	}
+
+	// Each scene update replaces the last, without counting as missed or taking up room
+	events, missed := buffer.since(0)
+	if len(events) != 2 || events[0].Seq != 2 || events[1].Seq != 4 || missed {
+		t.Errorf("Expected the response and the newest scene update, got %+v, missed=%v", events, missed)
+	}
+
+	// A session's buffer goes with its session
+	s := NewServer(0)
+	s.broadcastToSession("gone", SSEChatEvent{Type: "llm_response"})
+	if buffer := s.replayBuffer("gone"); buffer != nil {
+		t.Errorf("Expected no buffer for a session that doesn't exist")
+	}
+}
+
+// streamEvents connects to a session's chat stream for a moment and returns the events it received
+func streamEvents(t *testing.T, s *Server, query string) []SSEChatEvent {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	rec := httptest.NewRecorder()
+	s.handleChatStream(rec, httptest.NewRequest(http.MethodGet, "/api/chat/stream?"+query, nil).WithContext(ctx))
+
+	var events []SSEChatEvent
+	for _, line := range strings.Split(rec.Body.String(), "\n") {
+		data, ok := strings.CutPrefix(line, "data: ")
+		if !ok {
+			continue
+		}
+		var event SSEChatEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			t.Fatalf("Failed to decode event %q: %v", data, err)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestHandleChatStreamReplay(t *testing.T) {
+	s := NewServer(0)
+	s.sessions["abc"] = &ChatSession{ID: "abc"}
+	for _, text := range []string{"one", "two", "three"} {
+		s.broadcastToSession("abc", SSEChatEvent{Type: "llm_response", Data: text})
+	}
+
+	// A new connection gets every buffered event, in order, after connection_state
+	events := streamEvents(t, s, "session_id=abc")
+	if len(events) != 4 || events[0].Type != "connection_state" {
+		t.Fatalf("Expected connection_state and 3 replayed events, got %+v", events)
+	}
+	for i, text := range []string{"one", "two", "three"} {
+		if events[i+1].Data != text || events[i+1].Seq != uint64(i+1) {
+			t.Errorf("Expected event %d to be %q with seq %d, got %+v", i+1, text, i+1, events[i+1])
+		}
+	}
+	if state := events[0].Data.(map[string]interface{}); state["last_seq"] != 3.0 || state["missed_events"] != false {
+		t.Errorf("Expected connection_state to report last_seq 3, got %v", state)
+	}
+
+	// A reconnecting client only gets what it missed
+	events = streamEvents(t, s, "session_id=abc&since=2")
+	if len(events) != 2 || events[1].Data != "three" {
+		t.Errorf("Expected only the third event to be replayed, got %+v", events)
+	}
+
+	if events := streamEvents(t, s, "session_id=abc&since=soon"); len(events) != 1 || events[0].Type != "error" {
+		t.Errorf("Expected an invalid since to be rejected, got %+v", events)
+	}
+}
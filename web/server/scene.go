@@ -0,0 +1,216 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/df07/scene-llm/agent"
+	"github.com/df07/scene-llm/agent/llm"
+)
+
+// CopyShapeRequest represents a request to copy a shape from one session's scene to another
+type CopyShapeRequest struct {
+	FromSession string `json:"from_session"`
+	ToSession   string `json:"to_session"`
+	ShapeID     string `json:"shape_id"`
+	NewID       string `json:"new_id,omitempty"` // Optional ID for the copy (defaults to shape_id)
+}
+
+// handleCopyShape copies a shape between two sessions' scenes
+func (s *Server) handleCopyShape(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Method not allowed"})
+		return
+	}
+
+	// Parse request
+	var copyReq CopyShapeRequest
+	if err := json.NewDecoder(r.Body).Decode(&copyReq); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid JSON"})
+		return
+	}
+
+	if copyReq.FromSession == "" || copyReq.ToSession == "" || copyReq.ShapeID == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "from_session, to_session and shape_id are required"})
+		return
+	}
+
+	// Look up both sessions
+	s.mutex.RLock()
+	fromSession, fromExists := s.sessions[copyReq.FromSession]
+	toSession, toExists := s.sessions[copyReq.ToSession]
+	s.mutex.RUnlock()
+
+	if !fromExists {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Source session not found"})
+		return
+	}
+	if !toExists {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Destination session not found"})
+		return
+	}
+
+	// Copy the shape out of the source scene, which a running turn may still be changing
+	fromSession.mutex.Lock()
+	if fromSession.cancel != nil {
+		fromSession.mutex.Unlock()
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": "A message is still being processed for the source session - wait for it to finish or interrupt it"})
+		return
+	}
+	shape, err := fromSession.Agent.GetSceneManager().GetShapeCopy(copyReq.ShapeID)
+	fromSession.mutex.Unlock()
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	if copyReq.NewID != "" {
+		shape.ID = copyReq.NewID
+	}
+
+	// Like a chat message, the copy edits the destination scene, so it waits for any turn to finish
+	toSession.mutex.Lock()
+	if toSession.cancel != nil {
+		toSession.mutex.Unlock()
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": "A message is still being processed for the destination session - wait for it to finish or interrupt it"})
+		return
+	}
+
+	// Reject ID collisions in the destination rather than silently renaming
+	destScene := toSession.Agent.GetSceneManager()
+	if destScene.FindShape(shape.ID) != nil {
+		toSession.mutex.Unlock()
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": fmt.Sprintf("shape with ID '%s' already exists in destination session - provide a different new_id", shape.ID),
+		})
+		return
+	}
+
+	if err := destScene.AddShapes([]agent.ShapeRequest{*shape}); err != nil {
+		toSession.mutex.Unlock()
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	raytracerScene, sceneErr := destScene.ToRaytracerScene()
+	settings := toSession.Agent.SceneRenderSettings()
+	settings.Quality = agent.QualityDraft
+	toSession.mutex.Unlock()
+
+	log.Printf("INFO  [session:%s] Copied shape %s from session %s as %s",
+		copyReq.ToSession, copyReq.ShapeID, copyReq.FromSession, shape.ID)
+
+	// Refresh the destination preview so connected clients see the new shape
+	if sceneErr == nil {
+		s.startBackground(func() {
+			s.renderAndBroadcastScene(s.stopCtx, copyReq.ToSession, raytracerScene, settings)
+		})
+	}
+
+	// Return the created shape
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(shape)
+}
+
+// BranchSessionRequest represents a request to start a new session from a copy of another session's scene
+type BranchSessionRequest struct {
+	SessionID   string `json:"session_id"`
+	CopyHistory bool   `json:"copy_history,omitempty"` // Also copy the conversation so far (defaults to a fresh conversation)
+}
+
+// BranchSessionResponse describes the session created by a branch
+type BranchSessionResponse struct {
+	SessionID    string `json:"session_id"`
+	ShapeCount   int    `json:"shape_count"`
+	LightCount   int    `json:"light_count"`
+	MessageCount int    `json:"message_count"`
+}
+
+// handleBranchSession creates a new session whose scene is a copy of an existing session's,
+// so variations can be explored without changing the original
+func (s *Server) handleBranchSession(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Method not allowed"})
+		return
+	}
+
+	// Parse request
+	var branchReq BranchSessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&branchReq); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid JSON"})
+		return
+	}
+
+	if branchReq.SessionID == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "session_id is required"})
+		return
+	}
+
+	s.mutex.RLock()
+	source, exists := s.sessions[branchReq.SessionID]
+	s.mutex.RUnlock()
+	if !exists {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Source session not found"})
+		return
+	}
+
+	// A running turn is still changing the source scene, so wait for it like a chat message does
+	source.mutex.Lock()
+	if source.cancel != nil {
+		source.mutex.Unlock()
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": "A message is still being processed for the source session - wait for it to finish or interrupt it"})
+		return
+	}
+
+	// The branch keeps the source's model and starts from a snapshot of its scene
+	snapshot := source.Agent.GetSceneManager().Snapshot()
+	branch := &ChatSession{
+		ID:       generateSessionID(),
+		Messages: []llm.Message{},
+		Provider: source.Provider,
+		ModelID:  source.ModelID,
+	}
+	if branchReq.CopyHistory {
+		branch.Messages = append(branch.Messages, source.Messages...)
+	}
+	source.mutex.Unlock()
+
+	branch.Agent = agent.NewWithProvider(nil, branch.Provider, branch.ModelID)
+	branch.Agent.GetSceneManager().ReplaceState(snapshot)
+
+	s.mutex.Lock()
+	s.sessions[branch.ID] = branch
+	s.mutex.Unlock()
+
+	log.Printf("INFO  [session:%s] Branched from session %s with %d shapes and %d lights",
+		branch.ID, source.ID, len(snapshot.Shapes), len(snapshot.Lights))
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(BranchSessionResponse{
+		SessionID:    branch.ID,
+		ShapeCount:   len(snapshot.Shapes),
+		LightCount:   len(snapshot.Lights),
+		MessageCount: len(branch.Messages),
+	})
+}
@@ -0,0 +1,154 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/df07/scene-llm/agent"
+	"github.com/df07/scene-llm/agent/llm"
+)
+
+func TestHandleBranchSession(t *testing.T) {
+	s := NewServer(0)
+	source := &ChatSession{
+		ID:       "source",
+		Messages: []llm.Message{{Role: "user", Parts: []llm.Part{{Type: llm.PartTypeText, Text: "Add a ball"}}}},
+		Agent:    agent.NewWithProvider(nil, nil, "mock-model"),
+		ModelID:  "mock-model",
+	}
+	sourceScene := source.Agent.GetSceneManager()
+	if err := sourceScene.AddShapes([]agent.ShapeRequest{{ID: "ball", Type: "sphere", Properties: map[string]interface{}{
+		"center": []interface{}{0.0, 0.0, 0.0},
+		"radius": 1.0,
+	}}}); err != nil {
+		t.Fatalf("AddShapes() failed: %v", err)
+	}
+	s.sessions[source.ID] = source
+
+	branch := func(body string) (*httptest.ResponseRecorder, BranchSessionResponse) {
+		rec := httptest.NewRecorder()
+		s.handleBranchSession(rec, httptest.NewRequest(http.MethodPost, "/api/branch_session", bytes.NewBufferString(body)))
+		var resp BranchSessionResponse
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec, resp
+	}
+
+	// The source is busy with a message, so its scene can't be snapshotted yet
+	source.cancel = func() {}
+	if rec, _ := branch(`{"session_id": "source"}`); rec.Code != http.StatusConflict {
+		t.Errorf("Expected 409 while the source is processing a message, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if len(s.sessions) != 1 {
+		t.Errorf("Expected no session to be created from a busy source, got %d sessions", len(s.sessions))
+	}
+	source.cancel = nil
+
+	rec, resp := branch(`{"session_id": "source"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if resp.ShapeCount != 1 || resp.LightCount != 0 || resp.MessageCount != 0 {
+		t.Errorf("Expected 1 shape, 0 lights and no messages, got %+v", resp)
+	}
+
+	branched, exists := s.sessions[resp.SessionID]
+	if !exists || resp.SessionID == source.ID {
+		t.Fatalf("Expected a new session, got %q", resp.SessionID)
+	}
+	if branched.ModelID != source.ModelID {
+		t.Errorf("Expected the branch to keep model %q, got %q", source.ModelID, branched.ModelID)
+	}
+
+	// The two scenes are independent
+	if err := branched.Agent.GetSceneManager().RemoveShape("ball"); err != nil {
+		t.Fatalf("RemoveShape() failed: %v", err)
+	}
+	if sourceScene.FindShape("ball") == nil {
+		t.Errorf("Expected removing a shape from the branch to leave the source alone")
+	}
+
+	// History is copied only on request
+	if _, resp := branch(`{"session_id": "source", "copy_history": true}`); resp.MessageCount != 1 {
+		t.Errorf("Expected the conversation to be copied, got %d messages", resp.MessageCount)
+	}
+}
+
+func TestHandleBranchSessionErrors(t *testing.T) {
+	s := NewServer(0)
+
+	tests := []struct {
+		name   string
+		method string
+		body   string
+		status int
+	}{
+		{"unknown session", http.MethodPost, `{"session_id": "missing"}`, http.StatusNotFound},
+		{"missing session_id", http.MethodPost, `{}`, http.StatusBadRequest},
+		{"invalid JSON", http.MethodPost, `{`, http.StatusBadRequest},
+		{"wrong method", http.MethodGet, ``, http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			s.handleBranchSession(rec, httptest.NewRequest(tt.method, "/api/branch_session", bytes.NewBufferString(tt.body)))
+			if rec.Code != tt.status {
+				t.Errorf("Expected %d, got %d", tt.status, rec.Code)
+			}
+		})
+	}
+}
+
+func TestHandleCopyShape(t *testing.T) {
+	s := NewServer(0)
+	defer s.Stop(context.Background())
+	for _, id := range []string{"from", "to"} {
+		s.sessions[id] = &ChatSession{ID: id, Agent: agent.NewWithProvider(nil, nil, "mock-model"), ModelID: "mock-model"}
+	}
+	if err := s.sessions["from"].Agent.GetSceneManager().AddShapes([]agent.ShapeRequest{{ID: "ball", Type: "sphere", Properties: map[string]interface{}{
+		"center": []interface{}{0.0, 0.0, 0.0},
+		"radius": 1.0,
+	}}}); err != nil {
+		t.Fatalf("AddShapes() failed: %v", err)
+	}
+	copyShape := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		s.handleCopyShape(rec, httptest.NewRequest(http.MethodPost, "/api/copy_shape", bytes.NewBufferString(body)))
+		return rec
+	}
+	source, dest := s.sessions["from"], s.sessions["to"]
+
+	// The source is busy with a message whose turn may be changing the shape
+	source.cancel = func() {}
+	if rec := copyShape(`{"from_session": "from", "to_session": "to", "shape_id": "ball"}`); rec.Code != http.StatusConflict {
+		t.Errorf("Expected 409 while the source is processing a message, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if dest.Agent.GetSceneManager().FindShape("ball") != nil {
+		t.Errorf("Expected no shape to be copied from a busy session")
+	}
+	source.cancel = nil
+
+	// The destination is busy with a message, so its scene is left alone
+	dest.cancel = func() {}
+	if rec := copyShape(`{"from_session": "from", "to_session": "to", "shape_id": "ball"}`); rec.Code != http.StatusConflict {
+		t.Errorf("Expected 409 while the destination is processing a message, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if dest.Agent.GetSceneManager().FindShape("ball") != nil {
+		t.Errorf("Expected no shape to be copied into a busy session")
+	}
+	dest.cancel = nil
+
+	if rec := copyShape(`{"from_session": "from", "to_session": "to", "shape_id": "ball"}`); rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if dest.Agent.GetSceneManager().FindShape("ball") == nil {
+		t.Errorf("Expected the shape to be copied")
+	}
+	if rec := copyShape(`{"from_session": "from", "to_session": "to", "shape_id": "ball"}`); rec.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for an ID already in the destination, got %d", rec.Code)
+	}
+}
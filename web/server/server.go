@@ -2,16 +2,16 @@ package server
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
-	"os"
+	"strings"
 	"sync"
 
+	"github.com/df07/scene-llm/agent"
 	"github.com/df07/scene-llm/agent/llm"
-	"github.com/df07/scene-llm/agent/llm/claude"
-	"github.com/df07/scene-llm/agent/llm/gemini"
-	"github.com/df07/scene-llm/agent/llm/openrouter"
+	"github.com/df07/scene-llm/agent/llm/providers"
 )
 
 // Server handles web requests for the scene LLM
@@ -22,15 +22,31 @@ type Server struct {
 	sseClients  map[string]map[chan SSEChatEvent]bool // sessionID -> clients
 	mutex       sync.RWMutex
 	clientMutex sync.RWMutex
+
+	replayMutex sync.Mutex // Protects creating sessions' replay buffers
+
+	metrics serverMetrics // Activity counters for /api/metrics
+
+	httpServer *http.Server
+
+	// stopCtx is cancelled by Stop, which aborts message processing, renders and SSE streams.
+	// background tracks the goroutines started for that work so Stop can wait for them.
+	stopCtx         context.Context
+	stop            context.CancelFunc
+	background      sync.WaitGroup
+	backgroundMutex sync.Mutex // Orders startBackground against Stop
 }
 
 // NewServer creates a new web server
 func NewServer(port int) *Server {
-	return &Server{
+	s := &Server{
 		port:       port,
 		sessions:   make(map[string]*ChatSession),
 		sseClients: make(map[string]map[chan SSEChatEvent]bool),
 	}
+	s.stopCtx, s.stop = context.WithCancel(context.Background())
+	s.httpServer = &http.Server{Addr: fmt.Sprintf(":%d", port), Handler: s.routes()}
+	return s
 }
 
 // noCacheMiddleware adds no-cache headers to prevent browser caching during development
@@ -45,74 +61,106 @@ func noCacheMiddleware(next http.Handler) http.Handler {
 
 // initializeProviders initializes the LLM provider registry from environment variables
 func (s *Server) initializeProviders() error {
-	ctx := context.Background()
-	s.registry = llm.NewRegistry()
-
-	// Try to add Gemini provider
-	if apiKey := os.Getenv("GOOGLE_API_KEY"); apiKey != "" {
-		provider, err := gemini.NewProvider(ctx, apiKey)
-		if err != nil {
-			log.Printf("Warning: Failed to initialize Gemini provider: %v", err)
-		} else {
-			s.registry.Add(provider)
-			log.Printf("Initialized Gemini provider")
-		}
+	registry, err := providers.FromEnv(context.Background())
+	if err != nil {
+		return err
 	}
+	s.registry = registry
 
-	// Try to add Claude provider
-	if os.Getenv("ANTHROPIC_API_KEY") != "" {
-		provider, err := claude.NewProvider()
-		if err != nil {
-			log.Printf("Warning: Failed to initialize Claude provider: %v", err)
-		} else {
-			s.registry.Add(provider)
-			log.Printf("Initialized Claude provider")
-		}
-	}
+	log.Printf("Available models: %v", s.registry.ListModels())
+	return nil
+}
 
-	// Try to add OpenRouter provider
-	if os.Getenv("OPENROUTER_API_KEY") != "" {
-		provider, err := openrouter.NewProvider("")
-		if err != nil {
-			log.Printf("Warning: Failed to initialize OpenRouter provider: %v", err)
-		} else {
-			s.registry.Add(provider)
-			log.Printf("Initialized OpenRouter provider")
-		}
-	}
+// routes returns the handler for the server's static files and API endpoints
+func (s *Server) routes() http.Handler {
+	mux := http.NewServeMux()
 
-	// Validate at least one provider is available
-	if len(s.registry.ListModels()) == 0 {
-		return fmt.Errorf("no LLM providers available - set GOOGLE_API_KEY, ANTHROPIC_API_KEY, or OPENROUTER_API_KEY environment variable")
-	}
+	// Serve static files with no-cache headers for development
+	fs := http.FileServer(http.Dir("static/"))
+	mux.Handle("/", noCacheMiddleware(fs))
 
-	log.Printf("Available models: %v", s.registry.ListModels())
-	return nil
+	// API endpoints
+	mux.HandleFunc("/api/health", s.handleHealth)
+	mux.HandleFunc("/api/metrics", s.handleMetrics)
+	mux.HandleFunc("/api/models", s.handleModels)
+	mux.HandleFunc("/api/set_model", s.handleSetModel)
+	mux.HandleFunc("/api/tools", s.handleTools)
+	mux.HandleFunc("/api/chat", s.handleChat)
+	mux.HandleFunc("/api/chat/stream", s.handleChatStream)
+	mux.HandleFunc("/api/chat/interrupt", s.handleInterrupt)
+	mux.HandleFunc("/api/render", s.handleRender)
+	mux.HandleFunc("/api/image", s.handleImage)
+	mux.HandleFunc("/api/compare_renders", s.handleCompareRenders)
+	mux.HandleFunc("/api/history", s.handleHistory)
+	mux.HandleFunc("/api/copy_shape", s.handleCopyShape)
+	mux.HandleFunc("/api/branch_session", s.handleBranchSession)
+	return mux
 }
 
-// Start starts the web server
+// Start starts the web server and blocks until it fails or Stop shuts it down
 func (s *Server) Start() error {
 	// Initialize provider registry
 	if err := s.initializeProviders(); err != nil {
 		return err
 	}
 
-	// Serve static files with no-cache headers for development
-	fs := http.FileServer(http.Dir("static/"))
-	http.Handle("/", noCacheMiddleware(fs))
+	log.Printf("Starting server on %s", s.httpServer.Addr)
+	if err := s.httpServer.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
 
-	// API endpoints
-	http.HandleFunc("/api/health", s.handleHealth)
-	http.HandleFunc("/api/models", s.handleModels)
-	http.HandleFunc("/api/chat", s.handleChat)
-	http.HandleFunc("/api/chat/stream", s.handleChatStream)
-	http.HandleFunc("/api/chat/interrupt", s.handleInterrupt)
-	http.HandleFunc("/api/render", s.handleRender)
-
-	// Start server
-	addr := fmt.Sprintf(":%d", s.port)
-	log.Printf("Starting server on %s", addr)
-	return http.ListenAndServe(addr, nil)
+// Stop shuts the server down gracefully
+// It stops accepting connections, interrupts every session's message processing and any
+// other renders, closes SSE streams, and waits for that work to wind down. If ctx ends
+// first, Stop returns its error without waiting further.
+func (s *Server) Stop(ctx context.Context) error {
+	s.backgroundMutex.Lock()
+	s.stop()
+	s.backgroundMutex.Unlock()
+
+	// Processing contexts derive from stopCtx, but cancel them explicitly too
+	s.mutex.RLock()
+	for _, session := range s.sessions {
+		session.mutex.Lock()
+		if session.cancel != nil {
+			session.cancel()
+		}
+		session.mutex.Unlock()
+	}
+	s.mutex.RUnlock()
+
+	shutdownErr := s.httpServer.Shutdown(ctx)
+
+	done := make(chan struct{})
+	go func() {
+		s.background.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		log.Printf("Server stopped")
+		return shutdownErr
+	case <-ctx.Done():
+		return fmt.Errorf("timed out waiting for sessions and renders to stop: %w", ctx.Err())
+	}
+}
+
+// startBackground runs fn in a goroutine that Stop waits for
+// It returns false without running fn once the server is stopping.
+func (s *Server) startBackground(fn func()) bool {
+	s.backgroundMutex.Lock()
+	defer s.backgroundMutex.Unlock()
+	if s.stopCtx.Err() != nil {
+		return false
+	}
+	s.background.Add(1)
+	go func() {
+		defer s.background.Done()
+		fn()
+	}()
+	return true
 }
 
 // handleHealth returns server health status
@@ -122,37 +170,136 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(`{"status": "ok", "service": "scene-llm"}`))
 }
 
+// ModelSummary describes one available model for clients choosing between them
+type ModelSummary struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	Provider      string `json:"provider"`
+	Vision        bool   `json:"vision"`         // Accepts image attachments
+	Thinking      bool   `json:"thinking"`       // Supports a thinking budget
+	ContextWindow int    `json:"context_window"` // In tokens, 0 if unknown
+}
+
 // handleModels returns the list of available models grouped by provider
+// Format: { "google": [...], "claude": [...] }, each provider's models newest first
 func (s *Server) handleModels(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	grouped := s.registry.ListModelsGrouped()
+	response := make(map[string][]ModelSummary)
+	for provider, models := range s.registry.ListModelsGrouped() {
+		summaries := make([]ModelSummary, 0, len(models))
+		for _, model := range models {
+			summaries = append(summaries, ModelSummary{
+				ID:            model.ID,
+				Name:          model.DisplayName,
+				Provider:      model.Provider,
+				Vision:        model.Vision,
+				Thinking:      model.Thinking,
+				ContextWindow: model.ContextWindow,
+			})
+		}
+		response[provider] = summaries
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
 
-	// Build JSON response with grouped models
-	// Format: { "google": [...], "claude": [...] }
-	response := "{"
-	first := true
-	for provider, models := range grouped {
-		if !first {
-			response += ","
-		}
-		first = false
-
-		response += fmt.Sprintf(`"%s":[`, provider)
-		for i, model := range models {
-			if i > 0 {
-				response += ","
-			}
-			response += fmt.Sprintf(`{"id":"%s","name":"%s"}`, model.ID, model.DisplayName)
-		}
-		response += "]"
+// SetModelRequest switches the model an existing session uses
+type SetModelRequest struct {
+	SessionID string `json:"session_id"`
+	ModelID   string `json:"model_id"`
+}
+
+// handleSetModel switches a session to another available model, keeping its conversation and scene
+// A session that is processing a message can't switch until the message finishes.
+func (s *Server) handleSetModel(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Method not allowed"})
+		return
+	}
+
+	var req SetModelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid JSON"})
+		return
+	}
+	if req.SessionID == "" || req.ModelID == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "session_id and model_id are required"})
+		return
+	}
+
+	s.mutex.RLock()
+	session, exists := s.sessions[req.SessionID]
+	s.mutex.RUnlock()
+	if !exists {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Session not found"})
+		return
+	}
+
+	provider, err := s.registry.GetProviderForModel(req.ModelID)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": fmt.Sprintf("unknown model '%s' (available: %s)", req.ModelID, strings.Join(s.registry.ListModels(), ", "))})
+		return
 	}
-	response += "}"
 
-	w.Write([]byte(response))
+	session.mutex.Lock()
+	if session.cancel != nil {
+		session.mutex.Unlock()
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": "A message is still being processed for this session - wait for it to finish or interrupt it"})
+		return
+	}
+	session.Provider = provider
+	session.ModelID = req.ModelID
+	session.Agent.SetModel(provider, req.ModelID)
+	session.mutex.Unlock()
+	log.Printf("Session %s switched to model %s", session.ID, req.ModelID)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"session_id": session.ID,
+		"model_id":   req.ModelID,
+		"provider":   provider.Name(),
+	})
+}
+
+// ToolSchema describes one agent tool with its parameters as JSON Schema
+type ToolSchema struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Parameters  map[string]interface{} `json:"parameters"`
+}
+
+// handleTools returns the agent's tool definitions so external clients can discover them
+func (s *Server) handleTools(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	tools := agent.ToolDefinitions()
+	schemas := make([]ToolSchema, 0, len(tools))
+	for _, tool := range tools {
+		schemas = append(schemas, ToolSchema{
+			Name:        tool.Name,
+			Description: tool.Description,
+			Parameters:  tool.Parameters.JSONSchema(),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{"tools": schemas})
 }
@@ -0,0 +1,126 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandleModels(t *testing.T) {
+	s := newTestServer(&scriptedProvider{})
+	s.registry.Add(&blockingProvider{release: make(chan struct{})})
+
+	rec := httptest.NewRecorder()
+	s.handleModels(rec, httptest.NewRequest(http.MethodGet, "/api/models", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+
+	var grouped map[string][]ModelSummary
+	if err := json.Unmarshal(rec.Body.Bytes(), &grouped); err != nil {
+		t.Fatalf("Failed to decode models: %v", err)
+	}
+	expected := ModelSummary{ID: "scripted-model", Name: "Scripted Model", Provider: "scripted"}
+	if len(grouped) != 2 || len(grouped["scripted"]) != 1 || grouped["scripted"][0] != expected {
+		t.Errorf("Expected both providers with their models, got %+v", grouped)
+	}
+}
+
+func TestHandleSetModel(t *testing.T) {
+	provider := &blockingProvider{release: make(chan struct{})}
+	s := newTestServer(&scriptedProvider{})
+	s.registry.Add(provider)
+
+	code, response := postChatMessage(t, s, ChatMessage{Message: "Hi", ModelID: "scripted-model"})
+	if code != http.StatusOK {
+		t.Fatalf("Expected the message to be accepted, got %d %+v", code, response)
+	}
+	s.mutex.RLock()
+	session := s.sessions[response.SessionID]
+	s.mutex.RUnlock()
+	waitUntilIdle(t, session)
+
+	setModel := func(sessionID, modelID string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(SetModelRequest{SessionID: sessionID, ModelID: modelID})
+		rec := httptest.NewRecorder()
+		s.handleSetModel(rec, httptest.NewRequest(http.MethodPost, "/api/set_model", bytes.NewReader(body)))
+		return rec
+	}
+
+	if rec := setModel(session.ID, "missing-model"); rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "blocking-model") {
+		t.Errorf("Expected an unknown model to be rejected with the available ones, got %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := setModel("nowhere", "blocking-model"); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected an unknown session to be rejected, got %d", rec.Code)
+	}
+
+	if rec := setModel(session.ID, "blocking-model"); rec.Code != http.StatusOK {
+		t.Fatalf("Expected the switch to succeed, got %d %s", rec.Code, rec.Body.String())
+	}
+	if session.ModelID != "blocking-model" || session.Agent.ModelID() != "blocking-model" || session.Provider != provider {
+		t.Errorf("Expected the session and its agent to use the new model, got %q and %q", session.ModelID, session.Agent.ModelID())
+	}
+
+	// The next message goes to the new provider, which holds it until released
+	if code, response := postChat(t, s, session.ID, "Still there?"); code != http.StatusOK {
+		t.Fatalf("Expected the message to be accepted, got %d %+v", code, response)
+	}
+	if rec := setModel(session.ID, "scripted-model"); rec.Code != http.StatusConflict {
+		t.Errorf("Expected switching mid-message to be refused, got %d", rec.Code)
+	}
+	close(provider.release)
+	waitUntilIdle(t, session)
+	if len(session.Messages) != 4 {
+		t.Errorf("Expected the conversation to carry on across the switch, got %d messages", len(session.Messages))
+	}
+}
+
+func TestStopDrainsSessions(t *testing.T) {
+	// The provider is never released, so only Stop can end the turn
+	s := newTestServer(&blockingProvider{release: make(chan struct{})})
+
+	code, response := postChat(t, s, "", "Add a red ball")
+	if code != http.StatusOK {
+		t.Fatalf("Expected the message to be accepted, got %d %+v", code, response)
+	}
+	session := s.sessions[response.SessionID]
+
+	streamDone := make(chan struct{})
+	go func() {
+		defer close(streamDone)
+		rec := httptest.NewRecorder()
+		s.handleChatStream(rec, httptest.NewRequest(http.MethodGet, "/api/chat/stream?session_id="+session.ID, nil))
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.Stop(ctx); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+
+	// Stop only returns once processing has wound down
+	session.mutex.Lock()
+	idle := session.cancel == nil
+	session.mutex.Unlock()
+	if !idle {
+		t.Error("Expected the session to be idle after Stop")
+	}
+	if got := s.metrics.messagesProcessed.Load(); got != 1 {
+		t.Errorf("Expected the interrupted message to be counted as processed, got %d", got)
+	}
+
+	select {
+	case <-streamDone:
+	case <-time.After(5 * time.Second):
+		t.Error("Expected the SSE stream to close after Stop")
+	}
+
+	if code, response := postChat(t, s, session.ID, "Make it blue"); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 for a message after Stop, got %d %+v", code, response)
+	}
+}
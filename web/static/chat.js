@@ -2,6 +2,7 @@ class SceneLLMChat {
     constructor() {
         this.sessionId = null;
         this.eventSource = null;
+        this.lastEventSeq = 0; // Last numbered event seen, so a reconnect can replay what was missed
         this.isConnected = false;
         this.isProcessing = false;
         this.renderQuality = 'draft'; // default to fast/draft quality
@@ -98,6 +99,8 @@ class SceneLLMChat {
             this.selectedModel = e.target.value;
             // Save model preference
             localStorage.setItem('scene-llm-model', this.selectedModel);
+            // Switch the current conversation too, so the next message uses the new model
+            this.switchSessionModel();
         });
 
         // Auto-resize input and enable send on Enter
@@ -146,6 +149,10 @@ class SceneLLMChat {
                     const option = document.createElement('option');
                     option.value = model.id;
                     option.textContent = model.name;
+                    const features = [model.vision && 'image input', model.thinking && 'thinking'].filter(Boolean);
+                    if (features.length > 0) {
+                        option.title = `Supports ${features.join(' and ')}`;
+                    }
                     optgroup.appendChild(option);
                     allModelIds.push(model.id);
                 });
@@ -170,9 +177,29 @@ class SceneLLMChat {
         }
     }
 
+    async switchSessionModel() {
+        // New sessions pick up the selected model with their first message
+        if (!this.sessionId) return;
+
+        try {
+            const response = await fetch('/api/set_model', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ session_id: this.sessionId, model_id: this.selectedModel })
+            });
+            if (!response.ok) {
+                const data = await response.json();
+                this.addErrorMessage(`Couldn't switch model: ${data.error}`);
+            }
+        } catch (error) {
+            console.error('Failed to switch model:', error);
+        }
+    }
+
     async startNewSession() {
         // Start with a fresh session
         this.sessionId = null;
+        this.lastEventSeq = 0;
         this.connectSSE();
     }
 
@@ -187,7 +214,7 @@ class SceneLLMChat {
             return;
         }
 
-        const url = `/api/chat/stream?session_id=${this.sessionId}`;
+        const url = `/api/chat/stream?session_id=${this.sessionId}&since=${this.lastEventSeq}`;
         this.eventSource = new EventSource(url);
 
         this.eventSource.onopen = () => {
@@ -198,6 +225,9 @@ class SceneLLMChat {
         this.eventSource.onmessage = (event) => {
             try {
                 const data = JSON.parse(event.data);
+                if (data.seq) {
+                    this.lastEventSeq = data.seq;
+                }
                 this.handleSSEEvent(data);
             } catch (error) {
                 console.error('Failed to parse SSE event:', error);
@@ -254,7 +284,9 @@ class SceneLLMChat {
             });
 
             if (!response.ok) {
-                throw new Error(`HTTP ${response.status}`);
+                // Errors such as a busy session (409) explain themselves in the body
+                const data = await response.json().catch(() => ({}));
+                throw new Error(data.error || `HTTP ${response.status}`);
             }
 
             const data = await response.json();
@@ -344,6 +376,12 @@ class SceneLLMChat {
             case 'scene_update':
                 this.handleSceneUpdate(event.data);
                 break;
+            case 'render_progress':
+                this.handleRenderProgress(event.data);
+                break;
+            case 'render_cancelled':
+                this.hideRenderingIndicator();
+                break;
             case 'function_call_start':
                 this.handleFunctionCallStart(event.data);
                 break;
@@ -377,6 +415,9 @@ class SceneLLMChat {
             case 'ping':
                 // Keep-alive, ignore
                 break;
+            case 'telemetry':
+                console.debug('Turn telemetry:', event.data);
+                break;
             default:
                 console.log('Unknown SSE event type:', event.type);
         }
@@ -630,17 +671,29 @@ class SceneLLMChat {
             existingImage.classList.remove('rendering');
         }
         this.scenePreview.classList.remove('rendering');
+        delete this.scenePreview.dataset.progress;
     }
 
     handleRenderStart(data) {
         console.log('Render started:', data);
+        delete this.scenePreview.dataset.progress;
+        this.showRenderingIndicator();
+    }
+
+    handleRenderProgress(data) {
+        this.scenePreview.dataset.progress = `${data.percent}%`;
         this.showRenderingIndicator();
     }
 
     handleSceneUpdate(data) {
-        console.log('Scene update received:', { quality: data.quality, shape_count: data.shape_count });
+        console.log('Scene update received:', { quality: data.quality, shape_count: data.shape_count, thumbnail: data.thumbnail });
         if (data.image_base64) {
-            this.displaySceneImage(data.image_base64);
+            this.displaySceneImage(data.image_base64, data.mime_type);
+
+            // A thumbnail is followed by the full render, so keep showing that one is in progress
+            if (data.thumbnail) {
+                this.showRenderingIndicator();
+            }
         }
     }
 
@@ -922,7 +975,7 @@ class SceneLLMChat {
         );
     }
 
-    displaySceneImage(imageBase64) {
+    displaySceneImage(imageBase64, mimeType) {
         // Remove "No scene yet" placeholder
         const placeholder = this.scenePreview.querySelector('.no-scene-placeholder');
         if (placeholder) placeholder.remove();
@@ -941,7 +994,7 @@ class SceneLLMChat {
         // Add new image
         const img = document.createElement('img');
         img.className = 'scene-image';
-        img.src = `data:image/png;base64,${imageBase64}`;
+        img.src = `data:${mimeType || 'image/png'};base64,${imageBase64}`;
         img.alt = 'Generated 3D scene';
 
         // Add the image to the scene preview